@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"slices"
+
+	"github.com/marcoshack/netmonitor/internal/i18n"
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// desktopNotifier shows alerts in the app, and as system notifications
+// where the webview allows them, when notifications are enabled
+type desktopNotifier struct {
+	app *App
+}
+
+func (n desktopNotifier) Notify(ctx context.Context, alert models.Alert) error {
+	if n.app.ctx != nil && n.app.Config.Settings.NotificationsEnabled {
+		runtime.EventsEmit(n.app.ctx, "alert", alert)
+	}
+	return nil
+}
+
+// GetActiveAlerts returns the threshold and rule alerts firing, oldest first
+func (a *App) GetActiveAlerts() []models.Alert {
+	return a.Alerts.Active()
+}
+
+// GetAlertRules returns the configured alert rules
+func (a *App) GetAlertRules() []models.AlertRule {
+	if a.Config.Settings.Alerting == nil {
+		return []models.AlertRule{}
+	}
+	return append([]models.AlertRule{}, a.Config.Settings.Alerting.Rules...)
+}
+
+// SaveAlertRule adds a rule, or replaces the rule with the same name
+func (a *App) SaveAlertRule(rule models.AlertRule) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	cfg := *a.Config
+	settings := models.AlertSettings{}
+	if cfg.Settings.Alerting != nil {
+		settings = *cfg.Settings.Alerting
+	}
+	settings.Rules = slices.Clone(settings.Rules)
+	if i := slices.IndexFunc(settings.Rules, func(r models.AlertRule) bool { return r.Name == rule.Name }); i >= 0 {
+		settings.Rules[i] = rule
+	} else {
+		settings.Rules = append(settings.Rules, rule)
+	}
+	cfg.Settings.Alerting = &settings
+	return a.SaveConfig(cfg)
+}
+
+// DeleteAlertRule removes the named alert rule. Its alerts stop firing
+// without a resolved notification.
+func (a *App) DeleteAlertRule(name string) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	cfg := *a.Config
+	if cfg.Settings.Alerting == nil {
+		return i18n.T("error.rule_not_found", name)
+	}
+	settings := *cfg.Settings.Alerting
+	settings.Rules = slices.DeleteFunc(slices.Clone(settings.Rules), func(r models.AlertRule) bool { return r.Name == name })
+	if len(settings.Rules) == len(cfg.Settings.Alerting.Rules) {
+		return i18n.T("error.rule_not_found", name)
+	}
+	cfg.Settings.Alerting = &settings
+	return a.SaveConfig(cfg)
+}
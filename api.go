@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/api"
+	"github.com/marcoshack/netmonitor/internal/data"
+	"github.com/marcoshack/netmonitor/internal/i18n"
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+// apiBackend serves the app's state through the embedded HTTP API
+type apiBackend struct {
+	app *App
+}
+
+func (b apiBackend) Config() models.Configuration {
+	return *b.app.Config
+}
+
+// SaveConfig applies a config received through the API. The API settings
+// and the sync password are never changed through the API, since it serves
+// configs without them.
+func (b apiBackend) SaveConfig(cfg models.Configuration) error {
+	current := b.app.Config.Settings
+	cfg.Settings.API = current.API
+	if cfg.Settings.Sync != nil && cfg.Settings.Sync.Password == "" && current.Sync != nil {
+		cfg.Settings.Sync.Password = current.Sync.Password
+	}
+	if cfg.Settings.Alerting != nil && current.Alerting != nil {
+		for i, n := range cfg.Settings.Alerting.Notifiers {
+			j := slices.IndexFunc(current.Alerting.Notifiers, func(c models.NotifierSettings) bool { return c.Name == n.Name })
+			if n.SMTPPassword == "" && j >= 0 {
+				cfg.Settings.Alerting.Notifiers[i].SMTPPassword = current.Alerting.Notifiers[j].SMTPPassword
+			}
+		}
+	}
+	if msg := b.app.SaveConfig(cfg); msg != "" {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+func (b apiBackend) States() map[string]models.EndpointState {
+	return b.app.GetCurrentStates()
+}
+
+func (b apiBackend) Services() []models.ServiceStatus {
+	return b.app.GetServiceStatuses()
+}
+
+func (b apiBackend) Results(start, end time.Time) []models.TestResult {
+	res, _ := b.app.Storage.GetResultsForRange(start, end)
+	return b.app.filterResultsByCurrentConfig(res)
+}
+
+func (b apiBackend) Trends(days int) []models.LatencyTrend {
+	return b.app.GetLatencyTrends(days)
+}
+
+func (b apiBackend) EndpointSummary(id, window string) (models.EndpointSummary, error) {
+	return b.app.endpointSummary(id, window)
+}
+
+func (b apiBackend) LatencyDistribution(id, window string, bucketMs float64) (models.LatencyDistribution, error) {
+	return b.app.latencyDistribution(id, window, bucketMs)
+}
+
+func (b apiBackend) RegionMap(window string) []models.RegionMapEntry {
+	return b.app.GetRegionMap(window)
+}
+
+func (b apiBackend) Alerts() []models.Alert {
+	return b.app.GetActiveAlerts()
+}
+
+func (b apiBackend) AlertRules() []models.AlertRule {
+	return b.app.GetAlertRules()
+}
+
+func (b apiBackend) SaveAlertRule(rule models.AlertRule) error {
+	if msg := b.app.SaveAlertRule(rule); msg != "" {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// DeleteAlertRule fails with api.ErrNotFound when no rule has the name
+func (b apiBackend) DeleteAlertRule(name string) error {
+	if !slices.ContainsFunc(b.app.GetAlertRules(), func(r models.AlertRule) bool { return r.Name == name }) {
+		return api.ErrNotFound
+	}
+	if msg := b.app.DeleteAlertRule(name); msg != "" {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+func (b apiBackend) Exports() []models.ExportStatus {
+	return b.app.Exports.ListExports()
+}
+
+func (b apiBackend) CreateExport(req models.ExportRequest) (models.ExportStatus, error) {
+	return b.app.Exports.CreateExport(req)
+}
+
+func (b apiBackend) ExportStatus(id string) (models.ExportStatus, error) {
+	return b.app.Exports.ExportStatus(id)
+}
+
+func (b apiBackend) ExportAudit() ([]models.ExportAuditEntry, error) {
+	return b.app.Exports.Audit()
+}
+
+func (b apiBackend) LogLevel() string {
+	return b.app.GetLogLevel()
+}
+
+func (b apiBackend) SetLogLevel(level string) error {
+	if msg := b.app.SetLogLevel(level); msg != "" {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// PublicStatus summarizes the current state and last 24 hours of
+// availability of each region
+func (b apiBackend) PublicStatus() models.PublicStatus {
+	a := b.app
+	end := time.Now()
+	start := end.Add(-24 * time.Hour)
+	res, _ := a.Storage.GetResultsForRange(start, end)
+	availability := make(map[string]models.EndpointAvailability)
+	for _, av := range data.ComputeAvailability(res, a.endpointIDs(), start, end, a.testIntervals()) {
+		availability[av.EndpointID] = av
+	}
+	states := a.Monitor.CurrentStates()
+
+	status := models.PublicStatus{UpdatedAt: end.UnixMilli(), Regions: []models.RegionStatus{}}
+	for name, region := range a.Config.Regions {
+		var monitored, downtime int64
+		up, down := 0, 0
+		for _, ep := range region.Endpoints {
+			id := network.EndpointID(ep.Address, ep.Type)
+			monitored += availability[id].MonitoredMs
+			downtime += availability[id].DowntimeMs
+			if state, ok := states[id]; ok {
+				if state.Up {
+					up++
+				} else {
+					down++
+				}
+			}
+		}
+		rs := models.RegionStatus{Name: name, Status: data.RollupRegion(up, down)}
+		if monitored > 0 {
+			rs.AvailabilityPercent = float64(monitored-downtime) / float64(monitored) * 100
+		}
+		status.Regions = append(status.Regions, rs)
+	}
+	sort.Slice(status.Regions, func(i, j int) bool { return status.Regions[i].Name < status.Regions[j].Name })
+	return status
+}
+
+// CreateAPIToken adds an API token with the scope and returns it. The token
+// is only stored as a hash, so this is the only time it can be shown.
+func (a *App) CreateAPIToken(name string, scope string) models.NewAPIToken {
+	result := models.NewAPIToken{Name: name, Scope: scope}
+	if msg := a.writeDenied(); msg != "" {
+		result.Error = msg
+		return result
+	}
+	token, entry, err := api.NewToken(name, scope)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	cfg := *a.Config
+	settings := models.APISettings{}
+	if cfg.Settings.API != nil {
+		settings = *cfg.Settings.API
+	}
+	for _, t := range settings.Tokens {
+		if t.Name == name {
+			result.Error = i18n.T("error.token_exists", name)
+			return result
+		}
+	}
+	settings.Tokens = append(append([]models.APIToken(nil), settings.Tokens...), entry)
+	cfg.Settings.API = &settings
+	if msg := a.SaveConfig(cfg); msg != "" {
+		result.Error = msg
+		return result
+	}
+	result.Token = token
+	return result
+}
+
+// RevokeAPIToken removes the named API token
+func (a *App) RevokeAPIToken(name string) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	cfg := *a.Config
+	if cfg.Settings.API == nil {
+		return i18n.T("error.token_not_found", name)
+	}
+	settings := *cfg.Settings.API
+	settings.Tokens = nil
+	for _, t := range cfg.Settings.API.Tokens {
+		if t.Name != name {
+			settings.Tokens = append(settings.Tokens, t)
+		}
+	}
+	if len(settings.Tokens) == len(cfg.Settings.API.Tokens) {
+		return i18n.T("error.token_not_found", name)
+	}
+	cfg.Settings.API = &settings
+	return a.SaveConfig(cfg)
+}
@@ -2,18 +2,40 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync/atomic"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/marcoshack/netmonitor/internal/alerting"
+	"github.com/marcoshack/netmonitor/internal/api"
 	"github.com/marcoshack/netmonitor/internal/config"
 	"github.com/marcoshack/netmonitor/internal/data"
+	"github.com/marcoshack/netmonitor/internal/export"
+	"github.com/marcoshack/netmonitor/internal/geoip"
+	"github.com/marcoshack/netmonitor/internal/hooks"
+	"github.com/marcoshack/netmonitor/internal/hotkey"
+	"github.com/marcoshack/netmonitor/internal/i18n"
+	"github.com/marcoshack/netmonitor/internal/metrics"
 	"github.com/marcoshack/netmonitor/internal/models"
 	"github.com/marcoshack/netmonitor/internal/monitor"
+	"github.com/marcoshack/netmonitor/internal/network"
+	"github.com/marcoshack/netmonitor/internal/onboarding"
+	"github.com/marcoshack/netmonitor/internal/plugins"
+	"github.com/marcoshack/netmonitor/internal/replicate"
+	"github.com/marcoshack/netmonitor/internal/resolution"
+	"github.com/marcoshack/netmonitor/internal/routes"
+	"github.com/marcoshack/netmonitor/internal/services"
+	"github.com/marcoshack/netmonitor/internal/update"
 	"github.com/rs/zerolog/log"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 
+	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
 	stdruntime "runtime"
 
@@ -27,9 +49,51 @@ type App struct {
 	Config  *models.Configuration
 	Monitor *monitor.Monitor
 	Storage *data.Storage
+	Live    *data.Live
+	// Retention deletes expired results once a day
+	Retention *data.Janitor
+	Exports   *export.Manager
+	Tail      *export.Tail
+	Hooks     *hooks.Runner
+	Plugins   *plugins.Registry
+	GeoIP     *geoip.Enricher
+	Sync      *replicate.Replicator
+	API       *api.Server
+	Metrics   *metrics.Exporter
+	Alerts    *alerting.Engine
+	Updates   *update.Checker
+
+	// Services remembers the last status of each service to alert on changes
+	Services *services.Tracker
+
+	// resultsStop asks the result relay to drain and exit, resultsDone is
+	// closed once it has
+	resultsStop chan struct{}
+	resultsDone chan struct{}
+
+	// kiosk is set while the window shows the read-only dashboard
+	kiosk atomic.Bool
+
+	// readOnly is set by the --read-only flag, see IsReadOnly
+	readOnly bool
+
+	// offline is set by the --offline flag, see IsOffline
+	offline bool
+
+	// trayUpdate announces an available update in the tray
+	trayUpdate trayUpdate
+
+	// hotkeys holds the registered global hotkeys, see applyHotkeys
+	hotkeys hotkey.Manager
+
+	// windowHidden is set while the window is hidden to the tray
+	windowHidden atomic.Bool
+
 	// Paths
 	ConfigPath string
 	DataDir    string
+	// DefaultExportDir is used when the export directory setting is empty
+	DefaultExportDir string
 
 	// Logger Context (from main)
 	logCtx context.Context
@@ -47,22 +111,134 @@ func NewApp(ctx context.Context, appDir string) *App {
 	// We ignore error here because LoadConfig returns default if fail, or error if completely broken.
 	// Ideally we handle it.
 
+	if err := logger.SetComponentLevels(cfg.Settings.LogLevels); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Invalid log levels")
+	}
+
 	store := data.NewStorage(dataDir)
+	store.Ctx = logger.WithComponent(ctx, logger.ComponentStorage)
 
 	// Initialize Logger (already done in main, passed via ctx)
 	// logDir := "logs"
 	// _ = logger.Init(logDir)
 
-	mon := monitor.NewMonitor(ctx, cfg)
+	i18n.SetLocale(cfg.Settings.Locale)
 
-	return &App{
+	mon := monitor.NewMonitor(logger.WithComponent(ctx, logger.ComponentScheduler), cfg)
+	geo := geoip.NewEnricher(ctx)
+	if err := geo.Load(cfg.Settings.GeoIPCountryDB, cfg.Settings.GeoIPASNDB); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to load GeoIP databases")
+	}
+	// The resolution tracker runs inside the GeoIP enricher so the enricher
+	// can reuse the address it resolved
+	resolutions := resolution.NewTracker(ctx)
+	resolutions.OnChange = func(change models.ResolutionChange) {
+		if err := store.SaveResolutionChange(change); err != nil {
+			log.Ctx(store.Ctx).Error().Err(err).Msg("Failed to save resolution change")
+		}
+	}
+	routeTracker := routes.NewTracker(ctx)
+	routeTracker.OnChange = func(change models.RouteChange) {
+		if err := store.SaveRouteChange(change); err != nil {
+			log.Ctx(store.Ctx).Error().Err(err).Msg("Failed to save route change")
+		}
+	}
+	mon.Runner.Use(geo.Middleware(), resolutions.Middleware(), routeTracker.Middleware())
+	// Restore pauses from the previous session before the monitor is started
+	for _, reason := range cfg.Settings.PauseReasons {
+		mon.Pause(reason)
+	}
+
+	defaultExportDir := filepath.Join(appDir, "exports")
+	exportDir := defaultExportDir
+	if cfg.Settings.ExportDir != "" {
+		exportDir = cfg.Settings.ExportDir
+	}
+	exports := export.NewManager(logger.WithComponent(ctx, logger.ComponentExport), store, exportDir, cfg.Settings.ExportConcurrency)
+	exports.AuditPath = filepath.Join(appDir, "export-audit.ndjson")
+
+	tail := &export.Tail{}
+	if err := tail.SetPath(cfg.Settings.ResultsTailPath); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("path", cfg.Settings.ResultsTailPath).Msg("Failed to open results tail")
+	}
+
+	replicator := replicate.New(ctx, store, configPath)
+	if err := replicator.Configure(cfg.Settings.Sync); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to configure sync")
+	}
+
+	app := &App{
 		logCtx:     ctx,
 		Config:     cfg,
 		Monitor:    mon,
 		Storage:    store,
+		Live:       data.NewLive(),
+		Retention:  &data.Janitor{Ctx: logger.WithComponent(ctx, logger.ComponentRetention), Storage: store},
+		Services:   services.NewTracker(),
+		Exports:    exports,
+		Tail:       tail,
+		Hooks:      hooks.NewRunner(ctx),
+		GeoIP:      geo,
+		Sync:       replicator,
+		Updates:    update.New(Version),
+		Plugins:    plugins.Discover(ctx, filepath.Join(appDir, "plugins"), mon.Runner.Protocols),
 		ConfigPath: configPath,
 		DataDir:    dataDir,
+
+		DefaultExportDir: defaultExportDir,
+	}
+	exports.EndpointNames = app.endpointNames
+	exports.EndpointRegions = func() map[string]models.EndpointRegion { return config.EndpointRegions(app.Config) }
+	app.API = api.New(ctx, apiBackend{app})
+	app.API.Version = Version
+	if err := app.API.Configure(cfg.Settings.API); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to start API server")
+	}
+	app.Metrics = metrics.New(ctx, metricsSource{app})
+	if err := app.Metrics.Configure(cfg.Settings.Metrics); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to start metrics server")
+	}
+	alerting.Register(alerting.TypeDesktop, func(models.NotifierSettings) (alerting.Notifier, error) {
+		return desktopNotifier{app}, nil
+	})
+	app.Alerts = alerting.New(ctx)
+	if err := app.Alerts.Configure(cfg.Settings.Alerting); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to configure alerting")
+	}
+	mon.OnBatchProgress = func(status models.BatchStatus) {
+		if app.ctx != nil {
+			runtime.EventsEmit(app.ctx, "batch-progress", status)
+		}
 	}
+	mon.OnStateChange = func(ep models.Endpoint, state models.EndpointState) {
+		event := models.HookOnRecovered
+		if state.Degraded {
+			event = models.HookOnDegraded
+		} else if !state.Up {
+			event = models.HookOnDown
+		}
+		app.Hooks.Fire(app.Config.Hooks, event, ep, state)
+		app.API.Publish(api.EventAlert, api.AlertEvent{Event: event, Endpoint: ep, State: state})
+		app.checkServices()
+	}
+	mon.OnCertExpiring = func(ep models.Endpoint, state models.EndpointState) {
+		tags := state.LastResult.Tags
+		log.Ctx(ctx).Warn().Str("endpoint", ep.Name).Str("subject", tags[network.TagCertSubject]).
+			Str("not_after", tags[network.TagCertNotAfter]).Msg("Certificate expiring soon")
+		app.Hooks.Fire(app.Config.Hooks, models.HookOnCertExpiring, ep, state)
+		app.API.Publish(api.EventAlert, api.AlertEvent{Event: models.HookOnCertExpiring, Endpoint: ep, State: state})
+		if app.ctx != nil {
+			runtime.EventsEmit(app.ctx, "cert-expiring", ep, state)
+		}
+	}
+	mon.OnCycle = func(report models.CycleReport) {
+		if err := store.SaveCycleReport(report); err != nil {
+			log.Ctx(store.Ctx).Error().Err(err).Msg("Failed to save scheduler cycle report")
+		}
+		app.cleanupIfDue(time.Now())
+	}
+
+	return app
 }
 
 // Startup is called when the app starts. The context is saved
@@ -83,52 +259,157 @@ func (a *App) Startup(ctx context.Context) {
 
 	// Start Monitor
 	// Relay results to frontend
-	go func() {
-		for res := range a.Monitor.ResultsChan {
-			// Save to storage
-			_ = a.Storage.SaveResult(res)
-			// Emit event to frontend
-			runtime.EventsEmit(a.ctx, "test-result", res)
-		}
-	}()
+	a.applyTheme()
+
+	a.resultsStop = make(chan struct{})
+	a.resultsDone = make(chan struct{})
+	go a.relayResults()
+	go a.warmup()
+	go a.watchUpdates()
+	go a.watchPower()
+	if err := a.applyHotkeys(); err != nil {
+		log.Ctx(a.ctx).Warn().Err(err).Msg("Failed to register global hotkeys")
+	}
 
 	a.Monitor.Start()
 }
 
-// DomReady is called after the front-end is created.
-func (a *App) DomReady(ctx context.Context) {
-	// Restore Window Position if set
-	if a.Config.Settings.WindowX != -1 && a.Config.Settings.WindowY != -1 {
-		// Safety check for multi-monitor:
-		// If the user disconnected a monitor, coordinates might be off-screen.
-		// We use a heuristic: If only 1 screen is detected, we enforce positive coordinates.
-
-		screens, err := runtime.ScreenGetAll(a.ctx)
-		if err == nil && len(screens) == 1 {
-			// Single monitor setup
-			if a.Config.Settings.WindowX < 0 || a.Config.Settings.WindowY < 0 {
-				// Window was likely on a secondary monitor (top/left) that is gone.
-				runtime.WindowCenter(a.ctx)
-				return
-			}
+// warmup loads today's results in the background at launch, so the first
+// dashboard render finds them cached along with the current aggregates and
+// the last known endpoint states
+func (a *App) warmup() {
+	start := time.Now()
+	results, err := a.Storage.GetResultsForDay(start)
+	if err != nil {
+		log.Ctx(a.Storage.Ctx).Warn().Err(err).Msg("Failed to load today's results for warmup")
+		return
+	}
+	a.Live.Seed(results)
+	a.Monitor.SeedStates(results)
+	a.Monitor.SeedTraffic(a.Storage.Traffic(start))
+	log.Ctx(a.ctx).Info().Int("results", len(results)).Dur("duration", time.Since(start)).Msg("Caches warmed up")
+}
 
-			screen := screens[0]
-			// Check if out of bounds to the right/bottom
-			if a.Config.Settings.WindowX > screen.Size.Width || a.Config.Settings.WindowY > screen.Size.Height {
-				runtime.WindowCenter(a.ctx)
-				return
+// relayResults stores and forwards monitor results until Shutdown, then
+// flushes whatever is still buffered
+func (a *App) relayResults() {
+	defer close(a.resultsDone)
+	for {
+		select {
+		case res := <-a.Monitor.ResultsChan:
+			a.handleResult(res)
+		case <-a.resultsStop:
+			for {
+				select {
+				case res := <-a.Monitor.ResultsChan:
+					a.handleResult(res)
+				default:
+					return
+				}
 			}
 		}
+	}
+}
 
-		runtime.WindowSetPosition(a.ctx, a.Config.Settings.WindowX, a.Config.Settings.WindowY)
+func (a *App) handleResult(res models.TestResult) {
+	// Save to storage
+	if err := a.Storage.SaveResult(res); err != nil {
+		log.Ctx(a.Storage.Ctx).Error().Err(err).Msg("Failed to save result")
+	} else {
+		a.Live.Add(res)
 	}
+	a.Metrics.Observe(res)
+	a.Alerts.Observe(a.Config, res)
+	_ = a.Tail.Append(res)
+	a.API.Publish(api.EventResult, res)
+	// Emit event to frontend
+	runtime.EventsEmit(a.ctx, "test-result", res)
 }
 
-// Shutdown is called at termination
+// DomReady is called after the front-end is created.
+func (a *App) DomReady(ctx context.Context) {
+	// Kiosk mode starts fullscreen, where the saved placement doesn't apply
+	if a.kiosk.Load() {
+		return
+	}
+	displays, _ := a.displays()
+	p := config.PlaceWindow(a.Config.Settings, displays)
+	if p.Width > 0 && p.Height > 0 {
+		runtime.WindowSetSize(a.ctx, p.Width, p.Height)
+	}
+	if p.Center {
+		runtime.WindowCenter(a.ctx)
+	} else {
+		runtime.WindowSetPosition(a.ctx, p.X, p.Y)
+	}
+	// Maximise last so the window maximises on the display it was moved to
+	if p.Maximised {
+		runtime.WindowMaximise(a.ctx)
+	}
+}
+
+// displays returns the connected displays and the one the window is on
+func (a *App) displays() ([]models.Display, *models.Display) {
+	screens, err := runtime.ScreenGetAll(a.ctx)
+	if err != nil {
+		log.Ctx(a.ctx).Warn().Err(err).Msg("Failed to list displays")
+		return nil, nil
+	}
+	var current *models.Display
+	displays := make([]models.Display, 0, len(screens))
+	for _, s := range screens {
+		d := models.Display{Width: s.Size.Width, Height: s.Size.Height, Primary: s.IsPrimary, Count: len(screens)}
+		displays = append(displays, d)
+		if s.IsCurrent {
+			current = &d
+		}
+	}
+	return displays, current
+}
+
+// shutdownTimeout bounds how long Shutdown waits for aborted tests to
+// report their results
+const shutdownTimeout = 10 * time.Second
+
+// Shutdown is called at termination. Producers are stopped before the
+// consumers they feed: the monitor first so its last, cancelled results are
+// stored, then exports and hooks, and the results tail last.
 func (a *App) Shutdown(ctx context.Context) {
+	// Stop serving requests before the state they read goes away
+	if a.API != nil {
+		a.API.Stop()
+	}
+	if a.Metrics != nil {
+		a.Metrics.Stop()
+	}
+	a.hotkeys.Close()
 	if a.Monitor != nil {
-		a.Monitor.Stop()
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := a.Monitor.Shutdown(ctx); err != nil {
+			log.Ctx(a.ctx).Error().Err(err).Msg("Monitor did not shut down cleanly")
+		}
+		cancel()
+	}
+	if a.resultsStop != nil {
+		close(a.resultsStop)
+		<-a.resultsDone
+	}
+	if a.Exports != nil {
+		a.Exports.Stop()
+	}
+	if a.Hooks != nil {
+		a.Hooks.Stop()
 	}
+	if a.Alerts != nil {
+		a.Alerts.Stop()
+	}
+	if a.Sync != nil {
+		a.Sync.Stop()
+	}
+	if a.Tail != nil {
+		a.Tail.Close()
+	}
+	log.Ctx(a.ctx).Info().Msg("Shutdown complete")
 	// logger.Close() handled in main via defer
 }
 
@@ -139,6 +420,81 @@ func (a *App) GetConfig() models.Configuration {
 }
 
 func (a *App) SaveConfig(cfg models.Configuration) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	// Pauses are managed through PauseMonitoring/ResumeMonitoring, keep the monitor's view
+	cfg.Settings.PauseReasons = a.persistedPauseReasons()
+	// UI preferences are managed through SetUISettings
+	cfg.UI = a.Config.UI
+	cfg.Settings.OnboardingPending = a.Config.Settings.OnboardingPending
+	// Window placement is managed through WindowResized, the copy the
+	// frontend loaded is older than the window's last move
+	cfg.Settings.WindowWidth = a.Config.Settings.WindowWidth
+	cfg.Settings.WindowHeight = a.Config.Settings.WindowHeight
+	cfg.Settings.WindowX = a.Config.Settings.WindowX
+	cfg.Settings.WindowY = a.Config.Settings.WindowY
+	cfg.Settings.WindowMaximised = a.Config.Settings.WindowMaximised
+	cfg.Settings.WindowDisplay = a.Config.Settings.WindowDisplay
+	for _, h := range cfg.Hooks {
+		if err := hooks.Validate(h); err != nil {
+			return i18n.T("error.invalid_hook", err)
+		}
+	}
+	if cfg.Settings.API != nil {
+		if err := api.ValidateSettings(*cfg.Settings.API); err != nil {
+			return i18n.T("error.invalid_api", err)
+		}
+	}
+	if cfg.Settings.Metrics != nil {
+		if err := metrics.ValidateSettings(*cfg.Settings.Metrics); err != nil {
+			return i18n.T("error.invalid_metrics", err)
+		}
+	}
+	if cfg.Settings.Alerting != nil {
+		if err := alerting.ValidateSettings(*cfg.Settings.Alerting); err != nil {
+			return i18n.T("error.invalid_alerting", err)
+		}
+	}
+	if cfg.Settings.Locale != "" && i18n.Match(cfg.Settings.Locale) != cfg.Settings.Locale {
+		return i18n.T("error.invalid_locale", cfg.Settings.Locale)
+	}
+	if err := services.Validate(cfg); err != nil {
+		return i18n.T("error.invalid_services", err)
+	}
+	for _, h := range cfg.RetentionHolds {
+		if err := data.ValidateRetentionHold(h); err != nil {
+			return i18n.T("error.invalid_retention_hold", err)
+		}
+	}
+	if cfg.Settings.Health != nil {
+		if err := data.ValidateHealthWeights(*cfg.Settings.Health); err != nil {
+			return i18n.T("error.invalid_health", err)
+		}
+	}
+	if b := cfg.Settings.DataBudget; b != nil && (b.DailyMB < 0 || b.MonthlyMB < 0) {
+		return i18n.T("error.invalid_data_budget")
+	}
+	if _, err := a.hotkeyBindings(cfg.Settings.Hotkeys); err != nil {
+		return i18n.T("error.invalid_hotkey", err)
+	}
+	if err := logger.ValidateComponentLevels(cfg.Settings.LogLevels); err != nil {
+		return i18n.T("error.invalid_log_level", err)
+	}
+	for name, region := range cfg.Regions {
+		if region.Concurrency < 0 {
+			return i18n.T("error.invalid_region_concurrency", name)
+		}
+		if err := config.ValidateRegion(region); err != nil {
+			return i18n.T("error.invalid_region", name, err)
+		}
+	}
+	if cfg.Settings.ExportDir != a.Config.Settings.ExportDir && cfg.Settings.ExportDir != "" {
+		if err := export.ValidateDir(cfg.Settings.ExportDir); err != nil {
+			return i18n.T("error.invalid_export_dir", err)
+		}
+	}
+	i18n.SetLocale(cfg.Settings.Locale)
 	a.Config = &cfg         // Update in memory
 	a.Monitor.Config = &cfg // Update monitor config reference (simple pointer update)
 	// In robust app, better to use setter on monitor to restart ticker if interval changed
@@ -154,6 +510,36 @@ func (a *App) SaveConfig(cfg models.Configuration) string {
 		return err.Error()
 	}
 
+	if err := a.Tail.SetPath(cfg.Settings.ResultsTailPath); err != nil {
+		return i18n.T("error.results_tail", err)
+	}
+	if err := a.GeoIP.Load(cfg.Settings.GeoIPCountryDB, cfg.Settings.GeoIPASNDB); err != nil {
+		return i18n.T("error.geoip_load", err)
+	}
+	if err := a.Sync.Configure(cfg.Settings.Sync); err != nil {
+		return i18n.T("error.invalid_sync", err)
+	}
+	if err := a.API.Configure(cfg.Settings.API); err != nil {
+		return i18n.T("error.invalid_api", err)
+	}
+	if err := a.Metrics.Configure(cfg.Settings.Metrics); err != nil {
+		return i18n.T("error.invalid_metrics", err)
+	}
+	if err := a.Alerts.Configure(cfg.Settings.Alerting); err != nil {
+		return i18n.T("error.invalid_alerting", err)
+	}
+	_ = logger.SetComponentLevels(cfg.Settings.LogLevels) // Validated above
+	if err := a.applyHotkeys(); err != nil {
+		return i18n.T("error.hotkey_register", err)
+	}
+	exportDir := cfg.Settings.ExportDir
+	if exportDir == "" {
+		exportDir = a.DefaultExportDir
+	}
+	if err := a.Exports.SetDir(exportDir); err != nil {
+		return i18n.T("error.invalid_export_dir", err)
+	}
+
 	// Restart monitor to apply new settings (e.g. interval)
 	a.Monitor.Stop()
 	a.Monitor.Start()
@@ -173,6 +559,13 @@ func (a *App) GetHistory(dateStr string) []models.TestResult {
 }
 
 func (a *App) GetHistoryRange(durationStr string) []models.TestResult {
+	start, end := historyRange(durationStr)
+	res, _ := a.Storage.GetResultsForRange(start, end)
+	return a.filterResultsByCurrentConfig(res)
+}
+
+// historyRange converts the period names used by the frontend into a time range ending now
+func historyRange(durationStr string) (time.Time, time.Time) {
 	// durationStr: "24h", "168h" (week), "720h" (month)
 	// Or descriptive: "day", "week", "month"
 
@@ -191,9 +584,276 @@ func (a *App) GetHistoryRange(durationStr string) []models.TestResult {
 	default:
 		start = end.Add(-24 * time.Hour)
 	}
+	return start, end
+}
+
+// GetCurrentAggregates returns the aggregates of the current "hour" or
+// "day" of the configured endpoints, kept up to date as results arrive
+func (a *App) GetCurrentAggregates(period string) []models.AggregatedResult {
+	aggs := a.Live.Hour()
+	if period == "day" {
+		aggs = a.Live.Day()
+	}
+	ids := make(map[string]bool)
+	for _, id := range a.endpointIDs() {
+		ids[id] = true
+	}
+	current := aggs[:0]
+	for _, agg := range aggs {
+		if ids[agg.EndpointID] {
+			current = append(current, agg)
+		}
+	}
+	a.scoreHealth(current)
+	return current
+}
 
+// GetDataGaps reports periods without stored results for each configured endpoint
+func (a *App) GetDataGaps(durationStr string) []models.DataGap {
+	start, end := historyRange(durationStr)
 	res, _ := a.Storage.GetResultsForRange(start, end)
-	return a.filterResultsByCurrentConfig(res)
+	return data.DetectGaps(res, a.endpointIDs(), start, end, a.testIntervals())
+}
+
+// GetAvailability returns the availability of each configured endpoint,
+// not counting periods in which the monitor itself wasn't running, and
+// within business hours for endpoints of regions that set them
+func (a *App) GetAvailability(durationStr string) []models.EndpointAvailability {
+	start, end := historyRange(durationStr)
+	res, _ := a.Storage.GetResultsForRange(start, end)
+	return data.ComputeAvailabilityWithHours(res, a.endpointIDs(), start, end, a.testIntervals(), a.endpointSchedules())
+}
+
+// GetAggregatedHistory summarizes the results of the configured endpoints in
+// buckets of bucketMinutes, aligned to the local time of their region,
+// including a breakdown of failures by cause and the tests run within the
+// region's business hours
+func (a *App) GetAggregatedHistory(durationStr string, bucketMinutes int) []models.AggregatedResult {
+	if bucketMinutes <= 0 {
+		bucketMinutes = 60
+	}
+	start, end := historyRange(durationStr)
+	res, _ := a.Storage.GetResultsForRange(start, end)
+	aggs := data.AggregateLocal(a.filterResultsByCurrentConfig(res), time.Duration(bucketMinutes)*time.Minute, a.endpointLocations(), a.endpointSchedules())
+	a.scoreHealth(aggs)
+	return aggs
+}
+
+// endpointLocations returns the time zone of each endpoint's region, for
+// aggregates in local time
+func (a *App) endpointLocations() map[string]*time.Location {
+	locations := make(map[string]*time.Location)
+	for _, region := range a.Config.Regions {
+		loc, err := config.RegionLocation(region)
+		if err != nil {
+			continue
+		}
+		for _, ep := range region.Endpoints {
+			locations[a.GenerateEndpointID(ep.Address, ep.Type)] = loc
+		}
+	}
+	return locations
+}
+
+// endpointSchedules returns the business hours of each endpoint's region,
+// for the endpoints of regions that set them
+func (a *App) endpointSchedules() map[string]*data.Schedule {
+	schedules := make(map[string]*data.Schedule)
+	for _, region := range a.Config.Regions {
+		s, err := config.RegionSchedule(region)
+		if err != nil || s == nil {
+			continue
+		}
+		for _, ep := range region.Endpoints {
+			schedules[a.GenerateEndpointID(ep.Address, ep.Type)] = s
+		}
+	}
+	return schedules
+}
+
+// scoreHealth sets the health score of aggregates of configured endpoints,
+// against the latency threshold of their region
+func (a *App) scoreHealth(aggs []models.AggregatedResult) {
+	weights := data.DefaultHealthWeights
+	if w := a.Config.Settings.Health; w != nil {
+		weights = *w
+	}
+	data.ScoreHealth(aggs, a.latencyThresholds(), weights)
+}
+
+// LintConfig flags risky parts of cfg, such as timeouts longer than the
+// test interval, with localized messages. The UI passes unsaved edits.
+func (a *App) LintConfig(cfg models.Configuration) []models.LintIssue {
+	issues := config.Lint(cfg, a.Monitor.Runner.Protocols)
+	for i, issue := range issues {
+		issues[i].Message = i18n.T("lint."+issue.Code, issue.Region, issue.Endpoint, issue.Detail)
+	}
+	return issues
+}
+
+// GetServiceStatuses returns the rolled up status of each configured service
+func (a *App) GetServiceStatuses() []models.ServiceStatus {
+	return services.Rollup(*a.Config, a.Monitor.CurrentStates())
+}
+
+// GetRegionMap returns the status, position and time zone of every region,
+// with its availability and p95 latency over the window ("1h", "day",
+// "week" or "month", "day" otherwise), for the map view
+func (a *App) GetRegionMap(window string) []models.RegionMapEntry {
+	if !slices.Contains(models.SummaryWindows, window) {
+		window = "day"
+	}
+	start, end := historyRange(window)
+	res, _ := a.Storage.GetResultsForRange(start, end)
+	return data.RegionMap(a.Config, res, a.Monitor.CurrentStates(), start, end, a.testIntervals())
+}
+
+// checkServices alerts on services whose rolled up status changed
+func (a *App) checkServices() {
+	for _, s := range a.Services.Update(a.GetServiceStatuses()) {
+		log.Ctx(a.logCtx).Info().Str("service", s.Name).Str("status", s.Status).Int("up", s.Up).Int("total", s.Total).Msg("Service status changed")
+		a.API.Publish(api.EventService, s)
+		runtime.EventsEmit(a.ctx, "service-status", s)
+	}
+}
+
+// latencyThresholds maps the IDs of the configured endpoints to the latency
+// threshold of their region
+func (a *App) latencyThresholds() map[string]int {
+	thresholds := make(map[string]int)
+	for _, region := range a.Config.Regions {
+		for _, ep := range region.Endpoints {
+			thresholds[a.GenerateEndpointID(ep.Address, ep.Type)] = region.Thresholds.LatencyMs
+		}
+	}
+	return thresholds
+}
+
+// GetLatencyTrends reports the p95 latency trend of each configured endpoint
+// over the last days, 28 by default, flagging degrading endpoints
+func (a *App) GetLatencyTrends(days int) []models.LatencyTrend {
+	if days <= 0 {
+		days = 28
+	}
+	end := time.Now()
+	res, _ := a.Storage.GetResultsForRange(end.AddDate(0, 0, -days), end)
+	return data.LatencyTrends(a.filterResultsByCurrentConfig(res), a.latencyThresholds())
+}
+
+// GetEndpointSummary returns the current status and the statistics of the
+// window ("1h", "day", "week" or "month") of a configured endpoint in one
+// call, with its latency trend over the last 28 days
+func (a *App) GetEndpointSummary(endpointID string, window string) models.EndpointSummary {
+	summary, err := a.endpointSummary(endpointID, window)
+	if err != nil {
+		log.Ctx(a.ctx).Warn().Err(err).Str("endpoint", endpointID).Msg("Summary requested for unknown endpoint")
+		return models.EndpointSummary{EndpointID: endpointID, Window: window}
+	}
+	return summary
+}
+
+func (a *App) endpointSummary(endpointID, window string) (models.EndpointSummary, error) {
+	name, err := a.checkEndpointWindow(endpointID, window)
+	if err != nil {
+		return models.EndpointSummary{}, err
+	}
+	start, end := historyRange(window)
+	// One read covers both the window and the trend's 28 days
+	trendStart := end.AddDate(0, 0, -28)
+	readStart := start
+	if trendStart.Before(start) {
+		readStart = trendStart
+	}
+	res, _ := a.Storage.GetResultsForRange(readStart, end)
+
+	summary := data.SummarizeEndpoint(res, endpointID, start, end, a.testIntervals())
+	summary.Name, summary.Window = name, window
+	if state, ok := a.Monitor.CurrentStates()[endpointID]; ok {
+		summary.State = &state
+	}
+	var own []models.TestResult
+	for _, r := range res {
+		if r.Id == endpointID && r.Ts >= trendStart.UnixMilli() {
+			own = append(own, r)
+		}
+	}
+	if trends := data.LatencyTrends(own, a.latencyThresholds()); len(trends) > 0 {
+		summary.Trend = &trends[0]
+	}
+	return summary, nil
+}
+
+// GetLatencyDistribution bins the latencies of a configured endpoint's
+// successful tests in the window ("1h", "day", "week" or "month") into
+// buckets of bucketMs, or of an automatic size when zero
+func (a *App) GetLatencyDistribution(endpointID string, window string, bucketMs float64) models.LatencyDistribution {
+	dist, err := a.latencyDistribution(endpointID, window, bucketMs)
+	if err != nil {
+		log.Ctx(a.ctx).Warn().Err(err).Str("endpoint", endpointID).Msg("Latency distribution requested for unknown endpoint")
+		return models.LatencyDistribution{EndpointID: endpointID, Window: window}
+	}
+	return dist
+}
+
+func (a *App) latencyDistribution(endpointID, window string, bucketMs float64) (models.LatencyDistribution, error) {
+	if _, err := a.checkEndpointWindow(endpointID, window); err != nil {
+		return models.LatencyDistribution{}, err
+	}
+	start, end := historyRange(window)
+	res, _ := a.Storage.GetResultsForRange(start, end)
+	dist := data.LatencyDistribution(res, endpointID, start, end, bucketMs)
+	dist.Window = window
+	return dist, nil
+}
+
+// checkEndpointWindow checks that the endpoint is configured and the window
+// is one of models.SummaryWindows, and returns the endpoint's name
+func (a *App) checkEndpointWindow(endpointID, window string) (string, error) {
+	name, ok := a.endpointNames()[endpointID]
+	if !ok {
+		return "", fmt.Errorf("unknown endpoint %q", endpointID)
+	}
+	if !slices.Contains(models.SummaryWindows, window) {
+		return "", fmt.Errorf("unknown window %q", window)
+	}
+	return name, nil
+}
+
+// GetDataUsage reports the traffic of tests run today and this month, against
+// the data budget if one is set
+func (a *App) GetDataUsage() models.DataUsage {
+	return a.Monitor.DataUsage()
+}
+
+// GetStorageStats reports the disk usage of the stored history per endpoint
+// and month. Endpoints no longer configured are listed by ID only.
+func (a *App) GetStorageStats() models.StorageStats {
+	stats, err := a.Storage.Stats()
+	if err != nil {
+		log.Ctx(a.ctx).Warn().Err(err).Msg("Failed to compute storage stats")
+	}
+	names := a.endpointNames()
+	for i := range stats.Endpoints {
+		stats.Endpoints[i].Name = names[stats.Endpoints[i].Key]
+	}
+	return stats
+}
+
+// testIntervals returns the test interval of each configured endpoint, to
+// tell data gaps from endpoints tested less often
+func (a *App) testIntervals() data.Intervals {
+	intervals := data.Intervals{Default: time.Duration(a.Config.Settings.TestIntervalSeconds) * time.Second}
+	for _, region := range a.Config.Regions {
+		for _, ep := range region.Endpoints {
+			if ep.IntervalSeconds > 0 {
+				if intervals.Endpoints == nil {
+					intervals.Endpoints = make(map[string]time.Duration)
+				}
+				intervals.Endpoints[a.GenerateEndpointID(ep.Address, ep.Type)] = time.Duration(ep.IntervalSeconds) * time.Second
+			}
+		}
+	}
+	return intervals
 }
 
 func (a *App) filterResultsByCurrentConfig(results []models.TestResult) []models.TestResult {
@@ -214,33 +874,134 @@ func (a *App) filterResultsByCurrentConfig(results []models.TestResult) []models
 	return filtered
 }
 
+// endpointNames maps the IDs of the configured endpoints to their names
+func (a *App) endpointNames() map[string]string {
+	names := make(map[string]string)
+	for _, region := range a.Config.Regions {
+		for _, ep := range region.Endpoints {
+			names[a.GenerateEndpointID(ep.Address, ep.Type)] = ep.Name
+		}
+	}
+	return names
+}
+
+// endpointIDs returns the sorted IDs of the configured endpoints
+func (a *App) endpointIDs() []string {
+	names := a.endpointNames()
+	ids := make([]string, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// GetCurrentStates returns the last known state of each configured endpoint, keyed by endpoint ID
+func (a *App) GetCurrentStates() map[string]models.EndpointState {
+	states := a.Monitor.CurrentStates()
+	names := a.endpointNames()
+	for id := range states {
+		if _, ok := names[id]; !ok {
+			delete(states, id)
+		}
+	}
+	return states
+}
+
 func (a *App) ManualTest(endpoint models.Endpoint) models.TestResult {
 	return a.Monitor.TestEndpoint(endpoint)
 }
 
+const maxAdHocTimeoutMs = 30000
+
+// RunAdHocTest tests a target that isn't configured as an endpoint, e.g. to
+// quickly check whether 10.0.0.5:443 is reachable. The result is not stored.
+func (a *App) RunAdHocTest(cfg models.TestConfig) models.AdHocTestResult {
+	if cfg.Address == "" {
+		return models.AdHocTestResult{Error: i18n.T("error.address_required")}
+	}
+	if err := a.Monitor.Runner.Protocols.Validate(cfg.Type, cfg.Address); err != nil {
+		return models.AdHocTestResult{Error: i18n.T("error.invalid_test", err)}
+	}
+	if cfg.Timeout <= 0 {
+		proto, _ := a.Monitor.Runner.Protocols.Lookup(cfg.Type)
+		cfg.Timeout = proto.DefaultTimeoutMs
+	}
+	cfg.Timeout = min(cfg.Timeout, maxAdHocTimeoutMs)
+
+	res, err := a.Monitor.Test(models.Endpoint{
+		Name:    "Ad-hoc test",
+		Type:    cfg.Type,
+		Address: cfg.Address,
+		Timeout: cfg.Timeout,
+	})
+	if err != nil {
+		return models.AdHocTestResult{Result: res, Error: err.Error()}
+	}
+	return models.AdHocTestResult{Result: res}
+}
+
+// StartBatchTest tests the given endpoints, or every configured endpoint when
+// none are given, in the background. Progress is emitted as "batch-progress"
+// events and can be polled with GetBatchStatus.
+func (a *App) StartBatchTest(endpoints []models.Endpoint) models.BatchStatus {
+	if len(endpoints) == 0 {
+		for _, region := range a.Config.Regions {
+			endpoints = append(endpoints, region.Endpoints...)
+		}
+	}
+	return a.Monitor.StartBatch(endpoints)
+}
+
+func (a *App) GetBatchStatus(id string) models.BatchStatus {
+	status, err := a.Monitor.BatchStatus(id)
+	if err != nil {
+		log.Ctx(a.ctx).Warn().Err(err).Msg("Batch status requested for unknown batch")
+		return models.BatchStatus{ID: id}
+	}
+	return status
+}
+
+func (a *App) CancelBatchTest(id string) string {
+	err := a.Monitor.CancelBatch(id)
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
 func (a *App) GetRegions() map[string]models.Region {
 	return a.Config.Regions
 }
 
+// WindowResized saves the window placement, restored by DomReady at the
+// next start. While maximised only the flag is saved, so the size and
+// position it restores down to are kept.
 func (a *App) WindowResized() {
-	if a.ctx == nil {
+	if a.ctx == nil || a.kiosk.Load() || runtime.WindowIsMinimised(a.ctx) || runtime.WindowIsFullscreen(a.ctx) {
 		return
 	}
-	width, height := runtime.WindowGetSize(a.ctx)
-	x, y := runtime.WindowGetPosition(a.ctx)
-	a.Config.Settings.WindowWidth = width
-	a.Config.Settings.WindowHeight = height
-	a.Config.Settings.WindowX = x
-	a.Config.Settings.WindowY = y
+	a.Config.Settings.WindowMaximised = runtime.WindowIsMaximised(a.ctx)
+	if !a.Config.Settings.WindowMaximised {
+		width, height := runtime.WindowGetSize(a.ctx)
+		x, y := runtime.WindowGetPosition(a.ctx)
+		a.Config.Settings.WindowWidth = width
+		a.Config.Settings.WindowHeight = height
+		a.Config.Settings.WindowX = x
+		a.Config.Settings.WindowY = y
+	}
+	if _, current := a.displays(); current != nil {
+		a.Config.Settings.WindowDisplay = current
+	}
 	_ = config.SaveConfig(a.ConfigPath, a.Config)
 }
 
 func (a *App) AddEndpoint(endpoint models.Endpoint) string {
-	if endpoint.Name == "" || endpoint.Address == "" {
-		return "Name and Address are required"
+	if msg := a.writeDenied(); msg != "" {
+		return msg
 	}
-	if endpoint.Timeout <= 0 {
-		return "Timeout must be greater than 0"
+	if msg := a.validateEndpoint(endpoint); msg != "" {
+		return msg
 	}
 
 	// Generate ID (though currently not stored in Endpoint struct in config,
@@ -249,13 +1010,13 @@ func (a *App) AddEndpoint(endpoint models.Endpoint) string {
 
 	// Add to default region
 	region := a.Config.Regions["Default"]
-	region.Endpoints = append(region.Endpoints, endpoint)
+	region.Endpoints = config.InsertEndpoint(region.Endpoints, endpoint)
 	a.Config.Regions["Default"] = region
 
 	// Save
 	err := config.SaveConfig(a.ConfigPath, a.Config)
 	if err != nil {
-		return "Failed to save config: " + err.Error()
+		return i18n.T("error.save_config", err)
 	}
 
 	// Restart Monitor
@@ -266,43 +1027,174 @@ func (a *App) AddEndpoint(endpoint models.Endpoint) string {
 	return ""
 }
 
+// CloneEndpoint adds a copy of the endpoint named name to its region, with
+// the non-zero fields of overrides replacing the copied ones, e.g. only the
+// address of an HTTP check of another path. The copy is named after the
+// original with a numeric suffix unless overrides names it.
+func (a *App) CloneEndpoint(regionName string, name string, overrides models.Endpoint) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	clone, err := config.CloneEndpoint(a.Config, regionName, name, overrides)
+	switch {
+	case errors.Is(err, config.ErrRegionNotFound):
+		return i18n.T("error.region_not_found")
+	case errors.Is(err, config.ErrEndpointNotFound):
+		return i18n.T("error.endpoint_not_found")
+	case errors.Is(err, config.ErrDuplicateEndpoint):
+		return i18n.T("error.duplicate_endpoint", err)
+	case err != nil:
+		return i18n.T("error.invalid_endpoint", err)
+	}
+	if msg := a.validateEndpoint(clone); msg != "" {
+		return msg
+	}
+
+	region := a.Config.Regions[regionName]
+	region.Endpoints = config.InsertEndpoint(region.Endpoints, clone)
+	a.Config.Regions[regionName] = region
+	if err := config.SaveConfig(a.ConfigPath, a.Config); err != nil {
+		return i18n.T("error.save_config", err)
+	}
+	log.Ctx(a.logCtx).Info().Str("region", regionName).Str("endpoint", name).Str("clone", clone.Name).Msg("Endpoint cloned")
+
+	a.Monitor.Stop()
+	a.Monitor.Config = a.Config
+	a.Monitor.Start()
+	return ""
+}
+
+// validateEndpoint checks a new endpoint against its protocol, returning a
+// localized message if it is invalid
+func (a *App) validateEndpoint(endpoint models.Endpoint) string {
+	if endpoint.Name == "" || endpoint.Address == "" {
+		return i18n.T("error.endpoint_required")
+	}
+	if endpoint.Timeout <= 0 {
+		return i18n.T("error.timeout_positive")
+	}
+	if endpoint.IntervalSeconds < 0 {
+		return i18n.T("error.interval_negative")
+	}
+	if err := a.Monitor.Runner.Protocols.Validate(endpoint.Type, endpoint.Address); err != nil {
+		return i18n.T("error.invalid_endpoint", err)
+	}
+	if msg := a.validateConfirm(endpoint); msg != "" {
+		return msg
+	}
+	if err := network.ValidateHTTPOptions(endpoint.HTTP); err != nil {
+		return i18n.T("error.invalid_http_options", err)
+	}
+	if err := network.ValidateTCPOptions(endpoint.TCP); err != nil {
+		return i18n.T("error.invalid_tcp_options", err)
+	}
+	if err := network.ValidateUDPOptions(endpoint.UDP); err != nil {
+		return i18n.T("error.invalid_udp_options", err)
+	}
+	if err := network.ValidateDNSOptions(endpoint.DNS); err != nil {
+		return i18n.T("error.invalid_dns_options", err)
+	}
+	if err := network.ValidateICMPOptions(endpoint.ICMP); err != nil {
+		return i18n.T("error.invalid_icmp_options", err)
+	}
+	if err := a.Monitor.Runner.Protocols.ValidateAllAddresses(endpoint); err != nil {
+		return i18n.T("error.invalid_endpoint", err)
+	}
+	return ""
+}
+
+// validateConfirm checks the endpoint's confirmation probe, if it has one
+func (a *App) validateConfirm(ep models.Endpoint) string {
+	if ep.Confirm == nil {
+		return ""
+	}
+	if ep.Confirm.Address == "" {
+		return i18n.T("error.endpoint_required")
+	}
+	if err := a.Monitor.Runner.Protocols.Validate(ep.Confirm.Type, ep.Confirm.Address); err != nil {
+		return i18n.T("error.invalid_confirm", err)
+	}
+	return ""
+}
+
 func (a *App) GenerateEndpointID(address string, protocol models.EndpointType) string {
-	idData := address + string(protocol)
-	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(idData)).String()[:7]
+	return network.EndpointID(address, protocol)
 }
 
 func (a *App) UpdateEndpoint(oldAddress string, oldType string, updatedEndpoint models.Endpoint) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	if updatedEndpoint.IntervalSeconds < 0 {
+		return i18n.T("error.interval_negative")
+	}
+	if err := a.Monitor.Runner.Protocols.Validate(updatedEndpoint.Type, updatedEndpoint.Address); err != nil {
+		return i18n.T("error.invalid_endpoint", err)
+	}
+	if msg := a.validateConfirm(updatedEndpoint); msg != "" {
+		return msg
+	}
+	if err := network.ValidateHTTPOptions(updatedEndpoint.HTTP); err != nil {
+		return i18n.T("error.invalid_http_options", err)
+	}
+	if err := network.ValidateTCPOptions(updatedEndpoint.TCP); err != nil {
+		return i18n.T("error.invalid_tcp_options", err)
+	}
+	if err := network.ValidateUDPOptions(updatedEndpoint.UDP); err != nil {
+		return i18n.T("error.invalid_udp_options", err)
+	}
+	if err := network.ValidateDNSOptions(updatedEndpoint.DNS); err != nil {
+		return i18n.T("error.invalid_dns_options", err)
+	}
+	if err := network.ValidateICMPOptions(updatedEndpoint.ICMP); err != nil {
+		return i18n.T("error.invalid_icmp_options", err)
+	}
+	if err := a.Monitor.Runner.Protocols.ValidateAllAddresses(updatedEndpoint); err != nil {
+		return i18n.T("error.invalid_endpoint", err)
+	}
+
 	// Find the endpoint in the Default region using old values
 	region, ok := a.Config.Regions["Default"]
 
 	if !ok {
-		return "Default region not found"
+		return i18n.T("error.default_region_not_found")
 	}
 
 	found := false
 	for i, ep := range region.Endpoints {
 		// Use oldAddress and oldType to identify which one to update
 		if ep.Address == oldAddress && string(ep.Type) == oldType {
+			services.RenameMember(a.Config, "Default", ep.Name, updatedEndpoint.Name)
 			// Update fields - including Address and Type now
 			region.Endpoints[i].Name = updatedEndpoint.Name
 			region.Endpoints[i].Timeout = updatedEndpoint.Timeout
+			region.Endpoints[i].IntervalSeconds = updatedEndpoint.IntervalSeconds
+			region.Endpoints[i].Priority = updatedEndpoint.Priority
 			region.Endpoints[i].Address = updatedEndpoint.Address
 			region.Endpoints[i].Type = updatedEndpoint.Type
+			region.Endpoints[i].Confirm = updatedEndpoint.Confirm
+			region.Endpoints[i].HTTP = updatedEndpoint.HTTP
+			region.Endpoints[i].TCP = updatedEndpoint.TCP
+			region.Endpoints[i].UDP = updatedEndpoint.UDP
+			region.Endpoints[i].DNS = updatedEndpoint.DNS
+			region.Endpoints[i].ICMP = updatedEndpoint.ICMP
+			region.Endpoints[i].AllAddresses = updatedEndpoint.AllAddresses
 			found = true
 			break
 		}
 	}
 
 	if !found {
-		return "Endpoint not found"
+		return i18n.T("error.endpoint_not_found")
 	}
+	config.SortEndpoints(region.Endpoints)
 
 	a.Config.Regions["Default"] = region
 
 	// Save
 	err := config.SaveConfig(a.ConfigPath, a.Config)
 	if err != nil {
-		return "Failed to save config: " + err.Error()
+		return i18n.T("error.save_config", err)
 	}
 
 	// Restart Monitor
@@ -314,9 +1206,12 @@ func (a *App) UpdateEndpoint(oldAddress string, oldType string, updatedEndpoint
 }
 
 func (a *App) DeleteEndpoint(address string, endpointType string) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
 	region, ok := a.Config.Regions["Default"]
 	if !ok {
-		return "Default region not found"
+		return i18n.T("error.default_region_not_found")
 	}
 
 	found := false
@@ -325,13 +1220,14 @@ func (a *App) DeleteEndpoint(address string, endpointType string) string {
 	for _, ep := range region.Endpoints {
 		if ep.Address == address && string(ep.Type) == endpointType {
 			found = true
+			services.RemoveMember(a.Config, "Default", ep.Name)
 			continue // Skip this one to delete it
 		}
 		newEndpoints = append(newEndpoints, ep)
 	}
 
 	if !found {
-		return "Endpoint not found"
+		return i18n.T("error.endpoint_not_found")
 	}
 
 	region.Endpoints = newEndpoints
@@ -339,7 +1235,7 @@ func (a *App) DeleteEndpoint(address string, endpointType string) string {
 
 	err := config.SaveConfig(a.ConfigPath, a.Config)
 	if err != nil {
-		return "Failed to save config: " + err.Error()
+		return i18n.T("error.save_config", err)
 	}
 
 	// Restart Monitor
@@ -350,10 +1246,43 @@ func (a *App) DeleteEndpoint(address string, endpointType string) string {
 	return ""
 }
 
+// SetRegionConcurrency limits how many endpoints of a region are tested at
+// the same time. Zero removes the limit.
+func (a *App) SetRegionConcurrency(regionName string, limit int) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	if limit < 0 {
+		return i18n.T("error.concurrency_negative")
+	}
+	region, ok := a.Config.Regions[regionName]
+	if !ok {
+		return i18n.T("error.region_not_found")
+	}
+	region.Concurrency = limit
+	a.Config.Regions[regionName] = region
+
+	if err := config.SaveConfig(a.ConfigPath, a.Config); err != nil {
+		return i18n.T("error.save_config", err)
+	}
+
+	a.Monitor.Stop()
+	a.Monitor.Config = a.Config
+	a.Monitor.Start()
+
+	return ""
+}
+
+// ReorderEndpoints saves the order of a region's endpoints. Higher
+// priorities still list first, so the order only applies among endpoints of
+// equal priority.
 func (a *App) ReorderEndpoints(regionName string, newOrderIDs []string) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
 	region, ok := a.Config.Regions[regionName]
 	if !ok {
-		return "Region not found"
+		return i18n.T("error.region_not_found")
 	}
 
 	endpointMap := make(map[string]models.Endpoint)
@@ -370,39 +1299,413 @@ func (a *App) ReorderEndpoints(regionName string, newOrderIDs []string) string {
 		}
 	}
 
-	if len(newEndpoints) != len(region.Endpoints) {
-		for _, ep := range endpointMap {
+	// Endpoints missing from the new order keep their relative order
+	for _, ep := range region.Endpoints {
+		if _, left := endpointMap[a.GenerateEndpointID(ep.Address, ep.Type)]; left {
 			newEndpoints = append(newEndpoints, ep)
 		}
 	}
+	// Priorities come before the dragged order
+	config.SortEndpoints(newEndpoints)
 
 	region.Endpoints = newEndpoints
 	a.Config.Regions[regionName] = region
 
 	err := config.SaveConfig(a.ConfigPath, a.Config)
 	if err != nil {
-		return "Failed to save config: " + err.Error()
+		return i18n.T("error.save_config", err)
 	}
 
 	return ""
 }
 
 func (a *App) OpenLogDirectory() {
-	path := logger.GetLogPath()
-	dir := filepath.Dir(path)
+	openFolder(filepath.Dir(logger.GetLogPath()), "")
+}
+
+// GetLogLevel returns the current log level
+func (a *App) GetLogLevel() string {
+	return logger.Level()
+}
+
+// SetLogLevel changes the log level until the app restarts, so verbose
+// logging can capture an incident without restarting with -debug
+func (a *App) SetLogLevel(level string) string {
+	previous := logger.Level()
+	if err := logger.SetLevel(level); err != nil {
+		return i18n.T("error.invalid_log_level", err)
+	}
+	// Logged without a level so it shows whatever the new level is
+	log.Ctx(a.ctx).Log().Str("from", previous).Str("to", level).Msg("Log level changed")
+	return ""
+}
+
+// RevealExport opens the file manager at a completed export's file, or at
+// the export directory when id is empty
+func (a *App) RevealExport(id string) string {
+	if id == "" {
+		openFolder(a.Exports.Dir(), "")
+		return ""
+	}
+	status, err := a.Exports.ExportStatus(id)
+	if err != nil {
+		return err.Error()
+	}
+	if status.Path == "" {
+		return i18n.T("error.export_not_ready")
+	}
+	if _, err := os.Stat(status.Path); err != nil {
+		return i18n.T("error.export_missing", status.Path)
+	}
+	openFolder(filepath.Dir(status.Path), status.Path)
+	return ""
+}
 
+// openFolder shows dir in the platform file manager, selecting file where
+// the file manager supports it
+func openFolder(dir, file string) {
 	var cmd *exec.Cmd
 	switch stdruntime.GOOS {
 	case "windows":
-		cmd = exec.Command("explorer", dir)
+		if file != "" {
+			cmd = exec.Command("explorer", "/select,"+file)
+		} else {
+			cmd = exec.Command("explorer", dir)
+		}
 	case "darwin":
-		cmd = exec.Command("open", dir)
+		if file != "" {
+			cmd = exec.Command("open", "-R", file)
+		} else {
+			cmd = exec.Command("open", dir)
+		}
 	default: // linux
 		cmd = exec.Command("xdg-open", dir)
 	}
-	if cmd != nil {
-		_ = cmd.Start()
+	_ = cmd.Start()
+}
+
+func (a *App) CreateExport(req models.ExportRequest) models.ExportStatus {
+	return a.createExport(req, models.OriginApp)
+}
+
+// createExport queues an export requested by the local user through iface
+func (a *App) createExport(req models.ExportRequest, iface string) models.ExportStatus {
+	req.Origin = localOrigin(iface)
+	status, err := a.Exports.CreateExport(req)
+	if err != nil {
+		return models.ExportStatus{State: models.ExportFailed, Error: err.Error()}
 	}
+	return status
+}
+
+func (a *App) GetExportStatus(id string) models.ExportStatus {
+	status, err := a.Exports.ExportStatus(id)
+	if err != nil {
+		return models.ExportStatus{ID: id, State: models.ExportFailed, Error: err.Error()}
+	}
+	return status
+}
+
+func (a *App) ListExports() []models.ExportStatus {
+	return a.Exports.ListExports()
+}
+
+func (a *App) CancelExport(id string) string {
+	err := a.Exports.CancelExport(id)
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func (a *App) RetryExport(id string) models.ExportStatus {
+	status, err := a.Exports.RetryExport(id)
+	if err != nil {
+		return models.ExportStatus{ID: id, State: models.ExportFailed, Error: err.Error()}
+	}
+	return status
+}
+
+func (a *App) GetExportPresets() []models.ExportPreset {
+	return a.Config.ExportPresets
+}
+
+// SaveExportPreset adds a preset or replaces the one with the same name
+func (a *App) SaveExportPreset(preset models.ExportPreset) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	if err := export.ValidatePreset(preset); err != nil {
+		return err.Error()
+	}
+	preset.Request.Passphrase = "" // Never persist secrets in config
+
+	replaced := false
+	for i, p := range a.Config.ExportPresets {
+		if p.Name == preset.Name {
+			a.Config.ExportPresets[i] = preset
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		a.Config.ExportPresets = append(a.Config.ExportPresets, preset)
+	}
+
+	err := config.SaveConfig(a.ConfigPath, a.Config)
+	if err != nil {
+		return i18n.T("error.save_config", err)
+	}
+	return ""
+}
+
+func (a *App) DeleteExportPreset(name string) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	found := false
+	var presets []models.ExportPreset
+	for _, p := range a.Config.ExportPresets {
+		if p.Name == name {
+			found = true
+			continue
+		}
+		presets = append(presets, p)
+	}
+
+	if !found {
+		return i18n.T("error.preset_not_found")
+	}
+
+	a.Config.ExportPresets = presets
+	err := config.SaveConfig(a.ConfigPath, a.Config)
+	if err != nil {
+		return i18n.T("error.save_config", err)
+	}
+	return ""
+}
+
+// RunExportPreset queues an export for the named preset, covering the preset's
+// range up to now
+func (a *App) RunExportPreset(name string, passphrase string) models.ExportStatus {
+	return a.queuePreset(name, passphrase, models.OriginApp)
+}
+
+func (a *App) queuePreset(name, passphrase, iface string) models.ExportStatus {
+	for _, p := range a.Config.ExportPresets {
+		if p.Name == name {
+			return a.createExport(export.PresetRequest(p, time.Now(), passphrase), iface)
+		}
+	}
+	return models.ExportStatus{State: models.ExportFailed, Error: i18n.T("error.preset_not_found")}
+}
+
+// localOrigin identifies the user running the app as the requester of an export
+func localOrigin(iface string) *models.ExportOrigin {
+	origin := &models.ExportOrigin{Interface: iface}
+	if u, err := user.Current(); err == nil {
+		origin.Actor = u.Username
+	}
+	return origin
+}
+
+func (a *App) GetMonitoringState() models.MonitoringState {
+	return models.MonitoringState{
+		Running:      a.Monitor.Running(),
+		PauseReasons: a.Monitor.PauseReasons(),
+	}
+}
+
+// PauseMonitoring stops testing until ResumeMonitoring is called, including across restarts
+func (a *App) PauseMonitoring() string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	a.Monitor.Pause(monitor.PauseReasonUser)
+	return a.savePauseReasons()
+}
+
+func (a *App) ResumeMonitoring() string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	a.Monitor.Resume(monitor.PauseReasonUser)
+	return a.savePauseReasons()
+}
+
+func (a *App) savePauseReasons() string {
+	a.Config.Settings.PauseReasons = a.persistedPauseReasons()
+	err := config.SaveConfig(a.ConfigPath, a.Config)
+	if err != nil {
+		return i18n.T("error.save_config", err)
+	}
+	return ""
+}
+
+// persistedPauseReasons returns the pause reasons kept across restarts.
+// Battery pauses are left out, the power source is checked again at start.
+func (a *App) persistedPauseReasons() []string {
+	return slices.DeleteFunc(a.Monitor.PauseReasons(), func(r string) bool {
+		return r == monitor.PauseReasonBattery
+	})
+}
+
+// GetAnnotations returns the annotations of the given period, oldest first
+func (a *App) GetAnnotations(durationStr string) []models.Annotation {
+	start, end := historyRange(durationStr)
+	annotations, err := a.Storage.GetAnnotations(start, end)
+	if err != nil {
+		log.Ctx(a.Storage.Ctx).Error().Err(err).Msg("Failed to read annotations")
+		return []models.Annotation{}
+	}
+	return annotations
+}
+
+// GetSchedulerHistory returns the most recent test cycle summaries, oldest first.
+// A limit of 0 returns the whole history.
+func (a *App) GetSchedulerHistory(limit int) []models.CycleReport {
+	reports, err := a.Storage.GetCycleReports(limit)
+	if err != nil {
+		log.Ctx(a.Storage.Ctx).Error().Err(err).Msg("Failed to read scheduler history")
+		return []models.CycleReport{}
+	}
+	return reports
+}
+
+// GetResolutionHistory returns the recorded changes in the addresses an
+// endpoint resolves to, oldest first
+func (a *App) GetResolutionHistory(endpointID string) []models.ResolutionChange {
+	changes, err := a.Storage.GetResolutionChanges(endpointID)
+	if err != nil {
+		log.Ctx(a.Storage.Ctx).Error().Err(err).Msg("Failed to read resolution history")
+		return []models.ResolutionChange{}
+	}
+	return changes
+}
+
+// GetRouteHistory returns the recorded changes in the traced path of a
+// traceroute endpoint, oldest first
+func (a *App) GetRouteHistory(endpointID string) []models.RouteChange {
+	changes, err := a.Storage.GetRouteChanges(endpointID)
+	if err != nil {
+		log.Ctx(a.Storage.Ctx).Error().Err(err).Msg("Failed to read route history")
+		return []models.RouteChange{}
+	}
+	return changes
+}
+
+// NeedsOnboarding reports whether the app runs with the config created at
+// first run and the user hasn't been through onboarding yet
+func (a *App) NeedsOnboarding() bool {
+	return a.Config.Settings.OnboardingPending
+}
+
+// DetectOnboarding proposes a starter region from the detected gateway and
+// DNS servers
+func (a *App) DetectOnboarding() models.OnboardingProposal {
+	return onboarding.Detect(a.logCtx)
+}
+
+// CompleteOnboarding replaces the first run config with the selected region
+// in a single save, then starts monitoring it
+func (a *App) CompleteOnboarding(sel models.OnboardingSelection) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	if !a.Config.Settings.OnboardingPending {
+		return i18n.T("error.onboarding_done")
+	}
+	if err := onboarding.Validate(sel, a.Monitor.Runner.Protocols); err != nil {
+		return i18n.T("error.invalid_onboarding", err)
+	}
+	cfg := onboarding.Apply(*a.Config, sel, a.Monitor.Runner.Protocols)
+	if err := config.SaveConfig(a.ConfigPath, &cfg); err != nil {
+		return i18n.T("error.save_config", err)
+	}
+
+	a.Monitor.Stop()
+	a.Config = &cfg
+	a.Monitor.Config = &cfg
+	a.Monitor.Start()
+	log.Ctx(a.logCtx).Info().Str("region", sel.Region).Int("endpoints", len(sel.Endpoints)).Msg("Onboarding completed")
+	return ""
+}
+
+// SkipOnboarding keeps the default config and stops offering onboarding
+func (a *App) SkipOnboarding() string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	if !a.Config.Settings.OnboardingPending {
+		return ""
+	}
+	a.Config.Settings.OnboardingPending = false
+	if err := config.SaveConfig(a.ConfigPath, a.Config); err != nil {
+		return i18n.T("error.save_config", err)
+	}
+	return ""
+}
+
+// GetLocales returns the languages backend messages can be produced in
+func (a *App) GetLocales() []models.LocaleInfo {
+	return i18n.Locales()
+}
+
+// GetUISettings returns the persisted frontend preferences
+func (a *App) GetUISettings() models.UISettings {
+	return a.Config.UI
+}
+
+// SetUISettings persists frontend preferences and applies the theme to the
+// native window
+func (a *App) SetUISettings(ui models.UISettings) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	if err := config.ValidateUISettings(ui); err != nil {
+		return err.Error()
+	}
+	a.Config.UI = ui
+	if err := config.SaveConfig(a.ConfigPath, a.Config); err != nil {
+		return i18n.T("error.save_config", err)
+	}
+	a.applyTheme()
+	return ""
+}
+
+// applyTheme matches the native window decorations to the UI theme
+func (a *App) applyTheme() {
+	if a.ctx == nil {
+		return
+	}
+	switch a.Config.UI.Theme {
+	case "light":
+		runtime.WindowSetLightTheme(a.ctx)
+	case "dark":
+		runtime.WindowSetDarkTheme(a.ctx)
+	default:
+		runtime.WindowSetSystemDefaultTheme(a.ctx)
+	}
+}
+
+// SyncNow replicates results and config to the sync target right away
+func (a *App) SyncNow() models.SyncReport {
+	return a.Sync.Run()
+}
+
+// GetSyncStatus returns the report of the last sync run
+func (a *App) GetSyncStatus() models.SyncReport {
+	return a.Sync.Last()
+}
+
+// GetPlugins returns the test plugins discovered at startup
+func (a *App) GetPlugins() []models.PluginInfo {
+	return a.Plugins.List()
+}
+
+// GetHookRuns returns the most recent hook executions with their captured output
+func (a *App) GetHookRuns() []models.HookRun {
+	return a.Hooks.Runs()
 }
 
 func (a *App) GetStartOnBoot() bool {
@@ -410,6 +1713,9 @@ func (a *App) GetStartOnBoot() bool {
 }
 
 func (a *App) SetStartOnBoot(enabled bool) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
 	err := startup.Set(enabled)
 	if err != nil {
 		return err.Error()
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/config"
+	"github.com/marcoshack/netmonitor/internal/data"
+	"github.com/marcoshack/netmonitor/internal/generate"
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/state"
+)
+
+// runExportPreset runs a saved export preset without starting the UI and
+// returns the process exit code. The passphrase for encrypted presets is read
+// from NETMONITOR_EXPORT_PASSPHRASE so it doesn't end up in shell history.
+func runExportPreset(app *App, name string) int {
+	status := app.queuePreset(name, os.Getenv("NETMONITOR_EXPORT_PASSPHRASE"), models.OriginCLI)
+	for status.State == models.ExportQueued || status.State == models.ExportRunning {
+		time.Sleep(200 * time.Millisecond)
+		status = app.GetExportStatus(status.ID)
+	}
+	app.Exports.Stop()
+
+	if status.State != models.ExportCompleted {
+		println("Export failed:", status.Error)
+		return 1
+	}
+	println(status.Path)
+	return 0
+}
+
+// runGenerate fabricates result history for load testing, e.g.
+// "netmonitor generate --endpoints 100 --days 90 --interval 30s", and returns
+// the process exit code. The generated endpoints are added to the config as
+// their own region so the UI shows them.
+func runGenerate(args []string) int {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	endpoints := fs.Int("endpoints", 20, "Number of endpoints")
+	days := fs.Int("days", 30, "Days of history, ending today")
+	interval := fs.Duration("interval", time.Minute, "Time between tests of an endpoint")
+	outages := fs.Float64("outages", 1, "Average outages per endpoint per week")
+	dir := fs.String("dir", state.DefaultDir(), "App directory to write config and data to")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "Random seed, for repeatable data")
+	force := fs.Bool("force", false, "Overwrite existing day files")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *endpoints < 1 || *days < 1 || *interval < time.Second || *outages < 0 {
+		println("Error: endpoints and days must be positive, interval at least 1s and outages not negative")
+		return 2
+	}
+
+	ctx := context.Background()
+	if _, err := state.Prepare(ctx, *dir, Version); err != nil {
+		println("Error preparing data directory:", err.Error())
+		return 1
+	}
+
+	gen := generate.New(generate.Options{
+		Endpoints:      *endpoints,
+		Days:           *days,
+		Interval:       *interval,
+		End:            time.Now(),
+		OutagesPerWeek: *outages,
+		Seed:           *seed,
+	})
+	first, n := gen.Days()
+	store := data.NewStorage(filepath.Join(*dir, "data"))
+	if !*force {
+		for i := range n {
+			path := store.GetDailyFilePath(first.AddDate(0, 0, i))
+			if _, err := os.Stat(path); err == nil {
+				println("Error: day file exists, use --force to overwrite:", path)
+				return 1
+			}
+		}
+	}
+
+	configPath := filepath.Join(*dir, "config.json")
+	cfg, err := config.LoadConfig(ctx, configPath)
+	if err != nil {
+		println("Error loading config:", err.Error())
+		return 1
+	}
+	if cfg.Regions == nil {
+		cfg.Regions = map[string]models.Region{}
+	}
+	region := cfg.Regions[generate.Region]
+	region.Endpoints = generate.Endpoints(*endpoints)
+	if region.Thresholds == (models.Thresholds{}) {
+		region.Thresholds = models.Thresholds{LatencyMs: 100, AvailabilityPercent: 99.0}
+	}
+	cfg.Regions[generate.Region] = region
+	if err := config.SaveConfig(configPath, cfg); err != nil {
+		println("Error saving config:", err.Error())
+		return 1
+	}
+
+	total := 0
+	for i := range n {
+		day := first.AddDate(0, 0, i)
+		results := gen.Day(day)
+		if err := store.WriteDay(day, results); err != nil {
+			println("Error writing results:", err.Error())
+			return 1
+		}
+		total += len(results)
+	}
+	fmt.Printf("Generated %d results for %d endpoints over %d days in %s\n", total, *endpoints, n, *dir)
+	return 0
+}
@@ -0,0 +1,182 @@
+// Command netmonitord runs the netmonitor scheduler, storage and retention
+// without the desktop UI, e.g. on a Raspberry Pi. It reads and writes the
+// same app directory as the desktop app, so the directory, or the results it
+// replicates through the sync settings, can be opened on a desktop later.
+//
+// SIGHUP reloads the config. SIGINT and SIGTERM stop testing, store the last
+// results and exit.
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+	_ "time/tzdata" // Region time zones on systems without a zone database
+
+	"github.com/marcoshack/netmonitor/internal/alerting"
+	"github.com/marcoshack/netmonitor/internal/config"
+	"github.com/marcoshack/netmonitor/internal/data"
+	"github.com/marcoshack/netmonitor/internal/logger"
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/monitor"
+	"github.com/marcoshack/netmonitor/internal/replicate"
+	"github.com/marcoshack/netmonitor/internal/state"
+	"github.com/rs/zerolog/log"
+)
+
+// Version is the daemon version, set at build time with
+// -ldflags "-X main.Version=..."
+var Version = "1.0.0"
+
+// shutdownTimeout bounds how long in-flight tests get to finish on exit
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	dir := flag.String("dir", state.DefaultDir(), "App directory holding config, data and logs")
+	debug := flag.Bool("debug", false, "Enable debug logging")
+	flag.Parse()
+	os.Exit(run(*dir, *debug))
+}
+
+func run(appDir string, debug bool) int {
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		println("Error creating app directory:", err.Error())
+		return 1
+	}
+	l, closeLogger, err := logger.New(filepath.Join(appDir, "logs"), debug)
+	if err != nil {
+		println("Error initializing logger:", err.Error())
+		return 1
+	}
+	defer closeLogger()
+	ctx := l.WithContext(context.Background())
+
+	if _, err := state.Prepare(ctx, appDir, Version); err != nil {
+		l.Error().Err(err).Str("dir", appDir).Msg("Data directory is not usable")
+		return 1
+	}
+
+	configPath := filepath.Join(appDir, "config.json")
+	cfg, err := config.LoadConfig(ctx, configPath)
+	if err != nil {
+		l.Error().Err(err).Str("path", configPath).Msg("Failed to load config")
+		return 1
+	}
+	if err := logger.SetComponentLevels(cfg.Settings.LogLevels); err != nil {
+		l.Error().Err(err).Msg("Invalid log levels")
+	}
+
+	store := data.NewStorage(filepath.Join(appDir, "data"))
+	store.Ctx = logger.WithComponent(ctx, logger.ComponentStorage)
+	janitor := &data.Janitor{Ctx: logger.WithComponent(ctx, logger.ComponentRetention), Storage: store}
+
+	replicator := replicate.New(ctx, store, configPath)
+	if err := replicator.Configure(cfg.Settings.Sync); err != nil {
+		l.Error().Err(err).Msg("Failed to configure sync")
+	}
+	defer replicator.Stop()
+
+	// Desktop notifications aren't registered here, webhook and email
+	// notifiers still send alerts
+	alerts := alerting.New(ctx)
+	if err := alerts.Configure(cfg.Settings.Alerting); err != nil {
+		l.Error().Err(err).Msg("Failed to configure alerting")
+	}
+	defer alerts.Stop()
+
+	mon := monitor.NewMonitor(logger.WithComponent(ctx, logger.ComponentScheduler), cfg)
+	for _, reason := range cfg.Settings.PauseReasons {
+		mon.Pause(reason)
+	}
+	if results, err := store.GetResultsForDay(time.Now()); err == nil {
+		mon.SeedStates(results)
+		mon.SeedTraffic(store.Traffic(time.Now()))
+	}
+	mon.OnCycle = func(report models.CycleReport) {
+		if err := store.SaveCycleReport(report); err != nil {
+			log.Ctx(store.Ctx).Error().Err(err).Msg("Failed to save scheduler cycle report")
+		}
+		janitor.RunIfDue(time.Now(), mon.Config)
+	}
+
+	relayDone := make(chan struct{})
+	relayStop := make(chan struct{})
+	go relay(mon, store, alerts, relayStop, relayDone)
+
+	mon.Start()
+	l.Info().Str("dir", appDir).Str("version", Version).Msg("netmonitord started")
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		reload(ctx, configPath, mon, replicator, alerts)
+	}
+
+	l.Info().Msg("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := mon.Shutdown(shutdownCtx); err != nil {
+		l.Error().Err(err).Msg("Monitor did not shut down cleanly")
+	}
+	close(relayStop)
+	<-relayDone
+	l.Info().Msg("Shutdown complete")
+	return 0
+}
+
+// relay stores and evaluates monitor results until stop is closed, then
+// flushes whatever is still buffered
+func relay(mon *monitor.Monitor, store *data.Storage, alerts *alerting.Engine, stop, done chan struct{}) {
+	defer close(done)
+	save := func(res models.TestResult) {
+		if err := store.SaveResult(res); err != nil {
+			log.Ctx(store.Ctx).Error().Err(err).Msg("Failed to save result")
+		}
+		alerts.Observe(mon.Config, res)
+	}
+	for {
+		select {
+		case res := <-mon.ResultsChan:
+			save(res)
+		case <-stop:
+			for {
+				select {
+				case res := <-mon.ResultsChan:
+					save(res)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// reload applies the config file again, e.g. after it was edited or synced
+// from the desktop app. A config that fails to load keeps the current one.
+func reload(ctx context.Context, configPath string, mon *monitor.Monitor, replicator *replicate.Replicator, alerts *alerting.Engine) {
+	cfg, err := config.LoadConfig(ctx, configPath)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to reload config, keeping the current one")
+		return
+	}
+	if err := logger.SetComponentLevels(cfg.Settings.LogLevels); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Invalid log levels")
+	}
+	if err := replicator.Configure(cfg.Settings.Sync); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to configure sync")
+	}
+	if err := alerts.Configure(cfg.Settings.Alerting); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to configure alerting")
+	}
+	mon.Stop()
+	mon.Config = cfg
+	mon.Start()
+	log.Ctx(ctx).Info().Msg("Config reloaded")
+}
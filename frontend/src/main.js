@@ -2,6 +2,7 @@ import Chart from 'chart.js/auto';
 import 'chartjs-adapter-date-fns';
 
 let currentConfig = null;
+let uiSettings = { theme: "dark", default_time_range: "1h", visible_columns: ["time", "status", "latency"], chart_smoothing: 0.3 };
 let currentRegion = null;
 let testResults = {}; // EndpointId -> Array of full TestResult objects (using new keys: ts, id, ms, st)
 let chartInstances = {}; // id -> Chart instance
@@ -18,6 +19,11 @@ let editingEndpointId = null;
 let originalEndpoint = null; // {address, type} for detecting changes
 let confirmationCallback = null;
 
+// Kiosk Mode State
+const KIOSK_REFRESH_MS = 30000;
+let kioskTimer = null;
+let readOnly = false;
+
 // Wails Runtime and Backend variables (injected by Wails)
 // We assume window.go.main.App is available
 
@@ -27,6 +33,10 @@ async function init() {
     try {
         // Load Config
         currentConfig = await window.go.main.App.GetConfig();
+        uiSettings = await window.go.main.App.GetUISettings();
+        applyUISettings();
+        readOnly = await window.go.main.App.IsReadOnly();
+        document.body.classList.toggle("read-only", readOnly);
 
         // Initialize Endpoints Map (Generate IDs)
         await setupEndpoints();
@@ -36,6 +46,10 @@ async function init() {
 
         // Setup Event Listeners
         window.runtime.EventsOn("test-result", handleTestResult);
+        window.runtime.EventsOn("service-status", renderServices);
+        window.runtime.EventsOn("update-available", showUpdate);
+        window.runtime.EventsOn("cert-expiring", showCertExpiring);
+        window.runtime.EventsOn("alert", showAlert);
 
         setupSettings();
         setupAddMonitor();
@@ -45,9 +59,11 @@ async function init() {
 
         setupGlobalEsc(); // New
         setupConfirmationModal();
+        await setupKioskMode();
 
         // Initial Layout
         renderDashboard();
+        renderServices();
 
         // Update status
         document.getElementById("status-message").innerText = "Monitoring Active";
@@ -101,9 +117,12 @@ function setupRegionSelector() {
 
     // Time Range Selector
     const timeSelector = document.getElementById("time-range-select");
+    timeSelector.value = uiSettings.default_time_range;
     timeSelector.addEventListener("change", (e) => {
         const range = e.target.value;
         fetchHistory(range);
+        // Remember the last chosen range as the default
+        saveUISettings({ ...uiSettings, default_time_range: range });
     });
 
     // Initial fetch
@@ -141,10 +160,10 @@ function renderDashboard() {
         handleDrop();
     };
 
-    // Render in Config Order
+    // Render by priority, then in config order
     const regionData = currentConfig.regions[currentRegion];
     if (regionData && regionData.endpoints) {
-        regionData.endpoints.forEach(ep => {
+        byPriority(regionData.endpoints).forEach(ep => {
             // Use the _id we attached in setupEndpoints to find the full data in map
             if (ep._id && endpointMap[ep._id]) {
                 const card = createEndpointCard(endpointMap[ep._id]);
@@ -154,6 +173,27 @@ function renderDashboard() {
     }
 }
 
+// byPriority returns the endpoints highest priority first, keeping the order
+// of equal priorities
+function byPriority(endpoints) {
+    return [...endpoints].sort((a, b) => (b.priority || 0) - (a.priority || 0));
+}
+
+// renderServices shows the rolled up status of each configured service
+async function renderServices() {
+    const bar = document.getElementById("services-bar");
+    const statuses = await window.go.main.App.GetServiceStatuses();
+    bar.innerHTML = "";
+    (statuses || []).forEach(s => {
+        const chip = document.createElement("div");
+        chip.className = `service-chip service-${s.status}`;
+        chip.title = `${s.up}/${s.total} up`;
+        chip.innerHTML = `<div class="status-dot"></div><span></span>`;
+        chip.querySelector("span").innerText = s.name;
+        bar.appendChild(chip);
+    });
+}
+
 function createEndpointCard(ep) {
     const id = ep.id; // Correct Hash ID
 
@@ -170,7 +210,7 @@ function createEndpointCard(ep) {
     div.style.cursor = "pointer";
 
     // Drag and Drop
-    div.draggable = true;
+    div.draggable = !readOnly;
     div.ondragstart = (e) => {
         div.classList.add('dragging');
         // e.dataTransfer.setData('text/plain', id); // We use class for selection
@@ -257,6 +297,12 @@ async function handleDrop() {
             // If backend failed, next reload will revert.
         } else {
             console.log("Reorder saved");
+            // Higher priorities stay first, put back any card dragged past them
+            const endpoints = currentConfig.regions[currentRegion].endpoints;
+            if (byPriority(endpoints).some((ep, i) => ep !== endpoints[i])) {
+                renderDashboard();
+                await fetchHistory(document.getElementById("time-range-select").value);
+            }
         }
     } catch (e) {
         console.error(e);
@@ -420,7 +466,7 @@ function initChart(id) {
                     pointRadius: 0,
                     pointHoverRadius: 4,
                     fill: true,
-                    tension: 0.3,
+                    tension: uiSettings.chart_smoothing,
                     spanGaps: false // IMPORTANT for gaps
                 },
                 {
@@ -449,6 +495,7 @@ function initChart(id) {
                                 const st = context.raw.status;
                                 if (st === 1) return "Timeout";
                                 if (st === 2) return "Error";
+                                if (st === 3) return "Cancelled";
                                 return "Failure";
                             }
                             return `Latency: ${context.parsed.y} ms`;
@@ -512,6 +559,7 @@ function handleTestResult(result) {
         if (latSpan) {
             if (result.st === 1) latSpan.innerText = "Timeout";
             else if (result.st === 2) latSpan.innerText = "Error";
+            else if (result.st === 3) latSpan.innerText = "Cancelled";
             else latSpan.innerHTML = result.latency_ms + ' <span class="text-sm text-muted font-normal">ms</span>';
         }
         if (dot) {
@@ -603,6 +651,31 @@ function setupDetailsModal() {
         }
     });
 
+    // Clone copies every setting of the monitor, only asking for the address
+    // of the copy since two monitors can't test the same one
+    document.getElementById("btn-clone-details").onclick = async () => {
+        if (!currentDetailId || !endpointMap[currentDetailId]) return;
+
+        const ep = endpointMap[currentDetailId];
+        const address = prompt(`Address of the copy of "${ep.name}"`, ep.address);
+        if (!address || address === ep.address) return;
+        try {
+            const err = await window.go.main.App.CloneEndpoint(ep.regionName, ep.name, { address: address });
+            if (err) {
+                alert("Failed to clone endpoint: " + err);
+                return;
+            }
+            closeDetailView();
+            currentConfig = await window.go.main.App.GetConfig();
+            await setupEndpoints();
+            renderDashboard();
+            document.getElementById("status-message").innerText = "Monitor Cloned";
+        } catch (e) {
+            console.error(e);
+            alert("Error cloning endpoint: " + e);
+        }
+    };
+
     document.getElementById("btn-delete-details").onclick = () => {
         if (!currentDetailId || !endpointMap[currentDetailId]) return;
 
@@ -687,6 +760,19 @@ function openDetailView(id) {
     updateDetailView(id);
     initDetailChart(); // Create if not exists
     renderDetailChart(id);
+    updateDetailHealth(id);
+}
+
+async function updateDetailHealth(id) {
+    const el = document.getElementById("detail-health");
+    el.innerText = "--";
+    el.className = "text-lg font-bold";
+    const aggs = await window.go.main.App.GetCurrentAggregates("day");
+    const agg = (aggs || []).find(a => a.endpoint_id === id);
+    if (!agg || id !== currentDetailId) return;
+    const score = Math.round(agg.health);
+    el.innerText = score;
+    el.classList.add(score >= 90 ? "text-success" : score >= 70 ? "text-warning" : "text-error");
 }
 
 function closeDetailView() {
@@ -745,14 +831,18 @@ function updateDetailView(id) {
 
     last10.forEach(r => {
         const tr = document.createElement("tr");
+        // The trace finds the test's lines in the log
+        if (r.trace) tr.title = `Trace ${r.trace}`;
 
         // Time
         const tdTime = document.createElement("td");
+        tdTime.dataset.col = "time";
         tdTime.innerText = r.timestamp.toLocaleTimeString();
         tr.appendChild(tdTime);
 
         // Status
         const tdStatus = document.createElement("td");
+        tdStatus.dataset.col = "status";
         tdStatus.className = "text-center";
         const statusSpan = document.createElement("span");
         if (r.statusStr === "success") {
@@ -767,6 +857,7 @@ function updateDetailView(id) {
 
         // Latency
         const tdLat = document.createElement("td");
+        tdLat.dataset.col = "latency";
         tdLat.className = "text-right font-mono";
         tdLat.innerText = r.latency_ms + " ms";
         tr.appendChild(tdLat);
@@ -801,7 +892,7 @@ function initDetailChart() {
                 borderWidth: 2,
                 pointRadius: 1, // Visible points
                 fill: true,
-                tension: 0.3,
+                tension: uiSettings.chart_smoothing,
                 spanGaps: false
             }, {
                 label: 'Failures',
@@ -833,6 +924,7 @@ function initDetailChart() {
                                 const st = context.raw.status;
                                 if (st === 1) return "Timeout";
                                 if (st === 2) return "Error";
+                                if (st === 3) return "Cancelled";
                                 return "Failure";
                             }
                             return `Latency: ${context.parsed.y} ms`;
@@ -916,6 +1008,156 @@ function renderDetailChart(id) {
     detailChartInstance.update();
 }
 
+// renderConfigLint lists risky parts of the config, most severe first
+async function renderConfigLint() {
+    const list = document.getElementById("config-lint");
+    list.innerHTML = "";
+    const issues = await window.go.main.App.LintConfig(currentConfig);
+    (issues || []).forEach(issue => {
+        const li = document.createElement("li");
+        li.className = `lint-${issue.severity}`;
+        li.innerText = issue.message;
+        list.appendChild(li);
+    });
+}
+
+function formatBytes(bytes) {
+    const units = ["B", "KB", "MB", "GB"];
+    let i = 0;
+    while (bytes >= 1024 && i < units.length - 1) {
+        bytes /= 1024;
+        i++;
+    }
+    return `${bytes.toFixed(i === 0 ? 0 : 1)} ${units[i]}`;
+}
+
+// renderStorageStats shows how much disk the history uses and which
+// endpoints take most of it, and the traffic their tests caused
+async function renderStorageStats() {
+    const stats = await window.go.main.App.GetStorageStats();
+    let summary = `${formatBytes(stats.bytes)} in ${stats.files} days, ${stats.results.toLocaleString()} results`;
+    if (stats.trash_bytes > 0) summary += `, ${formatBytes(stats.trash_bytes)} in trash`;
+    if (stats.sent || stats.received) summary += `; tests sent ${formatBytes(stats.sent)} and received ${formatBytes(stats.received)}`;
+    document.getElementById("storage-summary").innerText = summary;
+
+    const fill = (id, usages, label) => {
+        const list = document.getElementById(id);
+        list.innerHTML = "";
+        (usages || []).forEach(u => {
+            const li = document.createElement("li");
+            li.innerText = `${label(u)}: ${formatBytes(u.bytes)} (${u.percent.toFixed(1)}%), ${u.results.toLocaleString()} results`;
+            if (u.sent || u.received) li.innerText += `, ${formatBytes(u.sent + u.received)} traffic`;
+            li.style.setProperty("--share", `${u.percent}%`);
+            list.appendChild(li);
+        });
+    };
+    fill("storage-endpoints", (stats.endpoints || []).slice(0, 10), u => u.name || u.key);
+    fill("storage-months", stats.months, u => u.key);
+}
+
+// showUpdate points to a new release in the status bar, with its notes on hover
+function showUpdate(info) {
+    const status = document.getElementById("status-message");
+    status.innerText = `Update available: ${info.latest}`;
+    status.title = info.notes || "";
+}
+
+// showCertExpiring announces an endpoint whose certificate expires within
+// the warning period
+function showCertExpiring(endpoint, state) {
+    const tags = (state.last_result && state.last_result.tags) || {};
+    const status = document.getElementById("status-message");
+    status.innerText = `Certificate of ${endpoint.name} expires in ${tags.cert_expiry_days} days`;
+    status.title = `${tags.cert_subject || ""} until ${tags.cert_not_after || ""}`;
+}
+
+// showAlert reports a threshold alert starting or resolving, as a system
+// notification too when the webview allows them
+function showAlert(alert) {
+    const what = {
+        latency: `p95 latency ${Math.round(alert.value)} ms, threshold ${alert.threshold} ms`,
+        availability: `availability ${alert.value.toFixed(2)}%, threshold ${alert.threshold}%`,
+        failures: `${alert.value} failed tests in a row`,
+    }[alert.kind] || alert.kind;
+    const title = `${alert.state === "resolved" ? "Resolved" : "Alert"}: ${alert.endpoint_name} (${alert.region})`;
+    const status = document.getElementById("status-message");
+    status.innerText = `${title}: ${what}`;
+    status.title = new Date(alert.since).toLocaleString();
+    if (window.Notification && Notification.permission === "granted") {
+        new Notification(title, { body: what });
+    } else if (window.Notification && Notification.permission === "default") {
+        Notification.requestPermission();
+    }
+}
+
+// readDataBudget returns the data budget set in the settings form, or null
+function readDataBudget() {
+    const budget = {};
+    const daily = parseInt(document.getElementById("setting-budget-daily").value) || 0;
+    const monthly = parseInt(document.getElementById("setting-budget-monthly").value) || 0;
+    if (daily) budget.daily_mb = daily;
+    if (monthly) budget.monthly_mb = monthly;
+    return Object.keys(budget).length ? budget : null;
+}
+
+function readBattery() {
+    // Same key order as the saved settings, for hasSettingsChanged
+    const battery = {};
+    const interval = parseInt(document.getElementById("setting-battery-interval").value) || 0;
+    if (document.getElementById("setting-battery-pause").checked) battery.pause = true;
+    if (interval) battery.interval_seconds = interval;
+    if (document.getElementById("setting-battery-light").checked) battery.light_tests = true;
+    return Object.keys(battery).length ? battery : null;
+}
+
+function readHotkeys() {
+    const hotkeys = {};
+    const toggle = document.getElementById("setting-hotkey-toggle").value.trim();
+    const run = document.getElementById("setting-hotkey-run").value.trim();
+    if (toggle) hotkeys.toggle_window = toggle;
+    if (run) hotkeys.run_tests = run;
+    return Object.keys(hotkeys).length ? hotkeys : null;
+}
+
+// renderDataUsage shows how much traffic tests used today and this month
+async function renderDataUsage() {
+    const usage = await window.go.main.App.GetDataUsage();
+    const budget = usage.budget || {};
+    const part = (bytes, limitMB) => formatBytes(bytes) + (limitMB ? ` of ${limitMB} MB` : "");
+    document.getElementById("data-usage").innerText =
+        `Tests used ${part(usage.today_bytes, budget.daily_mb)} today, ${part(usage.month_bytes, budget.monthly_mb)} this month`;
+}
+
+// renderRetentionHolds lists the days kept regardless of the retention setting
+async function renderRetentionHolds() {
+    const list = document.getElementById("retention-holds");
+    list.innerHTML = "";
+    const holds = await window.go.main.App.GetRetentionHolds();
+    (holds || []).forEach((hold, index) => {
+        const li = document.createElement("li");
+        li.className = "flex items-center justify-between";
+        const days = hold.to && hold.to !== hold.from ? `${hold.from} – ${hold.to}` : hold.from;
+        li.innerText = hold.reason ? `${days}: ${hold.reason}` : days;
+
+        const remove = document.createElement("button");
+        remove.type = "button";
+        remove.className = "btn btn-icon";
+        remove.innerText = "✕";
+        remove.title = "Release";
+        remove.addEventListener("click", async () => {
+            const err = await window.go.main.App.RemoveRetentionHold(index);
+            if (err) {
+                alert("Error: " + err);
+                return;
+            }
+            currentConfig = await window.go.main.App.GetConfig();
+            await renderRetentionHolds();
+        });
+        li.appendChild(remove);
+        list.appendChild(li);
+    });
+}
+
 function setupSettings() {
     const modal = document.getElementById("settings-modal");
 
@@ -925,8 +1167,32 @@ function setupSettings() {
         if (currentConfig && currentConfig.settings) {
             document.getElementById("setting-interval").value = currentConfig.settings.test_interval_seconds;
             document.getElementById("setting-retention").value = currentConfig.settings.data_retention_days;
+            document.getElementById("setting-cleanup-grace").value = currentConfig.settings.cleanup_grace_days || 0;
+            document.getElementById("setting-export-dir").value = currentConfig.settings.export_dir || "";
+            const budget = currentConfig.settings.data_budget || {};
+            document.getElementById("setting-budget-daily").value = budget.daily_mb || 0;
+            document.getElementById("setting-budget-monthly").value = budget.monthly_mb || 0;
+            const battery = currentConfig.settings.battery || {};
+            document.getElementById("setting-battery-interval").value = battery.interval_seconds || 0;
+            document.getElementById("setting-battery-light").checked = !!battery.light_tests;
+            document.getElementById("setting-battery-pause").checked = !!battery.pause;
+            document.getElementById("setting-cert-warning").value = currentConfig.settings.cert_warning_days || 0;
+            const hotkeys = currentConfig.settings.hotkeys || {};
+            document.getElementById("setting-hotkey-toggle").value = hotkeys.toggle_window || "";
+            document.getElementById("setting-hotkey-run").value = hotkeys.run_tests || "";
             document.getElementById("setting-notifications").checked = currentConfig.settings.notifications_enabled;
+            document.getElementById("setting-update-check").checked = !!currentConfig.settings.update_check;
         }
+        document.getElementById("update-status").innerText = "";
+        await populateLocales();
+        await renderConfigLint();
+        await renderRetentionHolds();
+        await renderStorageStats();
+        await renderDataUsage();
+        document.getElementById("setting-theme").value = uiSettings.theme;
+        document.getElementById("setting-smoothing").value = uiSettings.chart_smoothing;
+
+        document.getElementById("setting-log-level").value = await window.go.main.App.GetLogLevel();
 
         // Get Start on Boot status
         try {
@@ -939,6 +1205,57 @@ function setupSettings() {
         modal.classList.add("active");
     });
 
+    document.getElementById("btn-reveal-exports").addEventListener("click", async () => {
+        const err = await window.go.main.App.RevealExport("");
+        if (err) alert("Error: " + err);
+    });
+
+    document.getElementById("setting-log-level").addEventListener("change", async (e) => {
+        const err = await window.go.main.App.SetLogLevel(e.target.value);
+        if (err) {
+            alert("Error: " + err);
+            e.target.value = await window.go.main.App.GetLogLevel();
+        }
+    });
+
+    document.getElementById("btn-check-updates").addEventListener("click", async () => {
+        const info = await window.go.main.App.CheckForUpdates();
+        const status = document.getElementById("update-status");
+        if (info.error) {
+            status.innerText = `Version ${info.current}. ${info.error}`;
+        } else if (info.available) {
+            showUpdate(info);
+            status.innerText = `Version ${info.latest} is available, you have ${info.current}`;
+        } else {
+            status.innerText = `Version ${info.current} is the latest`;
+        }
+    });
+
+    document.getElementById("btn-undo-cleanup").addEventListener("click", async () => {
+        const err = await window.go.main.App.UndoLastCleanup();
+        if (err) {
+            alert("Error: " + err);
+            return;
+        }
+        document.getElementById("status-message").innerText = "Last Cleanup Undone";
+    });
+
+    document.getElementById("btn-add-hold").addEventListener("click", async () => {
+        const hold = {
+            from: document.getElementById("hold-from").value,
+            to: document.getElementById("hold-to").value,
+            reason: document.getElementById("hold-reason").value.trim()
+        };
+        const err = await window.go.main.App.AddRetentionHold(hold);
+        if (err) {
+            alert("Error: " + err);
+            return;
+        }
+        ["hold-from", "hold-to", "hold-reason"].forEach(id => document.getElementById(id).value = "");
+        currentConfig = await window.go.main.App.GetConfig();
+        await renderRetentionHolds();
+    });
+
     // Close
     document.getElementById("btn-close-settings").addEventListener("click", attemptCloseSettings);
 
@@ -948,8 +1265,16 @@ function setupSettings() {
 
         const interval = parseInt(document.getElementById("setting-interval").value);
         const retention = parseInt(document.getElementById("setting-retention").value);
+        const cleanupGrace = parseInt(document.getElementById("setting-cleanup-grace").value) || 0;
+        const exportDir = document.getElementById("setting-export-dir").value.trim();
+        const dataBudget = readDataBudget();
+        const battery = readBattery();
+        const hotkeys = readHotkeys();
+        const certWarning = parseInt(document.getElementById("setting-cert-warning").value) || 0;
         const notifications = document.getElementById("setting-notifications").checked;
+        const updateCheck = document.getElementById("setting-update-check").checked;
         const startOnBoot = document.getElementById("setting-start-on-boot").checked;
+        const locale = document.getElementById("setting-locale").value;
 
         const newConfig = {
             ...currentConfig,
@@ -957,7 +1282,15 @@ function setupSettings() {
                 ...currentConfig.settings,
                 test_interval_seconds: interval,
                 data_retention_days: retention,
-                notifications_enabled: notifications
+                cleanup_grace_days: cleanupGrace,
+                export_dir: exportDir,
+                data_budget: dataBudget,
+                battery: battery,
+                cert_warning_days: certWarning,
+                hotkeys: hotkeys,
+                notifications_enabled: notifications,
+                update_check: updateCheck,
+                locale: locale
             }
         };
 
@@ -973,6 +1306,15 @@ function setupSettings() {
                     // Don't block close, but maybe warn?
                 }
 
+                const uiErr = await saveUISettings({
+                    ...uiSettings,
+                    theme: document.getElementById("setting-theme").value,
+                    chart_smoothing: parseFloat(document.getElementById("setting-smoothing").value)
+                });
+                if (uiErr) {
+                    alert("Error saving display settings: " + uiErr);
+                }
+
                 currentConfig = newConfig; // Update local
                 document.getElementById("settings-modal").classList.remove("active"); // Direct close on save
                 document.getElementById("status-message").innerText = "Settings Saved";
@@ -1061,7 +1403,16 @@ function setupAddMonitor() {
             name: name,
             type: type, // Ensure exact casing matches struct if needed, but select values are uppercase
             address: address,
-            timeout: timeout
+            timeout: timeout,
+            interval_seconds: parseInt(document.getElementById("add-interval").value) || 0,
+            priority: parseInt(document.getElementById("add-priority").value) || 0,
+            confirm: readConfirmProbe(),
+            http: readHTTPOptions(),
+            tcp: readTCPOptions(),
+            udp: readUDPOptions(),
+            dns: readDNSOptions(),
+            icmp: readICMPOptions(),
+            all_addresses: document.getElementById("add-all-addresses").checked
         };
 
         try {
@@ -1139,7 +1490,11 @@ function openEditMonitor(endpoint) {
         name: endpoint.name,
         address: endpoint.address,
         type: endpoint.type,
-        timeout: endpoint.timeout
+        timeout: endpoint.timeout,
+        interval_seconds: endpoint.interval_seconds || 0,
+        priority: endpoint.priority || 0,
+        confirm: endpoint.confirm || null,
+        icmp: endpoint.icmp || null
     };
 
     document.querySelector("#add-monitor-modal h2").innerText = "Edit Monitor";
@@ -1150,6 +1505,25 @@ function openEditMonitor(endpoint) {
     document.getElementById("add-type").value = endpoint.type;
     document.getElementById("add-address").value = endpoint.address;
     document.getElementById("add-timeout").value = endpoint.timeout;
+    document.getElementById("add-interval").value = endpoint.interval_seconds || "";
+    document.getElementById("add-priority").value = endpoint.priority || "";
+    document.getElementById("add-confirm-type").value = endpoint.confirm ? endpoint.confirm.type : "";
+    document.getElementById("add-confirm-address").value = endpoint.confirm ? endpoint.confirm.address : "";
+    document.getElementById("add-http-keepalive").checked = !!(endpoint.http && endpoint.http.keep_alive);
+    document.getElementById("add-http-version").value = (endpoint.http && endpoint.http.version) || "";
+    document.getElementById("add-all-addresses").checked = !!endpoint.all_addresses;
+    document.getElementById("add-tcp-reuse").checked = !!(endpoint.tcp && endpoint.tcp.reuse);
+    document.getElementById("add-tcp-payload").value = (endpoint.tcp && endpoint.tcp.payload) || "";
+    document.getElementById("add-udp-payload").value = (endpoint.udp && endpoint.udp.payload) || "";
+    document.getElementById("add-udp-expect").value = (endpoint.udp && endpoint.udp.expect_prefix) || "";
+    document.getElementById("add-dns-record-type").value = (endpoint.dns && endpoint.dns.record_type || "").toUpperCase();
+    document.getElementById("add-dns-expect").value = ((endpoint.dns && endpoint.dns.expect) || []).join(", ");
+    document.getElementById("add-dns-dnssec").checked = !!(endpoint.dns && endpoint.dns.dnssec);
+    document.getElementById("add-icmp-count").value = (endpoint.icmp && endpoint.icmp.count) || "";
+    document.getElementById("add-icmp-interval").value = (endpoint.icmp && endpoint.icmp.interval_ms) || "";
+    document.getElementById("add-http-method").value = (endpoint.http && endpoint.http.method) || "";
+    document.getElementById("add-http-content-type").value = (endpoint.http && endpoint.http.content_type) || "";
+    document.getElementById("add-http-body").value = (endpoint.http && endpoint.http.body) || "";
 
     // Enable identity fields (now allowed)
     const addrInput = document.getElementById("add-address");
@@ -1178,8 +1552,58 @@ function openEditMonitor(endpoint) {
 }
 
 
+// applyUISettings reflects the UI preferences in the page. Charts pick up
+// smoothing changes when they are next created.
+function applyUISettings() {
+    const theme = uiSettings.theme === "system"
+        ? (window.matchMedia("(prefers-color-scheme: light)").matches ? "light" : "dark")
+        : uiSettings.theme;
+    document.body.classList.toggle("theme-light", theme === "light");
+
+    const visible = uiSettings.visible_columns && uiSettings.visible_columns.length
+        ? uiSettings.visible_columns
+        : ["time", "status", "latency"];
+    ["time", "status", "latency"].forEach(col => {
+        document.body.classList.toggle("hide-col-" + col, !visible.includes(col));
+    });
+}
+
+async function saveUISettings(settings) {
+    const err = await window.go.main.App.SetUISettings(settings);
+    if (err) {
+        console.error("Failed to save UI settings:", err);
+        return err;
+    }
+    uiSettings = settings;
+    applyUISettings();
+    return "";
+}
+
+async function setupKioskMode() {
+    window.runtime.EventsOn("kiosk-mode", applyKioskMode);
+    applyKioskMode(await window.go.main.App.IsKioskMode());
+}
+
+// In kiosk mode every control is hidden and the dashboard reloads its
+// history periodically, since nobody is there to interact with it
+function applyKioskMode(enabled) {
+    document.body.classList.toggle("kiosk", enabled);
+    clearInterval(kioskTimer);
+    kioskTimer = null;
+    if (enabled) {
+        closeDetailView();
+        kioskTimer = setInterval(() => {
+            fetchHistory(document.getElementById("time-range-select").value);
+        }, KIOSK_REFRESH_MS);
+    }
+}
+
 function setupGlobalEsc() {
     document.addEventListener("keydown", (e) => {
+        if (e.key === "Escape" && document.body.classList.contains("kiosk")) {
+            window.go.main.App.ExitKioskMode();
+            return;
+        }
         if (e.key === "Escape") {
             const settingsModal = document.getElementById("settings-modal");
             const addModal = document.getElementById("add-monitor-modal");
@@ -1196,17 +1620,47 @@ function setupGlobalEsc() {
     });
 }
 
+async function populateLocales() {
+    const select = document.getElementById("setting-locale");
+    if (select.options.length === 0) {
+        try {
+            const locales = await window.go.main.App.GetLocales();
+            for (const l of locales) {
+                const opt = document.createElement("option");
+                opt.value = l.code;
+                opt.textContent = l.name;
+                select.appendChild(opt);
+            }
+        } catch (e) {
+            console.error("Failed to load locales:", e);
+        }
+    }
+    select.value = (currentConfig && currentConfig.settings && currentConfig.settings.locale) || "en";
+}
+
 function hasSettingsChanged() {
     if (!currentConfig || !currentConfig.settings) return false;
     // Handle potential string vs number issues by casting to correct types
     const currentInterval = parseInt(document.getElementById("setting-interval").value);
     const currentRetention = parseInt(document.getElementById("setting-retention").value);
     const currentNotif = document.getElementById("setting-notifications").checked;
+    const currentTheme = document.getElementById("setting-theme").value;
+    const currentSmoothing = parseFloat(document.getElementById("setting-smoothing").value);
 
     return (
         currentInterval !== currentConfig.settings.test_interval_seconds ||
         currentRetention !== currentConfig.settings.data_retention_days ||
-        currentNotif !== currentConfig.settings.notifications_enabled
+        (parseInt(document.getElementById("setting-cleanup-grace").value) || 0) !== (currentConfig.settings.cleanup_grace_days || 0) ||
+        document.getElementById("setting-export-dir").value.trim() !== (currentConfig.settings.export_dir || "") ||
+        JSON.stringify(readDataBudget()) !== JSON.stringify(currentConfig.settings.data_budget || null) ||
+        JSON.stringify(readBattery()) !== JSON.stringify(currentConfig.settings.battery || null) ||
+        JSON.stringify(readHotkeys()) !== JSON.stringify(currentConfig.settings.hotkeys || null) ||
+        (parseInt(document.getElementById("setting-cert-warning").value) || 0) !== (currentConfig.settings.cert_warning_days || 0) ||
+        currentNotif !== currentConfig.settings.notifications_enabled ||
+        document.getElementById("setting-update-check").checked !== !!currentConfig.settings.update_check ||
+        document.getElementById("setting-locale").value !== (currentConfig.settings.locale || "en") ||
+        currentTheme !== uiSettings.theme ||
+        currentSmoothing !== uiSettings.chart_smoothing
     );
 }
 
@@ -1235,10 +1689,118 @@ function hasMonitorChanged() {
         currentName !== originalEndpoint.name ||
         currentType !== originalEndpoint.type ||
         currentAddress !== originalEndpoint.address ||
-        currentTimeout !== originalEndpoint.timeout
+        currentTimeout !== originalEndpoint.timeout ||
+        (parseInt(document.getElementById("add-interval").value) || 0) !== originalEndpoint.interval_seconds ||
+        document.getElementById("add-all-addresses").checked !== !!originalEndpoint.all_addresses ||
+        confirmKey(readConfirmProbe()) !== confirmKey(originalEndpoint.confirm) ||
+        JSON.stringify(readHTTPOptions()) !== JSON.stringify(originalEndpoint.http || null) ||
+        JSON.stringify(readTCPOptions()) !== JSON.stringify(originalEndpoint.tcp || null) ||
+        JSON.stringify(readUDPOptions()) !== JSON.stringify(originalEndpoint.udp || null) ||
+        JSON.stringify(readDNSOptions()) !== JSON.stringify(originalEndpoint.dns || null) ||
+        JSON.stringify(readICMPOptions()) !== JSON.stringify(originalEndpoint.icmp || null)
     );
 }
 
+// readConfirmProbe returns the confirmation probe set in the monitor form, or
+// null when none is selected. A probe without its own timeout in the config
+// uses the monitor's.
+function readConfirmProbe() {
+    const type = document.getElementById("add-confirm-type").value;
+    const address = document.getElementById("add-confirm-address").value.trim();
+    if (!type) return null;
+    const original = isEditMode && originalEndpoint ? originalEndpoint.confirm : null;
+    return { type: type, address: address, timeout: original ? original.timeout : 0 };
+}
+
+// readHTTPOptions returns the HTTP options set in the monitor form, or null
+// when none are set. Options the form doesn't show are kept.
+function readHTTPOptions() {
+    const original = isEditMode && originalEndpoint ? originalEndpoint.http : null;
+    const options = { ...(original || {}) };
+    if (document.getElementById("add-http-keepalive").checked) {
+        options.keep_alive = true;
+    } else {
+        delete options.keep_alive;
+    }
+    const version = document.getElementById("add-http-version").value;
+    if (version) {
+        options.version = version;
+    } else {
+        delete options.version;
+    }
+    for (const [key, id] of [["method", "add-http-method"], ["content_type", "add-http-content-type"], ["body", "add-http-body"]]) {
+        const value = document.getElementById(id).value;
+        if (value) {
+            options[key] = value;
+        } else {
+            delete options[key];
+        }
+    }
+    return Object.keys(options).length ? options : null;
+}
+
+// readTCPOptions returns the TCP options set in the monitor form, or null
+function readTCPOptions() {
+    const original = isEditMode && originalEndpoint ? originalEndpoint.tcp : null;
+    const options = { ...(original || {}) };
+    if (document.getElementById("add-tcp-reuse").checked) {
+        options.reuse = true;
+    } else {
+        delete options.reuse;
+    }
+    const payload = document.getElementById("add-tcp-payload").value;
+    if (payload) {
+        options.payload = payload;
+    } else {
+        delete options.payload;
+    }
+    return Object.keys(options).length ? options : null;
+}
+
+// readUDPOptions returns the UDP options set in the monitor form, or null.
+// Options the form doesn't show, such as hex payloads, are kept.
+function readUDPOptions() {
+    const original = isEditMode && originalEndpoint ? originalEndpoint.udp : null;
+    const options = { ...(original || {}) };
+    for (const [key, id] of [["payload", "add-udp-payload"], ["expect_prefix", "add-udp-expect"]]) {
+        const value = document.getElementById(id).value;
+        if (value) {
+            options[key] = value;
+        } else {
+            delete options[key];
+        }
+    }
+    return Object.keys(options).length ? options : null;
+}
+
+// readDNSOptions returns the DNS options set in the monitor form, or null
+function readDNSOptions() {
+    const original = isEditMode && originalEndpoint ? originalEndpoint.dns : null;
+    const recordType = document.getElementById("add-dns-record-type").value;
+    const expect = document.getElementById("add-dns-expect").value.split(",").map(s => s.trim()).filter(s => s);
+    const dnssec = document.getElementById("add-dns-dnssec").checked;
+    if (!recordType && !expect.length && !dnssec) return null;
+    // Keep the original spelling when the form shows the same values
+    const unchanged = original && (original.record_type || "").toUpperCase() === recordType &&
+        JSON.stringify(original.expect || []) === JSON.stringify(expect) && !!original.dnssec === dnssec;
+    if (unchanged) return original;
+    return { record_type: recordType, expect: expect.length ? expect : null, dnssec: dnssec };
+}
+
+// readICMPOptions returns the ICMP options set in the monitor form, or null
+function readICMPOptions() {
+    const options = {};
+    const count = parseInt(document.getElementById("add-icmp-count").value) || 0;
+    const interval = parseInt(document.getElementById("add-icmp-interval").value) || 0;
+    if (count) options.count = count;
+    if (interval) options.interval_ms = interval;
+    return Object.keys(options).length ? options : null;
+}
+
+function confirmKey(probe) {
+    return probe ? probe.type + " " + probe.address : "";
+}
+
 function attemptCloseAddMonitor() {
     const modal = document.getElementById("add-monitor-modal");
     if (!modal.classList.contains("active")) return;
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/marcoshack/netmonitor/internal/hotkey"
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/rs/zerolog/log"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Global hotkeys show or hide the window and start a test of every endpoint
+// from any application, for troubleshooting connectivity elsewhere without
+// switching to NetMonitor first.
+
+// hotkeyBindings parses the configured hotkeys into the actions they run
+func (a *App) hotkeyBindings(s *models.HotkeySettings) (map[hotkey.Hotkey]func(), error) {
+	bindings := make(map[hotkey.Hotkey]func())
+	if s == nil {
+		return bindings, nil
+	}
+	for _, h := range []struct {
+		key    string
+		action func()
+	}{
+		{s.ToggleWindow, a.ToggleWindow},
+		{s.RunTests, a.runTestsFromHotkey},
+	} {
+		if h.key == "" {
+			continue
+		}
+		key, err := hotkey.Parse(h.key)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := bindings[key]; dup {
+			return nil, fmt.Errorf("%s is assigned twice", key)
+		}
+		bindings[key] = h.action
+	}
+	return bindings, nil
+}
+
+// applyHotkeys registers the configured hotkeys in place of the previous ones
+func (a *App) applyHotkeys() error {
+	bindings, err := a.hotkeyBindings(a.Config.Settings.Hotkeys)
+	if err != nil {
+		return err
+	}
+	return a.hotkeys.Set(bindings)
+}
+
+// ToggleWindow hides the window if it is shown, and shows it otherwise
+func (a *App) ToggleWindow() {
+	if a.ctx == nil {
+		return
+	}
+	if a.windowHidden.Load() || runtime.WindowIsMinimised(a.ctx) {
+		a.ShowWindow()
+		return
+	}
+	a.WindowResized()
+	a.HideWindow()
+}
+
+// runTestsFromHotkey tests every endpoint now, outside the schedule. The
+// results are stored and shown like those of scheduled tests.
+func (a *App) runTestsFromHotkey() {
+	log.Ctx(a.ctx).Info().Msg("Test of all endpoints started by hotkey")
+	report := a.Monitor.RunAllTests()
+	log.Ctx(a.ctx).Info().Int("tests", report.Tests).Int("failures", report.Failures).Msg("Test of all endpoints finished")
+}
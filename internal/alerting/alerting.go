@@ -0,0 +1,503 @@
+// Package alerting evaluates the region thresholds against the results of
+// each endpoint as they arrive and sends alerts through notifiers when a
+// threshold starts or stops being breached.
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/data"
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultWindow is the period latency and availability are measured
+	// over when the settings don't set one
+	DefaultWindow = 15 * time.Minute
+
+	// DefaultConsecutiveFailures is the failure streak that alerts when the
+	// settings don't set one
+	DefaultConsecutiveFailures = 3
+
+	// MinTests is how many tests the window needs before latency and
+	// availability are judged, so one slow test after a restart doesn't alert
+	MinTests = 3
+
+	// NotifyTimeout bounds each notification, or each attempt of those
+	// that retry
+	NotifyTimeout = 30 * time.Second
+)
+
+// Notifier types
+const (
+	TypeWebhook = "webhook"
+	TypeEmail   = "email"
+	TypeDesktop = "desktop"
+)
+
+// Notifier sends alerts to one destination
+type Notifier interface {
+	Notify(ctx context.Context, alert models.Alert) error
+}
+
+// timeouter is implemented by notifiers that need longer than NotifyTimeout
+// to send an alert, e.g. to retry it
+type timeouter interface {
+	Timeout() time.Duration
+}
+
+// NotifierFactory builds the notifier of validated settings
+type NotifierFactory func(models.NotifierSettings) (Notifier, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]NotifierFactory{
+		TypeWebhook: newWebhook,
+		TypeEmail:   newEmail,
+	}
+)
+
+// Register makes a notifier type available to the settings, replacing any
+// factory of the same type. The app registers TypeDesktop.
+func Register(kind string, factory NotifierFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[kind] = factory
+}
+
+func factory(kind string) (NotifierFactory, bool) {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	f, ok := factories[kind]
+	return f, ok
+}
+
+// ValidateSettings checks the alert settings and every notifier
+func ValidateSettings(s models.AlertSettings) error {
+	if s.WindowMinutes < 0 || s.ConsecutiveFailures < 0 || s.RepeatMinutes < 0 {
+		return errors.New("window, consecutive failures and repeat must not be negative")
+	}
+	names := make(map[string]bool)
+	for _, n := range s.Notifiers {
+		if n.Name == "" {
+			return errors.New("notifier name is required")
+		}
+		if names[n.Name] {
+			return fmt.Errorf("duplicate notifier: %s", n.Name)
+		}
+		names[n.Name] = true
+		if _, ok := factory(n.Type); !ok {
+			return fmt.Errorf("notifier %s: unknown type %q", n.Name, n.Type)
+		}
+		switch n.Type {
+		case TypeWebhook:
+			u, err := url.Parse(n.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("notifier %s: webhook URL must be an http(s) URL", n.Name)
+			}
+			if n.Retries != nil && (*n.Retries < 0 || *n.Retries > MaxRetries) {
+				return fmt.Errorf("notifier %s: retries must be between 0 and %d", n.Name, MaxRetries)
+			}
+			if n.Template != "" {
+				if _, err := parseTemplate(n.Template); err != nil {
+					return fmt.Errorf("notifier %s: invalid template: %w", n.Name, err)
+				}
+			}
+		case TypeEmail:
+			if _, _, err := net.SplitHostPort(n.SMTPHost); err != nil {
+				return fmt.Errorf("notifier %s: SMTP host must be host:port: %w", n.Name, err)
+			}
+			if n.From == "" || len(n.To) == 0 {
+				return fmt.Errorf("notifier %s: email needs a sender and recipients", n.Name)
+			}
+		}
+	}
+	rules := make(map[string]bool)
+	for _, r := range s.Rules {
+		if err := validateRule(r); err != nil {
+			return err
+		}
+		if rules[r.Name] {
+			return fmt.Errorf("duplicate rule: %s", r.Name)
+		}
+		rules[r.Name] = true
+	}
+	return nil
+}
+
+// ruleKinds maps rule conditions to the kind of the alerts they raise
+var ruleKinds = map[string]string{
+	models.RuleConsecutiveFailures: models.AlertFailures,
+	models.RuleAvailability:        models.AlertAvailability,
+	models.RuleP95Latency:          models.AlertLatency,
+	models.RuleErrorKind:           models.AlertErrorKind,
+}
+
+func validateRule(r models.AlertRule) error {
+	if r.Name == "" {
+		return errors.New("rule name is required")
+	}
+	if _, ok := ruleKinds[r.Condition]; !ok {
+		return fmt.Errorf("rule %s: unknown condition %q", r.Name, r.Condition)
+	}
+	if r.WindowMinutes < 0 {
+		return fmt.Errorf("rule %s: window must not be negative", r.Name)
+	}
+	switch r.Condition {
+	case models.RuleConsecutiveFailures, models.RuleErrorKind:
+		if r.Threshold < 1 || r.Threshold != math.Trunc(r.Threshold) {
+			return fmt.Errorf("rule %s: threshold must be a whole number of failures", r.Name)
+		}
+	case models.RuleAvailability:
+		if r.Threshold <= 0 || r.Threshold > 100 {
+			return fmt.Errorf("rule %s: threshold must be a percentage above 0", r.Name)
+		}
+	case models.RuleP95Latency:
+		if r.Threshold <= 0 {
+			return fmt.Errorf("rule %s: threshold must be a positive latency", r.Name)
+		}
+	}
+	if r.Condition == models.RuleErrorKind && !slices.Contains(models.ErrorKinds, r.ErrorKind) {
+		return fmt.Errorf("rule %s: unknown error kind %q", r.Name, r.ErrorKind)
+	}
+	return nil
+}
+
+// Engine tracks each endpoint against its region's thresholds and notifies
+// on transitions: once when an alert starts firing, optionally again every
+// RepeatMinutes while it fires, and once when it resolves. Alerts are kept
+// per region, endpoint and kind, so the same breach never notifies twice.
+type Engine struct {
+	Ctx context.Context
+
+	mu        sync.Mutex
+	settings  models.AlertSettings
+	notifiers []*notifier
+	windows   map[string][]models.TestResult // Endpoint ID -> results in the window
+	streaks   map[string]int                 // Endpoint ID -> failures in a row
+	alerts    map[alertKey]*models.Alert     // Firing alerts, as last sent
+
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// alertKey identifies an alert. Rule and errorKind are empty for region
+// thresholds.
+type alertKey struct {
+	region, endpointID, kind, rule string
+	errorKind                      models.ErrorKind
+}
+
+// check is a condition evaluated on the results of an endpoint: a region
+// threshold or a rule
+type check struct {
+	kind, rule string
+	condition  string
+	threshold  float64 // Zero disables region thresholds
+	errorKind  models.ErrorKind
+	window     time.Duration
+}
+
+// notifier sends its alerts one at a time, in the order they were raised, so
+// a resolved alert never arrives before the one it resolves
+type notifier struct {
+	settings models.NotifierSettings
+	Notifier
+
+	mu      sync.Mutex
+	pending []models.Alert
+	sending bool // A goroutine is sending the pending alerts
+}
+
+func New(ctx context.Context) *Engine {
+	e := &Engine{
+		Ctx:     ctx,
+		windows: make(map[string][]models.TestResult),
+		streaks: make(map[string]int),
+		alerts:  make(map[alertKey]*models.Alert),
+	}
+	e.ctx, e.cancel = context.WithCancel(ctx)
+	return e
+}
+
+// Configure applies the settings and builds their notifiers. Nil settings
+// use the defaults. Unless a desktop notifier is configured, one for every
+// region is added when TypeDesktop is registered.
+func (e *Engine) Configure(settings *models.AlertSettings) error {
+	var s models.AlertSettings
+	if settings != nil {
+		s = *settings
+	}
+	if err := ValidateSettings(s); err != nil {
+		return err
+	}
+	configs := s.Notifiers
+	if _, ok := factory(TypeDesktop); ok && !slices.ContainsFunc(configs, func(n models.NotifierSettings) bool { return n.Type == TypeDesktop }) {
+		configs = append(slices.Clone(configs), models.NotifierSettings{Name: TypeDesktop, Type: TypeDesktop})
+	}
+	notifiers := make([]*notifier, 0, len(configs))
+	for _, cfg := range configs {
+		f, _ := factory(cfg.Type)
+		n, err := f(cfg)
+		if err != nil {
+			return fmt.Errorf("notifier %s: %w", cfg.Name, err)
+		}
+		notifiers = append(notifiers, &notifier{settings: cfg, Notifier: n})
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.settings, e.notifiers = s, notifiers
+	// Alerts of removed or changed rules would never resolve
+	for key := range e.alerts {
+		if key.rule != "" && !slices.ContainsFunc(s.Rules, func(r models.AlertRule) bool {
+			return r.Name == key.rule && ruleKinds[r.Condition] == key.kind && r.ErrorKind == key.errorKind
+		}) {
+			delete(e.alerts, key)
+		}
+	}
+	return nil
+}
+
+// Observe records a result and evaluates its endpoint against the
+// thresholds of the region it is configured in. Cancelled results and
+// results of endpoints no longer configured are ignored.
+func (e *Engine) Observe(cfg *models.Configuration, r models.TestResult) {
+	if r.St == models.TestStatusCancelled {
+		return
+	}
+	regionName, ep, ok := findEndpoint(cfg, r.Id)
+	if !ok {
+		return
+	}
+	thresholds := cfg.Regions[regionName].Thresholds
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	start := r.Ts - e.retention().Milliseconds()
+	results := append(e.windows[r.Id], r)
+	results = slices.DeleteFunc(results, func(old models.TestResult) bool { return old.Ts <= start })
+	e.windows[r.Id] = results
+
+	if r.St == models.TestStatusSuccess {
+		e.streaks[r.Id] = 0
+	} else {
+		e.streaks[r.Id]++
+	}
+
+	var send []models.Alert
+	for _, c := range e.checks(regionName, ep, thresholds) {
+		key := alertKey{regionName, r.Id, c.kind, c.rule, c.errorKind}
+		if c.threshold <= 0 {
+			send = e.transition(send, key, ep.Name, false, 0, 0, r.Ts)
+			continue
+		}
+		if breached, value, ok := evaluate(c, r.Id, results, e.streaks[r.Id], r.Ts); ok {
+			send = e.transition(send, key, ep.Name, breached, value, c.threshold, r.Ts)
+		}
+	}
+
+	for _, alert := range send {
+		e.dispatch(alert)
+	}
+}
+
+// checks returns the region thresholds and the rules that apply to an
+// endpoint. Must be called with mu held.
+func (e *Engine) checks(region string, ep models.Endpoint, t models.Thresholds) []check {
+	window := e.window()
+	limit := e.settings.ConsecutiveFailures
+	if limit == 0 {
+		limit = DefaultConsecutiveFailures
+	}
+	checks := []check{
+		{kind: models.AlertLatency, condition: models.RuleP95Latency, threshold: float64(t.LatencyMs), window: window},
+		{kind: models.AlertAvailability, condition: models.RuleAvailability, threshold: t.AvailabilityPercent, window: window},
+		{kind: models.AlertFailures, condition: models.RuleConsecutiveFailures, threshold: float64(limit)},
+	}
+	for _, r := range e.settings.Rules {
+		if (r.Region != "" && r.Region != region) || (r.Endpoint != "" && r.Endpoint != ep.Name) {
+			continue
+		}
+		c := check{kind: ruleKinds[r.Condition], rule: r.Name, condition: r.Condition, threshold: r.Threshold, errorKind: r.ErrorKind, window: window}
+		if r.WindowMinutes > 0 {
+			c.window = time.Duration(r.WindowMinutes) * time.Minute
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// evaluate tells whether the results of endpoint id up to now breach the
+// check, and the value compared to its threshold. It isn't ok while the
+// window holds too few tests to judge latency or availability.
+func evaluate(c check, id string, results []models.TestResult, streak int, now int64) (breached bool, value float64, ok bool) {
+	if c.condition == models.RuleConsecutiveFailures {
+		return float64(streak) >= c.threshold, float64(streak), true
+	}
+	start := now - c.window.Milliseconds()
+	agg := data.AggregateRange(results, id, time.UnixMilli(start+1), time.UnixMilli(now+1))
+	switch c.condition {
+	case models.RuleP95Latency:
+		if agg.Count-agg.Failures < MinTests {
+			return false, 0, false
+		}
+		return agg.P95Ms > c.threshold, agg.P95Ms, true
+	case models.RuleAvailability:
+		if agg.Count < MinTests {
+			return false, 0, false
+		}
+		availability := float64(agg.Count-agg.Failures) / float64(agg.Count) * 100
+		return availability < c.threshold, availability, true
+	case models.RuleErrorKind:
+		n := float64(agg.FailuresByKind[c.errorKind])
+		return n >= c.threshold, n, true
+	}
+	return false, 0, false
+}
+
+// transition updates the alert of key and appends the notification it
+// calls for, if any. Must be called with mu held.
+func (e *Engine) transition(send []models.Alert, key alertKey, name string, breached bool, value, threshold float64, at int64) []models.Alert {
+	firing, ok := e.alerts[key]
+	switch {
+	case breached && !ok:
+		alert := &models.Alert{
+			Kind: key.kind, Rule: key.rule, ErrorKind: key.errorKind, State: models.AlertFiring, Region: key.region,
+			EndpointID: key.endpointID, EndpointName: name,
+			Value: value, Threshold: threshold, Since: at, At: at,
+		}
+		e.alerts[key] = alert
+		return append(send, *alert)
+	case breached:
+		firing.EndpointName, firing.Value, firing.Threshold = name, value, threshold
+		repeat := time.Duration(e.settings.RepeatMinutes) * time.Minute
+		if repeat > 0 && at-firing.At >= repeat.Milliseconds() {
+			firing.At, firing.Repeat = at, true
+			return append(send, *firing)
+		}
+	case ok:
+		delete(e.alerts, key)
+		resolved := *firing
+		resolved.State, resolved.Value, resolved.At, resolved.Repeat = models.AlertResolved, value, at, false
+		return append(send, resolved)
+	}
+	return send
+}
+
+// dispatch queues the alert to every notifier of its region, each sending
+// its queue in the background
+func (e *Engine) dispatch(alert models.Alert) {
+	log.Ctx(e.Ctx).Warn().
+		Str("kind", alert.Kind).
+		Str("state", alert.State).
+		Str("region", alert.Region).
+		Str("endpoint", alert.EndpointID).
+		Float64("value", alert.Value).
+		Float64("threshold", alert.Threshold).
+		Msg("Alert")
+	for _, n := range e.notifiers {
+		if len(n.settings.Regions) > 0 && !slices.Contains(n.settings.Regions, alert.Region) {
+			continue
+		}
+		n.mu.Lock()
+		n.pending = append(n.pending, alert)
+		if !n.sending {
+			n.sending = true
+			e.wg.Add(1)
+			go e.send(n)
+		}
+		n.mu.Unlock()
+	}
+}
+
+// send sends the pending alerts of n until none is left
+func (e *Engine) send(n *notifier) {
+	defer e.wg.Done()
+	for {
+		n.mu.Lock()
+		if len(n.pending) == 0 {
+			n.sending = false
+			n.mu.Unlock()
+			return
+		}
+		alert := n.pending[0]
+		n.pending = n.pending[1:]
+		n.mu.Unlock()
+
+		timeout := NotifyTimeout
+		if t, ok := n.Notifier.(timeouter); ok {
+			timeout = t.Timeout()
+		}
+		ctx, cancel := context.WithTimeout(e.ctx, timeout)
+		if err := n.Notify(ctx, alert); err != nil {
+			log.Ctx(e.Ctx).Error().Err(err).Str("notifier", n.settings.Name).Str("endpoint", alert.EndpointID).Msg("Failed to send alert")
+		}
+		cancel()
+	}
+}
+
+// Active returns the firing alerts, oldest first
+func (e *Engine) Active() []models.Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	alerts := make([]models.Alert, 0, len(e.alerts))
+	for _, alert := range e.alerts {
+		alerts = append(alerts, *alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Since != alerts[j].Since {
+			return alerts[i].Since < alerts[j].Since
+		}
+		return alerts[i].EndpointID+alerts[i].Kind < alerts[j].EndpointID+alerts[j].Kind
+	})
+	return alerts
+}
+
+// Stop cancels the notifications in flight and waits for them to return
+func (e *Engine) Stop() {
+	e.cancel()
+	e.wg.Wait()
+}
+
+// window returns the configured window. Must be called with mu held.
+func (e *Engine) window() time.Duration {
+	if e.settings.WindowMinutes > 0 {
+		return time.Duration(e.settings.WindowMinutes) * time.Minute
+	}
+	return DefaultWindow
+}
+
+// retention returns how long results are kept: the longest window of the
+// settings and the rules. Must be called with mu held.
+func (e *Engine) retention() time.Duration {
+	longest := e.window()
+	for _, r := range e.settings.Rules {
+		longest = max(longest, time.Duration(r.WindowMinutes)*time.Minute)
+	}
+	return longest
+}
+
+func findEndpoint(cfg *models.Configuration, id string) (string, models.Endpoint, bool) {
+	for regionName, region := range cfg.Regions {
+		for _, ep := range region.Endpoints {
+			if network.EndpointID(ep.Address, ep.Type) == id {
+				return regionName, ep, true
+			}
+		}
+	}
+	return "", models.Endpoint{}, false
+}
@@ -0,0 +1,359 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	alerts []models.Alert
+}
+
+func (r *recorder) Notify(ctx context.Context, alert models.Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func (r *recorder) take() []models.Alert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	alerts := r.alerts
+	r.alerts = nil
+	return alerts
+}
+
+func testConfig() (*models.Configuration, string) {
+	ep := models.Endpoint{Name: "Router", Type: models.TypeICMP, Address: "192.168.1.1"}
+	return &models.Configuration{Regions: map[string]models.Region{
+		"Home": {Endpoints: []models.Endpoint{ep}, Thresholds: models.Thresholds{LatencyMs: 100, AvailabilityPercent: 60}},
+	}}, network.EndpointID(ep.Address, ep.Type)
+}
+
+func TestEngine(t *testing.T) {
+	rec := &recorder{}
+	Register("test", func(models.NotifierSettings) (Notifier, error) { return rec, nil })
+	e := New(context.Background())
+	defer e.Stop()
+	if err := e.Configure(&models.AlertSettings{RepeatMinutes: 1, Notifiers: []models.NotifierSettings{{Name: "rec", Type: "test"}}}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, id := testConfig()
+
+	observe := func(ts int64, st int, ms int64) []models.Alert {
+		e.Observe(cfg, models.TestResult{Ts: ts, Id: id, St: st, Ms: ms})
+		e.wg.Wait()
+		return rec.take()
+	}
+
+	// Slow tests alert once three are in the window
+	if got := observe(1000, models.TestStatusSuccess, 500); len(got) != 0 {
+		t.Errorf("Expected no alert below MinTests, got %+v", got)
+	}
+	observe(2000, models.TestStatusSuccess, 500)
+	got := observe(3000, models.TestStatusSuccess, 500)
+	if len(got) != 1 || got[0].Kind != models.AlertLatency || got[0].State != models.AlertFiring || got[0].Region != "Home" || got[0].EndpointName != "Router" {
+		t.Fatalf("Expected a latency alert, got %+v", got)
+	}
+	if got := observe(4000, models.TestStatusSuccess, 500); len(got) != 0 {
+		t.Errorf("Expected no duplicate while firing, got %+v", got)
+	}
+	if got := observe(64_000, models.TestStatusSuccess, 500); len(got) != 1 || !got[0].Repeat || got[0].Since != 3000 {
+		t.Errorf("Expected a reminder after RepeatMinutes, got %+v", got)
+	}
+	if active := e.Active(); len(active) != 1 || active[0].Kind != models.AlertLatency {
+		t.Errorf("Expected the latency alert to be active, got %+v", active)
+	}
+
+	// Failures in a row alert on the third, availability once below 60%
+	observe(65_000, models.TestStatusTimeout, 0)
+	observe(66_000, models.TestStatusTimeout, 0)
+	got = observe(67_000, models.TestStatusTimeout, 0)
+	if len(got) != 1 || got[0].Kind != models.AlertFailures || got[0].Value != 3 {
+		t.Errorf("Expected a consecutive failures alert, got %+v", got)
+	}
+	got = observe(68_000, models.TestStatusTimeout, 0)
+	if len(got) != 1 || got[0].Kind != models.AlertAvailability || got[0].Value >= 60 {
+		t.Errorf("Expected an availability alert, got %+v", got)
+	}
+
+	// A fast success resolves the failures, latency resolves once the
+	// window moves past the slow tests
+	got = observe(69_000, models.TestStatusSuccess, 10)
+	if !slices.ContainsFunc(got, func(a models.Alert) bool {
+		return a.Kind == models.AlertFailures && a.State == models.AlertResolved
+	}) {
+		t.Errorf("Expected the failures alert to resolve, got %+v", got)
+	}
+	for ts := int64(70_000); ts < 71_000+int64(DefaultWindow.Milliseconds()); ts += 60_000 {
+		got = append(got, observe(ts, models.TestStatusSuccess, 10)...)
+	}
+	resolved := map[string]bool{}
+	for _, a := range got {
+		if a.State == models.AlertResolved {
+			resolved[a.Kind] = true
+		}
+	}
+	if !resolved[models.AlertLatency] || !resolved[models.AlertAvailability] || len(e.Active()) != 0 {
+		t.Errorf("Expected every alert to resolve, got %+v, active %+v", got, e.Active())
+	}
+}
+
+// slowRecorder takes a while to send its first alert
+type slowRecorder struct {
+	recorder
+	calls atomic.Int32
+}
+
+func (r *slowRecorder) Notify(ctx context.Context, alert models.Alert) error {
+	if r.calls.Add(1) == 1 {
+		time.Sleep(50 * time.Millisecond)
+	}
+	return r.recorder.Notify(ctx, alert)
+}
+
+func TestEngineOrder(t *testing.T) {
+	rec := &slowRecorder{}
+	Register("slow", func(models.NotifierSettings) (Notifier, error) { return rec, nil })
+	e := New(context.Background())
+	defer e.Stop()
+	if err := e.Configure(&models.AlertSettings{ConsecutiveFailures: 1, Notifiers: []models.NotifierSettings{{Name: "slow", Type: "slow"}}}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, id := testConfig()
+	e.Observe(cfg, models.TestResult{Ts: 1000, Id: id, St: models.TestStatusError})
+	e.Observe(cfg, models.TestResult{Ts: 2000, Id: id, St: models.TestStatusSuccess, Ms: 10})
+	e.wg.Wait()
+	got := rec.take()
+	if len(got) != 2 || got[0].State != models.AlertFiring || got[1].State != models.AlertResolved {
+		t.Errorf("Expected the alert to fire then resolve, got %+v", got)
+	}
+}
+
+func TestEngineRegions(t *testing.T) {
+	home, office := &recorder{}, &recorder{}
+	Register("home", func(models.NotifierSettings) (Notifier, error) { return home, nil })
+	Register("office", func(models.NotifierSettings) (Notifier, error) { return office, nil })
+	e := New(context.Background())
+	defer e.Stop()
+	err := e.Configure(&models.AlertSettings{ConsecutiveFailures: 1, Notifiers: []models.NotifierSettings{
+		{Name: "home", Type: "home", Regions: []string{"Home"}},
+		{Name: "office", Type: "office", Regions: []string{"Office"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, id := testConfig()
+	e.Observe(cfg, models.TestResult{Ts: 1000, Id: id, St: models.TestStatusError})
+	e.Observe(cfg, models.TestResult{Ts: 2000, Id: "unknown", St: models.TestStatusError})
+	e.wg.Wait()
+	if got := home.take(); len(got) != 1 || got[0].Kind != models.AlertFailures {
+		t.Errorf("Expected the Home notifier to get the alert, got %+v", got)
+	}
+	if got := office.take(); len(got) != 0 {
+		t.Errorf("Expected the Office notifier to get nothing, got %+v", got)
+	}
+}
+
+func TestEngineRules(t *testing.T) {
+	rec := &recorder{}
+	Register("rules", func(models.NotifierSettings) (Notifier, error) { return rec, nil })
+	e := New(context.Background())
+	defer e.Stop()
+	settings := &models.AlertSettings{ConsecutiveFailures: 100, Notifiers: []models.NotifierSettings{{Name: "rec", Type: "rules"}}, Rules: []models.AlertRule{
+		{Name: "dns", Condition: models.RuleErrorKind, ErrorKind: models.ErrorKindDNS, Threshold: 2, WindowMinutes: 10},
+		{Name: "strict", Region: "Home", Endpoint: "Router", Condition: models.RuleP95Latency, Threshold: 20},
+		{Name: "elsewhere", Region: "Office", Condition: models.RuleConsecutiveFailures, Threshold: 1},
+	}}
+	if err := e.Configure(settings); err != nil {
+		t.Fatal(err)
+	}
+	cfg, id := testConfig()
+	observe := func(ts int64, st int, ek models.ErrorKind, ms int64) []models.Alert {
+		e.Observe(cfg, models.TestResult{Ts: ts, Id: id, St: st, Ek: ek, Ms: ms})
+		e.wg.Wait()
+		return rec.take()
+	}
+
+	// The rule fires below the 100 ms region threshold
+	observe(1000, models.TestStatusSuccess, "", 50)
+	observe(2000, models.TestStatusSuccess, "", 50)
+	got := observe(3000, models.TestStatusSuccess, "", 50)
+	if len(got) != 1 || got[0].Rule != "strict" || got[0].Kind != models.AlertLatency || got[0].Threshold != 20 {
+		t.Fatalf("Expected the strict rule to fire, got %+v", got)
+	}
+
+	// DNS failures count over the rule's own 10 minute window
+	observe(4000, models.TestStatusError, models.ErrorKindDNS, 0)
+	got = observe(400_000, models.TestStatusError, models.ErrorKindDNS, 0)
+	if len(got) != 1 || got[0].Rule != "dns" || got[0].Kind != models.AlertErrorKind || got[0].Value != 2 {
+		t.Fatalf("Expected the dns rule to fire, got %+v", got)
+	}
+	if text := Text(got[0]); text != "[FIRING] Router (Home): 2 dns failures in the window, threshold 2, rule dns" {
+		t.Errorf("Unexpected text: %s", text)
+	}
+
+	// Removing a rule drops its alert
+	settings.Rules = settings.Rules[:1]
+	if err := e.Configure(settings); err != nil {
+		t.Fatal(err)
+	}
+	if active := e.Active(); len(active) != 1 || active[0].Rule != "dns" {
+		t.Errorf("Expected only the dns alert to stay active, got %+v", active)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer srv.Close()
+
+	n, _ := newWebhook(models.NotifierSettings{URL: srv.URL})
+	alert := models.Alert{Kind: models.AlertLatency, State: models.AlertFiring, Region: "Home", EndpointName: "Router", Value: 150, Threshold: 100}
+	if err := n.Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	if body["kind"] != "latency" || body["text"] != "[FIRING] Router (Home): p95 latency 150 ms, threshold 100 ms" {
+		t.Errorf("Unexpected payload: %v", body)
+	}
+
+	// Server errors are retried, other client errors aren't
+	var attempts, status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer failing.Close()
+	n = &webhook{url: failing.URL, retries: 2, backoff: time.Millisecond, timeout: time.Second, client: http.DefaultClient}
+	if err := n.Notify(context.Background(), alert); err == nil || attempts.Load() != 3 {
+		t.Errorf("Expected 3 failed attempts, got %d: %v", attempts.Load(), err)
+	}
+	attempts.Store(0)
+	status.Store(http.StatusBadRequest)
+	if err := n.Notify(context.Background(), alert); err == nil || attempts.Load() != 1 {
+		t.Errorf("Expected 1 failed attempt, got %d: %v", attempts.Load(), err)
+	}
+}
+
+func TestWebhookLastRetry(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= MaxRetries {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	retries := MaxRetries
+	e := New(context.Background())
+	defer e.Stop()
+	err := e.Configure(&models.AlertSettings{ConsecutiveFailures: 1, Notifiers: []models.NotifierSettings{
+		{Name: "hook", Type: TypeWebhook, URL: srv.URL, Retries: &retries},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The backoff alone outlasts NotifyTimeout
+	w := e.notifiers[0].Notifier.(*webhook)
+	if waits := (1<<MaxRetries - 1) * retryBackoff; w.Timeout() <= waits {
+		t.Errorf("Expected the timeout to cover %v of backoff, got %v", waits, w.Timeout())
+	}
+	// Scaled down so the retries run in about a second
+	w.backoff, w.timeout = time.Millisecond, 50*time.Millisecond
+
+	cfg, id := testConfig()
+	e.Observe(cfg, models.TestResult{Ts: 1000, Id: id, St: models.TestStatusError})
+	e.wg.Wait()
+	if n := attempts.Load(); n != MaxRetries+1 {
+		t.Errorf("Expected the last retry to be sent, got %d attempts", n)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer srv.Close()
+
+	tmpl := `{"routing_key": "abc", "event_action": {{if eq .State "firing"}}"trigger"{{else}}"resolve"{{end}},
+		"dedup_key": {{json (printf "%s/%s" .EndpointID .Metric)}},
+		"payload": {"summary": {{json .Text}}, "source": {{json .Endpoint}}, "group": {{json .Region}}, "custom_details": {"value": {{.Value}}, "threshold": {{.Threshold}}}}}`
+	n, err := newWebhook(models.NotifierSettings{URL: srv.URL, Template: tmpl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alert := models.Alert{Kind: models.AlertAvailability, State: models.AlertResolved, Region: "Home", EndpointID: "5126db9", EndpointName: `Router "main"`, Value: 99.5, Threshold: 99}
+	if err := n.Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := body["payload"].(map[string]any)
+	if body["event_action"] != "resolve" || body["dedup_key"] != "5126db9/availability" || payload["source"] != `Router "main"` {
+		t.Errorf("Unexpected payload: %v", body)
+	}
+
+	for _, bad := range []string{`{"a": {{.Missing}}}`, `{"a": {{.Endpoint}}}`, `{{`} {
+		if _, err := newWebhook(models.NotifierSettings{URL: srv.URL, Template: bad}); err == nil {
+			t.Errorf("Expected template %q to be rejected", bad)
+		}
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	s := models.NotifierSettings{From: "netmonitor@example.com", To: []string{"a@example.com", "b@example.com"}}
+	msg := string(emailMessage(s, models.Alert{Kind: models.AlertFailures, State: models.AlertResolved, EndpointName: "Evil\r\nBcc: x@example.com", Value: 0, Threshold: 3}))
+	if !strings.Contains(msg, "To: a@example.com, b@example.com\r\n") || !strings.Contains(msg, "Subject: netmonitor [RESOLVED] Evil  Bcc: x@example.com") {
+		t.Errorf("Unexpected message:\n%s", msg)
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+	tooMany := MaxRetries + 1
+	for _, s := range []models.AlertSettings{
+		{WindowMinutes: -1},
+		{Notifiers: []models.NotifierSettings{{Type: TypeWebhook, URL: "https://example.com"}}},
+		{Notifiers: []models.NotifierSettings{{Name: "a", Type: "pager"}}},
+		{Notifiers: []models.NotifierSettings{{Name: "a", Type: TypeWebhook, URL: "example.com/hook"}}},
+		{Notifiers: []models.NotifierSettings{{Name: "a", Type: TypeWebhook, URL: "https://example.com", Retries: &tooMany}}},
+		{Notifiers: []models.NotifierSettings{{Name: "a", Type: TypeWebhook, URL: "https://example.com", Template: "not json"}}},
+		{Notifiers: []models.NotifierSettings{{Name: "a", Type: TypeEmail, SMTPHost: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}}}},
+		{Notifiers: []models.NotifierSettings{{Name: "a", Type: TypeEmail, SMTPHost: "smtp.example.com:587"}}},
+		{Notifiers: []models.NotifierSettings{{Name: "a", Type: TypeWebhook, URL: "https://example.com"}, {Name: "a", Type: TypeWebhook, URL: "https://example.com"}}},
+		{Rules: []models.AlertRule{{Condition: models.RuleP95Latency, Threshold: 100}}},
+		{Rules: []models.AlertRule{{Name: "a", Condition: "jitter", Threshold: 100}}},
+		{Rules: []models.AlertRule{{Name: "a", Condition: models.RuleAvailability, Threshold: 101}}},
+		{Rules: []models.AlertRule{{Name: "a", Condition: models.RuleConsecutiveFailures, Threshold: 2.5}}},
+		{Rules: []models.AlertRule{{Name: "a", Condition: models.RuleErrorKind, Threshold: 1}}},
+		{Rules: []models.AlertRule{{Name: "a", Condition: models.RuleP95Latency, Threshold: 100, WindowMinutes: -5}}},
+		{Rules: []models.AlertRule{{Name: "a", Condition: models.RuleP95Latency, Threshold: 100}, {Name: "a", Condition: models.RuleAvailability, Threshold: 99}}},
+	} {
+		if err := ValidateSettings(s); err == nil {
+			t.Errorf("Expected %+v to be rejected", s)
+		}
+	}
+	if err := ValidateSettings(models.AlertSettings{Notifiers: []models.NotifierSettings{
+		{Name: "hook", Type: TypeWebhook, URL: "https://example.com/hook"},
+		{Name: "mail", Type: TypeEmail, SMTPHost: "smtp.example.com:587", From: "a@example.com", To: []string{"b@example.com"}},
+	}, Rules: []models.AlertRule{
+		{Name: "tls", Condition: models.RuleErrorKind, ErrorKind: models.ErrorKindTLS, Threshold: 1},
+		{Name: "sla", Region: "Home", Condition: models.RuleAvailability, Threshold: 99.9, WindowMinutes: 60},
+	}}); err != nil {
+		t.Error(err)
+	}
+}
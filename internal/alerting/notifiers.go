@@ -0,0 +1,83 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// Text describes an alert in one line, for email subjects and chat webhooks
+func Text(a models.Alert) string {
+	state := "FIRING"
+	if a.State == models.AlertResolved {
+		state = "RESOLVED"
+	}
+	var what string
+	switch a.Kind {
+	case models.AlertLatency:
+		what = fmt.Sprintf("p95 latency %.0f ms, threshold %.0f ms", a.Value, a.Threshold)
+	case models.AlertAvailability:
+		what = fmt.Sprintf("availability %.2f%%, threshold %.2f%%", a.Value, a.Threshold)
+	case models.AlertFailures:
+		what = fmt.Sprintf("%.0f failed tests in a row, threshold %.0f", a.Value, a.Threshold)
+	case models.AlertErrorKind:
+		what = fmt.Sprintf("%.0f %s failures in the window, threshold %.0f", a.Value, a.ErrorKind, a.Threshold)
+	default:
+		what = fmt.Sprintf("%s %g, threshold %g", a.Kind, a.Value, a.Threshold)
+	}
+	if a.Rule != "" {
+		what += ", rule " + a.Rule
+	}
+	return fmt.Sprintf("[%s] %s (%s): %s", state, a.EndpointName, a.Region, what)
+}
+
+// email sends alerts through an SMTP server, with PLAIN authentication when
+// a username is set
+type email struct {
+	settings models.NotifierSettings
+}
+
+func newEmail(s models.NotifierSettings) (Notifier, error) {
+	return &email{settings: s}, nil
+}
+
+func (m *email) Notify(ctx context.Context, alert models.Alert) error {
+	s := m.settings
+	var auth smtp.Auth
+	if s.SMTPUsername != "" {
+		host, _, _ := net.SplitHostPort(s.SMTPHost) // Validated
+		auth = smtp.PlainAuth("", s.SMTPUsername, s.SMTPPassword, host)
+	}
+	// net/smtp takes no context, send in the background and stop waiting
+	// when it is done
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(s.SMTPHost, auth, s.From, s.To, emailMessage(s, alert)) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// headerEscaper keeps endpoint names from breaking out of the subject header
+var headerEscaper = strings.NewReplacer("\r", " ", "\n", " ")
+
+func emailMessage(s models.NotifierSettings, alert models.Alert) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&b, "Subject: netmonitor %s\r\n", headerEscaper.Replace(Text(alert)))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.UnixMilli(alert.At).Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "%s\r\n\r\n", Text(alert))
+	fmt.Fprintf(&b, "Endpoint: %s (%s)\r\n", alert.EndpointName, alert.EndpointID)
+	fmt.Fprintf(&b, "Region: %s\r\n", alert.Region)
+	fmt.Fprintf(&b, "Since: %s\r\n", time.UnixMilli(alert.Since).Format(time.RFC3339))
+	return []byte(b.String())
+}
@@ -0,0 +1,173 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+const (
+	// DefaultRetries is how many times a failed webhook is retried when the
+	// settings don't say
+	DefaultRetries = 3
+
+	// MaxRetries bounds the retries of a webhook
+	MaxRetries = 10
+
+	// retryBackoff is the wait before the first retry, doubled before each
+	// of the next ones
+	retryBackoff = time.Second
+)
+
+// TemplateData is what webhook templates are executed with
+type TemplateData struct {
+	Endpoint   string // Endpoint name
+	EndpointID string
+	Region     string
+	Metric     string  // models.AlertLatency, AlertAvailability, AlertFailures or AlertErrorKind
+	Rule       string  // Name of the rule that fired, empty for region thresholds
+	ErrorKind  string  // Kind counted by AlertErrorKind alerts
+	State      string  // models.AlertFiring or AlertResolved
+	Value      float64 // Current value: p95 ms, availability %, failures in a row or failures of the kind
+	Threshold  float64 // In the same unit
+	Since      time.Time
+	At         time.Time
+	Repeat     bool
+	Text       string // One line description, as Text returns
+}
+
+func templateData(a models.Alert) TemplateData {
+	return TemplateData{
+		Endpoint: a.EndpointName, EndpointID: a.EndpointID, Region: a.Region,
+		Metric: a.Kind, Rule: a.Rule, ErrorKind: string(a.ErrorKind), State: a.State, Value: a.Value, Threshold: a.Threshold,
+		Since: time.UnixMilli(a.Since).UTC(), At: time.UnixMilli(a.At).UTC(),
+		Repeat: a.Repeat, Text: Text(a),
+	}
+}
+
+// templateFuncs are available to webhook templates. json quotes a value as
+// JSON, so names with quotes can't break the payload.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// parseTemplate parses a webhook template and checks that it renders JSON
+func parseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("webhook").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sample := models.Alert{Kind: models.AlertLatency, State: models.AlertFiring, Region: "Default", EndpointName: "Example", Value: 150, Threshold: 100}
+	if _, err := render(tmpl, sample); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func render(tmpl *template.Template, alert models.Alert) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, templateData(alert)); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.New("template does not render valid JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+// webhook POSTs alerts as JSON, rendered from the settings' template or else
+// the alert itself with a text field, which Slack-compatible incoming
+// webhooks read as is. Network errors, 429 and 5xx responses are retried with
+// exponential backoff.
+type webhook struct {
+	url     string
+	tmpl    *template.Template
+	retries int
+	backoff time.Duration
+	timeout time.Duration // Of each attempt
+	client  *http.Client
+}
+
+func newWebhook(s models.NotifierSettings) (Notifier, error) {
+	w := &webhook{url: s.URL, retries: DefaultRetries, backoff: retryBackoff, timeout: NotifyTimeout, client: http.DefaultClient}
+	if s.Retries != nil {
+		w.retries = *s.Retries
+	}
+	if s.Template != "" {
+		tmpl, err := parseTemplate(s.Template)
+		if err != nil {
+			return nil, err
+		}
+		w.tmpl = tmpl
+	}
+	return w, nil
+}
+
+func (w *webhook) Notify(ctx context.Context, alert models.Alert) error {
+	var body []byte
+	var err error
+	if w.tmpl != nil {
+		body, err = render(w.tmpl, alert)
+	} else {
+		body, err = json.Marshal(struct {
+			models.Alert
+			Text string `json:"text"`
+		}{alert, Text(alert)})
+	}
+	if err != nil {
+		return err
+	}
+
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, body)
+		if err == nil || !retry || attempt >= w.retries {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("%w, giving up: %w", err, ctx.Err())
+		}
+		backoff *= 2
+	}
+}
+
+// Timeout leaves room for every attempt and the backoff between them
+func (w *webhook) Timeout() time.Duration {
+	total := time.Duration(w.retries+1) * w.timeout
+	for i, backoff := 0, w.backoff; i < w.retries; i, backoff = i+1, backoff*2 {
+		total += backoff
+	}
+	return total
+}
+
+// post sends one request, reporting whether a failure is worth retrying
+func (w *webhook) post(ctx context.Context, body []byte) (retry bool, err error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(attemptCtx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,591 @@
+// Package api serves endpoint status, results and the config over HTTP so
+// other tools on the network can read them. Every request is authenticated
+// with a bearer token whose scope decides whether it may change the config.
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/config"
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultListen is used when the API settings don't set an address. The API
+// only listens on loopback until configured otherwise.
+const DefaultListen = "127.0.0.1:8321"
+
+// DefaultResultsRange is the period served by /results without a since parameter
+const DefaultResultsRange = time.Hour
+
+// shutdownTimeout bounds how long Stop waits for requests in progress
+const shutdownTimeout = 5 * time.Second
+
+// ErrNotFound is returned by Backend methods when what they were asked to
+// change doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// Backend is what the API serves, implemented by the app
+type Backend interface {
+	Config() models.Configuration
+	SaveConfig(models.Configuration) error
+	States() map[string]models.EndpointState
+	Services() []models.ServiceStatus
+	// RegionMap takes one of models.SummaryWindows
+	RegionMap(window string) []models.RegionMapEntry
+	Results(start, end time.Time) []models.TestResult
+	Trends(days int) []models.LatencyTrend
+	// EndpointSummary fails when the endpoint isn't configured
+	EndpointSummary(id, window string) (models.EndpointSummary, error)
+	// LatencyDistribution fails when the endpoint isn't configured. A zero
+	// bucketMs picks the bucket size.
+	LatencyDistribution(id, window string, bucketMs float64) (models.LatencyDistribution, error)
+	// Alerts returns the alerts firing
+	Alerts() []models.Alert
+	AlertRules() []models.AlertRule
+	// SaveAlertRule adds a rule or replaces the rule with the same name
+	SaveAlertRule(models.AlertRule) error
+	// DeleteAlertRule returns ErrNotFound when no rule has the name
+	DeleteAlertRule(name string) error
+	Exports() []models.ExportStatus
+	CreateExport(models.ExportRequest) (models.ExportStatus, error)
+	ExportStatus(id string) (models.ExportStatus, error)
+	ExportAudit() ([]models.ExportAuditEntry, error)
+	PublicStatus() models.PublicStatus
+	LogLevel() string
+	SetLogLevel(level string) error
+}
+
+// Server runs the API listener
+type Server struct {
+	Ctx     context.Context
+	Backend Backend
+	// Version is the app version reported in the OpenAPI document
+	Version string
+
+	mu       sync.Mutex
+	settings models.APISettings
+	proxies  []netip.Prefix
+	srv      *http.Server
+	addr     net.Addr
+
+	subsMu sync.Mutex
+	subs   map[chan []byte]struct{}
+
+	status *statusCache
+}
+
+func New(ctx context.Context, backend Backend) *Server {
+	return &Server{Ctx: ctx, Backend: backend, status: newStatusCache()}
+}
+
+// Configure applies new settings. The listener is only restarted when the
+// address or TLS settings change, other changes apply to the next request.
+// Nil or disabled settings stop the API.
+func (s *Server) Configure(settings *models.APISettings) error {
+	if settings == nil || !settings.Enabled {
+		s.Stop()
+		return nil
+	}
+	cfg := *settings
+	if cfg.Listen == "" {
+		cfg.Listen = DefaultListen
+	}
+	cfg.BasePath = normalizeBasePath(cfg.BasePath)
+	if err := ValidateSettings(cfg); err != nil {
+		return err
+	}
+	proxies, _ := parseProxies(cfg.TrustedProxies)
+
+	s.mu.Lock()
+	if s.srv != nil && sameListener(s.settings, cfg) {
+		s.settings, s.proxies = cfg, proxies
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+
+	tlsConfig, err := loadTLS(cfg)
+	if err != nil {
+		return err
+	}
+	s.Stop()
+	ln, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	s.mu.Lock()
+	s.settings, s.proxies = cfg, proxies
+	s.srv = srv
+	s.addr = ln.Addr()
+	s.mu.Unlock()
+
+	log.Ctx(s.Ctx).Info().Str("addr", ln.Addr().String()).Bool("tls", tlsConfig != nil).Msg("API server started")
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Ctx(s.Ctx).Error().Err(err).Msg("API server failed")
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the API listens on, nil when it isn't running
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addr
+}
+
+// Stop closes the listener, waiting briefly for requests in progress
+func (s *Server) Stop() {
+	s.mu.Lock()
+	srv := s.srv
+	s.srv, s.addr = nil, nil
+	s.mu.Unlock()
+	if srv == nil {
+		return
+	}
+	s.closeStreams()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Ctx(s.Ctx).Warn().Err(err).Msg("API server did not shut down cleanly")
+	}
+	log.Ctx(s.Ctx).Info().Msg("API server stopped")
+}
+
+func sameListener(a, b models.APISettings) bool {
+	return a.Listen == b.Listen && a.TLSCert == b.TLSCert && a.TLSKey == b.TLSKey && a.ClientCA == b.ClientCA
+}
+
+// loadTLS returns the listener's TLS config, nil for plain HTTP
+func loadTLS(cfg models.APISettings) (*tls.Config, error) {
+	if cfg.TLSCert == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if cfg.ClientCA != "" {
+		pem, err := os.ReadFile(cfg.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("client CA file has no certificates")
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// route is an API operation. The same table registers the handlers and
+// generates the OpenAPI document.
+type route struct {
+	Method  string
+	Path    string
+	Scope   string // Required token scope, empty for public routes
+	Summary string
+	Params  []param
+	// Request and Response are values of the body types, nil for none
+	Request  any
+	Response any
+	handler  http.HandlerFunc
+}
+
+// param is a path or query parameter
+type param struct {
+	Name        string
+	In          string // "path" or "query"
+	Description string
+}
+
+func (s *Server) routes() []route {
+	return []route{
+		{Method: "GET", Path: "/api/v1/states", Scope: models.ScopeRead,
+			Summary:  "Last known state of every endpoint, keyed by endpoint ID",
+			Response: map[string]models.EndpointState{}, handler: s.getStates},
+		{Method: "GET", Path: "/api/v1/services", Scope: models.ScopeRead,
+			Summary:  "Rolled up status of every configured service",
+			Response: []models.ServiceStatus{}, handler: s.getServices},
+		{Method: "GET", Path: "/api/v1/regions/map", Scope: models.ScopeRead,
+			Summary:  "Status, location and time zone of every region, with its availability and p95 latency over the window, for map views",
+			Params:   []param{{Name: "window", In: "query", Description: "1h, day, week or month. Defaults to day."}},
+			Response: []models.RegionMapEntry{}, handler: s.getRegionMap},
+		{Method: "GET", Path: "/api/v1/results", Scope: models.ScopeRead,
+			Summary:  "Test results of the configured endpoints",
+			Params:   []param{{Name: "since", In: "query", Description: "Period ending now as a Go duration, e.g. 30m or 24h. Defaults to 1h."}},
+			Response: []models.TestResult{}, handler: s.getResults},
+		{Method: "GET", Path: "/api/v1/trends", Scope: models.ScopeRead,
+			Summary:  "Daily p95 latency trend of every endpoint with enough data, flagging degrading ones",
+			Params:   []param{{Name: "days", In: "query", Description: "Days to analyze, ending today. Defaults to 28."}},
+			Response: []models.LatencyTrend{}, handler: s.getTrends},
+		{Method: "GET", Path: "/api/v1/endpoints/{id}/summary", Scope: models.ScopeRead,
+			Summary: "Current status, availability, mean and p95 latency, failure streaks, last outage and latency trend of an endpoint",
+			Params: []param{
+				{Name: "id", In: "path", Description: "Endpoint ID"},
+				{Name: "window", In: "query", Description: "1h, day, week or month. Defaults to day."},
+			},
+			Response: models.EndpointSummary{}, handler: s.getEndpointSummary},
+		{Method: "GET", Path: "/api/v1/endpoints/{id}/latency", Scope: models.ScopeRead,
+			Summary: "Latency distribution of an endpoint's successful tests, binned for histogram charts",
+			Params: []param{
+				{Name: "id", In: "path", Description: "Endpoint ID"},
+				{Name: "window", In: "query", Description: "1h, day, week or month. Defaults to day."},
+				{Name: "bucket_ms", In: "query", Description: "Bin width in milliseconds, widened to fit 1000 bins. Defaults to about 30 bins."},
+			},
+			Response: models.LatencyDistribution{}, handler: s.getLatencyDistribution},
+		{Method: "GET", Path: "/api/v1/config", Scope: models.ScopeRead,
+			Summary:  "Current configuration, without API settings and secrets",
+			Response: models.Configuration{}, handler: s.getConfig},
+		{Method: "PUT", Path: "/api/v1/config", Scope: models.ScopeAdmin,
+			Summary: "Replace the configuration. API settings and secrets omitted from the body are kept.",
+			Request: models.Configuration{}, Response: models.Configuration{}, handler: s.putConfig},
+		{Method: "GET", Path: "/api/v1/alerts", Scope: models.ScopeRead,
+			Summary:  "Threshold and rule alerts firing, oldest first",
+			Response: []models.Alert{}, handler: s.getAlerts},
+		{Method: "GET", Path: "/api/v1/alert-rules", Scope: models.ScopeRead,
+			Summary:  "Configured alert rules",
+			Response: []models.AlertRule{}, handler: s.getAlertRules},
+		{Method: "PUT", Path: "/api/v1/alert-rules/{name}", Scope: models.ScopeAdmin,
+			Summary: "Add or replace an alert rule. The name in the path wins over the body's.",
+			Params:  []param{{Name: "name", In: "path", Description: "Rule name"}},
+			Request: models.AlertRule{}, Response: models.AlertRule{}, handler: s.putAlertRule},
+		{Method: "DELETE", Path: "/api/v1/alert-rules/{name}", Scope: models.ScopeAdmin,
+			Summary: "Remove an alert rule. Its firing alerts are dropped without a resolved notification.",
+			Params:  []param{{Name: "name", In: "path", Description: "Rule name"}},
+			handler: s.deleteAlertRule},
+		{Method: "GET", Path: "/api/v1/exports", Scope: models.ScopeRead,
+			Summary:  "Export jobs, most recent first",
+			Response: []models.ExportStatus{}, handler: s.getExports},
+		{Method: "POST", Path: "/api/v1/exports", Scope: models.ScopeAdmin,
+			Summary: "Queue an export job written to the default export directory",
+			Request: models.ExportRequest{}, Response: models.ExportStatus{}, handler: s.postExport},
+		{Method: "GET", Path: "/api/v1/exports/audit", Scope: models.ScopeAdmin,
+			Summary:  "Export audit log, most recent first: who requested each export through which interface, its filters, destination and rows",
+			Response: []models.ExportAuditEntry{}, handler: s.getExportAudit},
+		{Method: "GET", Path: "/api/v1/exports/{id}", Scope: models.ScopeRead,
+			Summary:  "Status of an export job",
+			Params:   []param{{Name: "id", In: "path", Description: "Export job ID"}},
+			Response: models.ExportStatus{}, handler: s.getExport},
+		{Method: "GET", Path: "/api/v1/log-level", Scope: models.ScopeRead,
+			Summary:  "Current log level",
+			Response: models.LogLevel{}, handler: s.getLogLevel},
+		{Method: "PUT", Path: "/api/v1/log-level", Scope: models.ScopeAdmin,
+			Summary: "Change the log level until the app restarts, e.g. to debug while reproducing an incident",
+			Request: models.LogLevel{}, Response: models.LogLevel{}, handler: s.putLogLevel},
+		{Method: "GET", Path: "/ws", Scope: models.ScopeRead,
+			Summary: "WebSocket stream of result and alert events. Browsers may pass the token in the access_token query parameter.",
+			handler: s.getStream},
+		{Method: "GET", Path: "/status.json",
+			Summary:  "Public availability per region, without endpoint details. Rate limited, no token needed.",
+			Response: models.PublicStatus{}, handler: s.getPublicStatus},
+		{Method: "GET", Path: "/api/v1/openapi.json",
+			Summary: "This document", handler: s.getOpenAPI},
+	}
+}
+
+// Handler returns the API routes, served under the configured base path
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, rt := range s.routes() {
+		s.handle(mux, rt.Method+" "+rt.Path, rt.Scope, rt.handler)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		origins, base := s.settings.CORSOrigins, s.settings.BasePath
+		s.mu.Unlock()
+
+		// Preflight requests carry no credentials, answer them before auth
+		if cors(w, r, origins) {
+			return
+		}
+		path, ok := stripBasePath(r.URL.Path, base)
+		if !ok {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path, r2.URL.RawPath = path, ""
+		mux.ServeHTTP(w, r2)
+	})
+}
+
+// callerKey holds the caller of authenticated requests in their context
+type callerKey struct{}
+
+type caller struct {
+	token  string // Token name
+	client string
+}
+
+// handle registers a route that needs a token with the given scope, or no
+// token at all without one
+func (s *Server) handle(mux *http.ServeMux, pattern, scope string, h http.HandlerFunc) {
+	if scope == "" {
+		mux.HandleFunc(pattern, h)
+		return
+	}
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		tokens, proxies := s.settings.Tokens, s.proxies
+		s.mu.Unlock()
+		client := clientIP(r, proxies)
+
+		token, ok := authenticate(r, tokens)
+		if !ok {
+			log.Ctx(s.Ctx).Warn().Str("client", client).Str("path", r.URL.Path).Msg("Unauthenticated API request")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="netmonitor"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		if !allows(token.Scope, scope) {
+			writeError(w, http.StatusForbidden, "token scope does not allow this request")
+			return
+		}
+		log.Ctx(s.Ctx).Debug().Str("token", token.Name).Str("client", client).Str("method", r.Method).Str("path", r.URL.Path).Msg("API request")
+		h(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, caller{token: token.Name, client: client})))
+	})
+}
+
+func (s *Server) getStates(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Backend.States())
+}
+
+func (s *Server) getServices(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Backend.Services())
+}
+
+func (s *Server) getRegionMap(w http.ResponseWriter, r *http.Request) {
+	window, ok := queryWindow(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Backend.RegionMap(window))
+}
+
+// getResults serves the results of the period given by the since parameter,
+// a Go duration such as 30m or 24h
+func (s *Server) getResults(w http.ResponseWriter, r *http.Request) {
+	since := DefaultResultsRange
+	if v := r.URL.Query().Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid since duration")
+			return
+		}
+		since = d
+	}
+	end := time.Now()
+	writeJSON(w, http.StatusOK, s.Backend.Results(end.Add(-since), end))
+}
+
+// getTrends serves latency trends over the number of days in the days
+// parameter
+func (s *Server) getTrends(w http.ResponseWriter, r *http.Request) {
+	days := 0
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 366 {
+			writeError(w, http.StatusBadRequest, "invalid days")
+			return
+		}
+		days = n
+	}
+	writeJSON(w, http.StatusOK, s.Backend.Trends(days))
+}
+
+func (s *Server) getEndpointSummary(w http.ResponseWriter, r *http.Request) {
+	window, ok := queryWindow(w, r)
+	if !ok {
+		return
+	}
+	summary, err := s.Backend.EndpointSummary(r.PathValue("id"), window)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+func (s *Server) getLatencyDistribution(w http.ResponseWriter, r *http.Request) {
+	window, ok := queryWindow(w, r)
+	if !ok {
+		return
+	}
+	var bucketMs float64
+	if v := r.URL.Query().Get("bucket_ms"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+			writeError(w, http.StatusBadRequest, "invalid bucket_ms")
+			return
+		}
+		bucketMs = f
+	}
+	dist, err := s.Backend.LatencyDistribution(r.PathValue("id"), window, bucketMs)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, dist)
+}
+
+// queryWindow returns the window query parameter, "day" by default, or
+// responds with an error if it isn't one of models.SummaryWindows
+func queryWindow(w http.ResponseWriter, r *http.Request) (string, bool) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "day"
+	}
+	if !slices.Contains(models.SummaryWindows, window) {
+		writeError(w, http.StatusBadRequest, "invalid window")
+		return "", false
+	}
+	return window, true
+}
+
+// getConfig serves the config without secrets
+func (s *Server) getConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, config.Redact(s.Backend.Config()))
+}
+
+func (s *Server) putConfig(w http.ResponseWriter, r *http.Request) {
+	var cfg models.Configuration
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&cfg); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid config: "+err.Error())
+		return
+	}
+	if err := s.Backend.SaveConfig(cfg); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, config.Redact(s.Backend.Config()))
+}
+
+func (s *Server) getAlerts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Backend.Alerts())
+}
+
+func (s *Server) getAlertRules(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Backend.AlertRules())
+}
+
+func (s *Server) putAlertRule(w http.ResponseWriter, r *http.Request) {
+	var rule models.AlertRule
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&rule); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid rule: "+err.Error())
+		return
+	}
+	rule.Name = r.PathValue("name")
+	if err := s.Backend.SaveAlertRule(rule); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (s *Server) deleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	err := s.Backend.DeleteAlertRule(r.PathValue("name"))
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, "rule not found")
+	case err != nil:
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) getExports(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Backend.Exports())
+}
+
+func (s *Server) postExport(w http.ResponseWriter, r *http.Request) {
+	var req models.ExportRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid export request: "+err.Error())
+		return
+	}
+	// Clients can't choose where files are written on this machine
+	req.Destination = ""
+	c, _ := r.Context().Value(callerKey{}).(caller)
+	req.Origin = &models.ExportOrigin{Interface: models.OriginAPI, Actor: c.token, Client: c.client}
+	status, err := s.Backend.CreateExport(req)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (s *Server) getExportAudit(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.Backend.ExportAudit()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) getExport(w http.ResponseWriter, r *http.Request) {
+	status, err := s.Backend.ExportStatus(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) getLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, models.LogLevel{Level: s.Backend.LogLevel()})
+}
+
+func (s *Server) putLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req models.LogLevel
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid log level: "+err.Error())
+		return
+	}
+	if err := s.Backend.SetLogLevel(req.Level); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, models.LogLevel{Level: s.Backend.LogLevel()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
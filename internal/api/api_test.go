@@ -0,0 +1,413 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+type fakeBackend struct {
+	cfg      models.Configuration
+	logLevel string
+	rules    []models.AlertRule
+}
+
+func (f *fakeBackend) Config() models.Configuration { return f.cfg }
+
+func (f *fakeBackend) SaveConfig(cfg models.Configuration) error {
+	if cfg.Settings.TestIntervalSeconds < 1 {
+		return errors.New("invalid interval")
+	}
+	f.cfg = cfg
+	return nil
+}
+
+func (f *fakeBackend) States() map[string]models.EndpointState {
+	return map[string]models.EndpointState{"a": {EndpointID: "a", Up: true}}
+}
+
+func (f *fakeBackend) Results(start, end time.Time) []models.TestResult {
+	return []models.TestResult{{Ts: end.UnixMilli(), Id: "a"}}
+}
+
+func (f *fakeBackend) Trends(days int) []models.LatencyTrend {
+	return []models.LatencyTrend{{EndpointID: "a", Days: days}}
+}
+
+func (f *fakeBackend) EndpointSummary(id, window string) (models.EndpointSummary, error) {
+	if id != "a" {
+		return models.EndpointSummary{}, errors.New("unknown endpoint")
+	}
+	return models.EndpointSummary{EndpointID: id, Window: window}, nil
+}
+
+func (f *fakeBackend) LatencyDistribution(id, window string, bucketMs float64) (models.LatencyDistribution, error) {
+	if id != "a" {
+		return models.LatencyDistribution{}, errors.New("unknown endpoint")
+	}
+	return models.LatencyDistribution{EndpointID: id, Window: window, BucketMs: bucketMs}, nil
+}
+
+func (f *fakeBackend) Services() []models.ServiceStatus {
+	return []models.ServiceStatus{}
+}
+
+func (f *fakeBackend) RegionMap(window string) []models.RegionMapEntry {
+	return []models.RegionMapEntry{{Name: "Default", Status: models.RegionUnknown}}
+}
+
+func (f *fakeBackend) Alerts() []models.Alert {
+	return []models.Alert{}
+}
+
+func (f *fakeBackend) AlertRules() []models.AlertRule { return f.rules }
+
+func (f *fakeBackend) SaveAlertRule(rule models.AlertRule) error {
+	if rule.Condition != models.RuleP95Latency {
+		return errors.New("unknown condition")
+	}
+	f.rules = append(f.rules, rule)
+	return nil
+}
+
+func (f *fakeBackend) DeleteAlertRule(name string) error {
+	if len(f.rules) == 0 || f.rules[0].Name != name {
+		return ErrNotFound
+	}
+	f.rules = f.rules[1:]
+	return nil
+}
+
+func (f *fakeBackend) Exports() []models.ExportStatus {
+	return []models.ExportStatus{{ID: "e1", State: models.ExportCompleted}}
+}
+
+func (f *fakeBackend) CreateExport(req models.ExportRequest) (models.ExportStatus, error) {
+	if req.Destination != "" {
+		return models.ExportStatus{}, errors.New("destination not allowed")
+	}
+	if req.Origin == nil || req.Origin.Interface != models.OriginAPI || req.Origin.Actor != "ops" || req.Origin.Client == "" {
+		return models.ExportStatus{}, errors.New("origin not set by the API")
+	}
+	return models.ExportStatus{ID: "e2", State: models.ExportQueued}, nil
+}
+
+func (f *fakeBackend) ExportAudit() ([]models.ExportAuditEntry, error) {
+	return []models.ExportAuditEntry{{ExportID: "e1", State: models.ExportCompleted}}, nil
+}
+
+func (f *fakeBackend) ExportStatus(id string) (models.ExportStatus, error) {
+	if id != "e1" {
+		return models.ExportStatus{}, errors.New("export not found")
+	}
+	return models.ExportStatus{ID: id, State: models.ExportCompleted}, nil
+}
+
+func (f *fakeBackend) PublicStatus() models.PublicStatus {
+	return models.PublicStatus{Regions: []models.RegionStatus{{Name: "Default", Status: models.RegionOperational, AvailabilityPercent: 100}}}
+}
+
+func (f *fakeBackend) LogLevel() string { return f.logLevel }
+
+func (f *fakeBackend) SetLogLevel(level string) error {
+	if level != "debug" && level != "info" {
+		return errors.New("unknown log level")
+	}
+	f.logLevel = level
+	return nil
+}
+
+func TestTokenScopes(t *testing.T) {
+	readToken, read, err := NewToken("dashboard", models.ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminToken, admin, err := NewToken("ops", models.ScopeAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(read.Hash, readToken) || !strings.HasPrefix(readToken, tokenPrefix) {
+		t.Fatalf("Unexpected token %q with hash %q", readToken, read.Hash)
+	}
+
+	backend := &fakeBackend{cfg: models.Configuration{Settings: models.AppSettings{
+		TestIntervalSeconds: 60,
+		Sync:                &models.SyncSettings{Target: "/tmp/x", Password: "secret"},
+	}}}
+	s := New(context.Background(), backend)
+	s.settings = models.APISettings{Tokens: []models.APIToken{read, admin}}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	do := func(method, path, token, body string) int {
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	steps := []struct {
+		method, path, token, body string
+		status                    int
+	}{
+		{"GET", "/api/v1/states", "", "", http.StatusUnauthorized},
+		{"GET", "/api/v1/states", "nm_wrong", "", http.StatusUnauthorized},
+		{"GET", "/api/v1/states", readToken, "", http.StatusOK},
+		{"GET", "/api/v1/results?since=30m", readToken, "", http.StatusOK},
+		{"GET", "/api/v1/results?since=bogus", readToken, "", http.StatusBadRequest},
+		{"GET", "/api/v1/services", readToken, "", http.StatusOK},
+		{"GET", "/api/v1/regions/map?window=week", readToken, "", http.StatusOK},
+		{"GET", "/api/v1/regions/map?window=year", readToken, "", http.StatusBadRequest},
+		{"GET", "/api/v1/trends?days=14", readToken, "", http.StatusOK},
+		{"GET", "/api/v1/trends?days=0", readToken, "", http.StatusBadRequest},
+		{"GET", "/api/v1/endpoints/a/summary?window=week", readToken, "", http.StatusOK},
+		{"GET", "/api/v1/endpoints/a/summary?window=year", readToken, "", http.StatusBadRequest},
+		{"GET", "/api/v1/endpoints/b/summary", readToken, "", http.StatusNotFound},
+		{"GET", "/api/v1/endpoints/a/latency?window=1h&bucket_ms=0.5", readToken, "", http.StatusOK},
+		{"GET", "/api/v1/endpoints/a/latency?bucket_ms=-1", readToken, "", http.StatusBadRequest},
+		{"GET", "/api/v1/endpoints/b/latency", readToken, "", http.StatusNotFound},
+		{"GET", "/api/v1/config", readToken, "", http.StatusOK},
+		{"PUT", "/api/v1/config", readToken, `{"settings":{"test_interval_seconds":30}}`, http.StatusForbidden},
+		{"PUT", "/api/v1/config", adminToken, `{"settings":{"test_interval_seconds":0}}`, http.StatusUnprocessableEntity},
+		{"PUT", "/api/v1/config", adminToken, `{"settings":{"test_interval_seconds":30}}`, http.StatusOK},
+		{"GET", "/api/v1/alerts", readToken, "", http.StatusOK},
+		{"GET", "/api/v1/alert-rules", readToken, "", http.StatusOK},
+		{"PUT", "/api/v1/alert-rules/slow", readToken, `{"condition":"p95_latency","threshold":50}`, http.StatusForbidden},
+		{"PUT", "/api/v1/alert-rules/slow", adminToken, `{"condition":"jitter","threshold":50}`, http.StatusUnprocessableEntity},
+		{"PUT", "/api/v1/alert-rules/slow", adminToken, `{"condition":"p95_latency","threshold":50}`, http.StatusOK},
+		{"DELETE", "/api/v1/alert-rules/fast", adminToken, "", http.StatusNotFound},
+		{"DELETE", "/api/v1/alert-rules/slow", adminToken, "", http.StatusNoContent},
+		{"GET", "/api/v1/exports", readToken, "", http.StatusOK},
+		{"GET", "/api/v1/exports/e1", readToken, "", http.StatusOK},
+		{"GET", "/api/v1/exports/nope", readToken, "", http.StatusNotFound},
+		{"POST", "/api/v1/exports", readToken, `{"format":"csv"}`, http.StatusForbidden},
+		{"POST", "/api/v1/exports", adminToken, `{"format":"csv","destination":"/etc"}`, http.StatusAccepted},
+		{"POST", "/api/v1/exports", adminToken, `{"format":"csv","origin":{"interface":"app","actor":"someone"}}`, http.StatusAccepted},
+		{"GET", "/api/v1/exports/audit", readToken, "", http.StatusForbidden},
+		{"GET", "/api/v1/exports/audit", adminToken, "", http.StatusOK},
+		{"GET", "/api/v1/log-level", readToken, "", http.StatusOK},
+		{"PUT", "/api/v1/log-level", readToken, `{"level":"debug"}`, http.StatusForbidden},
+		{"PUT", "/api/v1/log-level", adminToken, `{"level":"verbose"}`, http.StatusUnprocessableEntity},
+		{"PUT", "/api/v1/log-level", adminToken, `{"level":"debug"}`, http.StatusOK},
+		{"GET", "/api/v1/openapi.json", "", "", http.StatusOK},
+	}
+	for _, step := range steps {
+		if status := do(step.method, step.path, step.token, step.body); status != step.status {
+			t.Errorf("%s %s: expected %d, got %d", step.method, step.path, step.status, status)
+		}
+	}
+	if backend.cfg.Settings.TestIntervalSeconds != 30 {
+		t.Errorf("Expected config saved by admin token, got %+v", backend.cfg.Settings)
+	}
+	if backend.logLevel != "debug" {
+		t.Errorf("Expected log level set by admin token, got %q", backend.logLevel)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	s := New(context.Background(), &fakeBackend{})
+	if err := s.Configure(&models.APISettings{Enabled: true, Listen: "127.0.0.1:0", ClientCA: "ca.pem"}); err == nil {
+		t.Errorf("Expected error for client CA without TLS")
+	}
+	if err := s.Configure(&models.APISettings{Enabled: true, Listen: "127.0.0.1:0"}); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	addr := s.Addr()
+	if addr == nil {
+		t.Fatal("Expected API to listen")
+	}
+
+	// Token changes don't restart the listener
+	_, token, _ := NewToken("t", models.ScopeRead)
+	if err := s.Configure(&models.APISettings{Enabled: true, Listen: "127.0.0.1:0", Tokens: []models.APIToken{token}}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Addr().String() != addr.String() {
+		t.Errorf("Expected listener kept at %s, got %s", addr, s.Addr())
+	}
+
+	if err := s.Configure(&models.APISettings{Enabled: false}); err != nil || s.Addr() != nil {
+		t.Errorf("Expected API stopped, err %v", err)
+	}
+}
+
+func TestBasePathAndCORS(t *testing.T) {
+	token, entry, _ := NewToken("t", models.ScopeRead)
+	s := New(context.Background(), &fakeBackend{})
+	s.settings = models.APISettings{
+		Tokens:      []models.APIToken{entry},
+		BasePath:    normalizeBasePath("netmonitor/"),
+		CORSOrigins: []string{"https://grafana.example.com"},
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	get := func(method, path, origin string) *http.Response {
+		req, _ := http.NewRequest(method, ts.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+			req.Header.Set("Access-Control-Request-Method", "GET")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := get("GET", "/netmonitor/api/v1/states", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 under base path, got %d", resp.StatusCode)
+	}
+	for _, path := range []string{"/api/v1/states", "/netmonitorx/api/v1/states"} {
+		if resp := get("GET", path, ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected 404 outside base path, got %d", path, resp.StatusCode)
+		}
+	}
+
+	resp := get("OPTIONS", "/netmonitor/api/v1/states", "https://grafana.example.com")
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get("Access-Control-Allow-Origin") != "https://grafana.example.com" {
+		t.Errorf("Unexpected preflight response: %d %v", resp.StatusCode, resp.Header)
+	}
+	resp = get("OPTIONS", "/netmonitor/api/v1/states", "https://evil.example.com")
+	if resp.Header.Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers for unknown origin")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		remote, xff, realIP, want string
+	}{
+		{"203.0.113.5:1234", "198.51.100.1", "", "203.0.113.5"},         // Untrusted peer, header ignored
+		{"10.0.0.2:1234", "198.51.100.1, 10.0.0.3", "", "198.51.100.1"}, // Proxy chain
+		{"10.0.0.2:1234", "1.2.3.4, 198.51.100.1", "", "198.51.100.1"},  // Spoofed leftmost entry ignored
+		{"192.168.1.1:1234", "", "198.51.100.7", "198.51.100.7"},        // X-Real-IP
+		{"10.0.0.2:1234", "", "", "10.0.0.2"},                           // No headers
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = c.remote
+		if c.xff != "" {
+			r.Header.Set("X-Forwarded-For", c.xff)
+		}
+		if c.realIP != "" {
+			r.Header.Set("X-Real-IP", c.realIP)
+		}
+		if got := clientIP(r, proxies); got != c.want {
+			t.Errorf("%+v: got %s", c, got)
+		}
+	}
+
+	if err := ValidateSettings(models.APISettings{TrustedProxies: []string{"nope"}}); err == nil {
+		t.Errorf("Expected error for invalid proxy")
+	}
+	if err := ValidateSettings(models.APISettings{CORSOrigins: []string{"grafana.example.com"}}); err == nil {
+		t.Errorf("Expected error for origin without scheme")
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	s := New(context.Background(), &fakeBackend{})
+	s.Version = "1.2.3"
+	doc := OpenAPI(s.routes(), s.Version, "/netmonitor")
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.OpenAPI != "3.0.3" {
+		t.Errorf("Unexpected version: %s", parsed.OpenAPI)
+	}
+	for _, rt := range s.routes() {
+		if _, ok := parsed.Paths[rt.Path][strings.ToLower(rt.Method)]; !ok {
+			t.Errorf("Route %s %s missing from document", rt.Method, rt.Path)
+		}
+	}
+	for _, name := range []string{"TestResult", "EndpointState", "Configuration", "ExportRequest", "ExportStatus", "Endpoint"} {
+		if _, ok := parsed.Components.Schemas[name]; !ok {
+			t.Errorf("Schema %s missing", name)
+		}
+	}
+	if _, ok := parsed.Components.Schemas["TestResult"].Properties["ts"]; !ok {
+		t.Errorf("Expected TestResult properties named after json tags")
+	}
+	if id := operationID(route{Method: "GET", Path: "/api/v1/exports/{id}"}); id != "getApiV1ExportsId" {
+		t.Errorf("Unexpected operation ID: %s", id)
+	}
+}
+
+func TestPublicStatusRateLimit(t *testing.T) {
+	s := New(context.Background(), &fakeBackend{})
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	limited := false
+	for i := 0; i < statusClientBurst+1; i++ {
+		resp, err := http.Get(ts.URL + "/status.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			limited = true
+			resp.Body.Close()
+			break
+		}
+		var status models.PublicStatus
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if len(status.Regions) != 1 || status.Regions[0].Status != models.RegionOperational {
+			t.Errorf("Unexpected status: %+v", status)
+		}
+	}
+	if !limited {
+		t.Errorf("Expected requests past the burst to be rate limited")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(60, 2)
+	now := time.Now()
+	if !l.allow("a", now) || !l.allow("a", now) || l.allow("a", now) {
+		t.Errorf("Expected a burst of 2")
+	}
+	if !l.allow("b", now) {
+		t.Errorf("Expected separate buckets per key")
+	}
+	if !l.allow("a", now.Add(time.Second)) {
+		t.Errorf("Expected a token back after a second")
+	}
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// tokenPrefix makes tokens easy to recognize, e.g. in leaked secrets scans
+const tokenPrefix = "nm_"
+
+// NewToken generates a token and the config entry that grants it the scope.
+// The token itself is not stored anywhere and can't be recovered.
+func NewToken(name, scope string) (string, models.APIToken, error) {
+	if name == "" {
+		return "", models.APIToken{}, errors.New("token name is required")
+	}
+	if !validScope(scope) {
+		return "", models.APIToken{}, fmt.Errorf("unknown scope: %s", scope)
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", models.APIToken{}, err
+	}
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return token, models.APIToken{
+		Name:      name,
+		Scope:     scope,
+		Hash:      hashToken(token),
+		CreatedAt: time.Now().UnixMilli(),
+	}, nil
+}
+
+// ValidateSettings checks API settings before they are saved
+func ValidateSettings(s models.APISettings) error {
+	if (s.TLSCert == "") != (s.TLSKey == "") {
+		return errors.New("tls_cert and tls_key must be set together")
+	}
+	if s.ClientCA != "" && s.TLSCert == "" {
+		return errors.New("client_ca requires tls_cert and tls_key")
+	}
+	for _, o := range s.CORSOrigins {
+		if err := validateOrigin(o); err != nil {
+			return err
+		}
+	}
+	if _, err := parseProxies(s.TrustedProxies); err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, t := range s.Tokens {
+		if t.Name == "" || t.Hash == "" {
+			return errors.New("tokens need a name and a hash")
+		}
+		if names[t.Name] {
+			return fmt.Errorf("duplicate token name: %s", t.Name)
+		}
+		names[t.Name] = true
+		if !validScope(t.Scope) {
+			return fmt.Errorf("token %s: unknown scope: %s", t.Name, t.Scope)
+		}
+	}
+	return nil
+}
+
+func validScope(scope string) bool {
+	return scope == models.ScopeRead || scope == models.ScopeAdmin
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticate returns the token the request's bearer token matches.
+// Browsers can't set headers on WebSocket requests, so upgrades may pass the
+// token in the access_token query parameter instead.
+func authenticate(r *http.Request, tokens []models.APIToken) (models.APIToken, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		return models.APIToken{}, false
+	}
+	hash := []byte(hashToken(token))
+	var match models.APIToken
+	found := false
+	// Compare against every token so timing doesn't tell how many matched
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(t.Hash)) == 1 {
+			match, found = t, true
+		}
+	}
+	return match, found
+}
+
+// allows reports whether a token with the scope may perform a request
+// needing the required scope
+func allows(scope, required string) bool {
+	return scope == models.ScopeAdmin || scope == required
+}
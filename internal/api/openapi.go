@@ -0,0 +1,189 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The OpenAPI 3 document is generated from the route table and the Go types
+// of request and response bodies, so it can't drift from what is served.
+
+// pathParam matches {name} segments of route paths
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+func (s *Server) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	base := s.settings.BasePath
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, OpenAPI(s.routes(), s.Version, base))
+}
+
+// OpenAPI returns the OpenAPI 3 document describing the routes
+func OpenAPI(routes []route, version, basePath string) map[string]any {
+	g := &schemaGen{components: make(map[string]any)}
+	paths := make(map[string]map[string]any)
+
+	for _, rt := range routes {
+		op := map[string]any{
+			"summary":     rt.Summary,
+			"operationId": operationID(rt),
+		}
+		var params []any
+		for _, p := range rt.Params {
+			params = append(params, map[string]any{
+				"name":        p.Name,
+				"in":          p.In,
+				"required":    p.In == "path",
+				"description": p.Description,
+				"schema":      map[string]any{"type": "string"},
+			})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+		if rt.Request != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(rt.Request))}},
+			}
+		}
+
+		responses := map[string]any{}
+		switch {
+		case rt.Path == "/ws":
+			responses["101"] = map[string]any{"description": "Switching to the WebSocket protocol"}
+		case rt.Response != nil:
+			status := "200"
+			if rt.Method == "POST" {
+				status = "202"
+			}
+			responses[status] = map[string]any{
+				"description": "OK",
+				"content":     map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(rt.Response))}},
+			}
+		case rt.Method == "DELETE":
+			responses["204"] = map[string]any{"description": "Deleted"}
+		default:
+			responses["200"] = map[string]any{"description": "OK"}
+		}
+		errRef := map[string]any{"$ref": "#/components/responses/Error"}
+		if rt.Request != nil || rt.Params != nil {
+			responses["400"] = errRef
+		}
+		if rt.Scope != "" {
+			responses["401"] = errRef
+			responses["403"] = errRef
+			op["security"] = []any{map[string]any{"bearer": []string{rt.Scope}}}
+		}
+		op["responses"] = responses
+
+		if paths[rt.Path] == nil {
+			paths[rt.Path] = make(map[string]any)
+		}
+		paths[rt.Path][strings.ToLower(rt.Method)] = op
+	}
+
+	doc := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "NetMonitor API",
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": g.components,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer", "description": "API token. Tokens have the read or admin scope."},
+			},
+			"responses": map[string]any{
+				"Error": map[string]any{
+					"description": "Error",
+					"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{
+						"type":       "object",
+						"properties": map[string]any{"error": map[string]any{"type": "string"}},
+					}}},
+				},
+			},
+		},
+	}
+	if basePath != "" {
+		doc["servers"] = []any{map[string]any{"url": basePath}}
+	}
+	return doc
+}
+
+// operationID derives an ID such as getApiV1ExportsId from the route
+func operationID(rt route) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(rt.Method))
+	for _, part := range strings.FieldsFunc(rt.Path, func(r rune) bool { return r == '/' || r == '{' || r == '}' || r == '.' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// schemaGen converts Go types to JSON schemas, collecting named structs as
+// components
+type schemaGen struct {
+	components map[string]any
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, ok := g.components[t.Name()]; !ok {
+			g.components[t.Name()] = nil // Reserve the name for recursive types
+			g.components[t.Name()] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+	}
+	return map[string]any{"type": "object", "properties": props}
+}
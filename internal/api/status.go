@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// /status.json is public, so it is rate limited per client and overall, and
+// served from a cache so a burst of requests costs one computation.
+const (
+	statusClientPerMinute = 6
+	statusClientBurst     = 3
+	statusGlobalPerMinute = 120
+	statusGlobalBurst     = 20
+	statusCacheTTL        = 15 * time.Second
+)
+
+// statusCache holds the last public status computed
+type statusCache struct {
+	mu      sync.Mutex
+	status  models.PublicStatus
+	expires time.Time
+
+	clients *limiter
+	global  *limiter
+}
+
+func newStatusCache() *statusCache {
+	return &statusCache{
+		clients: newLimiter(statusClientPerMinute, statusClientBurst),
+		global:  newLimiter(statusGlobalPerMinute, statusGlobalBurst),
+	}
+}
+
+func (c *statusCache) get(now time.Time, compute func() models.PublicStatus) models.PublicStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.After(c.expires) {
+		c.status = compute()
+		c.expires = now.Add(statusCacheTTL)
+	}
+	return c.status
+}
+
+func (s *Server) getPublicStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	proxies := s.proxies
+	s.mu.Unlock()
+
+	now := time.Now()
+	client := clientIP(r, proxies)
+	if !s.status.clients.allow(client, now) || !s.status.global.allow("", now) {
+		log.Ctx(s.Ctx).Debug().Str("client", client).Msg("Public status request rate limited")
+		w.Header().Set("Retry-After", strconv.Itoa(60/statusClientPerMinute))
+		writeError(w, http.StatusTooManyRequests, "too many requests")
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(statusCacheTTL.Seconds())))
+	writeJSON(w, http.StatusOK, s.status.get(now, s.Backend.PublicStatus))
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// /ws streams events to subscribers as JSON text messages over a WebSocket
+// (RFC 6455). Only what the stream needs is implemented: the server sends
+// unfragmented text frames and answers pings and close frames from clients.
+
+// Event types sent on the stream
+const (
+	EventResult  = "result"  // Data is a models.TestResult
+	EventAlert   = "alert"   // Data is an AlertEvent
+	EventService = "service" // Data is a models.ServiceStatus whose status changed
+)
+
+// Event is one stream message
+type Event struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// AlertEvent reports an endpoint going down or recovering, or its
+// certificate expiring soon
+type AlertEvent struct {
+	Event    models.HookEvent     `json:"event"`
+	Endpoint models.Endpoint      `json:"endpoint"`
+	State    models.EndpointState `json:"state"`
+}
+
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+
+	// wsBuffer is how many events a subscriber may fall behind before it is
+	// disconnected
+	wsBuffer       = 256
+	wsPingInterval = 30 * time.Second
+	wsWriteTimeout = 10 * time.Second
+	// wsMaxClientFrame bounds the frames read from clients, which only send
+	// control frames
+	wsMaxClientFrame = 4096
+)
+
+// Publish sends an event to every stream subscriber. Subscribers too slow to
+// keep up are disconnected rather than slowing the caller down.
+func (s *Server) Publish(eventType string, data any) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	if len(s.subs) == 0 {
+		return
+	}
+	msg, err := json.Marshal(Event{Type: eventType, Data: data})
+	if err != nil {
+		log.Ctx(s.Ctx).Error().Err(err).Str("type", eventType).Msg("Failed to encode stream event")
+		return
+	}
+	for sub := range s.subs {
+		select {
+		case sub <- msg:
+		default:
+			log.Ctx(s.Ctx).Warn().Msg("Stream subscriber fell behind, disconnecting")
+			delete(s.subs, sub)
+			close(sub)
+		}
+	}
+}
+
+func (s *Server) subscribe() chan []byte {
+	sub := make(chan []byte, wsBuffer)
+	s.subsMu.Lock()
+	if s.subs == nil {
+		s.subs = make(map[chan []byte]struct{})
+	}
+	s.subs[sub] = struct{}{}
+	s.subsMu.Unlock()
+	return sub
+}
+
+func (s *Server) unsubscribe(sub chan []byte) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	if _, ok := s.subs[sub]; ok {
+		delete(s.subs, sub)
+		close(sub)
+	}
+}
+
+// closeStreams disconnects every subscriber. Hijacked connections aren't
+// closed by http.Server.Shutdown.
+func (s *Server) closeStreams() {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	for sub := range s.subs {
+		delete(s.subs, sub)
+		close(sub)
+	}
+}
+
+func (s *Server) getStream(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		writeError(w, http.StatusBadRequest, "websocket upgrade required")
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeError(w, http.StatusUpgradeRequired, "unsupported websocket version")
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "websocket not supported")
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		log.Ctx(s.Ctx).Error().Err(err).Msg("Failed to hijack stream connection")
+		return
+	}
+	defer conn.Close()
+
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	sub := s.subscribe()
+	defer s.unsubscribe(sub)
+	log.Ctx(s.Ctx).Info().Str("remote", r.RemoteAddr).Msg("Stream subscriber connected")
+
+	// The reader hands control frames that need an answer to the writer,
+	// which owns the connection's write side
+	replies := make(chan wsFrame, 1)
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		readFrames(rw.Reader, replies)
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case msg, ok := <-sub:
+			if !ok {
+				_ = writeFrame(conn, wsOpClose, closePayload(1001))
+				return
+			}
+			err = writeFrame(conn, wsOpText, msg)
+		case f := <-replies:
+			err = writeFrame(conn, f.op, f.payload)
+			if f.op == wsOpClose {
+				return
+			}
+		case <-ping.C:
+			err = writeFrame(conn, wsOpPing, nil)
+		case <-readDone:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+type wsFrame struct {
+	op      byte
+	payload []byte
+}
+
+// readFrames reads client frames until the connection fails or the client
+// closes it, queueing pongs and the close reply
+func readFrames(r *bufio.Reader, replies chan<- wsFrame) {
+	for {
+		op, payload, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		switch op {
+		case wsOpPing:
+			select {
+			case replies <- wsFrame{wsOpPong, payload}:
+			default: // A pong is already pending
+			}
+		case wsOpClose:
+			replies <- wsFrame{wsOpClose, closePayload(1000)}
+			return
+		}
+	}
+}
+
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	op := hdr[0] & 0x0F
+	masked := hdr[1]&0x80 != 0
+	n := uint64(hdr[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if !masked {
+		return 0, nil, errors.New("client frames must be masked")
+	}
+	if n > wsMaxClientFrame {
+		return 0, nil, errors.New("client frame too large")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return op, payload, nil
+}
+
+// writeFrame writes an unmasked, unfragmented server frame
+func writeFrame(conn net.Conn, op byte, payload []byte) error {
+	hdr := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = append(hdr, byte(n))
+	case n <= 0xFFFF:
+		hdr = append(hdr, 126, byte(n>>8), byte(n))
+	default:
+		hdr = append(hdr, 127)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	_, err := (&net.Buffers{hdr, payload}).WriteTo(conn)
+	return err
+}
+
+func closePayload(code uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, code)
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestStream(t *testing.T) {
+	token, entry, _ := NewToken("t", models.ScopeRead)
+	s := New(context.Background(), &fakeBackend{})
+	s.settings = models.APISettings{Tokens: []models.APIToken{entry}}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	req := "GET /ws?access_token=" + token + " HTTP/1.1\r\nHost: x\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Unexpected handshake response: %d %v", resp.StatusCode, resp.Header)
+	}
+
+	// Wait for the subscription before publishing
+	for i := 0; i < 100; i++ {
+		s.subsMu.Lock()
+		n := len(s.subs)
+		s.subsMu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Publish(EventResult, models.TestResult{Id: "abc", Ms: 12})
+
+	op, payload := readServerFrame(t, r)
+	if op != wsOpText {
+		t.Fatalf("Expected text frame, got opcode %d", op)
+	}
+	var ev struct {
+		Type string            `json:"type"`
+		Data models.TestResult `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != EventResult || ev.Data.Id != "abc" || ev.Data.Ms != 12 {
+		t.Errorf("Unexpected event: %+v", ev)
+	}
+
+	// A masked close frame from the client is answered with a close frame
+	mask := []byte{1, 2, 3, 4}
+	body := []byte{0x03, 0xE8}
+	frame := []byte{0x80 | wsOpClose, 0x80 | byte(len(body))}
+	frame = append(frame, mask...)
+	for i, b := range body {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	if op, _ := readServerFrame(t, r); op != wsOpClose {
+		t.Errorf("Expected close frame, got opcode %d", op)
+	}
+
+	// Plain requests without a token are rejected
+	res, err := http.Get(ts.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", res.StatusCode)
+	}
+}
+
+func readServerFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		t.Fatal(err)
+	}
+	n := int(hdr[1] & 0x7F)
+	if n == 126 {
+		ext := make([]byte, 2)
+		io.ReadFull(r, ext)
+		n = int(ext[0])<<8 | int(ext[1])
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return hdr[0] & 0x0F, payload
+}
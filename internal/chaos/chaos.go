@@ -0,0 +1,173 @@
+// Package chaos injects failures, timeouts and latency spikes into test
+// results during development, so alerting, flap detection and notifications
+// can be demonstrated end-to-end without breaking a real network.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+	"github.com/rs/zerolog/log"
+)
+
+// TagInjected is added to every result changed by the injector, with the
+// kind of fault as its value
+const TagInjected = "chaos"
+
+// Fault kinds, the values of TagInjected
+const (
+	FaultFailure = "failure"
+	FaultTimeout = "timeout"
+	FaultSpike   = "spike"
+)
+
+// ErrInjected is returned for injected failures and timeouts
+var ErrInjected = errors.New("chaos: injected fault")
+
+// Config holds the probability of each fault per test, between 0 and 1
+type Config struct {
+	FailureRate float64
+	TimeoutRate float64
+	SpikeRate   float64
+	// SpikeFactor multiplies the latency of spiked tests
+	SpikeFactor float64
+	// Match, if set, limits faults to endpoints whose name or address
+	// contains it
+	Match string
+}
+
+// Parse reads a config from a comma-separated spec such as
+// "failure=0.1,timeout=0.05,spike=0.2,factor=5,match=example.com"
+func Parse(spec string) (Config, error) {
+	cfg := Config{SpikeFactor: 10}
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return cfg, fmt.Errorf("expected key=value: %q", field)
+		}
+		if key == "match" {
+			cfg.Match = value
+			continue
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %w", key, err)
+		}
+		switch key {
+		case "failure":
+			cfg.FailureRate = f
+		case "timeout":
+			cfg.TimeoutRate = f
+		case "spike":
+			cfg.SpikeRate = f
+		case "factor":
+			cfg.SpikeFactor = f
+		default:
+			return cfg, fmt.Errorf("unknown key: %s", key)
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that rates are probabilities and spikes slow tests down
+func (c Config) Validate() error {
+	for name, rate := range map[string]float64{"failure": c.FailureRate, "timeout": c.TimeoutRate, "spike": c.SpikeRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s rate must be between 0 and 1", name)
+		}
+	}
+	if c.FailureRate+c.TimeoutRate > 1 {
+		return fmt.Errorf("failure and timeout rates add up to more than 1")
+	}
+	if c.SpikeFactor < 1 {
+		return fmt.Errorf("spike factor must be at least 1")
+	}
+	return nil
+}
+
+// Injector decides which tests get a fault
+type Injector struct {
+	Ctx    context.Context
+	Config Config
+
+	// Rand returns a number in [0, 1) and can be replaced in tests
+	Rand func() float64
+}
+
+func New(ctx context.Context, cfg Config) *Injector {
+	return &Injector{Ctx: ctx, Config: cfg, Rand: rand.Float64}
+}
+
+// Middleware injects faults into the tests it wraps. Failures and timeouts
+// replace the test, timeouts taking the endpoint's full timeout like a real
+// one; spikes are applied to the latency of successful tests.
+func (i *Injector) Middleware() network.Middleware {
+	return func(next network.RunFunc) network.RunFunc {
+		return func(ctx context.Context, ep models.Endpoint) (models.TestResult, error) {
+			if !i.matches(ep) {
+				return next(ctx, ep)
+			}
+
+			roll := i.Rand()
+			switch {
+			case roll < i.Config.FailureRate:
+				return i.inject(ep, FaultFailure, models.TestResult{
+					St: models.TestStatusError,
+					Ek: models.ErrorKindRefused,
+				}), ErrInjected
+			case roll < i.Config.FailureRate+i.Config.TimeoutRate:
+				timeout := time.Duration(ep.Timeout) * time.Millisecond
+				select {
+				case <-time.After(timeout):
+				case <-ctx.Done():
+					return models.TestResult{
+						Ts: time.Now().UnixMilli(),
+						Id: network.EndpointID(ep.Address, ep.Type),
+						St: models.TestStatusCancelled,
+					}, ctx.Err()
+				}
+				return i.inject(ep, FaultTimeout, models.TestResult{
+					Ms: timeout.Milliseconds(),
+					St: models.TestStatusTimeout,
+					Ek: models.ErrorKindTimeout,
+				}), ErrInjected
+			}
+
+			result, err := next(ctx, ep)
+			if result.St == models.TestStatusSuccess && i.Rand() < i.Config.SpikeRate {
+				result.Ms = int64(float64(max(result.Ms, 1)) * i.Config.SpikeFactor)
+				result.Us = int64(float64(result.Us) * i.Config.SpikeFactor)
+				tag(&result, FaultSpike)
+				log.Ctx(i.Ctx).Debug().Str("address", ep.Address).Int64("latency_ms", result.Ms).Msg("Chaos latency spike injected")
+			}
+			return result, err
+		}
+	}
+}
+
+func (i *Injector) matches(ep models.Endpoint) bool {
+	m := i.Config.Match
+	return m == "" || strings.Contains(ep.Name, m) || strings.Contains(ep.Address, m)
+}
+
+func (i *Injector) inject(ep models.Endpoint, fault string, result models.TestResult) models.TestResult {
+	result.Ts = time.Now().UnixMilli()
+	result.Id = network.EndpointID(ep.Address, ep.Type)
+	tag(&result, fault)
+	log.Ctx(i.Ctx).Debug().Str("address", ep.Address).Str("fault", fault).Msg("Chaos fault injected")
+	return result
+}
+
+func tag(result *models.TestResult, fault string) {
+	if result.Tags == nil {
+		result.Tags = make(map[string]string)
+	}
+	result.Tags[TagInjected] = fault
+}
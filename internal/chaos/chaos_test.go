@@ -0,0 +1,65 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse("failure=0.1, timeout=0.05,spike=0.2,factor=5,match=example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Config{FailureRate: 0.1, TimeoutRate: 0.05, SpikeRate: 0.2, SpikeFactor: 5, Match: "example.com"}
+	if cfg != want {
+		t.Errorf("Parse() = %+v, want %+v", cfg, want)
+	}
+
+	for _, spec := range []string{"failure", "failure=x", "loss=0.1", "failure=1.5", "failure=0.6,timeout=0.6", "factor=0.5"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) accepted", spec)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	ep := models.Endpoint{Name: "Test", Type: models.TypeTCP, Address: "example.com:443", Timeout: 10}
+	next := func(ctx context.Context, ep models.Endpoint) (models.TestResult, error) {
+		return models.TestResult{Ms: 20, St: models.TestStatusSuccess}, nil
+	}
+	inj := New(context.Background(), Config{FailureRate: 0.2, TimeoutRate: 0.2, SpikeRate: 0.5, SpikeFactor: 10})
+	run := func(rolls ...float64) (models.TestResult, error) {
+		inj.Rand = func() float64 {
+			r := rolls[0]
+			rolls = rolls[1:]
+			return r
+		}
+		return inj.Middleware()(next)(context.Background(), ep)
+	}
+
+	r, err := run(0.1)
+	if !errors.Is(err, ErrInjected) || r.St != models.TestStatusError || r.Tags[TagInjected] != FaultFailure {
+		t.Errorf("failure: %+v, %v", r, err)
+	}
+	r, err = run(0.3)
+	if !errors.Is(err, ErrInjected) || r.St != models.TestStatusTimeout || r.Ms != 10 || r.Tags[TagInjected] != FaultTimeout {
+		t.Errorf("timeout: %+v, %v", r, err)
+	}
+	r, err = run(0.5, 0.4)
+	if err != nil || r.Ms != 200 || r.Tags[TagInjected] != FaultSpike {
+		t.Errorf("spike: %+v, %v", r, err)
+	}
+	r, err = run(0.5, 0.6)
+	if err != nil || r.Ms != 20 || r.Tags != nil {
+		t.Errorf("untouched: %+v, %v", r, err)
+	}
+
+	inj.Config.Match = "other"
+	r, err = run()
+	if err != nil || r.Ms != 20 {
+		t.Errorf("unmatched endpoint: %+v, %v", r, err)
+	}
+}
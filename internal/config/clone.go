@@ -0,0 +1,129 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+// Errors of CloneEndpoint
+var (
+	ErrRegionNotFound    = errors.New("region not found")
+	ErrEndpointNotFound  = errors.New("endpoint not found")
+	ErrDuplicateEndpoint = errors.New("an endpoint already tests this address with this type")
+)
+
+// copySuffix matches the " (2)" CloneEndpoint appends to names
+var copySuffix = regexp.MustCompile(` \((\d+)\)$`)
+
+// CloneEndpoint returns a copy of the endpoint named name in region, ready
+// to be added to the region, with the non-zero fields of overrides replacing
+// the copied ones. Option structs such as HTTP are replaced whole.
+//
+// The copy keeps the original's name with the first free suffix, e.g.
+// "API (2)", or the name in overrides, suffixed too if the region already
+// has it. It must not test the same address with the same type as any
+// configured endpoint, since the two would share their results. Addresses
+// and options are left for the caller to validate against the protocols.
+func CloneEndpoint(cfg *models.Configuration, region, name string, overrides models.Endpoint) (models.Endpoint, error) {
+	r, ok := cfg.Regions[region]
+	if !ok {
+		return models.Endpoint{}, ErrRegionNotFound
+	}
+	i := slices.IndexFunc(r.Endpoints, func(ep models.Endpoint) bool { return ep.Name == name })
+	if i < 0 {
+		return models.Endpoint{}, ErrEndpointNotFound
+	}
+
+	// A round trip through JSON copies the option structs too
+	var clone models.Endpoint
+	raw, err := json.Marshal(r.Endpoints[i])
+	if err != nil {
+		return models.Endpoint{}, err
+	}
+	if err := json.Unmarshal(raw, &clone); err != nil {
+		return models.Endpoint{}, err
+	}
+	applyOverrides(&clone, overrides)
+
+	id := network.EndpointID(clone.Address, clone.Type)
+	for _, other := range cfg.Regions {
+		for _, ep := range other.Endpoints {
+			if network.EndpointID(ep.Address, ep.Type) == id {
+				return models.Endpoint{}, fmt.Errorf("%w: %s", ErrDuplicateEndpoint, ep.Name)
+			}
+		}
+	}
+	clone.Name = freeName(r.Endpoints, clone.Name)
+	return clone, nil
+}
+
+// applyOverrides sets the non-zero fields of overrides on ep
+func applyOverrides(ep *models.Endpoint, overrides models.Endpoint) {
+	if overrides.Name != "" {
+		ep.Name = overrides.Name
+	}
+	if overrides.Type != "" {
+		ep.Type = overrides.Type
+	}
+	if overrides.Address != "" {
+		ep.Address = overrides.Address
+	}
+	if overrides.Timeout != 0 {
+		ep.Timeout = overrides.Timeout
+	}
+	if overrides.IntervalSeconds != 0 {
+		ep.IntervalSeconds = overrides.IntervalSeconds
+	}
+	if overrides.Priority != 0 {
+		ep.Priority = overrides.Priority
+	}
+	if overrides.Confirm != nil {
+		ep.Confirm = overrides.Confirm
+	}
+	if overrides.HTTP != nil {
+		ep.HTTP = overrides.HTTP
+	}
+	if overrides.TCP != nil {
+		ep.TCP = overrides.TCP
+	}
+	if overrides.UDP != nil {
+		ep.UDP = overrides.UDP
+	}
+	if overrides.DNS != nil {
+		ep.DNS = overrides.DNS
+	}
+	if overrides.ICMP != nil {
+		ep.ICMP = overrides.ICMP
+	}
+	if overrides.AllAddresses {
+		ep.AllAddresses = true
+	}
+}
+
+// freeName returns name if no endpoint has it, or else name with the first
+// free " (n)" suffix, replacing a suffix it already has
+func freeName(endpoints []models.Endpoint, name string) string {
+	taken := func(n string) bool {
+		return slices.ContainsFunc(endpoints, func(ep models.Endpoint) bool { return ep.Name == n })
+	}
+	if !taken(name) {
+		return name
+	}
+	base, n := name, 2
+	if m := copySuffix.FindStringSubmatchIndex(name); m != nil {
+		base = name[:m[0]]
+		n, _ = strconv.Atoi(name[m[2]:m[3]])
+	}
+	for ; ; n++ {
+		if candidate := base + " (" + strconv.Itoa(n) + ")"; !taken(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestCloneEndpoint(t *testing.T) {
+	api := models.Endpoint{
+		Name: "API", Type: models.TypeHTTP, Address: "https://example.com/health", Timeout: 2000,
+		HTTP: &models.HTTPOptions{Method: "POST", Body: "{}"},
+	}
+	cfg := &models.Configuration{Regions: map[string]models.Region{
+		"Default": {Endpoints: []models.Endpoint{api, {Name: "API (2)", Type: models.TypeTCP, Address: "example.com:443", Timeout: 1000}}},
+	}}
+
+	clone, err := CloneEndpoint(cfg, "Default", "API", models.Endpoint{Address: "https://example.com/users", Timeout: 5000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clone.Name != "API (3)" || clone.Address != "https://example.com/users" || clone.Timeout != 5000 || clone.Type != models.TypeHTTP {
+		t.Errorf("Unexpected clone: %+v", clone)
+	}
+	if clone.HTTP == nil || clone.HTTP.Method != "POST" || clone.HTTP == cfg.Regions["Default"].Endpoints[0].HTTP {
+		t.Errorf("Expected a copy of the HTTP options, got %+v", clone.HTTP)
+	}
+
+	named, err := CloneEndpoint(cfg, "Default", "API", models.Endpoint{Name: "Users", Address: "https://example.com/users"})
+	if err != nil || named.Name != "Users" || named.Timeout != 2000 {
+		t.Errorf("Expected the name override and the original timeout, got %+v, %v", named, err)
+	}
+
+	if _, err := CloneEndpoint(cfg, "Default", "API", models.Endpoint{}); !errors.Is(err, ErrDuplicateEndpoint) {
+		t.Errorf("Expected a clone of the same address to be rejected, got %v", err)
+	}
+	if _, err := CloneEndpoint(cfg, "Default", "Missing", models.Endpoint{}); !errors.Is(err, ErrEndpointNotFound) {
+		t.Errorf("Expected ErrEndpointNotFound, got %v", err)
+	}
+	if _, err := CloneEndpoint(cfg, "Office", "API", models.Endpoint{}); !errors.Is(err, ErrRegionNotFound) {
+		t.Errorf("Expected ErrRegionNotFound, got %v", err)
+	}
+}
+
+func TestFreeName(t *testing.T) {
+	endpoints := []models.Endpoint{{Name: "Web"}, {Name: "Web (2)"}, {Name: "Web (4)"}}
+	for name, want := range map[string]string{"Web": "Web (3)", "Web (2)": "Web (3)", "Web (4)": "Web (5)", "DNS": "DNS"} {
+		if got := freeName(endpoints, name); got != want {
+			t.Errorf("freeName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -33,16 +34,54 @@ func DefaultConfig() *models.Configuration {
 			WindowHeight:         880,
 			WindowX:              -1,
 			WindowY:              -1,
+			ExportConcurrency:    2,
 		},
+		UI: DefaultUISettings(),
 	}
 }
 
+// DefaultUISettings returns the UI preferences used until the user changes them
+func DefaultUISettings() models.UISettings {
+	return models.UISettings{
+		Theme:            "dark",
+		DefaultTimeRange: "1h",
+		VisibleColumns:   []string{"time", "status", "latency"},
+		ChartSmoothing:   0.3,
+	}
+}
+
+// ValidateUISettings checks UI preferences against the values the frontend supports
+func ValidateUISettings(ui models.UISettings) error {
+	switch ui.Theme {
+	case "dark", "light", "system":
+	default:
+		return fmt.Errorf("unknown theme: %s", ui.Theme)
+	}
+	switch ui.DefaultTimeRange {
+	case "1h", "24h", "week", "month":
+	default:
+		return fmt.Errorf("unknown time range: %s", ui.DefaultTimeRange)
+	}
+	for _, c := range ui.VisibleColumns {
+		switch c {
+		case "time", "status", "latency":
+		default:
+			return fmt.Errorf("unknown column: %s", c)
+		}
+	}
+	if ui.ChartSmoothing < 0 || ui.ChartSmoothing > 1 {
+		return fmt.Errorf("chart smoothing must be between 0 and 1")
+	}
+	return nil
+}
+
 // LoadConfig reads the configuration from the specified file path
 func LoadConfig(ctx context.Context, path string) (*models.Configuration, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		// Return default config if file doesn't exist
 		log.Ctx(ctx).Info().Str("path", path).Msg("Config file not found, creating default config")
 		cfg := DefaultConfig()
+		cfg.Settings.OnboardingPending = true
 		// Attempt to save the default config so the user has a starting point
 		_ = SaveConfig(path, cfg)
 		return cfg, nil
@@ -62,6 +101,10 @@ func LoadConfig(ctx context.Context, path string) (*models.Configuration, error)
 	if cfg.Settings.TestIntervalSeconds < 1 {
 		cfg.Settings.TestIntervalSeconds = 300
 	}
+	// Configs written before UI settings existed, or edited by hand
+	if ValidateUISettings(cfg.UI) != nil {
+		cfg.UI = DefaultUISettings()
+	}
 
 	log.Ctx(ctx).Info().Interface("config", cfg).Msg("Configuration loaded")
 
@@ -80,5 +123,10 @@ func SaveConfig(path string, cfg *models.Configuration) error {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0644)
+	// Write to a temporary file first so a crash never leaves a partial config
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
 }
@@ -40,3 +40,29 @@ func TestLoadSaveConfig(t *testing.T) {
 		t.Errorf("Configs do not match")
 	}
 }
+
+func TestUISettingsDefaults(t *testing.T) {
+	tmpFile := t.TempDir() + "/config.json"
+	if err := os.WriteFile(tmpFile, []byte(`{"regions":{},"settings":{"test_interval_seconds":60}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(context.Background(), tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.UI, DefaultUISettings()) {
+		t.Errorf("Expected default UI settings for old config, got %+v", cfg.UI)
+	}
+
+	ui := DefaultUISettings()
+	ui.ChartSmoothing = 2
+	if err := ValidateUISettings(ui); err == nil {
+		t.Errorf("Expected error for smoothing out of range")
+	}
+	ui = DefaultUISettings()
+	ui.VisibleColumns = []string{"time", "jitter"}
+	if err := ValidateUISettings(ui); err == nil {
+		t.Errorf("Expected error for unknown column")
+	}
+}
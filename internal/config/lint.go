@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+// Lint issue codes
+const (
+	LintInvalidAddress         = "invalid_address"
+	LintInvalidConfirm         = "invalid_confirm"
+	LintDuplicateEndpoint      = "duplicate_endpoint"
+	LintTimeoutExceedsInterval = "timeout_exceeds_interval"
+	LintInsecureHTTP           = "insecure_http"
+	LintUDPUnverified          = "udp_unverified"
+	LintNoThresholds           = "no_thresholds"
+	LintEmptyRegion            = "empty_region"
+	LintAPIExposed             = "api_exposed"
+)
+
+var severityRank = map[string]int{models.LintError: 0, models.LintWarning: 1, models.LintInfo: 2}
+
+// Lint flags risky parts of cfg, most severe first. Addresses are checked
+// against the protocols in reg. Issues have no Message; callers localize
+// them from the code.
+func Lint(cfg models.Configuration, reg *network.Registry) []models.LintIssue {
+	var issues []models.LintIssue
+	add := func(severity, code, region, endpoint, detail string) {
+		issues = append(issues, models.LintIssue{Severity: severity, Code: code, Region: region, Endpoint: endpoint, Detail: detail})
+	}
+
+	seen := make(map[string]string) // Endpoint ID -> region/name of its first use
+
+	regions := make([]string, 0, len(cfg.Regions))
+	for name := range cfg.Regions {
+		regions = append(regions, name)
+	}
+	sort.Strings(regions)
+
+	for _, name := range regions {
+		region := cfg.Regions[name]
+		if len(region.Endpoints) == 0 {
+			add(models.LintInfo, LintEmptyRegion, name, "", "")
+			continue
+		}
+		if region.Thresholds.LatencyMs <= 0 && region.Thresholds.AvailabilityPercent <= 0 {
+			add(models.LintWarning, LintNoThresholds, name, "", "")
+		}
+
+		for _, ep := range region.Endpoints {
+			if err := reg.Validate(ep.Type, ep.Address); err != nil {
+				add(models.LintError, LintInvalidAddress, name, ep.Name, err.Error())
+				continue
+			}
+			if c := ep.Confirm; c != nil {
+				if err := reg.Validate(c.Type, c.Address); err != nil {
+					add(models.LintError, LintInvalidConfirm, name, ep.Name, err.Error())
+				}
+			}
+			id := network.EndpointID(ep.Address, ep.Type)
+			if first, ok := seen[id]; ok {
+				add(models.LintError, LintDuplicateEndpoint, name, ep.Name, first)
+			} else {
+				seen[id] = name + "/" + ep.Name
+			}
+			interval := cfg.Settings.TestIntervalSeconds
+			if ep.IntervalSeconds > 0 {
+				interval = ep.IntervalSeconds
+			}
+			if interval > 0 && ep.Timeout >= interval*1000 {
+				add(models.LintError, LintTimeoutExceedsInterval, name, ep.Name, fmt.Sprintf("%d ms ≥ %d s", ep.Timeout, interval))
+			}
+			switch ep.Type {
+			case models.TypeHTTP:
+				if u, err := url.Parse(ep.Address); err == nil && u.Scheme == "http" && public(u.Hostname()) {
+					add(models.LintWarning, LintInsecureHTTP, name, ep.Name, u.Hostname())
+				}
+			case models.TypeUDP:
+				add(models.LintWarning, LintUDPUnverified, name, ep.Name, "")
+			}
+		}
+	}
+
+	if api := cfg.Settings.API; api != nil && api.Enabled && api.TLSCert == "" {
+		if host, _, err := net.SplitHostPort(api.Listen); err == nil && (host == "" || public(host)) {
+			add(models.LintWarning, LintAPIExposed, "", "", api.Listen)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return severityRank[issues[i].Severity] < severityRank[issues[j].Severity]
+	})
+	return issues
+}
+
+// public reports whether host may be reached over the internet: anything but
+// loopback, private and link-local addresses and local names
+func public(host string) bool {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast()
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return false
+	}
+	for _, suffix := range []string{".localhost", ".local", ".lan", ".internal", ".home.arpa"} {
+		if strings.HasSuffix(host, suffix) {
+			return false
+		}
+	}
+	return true
+}
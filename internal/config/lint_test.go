@@ -0,0 +1,64 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+func TestLint(t *testing.T) {
+	cfg := models.Configuration{
+		Regions: map[string]models.Region{
+			"Home": {
+				Endpoints: []models.Endpoint{
+					{Name: "Router", Type: models.TypeHTTP, Address: "http://192.168.1.1", Timeout: 1000},
+					{Name: "Site", Type: models.TypeHTTP, Address: "http://example.com", Timeout: 1000},
+					{Name: "Slow", Type: models.TypeTCP, Address: "example.com:443", Timeout: 30000},
+					{Name: "Syslog", Type: models.TypeUDP, Address: "10.0.0.5:514", Timeout: 1000, IntervalSeconds: 1},
+					{Name: "Broken", Type: models.TypeTCP, Address: "no-port", Timeout: 1000},
+					{Name: "Panel", Type: models.TypeHTTP, Address: "http://192.168.1.2", Timeout: 1000, Confirm: &models.TestConfig{Type: models.TypeTCP, Address: "192.168.1.2"}},
+				},
+				Thresholds: models.Thresholds{LatencyMs: 100},
+			},
+			"Office": {
+				Endpoints: []models.Endpoint{{Name: "Again", Type: models.TypeTCP, Address: "example.com:443", Timeout: 1000}},
+			},
+			"Empty": {},
+		},
+		Settings: models.AppSettings{
+			TestIntervalSeconds: 30,
+			API:                 &models.APISettings{Enabled: true, Listen: ":8321"},
+		},
+	}
+
+	type key struct{ severity, code, endpoint string }
+	want := []key{
+		{models.LintError, LintTimeoutExceedsInterval, "Slow"},
+		{models.LintError, LintTimeoutExceedsInterval, "Syslog"},
+		{models.LintError, LintInvalidAddress, "Broken"},
+		{models.LintError, LintInvalidConfirm, "Panel"},
+		{models.LintError, LintDuplicateEndpoint, "Again"},
+		{models.LintWarning, LintInsecureHTTP, "Site"},
+		{models.LintWarning, LintUDPUnverified, "Syslog"},
+		{models.LintWarning, LintNoThresholds, ""},
+		{models.LintWarning, LintAPIExposed, ""},
+		{models.LintInfo, LintEmptyRegion, ""},
+	}
+	got := Lint(cfg, network.NewRegistry())
+	if len(got) != len(want) {
+		t.Fatalf("got %d issues, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if g := (key{got[i].Severity, got[i].Code, got[i].Endpoint}); g != w {
+			t.Errorf("issue %d: got %+v, want %+v", i, g, w)
+		}
+	}
+	if got[4].Detail != "Home/Slow" {
+		t.Errorf("duplicate should name the first use, got %q", got[4].Detail)
+	}
+
+	if issues := Lint(*DefaultConfig(), network.NewRegistry()); len(issues) != 0 {
+		t.Errorf("default config has issues: %+v", issues)
+	}
+}
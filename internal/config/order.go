@@ -0,0 +1,23 @@
+package config
+
+import (
+	"slices"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// SortEndpoints orders endpoints by priority, highest first, keeping the
+// order of endpoints of equal priority
+func SortEndpoints(endpoints []models.Endpoint) {
+	slices.SortStableFunc(endpoints, func(a, b models.Endpoint) int { return b.Priority - a.Priority })
+}
+
+// InsertEndpoint appends ep to endpoints after the last endpoint of the same
+// or a higher priority, so a sorted list stays sorted
+func InsertEndpoint(endpoints []models.Endpoint, ep models.Endpoint) []models.Endpoint {
+	i := len(endpoints)
+	for i > 0 && endpoints[i-1].Priority < ep.Priority {
+		i--
+	}
+	return slices.Insert(endpoints, i, ep)
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func names(endpoints []models.Endpoint) string {
+	var s string
+	for _, ep := range endpoints {
+		s += ep.Name
+	}
+	return s
+}
+
+func TestSortEndpoints(t *testing.T) {
+	endpoints := []models.Endpoint{{Name: "a"}, {Name: "b", Priority: 1}, {Name: "c", Priority: -1}, {Name: "d"}, {Name: "e", Priority: 1}}
+	SortEndpoints(endpoints)
+	if got := names(endpoints); got != "beadc" {
+		t.Errorf("Expected beadc, got %s", got)
+	}
+
+	endpoints = InsertEndpoint(endpoints, models.Endpoint{Name: "f"})
+	endpoints = InsertEndpoint(endpoints, models.Endpoint{Name: "g", Priority: 2})
+	endpoints = InsertEndpoint(endpoints, models.Endpoint{Name: "h", Priority: -2})
+	if got := names(endpoints); got != "gbeadfch" {
+		t.Errorf("Expected gbeadfch, got %s", got)
+	}
+}
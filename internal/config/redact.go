@@ -0,0 +1,28 @@
+package config
+
+import (
+	"slices"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// Redact removes the API settings and the sync and SMTP passwords from a
+// config that leaves the machine: served by the API or replicated to the sync
+// target. Configs saved through the API keep the current values of all three.
+func Redact(cfg models.Configuration) models.Configuration {
+	cfg.Settings.API = nil
+	if cfg.Settings.Sync != nil {
+		sync := *cfg.Settings.Sync
+		sync.Password = ""
+		cfg.Settings.Sync = &sync
+	}
+	if cfg.Settings.Alerting != nil {
+		alerting := *cfg.Settings.Alerting
+		alerting.Notifiers = slices.Clone(alerting.Notifiers)
+		for i := range alerting.Notifiers {
+			alerting.Notifiers[i].SMTPPassword = ""
+		}
+		cfg.Settings.Alerting = &alerting
+	}
+	return cfg
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestRedact(t *testing.T) {
+	cfg := models.Configuration{Settings: models.AppSettings{
+		Sync: &models.SyncSettings{Target: "https://dav", Password: "secret"},
+		API:  &models.APISettings{Enabled: true},
+		Alerting: &models.AlertSettings{Notifiers: []models.NotifierSettings{
+			{Name: "mail", Type: "email", SMTPPassword: "secret"},
+		}},
+	}}
+	redacted := Redact(cfg)
+	if redacted.Settings.API != nil || redacted.Settings.Sync.Password != "" || redacted.Settings.Alerting.Notifiers[0].SMTPPassword != "" {
+		t.Errorf("Secrets not redacted: %+v", redacted.Settings)
+	}
+	if cfg.Settings.Sync.Password != "secret" || cfg.Settings.Alerting.Notifiers[0].SMTPPassword != "secret" {
+		t.Errorf("Redact modified the original config")
+	}
+}
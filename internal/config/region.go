@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/data"
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+// ValidateRegion checks the location, time zone and business hours of a
+// region
+func ValidateRegion(r models.Region) error {
+	if p := r.Location; p != nil && (p.Latitude < -90 || p.Latitude > 90 || p.Longitude < -180 || p.Longitude > 180) {
+		return fmt.Errorf("location %g, %g is out of range", p.Latitude, p.Longitude)
+	}
+	if _, err := RegionSchedule(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RegionSchedule compiles the business hours of a region in its time zone,
+// nil when it has none
+func RegionSchedule(r models.Region) (*data.Schedule, error) {
+	loc, err := RegionLocation(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.BusinessHours == nil {
+		return nil, nil
+	}
+	return data.NewSchedule(*r.BusinessHours, loc)
+}
+
+// RegionLocation loads the time zone of a region, UTC when it has none
+func RegionLocation(r models.Region) (*time.Location, error) {
+	if r.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", r.Timezone)
+	}
+	return loc, nil
+}
+
+// EndpointRegions maps the ID of every configured endpoint to its region
+func EndpointRegions(cfg *models.Configuration) map[string]models.EndpointRegion {
+	regions := make(map[string]models.EndpointRegion)
+	for name, r := range cfg.Regions {
+		for _, ep := range r.Endpoints {
+			regions[network.EndpointID(ep.Address, ep.Type)] = models.EndpointRegion{
+				Region: name, Location: r.Location, Timezone: r.Timezone, BusinessHours: r.BusinessHours,
+			}
+		}
+	}
+	return regions
+}
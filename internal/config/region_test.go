@@ -0,0 +1,40 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+func TestValidateRegion(t *testing.T) {
+	for _, r := range []models.Region{
+		{Location: &models.GeoPoint{Latitude: 91}},
+		{Location: &models.GeoPoint{Longitude: -181}},
+		{Timezone: "Mars/Olympus_Mons"},
+		{BusinessHours: &models.BusinessHours{Start: "18:00", End: "09:00"}},
+		{BusinessHours: &models.BusinessHours{Days: []string{"monday"}, Start: "09:00", End: "17:00"}},
+	} {
+		if err := ValidateRegion(r); err == nil {
+			t.Errorf("Expected %+v to be rejected", r)
+		}
+	}
+	if err := ValidateRegion(models.Region{
+		Location: &models.GeoPoint{Latitude: 38.72, Longitude: -9.14}, Timezone: "UTC",
+		BusinessHours: &models.BusinessHours{Start: "09:00", End: "24:00", Holidays: []string{"2026-12-25"}},
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEndpointRegions(t *testing.T) {
+	lisbon := &models.GeoPoint{Latitude: 38.72, Longitude: -9.14}
+	ep := models.Endpoint{Type: models.TypeICMP, Address: "192.168.1.1"}
+	cfg := &models.Configuration{Regions: map[string]models.Region{
+		"Office": {Endpoints: []models.Endpoint{ep}, Location: lisbon, Timezone: "Europe/Lisbon"},
+	}}
+	got := EndpointRegions(cfg)[network.EndpointID(ep.Address, ep.Type)]
+	if got.Region != "Office" || got.Location != lisbon || got.Timezone != "Europe/Lisbon" {
+		t.Errorf("Unexpected region: %+v", got)
+	}
+}
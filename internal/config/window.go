@@ -0,0 +1,80 @@
+package config
+
+import "github.com/marcoshack/netmonitor/internal/models"
+
+// minVisible is how many pixels of a restored window must be on its display,
+// enough to grab the title bar and drag it back
+const minVisible = 100
+
+// WindowPlacement is how the window is restored at startup. Positions are
+// relative to the display the window is on, as the window runtime uses them.
+type WindowPlacement struct {
+	Width, Height int
+	X, Y          int
+	// Center ignores X and Y because the saved position is unknown or no
+	// longer on screen
+	Center    bool
+	Maximised bool
+}
+
+// PlaceWindow decides how to restore the window saved in the settings on the
+// displays connected now. The saved position is kept only if the display the
+// window was on is still connected, the number of displays didn't change and
+// the title bar would be on screen; otherwise the window is centered. The
+// size is capped to the display so a window saved on a larger one fits.
+func PlaceWindow(s models.AppSettings, displays []models.Display) WindowPlacement {
+	p := WindowPlacement{
+		Width:     s.WindowWidth,
+		Height:    s.WindowHeight,
+		X:         s.WindowX,
+		Y:         s.WindowY,
+		Center:    s.WindowX == -1 && s.WindowY == -1,
+		Maximised: s.WindowMaximised,
+	}
+	if len(displays) == 0 {
+		// The layout is unknown, trust the saved placement
+		return p
+	}
+
+	target, ok := findDisplay(displays, s.WindowDisplay)
+	if !ok {
+		p.Center = true
+	}
+	if target.Width > 0 && p.Width > target.Width {
+		p.Width = target.Width
+	}
+	if target.Height > 0 && p.Height > target.Height {
+		p.Height = target.Height
+	}
+	if !p.Center && (p.X+p.Width < minVisible || p.X > target.Width-minVisible ||
+		p.Y < 0 || p.Y > target.Height-minVisible) {
+		p.Center = true
+	}
+	return p
+}
+
+// findDisplay returns the connected display matching the saved one. Without
+// a saved display, from configs older than display tracking, it returns the
+// primary display. ok is false if the saved display is gone or the number of
+// displays changed.
+func findDisplay(displays []models.Display, saved *models.Display) (models.Display, bool) {
+	primary := displays[0]
+	for _, d := range displays {
+		if d.Primary {
+			primary = d
+			break
+		}
+	}
+	if saved == nil {
+		return primary, true
+	}
+	if saved.Count > 0 && saved.Count != len(displays) {
+		return primary, false
+	}
+	for _, d := range displays {
+		if d.Width == saved.Width && d.Height == saved.Height && d.Primary == saved.Primary {
+			return d, true
+		}
+	}
+	return primary, false
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestPlaceWindow(t *testing.T) {
+	laptop := models.Display{Width: 1440, Height: 900, Primary: true}
+	external := models.Display{Width: 2560, Height: 1440}
+	saved := func(x, y, w, h int, d *models.Display) models.AppSettings {
+		return models.AppSettings{WindowX: x, WindowY: y, WindowWidth: w, WindowHeight: h, WindowDisplay: d}
+	}
+	on := func(d models.Display, count int) *models.Display {
+		d.Count = count
+		return &d
+	}
+
+	tests := []struct {
+		name     string
+		settings models.AppSettings
+		displays []models.Display
+		want     WindowPlacement
+	}{
+		{
+			name:     "same layout",
+			settings: saved(200, 100, 1024, 880, on(external, 2)),
+			displays: []models.Display{laptop, external},
+			want:     WindowPlacement{X: 200, Y: 100, Width: 1024, Height: 880},
+		},
+		{
+			name:     "display disconnected",
+			settings: saved(200, 100, 1024, 880, on(external, 2)),
+			displays: []models.Display{laptop},
+			want:     WindowPlacement{X: 200, Y: 100, Width: 1024, Height: 880, Center: true},
+		},
+		{
+			name:     "display replaced",
+			settings: saved(200, 100, 1024, 880, on(external, 2)),
+			displays: []models.Display{laptop, {Width: 1920, Height: 1080}},
+			want:     WindowPlacement{X: 200, Y: 100, Width: 1024, Height: 880, Center: true},
+		},
+		{
+			name:     "larger than display",
+			settings: saved(0, 0, 2000, 1200, on(laptop, 1)),
+			displays: []models.Display{laptop},
+			want:     WindowPlacement{Width: 1440, Height: 900},
+		},
+		{
+			name:     "off screen",
+			settings: saved(1400, 100, 1024, 880, on(laptop, 1)),
+			displays: []models.Display{laptop},
+			want:     WindowPlacement{X: 1400, Y: 100, Width: 1024, Height: 880, Center: true},
+		},
+		{
+			name:     "title bar above screen",
+			settings: saved(100, -40, 1024, 880, on(laptop, 1)),
+			displays: []models.Display{laptop},
+			want:     WindowPlacement{X: 100, Y: -40, Width: 1024, Height: 880, Center: true},
+		},
+		{
+			name:     "no saved display",
+			settings: saved(-1000, 100, 1024, 880, nil),
+			displays: []models.Display{laptop},
+			want:     WindowPlacement{X: -1000, Y: 100, Width: 1024, Height: 880, Center: true},
+		},
+		{
+			name:     "never saved",
+			settings: saved(-1, -1, 1024, 880, nil),
+			displays: []models.Display{laptop},
+			want:     WindowPlacement{X: -1, Y: -1, Width: 1024, Height: 880, Center: true},
+		},
+		{
+			name:     "unknown layout",
+			settings: models.AppSettings{WindowX: 50, WindowY: 60, WindowWidth: 800, WindowHeight: 600, WindowMaximised: true},
+			want:     WindowPlacement{X: 50, Y: 60, Width: 800, Height: 600, Maximised: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlaceWindow(tt.settings, tt.displays); got != tt.want {
+				t.Errorf("PlaceWindow() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package data
+
+import (
+	"maps"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// maxAggregateWorkers bounds the endpoints aggregated at the same time
+const maxAggregateWorkers = 8
+
+// Aggregate summarizes results per endpoint in buckets of the given size,
+// aligned to the Unix epoch. Cancelled results are ignored. The output is
+// sorted by bucket start and endpoint ID.
+//
+// Endpoints are aggregated in parallel, each in a single pass over its results.
+func Aggregate(results []models.TestResult, bucket time.Duration) []models.AggregatedResult {
+	return AggregateLocal(results, bucket, nil, nil)
+}
+
+// AggregateLocal is Aggregate with the buckets of the endpoints in locations
+// aligned to their local time instead: buckets of whole days start at local
+// midnight, lasting 23 or 25 hours across daylight saving changes, and
+// shorter ones on the local clock, which matters for zones offset by
+// fractions of an hour. Aggregates of the endpoints in schedules also count
+// the tests run within their business hours.
+func AggregateLocal(results []models.TestResult, bucket time.Duration, locations map[string]*time.Location, schedules map[string]*Schedule) []models.AggregatedResult {
+	size := bucket.Milliseconds()
+	if size <= 0 {
+		return nil
+	}
+
+	byEndpoint := make(map[string][]models.TestResult)
+	for _, r := range results {
+		byEndpoint[r.Id] = append(byEndpoint[r.Id], r)
+	}
+	groups := make([][]models.TestResult, 0, len(byEndpoint))
+	for _, g := range byEndpoint {
+		groups = append(groups, g)
+	}
+
+	perEndpoint := make([][]models.AggregatedResult, len(groups))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(len(groups), runtime.GOMAXPROCS(0), maxAggregateWorkers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				id := groups[i][0].Id
+				perEndpoint[i] = aggregate(groups[i], size, locations[id], schedules[id])
+			}
+		}()
+	}
+	for i := range groups {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	aggregated := make([]models.AggregatedResult, 0, len(perEndpoint))
+	for _, a := range perEndpoint {
+		aggregated = append(aggregated, a...)
+	}
+	sort.Slice(aggregated, func(i, j int) bool {
+		if aggregated[i].Start != aggregated[j].Start {
+			return aggregated[i].Start < aggregated[j].Start
+		}
+		return aggregated[i].EndpointID < aggregated[j].EndpointID
+	})
+	return aggregated
+}
+
+// AggregateRange summarizes the results of endpoint id in [start, end) in a
+// single aggregate, e.g. over a sliding window. Cancelled results and results
+// of other endpoints are ignored.
+func AggregateRange(results []models.TestResult, id string, start, end time.Time) models.AggregatedResult {
+	startMs, endMs := start.UnixMilli(), end.UnixMilli()
+	acc := newAccumulator(id, startMs, endMs)
+	for _, r := range results {
+		if r.Id == id && r.St != models.TestStatusCancelled && r.Ts >= startMs && r.Ts < endMs {
+			acc.add(r)
+		}
+	}
+	return acc.result()
+}
+
+// aggregate buckets the results of one pass, in no particular order. A nil
+// loc aligns buckets to the Unix epoch, a nil sched counts no business hours.
+func aggregate(results []models.TestResult, size int64, loc *time.Location, sched *Schedule) []models.AggregatedResult {
+	type key struct {
+		id    string
+		start int64
+	}
+	buckets := make(map[key]*accumulator)
+	for _, r := range withoutCancelled(results) {
+		start, end := bucketBounds(r.Ts, size, loc)
+		k := key{id: r.Id, start: start}
+		acc, ok := buckets[k]
+		if !ok {
+			acc = newAccumulator(r.Id, start, end)
+			buckets[k] = acc
+		}
+		acc.add(r)
+		if sched != nil && sched.Contains(r.Ts) {
+			acc.agg.BusinessCount++
+			if r.St != models.TestStatusSuccess {
+				acc.agg.BusinessFailures++
+			}
+		}
+	}
+
+	aggregated := make([]models.AggregatedResult, 0, len(buckets))
+	for _, acc := range buckets {
+		aggregated = append(aggregated, acc.result())
+	}
+	return aggregated
+}
+
+// bucketBounds returns the bucket of size ms holding ts, in loc's local time
+func bucketBounds(ts, size int64, loc *time.Location) (start, end int64) {
+	if loc == nil || loc == time.UTC {
+		start = ts - ts%size
+		return start, start + size
+	}
+	t := time.UnixMilli(ts).In(loc)
+	if dayMs := (24 * time.Hour).Milliseconds(); size%dayMs == 0 {
+		days := size / dayMs
+		civil := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+		first := time.Unix((civil-civil%days)*86400, 0).UTC()
+		start = time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc).UnixMilli()
+		end = time.Date(first.Year(), first.Month(), first.Day()+int(days), 0, 0, 0, 0, loc).UnixMilli()
+		return start, end
+	}
+	_, offset := t.Zone()
+	local := ts + int64(offset)*1000
+	start = local - local%size - int64(offset)*1000
+	return start, start + size
+}
+
+// accumulator builds the aggregate of one bucket a result at a time, in
+// constant memory. The latency mean and variance are kept with Welford's
+// algorithm, which stays accurate over long runs.
+type accumulator struct {
+	agg       models.AggregatedResult
+	mean, m2  float64
+	quantiles *latencyQuantiles
+
+	loss float64 // Sum of the loss of every test, in percent
+	// Jitter measured by multi-packet tests, and between consecutive
+	// successful tests in the order they were added
+	measuredJitter, variation float64
+	measured, variations      int
+	last                      float64
+}
+
+func newAccumulator(id string, start, end int64) *accumulator {
+	return &accumulator{agg: models.AggregatedResult{EndpointID: id, Start: start, End: end}}
+}
+
+// add records a result, which must not be cancelled
+func (a *accumulator) add(r models.TestResult) {
+	agg := &a.agg
+	agg.Count++
+
+	if r.St != models.TestStatusSuccess {
+		a.loss += 100
+		agg.Failures++
+		kind := r.Ek
+		if kind == "" {
+			kind = models.ErrorKindOther
+		}
+		if agg.FailuresByKind == nil {
+			agg.FailuresByKind = make(map[models.ErrorKind]int)
+		}
+		agg.FailuresByKind[kind]++
+		return
+	}
+
+	successes := agg.Count - agg.Failures
+	if successes == 1 || r.Ms < agg.MinMs {
+		agg.MinMs = r.Ms
+	}
+	if r.Ms > agg.MaxMs {
+		agg.MaxMs = r.Ms
+	}
+	x := r.LatencyMs()
+	if successes > 1 {
+		a.variation += math.Abs(x - a.last)
+		a.variations++
+	}
+	a.last = x
+	if multiPacket(r) {
+		a.loss += r.Loss
+		a.measuredJitter += r.Jit
+		a.measured++
+	}
+	delta := x - a.mean
+	a.mean += delta / float64(successes)
+	a.m2 += delta * (x - a.mean)
+	if a.quantiles == nil {
+		a.quantiles = newLatencyQuantiles()
+	}
+	a.quantiles.Add(x)
+}
+
+// result returns the aggregate of the results added so far
+func (a *accumulator) result() models.AggregatedResult {
+	agg := a.agg
+	if agg.FailuresByKind != nil {
+		agg.FailuresByKind = maps.Clone(agg.FailuresByKind)
+	}
+	if successes := agg.Count - agg.Failures; successes > 0 {
+		agg.AvgMs = a.mean
+		agg.StdDevMs = math.Sqrt(a.m2 / float64(successes))
+		agg.P50Ms, agg.P95Ms, agg.P99Ms = a.quantiles.p50.Value(), a.quantiles.p95.Value(), a.quantiles.p99.Value()
+	}
+	switch {
+	case a.measured > 0:
+		agg.JitterMs = a.measuredJitter / float64(a.measured)
+	case a.variations > 0:
+		agg.JitterMs = a.variation / float64(a.variations)
+	}
+	if agg.Count > 0 {
+		agg.PacketLossPct = a.loss / float64(agg.Count)
+	}
+	return agg
+}
+
+// multiPacket reports whether r comes from a test sending a train of
+// packets, which measures its own jitter and loss: a UDP jitter test, which
+// always estimates a MOS, or an ICMP test with a packet count
+func multiPacket(r models.TestResult) bool {
+	return r.Rtt != nil || r.Mos > 0
+}
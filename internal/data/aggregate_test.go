@@ -0,0 +1,156 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestAggregate(t *testing.T) {
+	results := []models.TestResult{
+		{Ts: 1_000, Id: "a", Ms: 10, St: 0},
+		{Ts: 2_000, Id: "a", Ms: 30, St: 0},
+		{Ts: 3_000, Id: "a", St: 2, Ek: models.ErrorKindDNS},
+		{Ts: 4_000, Id: "a", St: 1, Ek: models.ErrorKindTimeout},
+		{Ts: 5_000, Id: "a", St: 2, Ek: models.ErrorKindDNS},
+		{Ts: 6_000, Id: "a", St: 2}, // Stored before failures were classified
+		{Ts: 7_000, Id: "a", St: models.TestStatusCancelled},
+		{Ts: 61_000, Id: "a", Ms: 20, St: 0},
+		{Ts: 1_500, Id: "b", Ms: 5, St: 0},
+	}
+
+	agg := Aggregate(results, time.Minute)
+	if len(agg) != 3 {
+		t.Fatalf("Expected 3 buckets, got %d: %+v", len(agg), agg)
+	}
+
+	a := agg[0]
+	if a.EndpointID != "a" || a.Start != 0 || a.End != 60_000 {
+		t.Fatalf("Unexpected first bucket: %+v", a)
+	}
+	if a.Count != 6 || a.Failures != 4 {
+		t.Errorf("Expected 6 tests and 4 failures, got %d and %d", a.Count, a.Failures)
+	}
+	if a.AvgMs != 20 || a.MinMs != 10 || a.MaxMs != 30 || a.P50Ms != 20 || a.P99Ms < 29 {
+		t.Errorf("Unexpected latency stats: %+v", a)
+	}
+	want := map[models.ErrorKind]int{
+		models.ErrorKindDNS:     2,
+		models.ErrorKindTimeout: 1,
+		models.ErrorKindOther:   1,
+	}
+	for kind, n := range want {
+		if a.FailuresByKind[kind] != n {
+			t.Errorf("Expected %d %s failures, got %d", n, kind, a.FailuresByKind[kind])
+		}
+	}
+
+	if agg[1].EndpointID != "b" || agg[2].Start != 60_000 || agg[2].FailuresByKind != nil {
+		t.Errorf("Unexpected bucket order or content: %+v", agg[1:])
+	}
+}
+
+func TestAggregateJitterAndLoss(t *testing.T) {
+	// Single probes: jitter between consecutive latencies, failures lost
+	results := []models.TestResult{
+		{Ts: 1_000, Id: "a", Ms: 10},
+		{Ts: 2_000, Id: "a", Ms: 40},
+		{Ts: 3_000, Id: "a", St: models.TestStatusTimeout},
+		{Ts: 4_000, Id: "a", Ms: 20},
+	}
+	a := AggregateRange(results, "a", time.UnixMilli(0), time.UnixMilli(60_000))
+	if a.JitterMs != 25 || a.PacketLossPct != 25 {
+		t.Errorf("Expected 25 ms jitter and 25%% loss, got %v and %v", a.JitterMs, a.PacketLossPct)
+	}
+
+	// Packet trains report their own jitter and loss
+	rtt := &models.RTTStats{Sent: 10, Recv: 8}
+	results = []models.TestResult{
+		{Ts: 1_000, Id: "a", Ms: 10, Jit: 2, Loss: 20, Rtt: rtt},
+		{Ts: 2_000, Id: "a", Ms: 90, Jit: 4, Loss: 0, Rtt: rtt},
+		{Ts: 3_000, Id: "a", St: models.TestStatusError, Ek: models.ErrorKindPacketLoss},
+		{Ts: 4_000, Id: "a", Ms: 10, Jit: 6, Loss: 40, Mos: 4.1},
+	}
+	a = AggregateRange(results, "a", time.UnixMilli(0), time.UnixMilli(60_000))
+	if a.JitterMs != 4 || a.PacketLossPct != 40 {
+		t.Errorf("Expected 4 ms jitter and 40%% loss, got %v and %v", a.JitterMs, a.PacketLossPct)
+	}
+}
+
+func TestAggregateMicroseconds(t *testing.T) {
+	// LAN latencies below a millisecond, mixed with a result stored before
+	// microseconds were
+	results := []models.TestResult{
+		{Ts: 1_000, Id: "a", Ms: 0, Us: 250},
+		{Ts: 2_000, Id: "a", Ms: 0, Us: 750},
+		{Ts: 3_000, Id: "a", Ms: 2},
+	}
+	a := Aggregate(results, time.Minute)[0]
+	if a.AvgMs != 1 || a.StdDevMs < 0.7 || a.StdDevMs > 0.8 {
+		t.Errorf("Unexpected latency stats: %+v", a)
+	}
+}
+
+func TestAggregateRange(t *testing.T) {
+	results := []models.TestResult{
+		{Ts: 1_000, Id: "a", Ms: 10},
+		{Ts: 2_000, Id: "a", Ms: 30},
+		{Ts: 3_000, Id: "a", St: models.TestStatusTimeout},
+		{Ts: 4_000, Id: "a", St: models.TestStatusCancelled},
+		{Ts: 5_000, Id: "a", Ms: 50}, // At the end, excluded
+		{Ts: 2_500, Id: "b", Ms: 5},
+	}
+	a := AggregateRange(results, "a", time.UnixMilli(2_000), time.UnixMilli(5_000))
+	if a.EndpointID != "a" || a.Start != 2_000 || a.End != 5_000 || a.Count != 2 || a.Failures != 1 || a.AvgMs != 30 {
+		t.Errorf("Unexpected aggregate: %+v", a)
+	}
+}
+
+func TestAggregateLocal(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip(err)
+	}
+	at := func(loc *time.Location, d, h, m int) int64 {
+		return time.Date(2024, time.March, d, h, m, 0, 0, loc).UnixMilli()
+	}
+	results := []models.TestResult{
+		{Ts: at(ny, 9, 23, 0), Id: "ny", Ms: 10},
+		{Ts: at(ny, 10, 1, 0), Id: "ny", Ms: 10}, // Before the clocks go forward at 2:00
+		{Ts: at(ny, 10, 23, 0), Id: "ny", Ms: 10},
+		{Ts: at(kolkata, 10, 10, 10), Id: "in", Ms: 10},
+		{Ts: at(time.UTC, 10, 10, 10), Id: "utc", Ms: 10},
+	}
+	locations := map[string]*time.Location{"ny": ny, "in": kolkata}
+
+	var days []models.AggregatedResult
+	for _, a := range AggregateLocal(results, 24*time.Hour, locations, nil) {
+		if a.EndpointID == "ny" {
+			days = append(days, a)
+		}
+	}
+	if len(days) != 2 || days[1].Count != 2 || days[1].Start != at(ny, 10, 0, 0) || days[1].End != at(ny, 11, 0, 0) {
+		t.Fatalf("Expected local days, got %+v", days)
+	}
+	if hours := time.Duration(days[1].End-days[1].Start) * time.Millisecond; hours != 23*time.Hour {
+		t.Errorf("Expected a 23 hour day, got %s", hours)
+	}
+
+	for _, a := range AggregateLocal(results, time.Hour, locations, nil) {
+		switch a.EndpointID {
+		case "in":
+			if a.Start != at(kolkata, 10, 10, 0) {
+				t.Errorf("Expected the local hour, got %s", time.UnixMilli(a.Start).In(kolkata))
+			}
+		case "utc":
+			if a.Start != at(time.UTC, 10, 10, 0) {
+				t.Errorf("Expected the UTC hour, got %s", time.UnixMilli(a.Start).UTC())
+			}
+		}
+	}
+}
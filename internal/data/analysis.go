@@ -0,0 +1,203 @@
+package data
+
+import (
+	"sort"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// DetectOutages groups consecutive failed results of each endpoint into outage
+// windows. Cancelled results are ignored. An outage ends at the first successful result after it; outages
+// still failing at the last result are reported as ongoing.
+func DetectOutages(results []models.TestResult) []models.Outage {
+	byEndpoint := make(map[string][]models.TestResult)
+	for _, r := range withoutCancelled(results) {
+		byEndpoint[r.Id] = append(byEndpoint[r.Id], r)
+	}
+
+	var outages []models.Outage
+	for id, epResults := range byEndpoint {
+		sort.Slice(epResults, func(i, j int) bool { return epResults[i].Ts < epResults[j].Ts })
+
+		var current *models.Outage
+		for _, r := range epResults {
+			if r.St != 0 {
+				if current == nil {
+					current = &models.Outage{EndpointID: id, Start: r.Ts, Trace: r.Trace}
+				}
+				current.End = r.Ts
+				current.Failures++
+				continue
+			}
+			if current != nil {
+				current.End = r.Ts
+				outages = append(outages, *current)
+				current = nil
+			}
+		}
+		if current != nil {
+			current.Ongoing = true
+			outages = append(outages, *current)
+		}
+	}
+
+	sort.Slice(outages, func(i, j int) bool {
+		if outages[i].Start != outages[j].Start {
+			return outages[i].Start < outages[j].Start
+		}
+		return outages[i].EndpointID < outages[j].EndpointID
+	})
+	return outages
+}
+
+// GapFactor is how many test intervals may pass without a result before the
+// silence is treated as a data gap (app closed, machine asleep). It matches
+// the threshold the dashboard uses to break chart lines.
+const GapFactor = 2.5
+
+// Intervals gives the test interval of each endpoint: the one in Endpoints,
+// or Default for endpoints without an interval of their own
+type Intervals struct {
+	Default   time.Duration
+	Endpoints map[string]time.Duration
+}
+
+// Of returns the test interval of endpoint id
+func (iv Intervals) Of(id string) time.Duration {
+	if d, ok := iv.Endpoints[id]; ok {
+		return d
+	}
+	return iv.Default
+}
+
+// DetectGaps reports windows within [start, end] where no results were stored
+// for each of the given endpoints, judged against each endpoint's interval.
+// Endpoints without any result in the range are reported as one gap covering
+// the whole range. Cancelled results don't count as data.
+func DetectGaps(results []models.TestResult, endpointIDs []string, start, end time.Time, intervals Intervals) []models.DataGap {
+	startMs, endMs := start.UnixMilli(), end.UnixMilli()
+
+	timestamps := make(map[string][]int64)
+	for _, r := range withoutCancelled(results) {
+		if r.Ts >= startMs && r.Ts <= endMs {
+			timestamps[r.Id] = append(timestamps[r.Id], r.Ts)
+		}
+	}
+
+	var gaps []models.DataGap
+	for _, id := range endpointIDs {
+		threshold := int64(float64(intervals.Of(id).Milliseconds()) * GapFactor)
+		ts := timestamps[id]
+		sort.Slice(ts, func(i, j int) bool { return ts[i] < ts[j] })
+
+		// Range boundaries act as virtual results so leading and trailing
+		// silences are reported too
+		last := startMs
+		for _, t := range append(ts, endMs) {
+			if t-last > threshold {
+				gaps = append(gaps, models.DataGap{EndpointID: id, Start: last, End: t})
+			}
+			last = t
+		}
+	}
+	return gaps
+}
+
+// ComputeAvailability returns time-weighted availability per endpoint, where
+// downtime is the duration of detected outages and periods without data are
+// excluded from both downtime and monitored time, so the monitor being off
+// isn't counted against the target.
+func ComputeAvailability(results []models.TestResult, endpointIDs []string, start, end time.Time, intervals Intervals) []models.EndpointAvailability {
+	return ComputeAvailabilityWithHours(results, endpointIDs, start, end, intervals, nil)
+}
+
+// ComputeAvailabilityWithHours is ComputeAvailability that also reports the
+// availability within business hours of the endpoints in schedules
+func ComputeAvailabilityWithHours(results []models.TestResult, endpointIDs []string, start, end time.Time, intervals Intervals, schedules map[string]*Schedule) []models.EndpointAvailability {
+	gaps := DetectGaps(results, endpointIDs, start, end, intervals)
+	gapsByEndpoint := make(map[string][]models.DataGap)
+	for _, g := range gaps {
+		gapsByEndpoint[g.EndpointID] = append(gapsByEndpoint[g.EndpointID], g)
+	}
+
+	outagesByEndpoint := make(map[string][]models.Outage)
+	for _, o := range DetectOutages(results) {
+		outagesByEndpoint[o.EndpointID] = append(outagesByEndpoint[o.EndpointID], o)
+	}
+
+	rangeMs := end.UnixMilli() - start.UnixMilli()
+	availability := make([]models.EndpointAvailability, 0, len(endpointIDs))
+	for _, id := range endpointIDs {
+		var gapMs, downtimeMs int64
+		for _, g := range gapsByEndpoint[id] {
+			gapMs += g.End - g.Start
+		}
+		for _, o := range outagesByEndpoint[id] {
+			oStart, oEnd := max(o.Start, start.UnixMilli()), min(o.End, end.UnixMilli())
+			if oEnd <= oStart {
+				continue
+			}
+			downtimeMs += oEnd - oStart
+			for _, g := range gapsByEndpoint[id] {
+				downtimeMs -= overlap(oStart, oEnd, g.Start, g.End)
+			}
+		}
+
+		a := models.EndpointAvailability{
+			EndpointID:  id,
+			MonitoredMs: rangeMs - gapMs,
+			DowntimeMs:  downtimeMs,
+			GapMs:       gapMs,
+		}
+		if a.MonitoredMs > 0 {
+			a.AvailabilityPercent = float64(a.MonitoredMs-a.DowntimeMs) / float64(a.MonitoredMs) * 100
+		}
+		if s := schedules[id]; s != nil {
+			a.Business = businessAvailability(s, start.UnixMilli(), end.UnixMilli(), gapsByEndpoint[id], outagesByEndpoint[id])
+		}
+		availability = append(availability, a)
+	}
+	return availability
+}
+
+// businessAvailability repeats the availability computation of one endpoint
+// counting only time within business hours
+func businessAvailability(s *Schedule, start, end int64, gaps []models.DataGap, outages []models.Outage) *models.BusinessAvailability {
+	var gapMs, downtimeMs int64
+	for _, g := range gaps {
+		gapMs += s.Overlap(max(g.Start, start), min(g.End, end))
+	}
+	for _, o := range outages {
+		oStart, oEnd := max(o.Start, start), min(o.End, end)
+		downtimeMs += s.Overlap(oStart, oEnd)
+		for _, g := range gaps {
+			downtimeMs -= s.Overlap(max(oStart, g.Start), min(oEnd, g.End))
+		}
+	}
+	b := &models.BusinessAvailability{MonitoredMs: s.Overlap(start, end) - gapMs, DowntimeMs: downtimeMs}
+	if b.MonitoredMs > 0 {
+		b.AvailabilityPercent = float64(b.MonitoredMs-b.DowntimeMs) / float64(b.MonitoredMs) * 100
+	}
+	return b
+}
+
+func overlap(aStart, aEnd, bStart, bEnd int64) int64 {
+	s, e := max(aStart, bStart), min(aEnd, bEnd)
+	if e <= s {
+		return 0
+	}
+	return e - s
+}
+
+// withoutCancelled drops results of tests that were aborted before they could
+// tell anything about the endpoint
+func withoutCancelled(results []models.TestResult) []models.TestResult {
+	filtered := make([]models.TestResult, 0, len(results))
+	for _, r := range results {
+		if r.St != models.TestStatusCancelled {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
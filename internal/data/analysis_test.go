@@ -0,0 +1,115 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestDetectOutages(t *testing.T) {
+	results := []models.TestResult{
+		{Ts: 1000, Id: "a", St: 0},
+		{Ts: 3000, Id: "a", St: 2},
+		{Ts: 2000, Id: "a", St: 1, Trace: "5f3a9c01.1"}, // Out of order on purpose
+		{Ts: 4000, Id: "a", St: 0},
+		{Ts: 5000, Id: "a", St: 2},
+		{Ts: 1500, Id: "b", St: 0},
+		{Ts: 2500, Id: "b", St: 0},
+	}
+
+	outages := DetectOutages(results)
+	if len(outages) != 2 {
+		t.Fatalf("Expected 2 outages, got %d: %+v", len(outages), outages)
+	}
+
+	first := outages[0]
+	if first.EndpointID != "a" || first.Start != 2000 || first.End != 4000 || first.Failures != 2 || first.Ongoing || first.Trace != "5f3a9c01.1" {
+		t.Errorf("Unexpected first outage: %+v", first)
+	}
+
+	second := outages[1]
+	if second.Start != 5000 || second.End != 5000 || !second.Ongoing {
+		t.Errorf("Unexpected second outage: %+v", second)
+	}
+}
+
+func TestDetectGaps(t *testing.T) {
+	start := time.UnixMilli(0)
+	end := time.UnixMilli(100_000)
+	interval := 10 * time.Second
+
+	var results []models.TestResult
+	// Regular results until 30s, silence until 80s, then regular again
+	for _, ts := range []int64{0, 10_000, 20_000, 30_000, 80_000, 90_000, 100_000} {
+		results = append(results, models.TestResult{Ts: ts, Id: "a"})
+	}
+
+	gaps := DetectGaps(results, []string{"a", "missing"}, start, end, Intervals{Default: interval})
+	if len(gaps) != 2 {
+		t.Fatalf("Expected 2 gaps, got %d: %+v", len(gaps), gaps)
+	}
+	if gaps[0] != (models.DataGap{EndpointID: "a", Start: 30_000, End: 80_000}) {
+		t.Errorf("Unexpected gap: %+v", gaps[0])
+	}
+	if gaps[1] != (models.DataGap{EndpointID: "missing", Start: 0, End: 100_000}) {
+		t.Errorf("Expected full range gap for endpoint without data, got %+v", gaps[1])
+	}
+
+	// Tested every minute, 50s without results is no gap
+	slow := Intervals{Default: interval, Endpoints: map[string]time.Duration{"a": time.Minute}}
+	if gaps := DetectGaps(results, []string{"a"}, start, end, slow); len(gaps) != 0 {
+		t.Errorf("Expected no gap at the endpoint's own interval, got %+v", gaps)
+	}
+}
+
+func TestComputeAvailabilityExcludesGaps(t *testing.T) {
+	start := time.UnixMilli(0)
+	end := time.UnixMilli(100_000)
+	interval := 10 * time.Second
+
+	// Fails at 20s, the app is off from 30s to 80s, and it recovers at 90s.
+	// Only the monitored parts of the outage (20-30s and 80-90s) count as downtime.
+	results := []models.TestResult{
+		{Ts: 0, Id: "a", St: 0},
+		{Ts: 10_000, Id: "a", St: 0},
+		{Ts: 20_000, Id: "a", St: 2},
+		{Ts: 30_000, Id: "a", St: 2},
+		{Ts: 80_000, Id: "a", St: 2},
+		{Ts: 90_000, Id: "a", St: 0},
+		{Ts: 100_000, Id: "a", St: 0},
+	}
+
+	av := ComputeAvailability(results, []string{"a"}, start, end, Intervals{Default: interval})
+	if len(av) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(av))
+	}
+	if av[0].GapMs != 50_000 || av[0].MonitoredMs != 50_000 || av[0].DowntimeMs != 20_000 {
+		t.Errorf("Unexpected availability: %+v", av[0])
+	}
+	if av[0].AvailabilityPercent != 60 {
+		t.Errorf("Expected 60%% availability, got %f", av[0].AvailabilityPercent)
+	}
+}
+
+func TestComputeAvailabilityIgnoresCancelled(t *testing.T) {
+	start := time.UnixMilli(0)
+	end := time.UnixMilli(30_000)
+	interval := 10 * time.Second
+
+	// A test aborted at shutdown must not open an outage
+	results := []models.TestResult{
+		{Ts: 0, Id: "a", St: 0},
+		{Ts: 10_000, Id: "a", St: models.TestStatusCancelled},
+		{Ts: 20_000, Id: "a", St: 0},
+		{Ts: 30_000, Id: "a", St: 0},
+	}
+
+	if outages := DetectOutages(results); len(outages) != 0 {
+		t.Errorf("Expected no outages, got %+v", outages)
+	}
+	av := ComputeAvailability(results, []string{"a"}, start, end, Intervals{Default: interval})
+	if av[0].DowntimeMs != 0 || av[0].AvailabilityPercent != 100 {
+		t.Errorf("Unexpected availability: %+v", av[0])
+	}
+}
@@ -0,0 +1,70 @@
+package data
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// MaxAnnotations caps the annotations file
+const MaxAnnotations = 5000
+
+func (s *Storage) annotationsPath() string {
+	return filepath.Join(s.DataDir, "annotations.json")
+}
+
+// SaveAnnotation appends an annotation, dropping the oldest ones once
+// MaxAnnotations is reached
+func (s *Storage) SaveAnnotation(a models.Annotation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	annotations, _ := s.readAnnotations()
+	annotations = append(annotations, a)
+	if len(annotations) > MaxAnnotations {
+		annotations = annotations[len(annotations)-MaxAnnotations:]
+	}
+
+	data, err := json.Marshal(annotations)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.annotationsPath(), data, 0644)
+}
+
+// GetAnnotations returns the annotations between start and end, oldest first
+func (s *Storage) GetAnnotations(start, end time.Time) ([]models.Annotation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	annotations, err := s.readAnnotations()
+	if err != nil {
+		return nil, err
+	}
+	filtered := []models.Annotation{}
+	for _, a := range annotations {
+		if a.Ts >= start.UnixMilli() && a.Ts <= end.UnixMilli() {
+			filtered = append(filtered, a)
+		}
+	}
+	return filtered, nil
+}
+
+func (s *Storage) readAnnotations() ([]models.Annotation, error) {
+	data, err := os.ReadFile(s.annotationsPath())
+	if os.IsNotExist(err) {
+		return []models.Annotation{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var annotations []models.Annotation
+	if err := json.Unmarshal(data, &annotations); err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
@@ -0,0 +1,102 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// Performance budgets for these benchmarks are listed in the README. Run
+// them with `make bench` before and after changes to storage or aggregation.
+
+// benchResults generates a day of results for the endpoints at the interval,
+// with latencies following a daily cycle and about 1% of tests failing
+func benchResults(endpoints int, day time.Time, interval time.Duration) []models.TestResult {
+	rng := rand.New(rand.NewSource(1))
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	var results []models.TestResult
+	for ts := start; ts.Before(start.Add(24 * time.Hour)); ts = ts.Add(interval) {
+		hour := float64(ts.Hour()) + float64(ts.Minute())/60
+		diurnal := 1 + 0.5*math.Sin((hour-8)/24*2*math.Pi)
+		for e := 0; e < endpoints; e++ {
+			r := models.TestResult{
+				Ts: ts.UnixMilli() + int64(e),
+				Id: fmt.Sprintf("ep%03d", e),
+				Ms: int64((10 + float64(e%20)*5) * diurnal * (0.8 + rng.ExpFloat64()*0.4)),
+			}
+			if rng.Float64() < 0.01 {
+				r.St, r.Ek, r.Ms = models.TestStatusTimeout, models.ErrorKindTimeout, 0
+			}
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+// BenchmarkStoreTestResult saves a result into a day file that already holds
+// a day of results for 10 endpoints tested every 5 minutes
+func BenchmarkStoreTestResult(b *testing.B) {
+	s := NewStorage(b.TempDir())
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)
+	for _, r := range benchResults(10, day, 5*time.Minute) {
+		if err := s.SaveResult(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	r := models.TestResult{Ts: day.Add(23 * time.Hour).UnixMilli(), Id: "ep000", Ms: 12}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.SaveResult(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkAggregateDaily aggregates a day of results for 50 endpoints
+// tested every 30 seconds in hourly buckets
+func BenchmarkAggregateDaily(b *testing.B) {
+	results := benchResults(50, time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local), 30*time.Second)
+	b.ReportMetric(float64(len(results)), "results/op")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if agg := Aggregate(results, time.Hour); len(agg) != 50*24 {
+			b.Fatalf("Unexpected bucket count %d", len(agg))
+		}
+	}
+}
+
+// BenchmarkGetResultsForRange reads a week of daily files for 50 endpoints
+// tested every minute
+func BenchmarkGetResultsForRange(b *testing.B) {
+	s := NewStorage(b.TempDir())
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)
+	for d := 0; d < 7; d++ {
+		writeDay(b, s, benchResults(50, start.AddDate(0, 0, d), time.Minute))
+	}
+	end := start.AddDate(0, 0, 7).Add(-time.Millisecond)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.GetResultsForRange(start, end); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// writeDay writes a day file in one go instead of a result at a time
+func writeDay(b *testing.B, s *Storage, results []models.TestResult) {
+	b.Helper()
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := os.WriteFile(s.GetDailyFilePath(time.UnixMilli(results[0].Ts)), data, 0644); err != nil {
+		b.Fatal(err)
+	}
+}
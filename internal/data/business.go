@@ -0,0 +1,101 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// weekdays maps the day names of business hours to time.Weekday
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Schedule is validated BusinessHours in a time zone, answering which
+// instants they cover
+type Schedule struct {
+	loc        *time.Location
+	days       [7]bool
+	start, end time.Duration // Since local midnight
+	holidays   map[string]bool
+}
+
+// NewSchedule validates business hours and places them in loc
+func NewSchedule(bh models.BusinessHours, loc *time.Location) (*Schedule, error) {
+	s := &Schedule{loc: loc, holidays: make(map[string]bool)}
+	if len(bh.Days) == 0 {
+		for d := time.Monday; d <= time.Friday; d++ {
+			s.days[d] = true
+		}
+	}
+	for _, name := range bh.Days {
+		d, ok := weekdays[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", name)
+		}
+		s.days[d] = true
+	}
+	var err error
+	if s.start, err = timeOfDay(bh.Start); err != nil {
+		return nil, err
+	}
+	if s.end, err = timeOfDay(bh.End); err != nil {
+		return nil, err
+	}
+	if s.end <= s.start {
+		return nil, fmt.Errorf("business hours end %s is not after start %s", bh.End, bh.Start)
+	}
+	for _, h := range bh.Holidays {
+		if _, err := time.Parse(time.DateOnly, h); err != nil {
+			return nil, fmt.Errorf("invalid holiday %q", h)
+		}
+		s.holidays[h] = true
+	}
+	return s, nil
+}
+
+// timeOfDay parses "15:04", or "24:00" for the end of the day
+func timeOfDay(v string) (time.Duration, error) {
+	if v == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", v)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether ts, in UnixMilli, is within business hours
+func (s *Schedule) Contains(ts int64) bool {
+	return s.Overlap(ts, ts+1) == 1
+}
+
+// Overlap returns how many milliseconds of [start, end), in UnixMilli, are
+// within business hours. Hours are placed on the local clock, so a day with
+// a daylight saving change still covers them from start to end.
+func (s *Schedule) Overlap(start, end int64) int64 {
+	if end <= start {
+		return 0
+	}
+	var total int64
+	first := time.UnixMilli(start).In(s.loc)
+	day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+	for ; ; day = day.AddDate(0, 0, 1) {
+		open := s.at(day, s.start)
+		if open >= end {
+			return total
+		}
+		if !s.days[day.Weekday()] || s.holidays[day.Format(time.DateOnly)] {
+			continue
+		}
+		total += overlap(start, end, open, s.at(day, s.end))
+	}
+}
+
+// at returns the UnixMilli of the time of day d on the civil date of day
+func (s *Schedule) at(day time.Time, d time.Duration) int64 {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(d/time.Hour), int(d%time.Hour/time.Minute), 0, 0, s.loc).UnixMilli()
+}
@@ -0,0 +1,72 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestSchedule(t *testing.T) {
+	for _, bh := range []models.BusinessHours{
+		{Start: "09:00", End: "25:00"},
+		{Start: "09:00", End: "09:00"},
+		{Days: []string{"Mon"}, Start: "09:00", End: "17:00"},
+		{Start: "09:00", End: "17:00", Holidays: []string{"25/12/2026"}},
+	} {
+		if _, err := NewSchedule(bh, time.UTC); err == nil {
+			t.Errorf("Expected %+v to be rejected", bh)
+		}
+	}
+
+	lisbon, _ := time.LoadLocation("Europe/Lisbon")
+	s, err := NewSchedule(models.BusinessHours{Start: "09:00", End: "17:00", Holidays: []string{"2026-10-05"}}, lisbon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour int) int64 { return time.Date(2026, 10, day, hour, 0, 0, 0, lisbon).UnixMilli() }
+	// Friday 2 October 2026, then the weekend, a holiday Monday and Tuesday
+	if !s.Contains(at(2, 9)) || s.Contains(at(2, 17)) || s.Contains(at(2, 8)) || s.Contains(at(3, 12)) || s.Contains(at(5, 12)) {
+		t.Error("Unexpected business hours on Friday, Saturday or the holiday")
+	}
+	if got, want := s.Overlap(at(2, 12), at(6, 10)), (5*time.Hour).Milliseconds()+time.Hour.Milliseconds(); got != want {
+		t.Errorf("Expected %d ms from Friday noon to Tuesday 10:00, got %d", want, got)
+	}
+	// Sunday 25 October 2026 is 25 hours long in Lisbon, Monday still opens at 09:00 local
+	if got := s.Overlap(at(23, 0), at(27, 0)); got != (16 * time.Hour).Milliseconds() {
+		t.Errorf("Expected two 8 hour days across the DST change, got %v", time.Duration(got)*time.Millisecond)
+	}
+	if s.Contains(time.Date(2026, 10, 26, 8, 30, 0, 0, time.UTC).UnixMilli()) {
+		t.Error("Expected 08:30 UTC to be before opening at 09:00 in Lisbon after the change")
+	}
+}
+
+func TestBusinessAvailability(t *testing.T) {
+	s, err := NewSchedule(models.BusinessHours{Days: []string{"thu"}, Start: "09:00", End: "17:00"}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Thursday 1 January 1970, tests every hour with an outage from 20:00,
+	// outside business hours, to 22:00
+	var results []models.TestResult
+	for h := range 24 {
+		st := models.TestStatusSuccess
+		if h == 20 || h == 21 {
+			st = models.TestStatusTimeout
+		}
+		results = append(results, models.TestResult{Ts: int64(h) * time.Hour.Milliseconds(), Id: "a", St: st})
+	}
+	start, end := time.UnixMilli(0), time.UnixMilli(24*time.Hour.Milliseconds())
+	av := ComputeAvailabilityWithHours(results, []string{"a", "b"}, start, end, Intervals{Default: time.Hour}, map[string]*Schedule{"a": s})
+	if av[0].AvailabilityPercent >= 100 || av[0].Business == nil || av[0].Business.AvailabilityPercent != 100 || av[0].Business.MonitoredMs != (8*time.Hour).Milliseconds() {
+		t.Errorf("Expected the outage to count 24/7 only, got %+v, %+v", av[0], av[0].Business)
+	}
+	if av[1].Business != nil {
+		t.Errorf("Expected no business availability without business hours, got %+v", av[1].Business)
+	}
+
+	aggs := AggregateLocal(results, 24*time.Hour, nil, map[string]*Schedule{"a": s})
+	if len(aggs) != 1 || aggs[0].Count != 24 || aggs[0].Failures != 2 || aggs[0].BusinessCount != 8 || aggs[0].BusinessFailures != 0 {
+		t.Errorf("Unexpected aggregate: %+v", aggs)
+	}
+}
@@ -0,0 +1,68 @@
+package data
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// MaxCycleReports caps the scheduler history file, about a week of cycles at
+// the default 5 minute interval
+const MaxCycleReports = 2000
+
+func (s *Storage) cycleHistoryPath() string {
+	return filepath.Join(s.DataDir, "scheduler-history.json")
+}
+
+// SaveCycleReport appends a scheduler cycle summary, dropping the oldest
+// entries once MaxCycleReports is reached
+func (s *Storage) SaveCycleReport(report models.CycleReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reports, _ := s.readCycleReports()
+	reports = append(reports, report)
+	if len(reports) > MaxCycleReports {
+		reports = reports[len(reports)-MaxCycleReports:]
+	}
+
+	data, err := json.Marshal(reports)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.cycleHistoryPath(), data, 0644)
+}
+
+// GetCycleReports returns the most recent cycle summaries, oldest first.
+// A limit of 0 returns the whole history.
+func (s *Storage) GetCycleReports(limit int) ([]models.CycleReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reports, err := s.readCycleReports()
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(reports) > limit {
+		reports = reports[len(reports)-limit:]
+	}
+	return reports, nil
+}
+
+func (s *Storage) readCycleReports() ([]models.CycleReport, error) {
+	data, err := os.ReadFile(s.cycleHistoryPath())
+	if os.IsNotExist(err) {
+		return []models.CycleReport{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []models.CycleReport
+	if err := json.Unmarshal(data, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
@@ -0,0 +1,75 @@
+package data
+
+import (
+	"math"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+const (
+	// MaxLatencyBins bounds the bins of a distribution. Narrower buckets are
+	// widened to fit.
+	MaxLatencyBins = 1000
+
+	// autoLatencyBins is about how many bins an automatic bucket size gives
+	autoLatencyBins = 30
+
+	// minBucketMs is the narrowest bucket, the resolution of stored latencies
+	minBucketMs = 0.001
+)
+
+// LatencyDistribution bins the latencies of the successful tests of endpoint
+// id in [start, end] into buckets of bucketMs. Zero picks a bucket size from
+// the 1-2-5 series giving about 30 bins. Results of other endpoints are
+// ignored. The caller sets the window.
+func LatencyDistribution(results []models.TestResult, id string, start, end time.Time, bucketMs float64) models.LatencyDistribution {
+	startMs, endMs := start.UnixMilli(), end.UnixMilli()
+	var latencies []float64
+	var slowest float64
+	for _, r := range results {
+		if r.Id != id || r.St != models.TestStatusSuccess || r.Ts < startMs || r.Ts > endMs {
+			continue
+		}
+		latency := r.LatencyMs()
+		latencies = append(latencies, latency)
+		slowest = max(slowest, latency)
+	}
+
+	if bucketMs <= 0 {
+		bucketMs = niceBucket(slowest / autoLatencyBins)
+	}
+	bucketMs = max(bucketMs, minBucketMs)
+	if math.Floor(slowest/bucketMs) >= MaxLatencyBins {
+		bucketMs = niceBucket(slowest / (MaxLatencyBins - 1))
+	}
+
+	dist := models.LatencyDistribution{EndpointID: id, BucketMs: bucketMs, Tests: len(latencies)}
+	if len(latencies) == 0 {
+		return dist
+	}
+	dist.Bins = make([]models.LatencyBin, int(slowest/bucketMs)+1)
+	for i := range dist.Bins {
+		dist.Bins[i].FromMs = float64(i) * bucketMs
+		dist.Bins[i].ToMs = float64(i+1) * bucketMs
+	}
+	for _, latency := range latencies {
+		dist.Bins[int(latency/bucketMs)].Count++
+	}
+	return dist
+}
+
+// niceBucket rounds ms up to the 1-2-5 series, e.g. 3.4 to 5 and 0.13 to 0.2
+func niceBucket(ms float64) float64 {
+	if ms <= minBucketMs {
+		return minBucketMs
+	}
+	scale := math.Pow(10, math.Floor(math.Log10(ms)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		// Tolerate rounding so 2 doesn't round up to 5
+		if ms <= step*scale*(1+1e-9) {
+			return step * scale
+		}
+	}
+	return 10 * scale
+}
@@ -0,0 +1,52 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestLatencyDistribution(t *testing.T) {
+	start := time.UnixMilli(0)
+	end := time.UnixMilli(100_000)
+	results := []models.TestResult{
+		{Ts: 1000, Id: "a", Ms: 3},
+		{Ts: 2000, Id: "a", Ms: 12, Us: 12_400},
+		{Ts: 3000, Id: "a", Ms: 14},
+		{Ts: 4000, Id: "a", Ms: 47},
+		{Ts: 5000, Id: "a", Ms: 900, St: models.TestStatusTimeout}, // Failures aren't binned
+		{Ts: 6000, Id: "b", Ms: 500},                               // Other endpoint
+		{Ts: 200_000, Id: "a", Ms: 500},                            // Outside the window
+	}
+
+	dist := LatencyDistribution(results, "a", start, end, 10)
+	if dist.Tests != 4 || dist.BucketMs != 10 || len(dist.Bins) != 5 {
+		t.Fatalf("Expected 4 tests in 5 bins of 10 ms, got %+v", dist)
+	}
+	counts := []int{1, 2, 0, 0, 1}
+	for i, bin := range dist.Bins {
+		if bin.Count != counts[i] || bin.FromMs != float64(10*i) || bin.ToMs != float64(10*(i+1)) {
+			t.Errorf("Unexpected bin %d: %+v", i, bin)
+		}
+	}
+
+	// 47 ms over about 30 bins
+	if auto := LatencyDistribution(results, "a", start, end, 0); auto.BucketMs != 2 || len(auto.Bins) != 24 {
+		t.Errorf("Expected automatic 2 ms bins, got %v ms and %d bins", auto.BucketMs, len(auto.Bins))
+	}
+	if narrow := LatencyDistribution(results, "a", start, end, 0.01); len(narrow.Bins) > MaxLatencyBins || narrow.BucketMs != 0.05 {
+		t.Errorf("Expected narrow bins to be widened to 0.05 ms, got %v ms and %d bins", narrow.BucketMs, len(narrow.Bins))
+	}
+	if empty := LatencyDistribution(results, "c", start, end, 10); empty.Tests != 0 || empty.Bins != nil {
+		t.Errorf("Expected no bins without tests, got %+v", empty)
+	}
+}
+
+func TestNiceBucket(t *testing.T) {
+	for ms, want := range map[float64]float64{3.4: 5, 0.13: 0.2, 2: 2, 10: 10, 11: 20, 0: minBucketMs} {
+		if got := niceBucket(ms); got != want {
+			t.Errorf("niceBucket(%v) = %v, want %v", ms, got, want)
+		}
+	}
+}
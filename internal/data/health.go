@@ -0,0 +1,63 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// DefaultHealthWeights weighs availability most, since a down endpoint is
+// worse than a slow one
+var DefaultHealthWeights = models.HealthWeights{Availability: 0.6, Latency: 0.3, Jitter: 0.1}
+
+// ValidateHealthWeights checks that weights are usable for scoring
+func ValidateHealthWeights(w models.HealthWeights) error {
+	if w.Availability < 0 || w.Latency < 0 || w.Jitter < 0 {
+		return fmt.Errorf("health weights can't be negative")
+	}
+	if w.Availability+w.Latency+w.Jitter == 0 {
+		return fmt.Errorf("at least one health weight must be positive")
+	}
+	return nil
+}
+
+// HealthScore rates an aggregate from 0 to 100. Each component scores 100
+// when healthy:
+//   - availability loses 10 points per percent of failed tests
+//   - latency scores the ratio of targetMs to the average, once above it
+//   - jitter, the latency standard deviation, loses points in proportion
+//     to targetMs and scores 0 once it equals it
+//
+// Without a latency target only availability counts.
+func HealthScore(agg models.AggregatedResult, targetMs int, w models.HealthWeights) float64 {
+	if agg.Count == 0 {
+		return 0
+	}
+	availability := 100 * float64(agg.Count-agg.Failures) / float64(agg.Count)
+	availScore := clamp(100-(100-availability)*10, 0, 100)
+	if targetMs <= 0 || agg.Failures == agg.Count {
+		return availScore
+	}
+
+	target := float64(targetMs)
+	latencyScore := 100.0
+	if agg.AvgMs > target {
+		latencyScore = 100 * target / agg.AvgMs
+	}
+	jitterScore := clamp(100-100*agg.StdDevMs/target, 0, 100)
+
+	total := w.Availability + w.Latency + w.Jitter
+	return (availScore*w.Availability + latencyScore*w.Latency + jitterScore*w.Jitter) / total
+}
+
+// ScoreHealth sets the health score of each aggregate, with the latency
+// target of its endpoint from targets
+func ScoreHealth(aggs []models.AggregatedResult, targets map[string]int, w models.HealthWeights) {
+	for i := range aggs {
+		aggs[i].Health = HealthScore(aggs[i], targets[aggs[i].EndpointID], w)
+	}
+}
+
+func clamp(x, lo, hi float64) float64 {
+	return min(max(x, lo), hi)
+}
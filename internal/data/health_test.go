@@ -0,0 +1,47 @@
+package data
+
+import (
+	"math"
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestHealthScore(t *testing.T) {
+	w := DefaultHealthWeights
+	tests := []struct {
+		name   string
+		agg    models.AggregatedResult
+		target int
+		want   float64
+	}{
+		{"healthy", models.AggregatedResult{Count: 100, AvgMs: 20, StdDevMs: 0}, 50, 100},
+		{"no tests", models.AggregatedResult{}, 50, 0},
+		{"all failed", models.AggregatedResult{Count: 10, Failures: 10}, 50, 0},
+		{"no target", models.AggregatedResult{Count: 100, Failures: 1, AvgMs: 500}, 0, 90},
+		// 99% available scores 90, twice the target 50, jitter half the target 50
+		{"degraded", models.AggregatedResult{Count: 100, Failures: 1, AvgMs: 100, StdDevMs: 25}, 50, 90*0.6 + 50*0.3 + 50*0.1},
+	}
+	for _, tt := range tests {
+		if got := HealthScore(tt.agg, tt.target, w); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Only the ratio of weights matters
+	agg := tests[4].agg
+	if a, b := HealthScore(agg, 50, w), HealthScore(agg, 50, models.HealthWeights{Availability: 6, Latency: 3, Jitter: 1}); math.Abs(a-b) > 1e-9 {
+		t.Errorf("scaled weights changed the score: %v != %v", a, b)
+	}
+}
+
+func TestValidateHealthWeights(t *testing.T) {
+	if err := ValidateHealthWeights(DefaultHealthWeights); err != nil {
+		t.Error(err)
+	}
+	for _, w := range []models.HealthWeights{{}, {Availability: -1, Latency: 2}} {
+		if ValidateHealthWeights(w) == nil {
+			t.Errorf("accepted %+v", w)
+		}
+	}
+}
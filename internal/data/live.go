@@ -0,0 +1,110 @@
+package data
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// Live keeps the aggregates of the current hour and day up to date as
+// results are stored, so dashboards can show the current period without
+// reading the day's file. Hours are aligned like Aggregate buckets, days
+// follow the local calendar like the daily files.
+type Live struct {
+	mu        sync.Mutex
+	hourStart int64
+	dayStart  int64
+	hour      map[string]*accumulator
+	day       map[string]*accumulator
+	// firstAdded is the timestamp of the first result added, 0 before that
+	firstAdded int64
+}
+
+func NewLive() *Live {
+	return &Live{hour: make(map[string]*accumulator), day: make(map[string]*accumulator)}
+}
+
+// Add records a stored result. Results from periods before the current one
+// are ignored, a result from a later period starts it.
+func (l *Live) Add(r models.TestResult) {
+	if r.St == models.TestStatusCancelled {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.firstAdded == 0 {
+		l.firstAdded = r.Ts
+	}
+	l.addLocked(r)
+}
+
+// Seed adds results stored before the app started, e.g. today's. Results
+// at or after the first one added with Add are skipped, since they were
+// already counted as they were stored.
+func (l *Live) Seed(results []models.TestResult) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, r := range results {
+		if r.St != models.TestStatusCancelled && (l.firstAdded == 0 || r.Ts < l.firstAdded) {
+			l.addLocked(r)
+		}
+	}
+}
+
+func (l *Live) addLocked(r models.TestResult) {
+	hourMs := time.Hour.Milliseconds()
+	hourStart := r.Ts - r.Ts%hourMs
+	t := time.UnixMilli(r.Ts)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	dayStart := day.UnixMilli()
+
+	if hourStart > l.hourStart {
+		l.hourStart = hourStart
+		clear(l.hour)
+	}
+	if dayStart > l.dayStart {
+		l.dayStart = dayStart
+		clear(l.day)
+	}
+	if hourStart == l.hourStart {
+		acc, ok := l.hour[r.Id]
+		if !ok {
+			acc = newAccumulator(r.Id, hourStart, hourStart+hourMs)
+			l.hour[r.Id] = acc
+		}
+		acc.add(r)
+	}
+	if dayStart == l.dayStart {
+		acc, ok := l.day[r.Id]
+		if !ok {
+			acc = newAccumulator(r.Id, dayStart, day.AddDate(0, 0, 1).UnixMilli())
+			l.day[r.Id] = acc
+		}
+		acc.add(r)
+	}
+}
+
+// Hour returns the aggregates of the current hour, sorted by endpoint ID
+func (l *Live) Hour() []models.AggregatedResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return results(l.hour)
+}
+
+// Day returns the aggregates of the current day, sorted by endpoint ID
+func (l *Live) Day() []models.AggregatedResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return results(l.day)
+}
+
+func results(accs map[string]*accumulator) []models.AggregatedResult {
+	aggregated := make([]models.AggregatedResult, 0, len(accs))
+	for _, acc := range accs {
+		aggregated = append(aggregated, acc.result())
+	}
+	sort.Slice(aggregated, func(i, j int) bool { return aggregated[i].EndpointID < aggregated[j].EndpointID })
+	return aggregated
+}
@@ -0,0 +1,76 @@
+package data
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestLive(t *testing.T) {
+	l := NewLive()
+	base := time.Date(2024, 5, 10, 10, 0, 0, 0, time.Local)
+	add := func(offset time.Duration, id string, ms int64, st int) {
+		l.Add(models.TestResult{Ts: base.Add(offset).UnixMilli(), Id: id, Ms: ms, St: st})
+	}
+
+	add(-90*time.Minute, "a", 100, models.TestStatusSuccess) // Earlier hour, same day
+	add(0, "a", 10, models.TestStatusSuccess)
+	add(time.Minute, "a", 20, models.TestStatusSuccess)
+	add(2*time.Minute, "a", 30, models.TestStatusSuccess)
+	add(3*time.Minute, "a", 0, models.TestStatusTimeout)
+	add(4*time.Minute, "a", 0, models.TestStatusCancelled)
+	add(-3*time.Hour, "a", 500, models.TestStatusSuccess) // Older hour, ignored for the hour only
+
+	hour := l.Hour()
+	if len(hour) != 1 {
+		t.Fatalf("Expected one endpoint, got %+v", hour)
+	}
+	h := hour[0]
+	if h.Count != 4 || h.Failures != 1 || h.AvgMs != 20 || h.MinMs != 10 || h.MaxMs != 30 {
+		t.Errorf("Unexpected hour aggregate: %+v", h)
+	}
+	if math.Abs(h.StdDevMs-math.Sqrt(200.0/3)) > 1e-9 {
+		t.Errorf("Unexpected standard deviation: %f", h.StdDevMs)
+	}
+
+	// The live aggregate matches a batch aggregation of the same results
+	batch := Aggregate([]models.TestResult{
+		{Ts: base.UnixMilli(), Id: "a", Ms: 10},
+		{Ts: base.Add(time.Minute).UnixMilli(), Id: "a", Ms: 20},
+		{Ts: base.Add(2 * time.Minute).UnixMilli(), Id: "a", Ms: 30},
+		{Ts: base.Add(3 * time.Minute).UnixMilli(), Id: "a", St: models.TestStatusTimeout},
+	}, time.Hour)
+	if b := batch[0]; b.AvgMs != h.AvgMs || b.StdDevMs != h.StdDevMs || b.P50Ms != h.P50Ms {
+		t.Errorf("Live %+v differs from batch %+v", h, b)
+	}
+
+	if day := l.Day(); len(day) != 1 || day[0].Count != 6 || day[0].MaxMs != 500 {
+		t.Errorf("Unexpected day aggregate: %+v", day)
+	}
+
+	// A result from the next hour starts a new period
+	add(time.Hour, "b", 5, models.TestStatusSuccess)
+	if hour := l.Hour(); len(hour) != 1 || hour[0].EndpointID != "b" {
+		t.Errorf("Expected hour to roll over, got %+v", hour)
+	}
+}
+
+func TestLiveSeed(t *testing.T) {
+	l := NewLive()
+	now := time.Now().Truncate(time.Hour).Add(30 * time.Minute).UnixMilli()
+
+	// A result stored after launch, also found in the file read by warmup
+	live := models.TestResult{Ts: now, Id: "a", Ms: 40}
+	l.Add(live)
+	l.Seed([]models.TestResult{
+		{Ts: now - 60_000, Id: "a", Ms: 20},
+		live,
+	})
+
+	hour := l.Hour()
+	if len(hour) != 1 || hour[0].Count != 2 || hour[0].AvgMs != 30 {
+		t.Errorf("Unexpected seeded aggregate: %+v", hour)
+	}
+}
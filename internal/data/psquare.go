@@ -0,0 +1,119 @@
+package data
+
+import "sort"
+
+// pSquare estimates a quantile of a stream in constant memory with the P²
+// algorithm (Jain and Chlamtac, 1985). It keeps five markers whose heights
+// approximate the minimum, the p/2, p and (1+p)/2 quantiles and the maximum,
+// adjusting them with a piecewise-parabolic fit as samples arrive.
+type pSquare struct {
+	p    float64
+	n    int
+	q    [5]float64 // Marker heights, the first n samples until there are 5
+	pos  [5]float64 // Marker positions, 1-based
+	want [5]float64 // Desired marker positions
+	inc  [5]float64 // Desired position increments per sample
+}
+
+func newPSquare(p float64) pSquare {
+	return pSquare{p: p, inc: [5]float64{0, p / 2, p, (1 + p) / 2, 1}}
+}
+
+// Add records a sample
+func (e *pSquare) Add(x float64) {
+	if e.n < 5 {
+		e.q[e.n] = x
+		e.n++
+		if e.n == 5 {
+			sort.Float64s(e.q[:])
+			e.pos = [5]float64{1, 2, 3, 4, 5}
+			e.want = [5]float64{1, 1 + 2*e.p, 1 + 4*e.p, 3 + 2*e.p, 5}
+		}
+		return
+	}
+	e.n++
+
+	// Find the cell the sample falls in, extending the extremes if needed
+	var k int
+	switch {
+	case x < e.q[0]:
+		e.q[0] = x
+		k = 0
+	case x >= e.q[4]:
+		e.q[4] = x
+		k = 3
+	default:
+		for k = 0; k < 3 && x >= e.q[k+1]; k++ {
+		}
+	}
+	for i := k + 1; i < 5; i++ {
+		e.pos[i]++
+	}
+	for i := range e.want {
+		e.want[i] += e.inc[i]
+	}
+
+	// Move the middle markers that drifted from their desired positions
+	for i := 1; i <= 3; i++ {
+		d := e.want[i] - e.pos[i]
+		if (d >= 1 && e.pos[i+1]-e.pos[i] > 1) || (d <= -1 && e.pos[i-1]-e.pos[i] < -1) {
+			s := 1.0
+			if d < 0 {
+				s = -1
+			}
+			h := e.parabolic(i, s)
+			if e.q[i-1] < h && h < e.q[i+1] {
+				e.q[i] = h
+			} else {
+				e.q[i] = e.linear(i, s)
+			}
+			e.pos[i] += s
+		}
+	}
+}
+
+func (e *pSquare) parabolic(i int, s float64) float64 {
+	return e.q[i] + s/(e.pos[i+1]-e.pos[i-1])*
+		((e.pos[i]-e.pos[i-1]+s)*(e.q[i+1]-e.q[i])/(e.pos[i+1]-e.pos[i])+
+			(e.pos[i+1]-e.pos[i]-s)*(e.q[i]-e.q[i-1])/(e.pos[i]-e.pos[i-1]))
+}
+
+func (e *pSquare) linear(i int, s float64) float64 {
+	j := i + int(s)
+	return e.q[i] + s*(e.q[j]-e.q[i])/(e.pos[j]-e.pos[i])
+}
+
+// Value returns the quantile estimate. Below five samples it is computed
+// exactly, interpolating between the closest ranks.
+func (e *pSquare) Value() float64 {
+	if e.n == 0 {
+		return 0
+	}
+	if e.n >= 5 {
+		return e.q[2]
+	}
+	samples := make([]float64, e.n)
+	copy(samples, e.q[:e.n])
+	sort.Float64s(samples)
+	rank := e.p * float64(e.n-1)
+	lo := int(rank)
+	if lo+1 >= e.n {
+		return samples[lo]
+	}
+	return samples[lo] + (rank-float64(lo))*(samples[lo+1]-samples[lo])
+}
+
+// latencyQuantiles tracks the latency percentiles reported per bucket
+type latencyQuantiles struct {
+	p50, p95, p99 pSquare
+}
+
+func newLatencyQuantiles() *latencyQuantiles {
+	return &latencyQuantiles{p50: newPSquare(0.50), p95: newPSquare(0.95), p99: newPSquare(0.99)}
+}
+
+func (l *latencyQuantiles) Add(ms float64) {
+	l.p50.Add(ms)
+	l.p95.Add(ms)
+	l.p99.Add(ms)
+}
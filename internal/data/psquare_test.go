@@ -0,0 +1,38 @@
+package data
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestPSquare(t *testing.T) {
+	// Exact below five samples
+	e := newPSquare(0.5)
+	for _, x := range []float64{30, 10, 20} {
+		e.Add(x)
+	}
+	if v := e.Value(); v != 20 {
+		t.Errorf("Expected exact median 20, got %f", v)
+	}
+
+	// Latency-like skewed distribution
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]float64, 200_000)
+	for i := range samples {
+		samples[i] = 20 + rng.ExpFloat64()*15
+	}
+	for _, p := range []float64{0.5, 0.95, 0.99} {
+		e := newPSquare(p)
+		for _, x := range samples {
+			e.Add(x)
+		}
+		sorted := append([]float64(nil), samples...)
+		sort.Float64s(sorted)
+		exact := sorted[int(p*float64(len(sorted)-1))]
+		if got := e.Value(); math.Abs(got-exact)/exact > 0.02 {
+			t.Errorf("p%.0f: estimate %f too far from exact %f", p*100, got, exact)
+		}
+	}
+}
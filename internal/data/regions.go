@@ -0,0 +1,81 @@
+package data
+
+import (
+	"sort"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+// RollupRegion returns the status of a region from how many of its endpoints
+// are up and down: operational when none is down, down when none is up and
+// unknown before any was tested
+func RollupRegion(up, down int) string {
+	switch {
+	case up+down == 0:
+		return models.RegionUnknown
+	case down == 0:
+		return models.RegionOperational
+	case up == 0:
+		return models.RegionDown
+	default:
+		return models.RegionDegraded
+	}
+}
+
+// RegionMap summarizes every region of cfg for the map view: its position,
+// the current status from states keyed by endpoint ID, and the availability
+// and p95 latency of its endpoints' results in [start, end). Sorted by name.
+func RegionMap(cfg *models.Configuration, results []models.TestResult, states map[string]models.EndpointState, start, end time.Time, intervals Intervals) []models.RegionMapEntry {
+	var ids []string
+	for _, region := range cfg.Regions {
+		for _, ep := range region.Endpoints {
+			ids = append(ids, network.EndpointID(ep.Address, ep.Type))
+		}
+	}
+	availability := make(map[string]models.EndpointAvailability)
+	for _, av := range ComputeAvailability(results, ids, start, end, intervals) {
+		availability[av.EndpointID] = av
+	}
+	byEndpoint := make(map[string][]models.TestResult)
+	startMs, endMs := start.UnixMilli(), end.UnixMilli()
+	for _, r := range results {
+		if r.St != models.TestStatusCancelled && r.Ts >= startMs && r.Ts < endMs {
+			byEndpoint[r.Id] = append(byEndpoint[r.Id], r)
+		}
+	}
+
+	entries := make([]models.RegionMapEntry, 0, len(cfg.Regions))
+	for name, region := range cfg.Regions {
+		entry := models.RegionMapEntry{
+			Name: name, Location: region.Location, Timezone: region.Timezone,
+			Thresholds: region.Thresholds, Endpoints: len(region.Endpoints),
+		}
+		var monitored, downtime int64
+		acc := newAccumulator(name, startMs, endMs)
+		for _, ep := range region.Endpoints {
+			id := network.EndpointID(ep.Address, ep.Type)
+			monitored += availability[id].MonitoredMs
+			downtime += availability[id].DowntimeMs
+			if state, ok := states[id]; ok {
+				if state.Up {
+					entry.Up++
+				} else {
+					entry.Down++
+				}
+			}
+			for _, r := range byEndpoint[id] {
+				acc.add(r)
+			}
+		}
+		entry.Status = RollupRegion(entry.Up, entry.Down)
+		if monitored > 0 {
+			entry.AvailabilityPercent = float64(monitored-downtime) / float64(monitored) * 100
+		}
+		entry.P95Ms = acc.result().P95Ms
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
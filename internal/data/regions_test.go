@@ -0,0 +1,67 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+func TestRegionMap(t *testing.T) {
+	router := models.Endpoint{Name: "Router", Type: models.TypeICMP, Address: "192.168.1.1"}
+	dns := models.Endpoint{Name: "DNS", Type: models.TypeICMP, Address: "8.8.8.8"}
+	routerID, dnsID := network.EndpointID(router.Address, router.Type), network.EndpointID(dns.Address, dns.Type)
+	cfg := &models.Configuration{Regions: map[string]models.Region{
+		"Office": {Endpoints: []models.Endpoint{router}},
+		"Home": {
+			Endpoints: []models.Endpoint{dns},
+			Location:  &models.GeoPoint{Latitude: -23.55, Longitude: -46.63},
+			Timezone:  "America/Sao_Paulo",
+		},
+	}}
+
+	var results []models.TestResult
+	for i := range 10 {
+		st := models.TestStatusSuccess
+		if i >= 8 {
+			st = models.TestStatusTimeout
+		}
+		results = append(results,
+			models.TestResult{Ts: int64(i) * 10_000, Id: dnsID, St: st, Ms: 20},
+			models.TestResult{Ts: int64(i) * 10_000, Id: routerID, St: models.TestStatusSuccess, Ms: 2},
+		)
+	}
+	states := map[string]models.EndpointState{dnsID: {EndpointID: dnsID, Up: false}}
+
+	entries := RegionMap(cfg, results, states, time.UnixMilli(0), time.UnixMilli(100_000), Intervals{Default: 10 * time.Second})
+	if len(entries) != 2 || entries[0].Name != "Home" || entries[1].Name != "Office" {
+		t.Fatalf("Expected Home and Office sorted by name, got %+v", entries)
+	}
+	home, office := entries[0], entries[1]
+	if home.Location == nil || home.Timezone != "America/Sao_Paulo" || home.Status != models.RegionDown || home.Down != 1 || home.Endpoints != 1 {
+		t.Errorf("Unexpected Home entry: %+v", home)
+	}
+	if home.AvailabilityPercent >= 100 || home.P95Ms != 20 {
+		t.Errorf("Expected Home below 100%% with a p95 of 20 ms, got %+v", home)
+	}
+	if office.Location != nil || office.Status != models.RegionUnknown || office.AvailabilityPercent != 100 || office.P95Ms != 2 {
+		t.Errorf("Unexpected Office entry: %+v", office)
+	}
+}
+
+func TestRollupRegion(t *testing.T) {
+	for _, tc := range []struct {
+		up, down int
+		want     string
+	}{
+		{0, 0, models.RegionUnknown},
+		{2, 0, models.RegionOperational},
+		{1, 1, models.RegionDegraded},
+		{0, 2, models.RegionDown},
+	} {
+		if got := RollupRegion(tc.up, tc.down); got != tc.want {
+			t.Errorf("RollupRegion(%d, %d) = %s, expected %s", tc.up, tc.down, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package data
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// MaxResolutionChanges caps the resolution history file
+const MaxResolutionChanges = 5000
+
+func (s *Storage) resolutionHistoryPath() string {
+	return filepath.Join(s.DataDir, "resolution-history.json")
+}
+
+// SaveResolutionChange appends a change in an endpoint's resolved addresses,
+// dropping the oldest entries once MaxResolutionChanges is reached
+func (s *Storage) SaveResolutionChange(change models.ResolutionChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changes, _ := s.readResolutionChanges()
+	changes = append(changes, change)
+	if len(changes) > MaxResolutionChanges {
+		changes = changes[len(changes)-MaxResolutionChanges:]
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.resolutionHistoryPath(), data, 0644)
+}
+
+// GetResolutionChanges returns the recorded resolution changes of an
+// endpoint, oldest first. An empty endpoint ID returns every change.
+func (s *Storage) GetResolutionChanges(endpointID string) ([]models.ResolutionChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changes, err := s.readResolutionChanges()
+	if err != nil || endpointID == "" {
+		return changes, err
+	}
+	filtered := []models.ResolutionChange{}
+	for _, c := range changes {
+		if c.EndpointID == endpointID {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered, nil
+}
+
+func (s *Storage) readResolutionChanges() ([]models.ResolutionChange, error) {
+	data, err := os.ReadFile(s.resolutionHistoryPath())
+	if os.IsNotExist(err) {
+		return []models.ResolutionChange{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []models.ResolutionChange
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,274 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// Janitor deletes daily result files older than the data_retention_days
+// setting once a day, after the first scheduler cycle of the day. Days under
+// a retention hold are kept forever.
+type Janitor struct {
+	Ctx     context.Context
+	Storage *Storage
+
+	mu  sync.Mutex
+	day string // Last day the cleanup ran
+}
+
+// RunIfDue runs the retention cleanup on the first call of each day
+func (j *Janitor) RunIfDue(now time.Time, cfg *models.Configuration) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	day := now.Format("2006-01-02")
+	if j.day == day {
+		return
+	}
+	j.day = day
+
+	grace := cfg.Settings.CleanupGraceDays
+	removed, err := j.Storage.Cleanup(now, cfg.Settings.DataRetentionDays, cfg.RetentionHolds, grace > 0)
+	if err != nil {
+		log.Ctx(j.Ctx).Error().Err(err).Msg("Failed to remove expired results")
+	}
+	if len(removed) > 0 {
+		log.Ctx(j.Ctx).Info().
+			Strs("files", removed).
+			Int("retention_days", cfg.Settings.DataRetentionDays).
+			Bool("trashed", grace > 0).
+			Msg("Removed expired results")
+	}
+
+	// Files trashed while the grace period was longer still go once it passes
+	purged, err := j.Storage.PurgeTrash(now, time.Duration(grace)*24*time.Hour)
+	if err != nil {
+		log.Ctx(j.Ctx).Error().Err(err).Msg("Failed to empty the results trash")
+	}
+	if purged > 0 {
+		log.Ctx(j.Ctx).Info().Int("files", purged).Msg("Deleted trashed results")
+	}
+}
+
+// Undo restores the files moved to the trash by the most recent cleanup,
+// see Storage.UndoLastCleanup. It never runs along with a cleanup.
+func (j *Janitor) Undo() ([]string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	restored, err := j.Storage.UndoLastCleanup()
+	if len(restored) > 0 {
+		log.Ctx(j.Ctx).Info().Strs("files", restored).Msg("Restored results from the trash")
+	}
+	return restored, err
+}
+
+// ValidateRetentionHold checks that a hold names valid days in order
+func ValidateRetentionHold(h models.RetentionHold) error {
+	from, err := time.Parse("2006-01-02", h.From)
+	if err != nil {
+		return fmt.Errorf("invalid start day %q", h.From)
+	}
+	if h.To == "" {
+		return nil
+	}
+	to, err := time.Parse("2006-01-02", h.To)
+	if err != nil {
+		return fmt.Errorf("invalid end day %q", h.To)
+	}
+	if to.Before(from) {
+		return errors.New("end day is before start day")
+	}
+	return nil
+}
+
+// Held reports whether a day, in YYYY-MM-DD form, is covered by any hold
+func Held(day string, holds []models.RetentionHold) bool {
+	for _, h := range holds {
+		to := h.To
+		if to == "" {
+			to = h.From
+		}
+		// The fixed width format sorts like the dates it encodes
+		if day >= h.From && day <= to {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrNothingToUndo is returned by UndoLastCleanup when the trash is empty
+var ErrNothingToUndo = errors.New("no cleanup to undo")
+
+// Cleanup deletes the daily result files of days more than retentionDays
+// before now, except for held days, and returns the names of the deleted
+// files. A retentionDays of 0 or less keeps everything.
+//
+// With trash set the files are moved instead to a new batch in the trash
+// folder, checked to have arrived intact, and kept there until PurgeTrash
+// deletes them or UndoLastCleanup restores them.
+func (s *Storage) Cleanup(now time.Time, retentionDays int, holds []models.RetentionHold, trash bool) ([]string, error) {
+	if retentionDays <= 0 {
+		return nil, nil
+	}
+	names, err := s.DailyFiles()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := now.AddDate(0, 0, -retentionDays).Format("2006-01-02")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := filepath.Join(s.trashDir(), strconv.FormatInt(now.UnixMilli(), 10))
+	if trash {
+		if err := os.MkdirAll(batch, 0755); err != nil {
+			return nil, err
+		}
+		// Only keep the batch if something was moved into it
+		defer os.Remove(batch)
+	}
+
+	var removed []string
+	var errs []error
+	for _, name := range names {
+		day := strings.TrimSuffix(name, ".json")
+		if day >= cutoff || Held(day, holds) {
+			continue
+		}
+		path := filepath.Join(s.DataDir, name)
+		if trash {
+			err = moveVerified(path, filepath.Join(batch, name))
+		} else if err = os.Remove(path); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		s.dropCachedDay(path)
+		removed = append(removed, name)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// UndoLastCleanup moves the files of the most recent trash batch back into
+// place and returns their names. Files whose day was written again since
+// are left in the trash and reported as errors.
+func (s *Storage) UndoLastCleanup() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batches, err := s.trashBatches()
+	if err != nil {
+		return nil, err
+	}
+	if len(batches) == 0 {
+		return nil, ErrNothingToUndo
+	}
+	batch := filepath.Join(s.trashDir(), batches[len(batches)-1])
+	entries, err := os.ReadDir(batch)
+	if err != nil {
+		return nil, err
+	}
+
+	var restored []string
+	var errs []error
+	for _, e := range entries {
+		path := filepath.Join(s.DataDir, e.Name())
+		if _, err := os.Stat(path); err == nil {
+			errs = append(errs, fmt.Errorf("%s exists, not restored", e.Name()))
+			continue
+		}
+		if err := moveVerified(filepath.Join(batch, e.Name()), path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		restored = append(restored, e.Name())
+	}
+	if len(errs) == 0 {
+		errs = append(errs, os.Remove(batch))
+	}
+	return restored, errors.Join(errs...)
+}
+
+// PurgeTrash permanently deletes trash batches moved there more than grace
+// before now and returns how many files they held
+func (s *Storage) PurgeTrash(now time.Time, grace time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batches, err := s.trashBatches()
+	if err != nil {
+		return 0, err
+	}
+	var purged int
+	var errs []error
+	for _, name := range batches {
+		ms, _ := strconv.ParseInt(name, 10, 64)
+		if now.Sub(time.UnixMilli(ms)) < grace {
+			break
+		}
+		batch := filepath.Join(s.trashDir(), name)
+		entries, _ := os.ReadDir(batch)
+		if err := os.RemoveAll(batch); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		purged += len(entries)
+	}
+	return purged, errors.Join(errs...)
+}
+
+func (s *Storage) trashDir() string {
+	return filepath.Join(s.DataDir, "trash")
+}
+
+// trashBatches returns the names of the trash batches, oldest first
+func (s *Storage) trashBatches() ([]string, error) {
+	entries, err := os.ReadDir(s.trashDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if _, err := strconv.ParseInt(e.Name(), 10, 64); err == nil && e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	// Stamps of the same width sort like numbers, shorter ones are older
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(a), len(b)), strings.Compare(a, b))
+	})
+	return names, nil
+}
+
+// moveVerified renames src to dst and checks that dst has the size src had,
+// moving it back if it doesn't
+func moveVerified(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+	if moved, err := os.Stat(dst); err != nil || moved.Size() != info.Size() {
+		_ = os.Rename(dst, src)
+		return fmt.Errorf("%s did not move intact", filepath.Base(src))
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package data
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestValidateRetentionHold(t *testing.T) {
+	tests := []struct {
+		hold models.RetentionHold
+		ok   bool
+	}{
+		{models.RetentionHold{From: "2025-03-01"}, true},
+		{models.RetentionHold{From: "2025-03-01", To: "2025-03-04"}, true},
+		{models.RetentionHold{From: "2025-03-04", To: "2025-03-01"}, false},
+		{models.RetentionHold{From: "March 1"}, false},
+		{models.RetentionHold{From: "2025-03-01", To: "soon"}, false},
+	}
+	for _, tt := range tests {
+		if err := ValidateRetentionHold(tt.hold); (err == nil) != tt.ok {
+			t.Errorf("%+v: got error %v, want ok=%v", tt.hold, err, tt.ok)
+		}
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	s := NewStorage(t.TempDir())
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.Local)
+	for d := range 20 {
+		day := now.AddDate(0, 0, -d)
+		if err := s.SaveResult(models.TestResult{Ts: day.UnixMilli(), Id: "a"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The oldest day is the cached one, so cleanup must also forget it
+	if _, err := s.GetResultsForDay(now.AddDate(0, 0, -19)); err != nil {
+		t.Fatal(err)
+	}
+
+	holds := []models.RetentionHold{
+		{From: "2025-03-02"},
+		{From: "2025-03-05", To: "2025-03-06", Reason: "ISP outage"},
+	}
+	removed, err := s.Cleanup(now, 14, holds, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2025-03-01.json", "2025-03-03.json", "2025-03-04.json"}
+	if !slices.Equal(removed, want) {
+		t.Errorf("Removed %v, want %v", removed, want)
+	}
+
+	files, _ := s.DailyFiles()
+	if len(files) != 17 || files[0] != "2025-03-02.json" {
+		t.Errorf("Unexpected files left: %v", files)
+	}
+	if results, _ := s.GetResultsForDay(now.AddDate(0, 0, -19)); len(results) != 0 {
+		t.Errorf("Removed day still readable from cache: %+v", results)
+	}
+
+	if removed, _ := s.Cleanup(now, 0, nil, false); len(removed) != 0 {
+		t.Errorf("Retention of 0 days removed %v", removed)
+	}
+}
+
+func TestCleanupTrash(t *testing.T) {
+	s := NewStorage(t.TempDir())
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.Local)
+	for d := range 5 {
+		if err := s.SaveResult(models.TestResult{Ts: now.AddDate(0, 0, -d).UnixMilli(), Id: "a"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := s.Cleanup(now, 3, nil, true)
+	if err != nil || len(first) != 1 {
+		t.Fatalf("First cleanup removed %v: %v", first, err)
+	}
+	later := now.Add(time.Hour)
+	second, err := s.Cleanup(later, 2, nil, true)
+	if err != nil || len(second) != 1 {
+		t.Fatalf("Second cleanup removed %v: %v", second, err)
+	}
+	if files, _ := s.DailyFiles(); len(files) != 3 {
+		t.Errorf("Trashed files still listed: %v", files)
+	}
+
+	// Undo restores the most recent cleanup only
+	restored, err := s.UndoLastCleanup()
+	if err != nil || !slices.Equal(restored, second) {
+		t.Errorf("Restored %v, want %v: %v", restored, second, err)
+	}
+	if results, _ := s.GetResultsForDay(now.AddDate(0, 0, -3)); len(results) != 1 {
+		t.Errorf("Restored day has %d results", len(results))
+	}
+
+	if n, err := s.PurgeTrash(later, 24*time.Hour); err != nil || n != 0 {
+		t.Errorf("Purged %d files within the grace period: %v", n, err)
+	}
+	if n, err := s.PurgeTrash(now.Add(25*time.Hour), 24*time.Hour); err != nil || n != 1 {
+		t.Errorf("Purged %d files after the grace period, want 1: %v", n, err)
+	}
+	if _, err := s.UndoLastCleanup(); err != ErrNothingToUndo {
+		t.Errorf("Expected nothing to undo after purge, got %v", err)
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	s := NewStorage(t.TempDir())
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.Local)
+	old := now.AddDate(0, 0, -10)
+	if err := s.SaveResult(models.TestResult{Ts: old.UnixMilli(), Id: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	j := &Janitor{Ctx: context.Background(), Storage: s}
+	cfg := &models.Configuration{Settings: models.AppSettings{DataRetentionDays: 30, CleanupGraceDays: 7}}
+
+	// Runs once a day, so a shorter retention only applies tomorrow
+	j.RunIfDue(now, cfg)
+	cfg.Settings.DataRetentionDays = 5
+	j.RunIfDue(now.Add(time.Hour), cfg)
+	if files, _ := s.DailyFiles(); len(files) != 1 {
+		t.Errorf("Expected the cleanup to wait for the next day, got %v", files)
+	}
+	j.RunIfDue(now.AddDate(0, 0, 1), cfg)
+	if files, _ := s.DailyFiles(); len(files) != 0 {
+		t.Errorf("Expected the old day to be trashed, got %v", files)
+	}
+
+	if restored, err := j.Undo(); err != nil || len(restored) != 1 {
+		t.Errorf("Expected the day to be restored, got %v, %v", restored, err)
+	}
+}
@@ -0,0 +1,63 @@
+package data
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/generate"
+)
+
+// FuzzStorageRoundTrip checks that day files give back exactly the results
+// written to them
+func FuzzStorageRoundTrip(f *testing.F) {
+	f.Add(int64(1), uint8(1))
+	f.Add(int64(2), uint8(50))
+	f.Fuzz(func(t *testing.T, seed int64, n uint8) {
+		want := generate.ArbitraryResults(rand.New(rand.NewSource(seed)), int(n))
+		dir := t.TempDir()
+		day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)
+		if err := NewStorage(dir).WriteDay(day, want); err != nil {
+			t.Fatal(err)
+		}
+
+		// A new storage reads the file rather than its cache
+		got, err := NewStorage(dir).GetResultsForDay(day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %d results, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if !reflect.DeepEqual(got[i], want[i]) {
+				t.Errorf("result %d changed\n got: %#v\nwant: %#v", i, got[i], want[i])
+			}
+		}
+	})
+}
+
+// TestSaveResultRoundTrip checks appending results one at a time keeps them
+// intact too
+func TestSaveResultRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	dir := t.TempDir()
+	s := NewStorage(dir)
+	ts := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local).UnixMilli()
+	want := generate.ArbitraryResults(rng, 20)
+	for i := range want {
+		want[i].Ts = ts + int64(i)
+		if err := s.SaveResult(want[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := NewStorage(dir).GetResultsForDay(time.UnixMilli(ts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("results changed\n got: %#v\nwant: %#v", got, want)
+	}
+}
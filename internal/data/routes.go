@@ -0,0 +1,70 @@
+package data
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// MaxRouteChanges caps the route history file
+const MaxRouteChanges = 5000
+
+func (s *Storage) routeHistoryPath() string {
+	return filepath.Join(s.DataDir, "route-history.json")
+}
+
+// SaveRouteChange appends a change in an endpoint's traced path, dropping the
+// oldest entries once MaxRouteChanges is reached
+func (s *Storage) SaveRouteChange(change models.RouteChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changes, _ := s.readRouteChanges()
+	changes = append(changes, change)
+	if len(changes) > MaxRouteChanges {
+		changes = changes[len(changes)-MaxRouteChanges:]
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.routeHistoryPath(), data, 0644)
+}
+
+// GetRouteChanges returns the recorded route changes of an endpoint, oldest
+// first. An empty endpoint ID returns every change.
+func (s *Storage) GetRouteChanges(endpointID string) ([]models.RouteChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changes, err := s.readRouteChanges()
+	if err != nil || endpointID == "" {
+		return changes, err
+	}
+	filtered := []models.RouteChange{}
+	for _, c := range changes {
+		if c.EndpointID == endpointID {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered, nil
+}
+
+func (s *Storage) readRouteChanges() ([]models.RouteChange, error) {
+	data, err := os.ReadFile(s.routeHistoryPath())
+	if os.IsNotExist(err) {
+		return []models.RouteChange{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []models.RouteChange
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
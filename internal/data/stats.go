@@ -0,0 +1,249 @@
+package data
+
+import (
+	"cmp"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// dayIndex is what the storage index remembers about a daily file. It is
+// valid while the file keeps the size and modification time it had when it
+// was indexed.
+type dayIndex struct {
+	Size      int64                 `json:"size"`
+	ModTime   int64                 `json:"mod_time"` // UnixNano
+	Endpoints map[string]usageCount `json:"endpoints"`
+}
+
+type usageCount struct {
+	Bytes   int64 `json:"bytes"`
+	Results int   `json:"results"`
+	// Traffic of the endpoint's tests
+	Sent     int64 `json:"sent,omitempty"`
+	Received int64 `json:"received,omitempty"`
+}
+
+func (s *Storage) indexPath() string {
+	return filepath.Join(s.DataDir, "index.json")
+}
+
+// Stats reports the size, result count and test traffic of the stored
+// history, in total and per endpoint and month. Per-day counts are kept in an
+// index file so only days written since the last call are read again.
+func (s *Storage) Stats() (models.StorageStats, error) {
+	names, err := s.DailyFiles()
+	if err != nil {
+		return models.StorageStats{}, err
+	}
+
+	index := s.index(names)
+	stats := models.StorageStats{Files: len(names)}
+	endpoints := make(map[string]usageCount)
+	months := make(map[string]usageCount)
+	for _, name := range names {
+		day, ok := index[name]
+		if !ok {
+			continue // Removed since it was listed
+		}
+
+		month := months[name[:7]]
+		month.Bytes += day.Size
+		for id, c := range day.Endpoints {
+			e := endpoints[id]
+			e.Bytes += c.Bytes
+			e.Results += c.Results
+			e.Sent += c.Sent
+			e.Received += c.Received
+			endpoints[id] = e
+			month.Results += c.Results
+			month.Sent += c.Sent
+			month.Received += c.Received
+			stats.Results += c.Results
+			stats.Sent += c.Sent
+			stats.Received += c.Received
+		}
+		months[name[:7]] = month
+		stats.Bytes += day.Size
+	}
+
+	if len(names) > 0 {
+		stats.Oldest = strings.TrimSuffix(names[0], ".json")
+		stats.Newest = strings.TrimSuffix(names[len(names)-1], ".json")
+	}
+	stats.TrashBytes = dirSize(s.trashDir())
+	stats.Endpoints = usages(endpoints, stats.Bytes)
+	slices.SortStableFunc(stats.Endpoints, func(a, b models.StorageUsage) int { return cmp.Compare(b.Bytes, a.Bytes) })
+	stats.Months = usages(months, stats.Bytes)
+	return stats, nil
+}
+
+// EstimateResults returns how many results of the endpoints, or of all
+// endpoints when ids is empty, are stored for the days from start to end.
+// It uses the stats index instead of reading the results, and counts whole
+// days, so results of the first and last day outside the range are included.
+func (s *Storage) EstimateResults(start, end time.Time, ids []string) int {
+	names, err := s.DailyFiles()
+	if err != nil {
+		return 0
+	}
+	first, last := start.Format("2006-01-02")+".json", end.Format("2006-01-02")+".json"
+	names = slices.DeleteFunc(names, func(name string) bool { return name < first || name > last })
+
+	var total int
+	for _, day := range s.index(names) {
+		for id, c := range day.Endpoints {
+			if len(ids) == 0 || slices.Contains(ids, id) {
+				total += c.Results
+			}
+		}
+	}
+	return total
+}
+
+// Traffic returns the bytes stored tests sent and received on the day of now
+// and in its month, from the stats index
+func (s *Storage) Traffic(now time.Time) (day, month int64) {
+	names, err := s.DailyFiles()
+	if err != nil {
+		return 0, 0
+	}
+	prefix, today := now.Format("2006-01-"), now.Format("2006-01-02")+".json"
+	names = slices.DeleteFunc(names, func(name string) bool { return !strings.HasPrefix(name, prefix) })
+	for name, entry := range s.index(names) {
+		for _, c := range entry.Endpoints {
+			month += c.Sent + c.Received
+			if name == today {
+				day += c.Sent + c.Received
+			}
+		}
+	}
+	return day, month
+}
+
+// index returns the index entries of the named daily files, refreshing those
+// that changed. Entries of other files already in the index are kept.
+func (s *Storage) index(names []string) map[string]dayIndex {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	index := make(map[string]dayIndex)
+	if data, err := os.ReadFile(s.indexPath()); err == nil {
+		_ = json.Unmarshal(data, &index) // A corrupt index is rebuilt
+	}
+
+	entries := make(map[string]dayIndex, len(names))
+	changed := false
+	for _, name := range names {
+		prev := index[name]
+		day, err := s.indexDay(name, prev)
+		if err != nil {
+			continue
+		}
+		entries[name] = day
+		if day.Size != prev.Size || day.ModTime != prev.ModTime {
+			index[name] = day
+			changed = true
+		}
+	}
+	// Forget files removed by cleanups
+	for name := range index {
+		if _, err := os.Stat(filepath.Join(s.DataDir, name)); err != nil {
+			delete(index, name)
+			changed = true
+		}
+	}
+	if changed {
+		if data, err := json.Marshal(index); err == nil {
+			_ = os.WriteFile(s.indexPath(), data, 0644)
+		}
+	}
+	return entries
+}
+
+// indexDay returns the index entry of a daily file, reading the file again
+// only if it changed since prev was recorded
+func (s *Storage) indexDay(name string, prev dayIndex) (dayIndex, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	path := filepath.Join(s.DataDir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return dayIndex{}, err
+	}
+	if prev.Endpoints != nil && prev.Size == info.Size() && prev.ModTime == info.ModTime().UnixNano() {
+		return prev, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return dayIndex{}, err
+	}
+	day := dayIndex{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Endpoints: make(map[string]usageCount)}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return day, nil // Unreadable days still count towards the size
+	}
+	var encoded int64
+	for _, r := range raw {
+		var result struct {
+			Id string `json:"id"`
+			Tx int64  `json:"tx"`
+			Rx int64  `json:"rx"`
+		}
+		if json.Unmarshal(r, &result) != nil {
+			continue
+		}
+		c := day.Endpoints[result.Id]
+		c.Bytes += int64(len(r))
+		c.Results++
+		c.Sent += result.Tx
+		c.Received += result.Rx
+		day.Endpoints[result.Id] = c
+		encoded += int64(len(r))
+	}
+	// Share the whole file, separators and all, in proportion to the
+	// size of each endpoint's results
+	for id, c := range day.Endpoints {
+		if encoded > 0 {
+			c.Bytes = c.Bytes * day.Size / encoded
+		}
+		day.Endpoints[id] = c
+	}
+	return day, nil
+}
+
+// usages turns counts into usages sorted by key, with their share of total
+func usages(counts map[string]usageCount, total int64) []models.StorageUsage {
+	list := make([]models.StorageUsage, 0, len(counts))
+	for key, c := range counts {
+		u := models.StorageUsage{Key: key, Bytes: c.Bytes, Results: c.Results, Sent: c.Sent, Received: c.Received}
+		if total > 0 {
+			u.Percent = float64(c.Bytes) / float64(total) * 100
+		}
+		list = append(list, u)
+	}
+	slices.SortFunc(list, func(a, b models.StorageUsage) int { return strings.Compare(a.Key, b.Key) })
+	return list
+}
+
+// dirSize returns the total size of the files under dir
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
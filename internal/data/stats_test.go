@@ -0,0 +1,125 @@
+package data
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestStats(t *testing.T) {
+	s := NewStorage(t.TempDir())
+	jan := time.Date(2025, 1, 31, 12, 0, 0, 0, time.Local)
+	feb := jan.AddDate(0, 0, 1)
+	for i := range 30 {
+		id := "big"
+		if i%3 == 0 {
+			id = "small"
+		}
+		ts := jan
+		if i >= 20 {
+			ts = feb
+		}
+		if err := s.SaveResult(models.TestResult{Ts: ts.Add(time.Duration(i) * time.Second).UnixMilli(), Id: id, Ms: 10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 2 || stats.Results != 30 || stats.Oldest != "2025-01-31" || stats.Newest != "2025-02-01" {
+		t.Errorf("Unexpected totals: %+v", stats)
+	}
+	if len(stats.Endpoints) != 2 || stats.Endpoints[0].Key != "big" || stats.Endpoints[0].Results != 20 {
+		t.Errorf("Expected the biggest endpoint first: %+v", stats.Endpoints)
+	}
+	if len(stats.Months) != 2 || stats.Months[0].Key != "2025-01" || stats.Months[0].Results != 20 {
+		t.Errorf("Unexpected months: %+v", stats.Months)
+	}
+	var endpointPct, monthPct float64
+	for _, u := range stats.Endpoints {
+		endpointPct += u.Percent
+	}
+	for _, u := range stats.Months {
+		monthPct += u.Percent
+	}
+	// Endpoint shares are rounded down to whole bytes
+	if math.Abs(endpointPct-100) > 0.1 || math.Abs(monthPct-100) > 1e-9 {
+		t.Errorf("Shares should add up to 100%%, got %.2f and %.2f", endpointPct, monthPct)
+	}
+
+	if _, err := os.Stat(s.indexPath()); err != nil {
+		t.Errorf("Index not written: %v", err)
+	}
+
+	// Days written since the last call are counted again
+	if err := s.SaveResult(models.TestResult{Ts: feb.UnixMilli(), Id: "small"}); err != nil {
+		t.Fatal(err)
+	}
+	stats, _ = s.Stats()
+	if stats.Results != 31 {
+		t.Errorf("Expected the new result to be counted, got %d", stats.Results)
+	}
+}
+
+func TestStatsTraffic(t *testing.T) {
+	s := NewStorage(t.TempDir())
+	ts := time.Date(2025, 3, 1, 12, 0, 0, 0, time.Local).UnixMilli()
+	for _, r := range []models.TestResult{
+		{Ts: ts, Id: "http", Tx: 500, Rx: 4000},
+		{Ts: ts + 1, Id: "http", Tx: 500, Rx: 4000},
+		{Ts: ts + 2, Id: "icmp", Tx: 32, Rx: 32},
+		{Ts: ts + 3, Id: "icmp"}, // Stored before traffic was counted
+	} {
+		if err := s.SaveResult(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sent != 1032 || stats.Received != 8032 {
+		t.Errorf("Unexpected total traffic: sent %d, received %d", stats.Sent, stats.Received)
+	}
+	for _, u := range stats.Endpoints {
+		if u.Key == "http" && (u.Sent != 1000 || u.Received != 8000) {
+			t.Errorf("Unexpected traffic of %s: %+v", u.Key, u)
+		}
+	}
+	if len(stats.Months) != 1 || stats.Months[0].Sent != 1032 {
+		t.Errorf("Unexpected months: %+v", stats.Months)
+	}
+}
+
+func TestEstimateResults(t *testing.T) {
+	s := NewStorage(t.TempDir())
+	day := time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local)
+	for d := range 3 {
+		for _, id := range []string{"a", "a", "b"} {
+			if err := s.SaveResult(models.TestResult{Ts: day.AddDate(0, 0, d).UnixMilli(), Id: id}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if n := s.EstimateResults(day, day.AddDate(0, 0, 1), nil); n != 6 {
+		t.Errorf("Expected 6 results in two days, got %d", n)
+	}
+	if n := s.EstimateResults(day, day.AddDate(0, 0, 5), []string{"a"}); n != 6 {
+		t.Errorf("Expected 6 results of a, got %d", n)
+	}
+
+	// Removed days drop out of the index
+	if err := os.Remove(s.GetDailyFilePath(day)); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.EstimateResults(day, day.AddDate(0, 0, 5), nil); n != 6 {
+		t.Errorf("Expected 6 results after removing a day, got %d", n)
+	}
+}
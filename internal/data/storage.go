@@ -1,25 +1,45 @@
 package data
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/rs/zerolog/log"
 )
 
 type Storage struct {
 	DataDir string
-	mu      sync.Mutex
+	// Ctx carries the logger of the storage component
+	Ctx context.Context
+	// mu serializes writes, reads of different days run in parallel
+	mu sync.RWMutex
+	// indexMu serializes updates of the stats index, see Stats
+	indexMu sync.Mutex
+
+	// The decoded results of the day written last, usually today, so saving
+	// a result or refreshing the dashboard doesn't decode the file again
+	cacheMu   sync.Mutex
+	cachePath string
+	cached    []models.TestResult
 }
 
+// maxReadWorkers bounds the daily files read at the same time
+const maxReadWorkers = 8
+
 func NewStorage(dataDir string) *Storage {
 	_ = os.MkdirAll(dataDir, 0755)
 	return &Storage{
 		DataDir: dataDir,
+		Ctx:     context.Background(),
 	}
 }
 
@@ -48,13 +68,15 @@ func (s *Storage) SaveResult(result models.TestResult) error {
 	// Let's implementation: Read existing, Append, Write. Max file size won't be huge (3 months limit elsewhere, but daily file size depends on interval).
 	// Interval 5 mins * 12 endpoints * 24 hours * 12 checks/hour = 3456 entries. Tiny.
 
-	var results []models.TestResult
+	results, ok := s.cachedDay(filepath)
 
 	// Read existing
-	if _, err := os.Stat(filepath); err == nil {
-		data, err := os.ReadFile(filepath)
-		if err == nil {
-			_ = json.Unmarshal(data, &results)
+	if !ok {
+		if _, err := os.Stat(filepath); err == nil {
+			data, err := os.ReadFile(filepath)
+			if err == nil {
+				_ = json.Unmarshal(data, &results)
+			}
 		}
 	}
 
@@ -65,15 +87,66 @@ func (s *Storage) SaveResult(result models.TestResult) error {
 		return err
 	}
 
-	return os.WriteFile(filepath, data, 0644)
+	if err := os.WriteFile(filepath, data, 0644); err != nil {
+		return err
+	}
+	s.setCachedDay(filepath, results)
+	return nil
 }
 
-// GetResultsForDay retrieves all results for a specific day
-func (s *Storage) GetResultsForDay(date time.Time) ([]models.TestResult, error) {
+// WriteDay replaces the day's file with the results, e.g. generated ones
+func (s *Storage) WriteDay(date time.Time, results []models.TestResult) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	path := s.GetDailyFilePath(date)
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	if _, ok := s.cachedDay(path); ok {
+		s.setCachedDay(path, slices.Clone(results))
+	}
+	return nil
+}
+
+func (s *Storage) cachedDay(path string) ([]models.TestResult, bool) {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+	if s.cachePath != path {
+		return nil, false
+	}
+	return s.cached, true
+}
+
+func (s *Storage) setCachedDay(path string, results []models.TestResult) {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+	s.cachePath, s.cached = path, results
+}
+
+// dropCachedDay forgets the cached results if they belong to path
+func (s *Storage) dropCachedDay(path string) {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+	if s.cachePath == path {
+		s.cachePath, s.cached = "", nil
+	}
+}
+
+// GetResultsForDay retrieves all results for a specific day
+func (s *Storage) GetResultsForDay(date time.Time) ([]models.TestResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	filepath := s.GetDailyFilePath(date)
+	// Callers may modify the results, never hand out the cached slice
+	if cached, ok := s.cachedDay(filepath); ok {
+		return slices.Clone(cached), nil
+	}
 	if _, err := os.Stat(filepath); os.IsNotExist(err) {
 		return []models.TestResult{}, nil
 	}
@@ -88,28 +161,83 @@ func (s *Storage) GetResultsForDay(date time.Time) ([]models.TestResult, error)
 		return nil, err
 	}
 
+	// Today's file is read again on every refresh until a result is saved
+	if filepath == s.GetDailyFilePath(time.Now()) {
+		s.setCachedDay(filepath, slices.Clone(results))
+	}
 	return results, nil
 }
 
-// GetResultsForRange retrieves results between start and end time
+// GetResultsForRange retrieves results between start and end time. Days
+// are read and decoded in parallel, the results are in day order.
 func (s *Storage) GetResultsForRange(start, end time.Time) ([]models.TestResult, error) {
-	// Identify all days in range
+	began := time.Now()
+	// Identify all days in range, normalized to start of day
+	var days []time.Time
+	for current := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location()); !current.After(end); current = current.AddDate(0, 0, 1) {
+		days = append(days, current)
+	}
+
+	perDay := make([][]models.TestResult, len(days))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(len(days), runtime.GOMAXPROCS(0), maxReadWorkers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				dayResults, err := s.GetResultsForDay(days[i])
+				if err != nil {
+					log.Ctx(s.Ctx).Warn().Err(err).Str("day", days[i].Format("2006-01-02")).Msg("Skipped unreadable daily results")
+				}
+				kept := dayResults[:0]
+				for _, r := range dayResults {
+					rTime := time.UnixMilli(r.Ts)
+					if !rTime.Before(start) && !rTime.After(end) {
+						kept = append(kept, r)
+					}
+				}
+				perDay[i] = kept
+			}
+		}()
+	}
+	for i := range days {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
 	var allResults []models.TestResult
+	for _, dayResults := range perDay {
+		allResults = append(allResults, dayResults...)
+	}
+	log.Ctx(s.Ctx).Debug().Int("days", len(days)).Int("results", len(allResults)).Dur("duration", time.Since(began)).Msg("Results read")
+	return allResults, nil
+}
 
-	current := start
-	// Normalize to start of day
-	current = time.Date(current.Year(), current.Month(), current.Day(), 0, 0, 0, 0, current.Location())
+// DailyFiles returns the names of the daily result files, oldest first
+func (s *Storage) DailyFiles() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	for !current.After(end) {
-		dayResults, _ := s.GetResultsForDay(current)
-		for _, r := range dayResults {
-			rTime := time.UnixMilli(r.Ts)
-			if (rTime.Equal(start) || rTime.After(start)) && (rTime.Equal(end) || rTime.Before(end)) {
-				allResults = append(allResults, r)
-			}
+	entries, err := os.ReadDir(s.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		day, ok := strings.CutSuffix(e.Name(), ".json")
+		if _, err := time.Parse("2006-01-02", day); ok && err == nil && !e.IsDir() {
+			names = append(names, e.Name())
 		}
-		current = current.AddDate(0, 0, 1)
 	}
+	return names, nil
+}
 
-	return allResults, nil
+// ReadDailyFile returns the raw content of a daily result file, consistent
+// with concurrent writes
+func (s *Storage) ReadDailyFile(name string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return os.ReadFile(filepath.Join(s.DataDir, filepath.Base(name)))
 }
@@ -62,3 +62,115 @@ func TestStorage(t *testing.T) {
 		t.Errorf("Expected 2 results, got %d", len(results))
 	}
 }
+
+func TestCycleReports(t *testing.T) {
+	s := NewStorage(t.TempDir())
+
+	reports, err := s.GetCycleReports(0)
+	if err != nil || len(reports) != 0 {
+		t.Fatalf("Expected empty history, got %v (err: %v)", reports, err)
+	}
+
+	for i := 0; i < MaxCycleReports+5; i++ {
+		if err := s.SaveCycleReport(models.CycleReport{Start: int64(i), Tests: 1}); err != nil {
+			t.Fatalf("SaveCycleReport failed: %v", err)
+		}
+	}
+
+	reports, _ = s.GetCycleReports(0)
+	if len(reports) != MaxCycleReports {
+		t.Errorf("Expected history capped at %d, got %d", MaxCycleReports, len(reports))
+	}
+	if reports[0].Start != 5 {
+		t.Errorf("Expected oldest entries to be dropped, first start is %d", reports[0].Start)
+	}
+
+	reports, _ = s.GetCycleReports(3)
+	if len(reports) != 3 || reports[2].Start != int64(MaxCycleReports+4) {
+		t.Errorf("Unexpected limited history: %+v", reports)
+	}
+}
+
+func TestResolutionChanges(t *testing.T) {
+	s := NewStorage(t.TempDir())
+
+	_ = s.SaveResolutionChange(models.ResolutionChange{EndpointID: "a", Ts: 1, NewIPs: []string{"192.0.2.1"}})
+	_ = s.SaveResolutionChange(models.ResolutionChange{EndpointID: "b", Ts: 2, NewIPs: []string{"192.0.2.2"}})
+	_ = s.SaveResolutionChange(models.ResolutionChange{EndpointID: "a", Ts: 3, NewIPs: []string{"192.0.2.3"}})
+
+	changes, err := s.GetResolutionChanges("a")
+	if err != nil {
+		t.Fatalf("GetResolutionChanges failed: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Ts != 1 || changes[1].Ts != 3 {
+		t.Errorf("Unexpected changes for a: %+v", changes)
+	}
+	if all, _ := s.GetResolutionChanges(""); len(all) != 3 {
+		t.Errorf("Expected 3 changes in total, got %d", len(all))
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	s := NewStorage(t.TempDir())
+
+	_ = s.SaveAnnotation(models.Annotation{Ts: 1000, Kind: models.AnnotationBattery})
+	_ = s.SaveAnnotation(models.Annotation{Ts: 2000, Kind: models.AnnotationAC})
+	_ = s.SaveAnnotation(models.Annotation{Ts: 3000, Kind: models.AnnotationBattery})
+
+	annotations, err := s.GetAnnotations(time.UnixMilli(1500), time.UnixMilli(3000))
+	if err != nil {
+		t.Fatalf("GetAnnotations failed: %v", err)
+	}
+	if len(annotations) != 2 || annotations[0].Kind != models.AnnotationAC || annotations[1].Ts != 3000 {
+		t.Errorf("Unexpected annotations in range: %+v", annotations)
+	}
+}
+
+func TestGetResultsForRangeOrder(t *testing.T) {
+	s := NewStorage(t.TempDir())
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	for i := 0; i < 10; i++ {
+		ts := day.AddDate(0, 0, i)
+		for _, id := range []string{"a", "b"} {
+			if err := s.SaveResult(models.TestResult{Ts: ts.UnixMilli(), Id: id}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	results, err := s.GetResultsForRange(day.AddDate(0, 0, 2), day.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 12 {
+		t.Fatalf("Expected 12 results, got %d", len(results))
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Ts < results[i-1].Ts {
+			t.Fatalf("Results out of order at %d", i)
+		}
+	}
+}
+
+func TestStorageDayCache(t *testing.T) {
+	s := NewStorage(t.TempDir())
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := s.SaveResult(models.TestResult{Ts: now.UnixMilli(), Id: "a", Ms: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, _ := s.GetResultsForDay(now)
+	results[0].Id = "modified"
+	results, _ = s.GetResultsForDay(now)
+	if len(results) != 3 || results[0].Id != "a" {
+		t.Errorf("Cached results were modified through a caller's copy: %+v", results)
+	}
+
+	// A fresh storage reads the file written through the cache
+	results, _ = NewStorage(s.DataDir).GetResultsForDay(now)
+	if len(results) != 3 || results[2].Ms != 2 {
+		t.Errorf("Unexpected results from file: %+v", results)
+	}
+}
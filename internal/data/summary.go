@@ -0,0 +1,49 @@
+package data
+
+import (
+	"sort"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// SummarizeEndpoint computes the statistics of endpoint id from its results
+// in [start, end]: test and failure counts, availability, mean and p95
+// latency, failure streaks and the last outage. Results of other endpoints
+// are ignored, so results can be passed as read from storage. The caller sets
+// the name, window, state and trend.
+func SummarizeEndpoint(results []models.TestResult, id string, start, end time.Time, intervals Intervals) models.EndpointSummary {
+	startMs, endMs := start.UnixMilli(), end.UnixMilli()
+	var own []models.TestResult
+	for _, r := range withoutCancelled(results) {
+		if r.Id == id && r.Ts >= startMs && r.Ts <= endMs {
+			own = append(own, r)
+		}
+	}
+	sort.Slice(own, func(i, j int) bool { return own[i].Ts < own[j].Ts })
+
+	summary := models.EndpointSummary{EndpointID: id}
+	acc := newAccumulator(id, startMs, endMs)
+	streak := 0
+	for _, r := range own {
+		acc.add(r)
+		if r.St == models.TestStatusSuccess {
+			streak = 0
+			continue
+		}
+		streak++
+		summary.LongestFailureStreak = max(summary.LongestFailureStreak, streak)
+	}
+	summary.CurrentFailureStreak = streak
+
+	agg := acc.result()
+	summary.Tests, summary.Failures = agg.Count, agg.Failures
+	summary.MeanMs, summary.P95Ms = agg.AvgMs, agg.P95Ms
+	if av := ComputeAvailability(own, []string{id}, start, end, intervals); len(av) > 0 {
+		summary.AvailabilityPercent = av[0].AvailabilityPercent
+	}
+	if outages := DetectOutages(own); len(outages) > 0 {
+		summary.LastOutage = &outages[len(outages)-1]
+	}
+	return summary
+}
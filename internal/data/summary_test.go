@@ -0,0 +1,49 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestSummarizeEndpoint(t *testing.T) {
+	start := time.UnixMilli(0)
+	end := time.UnixMilli(100_000)
+	interval := 10 * time.Second
+
+	var results []models.TestResult
+	for i, st := range []int{0, 1, 1, 1, 0, 0, 1, 0, models.TestStatusCancelled, 2, 1} {
+		results = append(results, models.TestResult{Ts: int64(i) * 10_000, Id: "a", St: st, Ms: int64(10 * (i + 1))})
+	}
+	results = append(results,
+		models.TestResult{Ts: 5000, Id: "b", St: 1},    // Other endpoint
+		models.TestResult{Ts: 200_000, Id: "a", St: 0}, // Outside the window
+	)
+
+	s := SummarizeEndpoint(results, "a", start, end, Intervals{Default: interval})
+	if s.EndpointID != "a" || s.Tests != 10 || s.Failures != 6 {
+		t.Errorf("Expected 10 tests with 6 failures, got %+v", s)
+	}
+	// Successes at 10, 50, 60 and 80 ms
+	if s.MeanMs != 50 {
+		t.Errorf("Expected a mean of 50 ms, got %v", s.MeanMs)
+	}
+	if s.P95Ms <= 0 {
+		t.Errorf("Expected a p95, got %v", s.P95Ms)
+	}
+	if s.LongestFailureStreak != 3 || s.CurrentFailureStreak != 2 {
+		t.Errorf("Expected streaks of 3 and 2, got %d and %d", s.LongestFailureStreak, s.CurrentFailureStreak)
+	}
+	if s.LastOutage == nil || s.LastOutage.Start != 90_000 || !s.LastOutage.Ongoing {
+		t.Errorf("Expected the ongoing outage from 90s as the last, got %+v", s.LastOutage)
+	}
+	if s.AvailabilityPercent <= 0 || s.AvailabilityPercent >= 100 {
+		t.Errorf("Expected a partial availability, got %v", s.AvailabilityPercent)
+	}
+
+	empty := SummarizeEndpoint(results, "c", start, end, Intervals{Default: interval})
+	if empty.Tests != 0 || empty.LastOutage != nil || empty.AvailabilityPercent != 0 {
+		t.Errorf("Expected an empty summary for an endpoint without results, got %+v", empty)
+	}
+}
@@ -0,0 +1,121 @@
+package data
+
+import (
+	"sort"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+const (
+	day = 24 * time.Hour
+
+	// minTrendDays is the fewest days with data a trend is computed from
+	minTrendDays = 7
+
+	// degradingPerWeek is the relative weekly p95 growth flagged as degrading
+	degradingPerWeek = 0.10
+
+	// maxProjection is how far ahead threshold crossings are projected
+	maxProjection = 365 * day
+)
+
+// LatencyTrends fits a line to the daily p95 latency of each endpoint in
+// results, flagging endpoints whose latency grows quickly and projecting when
+// it reaches the threshold in thresholds. Endpoints with fewer than 7 days of
+// successful tests are left out. The output is sorted by endpoint ID.
+func LatencyTrends(results []models.TestResult, thresholds map[string]int) []models.LatencyTrend {
+	daily := make(map[string][]models.AggregatedResult)
+	var ids []string
+	for _, agg := range Aggregate(results, day) {
+		if agg.Count == agg.Failures {
+			continue
+		}
+		if _, ok := daily[agg.EndpointID]; !ok {
+			ids = append(ids, agg.EndpointID)
+		}
+		daily[agg.EndpointID] = append(daily[agg.EndpointID], agg)
+	}
+
+	var trends []models.LatencyTrend
+	for _, id := range ids {
+		if len(daily[id]) < minTrendDays {
+			continue
+		}
+		trends = append(trends, latencyTrend(id, daily[id], thresholds[id]))
+	}
+	sort.Slice(trends, func(i, j int) bool { return trends[i].EndpointID < trends[j].EndpointID })
+	return trends
+}
+
+// latencyTrend computes the trend of days, which are in time order
+func latencyTrend(id string, days []models.AggregatedResult, thresholdMs int) models.LatencyTrend {
+	origin := days[0].Start
+	xs := make([]float64, len(days))
+	ys := make([]float64, len(days))
+	for i, d := range days {
+		xs[i] = float64(d.Start-origin) / float64(day.Milliseconds())
+		ys[i] = d.P95Ms
+	}
+	intercept, slope := linearFit(xs, ys)
+
+	last := days[len(days)-1]
+	trend := models.LatencyTrend{
+		EndpointID:          id,
+		Days:                len(days),
+		CurrentP95Ms:        last.P95Ms,
+		SlopeMsPerDay:       slope,
+		WeekOverWeekPercent: weekOverWeek(days),
+	}
+
+	fitted := intercept + slope*xs[len(xs)-1]
+	if slope > 0 && fitted > 0 {
+		trend.Degrading = slope*7 >= degradingPerWeek*fitted
+	}
+	if target := float64(thresholdMs); slope > 0 && thresholdMs > 0 && fitted < target {
+		ahead := time.Duration((target - fitted) / slope * float64(day))
+		if ahead <= maxProjection {
+			trend.ThresholdCrossingAt = last.Start + ahead.Milliseconds()
+		}
+	}
+	return trend
+}
+
+// weekOverWeek compares the mean p95 of the days in the last week with the
+// week before it
+func weekOverWeek(days []models.AggregatedResult) float64 {
+	end := days[len(days)-1].Start + day.Milliseconds()
+	week := 7 * day.Milliseconds()
+	var this, prev float64
+	var nThis, nPrev int
+	for _, d := range days {
+		switch {
+		case d.Start >= end-week:
+			this += d.P95Ms
+			nThis++
+		case d.Start >= end-2*week:
+			prev += d.P95Ms
+			nPrev++
+		}
+	}
+	if nThis == 0 || nPrev == 0 || prev == 0 {
+		return 0
+	}
+	return 100 * (this/float64(nThis) - prev/float64(nPrev)) / (prev / float64(nPrev))
+}
+
+// linearFit returns the least squares line through the points
+func linearFit(xs, ys []float64) (intercept, slope float64) {
+	n := float64(len(xs))
+	var sx, sy, sxx, sxy float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+		sxx += xs[i] * xs[i]
+		sxy += xs[i] * ys[i]
+	}
+	if d := n*sxx - sx*sx; d != 0 {
+		slope = (n*sxy - sx*sy) / d
+	}
+	return (sy - slope*sx) / n, slope
+}
@@ -0,0 +1,50 @@
+package data
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// dailyResults returns one result a day for each day, latency from ms
+func dailyResults(id string, start time.Time, days int, ms func(day int) int64) []models.TestResult {
+	var results []models.TestResult
+	for d := range days {
+		results = append(results, models.TestResult{Ts: start.AddDate(0, 0, d).UnixMilli(), Id: id, Ms: ms(d)})
+	}
+	return results
+}
+
+func TestLatencyTrends(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var results []models.TestResult
+	// Grows 5ms a day from 50ms, reaching 200ms on day 30
+	results = append(results, dailyResults("grow", start, 14, func(d int) int64 { return 50 + 5*int64(d) })...)
+	results = append(results, dailyResults("flat", start, 14, func(int) int64 { return 30 })...)
+	results = append(results, dailyResults("short", start, 3, func(int) int64 { return 30 })...)
+
+	trends := LatencyTrends(results, map[string]int{"grow": 200, "flat": 200})
+	if len(trends) != 2 || trends[0].EndpointID != "flat" || trends[1].EndpointID != "grow" {
+		t.Fatalf("Unexpected trends: %+v", trends)
+	}
+
+	flat := trends[0]
+	if flat.Degrading || flat.SlopeMsPerDay != 0 || flat.WeekOverWeekPercent != 0 || flat.ThresholdCrossingAt != 0 {
+		t.Errorf("Unexpected flat trend: %+v", flat)
+	}
+
+	grow := trends[1]
+	if math.Abs(grow.SlopeMsPerDay-5) > 1e-9 || !grow.Degrading || grow.Days != 14 || grow.CurrentP95Ms != 115 {
+		t.Errorf("Unexpected growing trend: %+v", grow)
+	}
+	// Mean of days 7-13 (100ms) over days 0-6 (65ms)
+	if math.Abs(grow.WeekOverWeekPercent-100*35.0/65) > 1e-9 {
+		t.Errorf("Unexpected week over week change: %v", grow.WeekOverWeekPercent)
+	}
+	want := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	if grow.ThresholdCrossingAt != want {
+		t.Errorf("Expected crossing at %v, got %v", time.UnixMilli(want).UTC(), time.UnixMilli(grow.ThresholdCrossingAt).UTC())
+	}
+}
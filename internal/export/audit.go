@@ -0,0 +1,83 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"slices"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// audit appends the job's current state to the audit log. Must be called
+// with m.mu held so entries of a job are written in order.
+func (m *Manager) audit(j *job) {
+	if m.AuditPath == "" {
+		return
+	}
+	req := j.req
+	req.Passphrase = ""
+	entry := models.ExportAuditEntry{
+		Time:      j.status.UpdatedAt,
+		ExportID:  j.status.ID,
+		State:     j.status.State,
+		Attempt:   j.status.Attempts,
+		Request:   req,
+		Encrypted: j.locked || j.req.Passphrase != "",
+		Path:      j.status.Path,
+		Rows:      j.status.Rows,
+		Error:     j.status.Error,
+	}
+	if err := appendAudit(m.AuditPath, entry); err != nil {
+		log.Ctx(m.Ctx).Error().Err(err).Str("id", j.status.ID).Msg("Failed to write export audit log")
+	}
+}
+
+func appendAudit(path string, entry models.ExportAuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Audit returns the audit log, most recent first
+func (m *Manager) Audit() ([]models.ExportAuditEntry, error) {
+	entries := []models.ExportAuditEntry{}
+	if m.AuditPath == "" {
+		return entries, nil
+	}
+	f, err := os.Open(m.AuditPath)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry models.ExportAuditEntry
+		// Skip a line cut short by a crash rather than hide the rest
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	slices.Reverse(entries)
+	return entries, nil
+}
@@ -0,0 +1,37 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// BenchmarkExportCSV writes 100,000 results with the core CSV columns, about
+// a month of 10 endpoints tested every 5 minutes. Budget in the README.
+func BenchmarkExportCSV(b *testing.B) {
+	results := make([]models.TestResult, 100_000)
+	for i := range results {
+		results[i] = models.TestResult{
+			Ts: 1_717_200_000_000 + int64(i)*30_000,
+			Id: fmt.Sprintf("ep%03d", i%10),
+			Ms: int64(10 + i%90),
+		}
+		if i%100 == 0 {
+			results[i].St, results[i].Ek = models.TestStatusTimeout, models.ErrorKindTimeout
+		}
+	}
+	// The columns the budget was set for, before optional ones were added
+	columns := []string{"ts", "id", "ms", "us", "st"}
+	fields, _ := resolve(columns, resultColumn)
+	path := filepath.Join(b.TempDir(), "export.csv")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := writeCSV(newProgress(context.Background()), path, results, columns, fields); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// TagColumnPrefix selects a result tag as a column, e.g. "tags.asn". Results
+// without the tag have an empty CSV cell and a JSON null.
+const TagColumnPrefix = "tags."
+
+// field extracts the value of one column from a row. Values are strings,
+// integers, floats or nil, typed so JSON exports keep numbers as numbers.
+type field[T any] func(T) any
+
+// resultColumns maps the column names of result exports to their fields
+var resultColumns = map[string]field[models.TestResult]{
+	"ts":            func(r models.TestResult) any { return r.Ts },
+	"seq":           func(r models.TestResult) any { return r.Seq },
+	"id":            func(r models.TestResult) any { return r.Id },
+	"ms":            func(r models.TestResult) any { return r.Ms },
+	"us":            func(r models.TestResult) any { return r.Us },
+	"st":            func(r models.TestResult) any { return r.St },
+	"ek":            func(r models.TestResult) any { return string(r.Ek) },
+	"err":           func(r models.TestResult) any { return errString(r.Err) },
+	"jit":           func(r models.TestResult) any { return r.Jit },
+	"loss":          func(r models.TestResult) any { return r.Loss },
+	"mos":           func(r models.TestResult) any { return r.Mos },
+	"rtt_sent":      rttColumn(func(s models.RTTStats) any { return s.Sent }),
+	"rtt_recv":      rttColumn(func(s models.RTTStats) any { return s.Recv }),
+	"rtt_min_us":    rttColumn(func(s models.RTTStats) any { return s.MinUs }),
+	"rtt_avg_us":    rttColumn(func(s models.RTTStats) any { return s.AvgUs }),
+	"rtt_max_us":    rttColumn(func(s models.RTTStats) any { return s.MaxUs }),
+	"rtt_stddev_us": rttColumn(func(s models.RTTStats) any { return s.StdDevUs }),
+	"tx":            func(r models.TestResult) any { return r.Tx },
+	"rx":            func(r models.TestResult) any { return r.Rx },
+	"trace":         func(r models.TestResult) any { return r.Trace },
+}
+
+// rttColumn extracts one of the RTT statistics of ICMP tests sending several
+// echo requests, nil for other results
+func rttColumn(f func(models.RTTStats) any) field[models.TestResult] {
+	return func(r models.TestResult) any {
+		if r.Rtt == nil {
+			return nil
+		}
+		return f(*r.Rtt)
+	}
+}
+
+// defaultCSVColumns is the column order used when the request doesn't select any
+var defaultCSVColumns = []string{"ts", "id", "ms", "st"}
+
+// resultColumn looks up a result column, including flattened tags
+func resultColumn(name string) (field[models.TestResult], bool) {
+	if tag, ok := strings.CutPrefix(name, TagColumnPrefix); ok && tag != "" {
+		return func(r models.TestResult) any {
+			if v, ok := r.Tags[tag]; ok {
+				return v
+			}
+			return nil
+		}, true
+	}
+	f, ok := resultColumns[name]
+	return f, ok
+}
+
+// aggColumns maps the column names of aggregated exports to their fields
+var aggColumns = map[string]field[models.AggregatedResult]{
+	"start":           func(a models.AggregatedResult) any { return a.Start },
+	"end":             func(a models.AggregatedResult) any { return a.End },
+	"id":              func(a models.AggregatedResult) any { return a.EndpointID },
+	"count":           func(a models.AggregatedResult) any { return a.Count },
+	"failures":        func(a models.AggregatedResult) any { return a.Failures },
+	"availability":    func(a models.AggregatedResult) any { return availability(a) },
+	"avg_ms":          func(a models.AggregatedResult) any { return a.AvgMs },
+	"stddev_ms":       func(a models.AggregatedResult) any { return a.StdDevMs },
+	"min_ms":          func(a models.AggregatedResult) any { return a.MinMs },
+	"max_ms":          func(a models.AggregatedResult) any { return a.MaxMs },
+	"p50_ms":          func(a models.AggregatedResult) any { return a.P50Ms },
+	"p95_ms":          func(a models.AggregatedResult) any { return a.P95Ms },
+	"p99_ms":          func(a models.AggregatedResult) any { return a.P99Ms },
+	"jitter_ms":       func(a models.AggregatedResult) any { return a.JitterMs },
+	"packet_loss_pct": func(a models.AggregatedResult) any { return a.PacketLossPct },
+	// Business hours columns are empty for endpoints whose region has none
+	"business_count":        func(a models.AggregatedResult) any { return a.BusinessCount },
+	"business_failures":     func(a models.AggregatedResult) any { return a.BusinessFailures },
+	"business_availability": func(a models.AggregatedResult) any { return businessAvailability(a) },
+}
+
+// defaultAggColumns is the column order of aggregated CSV exports that don't select any
+var defaultAggColumns = []string{"start", "id", "count", "availability", "avg_ms", "min_ms", "max_ms", "p50_ms", "p95_ms", "p99_ms"}
+
+func aggColumn(name string) (field[models.AggregatedResult], bool) {
+	f, ok := aggColumns[name]
+	return f, ok
+}
+
+// regionColumns are the columns of the endpoint's region, in result and
+// aggregated exports
+var regionColumns = map[string]field[models.EndpointRegion]{
+	"region": func(r models.EndpointRegion) any { return r.Region },
+	"latitude": func(r models.EndpointRegion) any {
+		if r.Location == nil {
+			return nil
+		}
+		return r.Location.Latitude
+	},
+	"longitude": func(r models.EndpointRegion) any {
+		if r.Location == nil {
+			return nil
+		}
+		return r.Location.Longitude
+	},
+	"timezone": func(r models.EndpointRegion) any { return r.Timezone },
+}
+
+// withRegion adds the region columns to lookup, resolving the region of each
+// row through its endpoint ID. Endpoints no longer configured have empty
+// region columns.
+func withRegion[T any](lookup func(string) (field[T], bool), id func(T) string, regions map[string]models.EndpointRegion) func(string) (field[T], bool) {
+	return func(name string) (field[T], bool) {
+		f, ok := regionColumns[name]
+		if !ok {
+			return lookup(name)
+		}
+		return func(row T) any {
+			r, ok := regions[id(row)]
+			if !ok {
+				return nil
+			}
+			return f(r)
+		}, true
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// availability is the percentage of successful tests in the bucket
+func availability(a models.AggregatedResult) float64 {
+	if a.Count == 0 {
+		return 0
+	}
+	return float64(a.Count-a.Failures) / float64(a.Count) * 100
+}
+
+// businessAvailability is the share of successful tests run within business
+// hours, nil without any
+func businessAvailability(a models.AggregatedResult) any {
+	if a.BusinessCount == 0 {
+		return nil
+	}
+	return float64(a.BusinessCount-a.BusinessFailures) / float64(a.BusinessCount) * 100
+}
+
+// resolve returns the fields of the named columns, in order
+func resolve[T any](names []string, lookup func(string) (field[T], bool)) ([]field[T], error) {
+	fields := make([]field[T], len(names))
+	for i, name := range names {
+		f, ok := lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown export column: %s", name)
+		}
+		fields[i] = f
+	}
+	return fields, nil
+}
+
+// formatCell renders a field value as a CSV cell. Floats get two decimals.
+func formatCell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		// Most results have no jitter, loss or MOS
+		if v == 0 {
+			return "0.00"
+		}
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
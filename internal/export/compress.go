@@ -0,0 +1,56 @@
+package export
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// compressedExt is the extension each compression adds to the export name
+var compressedExt = map[models.ExportCompression]string{
+	models.CompressionZip:  ".zip",
+	models.CompressionGzip: ".gz",
+	models.CompressionZstd: ".zst",
+}
+
+// compress writes the file at srcPath to dstPath with the requested compression
+func compress(req models.ExportRequest, srcPath, dstPath string) error {
+	switch req.Compression {
+	case models.CompressionGzip:
+		return writeGzip(srcPath, dstPath)
+	case models.CompressionZstd:
+		return writeZstd(srcPath, dstPath)
+	default:
+		return writeZip(srcPath, dstPath, req.Passphrase)
+	}
+}
+
+// writeGzip compresses the file at srcPath into a gzip stream at dstPath,
+// recording the export's name so gunzip -N restores it
+func writeGzip(srcPath, dstPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	zw := gzip.NewWriter(dst)
+	zw.Name = exportEntryName(srcPath)
+	zw.ModTime = time.Now()
+	if _, err := io.Copy(zw, src); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return dst.Close()
+}
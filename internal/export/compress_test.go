@@ -0,0 +1,94 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/data"
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestCreateExportGzip(t *testing.T) {
+	tmp := t.TempDir()
+	store := data.NewStorage(tmp + "/data")
+	ts := time.Date(2023, 11, 15, 12, 0, 0, 0, time.UTC)
+	_ = store.SaveResult(models.TestResult{Ts: ts.UnixMilli(), Id: "ep-1", Ms: 50})
+
+	m := NewManager(context.Background(), store, tmp+"/exports", 1)
+	defer m.Stop()
+
+	status, err := m.CreateExport(models.ExportRequest{
+		Format:      models.ExportCSV,
+		Start:       ts.Add(-time.Hour).UnixMilli(),
+		End:         ts.Add(time.Hour).UnixMilli(),
+		Compression: models.CompressionGzip,
+	})
+	if err != nil {
+		t.Fatalf("CreateExport failed: %v", err)
+	}
+	status = waitForState(t, m, status.ID, models.ExportCompleted)
+	if !strings.HasSuffix(status.Path, ".csv.gz") {
+		t.Fatalf("Expected a .csv.gz export, got %s", status.Path)
+	}
+
+	f, err := os.Open(status.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Failed to open gzip stream: %v", err)
+	}
+	content, _ := io.ReadAll(zr)
+	if zr.Name != strings.TrimSuffix(filepath.Base(status.Path), ".gz") {
+		t.Errorf("Unexpected gzip name %q", zr.Name)
+	}
+	if !strings.Contains(string(content), "ep-1") {
+		t.Errorf("Unexpected export content: %s", content)
+	}
+}
+
+// TestWriteZstd checks the frames with the reference decoder, so it is
+// skipped where the zstd command is not installed
+func TestWriteZstd(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd not installed")
+	}
+
+	var csv bytes.Buffer
+	for i := range 20_000 {
+		fmt.Fprintf(&csv, "%d,ep%03d,%d,0\n", 1_717_200_000_000+int64(i)*30_000, i%10, 10+i%90)
+	}
+	cases := map[string][]byte{
+		"empty":  nil,
+		"short":  []byte("ts,id,ms,st\n"),
+		"blocks": csv.Bytes(), // Spans several blocks
+	}
+	tmp := t.TempDir()
+	for name, content := range cases {
+		src := filepath.Join(tmp, name)
+		if err := os.WriteFile(src, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeZstd(src, src+".zst"); err != nil {
+			t.Fatalf("%s: writeZstd failed: %v", name, err)
+		}
+		out, err := exec.Command("zstd", "-d", "-c", src+".zst").Output()
+		if err != nil {
+			t.Fatalf("%s: zstd failed to decode: %v", name, err)
+		}
+		if !bytes.Equal(out, content) {
+			t.Errorf("%s: decoded %d bytes, expected %d", name, len(out), len(content))
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// MinFreeBytes is the free space an export directory needs to be accepted
+const MinFreeBytes = 100 << 20
+
+// ValidateDir checks that dir is an absolute path the app can create files
+// in and that its volume has at least MinFreeBytes free. The directory is
+// created if it doesn't exist.
+func ValidateDir(dir string) error {
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("export directory must be an absolute path: %s", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(dir, ".netmonitor-probe-*")
+	if err != nil {
+		return fmt.Errorf("export directory is not writable: %w", err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	free, err := freeSpace(dir)
+	if err != nil {
+		return err
+	}
+	if free < MinFreeBytes {
+		return fmt.Errorf("export directory has %d MB free, at least %d MB are needed", free>>20, MinFreeBytes>>20)
+	}
+	return nil
+}
+
+// Dir returns the directory new exports are written to
+func (m *Manager) Dir() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.dir
+}
+
+// SetDir validates dir and makes it the directory for new exports and the
+// export history. Running exports finish in the previous directory.
+func (m *Manager) SetDir(dir string) error {
+	if err := ValidateDir(dir); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	changed := m.dir != dir
+	m.dir = dir
+	m.mu.Unlock()
+
+	if changed {
+		return m.saveJobs()
+	}
+	return nil
+}
@@ -0,0 +1,688 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marcoshack/netmonitor/internal/data"
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultConcurrency is the number of exports allowed to run at the same time
+	DefaultConcurrency = 2
+
+	// smallExportDays is the range size up to which an export is considered small
+	// and is queued ahead of larger exports
+	smallExportDays = 1
+)
+
+type job struct {
+	status models.ExportStatus
+	req    models.ExportRequest
+	cancel context.CancelFunc
+	// progress is set while the job runs
+	progress *progress
+	// locked is set on jobs restored from a previous session whose
+	// passphrase was not persisted, they can't be retried
+	locked bool
+}
+
+// savedJob is how jobs are persisted across restarts. The passphrase is
+// never written to disk.
+type savedJob struct {
+	Status    models.ExportStatus  `json:"status"`
+	Request   models.ExportRequest `json:"request"`
+	Encrypted bool                 `json:"encrypted,omitempty"`
+}
+
+// small reports whether the job covers a short enough range to get priority
+func (j *job) small() bool {
+	days := time.Duration(j.req.End-j.req.Start) * time.Millisecond / (24 * time.Hour)
+	return days < smallExportDays
+}
+
+// Manager runs export jobs through a bounded worker pool
+type Manager struct {
+	Ctx     context.Context
+	Storage *data.Storage
+	// EndpointNames resolves endpoint IDs to display names for formats that
+	// show them, like ICS event titles. Optional.
+	EndpointNames func() map[string]string
+	// EndpointRegions resolves endpoint IDs to their region, for the region
+	// columns and to align aggregates to the region's time zone. Optional.
+	EndpointRegions func() map[string]models.EndpointRegion
+	// AuditPath is an NDJSON file recording every export as it is queued
+	// and finishes. Optional.
+	AuditPath string
+
+	mu      sync.Mutex
+	dir     string
+	cond    *sync.Cond
+	jobs    map[string]*job
+	queue   []*job
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func NewManager(ctx context.Context, store *data.Storage, dir string, concurrency int) *Manager {
+	if concurrency < 1 {
+		concurrency = DefaultConcurrency
+	}
+
+	m := &Manager{
+		Ctx:     ctx,
+		Storage: store,
+		dir:     dir,
+		jobs:    make(map[string]*job),
+	}
+	m.cond = sync.NewCond(&m.mu)
+	if err := m.loadJobs(); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to load export history")
+	}
+
+	for i := 0; i < concurrency; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	return m
+}
+
+// CreateExport validates the request and queues a new export job
+func (m *Manager) CreateExport(req models.ExportRequest) (models.ExportStatus, error) {
+	switch req.Format {
+	case models.ExportCSV, models.ExportJSON, models.ExportNDJSON, models.ExportICS:
+	default:
+		return models.ExportStatus{}, fmt.Errorf("unsupported export format: %s", req.Format)
+	}
+	if req.End < req.Start {
+		return models.ExportStatus{}, fmt.Errorf("export end must not be before start")
+	}
+	if _, ok := compressedExt[req.Compression]; !ok && req.Compression != models.CompressionNone {
+		return models.ExportStatus{}, fmt.Errorf("unsupported export compression: %s", req.Compression)
+	}
+	if req.Passphrase != "" && req.Compression != models.CompressionZip {
+		return models.ExportStatus{}, fmt.Errorf("password protection requires zip compression")
+	}
+	if _, ok := aggPeriods[req.Aggregate]; !ok && req.Aggregate != models.AggregateNone {
+		return models.ExportStatus{}, fmt.Errorf("unsupported export aggregation: %s", req.Aggregate)
+	}
+	if req.Aggregate != models.AggregateNone && req.Format == models.ExportICS {
+		return models.ExportStatus{}, fmt.Errorf("ics exports can't be aggregated")
+	}
+	if req.Format == models.ExportICS && len(req.Columns) > 0 {
+		return models.ExportStatus{}, fmt.Errorf("ics exports have no columns")
+	}
+	_, err := resolve(req.Columns, withRegion(resultColumn, nil, nil))
+	if req.Aggregate != models.AggregateNone {
+		_, err = resolve(req.Columns, withRegion(aggColumn, nil, nil))
+	}
+	if err != nil {
+		return models.ExportStatus{}, err
+	}
+	if req.Destination != "" && !filepath.IsAbs(req.Destination) {
+		return models.ExportStatus{}, fmt.Errorf("export destination must be an absolute path")
+	}
+
+	now := time.Now().UnixMilli()
+	j := &job{
+		req: req,
+		status: models.ExportStatus{
+			ID:        uuid.New().String(),
+			State:     models.ExportQueued,
+			Attempts:  1,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return models.ExportStatus{}, fmt.Errorf("export manager is stopped")
+	}
+
+	m.jobs[j.status.ID] = j
+	m.enqueue(j)
+	m.cond.Signal()
+	m.audit(j)
+
+	log.Ctx(m.Ctx).Info().
+		Str("id", j.status.ID).
+		Str("format", string(req.Format)).
+		Str("compression", string(req.Compression)).
+		Str("aggregate", string(req.Aggregate)).
+		Bool("encrypted", req.Passphrase != "").
+		Bool("small", j.small()).
+		Msg("Export queued")
+
+	return m.statusLocked(j), nil
+}
+
+// enqueue inserts the job after any queued small jobs if it is small itself,
+// or at the end of the queue otherwise. Must be called with m.mu held.
+func (m *Manager) enqueue(j *job) {
+	if !j.small() {
+		m.queue = append(m.queue, j)
+		return
+	}
+
+	i := 0
+	for i < len(m.queue) && m.queue[i].small() {
+		i++
+	}
+	m.queue = append(m.queue, nil)
+	copy(m.queue[i+1:], m.queue[i:])
+	m.queue[i] = j
+}
+
+// ExportStatus returns the current status of an export job
+func (m *Manager) ExportStatus(id string) (models.ExportStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return models.ExportStatus{}, fmt.Errorf("export not found: %s", id)
+	}
+	return m.statusLocked(j), nil
+}
+
+// ListExports returns the status of every known export job
+func (m *Manager) ListExports() []models.ExportStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]models.ExportStatus, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		statuses = append(statuses, m.statusLocked(j))
+	}
+	return statuses
+}
+
+// CancelExport removes a queued job from the queue or interrupts a running one
+func (m *Manager) CancelExport(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("export not found: %s", id)
+	}
+
+	switch j.status.State {
+	case models.ExportQueued:
+		for i, q := range m.queue {
+			if q == j {
+				m.queue = append(m.queue[:i], m.queue[i+1:]...)
+				break
+			}
+		}
+		m.setState(j, models.ExportCancelled, "")
+	case models.ExportRunning:
+		j.cancel()
+	default:
+		return fmt.Errorf("export %s is already %s", id, j.status.State)
+	}
+	return nil
+}
+
+// RetryExport queues a failed, cancelled or interrupted export again with its
+// original request
+func (m *Manager) RetryExport(id string) (models.ExportStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return models.ExportStatus{}, fmt.Errorf("export not found: %s", id)
+	}
+	switch j.status.State {
+	case models.ExportFailed, models.ExportCancelled, models.ExportInterrupted:
+	default:
+		return models.ExportStatus{}, fmt.Errorf("export %s is %s, only failed, cancelled or interrupted exports can be retried", id, j.status.State)
+	}
+	if j.locked {
+		return models.ExportStatus{}, fmt.Errorf("export %s was password protected, create it again with its passphrase", id)
+	}
+	if m.stopped {
+		return models.ExportStatus{}, fmt.Errorf("export manager is stopped")
+	}
+
+	j.status.Attempts++
+	j.status.Rows = 0
+	j.status.Path = ""
+	m.setState(j, models.ExportQueued, "")
+	m.enqueue(j)
+	m.cond.Signal()
+
+	log.Ctx(m.Ctx).Info().Str("id", id).Int("attempt", j.status.Attempts).Msg("Export retry queued")
+
+	return m.statusLocked(j), nil
+}
+
+// Stop interrupts queued and running jobs, waits for the workers to exit and
+// saves the job history so interrupted exports can be retried after a restart
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		return
+	}
+	m.stopped = true
+	for _, j := range m.queue {
+		m.setState(j, models.ExportInterrupted, "")
+	}
+	m.queue = nil
+	for _, j := range m.jobs {
+		if j.status.State == models.ExportRunning {
+			j.cancel()
+		}
+	}
+	m.cond.Broadcast()
+	m.mu.Unlock()
+
+	m.wg.Wait()
+	if err := m.saveJobs(); err != nil {
+		log.Ctx(m.Ctx).Error().Err(err).Msg("Failed to save export history")
+	}
+}
+
+func historyPath(dir string) string {
+	return filepath.Join(dir, "exports.json")
+}
+
+func (m *Manager) saveJobs() error {
+	m.mu.Lock()
+	dir := m.dir
+	saved := make([]savedJob, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		req := j.req
+		req.Passphrase = ""
+		saved = append(saved, savedJob{Status: j.status, Request: req, Encrypted: j.locked || j.req.Passphrase != ""})
+	}
+	m.mu.Unlock()
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(historyPath(dir), data, 0644)
+}
+
+func (m *Manager) loadJobs() error {
+	data, err := os.ReadFile(historyPath(m.dir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var saved []savedJob
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+	for _, sj := range saved {
+		m.jobs[sj.Status.ID] = &job{status: sj.Status, req: sj.Request, locked: sj.Encrypted}
+	}
+	return nil
+}
+
+// statusLocked returns a copy of the job status with its queue position filled in.
+// Must be called with m.mu held.
+func (m *Manager) statusLocked(j *job) models.ExportStatus {
+	status := j.status
+	if j.progress != nil {
+		j.progress.fill(&status, time.Now())
+	}
+	if status.State == models.ExportQueued {
+		for i, q := range m.queue {
+			if q == j {
+				status.Position = i + 1
+				break
+			}
+		}
+	}
+	return status
+}
+
+// setState updates the job state and audits every state but running. Must
+// be called with m.mu held.
+func (m *Manager) setState(j *job, state models.ExportState, errMsg string) {
+	j.status.State = state
+	j.status.Error = errMsg
+	j.status.UpdatedAt = time.Now().UnixMilli()
+	if state != models.ExportRunning {
+		m.audit(j)
+	}
+}
+
+func (m *Manager) worker() {
+	defer m.wg.Done()
+
+	for {
+		m.mu.Lock()
+		for len(m.queue) == 0 && !m.stopped {
+			m.cond.Wait()
+		}
+		if m.stopped {
+			m.mu.Unlock()
+			return
+		}
+
+		j := m.queue[0]
+		m.queue = m.queue[1:]
+		ctx, cancel := context.WithCancel(m.Ctx)
+		j.cancel = cancel
+		j.progress = newProgress(ctx)
+		m.setState(j, models.ExportRunning, "")
+		m.mu.Unlock()
+
+		path, rows, err := m.run(j.progress, j.status.ID, j.req)
+
+		m.mu.Lock()
+		j.progress = nil
+		j.status.Rows = rows
+		switch {
+		case ctx.Err() != nil && m.stopped:
+			m.setState(j, models.ExportInterrupted, "")
+		case ctx.Err() != nil:
+			m.setState(j, models.ExportCancelled, "")
+		case err != nil:
+			m.setState(j, models.ExportFailed, err.Error())
+		default:
+			j.status.Path = path
+			m.setState(j, models.ExportCompleted, "")
+		}
+		m.mu.Unlock()
+		cancel()
+
+		if err != nil {
+			log.Ctx(m.Ctx).Error().Err(err).Str("id", j.status.ID).Msg("Export failed")
+		} else {
+			log.Ctx(m.Ctx).Info().Str("id", j.status.ID).Int("rows", rows).Msg("Export finished")
+		}
+	}
+}
+
+// run reads the requested results and writes them to the export file
+func (m *Manager) run(p *progress, id string, req models.ExportRequest) (string, int, error) {
+	ctx := p.ctx
+	start, end := time.UnixMilli(req.Start), time.UnixMilli(req.End)
+	p.total.Store(int64(m.Storage.EstimateResults(start, end, req.EndpointIDs)))
+
+	results, err := m.Storage.GetResultsForRange(start, end)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", 0, err
+	}
+
+	results = filterEndpoints(results, req.EndpointIDs)
+
+	dir := m.Dir()
+	if req.Destination != "" {
+		dir = req.Destination
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("netmonitor-export-%s.%s", id, req.Format))
+
+	// Write to a temporary file and rename on success so a partial file
+	// never shows up under the final export name
+	tmpPath := path + ".tmp"
+	defer os.Remove(tmpPath)
+
+	rows := len(results)
+	regions := m.endpointRegions()
+	if period, ok := aggPeriods[req.Aggregate]; ok {
+		aggs := data.AggregateLocal(results, period, locations(regions), schedules(regions))
+		rows = len(aggs)
+		err = write(p, tmpPath, req, aggs, withRegion(aggColumn, func(a models.AggregatedResult) string { return a.EndpointID }, regions), defaultAggColumns)
+	} else if req.Format == models.ExportICS {
+		outages := data.DetectOutages(results)
+		rows = len(outages)
+		p.start(rows)
+		err = writeICS(tmpPath, outages, m.endpointNames(), time.Now())
+	} else {
+		err = write(p, tmpPath, req, results, withRegion(resultColumn, func(r models.TestResult) string { return r.Id }, regions), defaultCSVColumns)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	if ext, ok := compressedExt[req.Compression]; ok {
+		compressedPath := path + ext
+		compressedTmpPath := compressedPath + ".tmp"
+		defer os.Remove(compressedTmpPath)
+
+		if err := compress(req, tmpPath, compressedTmpPath); err != nil {
+			return "", 0, err
+		}
+		path, tmpPath = compressedPath, compressedTmpPath
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", 0, err
+	}
+	return path, rows, nil
+}
+
+// write writes rows, results or aggregates, in the requested tabular
+// format. JSON rows are written whole unless columns are selected.
+func write[T any](p *progress, path string, req models.ExportRequest, rows []T, lookup func(string) (field[T], bool), defaults []string) error {
+	columns := req.Columns
+	if len(columns) == 0 && req.Format == models.ExportCSV {
+		columns = defaults
+	}
+	fields, err := resolve(columns, lookup)
+	if err != nil {
+		return err
+	}
+	p.start(len(rows))
+	switch req.Format {
+	case models.ExportCSV:
+		return writeCSV(p, path, rows, columns, fields)
+	case models.ExportJSON:
+		return writeJSON(p, path, rows, columns, fields)
+	default:
+		return writeNDJSON(p, path, rows, columns, fields)
+	}
+}
+
+func (m *Manager) endpointNames() map[string]string {
+	if m.EndpointNames == nil {
+		return nil
+	}
+	return m.EndpointNames()
+}
+
+func (m *Manager) endpointRegions() map[string]models.EndpointRegion {
+	if m.EndpointRegions == nil {
+		return nil
+	}
+	return m.EndpointRegions()
+}
+
+// locations loads the time zones of the regions, skipping unknown ones
+func locations(regions map[string]models.EndpointRegion) map[string]*time.Location {
+	locs := make(map[string]*time.Location)
+	for id, r := range regions {
+		if r.Timezone == "" {
+			continue
+		}
+		if loc, err := time.LoadLocation(r.Timezone); err == nil {
+			locs[id] = loc
+		}
+	}
+	return locs
+}
+
+// schedules compiles the business hours of the endpoints' regions, leaving
+// out invalid ones, which the config doesn't accept
+func schedules(regions map[string]models.EndpointRegion) map[string]*data.Schedule {
+	scheds := make(map[string]*data.Schedule)
+	locs := locations(regions)
+	for id, r := range regions {
+		if r.BusinessHours == nil {
+			continue
+		}
+		loc := locs[id]
+		if loc == nil {
+			loc = time.UTC
+		}
+		if s, err := data.NewSchedule(*r.BusinessHours, loc); err == nil {
+			scheds[id] = s
+		}
+	}
+	return scheds
+}
+
+func filterEndpoints(results []models.TestResult, ids []string) []models.TestResult {
+	if len(ids) == 0 {
+		return results
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	var filtered []models.TestResult
+	for _, r := range results {
+		if wanted[r.Id] {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// aggPeriods maps export aggregations to their bucket size
+var aggPeriods = map[models.ExportAggregation]time.Duration{
+	models.AggregateHourly: time.Hour,
+	models.AggregateDaily:  24 * time.Hour,
+}
+
+func writeCSV[T any](p *progress, path string, rows []T, columns []string, fields []field[T]) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	if err := w.Write(columns); err != nil {
+		return err
+	}
+	record := make([]string, len(columns))
+	for i, r := range rows {
+		if err := p.row(i); err != nil {
+			return err
+		}
+		for c, f := range fields {
+			record[c] = formatCell(f(r))
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeJSON writes the rows as an indented array. With columns selected
+// each row is an object with just those keys, in that order, one per line.
+func writeJSON[T any](p *progress, path string, rows []T, columns []string, fields []field[T]) error {
+	if len(columns) == 0 {
+		if rows == nil {
+			rows = []T{}
+		}
+		data, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0644)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	w.WriteString("[")
+	for i, r := range rows {
+		if err := p.row(i); err != nil {
+			return err
+		}
+		if i > 0 {
+			w.WriteString(",")
+		}
+		w.WriteString("\n  ")
+		if err := writeObject(w, r, columns, fields); err != nil {
+			return err
+		}
+	}
+	w.WriteString("\n]\n")
+	return w.Flush()
+}
+
+func writeNDJSON[T any](p *progress, path string, rows []T, columns []string, fields []field[T]) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for i, r := range rows {
+		if err := p.row(i); err != nil {
+			return err
+		}
+		if len(columns) == 0 {
+			err = enc.Encode(r)
+		} else if err = writeObject(w, r, columns, fields); err == nil {
+			err = w.WriteByte('\n')
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// writeObject writes a row as a JSON object with the given keys in order
+func writeObject[T any](w *bufio.Writer, row T, columns []string, fields []field[T]) error {
+	w.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		key, _ := json.Marshal(columns[i])
+		value, err := json.Marshal(f(row))
+		if err != nil {
+			return err
+		}
+		w.Write(key)
+		w.WriteByte(':')
+		w.Write(value)
+	}
+	return w.WriteByte('}')
+}
@@ -0,0 +1,596 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/data"
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func waitForState(t *testing.T, m *Manager, id string, state models.ExportState) models.ExportStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, err := m.ExportStatus(id)
+		if err != nil {
+			t.Fatalf("ExportStatus failed: %v", err)
+		}
+		if status.State == state {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Export %s did not reach state %s", id, state)
+	return models.ExportStatus{}
+}
+
+func TestCreateExportCSV(t *testing.T) {
+	tmp := t.TempDir()
+	store := data.NewStorage(tmp + "/data")
+
+	ts := time.Date(2023, 11, 15, 12, 0, 0, 0, time.UTC)
+	_ = store.SaveResult(models.TestResult{Ts: ts.UnixMilli(), Id: "ep-1", Ms: 50, St: 0})
+	_ = store.SaveResult(models.TestResult{Ts: ts.Add(time.Minute).UnixMilli(), Id: "ep-2", Ms: 60, St: 0})
+
+	m := NewManager(context.Background(), store, tmp+"/exports", 1)
+	defer m.Stop()
+
+	status, err := m.CreateExport(models.ExportRequest{
+		Format:      models.ExportCSV,
+		Start:       ts.Add(-time.Hour).UnixMilli(),
+		End:         ts.Add(time.Hour).UnixMilli(),
+		EndpointIDs: []string{"ep-1"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExport failed: %v", err)
+	}
+
+	status = waitForState(t, m, status.ID, models.ExportCompleted)
+	if status.Rows != 1 {
+		t.Errorf("Expected 1 row, got %d", status.Rows)
+	}
+
+	content, err := os.ReadFile(status.Path)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	if !strings.Contains(string(content), "ep-1") || strings.Contains(string(content), "ep-2") {
+		t.Errorf("Unexpected export content: %s", content)
+	}
+}
+
+func TestCreateExportInvalid(t *testing.T) {
+	m := NewManager(context.Background(), data.NewStorage(t.TempDir()), t.TempDir(), 1)
+	defer m.Stop()
+
+	if _, err := m.CreateExport(models.ExportRequest{Format: "xml"}); err == nil {
+		t.Errorf("Expected error for unsupported format")
+	}
+	if _, err := m.CreateExport(models.ExportRequest{Format: models.ExportCSV, Start: 10, End: 5}); err == nil {
+		t.Errorf("Expected error for inverted range")
+	}
+	if _, err := m.CreateExport(models.ExportRequest{Format: models.ExportCSV, Passphrase: "secret"}); err == nil {
+		t.Errorf("Expected error for passphrase without zip compression")
+	}
+}
+
+func TestQueuePrioritizesSmallExports(t *testing.T) {
+	m := &Manager{jobs: make(map[string]*job)}
+
+	day := int64(24 * time.Hour / time.Millisecond)
+	large1 := &job{req: models.ExportRequest{End: 30 * day}}
+	large2 := &job{req: models.ExportRequest{End: 7 * day}}
+	small1 := &job{req: models.ExportRequest{End: day / 2}}
+	small2 := &job{req: models.ExportRequest{End: day / 4}}
+
+	m.enqueue(large1)
+	m.enqueue(small1)
+	m.enqueue(large2)
+	m.enqueue(small2)
+
+	expected := []*job{small1, small2, large1, large2}
+	for i, j := range expected {
+		if m.queue[i] != j {
+			t.Fatalf("Unexpected queue order at position %d", i)
+		}
+	}
+
+	small2.status.State = models.ExportQueued
+	if pos := m.statusLocked(small2).Position; pos != 2 {
+		t.Errorf("Expected position 2, got %d", pos)
+	}
+}
+
+func TestRetryExport(t *testing.T) {
+	tmp := t.TempDir()
+	store := data.NewStorage(tmp + "/data")
+
+	// Point the export directory at a regular file so the first attempt fails
+	blocker := tmp + "/blocked"
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(context.Background(), store, blocker, 1)
+	defer m.Stop()
+
+	status, err := m.CreateExport(models.ExportRequest{Format: models.ExportJSON})
+	if err != nil {
+		t.Fatalf("CreateExport failed: %v", err)
+	}
+	waitForState(t, m, status.ID, models.ExportFailed)
+
+	if _, err := m.RetryExport("missing"); err == nil {
+		t.Errorf("Expected error retrying unknown export")
+	}
+
+	_ = os.Remove(blocker)
+	status, err = m.RetryExport(status.ID)
+	if err != nil {
+		t.Fatalf("RetryExport failed: %v", err)
+	}
+	status = waitForState(t, m, status.ID, models.ExportCompleted)
+	if status.Attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", status.Attempts)
+	}
+	if _, err := os.Stat(status.Path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Temporary export file left behind")
+	}
+
+	if _, err := m.RetryExport(status.ID); err == nil {
+		t.Errorf("Expected error retrying a completed export")
+	}
+}
+
+func TestStopPersistsInterruptedExports(t *testing.T) {
+	dir := t.TempDir()
+	store := data.NewStorage(t.TempDir())
+
+	// A manager without workers keeps its jobs queued until stopped
+	m := &Manager{Ctx: context.Background(), Storage: store, dir: dir, jobs: make(map[string]*job)}
+	m.cond = sync.NewCond(&m.mu)
+	plain := &job{status: models.ExportStatus{ID: "plain", State: models.ExportQueued}, req: models.ExportRequest{Format: models.ExportJSON}}
+	locked := &job{status: models.ExportStatus{ID: "locked", State: models.ExportQueued}, req: models.ExportRequest{Format: models.ExportCSV, Compression: models.CompressionZip, Passphrase: "secret"}}
+	for _, j := range []*job{plain, locked} {
+		m.jobs[j.status.ID] = j
+		m.enqueue(j)
+	}
+	m.Stop()
+
+	saved, err := os.ReadFile(dir + "/exports.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(saved), "secret") {
+		t.Errorf("Passphrase was persisted")
+	}
+
+	restarted := NewManager(context.Background(), store, dir, 1)
+	defer restarted.Stop()
+	for _, id := range []string{"plain", "locked"} {
+		if status, err := restarted.ExportStatus(id); err != nil || status.State != models.ExportInterrupted {
+			t.Errorf("Expected %s to be restored as interrupted, got %+v (%v)", id, status, err)
+		}
+	}
+	if _, err := restarted.RetryExport("locked"); err == nil {
+		t.Errorf("Expected error retrying a password protected export without its passphrase")
+	}
+	status, err := restarted.RetryExport("plain")
+	if err != nil {
+		t.Fatalf("RetryExport failed: %v", err)
+	}
+	waitForState(t, restarted, status.ID, models.ExportCompleted)
+}
+
+func TestPresetRequest(t *testing.T) {
+	preset := models.ExportPreset{
+		Name:       "weekly",
+		RangeHours: 168,
+		Request: models.ExportRequest{
+			Format:  models.ExportCSV,
+			Columns: []string{"id", "ms"},
+		},
+	}
+	if err := ValidatePreset(preset); err != nil {
+		t.Fatalf("ValidatePreset failed: %v", err)
+	}
+	if err := ValidatePreset(models.ExportPreset{Name: "empty"}); err == nil {
+		t.Errorf("Expected error for preset without range")
+	}
+
+	now := time.Date(2023, 11, 15, 12, 0, 0, 0, time.UTC)
+	req := PresetRequest(preset, now, "secret")
+	if req.End != now.UnixMilli() || req.Start != now.AddDate(0, 0, -7).UnixMilli() {
+		t.Errorf("Unexpected range %d-%d", req.Start, req.End)
+	}
+	if req.Passphrase != "secret" || len(req.Columns) != 2 {
+		t.Errorf("Preset request fields not carried over: %+v", req)
+	}
+}
+
+func TestCreateExportColumnsAndDestination(t *testing.T) {
+	tmp := t.TempDir()
+	store := data.NewStorage(tmp + "/data")
+	ts := time.Date(2023, 11, 15, 12, 0, 0, 0, time.UTC)
+	_ = store.SaveResult(models.TestResult{Ts: ts.UnixMilli(), Id: "ep-1", Ms: 50, St: 0})
+
+	m := NewManager(context.Background(), store, tmp+"/exports", 1)
+	defer m.Stop()
+
+	if _, err := m.CreateExport(models.ExportRequest{Format: models.ExportCSV, Columns: []string{"bogus"}}); err == nil {
+		t.Errorf("Expected error for unknown column")
+	}
+
+	dest := tmp + "/elsewhere"
+	status, err := m.CreateExport(models.ExportRequest{
+		Format:      models.ExportCSV,
+		Start:       ts.Add(-time.Hour).UnixMilli(),
+		End:         ts.Add(time.Hour).UnixMilli(),
+		Columns:     []string{"ms", "id"},
+		Destination: dest,
+	})
+	if err != nil {
+		t.Fatalf("CreateExport failed: %v", err)
+	}
+	status = waitForState(t, m, status.ID, models.ExportCompleted)
+
+	if !strings.HasPrefix(status.Path, dest) {
+		t.Errorf("Expected export in %s, got %s", dest, status.Path)
+	}
+	content, _ := os.ReadFile(status.Path)
+	if string(content) != "ms,id\n50,ep-1\n" {
+		t.Errorf("Unexpected export content: %q", content)
+	}
+}
+
+func TestCreateExportNDJSON(t *testing.T) {
+	tmp := t.TempDir()
+	store := data.NewStorage(tmp + "/data")
+	ts := time.Date(2023, 11, 15, 12, 0, 0, 0, time.UTC)
+	_ = store.SaveResult(models.TestResult{Ts: ts.UnixMilli(), Id: "ep-1", Ms: 50, St: 0})
+	_ = store.SaveResult(models.TestResult{Ts: ts.Add(time.Minute).UnixMilli(), Id: "ep-2", Ms: 60, St: 2})
+
+	m := NewManager(context.Background(), store, tmp+"/exports", 1)
+	defer m.Stop()
+
+	status, err := m.CreateExport(models.ExportRequest{
+		Format: models.ExportNDJSON,
+		Start:  ts.Add(-time.Hour).UnixMilli(),
+		End:    ts.Add(time.Hour).UnixMilli(),
+	})
+	if err != nil {
+		t.Fatalf("CreateExport failed: %v", err)
+	}
+	status = waitForState(t, m, status.ID, models.ExportCompleted)
+
+	content, _ := os.ReadFile(status.Path)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d: %q", len(lines), content)
+	}
+	if !strings.HasPrefix(lines[0], "{") || !strings.Contains(lines[1], `"id":"ep-2"`) {
+		t.Errorf("Unexpected NDJSON content: %q", content)
+	}
+}
+
+func TestCreateExportAggregated(t *testing.T) {
+	tmp := t.TempDir()
+	store := data.NewStorage(tmp + "/data")
+	ts := time.Date(2023, 11, 15, 12, 0, 0, 0, time.UTC)
+	for i, ms := range []int64{10, 20, 30} {
+		_ = store.SaveResult(models.TestResult{Ts: ts.Add(time.Duration(i) * time.Minute).UnixMilli(), Id: "ep-1", Ms: ms})
+	}
+	_ = store.SaveResult(models.TestResult{Ts: ts.Add(3 * time.Minute).UnixMilli(), Id: "ep-1", St: models.TestStatusTimeout})
+	_ = store.SaveResult(models.TestResult{Ts: ts.Add(time.Hour).UnixMilli(), Id: "ep-1", Ms: 40})
+
+	m := NewManager(context.Background(), store, tmp+"/exports", 1)
+	defer m.Stop()
+
+	if _, err := m.CreateExport(models.ExportRequest{Format: models.ExportCSV, Aggregate: "weekly"}); err == nil {
+		t.Errorf("Expected error for unknown aggregation")
+	}
+	if _, err := m.CreateExport(models.ExportRequest{Format: models.ExportCSV, Aggregate: models.AggregateDaily, Columns: []string{"ms"}}); err == nil {
+		t.Errorf("Expected error for a result column in an aggregated export")
+	}
+
+	window := models.ExportRequest{Start: ts.Add(-time.Hour).UnixMilli(), End: ts.Add(2 * time.Hour).UnixMilli()}
+
+	req := window
+	req.Format, req.Aggregate = models.ExportCSV, models.AggregateHourly
+	req.Columns = []string{"start", "count", "availability", "avg_ms", "p50_ms"}
+	status, err := m.CreateExport(req)
+	if err != nil {
+		t.Fatalf("CreateExport failed: %v", err)
+	}
+	status = waitForState(t, m, status.ID, models.ExportCompleted)
+	content, _ := os.ReadFile(status.Path)
+	want := fmt.Sprintf("start,count,availability,avg_ms,p50_ms\n%d,4,75.00,20.00,20.00\n%d,1,100.00,40.00,40.00\n",
+		ts.UnixMilli(), ts.Add(time.Hour).UnixMilli())
+	if status.Rows != 2 || string(content) != want {
+		t.Errorf("Unexpected aggregated CSV (%d rows): %q", status.Rows, content)
+	}
+
+	req = window
+	req.Format, req.Aggregate = models.ExportJSON, models.AggregateDaily
+	status, err = m.CreateExport(req)
+	if err != nil {
+		t.Fatalf("CreateExport failed: %v", err)
+	}
+	status = waitForState(t, m, status.ID, models.ExportCompleted)
+	var aggs []models.AggregatedResult
+	content, _ = os.ReadFile(status.Path)
+	if err := json.Unmarshal(content, &aggs); err != nil || len(aggs) != 1 || aggs[0].Count != 5 || aggs[0].P95Ms == 0 {
+		t.Errorf("Unexpected aggregated JSON: %s (%v)", content, err)
+	}
+}
+
+func TestCreateExportRegionColumns(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip(err)
+	}
+	tmp := t.TempDir()
+	store := data.NewStorage(tmp + "/data")
+	ts := time.Date(2023, 11, 15, 12, 0, 0, 0, time.UTC)
+	_ = store.SaveResult(models.TestResult{Ts: ts.UnixMilli(), Id: "ep-1", Ms: 10})
+	_ = store.SaveResult(models.TestResult{Ts: ts.UnixMilli(), Id: "gone", Ms: 10})
+
+	m := NewManager(context.Background(), store, tmp+"/exports", 1)
+	m.EndpointRegions = func() map[string]models.EndpointRegion {
+		return map[string]models.EndpointRegion{"ep-1": {
+			Region: "Office", Location: &models.GeoPoint{Latitude: 12.97, Longitude: 77.59}, Timezone: "Asia/Kolkata",
+		}}
+	}
+	defer m.Stop()
+
+	status, err := m.CreateExport(models.ExportRequest{
+		Format: models.ExportCSV, Aggregate: models.AggregateDaily,
+		Start: ts.Add(-time.Hour).UnixMilli(), End: ts.Add(time.Hour).UnixMilli(),
+		Columns: []string{"start", "id", "region", "latitude", "longitude", "timezone"},
+	})
+	if err != nil {
+		t.Fatalf("CreateExport failed: %v", err)
+	}
+	status = waitForState(t, m, status.ID, models.ExportCompleted)
+	content, _ := os.ReadFile(status.Path)
+	midnight := time.Date(2023, 11, 15, 0, 0, 0, 0, kolkata).UnixMilli()
+	want := fmt.Sprintf("start,id,region,latitude,longitude,timezone\n%d,ep-1,Office,12.97,77.59,Asia/Kolkata\n%d,gone,,,,\n",
+		midnight, ts.Truncate(24*time.Hour).UnixMilli())
+	if string(content) != want {
+		t.Errorf("Unexpected export:\n%s\nwant:\n%s", content, want)
+	}
+}
+
+func TestCreateExportJSONColumns(t *testing.T) {
+	tmp := t.TempDir()
+	store := data.NewStorage(tmp + "/data")
+	ts := time.Date(2023, 11, 15, 12, 0, 0, 0, time.UTC)
+	_ = store.SaveResult(models.TestResult{Ts: ts.UnixMilli(), Id: "ep-1", Ms: 50, Tags: map[string]string{"asn": "AS15169"}})
+	_ = store.SaveResult(models.TestResult{Ts: ts.Add(time.Minute).UnixMilli(), Id: "ep-2", Ms: 60, St: 2})
+
+	m := NewManager(context.Background(), store, tmp+"/exports", 1)
+	defer m.Stop()
+
+	if _, err := m.CreateExport(models.ExportRequest{Format: models.ExportJSON, Columns: []string{"tags."}}); err == nil {
+		t.Errorf("Expected error for a tag column without a tag")
+	}
+
+	for format, want := range map[models.ExportFormat]string{
+		models.ExportJSON:   "[\n  {\"tags.asn\":\"AS15169\",\"ms\":50,\"id\":\"ep-1\"},\n  {\"tags.asn\":null,\"ms\":60,\"id\":\"ep-2\"}\n]\n",
+		models.ExportNDJSON: "{\"tags.asn\":\"AS15169\",\"ms\":50,\"id\":\"ep-1\"}\n{\"tags.asn\":null,\"ms\":60,\"id\":\"ep-2\"}\n",
+		models.ExportCSV:    "tags.asn,ms,id\nAS15169,50,ep-1\n,60,ep-2\n",
+	} {
+		status, err := m.CreateExport(models.ExportRequest{
+			Format:  format,
+			Start:   ts.Add(-time.Hour).UnixMilli(),
+			End:     ts.Add(time.Hour).UnixMilli(),
+			Columns: []string{"tags.asn", "ms", "id"},
+		})
+		if err != nil {
+			t.Fatalf("CreateExport %s failed: %v", format, err)
+		}
+		status = waitForState(t, m, status.ID, models.ExportCompleted)
+		if content, _ := os.ReadFile(status.Path); string(content) != want {
+			t.Errorf("Unexpected %s content: %q", format, content)
+		}
+	}
+}
+
+func TestSetDir(t *testing.T) {
+	tmp := t.TempDir()
+	store := data.NewStorage(tmp + "/data")
+	ts := time.Date(2023, 11, 15, 12, 0, 0, 0, time.UTC)
+	_ = store.SaveResult(models.TestResult{Ts: ts.UnixMilli(), Id: "ep-1", Ms: 50})
+
+	m := NewManager(context.Background(), store, tmp+"/exports", 1)
+	defer m.Stop()
+
+	if err := m.SetDir("relative/exports"); err == nil {
+		t.Errorf("Expected error for a relative directory")
+	}
+	blocker := tmp + "/file"
+	_ = os.WriteFile(blocker, nil, 0644)
+	if err := m.SetDir(blocker + "/exports"); err == nil {
+		t.Errorf("Expected error for a directory that can't be created")
+	}
+	if m.Dir() != tmp+"/exports" {
+		t.Errorf("Expected the directory to be kept after failed changes, got %s", m.Dir())
+	}
+
+	moved := tmp + "/moved"
+	if err := m.SetDir(moved); err != nil {
+		t.Fatalf("SetDir failed: %v", err)
+	}
+	status, err := m.CreateExport(models.ExportRequest{
+		Format: models.ExportCSV,
+		Start:  ts.Add(-time.Hour).UnixMilli(),
+		End:    ts.Add(time.Hour).UnixMilli(),
+	})
+	if err != nil {
+		t.Fatalf("CreateExport failed: %v", err)
+	}
+	status = waitForState(t, m, status.ID, models.ExportCompleted)
+	if !strings.HasPrefix(status.Path, moved+"/") {
+		t.Errorf("Expected export in %s, got %s", moved, status.Path)
+	}
+	if _, err := os.Stat(moved + "/exports.json"); err != nil {
+		t.Errorf("Expected export history in the new directory: %v", err)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	tmp := t.TempDir()
+	store := data.NewStorage(tmp + "/data")
+	ts := time.Date(2023, 11, 15, 12, 0, 0, 0, time.UTC)
+	_ = store.SaveResult(models.TestResult{Ts: ts.UnixMilli(), Id: "ep-1", Ms: 50})
+
+	m := NewManager(context.Background(), store, tmp+"/exports", 1)
+	m.AuditPath = tmp + "/export-audit.ndjson"
+	defer m.Stop()
+
+	origin := &models.ExportOrigin{Interface: models.OriginAPI, Actor: "ops", Client: "192.0.2.1"}
+	status, err := m.CreateExport(models.ExportRequest{
+		Format:      models.ExportCSV,
+		Start:       ts.Add(-time.Hour).UnixMilli(),
+		End:         ts.Add(time.Hour).UnixMilli(),
+		EndpointIDs: []string{"ep-1"},
+		Compression: models.CompressionZip,
+		Passphrase:  "secret",
+		Origin:      origin,
+	})
+	if err != nil {
+		t.Fatalf("CreateExport failed: %v", err)
+	}
+	status = waitForState(t, m, status.ID, models.ExportCompleted)
+
+	entries, err := m.Audit()
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].State != models.ExportCompleted || entries[1].State != models.ExportQueued {
+		t.Fatalf("Expected completed and queued entries, most recent first, got %+v", entries)
+	}
+	done := entries[0]
+	if done.ExportID != status.ID || done.Rows != 1 || done.Path != status.Path || !done.Encrypted {
+		t.Errorf("Unexpected completed entry %+v", done)
+	}
+	if done.Request.Origin == nil || *done.Request.Origin != *origin || !slices.Equal(done.Request.EndpointIDs, []string{"ep-1"}) {
+		t.Errorf("Expected the request filters and origin, got %+v", done.Request)
+	}
+	raw, _ := os.ReadFile(m.AuditPath)
+	if strings.Contains(string(raw), "secret") {
+		t.Errorf("Passphrase was written to the audit log")
+	}
+}
+
+func TestProgress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := newProgress(ctx)
+	p.total.Store(50_000) // Estimate while reading
+
+	var status models.ExportStatus
+	p.fill(&status, time.Now())
+	if status.RowsTotal != 50_000 || status.Rows != 0 || status.EtaMs != 0 {
+		t.Errorf("Unexpected progress while reading: %+v", status)
+	}
+
+	p.start(40_000)
+	started := time.Unix(0, p.writing.Load())
+	for i := range 10_001 {
+		if err := p.row(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.fill(&status, started.Add(time.Second))
+	if status.RowsTotal != 40_000 || status.Rows != 10_000 || status.EtaMs != 3000 {
+		t.Errorf("Expected 3s left after a quarter in 1s: %+v", status)
+	}
+
+	cancel()
+	if err := p.row(progressInterval); err == nil {
+		t.Errorf("Expected writers to stop once cancelled")
+	}
+}
+
+func TestTail(t *testing.T) {
+	path := t.TempDir() + "/tail/results.ndjson"
+	tail := &Tail{}
+
+	// Disabled tail ignores results
+	if err := tail.Append(models.TestResult{Id: "ignored"}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	if err := tail.SetPath(path); err != nil {
+		t.Fatalf("SetPath failed: %v", err)
+	}
+	_ = tail.Append(models.TestResult{Ts: 1, Id: "ep-1"})
+	_ = tail.Append(models.TestResult{Ts: 2, Id: "ep-2"})
+	tail.Close()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(content), "\n") != 2 || strings.Contains(string(content), "ignored") {
+		t.Errorf("Unexpected tail content: %q", content)
+	}
+}
+
+func TestCreateExportICS(t *testing.T) {
+	tmp := t.TempDir()
+	store := data.NewStorage(tmp + "/data")
+	ts := time.Date(2023, 11, 15, 12, 0, 0, 0, time.UTC)
+	_ = store.SaveResult(models.TestResult{Ts: ts.UnixMilli(), Id: "ep-1", St: 2})
+	_ = store.SaveResult(models.TestResult{Ts: ts.Add(5 * time.Minute).UnixMilli(), Id: "ep-1", St: 0})
+
+	m := NewManager(context.Background(), store, tmp+"/exports", 1)
+	m.EndpointNames = func() map[string]string { return map[string]string{"ep-1": "Office, Router"} }
+	defer m.Stop()
+
+	status, err := m.CreateExport(models.ExportRequest{
+		Format: models.ExportICS,
+		Start:  ts.Add(-time.Hour).UnixMilli(),
+		End:    ts.Add(time.Hour).UnixMilli(),
+	})
+	if err != nil {
+		t.Fatalf("CreateExport failed: %v", err)
+	}
+	status = waitForState(t, m, status.ID, models.ExportCompleted)
+	if status.Rows != 1 {
+		t.Errorf("Expected 1 row for the one outage, got %d", status.Rows)
+	}
+
+	content, _ := os.ReadFile(status.Path)
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"DTSTART:20231115T120000Z\r\n",
+		"DTEND:20231115T120500Z\r\n",
+		`SUMMARY:Outage: Office\, Router`,
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("ICS export missing %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestFoldICSLine(t *testing.T) {
+	long := "DESCRIPTION:" + strings.Repeat("x", 200)
+	folded := foldICSLine(long)
+	for _, l := range strings.Split(folded, "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("Line longer than 75 octets: %d", len(l))
+		}
+	}
+	if strings.ReplaceAll(folded, "\r\n ", "") != long {
+		t.Errorf("Unfolding does not restore the original line")
+	}
+}
@@ -0,0 +1,97 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/i18n"
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+const icsTimeFormat = "20060102T150405Z"
+
+// writeICS writes outages as an iCalendar file (RFC 5545) so outage windows can
+// be overlaid on team calendars
+func writeICS(path string, outages []models.Outage, names map[string]string, now time.Time) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	line := func(s string) {
+		_, _ = w.WriteString(foldICSLine(s) + "\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//NetMonitor//Outages//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+
+	for _, o := range outages {
+		name := names[o.EndpointID]
+		if name == "" {
+			name = o.EndpointID
+		}
+
+		start := time.UnixMilli(o.Start).UTC()
+		end := time.UnixMilli(o.End).UTC()
+		if !end.After(start) {
+			// Calendars drop zero-length events, give single failures a minimum length
+			end = start.Add(time.Minute)
+		}
+
+		description := i18n.T("export.ics.description", o.Failures, o.EndpointID)
+		if o.Ongoing {
+			description += i18n.T("export.ics.ongoing")
+		}
+
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:%s-%d@netmonitor", o.EndpointID, o.Start))
+		line("DTSTAMP:" + now.UTC().Format(icsTimeFormat))
+		line("DTSTART:" + start.Format(icsTimeFormat))
+		line("DTEND:" + end.Format(icsTimeFormat))
+		line("SUMMARY:" + escapeICSText(i18n.T("export.ics.summary", name)))
+		line("DESCRIPTION:" + escapeICSText(description))
+		line("CATEGORIES:OUTAGE")
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return w.Flush()
+}
+
+func escapeICSText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// foldICSLine splits lines longer than 75 octets as required by RFC 5545,
+// continuing them on the next line with a leading space
+func foldICSLine(s string) string {
+	const limit = 75
+	if len(s) <= limit {
+		return s
+	}
+
+	var b strings.Builder
+	width := limit
+	for len(s) > width {
+		// Don't split in the middle of a UTF-8 sequence
+		cut := width
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		width = limit - 1
+	}
+	b.WriteString(s)
+	return b.String()
+}
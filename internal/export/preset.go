@@ -0,0 +1,29 @@
+package export
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// ValidatePreset checks the preset fields that don't depend on run time
+func ValidatePreset(p models.ExportPreset) error {
+	if p.Name == "" {
+		return fmt.Errorf("preset name is required")
+	}
+	if p.RangeHours <= 0 {
+		return fmt.Errorf("preset range must be greater than 0 hours")
+	}
+	return nil
+}
+
+// PresetRequest builds the export request for a preset run at the given time.
+// Passphrases are never stored with presets, so one has to be supplied per run.
+func PresetRequest(p models.ExportPreset, now time.Time, passphrase string) models.ExportRequest {
+	req := p.Request
+	req.End = now.UnixMilli()
+	req.Start = now.Add(-time.Duration(p.RangeHours) * time.Hour).UnixMilli()
+	req.Passphrase = passphrase
+	return req
+}
@@ -0,0 +1,55 @@
+package export
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// progressInterval is how many rows writers write between progress updates
+// and cancellation checks
+const progressInterval = 1000
+
+// progress tracks the rows a running export expects and has written so far.
+// Writers report through row, which also stops them once ctx is done.
+type progress struct {
+	ctx     context.Context
+	total   atomic.Int64
+	written atomic.Int64
+	// writing is when the export started writing rows, in UnixNano, zero
+	// while it is still reading results
+	writing atomic.Int64
+}
+
+func newProgress(ctx context.Context) *progress {
+	return &progress{ctx: ctx}
+}
+
+// start records the exact row count once the rows are known
+func (p *progress) start(total int) {
+	p.total.Store(int64(total))
+	p.writing.Store(time.Now().UnixNano())
+}
+
+// row is called by writers before writing row i
+func (p *progress) row(i int) error {
+	if i%progressInterval != 0 {
+		return nil
+	}
+	p.written.Store(int64(i))
+	return p.ctx.Err()
+}
+
+// fill sets the progress of a running export in its status. The ETA assumes
+// the remaining rows are written at the rate of those written so far.
+func (p *progress) fill(status *models.ExportStatus, now time.Time) {
+	written, total := p.written.Load(), p.total.Load()
+	status.Rows = int(written)
+	status.RowsTotal = int(total)
+	if started := p.writing.Load(); started != 0 && written > 0 && total > written {
+		elapsed := now.Sub(time.Unix(0, started))
+		status.EtaMs = (elapsed * time.Duration(total-written) / time.Duration(written)).Milliseconds()
+	}
+}
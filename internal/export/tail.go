@@ -0,0 +1,72 @@
+package export
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// Tail appends every stored result to a file as a line of NDJSON so log
+// shippers (Logstash, Vector, Loki) can follow it like any other log file
+type Tail struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	enc  *json.Encoder
+}
+
+// SetPath switches the tail to a new file. An empty path disables it.
+func (t *Tail) SetPath(path string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if path == t.path {
+		return nil
+	}
+	t.closeLocked()
+	if path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	t.path = path
+	t.file = file
+	t.enc = json.NewEncoder(file)
+	return nil
+}
+
+// Append writes the result as a single line, doing nothing if the tail is disabled
+func (t *Tail) Append(result models.TestResult) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.enc == nil {
+		return nil
+	}
+	return t.enc.Encode(result)
+}
+
+// Close closes the current tail file
+func (t *Tail) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeLocked()
+}
+
+func (t *Tail) closeLocked() {
+	if t.file != nil {
+		_ = t.file.Close()
+	}
+	t.path = ""
+	t.file = nil
+	t.enc = nil
+}
@@ -0,0 +1,214 @@
+package export
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WinZip AES (AE-2) parameters, see https://www.winzip.com/en/support/aes-encryption/
+const (
+	aesMethod        = 99
+	aesExtraID       = 0x9901
+	aesVendorVersion = 2 // AE-2: CRC is not stored, the HMAC authenticates the data
+	aesStrength256   = 3
+	aesSaltSize      = 16
+	aesKeySize       = 32
+	aesIterations    = 1000
+	aesVerifierSize  = 2
+	aesAuthCodeSize  = 10
+)
+
+// writeZip packs the file at srcPath into a zip archive at dstPath. When a
+// passphrase is given the entry is encrypted with WinZip-compatible AES-256,
+// which 7-Zip, WinZip and most desktop archivers can open.
+func writeZip(srcPath, dstPath, passphrase string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	zw := zip.NewWriter(dst)
+	fh := &zip.FileHeader{
+		Name:     exportEntryName(srcPath),
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	}
+
+	if passphrase == "" {
+		w, err := zw.CreateHeader(fh)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, src); err != nil {
+			return err
+		}
+		return zw.Close()
+	}
+
+	// The encrypted payload is staged in a temporary file because the raw
+	// entry header needs the final compressed size up front
+	payload, err := os.CreateTemp(filepath.Dir(dstPath), "aes-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(payload.Name())
+	defer payload.Close()
+
+	if err := encryptAES(payload, src, passphrase); err != nil {
+		return err
+	}
+	payloadInfo, err := payload.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := payload.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	fh.Method = aesMethod
+	fh.Flags |= 0x1 // encrypted
+	fh.CRC32 = 0
+	fh.CompressedSize64 = uint64(payloadInfo.Size())
+	fh.UncompressedSize64 = uint64(info.Size())
+	fh.Extra = aesExtraField(zip.Deflate)
+	// CreateRaw does not derive the MS-DOS timestamp from Modified
+	fh.ModifiedDate, fh.ModifiedTime = msDosTime(fh.Modified)
+
+	w, err := zw.CreateRaw(fh)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, payload); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// exportEntryName strips the temporary suffix so the archive entry carries
+// the final export file name
+func exportEntryName(srcPath string) string {
+	name := filepath.Base(srcPath)
+	if ext := filepath.Ext(name); ext == ".tmp" {
+		name = name[:len(name)-len(ext)]
+	}
+	return name
+}
+
+// encryptAES deflates src and writes salt, password verifier, ciphertext and
+// authentication code to w in the AE-2 layout
+func encryptAES(w io.Writer, src io.Reader, passphrase string) error {
+	salt := make([]byte, aesSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	keys, err := pbkdf2.Key(sha1.New, passphrase, salt, aesIterations, 2*aesKeySize+aesVerifierSize)
+	if err != nil {
+		return err
+	}
+	encKey, authKey, verifier := keys[:aesKeySize], keys[aesKeySize:2*aesKeySize], keys[2*aesKeySize:]
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(salt); err != nil {
+		return err
+	}
+	if _, err := w.Write(verifier); err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha1.New, authKey)
+	enc := &ctrWriter{block: block, w: io.MultiWriter(w, mac)}
+
+	fw, err := flate.NewWriter(enc, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fw, src); err != nil {
+		return err
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+
+	_, err = w.Write(mac.Sum(nil)[:aesAuthCodeSize])
+	return err
+}
+
+func aesExtraField(method uint16) []byte {
+	extra := make([]byte, 11)
+	binary.LittleEndian.PutUint16(extra[0:], aesExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], aesVendorVersion)
+	copy(extra[6:], "AE")
+	extra[8] = aesStrength256
+	binary.LittleEndian.PutUint16(extra[9:], method)
+	return extra
+}
+
+func msDosTime(t time.Time) (uint16, uint16) {
+	date := uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	clock := uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, clock
+}
+
+// ctrWriter applies AES in counter mode the way WinZip does it: a little-endian
+// counter starting at 1, which differs from crypto/cipher's big-endian CTR
+type ctrWriter struct {
+	block   cipher.Block
+	w       io.Writer
+	counter uint64
+	stream  [aes.BlockSize]byte
+	used    int
+	buf     []byte
+}
+
+func (c *ctrWriter) Write(p []byte) (int, error) {
+	if cap(c.buf) < len(p) {
+		c.buf = make([]byte, len(p))
+	}
+	out := c.buf[:len(p)]
+
+	for i := range p {
+		if c.counter == 0 || c.used == aes.BlockSize {
+			c.counter++
+			var ctr [aes.BlockSize]byte
+			binary.LittleEndian.PutUint64(ctr[:], c.counter)
+			c.block.Encrypt(c.stream[:], ctr[:])
+			c.used = 0
+		}
+		out[i] = p[i] ^ c.stream[c.used]
+		c.used++
+	}
+
+	if _, err := c.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
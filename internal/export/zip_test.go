@@ -0,0 +1,130 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// decryptAES reverses encryptAES so the AE-2 layout can be checked without an
+// external archiver
+func decryptAES(t *testing.T, payload []byte, passphrase string) []byte {
+	t.Helper()
+
+	salt := payload[:aesSaltSize]
+	verifier := payload[aesSaltSize : aesSaltSize+aesVerifierSize]
+	ciphertext := payload[aesSaltSize+aesVerifierSize : len(payload)-aesAuthCodeSize]
+	authCode := payload[len(payload)-aesAuthCodeSize:]
+
+	keys, err := pbkdf2.Key(sha1.New, passphrase, salt, aesIterations, 2*aesKeySize+aesVerifierSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(keys[2*aesKeySize:], verifier) {
+		t.Fatalf("Password verifier mismatch")
+	}
+
+	mac := hmac.New(sha1.New, keys[aesKeySize:2*aesKeySize])
+	mac.Write(ciphertext)
+	if !hmac.Equal(mac.Sum(nil)[:aesAuthCodeSize], authCode) {
+		t.Fatalf("Authentication code mismatch")
+	}
+
+	block, err := aes.NewCipher(keys[:aesKeySize])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plain bytes.Buffer
+	// CTR mode is symmetric, so running the ciphertext through ctrWriter decrypts it
+	dec := &ctrWriter{block: block, w: &plain}
+	if _, err := dec.Write(ciphertext); err != nil {
+		t.Fatal(err)
+	}
+	return plain.Bytes()
+}
+
+func TestWriteZipEncrypted(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "netmonitor-export-1.csv.tmp")
+	content := bytes.Repeat([]byte("1700000000000,abc1234,42,0\n"), 100)
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(tmp, "export.zip")
+	if err := writeZip(src, dst, "s3cret"); err != nil {
+		t.Fatalf("writeZip failed: %v", err)
+	}
+
+	r, err := zip.OpenReader(dst)
+	if err != nil {
+		t.Fatalf("Failed to open zip: %v", err)
+	}
+	defer r.Close()
+
+	if len(r.File) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(r.File))
+	}
+	f := r.File[0]
+	if f.Name != "netmonitor-export-1.csv" {
+		t.Errorf("Unexpected entry name %s", f.Name)
+	}
+	if f.Method != aesMethod || f.Flags&0x1 == 0 {
+		t.Errorf("Entry is not marked as AES encrypted")
+	}
+
+	raw, err := f.OpenRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := io.ReadAll(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressed := decryptAES(t, payload, "s3cret")
+	plain, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		t.Fatalf("Failed to inflate: %v", err)
+	}
+	if !bytes.Equal(plain, content) {
+		t.Errorf("Decrypted content does not match")
+	}
+}
+
+func TestWriteZipPlain(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "netmonitor-export-1.json.tmp")
+	if err := os.WriteFile(src, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(tmp, "export.zip")
+	if err := writeZip(src, dst, ""); err != nil {
+		t.Fatalf("writeZip failed: %v", err)
+	}
+
+	r, err := zip.OpenReader(dst)
+	if err != nil {
+		t.Fatalf("Failed to open zip: %v", err)
+	}
+	defer r.Close()
+
+	rc, err := r.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(rc)
+	if string(data) != "[]" {
+		t.Errorf("Unexpected content %q", data)
+	}
+}
@@ -0,0 +1,374 @@
+package export
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math/bits"
+	"os"
+	"slices"
+	"sort"
+)
+
+// A minimal zstd encoder, see RFC 8878. Matches are found within each block
+// and coded with the predefined FSE tables, literals are stored raw. The
+// output is larger than the reference compressor's at its fastest level,
+// but CSV and JSON exports are repetitive enough that matches alone shrink
+// them several times, and any zstd decoder reads it.
+const (
+	zstdMagic     = 0xFD2FB528
+	zstdBlockSize = 128 << 10
+	// zstdWindowLog is the smallest window that holds a full block.
+	// Matches never reach into earlier blocks.
+	zstdWindowLog = 17
+	zstdMinMatch  = 4
+	zstdHashLog   = 15
+
+	zstdBlockRaw        = 0
+	zstdBlockCompressed = 2
+)
+
+// Literal length and match length codes: the smallest value of each code and
+// the number of extra bits that follow it
+var (
+	zstdLLBase = []uint32{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536,
+	}
+	zstdLLBits = []uint{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+	zstdMLBase = []uint32{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539,
+	}
+	zstdMLBits = []uint{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16,
+	}
+)
+
+// Predefined FSE distributions, -1 marks a "less than 1" probability
+var (
+	zstdLLTable = newFSETable([]int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}, 6)
+	zstdMLTable = newFSETable([]int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}, 6)
+	zstdOFTable = newFSETable([]int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}, 5)
+)
+
+// writeZstd compresses the file at srcPath into a single zstd frame at dstPath
+func writeZstd(srcPath, dstPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	w := bufio.NewWriter(dst)
+	// No content size, single segment or checksum flags, then the window
+	// descriptor with a zero mantissa
+	header := binary.LittleEndian.AppendUint32(nil, zstdMagic)
+	header = append(header, 0, (zstdWindowLog-10)<<3)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	enc := newZstdEncoder()
+	block := make([]byte, zstdBlockSize)
+	remaining := info.Size()
+	for {
+		n := min(remaining, zstdBlockSize)
+		if _, err := io.ReadFull(src, block[:n]); err != nil {
+			return err
+		}
+		remaining -= n
+		if err := enc.writeBlock(w, block[:n], remaining == 0); err != nil {
+			return err
+		}
+		if remaining == 0 {
+			break
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return dst.Close()
+}
+
+type zstdSequence struct {
+	litLen, matchLen, offset uint32
+}
+
+type zstdEncoder struct {
+	table   []int32
+	lits    []byte
+	seqs    []zstdSequence
+	payload []byte
+}
+
+func newZstdEncoder() *zstdEncoder {
+	return &zstdEncoder{table: make([]int32, 1<<zstdHashLog)}
+}
+
+// writeBlock writes block compressed, or raw when that isn't smaller
+func (e *zstdEncoder) writeBlock(w io.Writer, block []byte, last bool) error {
+	blockType, payload := zstdBlockRaw, block
+	if compressed := e.compress(block); compressed != nil && len(compressed) < len(block) {
+		blockType, payload = zstdBlockCompressed, compressed
+	}
+	header := uint32(len(payload))<<3 | uint32(blockType)<<1
+	if last {
+		header |= 1
+	}
+	if _, err := w.Write([]byte{byte(header), byte(header >> 8), byte(header >> 16)}); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// compress returns the compressed block content, or nil if block has no matches
+func (e *zstdEncoder) compress(block []byte) []byte {
+	e.findMatches(block)
+	if len(e.seqs) == 0 {
+		return nil
+	}
+
+	// Literals section, raw
+	out := e.payload[:0]
+	switch n := len(e.lits); {
+	case n < 1<<5:
+		out = append(out, byte(n<<3))
+	case n < 1<<12:
+		out = append(out, byte(1<<2|n<<4), byte(n>>4))
+	default:
+		out = append(out, byte(3<<2|n<<4), byte(n>>4), byte(n>>12))
+	}
+	out = append(out, e.lits...)
+
+	// Sequences section header, all three tables in predefined mode
+	switch n := len(e.seqs); {
+	case n < 128:
+		out = append(out, byte(n))
+	case n < 0x7F00:
+		out = append(out, byte(n>>8|0x80), byte(n))
+	default:
+		out = append(out, 0xFF, byte(n-0x7F00), byte((n-0x7F00)>>8))
+	}
+	out = append(out, 0)
+
+	e.payload = e.encodeSequences(out)
+	return e.payload
+}
+
+// findMatches splits block into literals and sequences, matching each
+// position against the last one with the same hash
+func (e *zstdEncoder) findMatches(block []byte) {
+	for i := range e.table {
+		e.table[i] = -1
+	}
+	e.lits, e.seqs = e.lits[:0], e.seqs[:0]
+
+	anchor := 0
+	for i := 0; i+zstdMinMatch <= len(block); {
+		v := binary.LittleEndian.Uint32(block[i:])
+		h := (v * 2654435761) >> (32 - zstdHashLog)
+		cand := int(e.table[h])
+		e.table[h] = int32(i)
+		if cand < 0 || binary.LittleEndian.Uint32(block[cand:]) != v {
+			i++
+			continue
+		}
+		n := zstdMinMatch
+		for i+n < len(block) && block[cand+n] == block[i+n] {
+			n++
+		}
+		e.lits = append(e.lits, block[anchor:i]...)
+		e.seqs = append(e.seqs, zstdSequence{litLen: uint32(i - anchor), matchLen: uint32(n), offset: uint32(i - cand)})
+		i += n
+		anchor = i
+	}
+	e.lits = append(e.lits, block[anchor:]...)
+}
+
+// encodeSequences appends the sequences bitstream to out. The decoder reads
+// it backwards, so sequences are written last to first.
+func (e *zstdEncoder) encodeSequences(out []byte) []byte {
+	w := bitWriter{out: out}
+	var ll, ml, of fseState
+
+	for i := len(e.seqs) - 1; i >= 0; i-- {
+		seq := e.seqs[i]
+		llCode := zstdCode(zstdLLBase, seq.litLen)
+		mlCode := zstdCode(zstdMLBase, seq.matchLen)
+		// Offset values 1 to 3 are repeat offsets
+		offset := seq.offset + 3
+		ofCode := uint(bits.Len32(offset) - 1)
+
+		if i == len(e.seqs)-1 {
+			ml.init(zstdMLTable, mlCode)
+			of.init(zstdOFTable, ofCode)
+			ll.init(zstdLLTable, llCode)
+		} else {
+			of.encode(&w, ofCode)
+			ml.encode(&w, mlCode)
+			ll.encode(&w, llCode)
+		}
+		w.add(uint64(seq.litLen-zstdLLBase[llCode]), zstdLLBits[llCode])
+		w.add(uint64(seq.matchLen-zstdMLBase[mlCode]), zstdMLBits[mlCode])
+		w.add(uint64(offset-1<<ofCode), ofCode)
+	}
+	ml.flush(&w)
+	of.flush(&w)
+	ll.flush(&w)
+	return w.close()
+}
+
+// zstdCode returns the code whose range holds v
+func zstdCode(base []uint32, v uint32) uint {
+	return uint(sort.Search(len(base), func(i int) bool { return base[i] > v }) - 1)
+}
+
+// fseTable is the encoding form of a finite state entropy table, built the
+// way the reference encoder builds it so states match the decoder's table
+type fseTable struct {
+	log     uint
+	states  []uint16
+	symbols []fseSymbol
+}
+
+type fseSymbol struct {
+	deltaNbBits    uint32
+	deltaFindState int32
+}
+
+func newFSETable(norm []int16, log uint) *fseTable {
+	size := 1 << log
+	high := size - 1
+	spread := make([]uint8, size)
+	cumul := make([]int, len(norm)+1)
+	for s, n := range norm {
+		if n == -1 {
+			spread[high] = uint8(s)
+			high--
+			cumul[s+1] = cumul[s] + 1
+		} else {
+			cumul[s+1] = cumul[s] + int(n)
+		}
+	}
+
+	step := size>>1 + size>>3 + 3
+	pos := 0
+	for s, n := range norm {
+		for range max(n, 0) {
+			spread[pos] = uint8(s)
+			pos = (pos + step) & (size - 1)
+			for pos > high {
+				pos = (pos + step) & (size - 1)
+			}
+		}
+	}
+
+	t := &fseTable{log: log, states: make([]uint16, size), symbols: make([]fseSymbol, len(norm))}
+	next := slices.Clone(cumul)
+	for u, s := range spread {
+		t.states[next[s]] = uint16(size + u)
+		next[s]++
+	}
+
+	total := 0
+	for s, n := range norm {
+		switch {
+		case n == -1 || n == 1:
+			t.symbols[s] = fseSymbol{deltaNbBits: uint32(log<<16) - uint32(size), deltaFindState: int32(total - 1)}
+			total++
+		case n > 1:
+			maxBits := log - uint(bits.Len(uint(n-1))-1)
+			t.symbols[s] = fseSymbol{deltaNbBits: uint32(maxBits<<16) - uint32(n)<<maxBits, deltaFindState: int32(total - int(n))}
+			total += int(n)
+		}
+	}
+	return t
+}
+
+type fseState struct {
+	t     *fseTable
+	value uint32
+}
+
+// init starts the state at the first symbol encoded, which needs no bits
+func (st *fseState) init(t *fseTable, symbol uint) {
+	sym := t.symbols[symbol]
+	nbBits := (sym.deltaNbBits + 1<<15) >> 16
+	v := nbBits<<16 - sym.deltaNbBits
+	st.t = t
+	st.value = uint32(t.states[int32(v>>nbBits)+sym.deltaFindState])
+}
+
+func (st *fseState) encode(w *bitWriter, symbol uint) {
+	sym := st.t.symbols[symbol]
+	nbBits := (st.value + sym.deltaNbBits) >> 16
+	w.add(uint64(st.value), uint(nbBits))
+	st.value = uint32(st.t.states[int32(st.value>>nbBits)+sym.deltaFindState])
+}
+
+func (st *fseState) flush(w *bitWriter) {
+	w.add(uint64(st.value), st.t.log)
+}
+
+// bitWriter packs bits least significant first
+type bitWriter struct {
+	out []byte
+	acc uint64
+	n   uint
+}
+
+func (w *bitWriter) add(v uint64, n uint) {
+	w.acc |= (v & (1<<n - 1)) << w.n
+	w.n += n
+	for w.n >= 8 {
+		w.out = append(w.out, byte(w.acc))
+		w.acc >>= 8
+		w.n -= 8
+	}
+}
+
+// close writes the end mark the decoder finds the last bit by and pads the
+// stream to a full byte
+func (w *bitWriter) close() []byte {
+	w.add(1, 1)
+	if w.n > 0 {
+		w.out = append(w.out, byte(w.acc))
+	}
+	w.acc, w.n = 0, 0
+	return w.out
+}
@@ -0,0 +1,103 @@
+package generate
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// errorKinds are the failure causes arbitrary results pick from
+var errorKinds = []models.ErrorKind{
+	"", models.ErrorKindDNS, models.ErrorKindRefused, models.ErrorKindUnreachable, models.ErrorKindProhibited,
+	models.ErrorKindTTLExceeded, models.ErrorKindTimeout,
+	models.ErrorKindTLS, models.ErrorKindHTTPStatus, models.ErrorKindPacketLoss, models.ErrorKindBadResponse,
+	models.ErrorKindOther,
+}
+
+// Arbitrary returns a result with every stored field set to an unlikely but
+// valid value: extreme timestamps and latencies, floats that don't round
+// nicely, unicode IDs and tags. Property tests use it to check that storage
+// and export formats round-trip results without losing fields or precision.
+//
+// Err is always nil since errors aren't stored, and Tags is either nil or
+// non-empty since encoding drops empty maps.
+func Arbitrary(rng *rand.Rand) models.TestResult {
+	r := models.TestResult{
+		Ts:  pick(rng, []int64{0, 1, -1, math.MaxInt64, math.MinInt64, 1<<53 + 1}, rng.Int63),
+		Seq: pick(rng, []uint64{0, 1, math.MaxUint64}, rng.Uint64),
+		Id:  arbitraryString(rng),
+		Ms:  pick(rng, []int64{0, 1, math.MaxInt64, 1<<53 + 1}, rng.Int63),
+		Us:  pick(rng, []int64{0, 1, 999, math.MaxInt64}, rng.Int63),
+		St:  rng.Intn(4),
+		Ek:  errorKinds[rng.Intn(len(errorKinds))],
+	}
+	if rng.Intn(2) == 0 {
+		r.Jit = arbitraryFloat(rng)
+		r.Loss = arbitraryFloat(rng)
+		r.Mos = arbitraryFloat(rng)
+	}
+	if rng.Intn(2) == 0 {
+		r.Rtt = &models.RTTStats{
+			Sent:     pick(rng, []int{0, 1, math.MaxInt32}, rng.Int),
+			Recv:     pick(rng, []int{0, 1, math.MaxInt32}, rng.Int),
+			MinUs:    pick(rng, []int64{0, 1, math.MaxInt64}, rng.Int63),
+			AvgUs:    pick(rng, []int64{0, 1, math.MaxInt64}, rng.Int63),
+			MaxUs:    pick(rng, []int64{0, 1, math.MaxInt64}, rng.Int63),
+			StdDevUs: pick(rng, []int64{0, 1, math.MaxInt64}, rng.Int63),
+		}
+	}
+	if rng.Intn(2) == 0 {
+		r.Tx = pick(rng, []int64{0, 1, math.MaxInt64}, rng.Int63)
+		r.Rx = pick(rng, []int64{0, 1, math.MaxInt64}, rng.Int63)
+	}
+	if n := rng.Intn(4); n > 0 {
+		r.Tags = make(map[string]string, n)
+		for range n {
+			r.Tags[arbitraryString(rng)] = arbitraryString(rng)
+		}
+	}
+	return r
+}
+
+// ArbitraryResults returns n arbitrary results
+func ArbitraryResults(rng *rand.Rand, n int) []models.TestResult {
+	results := make([]models.TestResult, n)
+	for i := range results {
+		results[i] = Arbitrary(rng)
+	}
+	return results
+}
+
+// pick returns one of the edge cases half of the time and a random value
+// otherwise
+func pick[T int | int64 | uint64](rng *rand.Rand, edges []T, random func() T) T {
+	if rng.Intn(2) == 0 {
+		return edges[rng.Intn(len(edges))]
+	}
+	return random()
+}
+
+func arbitraryFloat(rng *rand.Rand) float64 {
+	switch rng.Intn(4) {
+	case 0:
+		return 0
+	case 1:
+		return math.SmallestNonzeroFloat64
+	case 2:
+		return rng.Float64() * 100
+	default:
+		return rng.NormFloat64() * math.MaxFloat32
+	}
+}
+
+var runes = []rune("aZ09-_ .,:;\"'\\/<>&{}[]\t\nçãé日本🙂 \x00\x7f")
+
+func arbitraryString(rng *rand.Rand) string {
+	var b strings.Builder
+	for range rng.Intn(12) {
+		b.WriteRune(runes[rng.Intn(len(runes))])
+	}
+	return b.String()
+}
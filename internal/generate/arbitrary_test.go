@@ -0,0 +1,30 @@
+package generate
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func FuzzResultJSONRoundTrip(f *testing.F) {
+	for seed := range int64(8) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		want := Arbitrary(rand.New(rand.NewSource(seed)))
+		data, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got models.TestResult
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip changed the result\n got: %#v\nwant: %#v", got, want)
+		}
+	})
+}
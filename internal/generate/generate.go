@@ -0,0 +1,172 @@
+// Package generate fabricates monitoring history for load testing:
+// realistic daily result files for many endpoints over long periods, so
+// retention, aggregation, exports and the UI can be exercised without
+// waiting months for real data.
+package generate
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+// Region is the name of the region holding the generated endpoints
+const Region = "Generated"
+
+// Options controls what is generated
+type Options struct {
+	Endpoints int
+	Days      int           // Days of history, the last one ending at End
+	Interval  time.Duration // Time between tests of an endpoint
+	End       time.Time
+	// OutagesPerWeek is the average number of outages per endpoint per week.
+	// Outages last from 2 minutes to 2 hours.
+	OutagesPerWeek float64
+	Seed           int64
+}
+
+// Endpoints returns the endpoints results are generated for. Addresses are
+// in documentation ranges and domains so they can't hit real hosts.
+func Endpoints(n int) []models.Endpoint {
+	endpoints := make([]models.Endpoint, n)
+	for i := range endpoints {
+		ep := models.Endpoint{Timeout: 2000}
+		switch i % 4 {
+		case 0:
+			ep.Type, ep.Address = models.TypeICMP, fmt.Sprintf("192.0.2.%d", i%254+1)
+		case 1:
+			ep.Type, ep.Address = models.TypeHTTP, fmt.Sprintf("https://svc%d.example.com/health", i)
+		case 2:
+			ep.Type, ep.Address = models.TypeTCP, fmt.Sprintf("198.51.100.%d:%d", i%254+1, 443+i/254)
+		case 3:
+			ep.Type, ep.Address = models.TypeDNS, fmt.Sprintf("udp://203.0.113.%d#host%d.example.com", i%254+1, i)
+		}
+		ep.Name = fmt.Sprintf("Generated %s %d", ep.Type, i+1)
+		endpoints[i] = ep
+	}
+	return endpoints
+}
+
+// profile is the latency behavior of one endpoint
+type profile struct {
+	id       string
+	baseMs   float64 // Typical latency at the quietest time of day
+	jitter   float64 // Relative spread of latencies
+	peakHour float64 // Local hour of the daily latency peak
+	outages  []outage
+}
+
+type outage struct {
+	start, end int64 // UnixMilli
+	kind       models.ErrorKind
+}
+
+// Generator produces the results of consecutive days
+type Generator struct {
+	opts     Options
+	rng      *rand.Rand
+	profiles []profile
+}
+
+func New(opts Options) *Generator {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	endpoints := Endpoints(opts.Endpoints)
+	g := &Generator{opts: opts, rng: rng, profiles: make([]profile, len(endpoints))}
+	for i, ep := range endpoints {
+		g.profiles[i] = profile{
+			id:       network.EndpointID(ep.Address, ep.Type),
+			baseMs:   5 + rng.ExpFloat64()*40,
+			jitter:   0.05 + rng.Float64()*0.25,
+			peakHour: 19 + rng.Float64()*3,
+		}
+	}
+	g.planOutages()
+	return g
+}
+
+// Days returns the first day of the history and the number of days
+func (g *Generator) Days() (time.Time, int) {
+	end := g.opts.End
+	last := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, end.Location())
+	return last.AddDate(0, 0, -(g.opts.Days - 1)), g.opts.Days
+}
+
+func (g *Generator) planOutages() {
+	first, days := g.Days()
+	start := first.UnixMilli()
+	span := first.AddDate(0, 0, days).UnixMilli() - start
+	kinds := []models.ErrorKind{models.ErrorKindTimeout, models.ErrorKindTimeout, models.ErrorKindRefused, models.ErrorKindUnreachable, models.ErrorKindDNS}
+	for i := range g.profiles {
+		expected := g.opts.OutagesPerWeek * float64(days) / 7
+		// Round the expected count randomly so fractional rates add up
+		n := int(expected)
+		if g.rng.Float64() < expected-float64(n) {
+			n++
+		}
+		for j := 0; j < n; j++ {
+			begin := start + g.rng.Int63n(span)
+			length := time.Duration(2+g.rng.ExpFloat64()*20) * time.Minute
+			length = min(length, 2*time.Hour)
+			g.profiles[i].outages = append(g.profiles[i].outages, outage{
+				start: begin,
+				end:   begin + length.Milliseconds(),
+				kind:  kinds[g.rng.Intn(len(kinds))],
+			})
+		}
+	}
+}
+
+// Day returns the results of every endpoint for the day, in time order
+func (g *Generator) Day(day time.Time) []models.TestResult {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+	// Today only has results up to now
+	if g.opts.End.Before(end) {
+		end = g.opts.End
+	}
+	interval := g.opts.Interval
+	// Spread endpoints over the interval like a real test cycle does
+	stagger := interval.Milliseconds() / int64(max(len(g.profiles), 1))
+
+	results := make([]models.TestResult, 0, max(int(end.Sub(start)/interval), 0)*len(g.profiles))
+	for ts := start; ts.Before(end); ts = ts.Add(interval) {
+		hour := float64(ts.Hour()) + float64(ts.Minute())/60
+		for i := range g.profiles {
+			results = append(results, g.result(&g.profiles[i], ts.UnixMilli()+int64(i)*stagger, hour))
+		}
+	}
+	return results
+}
+
+func (g *Generator) result(p *profile, ts int64, hour float64) models.TestResult {
+	r := models.TestResult{Ts: ts, Id: p.id}
+	for _, o := range p.outages {
+		if ts >= o.start && ts < o.end {
+			r.St, r.Ek = models.TestStatusError, o.kind
+			if o.kind == models.ErrorKindTimeout {
+				r.St = models.TestStatusTimeout
+			}
+			return r
+		}
+	}
+	// Rare isolated failures
+	if g.rng.Float64() < 0.001 {
+		r.St, r.Ek = models.TestStatusTimeout, models.ErrorKindTimeout
+		return r
+	}
+
+	// Latency peaks in the evening and is lowest twelve hours later
+	diurnal := 1 + 0.4*(1+math.Cos((hour-p.peakHour)/24*2*math.Pi))/2
+	ms := p.baseMs * diurnal * (1 + p.jitter*g.rng.NormFloat64())
+	// Occasional spikes, e.g. from congestion or retransmits
+	if g.rng.Float64() < 0.005 {
+		ms *= 3 + g.rng.Float64()*7
+	}
+	r.Us = max(int64(ms*1000), 1)
+	r.Ms = r.Us / 1000
+	return r
+}
@@ -0,0 +1,97 @@
+package generate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+func testOptions() Options {
+	return Options{
+		Endpoints:      8,
+		Days:           7,
+		Interval:       time.Minute,
+		End:            time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC),
+		OutagesPerWeek: 2,
+		Seed:           1,
+	}
+}
+
+func TestEndpointsValid(t *testing.T) {
+	reg := network.NewRegistry()
+	seen := map[string]bool{}
+	for _, ep := range Endpoints(50) {
+		if err := reg.Validate(ep.Type, ep.Address); err != nil {
+			t.Errorf("%s: %v", ep.Name, err)
+		}
+		id := network.EndpointID(ep.Address, ep.Type)
+		if seen[id] {
+			t.Errorf("duplicate endpoint %s", ep.Address)
+		}
+		seen[id] = true
+	}
+}
+
+func TestDay(t *testing.T) {
+	g := New(testOptions())
+	first, n := g.Days()
+	if n != 7 || !first.Equal(time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Days() = %v, %d", first, n)
+	}
+
+	results := g.Day(first)
+	if len(results) != 8*24*60 {
+		t.Fatalf("got %d results, want %d", len(results), 8*24*60)
+	}
+	end := first.AddDate(0, 0, 1).UnixMilli()
+	for i, r := range results {
+		if r.Ts < first.UnixMilli() || r.Ts >= end {
+			t.Fatalf("result %d outside the day: %d", i, r.Ts)
+		}
+		if i > 0 && r.Ts < results[i-1].Ts {
+			t.Fatalf("results not in time order at %d", i)
+		}
+		if r.St == models.TestStatusSuccess && r.Ms < 1 {
+			t.Fatalf("result %d succeeded without latency", i)
+		}
+	}
+
+	// The last day ends at End
+	last := g.Day(first.AddDate(0, 0, n-1))
+	if len(last) != 8*15*60 {
+		t.Fatalf("got %d results on the last day, want %d", len(last), 8*15*60)
+	}
+
+	// Same seed, same history
+	again := New(testOptions()).Day(first)
+	for i := range results {
+		if a, b := results[i], again[i]; a.Ts != b.Ts || a.Ms != b.Ms || a.St != b.St {
+			t.Fatalf("result %d differs between runs with the same seed", i)
+		}
+	}
+}
+
+func TestOutages(t *testing.T) {
+	opts := testOptions()
+	opts.OutagesPerWeek = 3
+	g := New(opts)
+	first, n := g.Days()
+	failed := 0
+	for i := range n {
+		for _, r := range g.Day(first.AddDate(0, 0, i)) {
+			if r.St != models.TestStatusSuccess {
+				failed++
+			}
+		}
+	}
+	if failed == 0 {
+		t.Fatal("no failures injected")
+	}
+
+	opts.OutagesPerWeek = 0
+	if g := New(opts); len(g.profiles[0].outages) != 0 {
+		t.Fatal("outages planned with a zero rate")
+	}
+}
@@ -0,0 +1,168 @@
+// Package geoip tags test results with the country and autonomous system of
+// the target, looked up in local MaxMind DB files.
+package geoip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+	"github.com/rs/zerolog/log"
+)
+
+// Result tags added by the enricher
+const (
+	TagIP         = "ip"
+	TagCountry    = "country"
+	TagASN        = "asn"
+	TagASOrg      = "as_org"
+	TagISP        = "isp"
+	TagGeoChanged = "geo_changed" // Previous and new location when it changed, e.g. "US/AS13335 -> DE/AS13335"
+)
+
+const resolveTimeout = 2 * time.Second
+
+// Enricher resolves endpoint hosts and tags results with their location.
+// It does nothing until at least one database is loaded.
+type Enricher struct {
+	Ctx context.Context
+
+	mu      sync.RWMutex
+	country *Reader
+	asn     *Reader
+
+	lastMu sync.Mutex
+	last   map[string]string // Endpoint ID -> last location
+}
+
+func NewEnricher(ctx context.Context) *Enricher {
+	return &Enricher{Ctx: ctx, last: make(map[string]string)}
+}
+
+// Load opens the country and ASN databases. Empty paths disable the
+// corresponding lookups. Either database may also be an ISP database, whose
+// isp field is tagged too.
+func (e *Enricher) Load(countryPath, asnPath string) error {
+	var country, asn *Reader
+	var err error
+	if countryPath != "" {
+		if country, err = Open(countryPath); err != nil {
+			return fmt.Errorf("country database: %w", err)
+		}
+	}
+	if asnPath != "" {
+		if asn, err = Open(asnPath); err != nil {
+			return fmt.Errorf("ASN database: %w", err)
+		}
+	}
+
+	e.mu.Lock()
+	e.country, e.asn = country, asn
+	e.mu.Unlock()
+	return nil
+}
+
+// Middleware tags every non-cancelled result with the location of the target
+func (e *Enricher) Middleware() network.Middleware {
+	return func(next network.RunFunc) network.RunFunc {
+		return func(ctx context.Context, ep models.Endpoint) (models.TestResult, error) {
+			result, err := next(ctx, ep)
+			if result.St != models.TestStatusCancelled {
+				e.enrich(ctx, ep, &result)
+			}
+			return result, err
+		}
+	}
+}
+
+func (e *Enricher) enrich(ctx context.Context, ep models.Endpoint, result *models.TestResult) {
+	e.mu.RLock()
+	country, asn := e.country, e.asn
+	e.mu.RUnlock()
+	if country == nil && asn == nil {
+		return
+	}
+
+	// Reuse the address resolved by the resolution tracker when it ran first
+	host := network.Host(ep.Address)
+	if tagged, ok := result.Tags[TagIP]; ok {
+		host = tagged
+	}
+	ip, err := resolve(ctx, host)
+	if err != nil {
+		log.Ctx(e.Ctx).Debug().Err(err).Str("address", ep.Address).Msg("GeoIP enrichment skipped")
+		return
+	}
+
+	tags := e.lookup(ip, country, asn)
+	if result.Tags == nil {
+		result.Tags = make(map[string]string)
+	}
+	for k, v := range tags {
+		result.Tags[k] = v
+	}
+
+	location := tags[TagCountry] + "/AS" + tags[TagASN]
+	e.lastMu.Lock()
+	previous, seen := e.last[result.Id]
+	e.last[result.Id] = location
+	e.lastMu.Unlock()
+
+	if seen && previous != location {
+		result.Tags[TagGeoChanged] = previous + " -> " + location
+		log.Ctx(e.Ctx).Info().
+			Str("id", result.Id).
+			Str("address", ep.Address).
+			Str("from", previous).
+			Str("to", location).
+			Msg("Endpoint now answers from a different location")
+	}
+}
+
+func (e *Enricher) lookup(ip net.IP, country, asn *Reader) map[string]string {
+	tags := map[string]string{TagIP: ip.String()}
+	for _, db := range []*Reader{country, asn} {
+		if db == nil {
+			continue
+		}
+		rec, err := db.Lookup(ip)
+		if err != nil {
+			log.Ctx(e.Ctx).Warn().Err(err).Str("ip", ip.String()).Str("database", db.DatabaseType).Msg("GeoIP lookup failed")
+			continue
+		}
+		m, _ := rec.(map[string]any)
+		if c, ok := m["country"].(map[string]any); ok {
+			if iso, ok := c["iso_code"].(string); ok {
+				tags[TagCountry] = iso
+			}
+		}
+		if n, ok := m["autonomous_system_number"].(uint64); ok {
+			tags[TagASN] = strconv.FormatUint(n, 10)
+		}
+		if org, ok := m["autonomous_system_organization"].(string); ok {
+			tags[TagASOrg] = org
+		}
+		if isp, ok := m["isp"].(string); ok {
+			tags[TagISP] = isp
+		}
+	}
+	return tags
+}
+
+func resolve(ctx context.Context, host string) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return addrs[0].IP, nil
+}
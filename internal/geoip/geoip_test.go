@@ -0,0 +1,143 @@
+package geoip
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+// encode writes a data section value using the types the tests need.
+// Strings must be shorter than 285 bytes.
+func encode(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case string:
+		if len(v) < 29 {
+			buf.WriteByte(typeString<<5 | byte(len(v)))
+		} else {
+			buf.WriteByte(typeString<<5 | 29)
+			buf.WriteByte(byte(len(v) - 29))
+		}
+		buf.WriteString(v)
+	case uint32:
+		buf.WriteByte(typeUint32<<5 | 4)
+		_ = binary.Write(buf, binary.BigEndian, v)
+	case uint16:
+		buf.WriteByte(typeUint16<<5 | 2)
+		_ = binary.Write(buf, binary.BigEndian, v)
+	case map[string]any:
+		buf.WriteByte(typeMap<<5 | byte(len(v)))
+		for k, val := range v {
+			encode(buf, k)
+			encode(buf, val)
+		}
+	}
+}
+
+// buildDB returns an IPv4 database with 24-bit records mapping one /24 to record
+func buildDB(prefix net.IP, record map[string]any) []byte {
+	const nodeCount = 24
+	ip := prefix.To4()
+
+	var tree bytes.Buffer
+	put := func(v uint32) { tree.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)}) }
+	for i := 0; i < nodeCount; i++ {
+		next := uint32(i + 1)
+		if i == nodeCount-1 {
+			next = nodeCount + dataSectionSeparator // Data at offset 0
+		}
+		bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+		if bit == 0 {
+			put(next)
+			put(nodeCount)
+		} else {
+			put(nodeCount)
+			put(next)
+		}
+	}
+
+	var db bytes.Buffer
+	db.Write(tree.Bytes())
+	db.Write(make([]byte, dataSectionSeparator))
+	encode(&db, record)
+	db.Write(metadataMarker)
+	encode(&db, map[string]any{
+		"node_count":    uint32(nodeCount),
+		"record_size":   uint16(24),
+		"ip_version":    uint16(4),
+		"database_type": "Test",
+	})
+	return db.Bytes()
+}
+
+func TestReaderLookup(t *testing.T) {
+	r, err := FromBytes(buildDB(net.ParseIP("192.0.2.0"), map[string]any{
+		"country": map[string]any{"iso_code": "NL"},
+	}))
+	if err != nil {
+		t.Fatalf("FromBytes failed: %v", err)
+	}
+	if r.DatabaseType != "Test" {
+		t.Errorf("Unexpected database type %q", r.DatabaseType)
+	}
+
+	rec, err := r.Lookup(net.ParseIP("192.0.2.77"))
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	country := rec.(map[string]any)["country"].(map[string]any)
+	if country["iso_code"] != "NL" {
+		t.Errorf("Unexpected record: %+v", rec)
+	}
+
+	if rec, err := r.Lookup(net.ParseIP("198.51.100.1")); err != nil || rec != nil {
+		t.Errorf("Expected no record, got %+v, %v", rec, err)
+	}
+	if _, err := FromBytes([]byte("garbage")); err == nil {
+		t.Errorf("Expected error for invalid database")
+	}
+}
+
+func TestEnricherMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	asnPath := filepath.Join(dir, "asn.mmdb")
+	db := buildDB(net.ParseIP("127.0.0.0"), map[string]any{
+		"autonomous_system_number":       uint32(64500),
+		"autonomous_system_organization": "Example Net",
+	})
+	if err := os.WriteFile(asnPath, db, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEnricher(context.Background())
+	if err := e.Load("", asnPath); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	runner := network.NewRunner(context.Background())
+	_ = runner.Protocols.Register(network.NewMockTest().Protocol())
+	runner.Use(e.Middleware())
+
+	result, _ := runner.Run(context.Background(), models.Endpoint{Type: network.MockType, Address: "127.0.0.1:80", Timeout: 1000})
+	if result.Tags[TagIP] != "127.0.0.1" || result.Tags[TagASN] != "64500" || result.Tags[TagASOrg] != "Example Net" {
+		t.Errorf("Unexpected tags: %+v", result.Tags)
+	}
+	if _, ok := result.Tags[TagGeoChanged]; ok {
+		t.Errorf("First result must not be flagged as a location change")
+	}
+
+	// The endpoint's location changes between runs
+	e.lastMu.Lock()
+	e.last[result.Id] = "US/AS13335"
+	e.lastMu.Unlock()
+	result, _ = runner.Run(context.Background(), models.Endpoint{Type: network.MockType, Address: "127.0.0.1:80", Timeout: 1000})
+	if result.Tags[TagGeoChanged] != "US/AS13335 -> /AS64500" {
+		t.Errorf("Expected location change, got %+v", result.Tags)
+	}
+}
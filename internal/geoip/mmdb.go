@@ -0,0 +1,303 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"os"
+)
+
+// MaxMind DB format, see https://maxmind.github.io/MaxMind-DB/
+
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+const dataSectionSeparator = 16
+
+// Reader looks up records in a MaxMind DB (.mmdb) file such as GeoLite2-ASN
+// or GeoLite2-Country. The whole file is held in memory.
+type Reader struct {
+	buf          []byte
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	treeSize     uint
+	dataStart    uint
+	ipv4Start    uint
+	DatabaseType string
+}
+
+// Open reads and validates a MaxMind DB file
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(buf)
+}
+
+// FromBytes parses a MaxMind DB held in memory
+func FromBytes(buf []byte) (*Reader, error) {
+	idx := bytes.LastIndex(buf, metadataMarker)
+	if idx < 0 {
+		return nil, errors.New("not a MaxMind DB file: metadata marker not found")
+	}
+	metaStart := uint(idx + len(metadataMarker))
+	d := decoder{buf: buf[metaStart:]}
+	v, _, err := d.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	meta, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid metadata: not a map")
+	}
+
+	r := &Reader{buf: buf}
+	r.nodeCount = uint(toUint(meta["node_count"]))
+	r.recordSize = uint(toUint(meta["record_size"]))
+	r.ipVersion = uint(toUint(meta["ip_version"]))
+	r.DatabaseType, _ = meta["database_type"].(string)
+
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", r.recordSize)
+	}
+	r.treeSize = r.nodeCount * r.recordSize / 4
+	r.dataStart = r.treeSize + dataSectionSeparator
+	if r.dataStart > uint(idx) {
+		return nil, errors.New("invalid metadata: search tree exceeds file size")
+	}
+
+	// IPv4 addresses live under ::/96 in IPv6 databases
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readRecord(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// Lookup returns the record for the IP address, or nil when the database has
+// no data for it
+func (r *Reader) Lookup(ip net.IP) (any, error) {
+	node := uint(0)
+	bits := 128
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+		bits = 32
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.ipVersion == 4 {
+		return nil, errors.New("IPv6 address looked up in an IPv4-only database")
+	}
+
+	for i := 0; i < bits && node < r.nodeCount; i++ {
+		bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+		node = r.readRecord(node, uint(bit))
+	}
+	if node == r.nodeCount {
+		return nil, nil
+	}
+	if node < r.nodeCount {
+		return nil, errors.New("invalid search tree: address bits exhausted inside the tree")
+	}
+
+	offset := node - r.nodeCount - dataSectionSeparator
+	d := decoder{buf: r.buf[r.dataStart:]}
+	v, _, err := d.decode(offset)
+	return v, err
+}
+
+func (r *Reader) readRecord(node, bit uint) uint {
+	base := node * r.recordSize / 4
+	b := r.buf[base : base+r.recordSize/4]
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// Data section types
+const (
+	typeExtended = 0
+	typePointer  = 1
+	typeString   = 2
+	typeDouble   = 3
+	typeBytes    = 4
+	typeUint16   = 5
+	typeUint32   = 6
+	typeMap      = 7
+	typeInt32    = 8
+	typeUint64   = 9
+	typeUint128  = 10
+	typeArray    = 11
+	typeBool     = 14
+	typeFloat    = 15
+)
+
+type decoder struct {
+	buf []byte
+}
+
+// decode returns the value at offset and the offset following it
+func (d *decoder) decode(offset uint) (any, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, errors.New("offset out of range")
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typ := uint(ctrl >> 5)
+
+	if typ == typePointer {
+		ptr, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(ptr)
+		return v, next, err
+	}
+
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errors.New("truncated extended type")
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1F)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.buf)) {
+			return nil, 0, errors.New("truncated size")
+		}
+		v := uint(0)
+		for _, b := range d.buf[offset : offset+n] {
+			v = v<<8 | uint(b)
+		}
+		switch size {
+		case 29:
+			size = 29 + v
+		case 30:
+			size = 285 + v
+		default:
+			size = 65821 + v
+		}
+		offset += n
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, size)
+		for range size {
+			k, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			v, next, err := d.decode(next)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, size)
+		for range size {
+			v, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errors.New("value exceeds data section")
+	}
+	raw := d.buf[offset : offset+size]
+	next := offset + size
+
+	switch typ {
+	case typeString:
+		return string(raw), next, nil
+	case typeBytes:
+		return append([]byte(nil), raw...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("invalid double size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("invalid float size")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		v := uint64(0)
+		for _, b := range raw {
+			v = v<<8 | uint64(b)
+		}
+		return v, next, nil
+	case typeInt32:
+		v := uint32(0)
+		for _, b := range raw {
+			v = v<<8 | uint32(b)
+		}
+		return int64(int32(v)), next, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(raw), next, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+}
+
+func (d *decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint((ctrl>>3)&0x3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errors.New("truncated pointer")
+	}
+	b := d.buf[offset : offset+n]
+	vvv := uint(ctrl & 0x7)
+	var ptr uint
+	switch n {
+	case 1:
+		ptr = vvv<<8 | uint(b[0])
+	case 2:
+		ptr = (vvv<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 3:
+		ptr = (vvv<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	default:
+		ptr = uint(binary.BigEndian.Uint32(b))
+	}
+	return ptr, offset + n, nil
+}
+
+func toUint(v any) uint64 {
+	u, _ := v.(uint64)
+	return u
+}
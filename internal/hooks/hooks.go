@@ -0,0 +1,186 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultTimeout bounds hooks that don't set TimeoutSeconds
+	DefaultTimeout = 30 * time.Second
+
+	// MaxOutput is how much of a hook's combined stdout/stderr is kept
+	MaxOutput = 4096
+
+	// MaxRuns is how many hook executions are kept for GetHookRuns
+	MaxRuns = 100
+)
+
+// Runner executes hook commands when endpoints change state
+type Runner struct {
+	Ctx context.Context
+
+	mu     sync.Mutex
+	runs   []models.HookRun
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+	ctx    context.Context
+}
+
+func NewRunner(ctx context.Context) *Runner {
+	r := &Runner{Ctx: ctx}
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	return r
+}
+
+// Validate checks that a hook can be executed
+func Validate(h models.Hook) error {
+	if h.Name == "" {
+		return errors.New("hook name is required")
+	}
+	if h.Command == "" {
+		return errors.New("hook command is required")
+	}
+	if len(h.On) == 0 {
+		return errors.New("hook must run on at least one event")
+	}
+	for _, ev := range h.On {
+		switch ev {
+		case models.HookOnDown, models.HookOnDegraded, models.HookOnRecovered, models.HookOnCertExpiring:
+		default:
+			return fmt.Errorf("unknown hook event: %s", ev)
+		}
+	}
+	if h.TimeoutSeconds < 0 {
+		return errors.New("hook timeout must not be negative")
+	}
+	return nil
+}
+
+// Fire runs, in the background, every hook subscribed to the event for the endpoint
+func (r *Runner) Fire(hooks []models.Hook, event models.HookEvent, ep models.Endpoint, state models.EndpointState) {
+	for _, h := range hooks {
+		if !slices.Contains(h.On, event) {
+			continue
+		}
+		if len(h.EndpointIDs) > 0 && !slices.Contains(h.EndpointIDs, state.EndpointID) {
+			continue
+		}
+		r.wg.Add(1)
+		go func(h models.Hook) {
+			defer r.wg.Done()
+			r.record(r.run(h, event, ep, state))
+		}(h)
+	}
+}
+
+// Runs returns the most recent hook executions, newest first
+func (r *Runner) Runs() []models.HookRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	runs := make([]models.HookRun, len(r.runs))
+	for i, run := range r.runs {
+		runs[len(r.runs)-1-i] = run
+	}
+	return runs
+}
+
+// Stop kills running hooks and waits for them to exit
+func (r *Runner) Stop() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *Runner) run(h models.Hook, event models.HookEvent, ep models.Endpoint, state models.EndpointState) models.HookRun {
+	timeout := DefaultTimeout
+	if h.TimeoutSeconds > 0 {
+		timeout = time.Duration(h.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(r.ctx, timeout)
+	defer cancel()
+
+	result := state.LastResult
+	cmd := exec.CommandContext(ctx, h.Command, h.Args...)
+	cmd.Env = append(os.Environ(),
+		"NETMONITOR_EVENT="+string(event),
+		"NETMONITOR_ENDPOINT_ID="+state.EndpointID,
+		"NETMONITOR_ENDPOINT_NAME="+ep.Name,
+		"NETMONITOR_ENDPOINT_TYPE="+string(ep.Type),
+		"NETMONITOR_ENDPOINT_ADDRESS="+ep.Address,
+		"NETMONITOR_STATUS="+strconv.Itoa(result.St),
+		"NETMONITOR_LATENCY_MS="+strconv.FormatInt(result.Ms, 10),
+		"NETMONITOR_ERROR_KIND="+string(result.Ek),
+		"NETMONITOR_CONSECUTIVE_FAILURES="+strconv.Itoa(state.ConsecutiveFailures),
+		"NETMONITOR_TRACE="+result.Trace,
+	)
+	if days, ok := result.Tags[network.TagCertExpiryDays]; ok {
+		cmd.Env = append(cmd.Env,
+			"NETMONITOR_CERT_EXPIRY_DAYS="+days,
+			"NETMONITOR_CERT_NOT_AFTER="+result.Tags[network.TagCertNotAfter],
+		)
+	}
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	start := time.Now()
+	err := cmd.Run()
+
+	run := models.HookRun{
+		Hook:       h.Name,
+		EndpointID: state.EndpointID,
+		Event:      event,
+		Start:      start.UnixMilli(),
+		DurationMs: time.Since(start).Milliseconds(),
+		ExitCode:   cmd.ProcessState.ExitCode(),
+		Output:     truncate(output.String(), MaxOutput),
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		run.Error = fmt.Sprintf("timed out after %s", timeout)
+	} else if err != nil {
+		run.Error = err.Error()
+	}
+
+	logEvent := log.Ctx(r.Ctx).Info()
+	if run.Error != "" {
+		logEvent = log.Ctx(r.Ctx).Warn().Str("error", run.Error)
+	}
+	logEvent.
+		Str("hook", h.Name).
+		Str("event", string(event)).
+		Str("endpoint", state.EndpointID).
+		Str(network.TraceField, result.Trace).
+		Int("exit_code", run.ExitCode).
+		Int64("duration_ms", run.DurationMs).
+		Msg("Hook executed")
+	return run
+}
+
+func (r *Runner) record(run models.HookRun) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, run)
+	if len(r.runs) > MaxRuns {
+		r.runs = r.runs[len(r.runs)-MaxRuns:]
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
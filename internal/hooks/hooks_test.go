@@ -0,0 +1,65 @@
+package hooks
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestFire(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test uses sh")
+	}
+
+	r := NewRunner(context.Background())
+	hooks := []models.Hook{
+		{Name: "echo", On: []models.HookEvent{models.HookOnDown}, Command: "sh", Args: []string{"-c", "echo $NETMONITOR_EVENT $NETMONITOR_ENDPOINT_NAME $NETMONITOR_LATENCY_MS"}},
+		{Name: "recovered-only", On: []models.HookEvent{models.HookOnRecovered}, Command: "sh", Args: []string{"-c", "true"}},
+		{Name: "slow", On: []models.HookEvent{models.HookOnDown}, Command: "sleep", Args: []string{"5"}, TimeoutSeconds: 1},
+	}
+	ep := models.Endpoint{Name: "Router", Type: models.TypeTCP, Address: "10.0.0.1:80"}
+	state := models.EndpointState{EndpointID: "abc1234", LastResult: models.TestResult{Ms: 42, St: 2}}
+
+	r.Fire(hooks, models.HookOnDown, ep, state)
+	r.wg.Wait()
+
+	runs := r.Runs()
+	if len(runs) != 2 {
+		t.Fatalf("Expected 2 runs, got %d: %+v", len(runs), runs)
+	}
+	for _, run := range runs {
+		switch run.Hook {
+		case "echo":
+			if strings.TrimSpace(run.Output) != "down Router 42" || run.ExitCode != 0 || run.Error != "" {
+				t.Errorf("Unexpected echo run: %+v", run)
+			}
+		case "slow":
+			if !strings.Contains(run.Error, "timed out") {
+				t.Errorf("Expected timeout, got %+v", run)
+			}
+		default:
+			t.Errorf("Unexpected hook run: %+v", run)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := models.Hook{Name: "h", Command: "true", On: []models.HookEvent{models.HookOnDown}}
+	if err := Validate(valid); err != nil {
+		t.Errorf("Expected valid hook, got %v", err)
+	}
+	invalid := []models.Hook{
+		{Command: "true", On: valid.On},
+		{Name: "h", On: valid.On},
+		{Name: "h", Command: "true"},
+		{Name: "h", Command: "true", On: []models.HookEvent{"sideways"}},
+	}
+	for _, h := range invalid {
+		if err := Validate(h); err == nil {
+			t.Errorf("Expected error for %+v", h)
+		}
+	}
+}
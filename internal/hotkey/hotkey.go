@@ -0,0 +1,145 @@
+// Package hotkey registers system-wide keyboard shortcuts, which fire while
+// another application has the focus. Shortcuts are written like
+// "Ctrl+Alt+N": any of the Ctrl, Alt, Shift and Super modifiers followed by
+// a letter, a digit or F1 to F12.
+package hotkey
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupported is returned on platforms without global hotkeys
+var ErrUnsupported = errors.New("global hotkeys are not supported on this platform")
+
+// Modifier is a set of modifier keys
+type Modifier uint8
+
+const (
+	ModCtrl Modifier = 1 << iota
+	ModAlt
+	ModShift
+	ModSuper // The Windows or Command key
+)
+
+// Hotkey is a key pressed with modifiers
+type Hotkey struct {
+	Mods Modifier
+	// Key is an upper case letter, a digit or F1 to F12
+	Key string
+}
+
+var modNames = []struct {
+	mod   Modifier
+	names []string
+}{
+	{ModCtrl, []string{"ctrl", "control"}},
+	{ModAlt, []string{"alt", "option"}},
+	{ModShift, []string{"shift"}},
+	{ModSuper, []string{"super", "win", "cmd", "meta"}},
+}
+
+// Parse reads a hotkey such as "Ctrl+Shift+F5". Modifiers are case
+// insensitive and at least one is required, so a hotkey can't take over a
+// key other applications need.
+func Parse(s string) (Hotkey, error) {
+	parts := strings.Split(s, "+")
+	var h Hotkey
+	for _, part := range parts[:len(parts)-1] {
+		mod, ok := parseModifier(strings.TrimSpace(part))
+		if !ok {
+			return Hotkey{}, fmt.Errorf("unknown modifier %q in %q", part, s)
+		}
+		h.Mods |= mod
+	}
+	h.Key = strings.ToUpper(strings.TrimSpace(parts[len(parts)-1]))
+	if !validKey(h.Key) {
+		return Hotkey{}, fmt.Errorf("unsupported key %q in %q", h.Key, s)
+	}
+	if h.Mods == 0 {
+		return Hotkey{}, fmt.Errorf("hotkey %q needs a modifier", s)
+	}
+	return h, nil
+}
+
+func parseModifier(s string) (Modifier, bool) {
+	for _, m := range modNames {
+		for _, name := range m.names {
+			if strings.EqualFold(s, name) {
+				return m.mod, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func validKey(key string) bool {
+	if len(key) == 1 {
+		c := key[0]
+		return (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+	}
+	return fnKey(key) > 0
+}
+
+// fnKey returns n for the function key Fn, or 0 for other keys
+func fnKey(key string) int {
+	digits, ok := strings.CutPrefix(key, "F")
+	n, err := strconv.Atoi(digits)
+	if !ok || err != nil || n < 1 || n > 12 || digits != strconv.Itoa(n) {
+		return 0
+	}
+	return n
+}
+
+// String formats the hotkey the way Parse reads it
+func (h Hotkey) String() string {
+	var parts []string
+	for _, m := range modNames {
+		if h.Mods&m.mod != 0 {
+			parts = append(parts, strings.ToUpper(m.names[0][:1])+m.names[0][1:])
+		}
+	}
+	return strings.Join(append(parts, h.Key), "+")
+}
+
+// binding is a registered hotkey and what it does
+type binding struct {
+	key Hotkey
+	fn  func()
+}
+
+// Manager keeps a set of hotkeys registered with the system
+type Manager struct {
+	mu   sync.Mutex
+	stop func()
+}
+
+// Set replaces the registered hotkeys with the given ones. Hotkeys another
+// application already registered are reported in the error, the others
+// work regardless. Actions run on their own goroutine.
+func (m *Manager) Set(bindings map[Hotkey]func()) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		m.stop()
+		m.stop = nil
+	}
+	if len(bindings) == 0 {
+		return nil
+	}
+	list := make([]binding, 0, len(bindings))
+	for key, fn := range bindings {
+		list = append(list, binding{key: key, fn: fn})
+	}
+	stop, err := listen(list)
+	m.stop = stop
+	return err
+}
+
+// Close unregisters every hotkey
+func (m *Manager) Close() {
+	_ = m.Set(nil)
+}
@@ -0,0 +1,9 @@
+//go:build darwin
+
+package hotkey
+
+// listen is not implemented on macOS, where global hotkeys need the Carbon
+// event loop and the accessibility permission
+func listen(bindings []binding) (func(), error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,146 @@
+//go:build linux
+
+package hotkey
+
+/*
+#cgo pkg-config: x11
+#include <stdlib.h>
+#include <X11/Xlib.h>
+
+static int grabFailed;
+
+static int onGrabError(Display *d, XErrorEvent *e) {
+	if (e->error_code == BadAccess) {
+		grabFailed = 1;
+	}
+	return 0;
+}
+
+// grab takes the key on the root window, also with Caps Lock and Num Lock
+// on since they count as modifiers. It returns 0 if another client holds it.
+static int grab(Display *d, int keycode, unsigned int mods) {
+	unsigned int locks[] = {0, LockMask, Mod2Mask, LockMask | Mod2Mask};
+	XErrorHandler prev;
+	int i;
+
+	XSync(d, False);
+	grabFailed = 0;
+	prev = XSetErrorHandler(onGrabError);
+	for (i = 0; i < 4; i++) {
+		XGrabKey(d, keycode, mods | locks[i], DefaultRootWindow(d), False, GrabModeAsync, GrabModeAsync);
+	}
+	XSync(d, False);
+	XSetErrorHandler(prev);
+	return !grabFailed;
+}
+
+// nextKeyPress reads queued events up to the next key press, returning 0
+// once the queue is empty
+static int nextKeyPress(Display *d, unsigned int *keycode, unsigned int *state) {
+	XEvent e;
+	while (XPending(d) > 0) {
+		XNextEvent(d, &e);
+		if (e.type == KeyPress) {
+			*keycode = e.xkey.keycode;
+			*state = e.xkey.state;
+			return 1;
+		}
+	}
+	return 0;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// pollInterval is how often queued key presses are read
+const pollInterval = 50 * time.Millisecond
+
+// grabMu serializes grabs, which share the C error flag
+var grabMu sync.Mutex
+
+func x11Mods(mods Modifier) C.uint {
+	var m C.uint
+	for _, x := range []struct {
+		mod  Modifier
+		mask C.uint
+	}{{ModCtrl, C.ControlMask}, {ModAlt, C.Mod1Mask}, {ModShift, C.ShiftMask}, {ModSuper, C.Mod4Mask}} {
+		if mods&x.mod != 0 {
+			m |= x.mask
+		}
+	}
+	return m
+}
+
+// listen grabs the hotkeys on the X11 root window through a connection of
+// its own. Wayland sessions only deliver them through XWayland while an X11
+// application has the focus.
+func listen(bindings []binding) (func(), error) {
+	display := C.XOpenDisplay(nil)
+	if display == nil {
+		return nil, errors.New("global hotkeys need an X11 display")
+	}
+
+	type grabbed struct {
+		keycode C.uint
+		mods    C.uint
+		fn      func()
+	}
+	var keys []grabbed
+	var errs []error
+	grabMu.Lock()
+	for _, b := range bindings {
+		name := C.CString(b.key.Key)
+		keycode := C.XKeysymToKeycode(display, C.XStringToKeysym(name))
+		C.free(unsafe.Pointer(name))
+		if keycode == 0 {
+			errs = append(errs, fmt.Errorf("register %s: key not on the keyboard", b.key))
+			continue
+		}
+		mods := x11Mods(b.key.Mods)
+		if C.grab(display, C.int(keycode), mods) == 0 {
+			errs = append(errs, fmt.Errorf("register %s: taken by another application", b.key))
+			continue
+		}
+		keys = append(keys, grabbed{keycode: C.uint(keycode), mods: mods, fn: b.fn})
+	}
+	grabMu.Unlock()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		defer C.XCloseDisplay(display)
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		relevant := x11Mods(ModCtrl | ModAlt | ModShift | ModSuper)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			var keycode, state C.uint
+			for C.nextKeyPress(display, &keycode, &state) != 0 {
+				for _, k := range keys {
+					if k.keycode == keycode && state&relevant == k.mods {
+						go k.fn()
+					}
+				}
+			}
+		}
+	}()
+
+	// Closing the connection releases its grabs
+	stop := func() {
+		close(done)
+		<-stopped
+	}
+	return stop, errors.Join(errs...)
+}
@@ -0,0 +1,35 @@
+package hotkey
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	valid := []struct {
+		in   string
+		want Hotkey
+		str  string
+	}{
+		{"Ctrl+Alt+N", Hotkey{Mods: ModCtrl | ModAlt, Key: "N"}, "Ctrl+Alt+N"},
+		{"shift + control + f5", Hotkey{Mods: ModCtrl | ModShift, Key: "F5"}, "Ctrl+Shift+F5"},
+		{"Win+1", Hotkey{Mods: ModSuper, Key: "1"}, "Super+1"},
+		{"Cmd+Option+F12", Hotkey{Mods: ModSuper | ModAlt, Key: "F12"}, "Alt+Super+F12"},
+	}
+	for _, tt := range valid {
+		got, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if got.String() != tt.str {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got.String(), tt.str)
+		}
+	}
+
+	for _, in := range []string{"", "N", "F5", "Ctrl+", "Ctrl+Hyper+N", "Ctrl+F13", "Ctrl+F05", "Ctrl+Space", "Ctrl+NN"} {
+		if h, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = %+v, want error", in, h)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+//go:build windows
+
+package hotkey
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32                 = windows.NewLazySystemDLL("user32.dll")
+	procRegisterHotKey     = user32.NewProc("RegisterHotKey")
+	procUnregisterHotKey   = user32.NewProc("UnregisterHotKey")
+	procGetMessageW        = user32.NewProc("GetMessageW")
+	procPostThreadMessageW = user32.NewProc("PostThreadMessageW")
+)
+
+const (
+	modAlt      = 0x0001
+	modControl  = 0x0002
+	modShift    = 0x0004
+	modWin      = 0x0008
+	modNoRepeat = 0x4000
+
+	wmQuit   = 0x0012
+	wmHotkey = 0x0312
+
+	vkF1 = 0x70
+)
+
+// msg is the Win32 MSG structure
+type msg struct {
+	hwnd    uintptr
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	pt      struct{ x, y int32 }
+}
+
+func virtualKey(key string) uintptr {
+	if n := fnKey(key); n > 0 {
+		return uintptr(vkF1 + n - 1)
+	}
+	// Letters and digits are their own virtual key codes
+	return uintptr(key[0])
+}
+
+// listen registers the hotkeys on a dedicated thread, since Windows delivers
+// them to the message queue of the thread that registered them
+func listen(bindings []binding) (func(), error) {
+	type started struct {
+		thread uint32
+		err    error
+	}
+	ready := make(chan started, 1)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		var errs []error
+		for i, b := range bindings {
+			mods := uintptr(modNoRepeat)
+			for _, m := range []struct {
+				mod Modifier
+				win uintptr
+			}{{ModCtrl, modControl}, {ModAlt, modAlt}, {ModShift, modShift}, {ModSuper, modWin}} {
+				if b.key.Mods&m.mod != 0 {
+					mods |= m.win
+				}
+			}
+			if r, _, err := procRegisterHotKey.Call(0, uintptr(i+1), mods, virtualKey(b.key.Key)); r == 0 {
+				errs = append(errs, fmt.Errorf("register %s: %w", b.key, err))
+				continue
+			}
+			defer procUnregisterHotKey.Call(0, uintptr(i+1))
+		}
+		ready <- started{thread: windows.GetCurrentThreadId(), err: errors.Join(errs...)}
+
+		var m msg
+		for {
+			// GetMessage returns 0 for WM_QUIT and -1 on errors
+			r, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+			if int32(r) <= 0 {
+				return
+			}
+			if m.message == wmHotkey && m.wParam >= 1 && int(m.wParam) <= len(bindings) {
+				go bindings[m.wParam-1].fn()
+			}
+		}
+	}()
+
+	s := <-ready
+	stop := func() {
+		procPostThreadMessageW.Call(uintptr(s.thread), wmQuit, 0, 0)
+	}
+	return stop, s.err
+}
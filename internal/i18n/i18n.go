@@ -0,0 +1,114 @@
+// Package i18n translates the strings the backend produces for users, like
+// binding errors, tray menu labels and export contents. Catalogs are JSON
+// files in locales/ mapping message keys to fmt format strings.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// DefaultLocale is used for unknown locales and for keys a catalog lacks
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var files embed.FS
+
+type catalog struct {
+	Name     string            `json:"name"` // Language name in that language
+	Messages map[string]string `json:"messages"`
+}
+
+var catalogs = load()
+
+var current atomic.Value // string
+
+func init() {
+	current.Store(DefaultLocale)
+}
+
+func load() map[string]catalog {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	catalogs := make(map[string]catalog, len(entries))
+	for _, e := range entries {
+		data, err := files.ReadFile(path.Join("locales", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var c catalog
+		if err := json.Unmarshal(data, &c); err != nil {
+			panic(fmt.Sprintf("i18n: %s: %v", e.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(e.Name(), ".json")] = c
+	}
+	return catalogs
+}
+
+// Locales returns the available locales sorted by code
+func Locales() []models.LocaleInfo {
+	locales := make([]models.LocaleInfo, 0, len(catalogs))
+	for code, c := range catalogs {
+		locales = append(locales, models.LocaleInfo{Code: code, Name: c.Name})
+	}
+	sort.Slice(locales, func(i, j int) bool { return locales[i].Code < locales[j].Code })
+	return locales
+}
+
+// Match returns the available locale closest to the requested one, e.g.
+// "pt_BR.UTF-8" or "pt" match "pt-BR". It falls back to DefaultLocale.
+func Match(locale string) string {
+	locale = strings.ReplaceAll(strings.SplitN(locale, ".", 2)[0], "_", "-")
+	lang, _, _ := strings.Cut(locale, "-")
+	var sameLang string
+	for code := range catalogs {
+		if strings.EqualFold(code, locale) {
+			return code
+		}
+		if l, _, _ := strings.Cut(code, "-"); strings.EqualFold(l, lang) && (sameLang == "" || code < sameLang) {
+			sameLang = code
+		}
+	}
+	if sameLang != "" {
+		return sameLang
+	}
+	return DefaultLocale
+}
+
+// SetLocale selects the locale used by T
+func SetLocale(locale string) {
+	current.Store(Match(locale))
+}
+
+// Locale returns the locale used by T
+func Locale() string {
+	return current.Load().(string)
+}
+
+// T translates key into the current locale, formatting args into it
+func T(key string, args ...any) string {
+	return Translate(Locale(), key, args...)
+}
+
+// Translate translates key into the given locale
+func Translate(locale, key string, args ...any) string {
+	format, ok := catalogs[locale].Messages[key]
+	if !ok {
+		if format, ok = catalogs[DefaultLocale].Messages[key]; !ok {
+			format = key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
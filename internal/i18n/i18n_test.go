@@ -0,0 +1,51 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCatalogsComplete(t *testing.T) {
+	base := catalogs[DefaultLocale].Messages
+	for code, c := range catalogs {
+		for key, format := range base {
+			translated, ok := c.Messages[key]
+			if !ok {
+				t.Errorf("%s: missing %s", code, key)
+				continue
+			}
+			if strings.Count(translated, "%") != strings.Count(format, "%") {
+				t.Errorf("%s: %s has different format verbs than %s", code, key, DefaultLocale)
+			}
+		}
+		for key := range c.Messages {
+			if _, ok := base[key]; !ok {
+				t.Errorf("%s: %s is not in the %s catalog", code, key, DefaultLocale)
+			}
+		}
+	}
+}
+
+func TestMatchAndTranslate(t *testing.T) {
+	for in, want := range map[string]string{
+		"pt-BR":       "pt-BR",
+		"pt_BR.UTF-8": "pt-BR",
+		"pt":          "pt-BR",
+		"es-MX":       "es",
+		"de":          DefaultLocale,
+		"":            DefaultLocale,
+	} {
+		if got := Match(in); got != want {
+			t.Errorf("Match(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	SetLocale("pt-BR")
+	defer SetLocale(DefaultLocale)
+	if got := T("error.invalid_endpoint", "boom"); got != "Endpoint inválido: boom" {
+		t.Errorf("Unexpected translation: %q", got)
+	}
+	if got := T("no.such.key"); got != "no.such.key" {
+		t.Errorf("Expected unknown keys to be returned as is, got %q", got)
+	}
+}
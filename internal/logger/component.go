@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// Subsystems log through loggers of their own, which add a component field
+// so the log file can be filtered by subsystem, and can be given a level of
+// their own, e.g. to debug storage without the scheduler flooding the log.
+const (
+	ComponentStorage   = "storage"
+	ComponentScheduler = "scheduler"
+	ComponentExport    = "export"
+	ComponentRetention = "retention"
+)
+
+// ComponentField is the log field naming the component of a message
+const ComponentField = "component"
+
+// Components are the components that can be given a level of their own
+var Components = []string{ComponentStorage, ComponentScheduler, ComponentExport, ComponentRetention}
+
+var (
+	levelsMu sync.RWMutex
+	// defaultLevel applies outside components and to components without
+	// a level of their own
+	defaultLevel    = zerolog.InfoLevel
+	componentLevels = map[string]zerolog.Level{}
+
+	// base is the logger New created, without level filtering, which
+	// component loggers are derived from
+	base        zerolog.Logger
+	initialized bool
+)
+
+// levelHook discards the messages below the level of its component, or the
+// default level for the empty component. zerolog's global level is kept at
+// the most verbose level in use so the hooks see every message they may keep.
+type levelHook string
+
+func (h levelHook) Run(e *zerolog.Event, level zerolog.Level, _ string) {
+	if level < levelOf(string(h)) {
+		e.Discard()
+	}
+}
+
+func levelOf(component string) zerolog.Level {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	if level, ok := componentLevels[component]; ok {
+		return level
+	}
+	return defaultLevel
+}
+
+// applyGlobalLevel sets zerolog's global level to the most verbose level in
+// use. levelsMu must be held.
+func applyGlobalLevel() {
+	global := defaultLevel
+	for _, level := range componentLevels {
+		global = min(global, level)
+	}
+	zerolog.SetGlobalLevel(global)
+}
+
+// parseLevel accepts the names in Levels
+func parseLevel(name string) (zerolog.Level, error) {
+	level, err := zerolog.ParseLevel(name)
+	if err != nil || level < zerolog.TraceLevel || level > zerolog.ErrorLevel {
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
+
+// WithComponent returns a context whose logger adds the component field and
+// logs at the component's level
+func WithComponent(ctx context.Context, component string) context.Context {
+	l := *zerolog.Ctx(ctx)
+	if initialized {
+		l = base
+	}
+	l = l.With().Str(ComponentField, component).Logger().Hook(levelHook(component))
+	return l.WithContext(ctx)
+}
+
+// SetComponentLevels gives components levels of their own, replacing those
+// set before. Components left out log at the default level.
+func SetComponentLevels(levels map[string]string) error {
+	parsed, err := parseComponentLevels(levels)
+	if err != nil {
+		return err
+	}
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	componentLevels = parsed
+	applyGlobalLevel()
+	return nil
+}
+
+// ValidateComponentLevels checks levels for SetComponentLevels
+func ValidateComponentLevels(levels map[string]string) error {
+	_, err := parseComponentLevels(levels)
+	return err
+}
+
+func parseComponentLevels(levels map[string]string) (map[string]zerolog.Level, error) {
+	parsed := make(map[string]zerolog.Level, len(levels))
+	for component, name := range levels {
+		if !slices.Contains(Components, component) {
+			return nil, fmt.Errorf("unknown log component %q", component)
+		}
+		level, err := parseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", component, err)
+		}
+		parsed[component] = level
+	}
+	return parsed, nil
+}
+
+// ComponentLevels returns the components with a level of their own
+func ComponentLevels() map[string]string {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	levels := make(map[string]string, len(componentLevels))
+	for component, level := range componentLevels {
+		levels[component] = level.String()
+	}
+	return levels
+}
@@ -28,14 +28,18 @@ func New(logDir string, debug bool) (zerolog.Logger, func(), error) {
 	consoleWriter := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
 	multi := zerolog.MultiLevelWriter(consoleWriter, file)
 
-	l := zerolog.New(multi).With().Timestamp().Logger()
+	base = zerolog.New(multi).With().Timestamp().Logger()
+	initialized = true
+	l := base.Hook(levelHook(""))
 
-	// Set global level (still useful if using zerolog types, but we are returning instance)
 	level := zerolog.InfoLevel
 	if debug {
 		level = zerolog.DebugLevel
 	}
-	zerolog.SetGlobalLevel(level)
+	levelsMu.Lock()
+	defaultLevel = level
+	applyGlobalLevel()
+	levelsMu.Unlock()
 
 	l.Info().Str("path", LogFile).Msg("Logger initialized")
 
@@ -48,6 +52,31 @@ func New(logDir string, debug bool) (zerolog.Logger, func(), error) {
 	return l, closeFunc, nil
 }
 
+// Levels are the log levels that can be set, most verbose first
+var Levels = []string{"trace", "debug", "info", "warn", "error"}
+
+// SetLevel changes the default level, used outside components and by
+// components without a level of their own, such as to enable debug logging
+// while reproducing an issue. It lasts until the app restarts.
+func SetLevel(name string) error {
+	level, err := parseLevel(name)
+	if err != nil {
+		return err
+	}
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	defaultLevel = level
+	applyGlobalLevel()
+	return nil
+}
+
+// Level returns the default log level
+func Level() string {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	return defaultLevel.String()
+}
+
 // GetLogPath returns the absolute path to the log file
 func GetLogPath() string {
 	abs, err := filepath.Abs(LogFile)
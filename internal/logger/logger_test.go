@@ -1,8 +1,12 @@
 package logger
 
 import (
+	"context"
 	"os"
+	"strings"
 	"testing"
+
+	"github.com/rs/zerolog/log"
 )
 
 func TestNew(t *testing.T) {
@@ -17,3 +21,69 @@ func TestNew(t *testing.T) {
 		t.Errorf("LogFile not created: %s", LogFile)
 	}
 }
+
+func TestSetLevel(t *testing.T) {
+	defer SetLevel(Level())
+	for _, name := range Levels {
+		if err := SetLevel(name); err != nil {
+			t.Fatalf("SetLevel(%q) failed: %v", name, err)
+		}
+		if Level() != name {
+			t.Errorf("Expected level %q, got %q", name, Level())
+		}
+	}
+	for _, name := range []string{"", "verbose", "disabled", "panic"} {
+		if err := SetLevel(name); err == nil {
+			t.Errorf("SetLevel(%q) should fail", name)
+		}
+	}
+	if Level() != "error" {
+		t.Errorf("Failed SetLevel changed the level to %q", Level())
+	}
+}
+
+func TestComponentLevels(t *testing.T) {
+	l, closeFunc, err := New(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer closeFunc()
+	defer SetComponentLevels(nil)
+
+	if err := SetComponentLevels(map[string]string{"storage": "verbose"}); err == nil {
+		t.Errorf("Expected error for an unknown level")
+	}
+	if err := SetComponentLevels(map[string]string{"network": "debug"}); err == nil {
+		t.Errorf("Expected error for an unknown component")
+	}
+	if err := SetComponentLevels(map[string]string{ComponentStorage: "debug", ComponentExport: "error"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := l.WithContext(context.Background())
+	log.Ctx(ctx).Debug().Msg("root debug")
+	log.Ctx(ctx).Info().Msg("root info")
+	log.Ctx(WithComponent(ctx, ComponentStorage)).Debug().Msg("storage debug")
+	log.Ctx(WithComponent(ctx, ComponentScheduler)).Debug().Msg("scheduler debug")
+	log.Ctx(WithComponent(ctx, ComponentScheduler)).Info().Msg("scheduler info")
+	log.Ctx(WithComponent(ctx, ComponentExport)).Warn().Msg("export warn")
+
+	data, err := os.ReadFile(LogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, msg := range []string{"root info", "storage debug", "scheduler info"} {
+		if !strings.Contains(out, msg) {
+			t.Errorf("Expected %q in the log:\n%s", msg, out)
+		}
+	}
+	for _, msg := range []string{"root debug", "scheduler debug", "export warn"} {
+		if strings.Contains(out, msg) {
+			t.Errorf("Unexpected %q in the log:\n%s", msg, out)
+		}
+	}
+	if !strings.Contains(out, `"level":"debug","component":"storage"`) {
+		t.Errorf("Expected the component field in the log:\n%s", out)
+	}
+}
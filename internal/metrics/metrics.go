@@ -0,0 +1,198 @@
+// Package metrics serves endpoint latency and availability, the scheduler
+// queue and the storage size in the Prometheus text format, so a Prometheus
+// server can scrape netmonitor.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultListen is used when the metrics settings don't set an address
+const DefaultListen = "127.0.0.1:9321"
+
+const shutdownTimeout = 5 * time.Second
+
+// LatencyBuckets are the upper bounds, in seconds, of the latency histogram
+var LatencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Endpoint identifies a configured endpoint and gives its labels
+type Endpoint struct {
+	ID     string
+	Name   string
+	Region string
+	Type   string
+}
+
+// Source provides the values read at every scrape
+type Source interface {
+	// Endpoints lists the configured endpoints. Metrics of others aren't served.
+	Endpoints() []Endpoint
+	States() map[string]models.EndpointState
+	// Availability returns the ratio of successful tests of each endpoint today
+	Availability() map[string]float64
+	// Queue returns the scheduled tests waiting to run and those running
+	Queue() (queued, running int)
+	// StorageBytes returns the size of the stored results
+	StorageBytes() int64
+}
+
+// Exporter counts test results as they arrive and serves them with the
+// values of its Source
+type Exporter struct {
+	Ctx    context.Context
+	Source Source
+
+	mu        sync.Mutex
+	latencies map[string]*histogram // Endpoint ID -> latency of successful tests
+	tests     map[testKey]uint64
+
+	srvMu  sync.Mutex
+	srv    *http.Server
+	addr   net.Addr
+	listen string
+}
+
+type testKey struct {
+	id     string
+	result string // "success" or "failure"
+}
+
+// histogram counts observations per bucket of LatencyBuckets, not
+// cumulatively; the +Inf bucket is count
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+func New(ctx context.Context, source Source) *Exporter {
+	return &Exporter{
+		Ctx:       ctx,
+		Source:    source,
+		latencies: make(map[string]*histogram),
+		tests:     make(map[testKey]uint64),
+	}
+}
+
+// Observe counts a test result. Cancelled tests say nothing about the
+// endpoint and aren't counted.
+func (e *Exporter) Observe(r models.TestResult) {
+	if r.St == models.TestStatusCancelled {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if r.St != models.TestStatusSuccess {
+		e.tests[testKey{r.Id, "failure"}]++
+		return
+	}
+	e.tests[testKey{r.Id, "success"}]++
+	h, ok := e.latencies[r.Id]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(LatencyBuckets))}
+		e.latencies[r.Id] = h
+	}
+	seconds := r.LatencyMs() / 1000
+	if i := sort.SearchFloat64s(LatencyBuckets, seconds); i < len(LatencyBuckets) {
+		h.buckets[i]++
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// ValidateSettings checks the listen address
+func ValidateSettings(s models.MetricsSettings) error {
+	if s.Listen == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(s.Listen); err != nil {
+		return fmt.Errorf("invalid listen address: %w", err)
+	}
+	return nil
+}
+
+// Configure starts, restarts or stops the listener to match the settings.
+// Nil or disabled settings stop it.
+func (e *Exporter) Configure(settings *models.MetricsSettings) error {
+	if settings == nil || !settings.Enabled {
+		e.Stop()
+		return nil
+	}
+	if err := ValidateSettings(*settings); err != nil {
+		return err
+	}
+	listen := settings.Listen
+	if listen == "" {
+		listen = DefaultListen
+	}
+
+	e.srvMu.Lock()
+	running := e.srv != nil && e.listen == listen
+	e.srvMu.Unlock()
+	if running {
+		return nil
+	}
+
+	e.Stop()
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: e.Handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	e.srvMu.Lock()
+	e.srv, e.addr, e.listen = srv, ln.Addr(), listen
+	e.srvMu.Unlock()
+
+	log.Ctx(e.Ctx).Info().Str("addr", ln.Addr().String()).Msg("Metrics server started")
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Ctx(e.Ctx).Error().Err(err).Msg("Metrics server failed")
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address metrics are served on, nil when not running
+func (e *Exporter) Addr() net.Addr {
+	e.srvMu.Lock()
+	defer e.srvMu.Unlock()
+	return e.addr
+}
+
+// Stop closes the listener
+func (e *Exporter) Stop() {
+	e.srvMu.Lock()
+	srv := e.srv
+	e.srv, e.addr, e.listen = nil, nil, ""
+	e.srvMu.Unlock()
+	if srv == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Ctx(e.Ctx).Warn().Err(err).Msg("Metrics server did not shut down cleanly")
+	}
+	log.Ctx(e.Ctx).Info().Msg("Metrics server stopped")
+}
+
+// Handler serves /metrics
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		e.Write(w)
+	})
+	return mux
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+type fakeSource struct{}
+
+func (fakeSource) Endpoints() []Endpoint {
+	return []Endpoint{
+		{ID: "b", Name: `Say "hi"`, Region: "Default", Type: "TCP"},
+		{ID: "a", Name: "Router", Region: "Home", Type: "ICMP"},
+	}
+}
+
+func (fakeSource) States() map[string]models.EndpointState {
+	return map[string]models.EndpointState{
+		"a": {EndpointID: "a", Up: true, LastResult: models.TestResult{Ms: 12}},
+		"b": {EndpointID: "b", Up: false},
+	}
+}
+
+func (fakeSource) Availability() map[string]float64 { return map[string]float64{"a": 0.75} }
+func (fakeSource) Queue() (int, int)                { return 3, 2 }
+func (fakeSource) StorageBytes() int64              { return 2048 }
+
+func TestWrite(t *testing.T) {
+	e := New(context.Background(), fakeSource{})
+	for _, r := range []models.TestResult{
+		{Id: "a", Ms: 3},
+		{Id: "a", Us: 40_000},
+		{Id: "a", St: models.TestStatusTimeout},
+		{Id: "a", St: models.TestStatusCancelled},
+		{Id: "gone", Ms: 1}, // No longer configured
+	} {
+		e.Observe(r)
+	}
+
+	var sb strings.Builder
+	e.Write(&sb)
+	out := sb.String()
+	a := `endpoint="a",name="Router",region="Home",type="ICMP"`
+	for _, want := range []string{
+		"# TYPE netmonitor_endpoint_latency_seconds histogram\n",
+		`netmonitor_endpoint_up{` + a + `} 1`,
+		`netmonitor_endpoint_up{endpoint="b",name="Say \"hi\"",region="Default",type="TCP"} 0`,
+		`netmonitor_endpoint_last_latency_seconds{` + a + `} 0.012`,
+		`netmonitor_endpoint_availability_ratio{` + a + `} 0.75`,
+		`netmonitor_endpoint_tests_total{` + a + `,result="success"} 2`,
+		`netmonitor_endpoint_tests_total{` + a + `,result="failure"} 1`,
+		`netmonitor_endpoint_latency_seconds_bucket{` + a + `,le="0.0025"} 0`,
+		`netmonitor_endpoint_latency_seconds_bucket{` + a + `,le="0.005"} 1`,
+		`netmonitor_endpoint_latency_seconds_bucket{` + a + `,le="0.05"} 2`,
+		`netmonitor_endpoint_latency_seconds_bucket{` + a + `,le="+Inf"} 2`,
+		`netmonitor_endpoint_latency_seconds_sum{` + a + `} 0.043`,
+		`netmonitor_endpoint_latency_seconds_count{` + a + `} 2`,
+		"netmonitor_scheduler_queued_tests 3\n",
+		"netmonitor_scheduler_running_tests 2\n",
+		"netmonitor_storage_bytes 2048\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `endpoint="gone"`) || strings.Contains(out, `netmonitor_endpoint_last_latency_seconds{endpoint="b"`) {
+		t.Errorf("Expected only configured endpoints, and no latency for a down one:\n%s", out)
+	}
+	if strings.Index(out, `endpoint="a"`) > strings.Index(out, `endpoint="b"`) {
+		t.Errorf("Expected endpoints sorted by ID:\n%s", out)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	e := New(context.Background(), fakeSource{})
+	if err := e.Configure(&models.MetricsSettings{Enabled: true, Listen: "bogus"}); err == nil {
+		t.Error("Expected an invalid listen address to be rejected")
+	}
+	if err := e.Configure(&models.MetricsSettings{Enabled: true, Listen: "127.0.0.1:0"}); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Stop()
+
+	resp, err := http.Get("http://" + e.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "netmonitor_storage_bytes 2048") {
+		t.Errorf("Unexpected scrape: %d %s", resp.StatusCode, body)
+	}
+
+	if err := e.Configure(nil); err != nil || e.Addr() != nil {
+		t.Errorf("Expected nil settings to stop the listener, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Write writes every metric in the Prometheus text exposition format.
+// Endpoints are sorted by ID so scrapes are stable.
+func (e *Exporter) Write(w io.Writer) {
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	endpoints := e.Source.Endpoints()
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].ID < endpoints[j].ID })
+	states := e.Source.States()
+	availability := e.Source.Availability()
+
+	header(bw, "netmonitor_endpoint_up", "gauge", "Whether the last test of the endpoint succeeded")
+	for _, ep := range endpoints {
+		if state, ok := states[ep.ID]; ok {
+			up := 0
+			if state.Up {
+				up = 1
+			}
+			sample(bw, "netmonitor_endpoint_up", labels(ep), float64(up))
+		}
+	}
+
+	header(bw, "netmonitor_endpoint_last_latency_seconds", "gauge", "Latency of the last successful test of the endpoint")
+	for _, ep := range endpoints {
+		if state, ok := states[ep.ID]; ok && state.Up {
+			sample(bw, "netmonitor_endpoint_last_latency_seconds", labels(ep), state.LastResult.LatencyMs()/1000)
+		}
+	}
+
+	header(bw, "netmonitor_endpoint_availability_ratio", "gauge", "Ratio of successful tests of the endpoint today")
+	for _, ep := range endpoints {
+		if ratio, ok := availability[ep.ID]; ok {
+			sample(bw, "netmonitor_endpoint_availability_ratio", labels(ep), ratio)
+		}
+	}
+
+	e.mu.Lock()
+	header(bw, "netmonitor_endpoint_tests_total", "counter", "Tests of the endpoint since the app started, by result")
+	for _, ep := range endpoints {
+		for _, result := range []string{"success", "failure"} {
+			if n, ok := e.tests[testKey{ep.ID, result}]; ok {
+				sample(bw, "netmonitor_endpoint_tests_total", labels(ep)+`,result="`+result+`"`, float64(n))
+			}
+		}
+	}
+
+	header(bw, "netmonitor_endpoint_latency_seconds", "histogram", "Latency of the successful tests of the endpoint since the app started")
+	for _, ep := range endpoints {
+		h, ok := e.latencies[ep.ID]
+		if !ok {
+			continue
+		}
+		var cumulative uint64
+		for i, le := range LatencyBuckets {
+			cumulative += h.buckets[i]
+			sample(bw, "netmonitor_endpoint_latency_seconds_bucket", labels(ep)+`,le="`+formatFloat(le)+`"`, float64(cumulative))
+		}
+		sample(bw, "netmonitor_endpoint_latency_seconds_bucket", labels(ep)+`,le="+Inf"`, float64(h.count))
+		sample(bw, "netmonitor_endpoint_latency_seconds_sum", labels(ep), h.sum)
+		sample(bw, "netmonitor_endpoint_latency_seconds_count", labels(ep), float64(h.count))
+	}
+	e.mu.Unlock()
+
+	queued, running := e.Source.Queue()
+	header(bw, "netmonitor_scheduler_queued_tests", "gauge", "Scheduled tests waiting for a worker")
+	sample(bw, "netmonitor_scheduler_queued_tests", "", float64(queued))
+	header(bw, "netmonitor_scheduler_running_tests", "gauge", "Scheduled tests running")
+	sample(bw, "netmonitor_scheduler_running_tests", "", float64(running))
+
+	header(bw, "netmonitor_storage_bytes", "gauge", "Size of the stored results")
+	sample(bw, "netmonitor_storage_bytes", "", float64(e.Source.StorageBytes()))
+}
+
+func header(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes one sample. labels are the label pairs without braces.
+func sample(w io.Writer, name, labels string, value float64) {
+	if labels != "" {
+		fmt.Fprintf(w, "%s{%s} %s\n", name, labels, formatFloat(value))
+		return
+	}
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(value))
+}
+
+func labels(ep Endpoint) string {
+	return `endpoint="` + escape(ep.ID) + `",name="` + escape(ep.Name) +
+		`",region="` + escape(ep.Region) + `",type="` + escape(ep.Type) + `"`
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
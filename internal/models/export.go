@@ -0,0 +1,113 @@
+package models
+
+// ExportFormat defines the file format produced by an export
+type ExportFormat string
+
+const (
+	ExportCSV    ExportFormat = "csv"
+	ExportJSON   ExportFormat = "json"
+	ExportNDJSON ExportFormat = "ndjson" // One JSON object per line, for log pipelines
+	ExportICS    ExportFormat = "ics"    // Detected outages as iCalendar events
+)
+
+// ExportCompression defines how the export file is packaged
+type ExportCompression string
+
+const (
+	CompressionNone ExportCompression = ""
+	CompressionZip  ExportCompression = "zip"
+	CompressionGzip ExportCompression = "gzip" // Single file stream, export.csv.gz
+	CompressionZstd ExportCompression = "zstd" // Single file stream, export.csv.zst
+)
+
+// ExportAggregation selects whether an export has one row per result or
+// per endpoint and period
+type ExportAggregation string
+
+const (
+	AggregateNone   ExportAggregation = ""
+	AggregateHourly ExportAggregation = "hourly"
+	AggregateDaily  ExportAggregation = "daily" // Days in the region's time zone, UTC if it has none
+)
+
+// ExportState describes where an export job is in its lifecycle
+type ExportState string
+
+const (
+	ExportQueued    ExportState = "queued"
+	ExportRunning   ExportState = "running"
+	ExportCompleted ExportState = "completed"
+	ExportFailed    ExportState = "failed"
+	ExportCancelled ExportState = "cancelled"
+	// ExportInterrupted marks jobs that were queued or running when the app shut down
+	ExportInterrupted ExportState = "interrupted"
+)
+
+// ExportRequest describes which results should be exported and how
+type ExportRequest struct {
+	Format      ExportFormat      `json:"format"`
+	Start       int64             `json:"start"` // UnixMilli, inclusive
+	End         int64             `json:"end"`   // UnixMilli, inclusive
+	EndpointIDs []string          `json:"endpoint_ids,omitempty"`
+	Compression ExportCompression `json:"compression,omitempty"`
+	Columns     []string          `json:"columns,omitempty"`     // Fields in output order, "tags.<name>" for a tag
+	Aggregate   ExportAggregation `json:"aggregate,omitempty"`   // Export aggregates instead of results
+	Destination string            `json:"destination,omitempty"` // Directory overriding the default export directory
+	// Passphrase encrypts the zip archive with AES-256 when set. It is kept
+	// in memory only and never written to logs or export status.
+	Passphrase string `json:"passphrase,omitempty"`
+	// Origin is set by the interface the export was requested through,
+	// values sent by clients are replaced
+	Origin *ExportOrigin `json:"origin,omitempty"`
+}
+
+// Interfaces exports are requested through
+const (
+	OriginApp = "app"
+	OriginAPI = "api"
+	OriginCLI = "cli"
+)
+
+// ExportOrigin records who requested an export and through which interface
+type ExportOrigin struct {
+	Interface string `json:"interface"`
+	Actor     string `json:"actor,omitempty"`  // OS user for the app and CLI, token name for the API
+	Client    string `json:"client,omitempty"` // Address of API clients
+}
+
+// ExportAuditEntry records an export being queued or finishing. Retries are
+// recorded with the origin of the original request.
+type ExportAuditEntry struct {
+	Time      int64         `json:"time"` // UnixMilli
+	ExportID  string        `json:"export_id"`
+	State     ExportState   `json:"state"`
+	Attempt   int           `json:"attempt"`
+	Request   ExportRequest `json:"request"` // Without the passphrase
+	Encrypted bool          `json:"encrypted,omitempty"`
+	Path      string        `json:"path,omitempty"`
+	Rows      int           `json:"rows,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// ExportStatus reports the progress of an export job
+type ExportStatus struct {
+	ID        string      `json:"id"`
+	State     ExportState `json:"state"`
+	Position  int         `json:"position,omitempty"` // 1-based position while queued
+	Path      string      `json:"path,omitempty"`
+	Rows      int         `json:"rows"`                 // Written so far while running
+	RowsTotal int         `json:"rows_total,omitempty"` // Expected rows while running, estimated until the results are read
+	EtaMs     int64       `json:"eta_ms,omitempty"`     // Estimated time left while running
+	Attempts  int         `json:"attempts"`
+	Error     string      `json:"error,omitempty"`
+	CreatedAt int64       `json:"created_at"`
+	UpdatedAt int64       `json:"updated_at"`
+}
+
+// ExportPreset is a named export request that can be re-run with one call.
+// The exported window is relative to the time the preset is run.
+type ExportPreset struct {
+	Name       string        `json:"name"`
+	RangeHours int           `json:"range_hours"`
+	Request    ExportRequest `json:"request"`
+}
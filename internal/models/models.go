@@ -8,6 +8,19 @@ const (
 	TypeTCP  EndpointType = "TCP"
 	TypeUDP  EndpointType = "UDP"
 	TypeICMP EndpointType = "ICMP"
+	// TypeUDPJitter sends a VoIP-like packet train to a UDP echo service and
+	// measures jitter, loss and an estimated MOS
+	TypeUDPJitter EndpointType = "UDP_JITTER"
+	// TypeDNS queries a resolver over UDP, TCP, TLS (DoT) or HTTPS (DoH)
+	TypeDNS EndpointType = "DNS"
+	// TypeHappyEyeballs connects to host:port over IPv4 and IPv6 in parallel
+	// and records which family was faster
+	TypeHappyEyeballs EndpointType = "HAPPY_EYEBALLS"
+	// TypeTraceroute traces the IPv4 path to a host and tracks route changes
+	TypeTraceroute EndpointType = "TRACEROUTE"
+	// TypeTLS completes a TLS handshake with host:port and records the
+	// negotiated version and cipher and the server certificate's expiry
+	TypeTLS EndpointType = "TLS"
 )
 
 // Endpoint represents a single network target to monitor
@@ -16,6 +29,154 @@ type Endpoint struct {
 	Type    EndpointType `json:"type"`
 	Address string       `json:"address"`
 	Timeout int          `json:"timeout"` // Timeout in milliseconds
+
+	// IntervalSeconds, when set, tests the endpoint at its own interval
+	// instead of the settings' TestIntervalSeconds, e.g. every 10 seconds for
+	// a critical endpoint
+	IntervalSeconds int `json:"interval_seconds,omitempty"`
+
+	// Priority orders the endpoints of a region, highest first: they are
+	// listed first and, when concurrency limits or the data budget hold
+	// tests back, tested first. Endpoints of equal priority keep their
+	// order in the config.
+	Priority int `json:"priority,omitempty"`
+
+	// Confirm is an optional secondary probe run when a test fails, e.g. a
+	// TCP connect to the host of an HTTP endpoint. When it succeeds the
+	// endpoint is reported as degraded rather than down.
+	Confirm *TestConfig `json:"confirm,omitempty"`
+
+	// HTTP holds options of HTTP endpoints
+	HTTP *HTTPOptions `json:"http,omitempty"`
+
+	// TCP holds options of TCP endpoints
+	TCP *TCPOptions `json:"tcp,omitempty"`
+
+	// UDP holds options of UDP endpoints
+	UDP *UDPOptions `json:"udp,omitempty"`
+
+	// DNS holds options of DNS endpoints
+	DNS *DNSOptions `json:"dns,omitempty"`
+
+	// ICMP holds options of ICMP endpoints
+	ICMP *ICMPOptions `json:"icmp,omitempty"`
+
+	// AllAddresses tests every address the host resolves to in parallel,
+	// e.g. each anycast or CDN node, instead of the one the resolver picks.
+	// The result fails when any address fails and tags each one's outcome.
+	AllAddresses bool `json:"all_addresses,omitempty"`
+}
+
+// PluginInfo describes an external test plugin providing a custom endpoint type
+type PluginInfo struct {
+	Type    EndpointType `json:"type"`
+	Name    string       `json:"name"`
+	Version string       `json:"version,omitempty"`
+	Path    string       `json:"path"`
+}
+
+// UDPOptions tune how UDP endpoints are tested. Without expectations the
+// test only checks that the datagram could be sent.
+type UDPOptions struct {
+	// Payload is the datagram sent, given as text or as hex for binary
+	// protocols. Defaults to a single zero byte.
+	Payload    string `json:"payload,omitempty"`
+	PayloadHex string `json:"payload_hex,omitempty"`
+
+	// The test waits for a reply and succeeds only if it matches every
+	// expectation set: a text prefix, a regular expression, or hex bytes at
+	// its start where ?? matches any byte, e.g. "1c??0001"
+	ExpectPrefix string `json:"expect_prefix,omitempty"`
+	ExpectRegex  string `json:"expect_regex,omitempty"`
+	ExpectHex    string `json:"expect_hex,omitempty"`
+}
+
+// DNSOptions tune how DNS endpoints are tested. The resolver and the name
+// to query are part of the endpoint address.
+type DNSOptions struct {
+	// RecordType is the type of record queried, e.g. "AAAA" or "MX".
+	// Defaults to "A".
+	RecordType string `json:"record_type,omitempty"`
+	// Expect lists values that must all be among the answers: addresses
+	// for A and AAAA records, host names for CNAME, MX, NS and PTR records
+	// and the text of TXT records
+	Expect []string `json:"expect,omitempty"`
+	// DNSSEC asks the resolver to validate the answer and fails the test
+	// unless it reports the answer as authenticated
+	DNSSEC bool `json:"dnssec,omitempty"`
+}
+
+// ICMPOptions tune how ICMP endpoints are tested
+type ICMPOptions struct {
+	// Count is the number of echo requests sent per test, 1 by default.
+	// With more than one, results record packet loss, jitter and RTT
+	// statistics, and the test fails only when every request is lost.
+	Count int `json:"count,omitempty"`
+	// IntervalMs is the time between echo requests, 200 by default
+	IntervalMs int `json:"interval_ms,omitempty"`
+}
+
+// TCPOptions tune how TCP endpoints are tested
+type TCPOptions struct {
+	// Reuse sends Payload over the new connection and times the first byte
+	// of the reply, so the round trip on an established connection is
+	// recorded next to the connect time
+	Reuse   bool   `json:"reuse,omitempty"`
+	Payload string `json:"payload,omitempty"`
+}
+
+// HTTPOptions tune how HTTP endpoints are tested
+type HTTPOptions struct {
+	// KeepAlive reuses a connection pooled for the endpoint across tests to
+	// measure warm-connection latency. Results are tagged with whether the
+	// connection was reused.
+	KeepAlive bool `json:"keep_alive,omitempty"`
+	// Version restricts the test to HTTP/1.1 or HTTP/2, see HTTPVersion1
+	// and HTTPVersion2. Empty negotiates like a browser would.
+	Version string `json:"version,omitempty"`
+	// Method defaults to GET, or POST when a body or form is set
+	Method string `json:"method,omitempty"`
+	// Body is sent as is with ContentType. Form is sent URL encoded instead,
+	// as application/x-www-form-urlencoded unless ContentType is set.
+	Body        string            `json:"body,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Form        map[string]string `json:"form,omitempty"`
+	// Steps are requested in order before the endpoint's own request, all
+	// sharing a cookie jar, e.g. to log in before fetching a health page.
+	// The test fails at the first step that fails and its latency covers
+	// the whole sequence.
+	Steps []HTTPStep `json:"steps,omitempty"`
+}
+
+// HTTPStep is a request made before the endpoint's own one in an HTTP test.
+// Its fields work like the HTTPOptions fields of the same name.
+type HTTPStep struct {
+	// Address is an absolute URL or a path relative to the endpoint address
+	Address     string            `json:"address"`
+	Method      string            `json:"method,omitempty"`
+	Body        string            `json:"body,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Form        map[string]string `json:"form,omitempty"`
+}
+
+// HTTP versions an endpoint can be restricted to. HTTP/2 is used without TLS
+// too, for http:// addresses of servers that accept it.
+const (
+	HTTPVersion1 = "1.1"
+	HTTPVersion2 = "2"
+)
+
+// TestConfig describes a one-off test target that isn't saved as an endpoint
+type TestConfig struct {
+	Type    EndpointType `json:"type"`
+	Address string       `json:"address"`
+	Timeout int          `json:"timeout"` // Timeout in milliseconds
+}
+
+// AdHocTestResult is the outcome of a one-off test, including the failure reason
+type AdHocTestResult struct {
+	Result TestResult `json:"result"`
+	Error  string     `json:"error,omitempty"`
 }
 
 // Thresholds defines when to trigger alerts for a region
@@ -28,15 +189,216 @@ type Thresholds struct {
 type Region struct {
 	Endpoints  []Endpoint `json:"endpoints"`
 	Thresholds Thresholds `json:"thresholds"`
+	// Concurrency caps how many of the region's endpoints are tested at the
+	// same time, so a region of slow endpoints doesn't hog the scheduler.
+	// Zero means no limit.
+	Concurrency int `json:"concurrency,omitempty"`
+	// Location places the region on a map. Optional.
+	Location *GeoPoint `json:"location,omitempty"`
+	// Timezone is the IANA time zone of the region, e.g. "Europe/Lisbon".
+	// Hourly and daily aggregates of its endpoints start on its local hours
+	// and midnights. Empty uses UTC.
+	Timezone string `json:"timezone,omitempty"`
+	// BusinessHours, when set, get their own availability figures, e.g. for
+	// an SLA that only covers working hours. Optional.
+	BusinessHours *BusinessHours `json:"business_hours,omitempty"`
 }
 
+// BusinessHours are the weekly hours an SLA covers, in the region's time
+// zone, e.g. 8×5 from 09:00 to 17:00 Monday to Friday
+type BusinessHours struct {
+	// Days are the weekdays covered: "mon", "tue", "wed", "thu", "fri",
+	// "sat" and "sun". Monday to Friday when empty.
+	Days []string `json:"days,omitempty"`
+	// Start and End are local times of day as "15:04", End after Start.
+	// End may be "24:00".
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Holidays are local dates as "2006-01-02" that aren't covered at all
+	Holidays []string `json:"holidays,omitempty"`
+}
+
+// GeoPoint is a position in decimal degrees
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// EndpointRegion is the region an endpoint is configured in, with the
+// region's metadata
+type EndpointRegion struct {
+	Region        string         `json:"region"`
+	Location      *GeoPoint      `json:"location,omitempty"`
+	Timezone      string         `json:"timezone,omitempty"`
+	BusinessHours *BusinessHours `json:"business_hours,omitempty"`
+}
+
+// Values of TestResult.St
+const (
+	TestStatusSuccess = 0
+	TestStatusTimeout = 1
+	TestStatusError   = 2
+	// TestStatusCancelled marks a test aborted because monitoring was stopped
+	// or the batch it belonged to was cancelled. It says nothing about the
+	// endpoint, so it is excluded from outages and availability.
+	TestStatusCancelled = 3
+)
+
 // TestResult captures the outcome of a single endpoint test
 type TestResult struct {
-	Ts  int64  `json:"ts"`
+	Ts int64 `json:"ts"`
+	// Seq increases with every result of a session, so results stay ordered
+	// even if the system clock is set back. It restarts with the app.
+	Seq uint64 `json:"seq,omitempty"`
 	Id  string `json:"id"`
 	Ms  int64  `json:"ms"`
-	St  int    `json:"st"` // 0=success, 1=timeout, 2=error
-	Err error  `json:"err"`
+	// Us is the latency in microseconds, for sub-millisecond LAN latencies.
+	// Zero in results stored before schema 2, which only have Ms.
+	Us  int64     `json:"us,omitempty"`
+	St  int       `json:"st"`           // 0=success, 1=timeout, 2=error, 3=cancelled
+	Ek  ErrorKind `json:"ek,omitempty"` // Cause of the failure, empty on success
+	Err error     `json:"err"`
+
+	// Metrics of UDP jitter tests; Ms holds the average round-trip time
+	Jit  float64 `json:"jit,omitempty"`  // Jitter in milliseconds
+	Loss float64 `json:"loss,omitempty"` // Packet loss percentage
+	Mos  float64 `json:"mos,omitempty"`  // Estimated mean opinion score, 1-5
+
+	// Rtt holds the round-trip statistics of ICMP tests sending more than
+	// one echo request, whose jitter and packet loss are in Jit and Loss.
+	// Ms and Us hold the average.
+	Rtt *RTTStats `json:"rtt,omitempty"`
+
+	// Tags are annotations added by test middlewares, e.g. enrichment or
+	// anomaly detection
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Bytes the test sent and received, headers below the transport not
+	// included. Zero in results stored before traffic was counted.
+	Tx int64 `json:"tx,omitempty"`
+	Rx int64 `json:"rx,omitempty"`
+
+	// Trace identifies the test in the logs. Tests of a scheduling cycle or
+	// batch have the run ID as a prefix, e.g. 5f3a9c01.12.
+	Trace string `json:"trace,omitempty"`
+}
+
+// RTTStats are the round-trip times of the replies to a train of packets,
+// in microseconds
+type RTTStats struct {
+	Sent     int   `json:"sent"`
+	Recv     int   `json:"recv"`
+	MinUs    int64 `json:"min_us"`
+	AvgUs    int64 `json:"avg_us"`
+	MaxUs    int64 `json:"max_us"`
+	StdDevUs int64 `json:"stddev_us"`
+}
+
+// LatencyMs returns the latency in fractional milliseconds, as precise as the
+// result was stored
+func (r TestResult) LatencyMs() float64 {
+	if r.Us != 0 {
+		return float64(r.Us) / 1000
+	}
+	return float64(r.Ms)
+}
+
+// ErrorKind classifies why a test failed
+type ErrorKind string
+
+const (
+	ErrorKindDNS         ErrorKind = "dns"          // Name resolution failed
+	ErrorKindRefused     ErrorKind = "refused"      // Connection refused
+	ErrorKindUnreachable ErrorKind = "unreachable"  // No route to host or network
+	ErrorKindProhibited  ErrorKind = "prohibited"   // A firewall rejected the probe
+	ErrorKindTTLExceeded ErrorKind = "ttl_exceeded" // Probe expired in transit, e.g. a routing loop
+	ErrorKindTimeout     ErrorKind = "timeout"      // No answer within the endpoint timeout
+	ErrorKindTLS         ErrorKind = "tls"          // Handshake or certificate failure
+	ErrorKindHTTPStatus  ErrorKind = "http_status"  // Server answered with a 4xx/5xx status
+	ErrorKindPacketLoss  ErrorKind = "packet_loss"  // ICMP echo sent but not answered
+	ErrorKindBadResponse ErrorKind = "bad_response" // Reply didn't match the expected content
+	ErrorKindOther       ErrorKind = "other"
+)
+
+// ErrorKinds lists every ErrorKind
+var ErrorKinds = []ErrorKind{
+	ErrorKindDNS, ErrorKindRefused, ErrorKindUnreachable, ErrorKindProhibited, ErrorKindTTLExceeded,
+	ErrorKindTimeout, ErrorKindTLS, ErrorKindHTTPStatus, ErrorKindPacketLoss, ErrorKindBadResponse,
+	ErrorKindOther,
+}
+
+// AggregatedResult summarizes the results of one endpoint within a time bucket
+type AggregatedResult struct {
+	EndpointID string `json:"endpoint_id"`
+	Start      int64  `json:"start"` // UnixMilli, bucket start
+	End        int64  `json:"end"`   // UnixMilli, bucket end (exclusive)
+	Count      int    `json:"count"`
+	Failures   int    `json:"failures"`
+	// FailuresByKind counts failures per ErrorKind. Results stored before
+	// failures were classified are counted as ErrorKindOther.
+	FailuresByKind map[ErrorKind]int `json:"failures_by_kind,omitempty"`
+	AvgMs          float64           `json:"avg_ms"` // Over successful tests only
+	StdDevMs       float64           `json:"stddev_ms"`
+	MinMs          int64             `json:"min_ms"`
+	MaxMs          int64             `json:"max_ms"`
+	// Latency percentiles over successful tests. Estimated in constant memory,
+	// exact below five samples.
+	P50Ms float64 `json:"p50_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	P99Ms float64 `json:"p99_ms"`
+	// JitterMs averages the jitter measured by multi-packet tests, or
+	// without any, the mean difference between the latencies of consecutive
+	// successful tests
+	JitterMs float64 `json:"jitter_ms"`
+	// PacketLossPct averages the loss of every test: as measured by
+	// multi-packet tests, 100% for failed tests and none for other successes
+	PacketLossPct float64 `json:"packet_loss_pct"`
+	// BusinessCount and BusinessFailures count the tests run within the
+	// business hours of the endpoint's region, when it has them
+	BusinessCount    int `json:"business_count,omitempty"`
+	BusinessFailures int `json:"business_failures,omitempty"`
+	// Health is the composite health score, 0 to 100, weighted by the
+	// configured HealthWeights. Set where aggregates are served, since it
+	// depends on the endpoint's region thresholds.
+	Health float64 `json:"health"`
+}
+
+// HealthWeights sets how much availability, latency against the region's
+// latency threshold and jitter count towards the health score. Only their
+// ratios matter.
+type HealthWeights struct {
+	Availability float64 `json:"availability"`
+	Latency      float64 `json:"latency"`
+	Jitter       float64 `json:"jitter"`
+}
+
+// Display describes a connected display. Displays have no stable ID across
+// platforms, so one is told apart by its size and whether it is primary.
+type Display struct {
+	Width   int  `json:"width"`
+	Height  int  `json:"height"`
+	Primary bool `json:"primary,omitempty"`
+	// Count is the number of displays connected at the time
+	Count int `json:"count,omitempty"`
+}
+
+// HotkeySettings assigns global hotkeys such as "Ctrl+Alt+N" to actions.
+// Empty ones are not registered.
+type HotkeySettings struct {
+	ToggleWindow string `json:"toggle_window,omitempty"`
+	RunTests     string `json:"run_tests,omitempty"`
+}
+
+// BatterySettings change how monitoring runs while the computer is on
+// battery power. Full monitoring resumes once it is plugged in.
+type BatterySettings struct {
+	// Pause stops monitoring altogether
+	Pause bool `json:"pause,omitempty"`
+	// IntervalSeconds replaces the test interval when it is longer
+	IntervalSeconds int `json:"interval_seconds,omitempty"`
+	// LightTests runs heavy tests in a lighter form, or skips them when
+	// there is none, as the data budget does
+	LightTests bool `json:"light_tests,omitempty"`
 }
 
 // AppSettings defines global application settings
@@ -48,10 +410,685 @@ type AppSettings struct {
 	WindowHeight         int  `json:"window_height,omitempty"`
 	WindowX              int  `json:"window_x,omitempty"`
 	WindowY              int  `json:"window_y,omitempty"`
+	ExportConcurrency    int  `json:"export_concurrency,omitempty"`
+	// WindowMaximised restores the window maximised, keeping the size and
+	// position above for when it is restored down
+	WindowMaximised bool `json:"window_maximised,omitempty"`
+	// WindowDisplay is the display the window was last on, to tell whether
+	// its position is still valid when the display layout changed
+	WindowDisplay *Display `json:"window_display,omitempty"`
+	// ResultsTailPath, when set, receives every result as NDJSON as it is stored
+	ResultsTailPath string `json:"results_tail_path,omitempty"`
+	// PauseReasons keeps monitoring paused across restarts until cleared
+	PauseReasons []string `json:"pause_reasons,omitempty"`
+	// ReadOnly disables every change to the config from the app, for
+	// shared machines where users should only see status. It can only be
+	// turned off by editing the config file.
+	ReadOnly bool `json:"read_only,omitempty"`
+	// OnboardingPending is set on the config created at first run, until
+	// the user completes or skips onboarding
+	OnboardingPending bool `json:"onboarding_pending,omitempty"`
+	// GeoIPCountryDB and GeoIPASNDB are paths to MaxMind DB files used to tag
+	// results with the target's country and autonomous system
+	GeoIPCountryDB string `json:"geoip_country_db,omitempty"`
+	GeoIPASNDB     string `json:"geoip_asn_db,omitempty"`
+	// Locale selects the language of backend messages, notifications and
+	// exports. Empty means English.
+	Locale string `json:"locale,omitempty"`
+	// Sync replicates results and config to another location when set
+	Sync *SyncSettings `json:"sync,omitempty"`
+	// API enables the embedded HTTP API when set
+	API *APISettings `json:"api,omitempty"`
+	// Metrics serves Prometheus metrics when set
+	Metrics *MetricsSettings `json:"metrics,omitempty"`
+	// Alerting tunes threshold alerts and where they are sent
+	Alerting *AlertSettings `json:"alerting,omitempty"`
+	// Health overrides the default weights of the health score
+	Health *HealthWeights `json:"health,omitempty"`
+	// CleanupGraceDays, when positive, makes the retention cleanup move
+	// expired files to a trash folder, where they can be restored with
+	// UndoLastCleanup until they are deleted this many days later
+	CleanupGraceDays int `json:"cleanup_grace_days,omitempty"`
+	// ExportDir overrides the default exports folder in the app directory
+	ExportDir string `json:"export_dir,omitempty"`
+	// DataBudget caps the traffic of scheduled tests, for metered connections
+	DataBudget *DataBudget `json:"data_budget,omitempty"`
+	// UpdateCheck opts in to checking GitHub for new releases once a day
+	UpdateCheck bool `json:"update_check,omitempty"`
+	// Offline keeps the app from reaching the internet on its own, update
+	// checks included, for air-gapped installs. Tests still run.
+	Offline bool `json:"offline,omitempty"`
+	// Hotkeys are global keyboard shortcuts, which work while another
+	// application has the focus
+	Hotkeys *HotkeySettings `json:"hotkeys,omitempty"`
+	// Battery saves energy while the computer runs on battery when set
+	Battery *BatterySettings `json:"battery,omitempty"`
+	// CertWarningDays is how many days before a TLS or HTTPS endpoint's
+	// certificate expires to alert about it. Zero uses 14 days.
+	CertWarningDays int `json:"cert_warning_days,omitempty"`
+	// LogLevels gives the storage, scheduler, export and retention
+	// components log levels of their own, e.g. {"storage": "debug"}. The
+	// others log at the default level.
+	LogLevels map[string]string `json:"log_levels,omitempty"`
+}
+
+// UpdateInfo compares the running version with the latest release
+type UpdateInfo struct {
+	Current     string `json:"current"`
+	Latest      string `json:"latest,omitempty"`
+	Available   bool   `json:"available"` // Latest is newer than Current
+	Name        string `json:"name,omitempty"`
+	Notes       string `json:"notes,omitempty"` // Release notes, in Markdown
+	URL         string `json:"url,omitempty"`   // Release page
+	PublishedAt int64  `json:"published_at,omitempty"`
+	CheckedAt   int64  `json:"checked_at,omitempty"` // UnixMilli
+	// Error explains why the latest release is unknown, e.g. checks are
+	// disabled or GitHub couldn't be reached
+	Error string `json:"error,omitempty"`
+}
+
+// DataBudget limits how much traffic scheduled tests use per day and per
+// calendar month. Zero means no limit. Tests that would exceed the budget are
+// run in a lighter form where the protocol has one and skipped otherwise;
+// tests lighter than a few kilobytes always run.
+type DataBudget struct {
+	DailyMB   int `json:"daily_mb,omitempty"`
+	MonthlyMB int `json:"monthly_mb,omitempty"`
+}
+
+// DataUsage is the traffic of tests run today and this month, against the
+// data budget if one is set
+type DataUsage struct {
+	TodayBytes int64       `json:"today_bytes"`
+	MonthBytes int64       `json:"month_bytes"`
+	Budget     *DataBudget `json:"budget,omitempty"`
+}
+
+// PublicStatus summarizes availability per region for sharing with end
+// users. It never names endpoints or their addresses.
+type PublicStatus struct {
+	UpdatedAt int64          `json:"updated_at"` // UnixMilli
+	Regions   []RegionStatus `json:"regions"`
+}
+
+// Region states reported in RegionStatus
+const (
+	RegionOperational = "operational"
+	RegionDegraded    = "degraded"
+	RegionDown        = "down"
+	RegionUnknown     = "unknown"
+)
+
+// RegionStatus is the public status of a region
+type RegionStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// AvailabilityPercent covers the last 24 hours, time the monitor wasn't
+	// running excluded
+	AvailabilityPercent float64 `json:"availability_percent"`
+}
+
+// RegionMapEntry is the status of a region with its position, for the map
+// view. Regions without a location are included, the map lists them apart.
+type RegionMapEntry struct {
+	Name       string     `json:"name"`
+	Location   *GeoPoint  `json:"location,omitempty"`
+	Timezone   string     `json:"timezone,omitempty"`
+	Thresholds Thresholds `json:"thresholds"`
+	// Status is one of the region states, from the current endpoint states
+	Status    string `json:"status"`
+	Up        int    `json:"up"`
+	Down      int    `json:"down"`
+	Endpoints int    `json:"endpoints"`
+	// AvailabilityPercent and P95Ms cover the requested window.
+	// Availability excludes time the monitor wasn't running, latency counts
+	// successful tests only.
+	AvailabilityPercent float64 `json:"availability_percent"`
+	P95Ms               float64 `json:"p95_ms"`
+}
+
+// API token scopes
+const (
+	ScopeRead  = "read"  // Status, results and config, no changes
+	ScopeAdmin = "admin" // Everything, including config changes
+)
+
+// APISettings configures the embedded HTTP API. Every request needs a bearer
+// token; with ClientCA set clients must also present a certificate it signed.
+type APISettings struct {
+	Enabled bool   `json:"enabled"`
+	Listen  string `json:"listen,omitempty"` // host:port, 127.0.0.1:8321 if empty
+	// TLSCert and TLSKey are PEM file paths. Without them the API is plain HTTP.
+	TLSCert  string     `json:"tls_cert,omitempty"`
+	TLSKey   string     `json:"tls_key,omitempty"`
+	ClientCA string     `json:"client_ca,omitempty"` // PEM file path, requires TLS
+	Tokens   []APIToken `json:"tokens,omitempty"`
+	// CORSOrigins lists the browser origins allowed to call the API, e.g.
+	// https://grafana.example.com, or "*" for any
+	CORSOrigins []string `json:"cors_origins,omitempty"`
+	// BasePath serves the API under a path prefix, e.g. /netmonitor, for
+	// reverse proxies that route by path
+	BasePath string `json:"base_path,omitempty"`
+	// TrustedProxies lists the IPs or CIDRs of reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers identify the client
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+}
+
+// MetricsSettings configure the Prometheus metrics listener. It serves
+// /metrics without authentication, so it listens on loopback unless
+// configured otherwise.
+type MetricsSettings struct {
+	Enabled bool   `json:"enabled"`
+	Listen  string `json:"listen,omitempty"` // host:port, 127.0.0.1:9321 if empty
+}
+
+// APIToken grants access to the API. Only a hash of the token is stored.
+type APIToken struct {
+	Name      string `json:"name"`
+	Scope     string `json:"scope"`      // ScopeRead or ScopeAdmin
+	Hash      string `json:"hash"`       // Hex SHA-256 of the token
+	CreatedAt int64  `json:"created_at"` // UnixMilli
+}
+
+// NewAPIToken is returned once when a token is created, the only time the
+// token itself is available
+type NewAPIToken struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+	Token string `json:"token,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// LogLevel is the log level served and set through the API: trace, debug,
+// info, warn or error
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// SyncSettings configures replication of daily result files and the config
+// to a directory (e.g. a Dropbox or OneDrive folder) or a WebDAV server
+type SyncSettings struct {
+	Target          string `json:"target"` // Directory path, or http(s) URL of a WebDAV folder
+	Username        string `json:"username,omitempty"`
+	Password        string `json:"password,omitempty"`
+	IntervalMinutes int    `json:"interval_minutes,omitempty"`
+}
+
+// SyncReport summarizes a sync run
+type SyncReport struct {
+	Start      int64  `json:"start"` // UnixMilli
+	DurationMs int64  `json:"duration_ms"`
+	Uploaded   int    `json:"uploaded"`
+	Unchanged  int    `json:"unchanged"`
+	Conflicts  int    `json:"conflicts"` // Files written under a conflict name instead of overwriting
+	Error      string `json:"error,omitempty"`
 }
 
 // Configuration represents the entire application config structure
 type Configuration struct {
-	Regions  map[string]Region `json:"regions"`
-	Settings AppSettings       `json:"settings"`
+	Regions        map[string]Region `json:"regions"`
+	Settings       AppSettings       `json:"settings"`
+	ExportPresets  []ExportPreset    `json:"export_presets,omitempty"`
+	Hooks          []Hook            `json:"hooks,omitempty"`
+	Services       []Service         `json:"services,omitempty"`
+	RetentionHolds []RetentionHold   `json:"retention_holds,omitempty"` // Days kept regardless of DataRetentionDays
+	UI             UISettings        `json:"ui"`
+}
+
+// StorageUsage is the disk space and result count of part of the history,
+// one endpoint or one month
+type StorageUsage struct {
+	Key     string  `json:"key"`            // Endpoint ID or YYYY-MM
+	Name    string  `json:"name,omitempty"` // Endpoint name, when configured
+	Bytes   int64   `json:"bytes"`
+	Results int     `json:"results"`
+	Percent float64 `json:"percent"` // Share of the total bytes
+	// Network traffic of the stored tests, what monitoring costs on a
+	// metered connection
+	Sent     int64 `json:"sent"`
+	Received int64 `json:"received"`
+}
+
+// StorageStats summarizes the stored history. Endpoints are sorted by size,
+// largest first, and months by date.
+type StorageStats struct {
+	Files      int            `json:"files"`
+	Bytes      int64          `json:"bytes"`
+	Results    int            `json:"results"`
+	Sent       int64          `json:"sent"`             // Bytes the stored tests sent
+	Received   int64          `json:"received"`         // Bytes the stored tests received
+	Oldest     string         `json:"oldest,omitempty"` // First stored day, YYYY-MM-DD
+	Newest     string         `json:"newest,omitempty"`
+	TrashBytes int64          `json:"trash_bytes"`
+	Endpoints  []StorageUsage `json:"endpoints"`
+	Months     []StorageUsage `json:"months"`
+}
+
+// RetentionHold marks a day, or a range of days such as an incident window,
+// to be kept forever, e.g. as evidence for a dispute with the ISP. Dates are
+// in YYYY-MM-DD form and To is inclusive; an empty To holds only From.
+type RetentionHold struct {
+	From   string `json:"from"`
+	To     string `json:"to,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Service rollup rules
+const (
+	ServiceRuleAll    = "all"    // Up while every member is up
+	ServiceRuleAny    = "any"    // Up while at least one member is up
+	ServiceRuleQuorum = "quorum" // Up while at least Quorum members are up
+)
+
+// Service groups endpoints that together make up something users care
+// about, e.g. an API behind a load balancer, its DNS name and health URL
+type Service struct {
+	Name    string          `json:"name"`
+	Members []ServiceMember `json:"members"`
+	Rule    string          `json:"rule"`
+	// Quorum is the number of members that must be up with the quorum
+	// rule. Zero means a majority.
+	Quorum int `json:"quorum,omitempty"`
+}
+
+// ServiceMember refers to an endpoint by region and name
+type ServiceMember struct {
+	Region   string `json:"region"`
+	Endpoint string `json:"endpoint"`
+}
+
+// ServiceStatus is the rolled up state of a service. Status is one of the
+// region states: operational when every member is up, degraded when some
+// are down but the rule still holds, down when it doesn't and unknown
+// before any member was tested.
+type ServiceStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Up     int    `json:"up"`
+	Down   int    `json:"down"`
+	Total  int    `json:"total"`
+}
+
+// OnboardingProposal is what onboarding detected about the local network
+// and the starter region it suggests
+type OnboardingProposal struct {
+	Gateway    string     `json:"gateway,omitempty"`
+	DNSServers []string   `json:"dns_servers,omitempty"`
+	Region     string     `json:"region"`
+	Endpoints  []Endpoint `json:"endpoints"`
+}
+
+// OnboardingSelection is the starter region the user picked during onboarding
+type OnboardingSelection struct {
+	Region    string     `json:"region"`
+	Endpoints []Endpoint `json:"endpoints"`
+	// TestIntervalSeconds keeps the default interval when zero
+	TestIntervalSeconds int `json:"test_interval_seconds,omitempty"`
+}
+
+// LocaleInfo describes a language backend messages are available in
+type LocaleInfo struct {
+	Code string `json:"code"` // e.g. "pt-BR"
+	Name string `json:"name"` // Language name in that language
+}
+
+// UISettings holds frontend preferences. They are kept in the config so they
+// survive reinstalls and travel with backups.
+type UISettings struct {
+	Theme            string   `json:"theme"`                     // "dark", "light" or "system"
+	DefaultTimeRange string   `json:"default_time_range"`        // "1h", "24h", "week" or "month"
+	VisibleColumns   []string `json:"visible_columns,omitempty"` // Detail history columns: time, status, latency
+	ChartSmoothing   float64  `json:"chart_smoothing"`           // Line tension, 0 for straight lines up to 1
+}
+
+// HookEvent is an endpoint state change that can trigger hooks
+type HookEvent string
+
+const (
+	HookOnDown      HookEvent = "down"
+	HookOnDegraded  HookEvent = "degraded" // Down, but the confirmation probe reached the host
+	HookOnRecovered HookEvent = "recovered"
+	// HookOnCertExpiring fires once when an endpoint's certificate comes
+	// within the warning period, again only after it was renewed
+	HookOnCertExpiring HookEvent = "cert_expiring"
+)
+
+// Hook is a local command run when an endpoint changes state. Details about
+// the endpoint and the triggering result are passed in NETMONITOR_* variables.
+type Hook struct {
+	Name           string      `json:"name"`
+	On             []HookEvent `json:"on"`
+	Command        string      `json:"command"`
+	Args           []string    `json:"args,omitempty"`
+	EndpointIDs    []string    `json:"endpoint_ids,omitempty"` // Empty means every endpoint
+	TimeoutSeconds int         `json:"timeout_seconds,omitempty"`
+}
+
+// HookRun records one execution of a hook
+type HookRun struct {
+	Hook       string    `json:"hook"`
+	EndpointID string    `json:"endpoint_id"`
+	Event      HookEvent `json:"event"`
+	Start      int64     `json:"start"` // UnixMilli
+	DurationMs int64     `json:"duration_ms"`
+	ExitCode   int       `json:"exit_code"`
+	Output     string    `json:"output,omitempty"` // Combined stdout and stderr, truncated
+	Error      string    `json:"error,omitempty"`
+}
+
+// Alert kinds, the threshold an alert is about
+const (
+	AlertLatency      = "latency"      // p95 latency over the window above the region's LatencyMs
+	AlertAvailability = "availability" // Availability over the window below the region's AvailabilityPercent
+	AlertFailures     = "failures"     // Consecutive failed tests reaching AlertSettings.ConsecutiveFailures
+	AlertErrorKind    = "error_kind"   // Failures of one ErrorKind over the window reaching an AlertRule's threshold
+)
+
+// Conditions of alert rules
+const (
+	RuleConsecutiveFailures = "consecutive_failures" // Failed tests in a row reach Threshold
+	RuleAvailability        = "availability"         // Availability over the window drops below Threshold %
+	RuleP95Latency          = "p95_latency"          // p95 latency over the window exceeds Threshold ms
+	RuleErrorKind           = "error_kind"           // Failures of ErrorKind over the window reach Threshold
+)
+
+// Alert states
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// AlertSettings tune when thresholds alert and where alerts are sent
+type AlertSettings struct {
+	// WindowMinutes is the period latency and availability are measured
+	// over, 15 by default
+	WindowMinutes int `json:"window_minutes,omitempty"`
+	// ConsecutiveFailures alerts once an endpoint failed this many tests in
+	// a row, 3 by default
+	ConsecutiveFailures int `json:"consecutive_failures,omitempty"`
+	// RepeatMinutes sends alerts still firing again after this long. Zero
+	// sends each alert once, and once more when it resolves.
+	RepeatMinutes int `json:"repeat_minutes,omitempty"`
+	// Notifiers receive alerts. Desktop notifications go out for every
+	// region unless a desktop notifier limits them to some.
+	Notifiers []NotifierSettings `json:"notifiers,omitempty"`
+	// Rules are custom conditions alerting alongside the region thresholds
+	Rules []AlertRule `json:"rules,omitempty"`
+}
+
+// AlertRule is a custom alert condition on the endpoints it applies to. Each
+// endpoint alerts on its own, and every rule separately.
+type AlertRule struct {
+	Name string `json:"name"`
+	// Region limits the rule to the endpoints of a region, and Endpoint to
+	// the endpoints of that name. Both empty apply it to every endpoint.
+	Region   string `json:"region,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	// Condition is one of the rule conditions, compared to Threshold in its
+	// unit: failures, percent, milliseconds or failures
+	Condition string  `json:"condition"`
+	Threshold float64 `json:"threshold"`
+	// ErrorKind is the failure cause counted by error_kind rules
+	ErrorKind ErrorKind `json:"error_kind,omitempty"`
+	// WindowMinutes is the period the condition is measured over, the
+	// settings' WindowMinutes by default
+	WindowMinutes int `json:"window_minutes,omitempty"`
+}
+
+// NotifierSettings configure one destination of alerts
+type NotifierSettings struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // "webhook", "email" or "desktop"
+	// Regions limits the notifier to alerts of these regions, empty for all
+	Regions []string `json:"regions,omitempty"`
+
+	// URL receives webhook alerts as a JSON POST
+	URL string `json:"url,omitempty"`
+	// Template is a Go text/template rendering the JSON body of webhook
+	// alerts, e.g. for PagerDuty. Empty posts the alert as is.
+	Template string `json:"template,omitempty"`
+	// Retries is how many times a failed webhook is retried, with
+	// exponential backoff. Nil retries 3 times.
+	Retries *int `json:"retries,omitempty"`
+
+	// SMTPHost is the host:port of the mail server email alerts are sent
+	// through, authenticated when SMTPUsername is set
+	SMTPHost     string   `json:"smtp_host,omitempty"`
+	SMTPUsername string   `json:"smtp_username,omitempty"`
+	SMTPPassword string   `json:"smtp_password,omitempty"`
+	From         string   `json:"from,omitempty"`
+	To           []string `json:"to,omitempty"`
+}
+
+// Alert is a threshold or rule breach of an endpoint, sent to notifiers when
+// it starts firing and when it resolves
+type Alert struct {
+	Kind         string  `json:"kind"`  // AlertLatency, AlertAvailability, AlertFailures or AlertErrorKind
+	State        string  `json:"state"` // AlertFiring or AlertResolved
+	Region       string  `json:"region"`
+	EndpointID   string  `json:"endpoint_id"`
+	EndpointName string  `json:"endpoint_name"`
+	Value        float64 `json:"value"`     // p95 ms, availability %, failures in a row or failures of the kind
+	Threshold    float64 `json:"threshold"` // In the same unit
+	Since        int64   `json:"since"`     // UnixMilli the breach started
+	At           int64   `json:"at"`        // UnixMilli of this notification
+	// Repeat is set on reminders of an alert still firing
+	Repeat bool `json:"repeat,omitempty"`
+	// Rule names the AlertRule that fired, empty for region thresholds
+	Rule string `json:"rule,omitempty"`
+	// ErrorKind is the kind counted by AlertErrorKind alerts
+	ErrorKind ErrorKind `json:"error_kind,omitempty"`
+}
+
+// Outage is a window of consecutive failed tests for one endpoint
+type Outage struct {
+	EndpointID string `json:"endpoint_id"`
+	Start      int64  `json:"start"` // UnixMilli of the first failed test
+	End        int64  `json:"end"`   // UnixMilli of the recovering test, or the last failure if ongoing
+	Failures   int    `json:"failures"`
+	Ongoing    bool   `json:"ongoing"`
+	// Trace is the trace ID of the first failed test, to find the outage's
+	// start in the logs
+	Trace string `json:"trace,omitempty"`
+}
+
+// DataGap is a window in which no results were stored for an endpoint,
+// typically because the app was closed or the machine was asleep
+type DataGap struct {
+	EndpointID string `json:"endpoint_id"`
+	Start      int64  `json:"start"` // UnixMilli
+	End        int64  `json:"end"`   // UnixMilli
+}
+
+// EndpointAvailability is the time-weighted availability of an endpoint over
+// a range, excluding periods without data
+type EndpointAvailability struct {
+	EndpointID          string  `json:"endpoint_id"`
+	AvailabilityPercent float64 `json:"availability_percent"`
+	MonitoredMs         int64   `json:"monitored_ms"`
+	DowntimeMs          int64   `json:"downtime_ms"`
+	GapMs               int64   `json:"gap_ms"`
+	// Business counts only the business hours of the endpoint's region,
+	// when it has them
+	Business *BusinessAvailability `json:"business,omitempty"`
+}
+
+// BusinessAvailability is the availability of an endpoint within business
+// hours, time outside them excluded like time without data
+type BusinessAvailability struct {
+	AvailabilityPercent float64 `json:"availability_percent"`
+	MonitoredMs         int64   `json:"monitored_ms"`
+	DowntimeMs          int64   `json:"downtime_ms"`
+}
+
+// LatencyTrend is the trend of an endpoint's daily p95 latency, from a
+// linear regression over the analyzed days
+type LatencyTrend struct {
+	EndpointID    string  `json:"endpoint_id"`
+	Days          int     `json:"days"` // Days with successful tests
+	CurrentP95Ms  float64 `json:"current_p95_ms"`
+	SlopeMsPerDay float64 `json:"slope_ms_per_day"`
+	// WeekOverWeekPercent compares the mean daily p95 of the last 7 days with
+	// the 7 before. Zero without two weeks of data.
+	WeekOverWeekPercent float64 `json:"week_over_week_percent"`
+	// Degrading is set when the p95 grows by 10% or more per week
+	Degrading bool `json:"degrading"`
+	// ThresholdCrossingAt is the projected UnixMilli day the p95 reaches the
+	// region's latency threshold, 0 if it isn't heading there within a year
+	ThresholdCrossingAt int64 `json:"threshold_crossing_at,omitempty"`
+}
+
+// SummaryWindows are the windows an EndpointSummary covers, ending now
+var SummaryWindows = []string{"1h", "day", "week", "month"}
+
+// EndpointSummary gathers the statistics of one endpoint over a window in a
+// single call, for the endpoint detail page
+type EndpointSummary struct {
+	EndpointID string `json:"endpoint_id"`
+	Name       string `json:"name"`
+	Window     string `json:"window"` // One of SummaryWindows
+	// State is the endpoint's current status, nil before its first test
+	State *EndpointState `json:"state,omitempty"`
+	// Tests and Failures count the window's results, without cancelled tests
+	Tests               int     `json:"tests"`
+	Failures            int     `json:"failures"`
+	AvailabilityPercent float64 `json:"availability_percent"` // Time-weighted, not counting data gaps
+	MeanMs              float64 `json:"mean_ms"`
+	P95Ms               float64 `json:"p95_ms"`
+	// CurrentFailureStreak is the number of consecutive failures up to the
+	// latest result, LongestFailureStreak the longest run in the window
+	CurrentFailureStreak int `json:"current_failure_streak"`
+	LongestFailureStreak int `json:"longest_failure_streak"`
+	// LastOutage is the most recent outage in the window, if any
+	LastOutage *Outage `json:"last_outage,omitempty"`
+	// Trend is the p95 latency trend of the last 28 days, nil with fewer
+	// than 7 days of data
+	Trend *LatencyTrend `json:"trend,omitempty"`
+}
+
+// LatencyDistribution bins the latencies of an endpoint's successful tests
+// over a window, for histogram charts
+type LatencyDistribution struct {
+	EndpointID string  `json:"endpoint_id"`
+	Window     string  `json:"window"`    // One of SummaryWindows
+	BucketMs   float64 `json:"bucket_ms"` // Width of every bin
+	Tests      int     `json:"tests"`     // Successful tests binned
+	// Bins run from zero to the bin of the slowest test. Empty bins are
+	// included so the bins chart as they are.
+	Bins []LatencyBin `json:"bins"`
+}
+
+// LatencyBin counts the tests with a latency in [FromMs, ToMs)
+type LatencyBin struct {
+	FromMs float64 `json:"from_ms"`
+	ToMs   float64 `json:"to_ms"`
+	Count  int     `json:"count"`
+}
+
+// Lint issue severities, most severe first
+const (
+	LintError   = "error"   // The setup is broken, e.g. tests can't run
+	LintWarning = "warning" // The setup works but results may mislead
+	LintInfo    = "info"    // Worth a look, often intended
+)
+
+// LintIssue is a risky part of the configuration found by config linting
+type LintIssue struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"` // Stable identifier, e.g. timeout_exceeds_interval
+	Region   string `json:"region,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"` // Endpoint name
+	// Detail completes the message, e.g. the validation error
+	Detail  string `json:"detail,omitempty"`
+	Message string `json:"message"`
+}
+
+// MonitoringState reports whether tests are running and why they are paused
+type MonitoringState struct {
+	Running      bool     `json:"running"`
+	PauseReasons []string `json:"pause_reasons"`
+}
+
+// CycleReport summarizes one scheduled run of the tests of the endpoints due
+type CycleReport struct {
+	// ID is the run ID prefixing the trace IDs of the cycle's tests
+	ID         string `json:"id,omitempty"`
+	Start      int64  `json:"start"` // UnixMilli
+	DurationMs int64  `json:"duration_ms"`
+	Tests      int    `json:"tests"`
+	Failures   int    `json:"failures"`
+	Cancelled  int    `json:"cancelled"` // Tests aborted because monitoring stopped mid-cycle
+	Overrun    bool   `json:"overrun"`   // A test of the cycle took longer than its endpoint's test interval
+	Skipped    int    `json:"skipped"`   // Most tests an endpoint of the cycle missed since its previous one
+	// OverBudget counts tests skipped because they would exceed the data
+	// budget. They aren't counted as cancelled.
+	OverBudget int `json:"over_budget,omitempty"`
+}
+
+// EndpointState is the last known state of an endpoint as seen by the monitor
+type EndpointState struct {
+	EndpointID          string     `json:"endpoint_id"`
+	Up                  bool       `json:"up"`
+	LastResult          TestResult `json:"last_result"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	StateChangedAt      int64      `json:"state_changed_at"`   // UnixMilli of the result that changed Up
+	Degraded            bool       `json:"degraded,omitempty"` // Down, but the confirmation probe reached the host
+}
+
+// BatchState describes where a batch of manual tests is in its lifecycle
+type BatchState string
+
+const (
+	BatchRunning   BatchState = "running"
+	BatchCompleted BatchState = "completed"
+	BatchCancelled BatchState = "cancelled"
+)
+
+// BatchStatus reports the progress of a batch of manual tests
+type BatchStatus struct {
+	ID         string       `json:"id"`
+	State      BatchState   `json:"state"`
+	Total      int          `json:"total"`
+	Completed  int          `json:"completed"`
+	Failures   int          `json:"failures"`
+	Current    string       `json:"current,omitempty"` // Name of the endpoint most recently started
+	Results    []TestResult `json:"results"`
+	StartedAt  int64        `json:"started_at"`
+	FinishedAt int64        `json:"finished_at,omitempty"`
+}
+
+// ResolutionChange records a change in the addresses an endpoint host resolves to
+type ResolutionChange struct {
+	EndpointID string   `json:"endpoint_id"`
+	Host       string   `json:"host"`
+	Ts         int64    `json:"ts"` // UnixMilli of the test that noticed the change
+	OldIPs     []string `json:"old_ips"`
+	NewIPs     []string `json:"new_ips"`
+	PTR        string   `json:"ptr,omitempty"` // Reverse DNS name of the first new address
+}
+
+// Annotation kinds
+const (
+	AnnotationBattery = "battery" // Switched to battery power
+	AnnotationAC      = "ac"      // Switched to AC power
+)
+
+// Annotation marks an event on the timeline that explains changes in the
+// results around it, such as monitoring slowing down on battery
+type Annotation struct {
+	Ts   int64  `json:"ts"` // UnixMilli
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+// RouteChange records a change in the traced path to an endpoint. The
+// latencies of the tests before and after the change help tell whether the
+// new route is slower.
+type RouteChange struct {
+	EndpointID string   `json:"endpoint_id"`
+	Ts         int64    `json:"ts"` // UnixMilli of the test that noticed the change
+	OldHops    []string `json:"old_hops"`
+	NewHops    []string `json:"new_hops"` // "*" marks hops that didn't answer
+	OldMs      int64    `json:"old_ms"`
+	NewMs      int64    `json:"new_ms"`
 }
@@ -0,0 +1,196 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// batchConcurrency bounds how many endpoints a batch tests at once so
+	// progress is meaningful and cancellation can take effect
+	batchConcurrency = 4
+
+	// maxFinishedBatches is how many finished batches are kept for status queries
+	maxFinishedBatches = 20
+)
+
+type batch struct {
+	status    models.BatchStatus
+	cancelled bool
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// StartBatch tests the given endpoints in the background and returns a handle
+// whose progress can be polled with BatchStatus or followed via OnBatchProgress.
+// After Shutdown the batch is cancelled before any test runs.
+func (m *Monitor) StartBatch(endpoints []models.Endpoint) models.BatchStatus {
+	b := &batch{
+		status: models.BatchStatus{
+			ID:        uuid.New().String(),
+			State:     models.BatchRunning,
+			Total:     len(endpoints),
+			Results:   []models.TestResult{},
+			StartedAt: time.Now().UnixMilli(),
+		},
+	}
+	b.ctx, b.cancel = context.WithCancel(m.Ctx)
+
+	m.mu.Lock()
+	if m.closed {
+		b.cancelled = true
+		b.cancel()
+	}
+	m.inflight.Add(1)
+	m.mu.Unlock()
+
+	m.batchMu.Lock()
+	if m.batches == nil {
+		m.batches = make(map[string]*batch)
+	}
+	m.batches[b.status.ID] = b
+	m.pruneBatchesLocked()
+	status := b.snapshot()
+	m.batchMu.Unlock()
+
+	log.Ctx(m.Ctx).Info().Str("batch", status.ID).Int("total", status.Total).Msg("Batch test started")
+	go func() {
+		defer m.inflight.Done()
+		m.runBatch(b, endpoints)
+	}()
+	return status
+}
+
+// BatchStatus returns the progress of a batch started with StartBatch
+func (m *Monitor) BatchStatus(id string) (models.BatchStatus, error) {
+	m.batchMu.Lock()
+	defer m.batchMu.Unlock()
+
+	b, ok := m.batches[id]
+	if !ok {
+		return models.BatchStatus{}, fmt.Errorf("batch not found: %s", id)
+	}
+	return b.snapshot(), nil
+}
+
+// CancelBatch stops a running batch. Tests not yet started are skipped and
+// tests in flight are aborted and reported as cancelled.
+func (m *Monitor) CancelBatch(id string) error {
+	m.batchMu.Lock()
+	defer m.batchMu.Unlock()
+
+	b, ok := m.batches[id]
+	if !ok {
+		return fmt.Errorf("batch not found: %s", id)
+	}
+	if b.status.State != models.BatchRunning {
+		return fmt.Errorf("batch %s is already %s", id, b.status.State)
+	}
+	b.cancelled = true
+	b.cancel()
+	return nil
+}
+
+func (m *Monitor) runBatch(b *batch, endpoints []models.Endpoint) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, batchConcurrency)
+
+	for i, ep := range endpoints {
+		sem <- struct{}{}
+
+		m.batchMu.Lock()
+		cancelled := b.cancelled
+		if !cancelled {
+			b.status.Current = ep.Name
+		}
+		m.batchMu.Unlock()
+		if cancelled {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		// Traces start like the batch ID, which the batch's log lines carry
+		trace := fmt.Sprintf("%s.%d", b.status.ID[:8], i+1)
+		go func(ep models.Endpoint) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result, _ := m.TestContext(network.WithTrace(b.ctx, trace), ep)
+
+			m.batchMu.Lock()
+			b.status.Completed++
+			if result.St != ResultSuccess && result.St != ResultCancelled {
+				b.status.Failures++
+			}
+			b.status.Results = append(b.status.Results, result)
+			status := b.snapshot()
+			m.batchMu.Unlock()
+
+			m.notifyBatch(status)
+		}(ep)
+	}
+	wg.Wait()
+	b.cancel()
+
+	m.batchMu.Lock()
+	b.status.Current = ""
+	b.status.FinishedAt = time.Now().UnixMilli()
+	if b.cancelled {
+		b.status.State = models.BatchCancelled
+	} else {
+		b.status.State = models.BatchCompleted
+	}
+	status := b.snapshot()
+	m.batchMu.Unlock()
+
+	log.Ctx(m.Ctx).Info().
+		Str("batch", status.ID).
+		Int("completed", status.Completed).
+		Int("failures", status.Failures).
+		Str("state", string(status.State)).
+		Msg("Batch test finished")
+	m.notifyBatch(status)
+}
+
+func (m *Monitor) notifyBatch(status models.BatchStatus) {
+	if m.OnBatchProgress != nil {
+		m.OnBatchProgress(status)
+	}
+}
+
+// pruneBatchesLocked drops the oldest finished batches beyond maxFinishedBatches.
+// Must be called with m.batchMu held.
+func (m *Monitor) pruneBatchesLocked() {
+	var finished []*batch
+	for _, b := range m.batches {
+		if b.status.State != models.BatchRunning {
+			finished = append(finished, b)
+		}
+	}
+	for len(finished) > maxFinishedBatches {
+		oldest := 0
+		for i, b := range finished {
+			if b.status.StartedAt < finished[oldest].status.StartedAt {
+				oldest = i
+			}
+		}
+		delete(m.batches, finished[oldest].status.ID)
+		finished = append(finished[:oldest], finished[oldest+1:]...)
+	}
+}
+
+// snapshot copies the status so callers don't share the results slice.
+// Must be called with m.batchMu held.
+func (b *batch) snapshot() models.BatchStatus {
+	status := b.status
+	status.Results = append([]models.TestResult(nil), b.status.Results...)
+	return status
+}
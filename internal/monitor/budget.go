@@ -0,0 +1,179 @@
+package monitor
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+// TagBudget marks results of scheduled tests the data budget changed, with
+// BudgetDowngraded or BudgetSkipped
+const TagBudget = "budget"
+
+// Data budget actions recorded in TagBudget
+const (
+	BudgetDowngraded = "downgraded" // Run in a lighter form
+	BudgetSkipped    = "skipped"    // Not run, the result is cancelled
+)
+
+// lightTestBytes is the traffic below which tests always run, so an
+// exhausted budget still leaves basic reachability monitoring
+const lightTestBytes = 16 << 10
+
+// trafficMeter adds up the traffic of every test the monitor runs, per day
+// and per calendar month, and remembers what a full test of each endpoint
+// costs so the budget can tell heavy tests apart before running them
+type trafficMeter struct {
+	mu         sync.Mutex
+	day, month string // Local date the counters are for
+	dayBytes   int64
+	monthBytes int64
+	costs      map[string]int64 // Endpoint ID -> bytes of its last full test
+}
+
+// add counts bytes used at t, starting new counters when the day or month
+// changed
+func (t *trafficMeter) add(at time.Time, bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollLocked(at)
+	t.dayBytes += bytes
+	t.monthBytes += bytes
+}
+
+func (t *trafficMeter) rollLocked(at time.Time) {
+	day, month := at.Format("2006-01-02"), at.Format("2006-01")
+	if month != t.month {
+		t.month, t.monthBytes = month, 0
+	}
+	if day != t.day {
+		t.day, t.dayBytes = day, 0
+	}
+}
+
+// used returns the traffic of the day and month of now
+func (t *trafficMeter) used(now time.Time) (day, month int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollLocked(now)
+	return t.dayBytes, t.monthBytes
+}
+
+func (t *trafficMeter) cost(id string) (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.costs[id]
+	return c, ok
+}
+
+func (t *trafficMeter) setCost(id string, bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.costs == nil {
+		t.costs = make(map[string]int64)
+	}
+	t.costs[id] = bytes
+}
+
+// SeedTraffic adds traffic of tests stored before the monitor was created,
+// so the data budget covers the whole day and month after a restart
+func (m *Monitor) SeedTraffic(today, month int64) {
+	m.traffic.mu.Lock()
+	defer m.traffic.mu.Unlock()
+	m.traffic.rollLocked(time.Now())
+	m.traffic.dayBytes += today
+	m.traffic.monthBytes += month
+}
+
+// DataUsage returns the traffic of tests run today and this month
+func (m *Monitor) DataUsage() models.DataUsage {
+	day, month := m.traffic.used(time.Now())
+	return models.DataUsage{TodayBytes: day, MonthBytes: month, Budget: m.Config.Settings.DataBudget}
+}
+
+// budgetAction decides whether a scheduled test of ep fits the data budget.
+// It returns "" when the test runs as configured, or BudgetDowngraded or
+// BudgetSkipped. Endpoints not tested yet always run once to learn their
+// cost.
+func (m *Monitor) budgetAction(ep models.Endpoint) string {
+	budget := m.Config.Settings.DataBudget
+	if budget == nil || (budget.DailyMB <= 0 && budget.MonthlyMB <= 0) {
+		return ""
+	}
+	cost, ok := m.traffic.cost(network.EndpointID(ep.Address, ep.Type))
+	if !ok || cost <= lightTestBytes {
+		return ""
+	}
+	day, month := m.traffic.used(time.Now())
+	if (budget.DailyMB <= 0 || day+cost <= int64(budget.DailyMB)<<20) &&
+		(budget.MonthlyMB <= 0 || month+cost <= int64(budget.MonthlyMB)<<20) {
+		return ""
+	}
+	if _, ok := downgrade(ep); ok {
+		return BudgetDowngraded
+	}
+	return BudgetSkipped
+}
+
+// downgrade returns a lighter form of the endpoint's test, turning off the
+// options that add traffic on top of reaching the endpoint
+func downgrade(ep models.Endpoint) (models.Endpoint, bool) {
+	changed := false
+	if ep.AllAddresses {
+		ep.AllAddresses = false
+		changed = true
+	}
+	if ep.HTTP != nil && ep.HTTP.KeepAlive {
+		// Keep-alive tests download the body to reuse the connection
+		opts := *ep.HTTP
+		opts.KeepAlive = false
+		ep.HTTP = &opts
+		changed = true
+	}
+	if ep.TCP != nil && ep.TCP.Reuse {
+		opts := *ep.TCP
+		opts.Reuse = false
+		ep.TCP = &opts
+		changed = true
+	}
+	return ep, changed
+}
+
+// runScheduled runs a scheduled test within the data budget and, on
+// battery, the battery settings
+func (m *Monitor) runScheduled(ctx context.Context, ep models.Endpoint) models.TestResult {
+	tag, action := TagBudget, m.budgetAction(ep)
+	if action == "" {
+		tag, action = TagPower, m.powerAction(ep)
+	}
+	switch action {
+	case BudgetSkipped:
+		ts, seq, _ := m.Runner.Clock.Stamp()
+		return models.TestResult{
+			Ts:    ts.UnixMilli(),
+			Seq:   seq,
+			Id:    network.EndpointID(ep.Address, ep.Type),
+			St:    ResultCancelled,
+			Tags:  map[string]string{tag: BudgetSkipped},
+			Trace: network.TraceFrom(ctx),
+		}
+	case BudgetDowngraded:
+		ep, _ = downgrade(ep)
+	}
+
+	result, _ := m.TestContext(ctx, ep)
+	if action == BudgetDowngraded {
+		result.Tags = maps.Clone(result.Tags)
+		if result.Tags == nil {
+			result.Tags = make(map[string]string, 1)
+		}
+		result.Tags[tag] = BudgetDowngraded
+	} else if result.St != ResultCancelled {
+		m.traffic.setCost(result.Id, result.Tx+result.Rx)
+	}
+	return result
+}
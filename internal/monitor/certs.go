@@ -0,0 +1,45 @@
+package monitor
+
+import (
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+// DefaultCertWarningDays is the warning period used when the settings don't
+// set CertWarningDays
+const DefaultCertWarningDays = 14
+
+// certWarningDays returns how many days before expiry certificates are
+// reported
+func (m *Monitor) certWarningDays() int {
+	if days := m.Config.Settings.CertWarningDays; days > 0 {
+		return days
+	}
+	return DefaultCertWarningDays
+}
+
+// updateCert reports whether the result finds the endpoint's certificate
+// expiring within the warning period for the first time. An endpoint is
+// reported again only after a result showed a renewed certificate, so a
+// certificate is reported once however many tests see it expiring.
+func (m *Monitor) updateCert(result models.TestResult) (models.EndpointState, bool) {
+	days, ok := network.CertExpiryDays(result)
+	if !ok {
+		return models.EndpointState{}, false
+	}
+	expiring := days <= m.certWarningDays()
+
+	m.statesMu.Lock()
+	defer m.statesMu.Unlock()
+	if m.certsExpiring[result.Id] == expiring {
+		return models.EndpointState{}, false
+	}
+	m.certsExpiring[result.Id] = expiring
+	if !expiring {
+		return models.EndpointState{}, false
+	}
+	state := m.states[result.Id]
+	state.EndpointID = result.Id
+	state.LastResult = result
+	return state, true
+}
@@ -3,18 +3,21 @@ package monitor
 import (
 	"context"
 	"fmt"
-	"net"
-	"net/http"
-	"runtime"
+	"maps"
+	"slices"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/marcoshack/netmonitor/internal/models"
-	probing "github.com/prometheus-community/pro-bing"
+	"github.com/marcoshack/netmonitor/internal/network"
 	"github.com/rs/zerolog/log"
 )
 
+// PauseReasonUser is the pause reason used when monitoring is paused from the UI
+const PauseReasonUser = "user"
+
 type Monitor struct {
 	Ctx         context.Context
 	Config      *models.Configuration
@@ -22,34 +25,155 @@ type Monitor struct {
 	ResultsChan chan models.TestResult
 	IsRunning   bool
 	mu          sync.Mutex
+
+	// active is true between Start and Stop. The test loop only runs while
+	// the monitor is active and there are no pause reasons.
+	active       bool
+	pauseReasons map[string]bool
+
+	// closed is set by Shutdown, after which the monitor can't be restarted.
+	// inflight tracks the test loop and running batches so Shutdown can
+	// wait for their last results.
+	closed   bool
+	inflight sync.WaitGroup
+
+	// Runner executes every test the monitor runs, scheduled or not
+	Runner *network.Runner
+
+	// Workers is the number of scheduled tests run at the same time,
+	// DefaultWorkers if zero. It must be set before the first cycle.
+	Workers     int
+	queue       *testQueue
+	workersOnce sync.Once
+	// workersCtx is cancelled by Shutdown to stop the workers
+	workersCtx  context.Context
+	stopWorkers context.CancelFunc
+	workersWg   sync.WaitGroup
+
+	// OnCycle, if set, receives a summary after every scheduled test cycle
+	OnCycle func(models.CycleReport)
+
+	// OnStateChange, if set, is called when a scheduled test finds an endpoint
+	// down, or up again after being down
+	OnStateChange func(models.Endpoint, models.EndpointState)
+
+	// OnCertExpiring, if set, is called when a scheduled test finds the
+	// certificate of an endpoint expiring within the warning period
+	OnCertExpiring func(models.Endpoint, models.EndpointState)
+
+	statesMu sync.Mutex
+	states   map[string]models.EndpointState
+	// certsExpiring holds the endpoints already reported to OnCertExpiring
+	certsExpiring map[string]bool
+
+	// traffic counts the bytes of every test, for the data budget
+	traffic trafficMeter
+
+	// onBattery applies the battery settings, see SetOnBattery.
+	// intervalChanged wakes the test loop to apply the new interval.
+	onBattery       atomic.Bool
+	intervalChanged chan struct{}
+
+	// OnBatchProgress, if set, receives the batch status every time a test of
+	// a batch started with StartBatch completes, and once when the batch ends
+	OnBatchProgress func(models.BatchStatus)
+
+	batchMu sync.Mutex
+	batches map[string]*batch
 }
 
 func NewMonitor(ctx context.Context, cfg *models.Configuration) *Monitor {
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
 	return &Monitor{
-		Ctx:         ctx,
-		Config:      cfg,
-		StopChan:    make(chan struct{}),
-		ResultsChan: make(chan models.TestResult, 100),
+		Ctx:          ctx,
+		Config:       cfg,
+		StopChan:     make(chan struct{}),
+		ResultsChan:  make(chan models.TestResult, 100),
+		Runner:       network.NewRunner(ctx),
+		queue:        newTestQueue(),
+		workersCtx:   workersCtx,
+		stopWorkers:  stopWorkers,
+		pauseReasons: make(map[string]bool),
+		states:       make(map[string]models.EndpointState),
+
+		certsExpiring:   make(map[string]bool),
+		intervalChanged: make(chan struct{}, 1),
 	}
 }
 
 func (m *Monitor) Start() {
 	m.mu.Lock()
-	if m.IsRunning {
-		m.mu.Unlock()
+	defer m.mu.Unlock()
+	m.active = true
+	m.startLocked()
+}
+
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active = false
+	m.stopLocked()
+}
+
+// Pause stops testing for the given reason. Testing resumes once every pause
+// reason has been cleared with Resume.
+func (m *Monitor) Pause(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pauseReasons[reason] = true
+	log.Ctx(m.Ctx).Info().Str("reason", reason).Msg("Monitor paused")
+	m.stopLocked()
+}
+
+// Resume clears a pause reason and restarts testing if no other reason remains
+func (m *Monitor) Resume(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.pauseReasons[reason] {
+		return
+	}
+	delete(m.pauseReasons, reason)
+	log.Ctx(m.Ctx).Info().Str("reason", reason).Msg("Monitor pause reason cleared")
+	if m.active {
+		m.startLocked()
+	}
+}
+
+// PauseReasons returns the sorted list of reasons the monitor is paused for
+func (m *Monitor) PauseReasons() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reasons := make([]string, 0, len(m.pauseReasons))
+	for r := range m.pauseReasons {
+		reasons = append(reasons, r)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// Running reports whether the test loop is currently running
+func (m *Monitor) Running() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.IsRunning
+}
+
+func (m *Monitor) startLocked() {
+	if m.IsRunning || m.closed || len(m.pauseReasons) > 0 {
 		return
 	}
 	m.IsRunning = true
 	m.StopChan = make(chan struct{}) // Recreate in case it was closed
-	m.mu.Unlock()
 
 	log.Ctx(m.Ctx).Info().Msg("Monitor started")
-	go m.runLoop()
+	m.inflight.Add(1)
+	go func(stopChan chan struct{}) {
+		defer m.inflight.Done()
+		m.runLoop(stopChan)
+	}(m.StopChan)
 }
 
-func (m *Monitor) Stop() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+func (m *Monitor) stopLocked() {
 	if !m.IsRunning {
 		return
 	}
@@ -58,171 +182,233 @@ func (m *Monitor) Stop() {
 	log.Ctx(m.Ctx).Info().Msg("Monitor stopped")
 }
 
-func (m *Monitor) runLoop() {
-	ticker := time.NewTicker(time.Duration(m.Config.Settings.TestIntervalSeconds) * time.Second)
-	defer ticker.Stop()
+// Shutdown stops the monitor for good. Tests in flight, scheduled or part of
+// a batch, are aborted right away and reported as cancelled. Shutdown waits
+// for them to deliver their results until ctx is done, then stops the
+// workers. Tests queued from then on are reported as cancelled.
+func (m *Monitor) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.active = false
+	m.closed = true
+	m.stopLocked()
+	m.mu.Unlock()
+
+	m.batchMu.Lock()
+	for _, b := range m.batches {
+		if b.status.State == models.BatchRunning {
+			b.cancelled = true
+			b.cancel()
+		}
+	}
+	m.batchMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.inflight.Wait()
+		close(done)
+	}()
+	defer func() {
+		for _, j := range m.queue.close() {
+			go m.cancelJob(j)
+		}
+		m.stopWorkers()
+	}()
+	select {
+	case <-done:
+		log.Ctx(m.Ctx).Info().Msg("Monitor shut down")
+		return nil
+	case <-ctx.Done():
+		log.Ctx(m.Ctx).Warn().Msg("Monitor shutdown deadline exceeded with tests still in flight")
+		return ctx.Err()
+	}
+}
 
-	// Run immediately on start
-	m.RunAllTests()
+// runLoop tests every endpoint at its interval until stopChan is closed
+func (m *Monitor) runLoop(stopChan chan struct{}) {
+	// Tests still in flight when the monitor stops are aborted and recorded
+	// as cancelled instead of waiting out their timeouts
+	ctx, cancel := context.WithCancel(m.Ctx)
+	defer cancel()
 
+	s := newScheduler(ctx, m)
+	s.start()
 	for {
 		select {
-		case <-m.StopChan:
+		case <-stopChan:
+			cancel()
+			s.stop()
 			return
-		case <-ticker.C:
-			m.RunAllTests()
+		case <-m.intervalChanged:
+			// Catch up on endpoints whose interval got shorter, e.g. back on AC
+			s.reschedule()
 		}
 	}
 }
 
-func (m *Monitor) RunAllTests() {
-	var wg sync.WaitGroup
-
-	for regionName, region := range m.Config.Regions {
-		for _, endpoint := range region.Endpoints {
-			wg.Add(1)
-			go func(rName string, ep models.Endpoint) {
-				defer wg.Done()
-				result := m.TestEndpoint(ep)
-				// ID is already generated in TestEndpoint based on address/protocol
-				// If we needed region in hash, we'd pass it. User said Address + Protocol.
-				m.ResultsChan <- result
-			}(regionName, endpoint)
+// RunAllTests queues a test of every configured endpoint, waits for the
+// worker pool to run them and returns a summary of the cycle
+func (m *Monitor) RunAllTests() models.CycleReport {
+	type dueTest struct {
+		regionName string
+		region     models.Region
+		ep         models.Endpoint
+	}
+	var tests []dueTest
+	for _, regionName := range slices.Sorted(maps.Keys(m.Config.Regions)) {
+		region := m.Config.Regions[regionName]
+		for _, ep := range region.Endpoints {
+			tests = append(tests, dueTest{regionName, region, ep})
 		}
 	}
+	if len(tests) == 0 {
+		return models.CycleReport{}
+	}
+	// Tests are numbered in the order they run, higher priorities first
+	slices.SortStableFunc(tests, func(a, b dueTest) int { return b.ep.Priority - a.ep.Priority })
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	start := time.Now()
+	report := models.CycleReport{ID: network.NewRunID(), Start: start.UnixMilli()}
+	log.Ctx(m.Ctx).Debug().Str("run", report.ID).Int("tests", len(tests)).Msg("Test cycle started")
+
+	for n, t := range tests {
+		ep := t.ep
+		wg.Add(1)
+		traceCtx := network.WithTrace(m.Ctx, fmt.Sprintf("%s.%d", report.ID, n+1))
+		m.enqueue(traceCtx, start, t.region, t.regionName, ep, func(result models.TestResult) {
+			defer wg.Done()
+			m.deliver(ep, result)
+			mu.Lock()
+			countResult(&report, result)
+			mu.Unlock()
+		})
+	}
 
 	wg.Wait()
+	report.DurationMs = time.Since(start).Milliseconds()
+	return report
 }
 
-const (
-	ResultSuccess = 0
-	ResultTimeout = 1
-	ResultError   = 2
-)
-
-func (m *Monitor) TestEndpoint(ep models.Endpoint) models.TestResult {
-	var err error
-	var status int
-	var durationMs int64
-
-	timeout := time.Duration(ep.Timeout) * time.Millisecond
-	var d time.Duration
-
-	switch ep.Type {
-	case models.TypeHTTP:
-		d, err = checkHTTP(ep.Address, timeout)
-	case models.TypeTCP:
-		d, err = checkTCP(ep.Address, timeout)
-	case models.TypeUDP:
-		d, err = checkUDP(ep.Address, timeout)
-	case models.TypeICMP:
-		d, err = checkICMP(ep.Address, timeout)
-	default:
-		err = fmt.Errorf("unknown endpoint type: %s", ep.Type)
+// deliver records the result of a scheduled test of ep, reports its state
+// changes and sends it to ResultsChan
+func (m *Monitor) deliver(ep models.Endpoint, result models.TestResult) {
+	// ID is already generated in TestEndpoint based on address/protocol
+	// If we needed region in hash, we'd pass it. User said Address + Protocol.
+	if state, changed := m.updateState(result); changed && m.OnStateChange != nil {
+		m.OnStateChange(ep, state)
 	}
-
-	durationMs = d.Milliseconds()
-	if err != nil {
-		if d >= timeout {
-			status = ResultTimeout
-		} else {
-			status = ResultError
-		}
-	} else {
-		status = ResultSuccess
+	if state, expiring := m.updateCert(result); expiring && m.OnCertExpiring != nil {
+		m.OnCertExpiring(ep, state)
 	}
+	m.ResultsChan <- result
+}
 
-	// Generate ID: last 7 chars of UUID SHA1(NameSpaceURL, Address + Protocol)
-	// Note: Providing Protocol (Type) and Address ensures uniqueness for same address different protocols.
-	idData := ep.Address + string(ep.Type)
-	shortId := uuid.NewSHA1(uuid.NameSpaceURL, []byte(idData)).String()[:7]
-
-	log.Ctx(m.Ctx).Debug().
-		Str("id", shortId).
-		Str("address", ep.Address).
-		Str("type", string(ep.Type)).
-		Int64("latency_ms", durationMs).
-		Int("status", status).
-		Msg("Endpoint tested")
-
-	return models.TestResult{
-		Ts: time.Now().UnixMilli(),
-		Id: shortId,
-		Ms: durationMs,
-		St: status,
+// countResult adds a test result to the totals of its cycle
+func countResult(report *models.CycleReport, result models.TestResult) {
+	report.Tests++
+	switch {
+	case result.St == ResultSuccess:
+	case result.Tags[TagBudget] == BudgetSkipped:
+		report.OverBudget++
+	case result.St == ResultCancelled:
+		report.Cancelled++
+	default:
+		report.Failures++
 	}
 }
 
-func errStr(err error) string {
-	if err == nil {
-		return ""
+// updateState records the result as the endpoint's last known state and
+// reports whether the endpoint went down, or recovered after being down.
+// Cancelled results say nothing about the endpoint and are ignored.
+func (m *Monitor) updateState(result models.TestResult) (models.EndpointState, bool) {
+	if result.St == ResultCancelled {
+		return models.EndpointState{}, false
 	}
-	return err.Error()
+	m.statesMu.Lock()
+	defer m.statesMu.Unlock()
+	return m.updateStateLocked(result)
 }
 
-func checkHTTP(url string, timeout time.Duration) (time.Duration, error) {
-	start := time.Now()
-	client := http.Client{
-		Timeout: timeout,
+func (m *Monitor) updateStateLocked(result models.TestResult) (models.EndpointState, bool) {
+	up := result.St == ResultSuccess
+	degraded := !up && result.Tags[network.TagConfirm] == network.ConfirmUp
+	state, seen := m.states[result.Id]
+	changed := (seen && state.Up != up) || (!seen && !up) || (seen && !up && state.Degraded != degraded)
+	if !seen || state.Up != up {
+		state.StateChangedAt = result.Ts
 	}
-	resp, err := client.Get(url)
-	if err != nil {
-		return time.Since(start), err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 400 {
-		return time.Since(start), fmt.Errorf("http status %d", resp.StatusCode)
+	if up {
+		state.ConsecutiveFailures = 0
+	} else {
+		state.ConsecutiveFailures++
 	}
-	return time.Since(start), nil
+	state.EndpointID = result.Id
+	state.Up = up
+	state.Degraded = degraded
+	state.LastResult = result
+	m.states[result.Id] = state
+	return state, changed
 }
 
-func checkTCP(address string, timeout time.Duration) (time.Duration, error) {
-	start := time.Now()
-	conn, err := net.DialTimeout("tcp", address, timeout)
-	if err != nil {
-		return time.Since(start), err
+// SeedStates restores the last known state of endpoints not tested since
+// the monitor was created from stored results, without reporting changes.
+// Endpoints already tested keep their state.
+func (m *Monitor) SeedStates(results []models.TestResult) {
+	sorted := slices.Clone(results)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Ts < sorted[j].Ts })
+
+	m.statesMu.Lock()
+	defer m.statesMu.Unlock()
+	tested := make(map[string]bool, len(m.states))
+	for id := range m.states {
+		tested[id] = true
 	}
-	conn.Close()
-	return time.Since(start), nil
-}
-
-func checkUDP(address string, timeout time.Duration) (time.Duration, error) {
-	start := time.Now()
-	conn, err := net.DialTimeout("udp", address, timeout)
-	if err != nil {
-		return time.Since(start), err
+	for _, r := range sorted {
+		if r.St != ResultCancelled && !tested[r.Id] {
+			m.updateStateLocked(r)
+		}
+		// Certificates already reported before the restart aren't again
+		if days, ok := network.CertExpiryDays(r); ok && !tested[r.Id] {
+			m.certsExpiring[r.Id] = days <= m.certWarningDays()
+		}
 	}
-	defer conn.Close()
-
-	// Attempt to write a byte to verify socket
-	_, err = conn.Write([]byte{0})
-	return time.Since(start), err
 }
 
-func checkICMP(address string, timeout time.Duration) (time.Duration, error) {
-	pinger, err := probing.NewPinger(address)
-	if err != nil {
-		return 0, err
-	}
-
-	pinger.Count = 1
-	pinger.Timeout = timeout
+// CurrentStates returns the last known state of every tested endpoint, keyed by endpoint ID
+func (m *Monitor) CurrentStates() map[string]models.EndpointState {
+	m.statesMu.Lock()
+	defer m.statesMu.Unlock()
 
-	// On Windows, this triggers the use of the IcmpSendEcho API which works for unprivileged users.
-	// On Linux, it attempts raw sockets (requires root) unless configured otherwise.
-	if runtime.GOOS == "windows" {
-		pinger.SetPrivileged(true)
+	states := make(map[string]models.EndpointState, len(m.states))
+	for id, state := range m.states {
+		states[id] = state
 	}
+	return states
+}
 
-	err = pinger.Run()
-	if err != nil {
-		return 0, err
-	}
+const (
+	ResultSuccess   = models.TestStatusSuccess
+	ResultTimeout   = models.TestStatusTimeout
+	ResultError     = models.TestStatusError
+	ResultCancelled = models.TestStatusCancelled
+)
 
-	stats := pinger.Statistics()
-	if stats.PacketsRecv == 0 {
-		return 0, fmt.Errorf("packet loss")
-	}
+func (m *Monitor) TestEndpoint(ep models.Endpoint) models.TestResult {
+	result, _ := m.Test(ep)
+	return result
+}
+
+// Test runs a single test against the endpoint and also returns the error
+// that made it fail, if any
+func (m *Monitor) Test(ep models.Endpoint) (models.TestResult, error) {
+	return m.TestContext(m.Ctx, ep)
+}
 
-	return stats.AvgRtt, nil
+// TestContext is like Test but aborts the test when ctx is done, in which case
+// the result is reported as ResultCancelled rather than as a timeout
+func (m *Monitor) TestContext(ctx context.Context, ep models.Endpoint) (models.TestResult, error) {
+	result, err := m.Runner.Run(ctx, ep)
+	m.traffic.add(time.Now(), result.Tx+result.Rx)
+	return result, err
 }
@@ -1,16 +1,21 @@
 package monitor
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"context"
 
 	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
 )
 
 func TestMonitorHTTP(t *testing.T) {
@@ -48,6 +53,12 @@ func TestMonitorHTTP(t *testing.T) {
 	if res.St != ResultError {
 		t.Errorf("Expected failure for bad port, got %d", res.St)
 	}
+
+	// Test returns the cause of the failure
+	_, err := mon.Test(ep)
+	if err == nil {
+		t.Errorf("Expected error for bad port")
+	}
 }
 
 func TestMonitorTCP(t *testing.T) {
@@ -73,26 +84,624 @@ func TestMonitorTCP(t *testing.T) {
 	}
 }
 
-func TestCheckICMP_Integration(t *testing.T) {
-	// Pinging localhost should generally work, but might require privileges or specific setup on Windows.
-	// Since we are switching to pro-bing with unprivileged support via API, this test is crucial.
+func TestMonitorPauseResume(t *testing.T) {
+	cfg := &models.Configuration{
+		Regions:  map[string]models.Region{},
+		Settings: models.AppSettings{TestIntervalSeconds: 60},
+	}
+	mon := NewMonitor(context.Background(), cfg)
+
+	// Pausing before start keeps the monitor from running
+	mon.Pause(PauseReasonUser)
+	mon.Start()
+	if mon.Running() {
+		t.Errorf("Expected paused monitor not to run after Start")
+	}
+
+	mon.Pause("battery")
+	mon.Resume(PauseReasonUser)
+	if mon.Running() {
+		t.Errorf("Expected monitor to stay paused while other reasons remain")
+	}
+	if reasons := mon.PauseReasons(); len(reasons) != 1 || reasons[0] != "battery" {
+		t.Errorf("Unexpected pause reasons: %v", reasons)
+	}
+
+	mon.Resume("battery")
+	if !mon.Running() {
+		t.Errorf("Expected monitor to run once all pause reasons are cleared")
+	}
+
+	mon.Stop()
+	mon.Pause(PauseReasonUser)
+	mon.Resume(PauseReasonUser)
+	if mon.Running() {
+		t.Errorf("Expected stopped monitor not to be started by Resume")
+	}
+}
+
+func TestRunAllTestsReport(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	cfg := &models.Configuration{
+		Regions: map[string]models.Region{
+			"Default": {Endpoints: []models.Endpoint{
+				{Name: "up", Type: models.TypeTCP, Address: ln.Addr().String(), Timeout: 1000},
+				{Name: "bad", Type: "BOGUS", Address: "nowhere", Timeout: 1000},
+			}},
+		},
+	}
+	mon := NewMonitor(context.Background(), cfg)
+
+	report := mon.RunAllTests()
+	if report.Tests != 2 || report.Failures != 1 {
+		t.Errorf("Unexpected cycle report: %+v", report)
+	}
+	if len(mon.ResultsChan) != 2 {
+		t.Errorf("Expected 2 results emitted, got %d", len(mon.ResultsChan))
+	}
+}
+
+func TestScriptedOutage(t *testing.T) {
+	ep := models.Endpoint{Name: "flaky", Type: network.MockType, Address: "flaky", Timeout: 1000}
+	cfg := &models.Configuration{
+		Regions: map[string]models.Region{"Default": {Endpoints: []models.Endpoint{ep}}},
+	}
+	mon := NewMonitor(context.Background(), cfg)
+	mock := network.NewMockTest()
+	if err := mon.Runner.Protocols.Register(mock.Protocol()); err != nil {
+		t.Fatal(err)
+	}
+	down := network.MockStep{Err: errors.New("down")}
+	mock.Script("flaky", down, down)
+
+	var changes []bool
+	mon.OnStateChange = func(_ models.Endpoint, state models.EndpointState) {
+		changes = append(changes, state.Up)
+	}
+	for range 3 {
+		mon.RunAllTests()
+	}
+	if len(changes) != 2 || changes[0] || !changes[1] {
+		t.Errorf("Expected down then up, got %v", changes)
+	}
+}
+
+func TestConfirmedOutage(t *testing.T) {
+	ep := models.Endpoint{
+		Name: "web", Type: network.MockType, Address: "web", Timeout: 1000,
+		Confirm: &models.TestConfig{Type: network.MockType, Address: "host"},
+	}
+	cfg := &models.Configuration{
+		Regions: map[string]models.Region{"Default": {Endpoints: []models.Endpoint{ep}}},
+	}
+	mon := NewMonitor(context.Background(), cfg)
+	mock := network.NewMockTest()
+	if err := mon.Runner.Protocols.Register(mock.Protocol()); err != nil {
+		t.Fatal(err)
+	}
+	down := network.MockStep{Err: errors.New("down")}
+	mock.Script("web", down, down, down)
+	mock.Script("host", mock.Default, down)
+
+	type change struct{ up, degraded bool }
+	var changes []change
+	mon.OnStateChange = func(_ models.Endpoint, state models.EndpointState) {
+		changes = append(changes, change{state.Up, state.Degraded})
+	}
+	for range 4 {
+		mon.RunAllTests()
+	}
+	want := []change{{false, true}, {false, false}, {false, true}, {true, false}}
+	if !slices.Equal(changes, want) {
+		t.Errorf("Expected degraded, down, degraded, up; got %v", changes)
+	}
+	if calls := mock.Calls("host"); calls != 3 {
+		t.Errorf("Expected the probe to run only on failures, ran %d times", calls)
+	}
+}
+
+func TestCurrentStates(t *testing.T) {
+	mon := NewMonitor(context.Background(), nil)
+
+	mon.updateState(models.TestResult{Ts: 1, Id: "a", St: ResultSuccess})
+	mon.updateState(models.TestResult{Ts: 2, Id: "a", St: ResultError})
+	mon.updateState(models.TestResult{Ts: 3, Id: "a", St: ResultTimeout})
+
+	state := mon.CurrentStates()["a"]
+	if state.Up || state.ConsecutiveFailures != 2 || state.StateChangedAt != 2 || state.LastResult.Ts != 3 {
+		t.Errorf("Unexpected state after failures: %+v", state)
+	}
+
+	mon.updateState(models.TestResult{Ts: 4, Id: "a", St: ResultSuccess})
+	state = mon.CurrentStates()["a"]
+	if !state.Up || state.ConsecutiveFailures != 0 || state.StateChangedAt != 4 {
+		t.Errorf("Unexpected state after recovery: %+v", state)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	mon := NewMonitor(context.Background(), nil)
+	progress := make(chan models.BatchStatus, 10)
+	mon.OnBatchProgress = func(s models.BatchStatus) { progress <- s }
 
-	// Skip if short test? No, we want to run this.
+	endpoints := []models.Endpoint{
+		{Name: "up", Type: models.TypeTCP, Address: ln.Addr().String(), Timeout: 1000},
+		{Name: "bad", Type: "BOGUS", Address: "nowhere", Timeout: 1000},
+	}
+	status := mon.StartBatch(endpoints)
+	if status.Total != 2 || status.State != models.BatchRunning {
+		t.Fatalf("Unexpected initial status: %+v", status)
+	}
 
-	target := "127.0.0.1"
-	timeout := 2 * time.Second
+	var last models.BatchStatus
+	for last.State != models.BatchCompleted {
+		select {
+		case last = <-progress:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Batch did not complete, last status: %+v", last)
+		}
+	}
+	if last.Completed != 2 || last.Failures != 1 || len(last.Results) != 2 {
+		t.Errorf("Unexpected final status: %+v", last)
+	}
 
-	fmt.Printf("Attempting to ping %s...\n", target)
+	if err := mon.CancelBatch(status.ID); err == nil {
+		t.Errorf("Expected error cancelling a finished batch")
+	}
+	if _, err := mon.BatchStatus("missing"); err == nil {
+		t.Errorf("Expected error for unknown batch")
+	}
+}
 
-	_, err := checkICMP(target, timeout)
+func TestTestContextCancelled(t *testing.T) {
+	// Accept connections but never respond so the request hangs until cancelled
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
-		t.Logf("ICMP Ping to %s failed: %v", target, err)
-		t.Logf("Note: This might be expected if running without sufficient privileges or OS support.")
-		// We don't fail the test immediately because CI/Environments vary,
-		// but for local dev this should pass.
-		// Un-commenting Fatal to enforce it for now as per user request.
 		t.Fatal(err)
-	} else {
-		t.Logf("ICMP Ping to %s succeeded", target)
+	}
+	defer ln.Close()
+
+	mon := NewMonitor(context.Background(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	ep := models.Endpoint{Type: models.TypeHTTP, Address: "http://" + ln.Addr().String(), Timeout: 5000}
+	result, err := mon.TestContext(ctx, ep)
+	if err == nil {
+		t.Fatal("Expected error from cancelled test")
+	}
+	if result.St != ResultCancelled {
+		t.Errorf("Expected status %d, got %d", ResultCancelled, result.St)
+	}
+}
+
+func TestMonitorUDPJitter(t *testing.T) {
+	// UDP echo server
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	mon := NewMonitor(context.Background(), nil)
+	result, err := mon.Test(models.Endpoint{Type: models.TypeUDPJitter, Address: pc.LocalAddr().String(), Timeout: 1000})
+	if err != nil {
+		t.Fatalf("Jitter test failed: %v", err)
+	}
+	if result.St != ResultSuccess || result.Loss != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if result.Mos < 4 || result.Mos > 4.5 {
+		t.Errorf("Expected a high MOS on loopback, got %f", result.Mos)
+	}
+}
+
+func TestUpdateStateReportsChanges(t *testing.T) {
+	mon := NewMonitor(context.Background(), nil)
+
+	steps := []struct {
+		id      string
+		st      int
+		changed bool
+	}{
+		{"a", ResultSuccess, false}, // First result up is not a change
+		{"a", ResultError, true},
+		{"a", ResultTimeout, false},
+		{"a", ResultCancelled, false},
+		{"a", ResultSuccess, true},
+		{"b", ResultError, true}, // First result down is reported
+	}
+	for i, s := range steps {
+		if _, changed := mon.updateState(models.TestResult{Ts: int64(i), Id: s.id, St: s.st}); changed != s.changed {
+			t.Errorf("Step %d: expected changed=%v", i, s.changed)
+		}
+	}
+}
+
+func TestRegionConcurrency(t *testing.T) {
+	// Count concurrent requests on a server that holds them briefly
+	var mu sync.Mutex
+	active, peak := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		peak = max(peak, active)
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	var endpoints []models.Endpoint
+	for i := 0; i < 6; i++ {
+		endpoints = append(endpoints, models.Endpoint{Type: models.TypeHTTP, Address: fmt.Sprintf("%s/%d", ts.URL, i), Timeout: 2000})
+	}
+	cfg := &models.Configuration{
+		Regions: map[string]models.Region{
+			"Slow": {Endpoints: endpoints, Concurrency: 2},
+		},
+	}
+	mon := NewMonitor(context.Background(), cfg)
+
+	report := mon.RunAllTests()
+	if report.Tests != 6 || report.Failures != 0 {
+		t.Errorf("Unexpected cycle report: %+v", report)
+	}
+	if peak > 2 {
+		t.Errorf("Expected at most 2 concurrent tests, got %d", peak)
+	}
+}
+
+func TestShutdownCancelsInFlightTests(t *testing.T) {
+	// Accept connections but never respond so tests hang until cancelled
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	ep := models.Endpoint{Name: "hang", Type: models.TypeHTTP, Address: "http://" + ln.Addr().String(), Timeout: 30000}
+	cfg := &models.Configuration{
+		Regions:  map[string]models.Region{"Default": {Endpoints: []models.Endpoint{ep}}},
+		Settings: models.AppSettings{TestIntervalSeconds: 60},
+	}
+	mon := NewMonitor(context.Background(), cfg)
+	mon.Start()
+	batch := mon.StartBatch([]models.Endpoint{ep})
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := mon.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Shutdown took %v, expected in-flight tests to be aborted", elapsed)
+	}
+
+	select {
+	case result := <-mon.ResultsChan:
+		if result.St != ResultCancelled {
+			t.Errorf("Expected scheduled test to be cancelled, got %d", result.St)
+		}
+	default:
+		t.Errorf("Expected the cancelled scheduled result to be delivered")
+	}
+	status, _ := mon.BatchStatus(batch.ID)
+	if status.State != models.BatchCancelled || status.Results[0].St != ResultCancelled {
+		t.Errorf("Unexpected batch status: %+v", status)
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		mon.workersWg.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-time.After(time.Second):
+		t.Errorf("Expected the workers to exit after Shutdown")
+	}
+
+	// Without workers, tests queued after Shutdown are cancelled right away
+	reports := make(chan models.CycleReport, 1)
+	go func() { reports <- mon.RunAllTests() }()
+	select {
+	case report := <-reports:
+		if report.Tests != 1 || report.Cancelled != 1 {
+			t.Errorf("Expected the test to be cancelled, got %+v", report)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected tests queued after Shutdown not to wait for workers")
+	}
+
+	mon.Start()
+	if mon.Running() {
+		t.Errorf("Expected monitor not to restart after Shutdown")
+	}
+}
+
+func TestSeedStates(t *testing.T) {
+	mon := NewMonitor(context.Background(), nil)
+	mon.updateState(models.TestResult{Ts: 100, Id: "tested", St: ResultSuccess})
+
+	mon.SeedStates([]models.TestResult{
+		{Ts: 3, Id: "a", St: ResultError},
+		{Ts: 1, Id: "a", St: ResultSuccess},
+		{Ts: 2, Id: "a", St: ResultTimeout},
+		{Ts: 4, Id: "a", St: ResultCancelled},
+		{Ts: 50, Id: "tested", St: ResultError},
+	})
+
+	states := mon.CurrentStates()
+	if a := states["a"]; a.Up || a.ConsecutiveFailures != 2 || a.StateChangedAt != 2 || a.LastResult.Ts != 3 {
+		t.Errorf("Unexpected seeded state: %+v", a)
+	}
+	if s := states["tested"]; !s.Up || s.LastResult.Ts != 100 {
+		t.Errorf("Expected tested endpoint to keep its state, got %+v", s)
+	}
+}
+
+func TestDataBudget(t *testing.T) {
+	ep := models.Endpoint{Name: "speed", Type: network.MockType, Address: "speed", Timeout: 1000}
+	cfg := &models.Configuration{
+		Regions:  map[string]models.Region{"Default": {Endpoints: []models.Endpoint{ep}}},
+		Settings: models.AppSettings{DataBudget: &models.DataBudget{DailyMB: 2}},
+	}
+	mon := NewMonitor(context.Background(), cfg)
+	mock := network.NewMockTest()
+	mock.Default.BytesReceived = 1 << 20
+	if err := mon.Runner.Protocols.Register(mock.Protocol()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first test learns the cost, the second still fits the budget
+	for range 2 {
+		if report := mon.RunAllTests(); report.OverBudget != 0 {
+			t.Fatalf("Expected the test to fit the budget: %+v", report)
+		}
+	}
+	report := mon.RunAllTests()
+	if report.OverBudget != 1 || report.Cancelled != 0 || mock.Calls("speed") != 2 {
+		t.Errorf("Expected the third test to be skipped: %+v, %d calls", report, mock.Calls("speed"))
+	}
+	if usage := mon.DataUsage(); usage.TodayBytes != 2<<20 || usage.MonthBytes != 2<<20 {
+		t.Errorf("Unexpected usage: %+v", usage)
+	}
+
+	// Endpoints with a lighter form run in it instead
+	cfg.Regions["Default"].Endpoints[0].AllAddresses = true
+	mon.RunAllTests()
+	var last models.TestResult
+	for len(mon.ResultsChan) > 0 {
+		last = <-mon.ResultsChan
+	}
+	if last.St != ResultSuccess || last.Tags[TagBudget] != BudgetDowngraded {
+		t.Errorf("Expected a downgraded test: %+v", last)
+	}
+}
+
+func TestBattery(t *testing.T) {
+	ep := models.Endpoint{Name: "speed", Type: network.MockType, Address: "speed", Timeout: 1000}
+	cfg := &models.Configuration{
+		Regions: map[string]models.Region{"Default": {Endpoints: []models.Endpoint{ep}}},
+		Settings: models.AppSettings{
+			TestIntervalSeconds: 60,
+			Battery:             &models.BatterySettings{IntervalSeconds: 600, LightTests: true},
+		},
+	}
+	mon := NewMonitor(context.Background(), cfg)
+	mock := network.NewMockTest()
+	mock.Default.BytesReceived = 1 << 20
+	if err := mon.Runner.Protocols.Register(mock.Protocol()); err != nil {
+		t.Fatal(err)
+	}
+
+	if d := mon.interval(); d != time.Minute {
+		t.Errorf("Expected the configured interval on AC power, got %v", d)
+	}
+	mon.RunAllTests() // Learns the cost on AC power
+
+	mon.SetOnBattery(true)
+	if d := mon.interval(); d != 10*time.Minute {
+		t.Errorf("Expected the battery interval, got %v", d)
+	}
+	report := mon.RunAllTests()
+	last := <-mon.ResultsChan
+	for len(mon.ResultsChan) > 0 {
+		last = <-mon.ResultsChan
+	}
+	if report.Cancelled != 1 || mock.Calls("speed") != 1 || last.Tags[TagPower] != BudgetSkipped {
+		t.Errorf("Expected the heavy test to be skipped on battery: %+v, %d calls, %+v", report, mock.Calls("speed"), last)
+	}
+
+	mon.SetOnBattery(false)
+	if report := mon.RunAllTests(); report.Cancelled != 0 || mock.Calls("speed") != 2 {
+		t.Errorf("Expected the test to run on AC power: %+v, %d calls", report, mock.Calls("speed"))
+	}
+}
+
+func TestEndpointIntervals(t *testing.T) {
+	fast := models.Endpoint{Name: "fast", Type: network.MockType, Address: "fast", Timeout: 1000, IntervalSeconds: 10}
+	slow := models.Endpoint{Name: "slow", Type: network.MockType, Address: "slow", Timeout: 1000}
+	cfg := &models.Configuration{
+		Regions: map[string]models.Region{"Default": {Endpoints: []models.Endpoint{fast, slow}}},
+		Settings: models.AppSettings{
+			TestIntervalSeconds: 300,
+			Battery:             &models.BatterySettings{IntervalSeconds: 60},
+		},
+	}
+	mon := NewMonitor(context.Background(), cfg)
+	if d := mon.endpointInterval(fast); d != 10*time.Second {
+		t.Errorf("Expected the fast endpoint to keep its own interval, got %v", d)
+	}
+	if d := mon.endpointInterval(slow); d != 5*time.Minute {
+		t.Errorf("Expected the slow endpoint to use the default interval, got %v", d)
+	}
+
+	mon.SetOnBattery(true)
+	if d := mon.endpointInterval(fast); d != time.Minute {
+		t.Errorf("Expected the battery interval to slow down the fast endpoint, got %v", d)
+	}
+	if d := mon.endpointInterval(slow); d != 5*time.Minute {
+		t.Errorf("Expected the slow endpoint to keep its interval on battery, got %v", d)
+	}
+}
+
+func TestSchedulerCadence(t *testing.T) {
+	fast := models.Endpoint{Name: "fast", Type: network.MockType, Address: "fast", Timeout: 5000}
+	slow := models.Endpoint{Name: "slow", Type: network.MockType, Address: "slow", Timeout: 5000}
+	cfg := &models.Configuration{
+		Regions:  map[string]models.Region{"Default": {Endpoints: []models.Endpoint{fast, slow}}},
+		Settings: models.AppSettings{TestIntervalSeconds: 60},
+	}
+	mon := NewMonitor(context.Background(), cfg)
+	mock := network.NewMockTest()
+	mock.Script("slow", network.MockStep{Measurement: network.Measurement{Latency: 400 * time.Millisecond}, Sleep: true})
+	if err := mon.Runner.Protocols.Register(mock.Protocol()); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for range mon.ResultsChan {
+		}
+	}()
+
+	// Intervals scaled down so the test runs in half a second
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newScheduler(ctx, mon)
+	s.interval = func(ep models.Endpoint) time.Duration {
+		if ep.Name == "fast" {
+			return 50 * time.Millisecond
+		}
+		return time.Second
+	}
+	s.start()
+	time.Sleep(520 * time.Millisecond)
+	cancel()
+	s.stop()
+	mon.inflight.Wait()
+
+	// The slow test must not hold up the fast endpoint, due 11 times
+	if n := mock.Calls("fast"); n < 8 {
+		t.Errorf("Expected the fast endpoint to be tested every 50ms, got %d tests", n)
+	}
+	if n := mock.Calls("slow"); n != 1 {
+		t.Errorf("Expected the slow endpoint to be tested once, got %d", n)
+	}
+}
+
+func TestCertExpiring(t *testing.T) {
+	ep := models.Endpoint{Name: "site", Type: network.MockType, Address: "site", Timeout: 1000}
+	cfg := &models.Configuration{
+		Regions:  map[string]models.Region{"Default": {Endpoints: []models.Endpoint{ep}}},
+		Settings: models.AppSettings{TestIntervalSeconds: 60, CertWarningDays: 30},
+	}
+	mon := NewMonitor(context.Background(), cfg)
+	mock := network.NewMockTest()
+	if err := mon.Runner.Protocols.Register(mock.Protocol()); err != nil {
+		t.Fatal(err)
+	}
+	var alerts []string
+	mon.OnCertExpiring = func(ep models.Endpoint, state models.EndpointState) {
+		alerts = append(alerts, state.LastResult.Tags[network.TagCertExpiryDays])
+	}
+
+	for _, days := range []string{"90", "30", "29", "12", "365", "3"} {
+		mock.Default.Tags = map[string]string{network.TagCertExpiryDays: days}
+		mon.RunAllTests()
+		for len(mon.ResultsChan) > 0 {
+			<-mon.ResultsChan
+		}
+	}
+	// Reported when first within the period, and again after the renewal
+	if !slices.Equal(alerts, []string{"30", "3"}) {
+		t.Errorf("Expected alerts at 30 and 3 days, got %v", alerts)
+	}
+}
+
+func TestTraceIDs(t *testing.T) {
+	eps := []models.Endpoint{
+		{Name: "a", Type: network.MockType, Address: "a", Timeout: 1000},
+		{Name: "b", Type: network.MockType, Address: "b", Timeout: 1000},
+	}
+	cfg := &models.Configuration{
+		Regions:  map[string]models.Region{"Default": {Endpoints: eps}},
+		Settings: models.AppSettings{TestIntervalSeconds: 60},
+	}
+	mon := NewMonitor(context.Background(), cfg)
+	mock := network.NewMockTest()
+	if err := mon.Runner.Protocols.Register(mock.Protocol()); err != nil {
+		t.Fatal(err)
+	}
+
+	report := mon.RunAllTests()
+	var traces []string
+	for len(mon.ResultsChan) > 0 {
+		traces = append(traces, (<-mon.ResultsChan).Trace)
+	}
+	slices.Sort(traces)
+	if len(report.ID) != 8 || !slices.Equal(traces, []string{report.ID + ".1", report.ID + ".2"}) {
+		t.Errorf("Expected traces of run %q, got %v", report.ID, traces)
+	}
+
+	// Tests run on their own get a trace of their own
+	first, second := mon.TestEndpoint(eps[0]), mon.TestEndpoint(eps[0])
+	if first.Trace == "" || first.Trace == second.Trace || strings.Contains(first.Trace, ".") {
+		t.Errorf("Expected distinct traces, got %q and %q", first.Trace, second.Trace)
+	}
+}
+
+func TestPriority(t *testing.T) {
+	a := models.Endpoint{Name: "a", Type: network.MockType, Address: "a", Timeout: 1000}
+	b := models.Endpoint{Name: "b", Type: network.MockType, Address: "b", Timeout: 1000, Priority: 2}
+	c := models.Endpoint{Name: "c", Type: network.MockType, Address: "c", Timeout: 1000, Priority: 1}
+	cfg := &models.Configuration{
+		Regions: map[string]models.Region{
+			"A": {Endpoints: []models.Endpoint{a, c}},
+			"B": {Endpoints: []models.Endpoint{b}},
+		},
+		Settings: models.AppSettings{TestIntervalSeconds: 60},
+	}
+	mon := NewMonitor(context.Background(), cfg)
+	mock := network.NewMockTest()
+	if err := mon.Runner.Protocols.Register(mock.Protocol()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Trace IDs number tests in the order they were queued
+	report := mon.RunAllTests()
+	order := make(map[string]string)
+	for len(mon.ResultsChan) > 0 {
+		r := <-mon.ResultsChan
+		order[strings.TrimPrefix(r.Trace, report.ID+".")] = r.Id
+	}
+	for n, ep := range []models.Endpoint{b, c, a} {
+		if id := network.EndpointID(ep.Address, ep.Type); order[fmt.Sprint(n+1)] != id {
+			t.Errorf("Expected %s to be queued %d, got order %v", ep.Name, n+1, order)
+		}
 	}
 }
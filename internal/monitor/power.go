@@ -0,0 +1,63 @@
+package monitor
+
+import (
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+// PauseReasonBattery pauses monitoring while the computer runs on battery
+const PauseReasonBattery = "battery"
+
+// TagPower marks results of scheduled tests changed to save battery, with
+// BudgetDowngraded or BudgetSkipped like TagBudget
+const TagPower = "power"
+
+// SetOnBattery switches to the battery settings, or back to full monitoring
+// on AC power. A running test loop picks up the new interval right away.
+func (m *Monitor) SetOnBattery(on bool) {
+	if m.onBattery.Swap(on) == on {
+		return
+	}
+	select {
+	case m.intervalChanged <- struct{}{}:
+	default:
+	}
+}
+
+// OnBattery reports whether the monitor runs with the battery settings
+func (m *Monitor) OnBattery() bool {
+	return m.onBattery.Load()
+}
+
+// battery returns the battery settings in effect, nil on AC power
+func (m *Monitor) battery() *models.BatterySettings {
+	if !m.onBattery.Load() {
+		return nil
+	}
+	return m.Config.Settings.Battery
+}
+
+// interval returns the time between scheduled tests of endpoints without an
+// interval of their own
+func (m *Monitor) interval() time.Duration {
+	return m.endpointInterval(models.Endpoint{})
+}
+
+// powerAction decides whether a scheduled test of ep runs in full on
+// battery, like budgetAction does for the data budget. Tests are heavy by
+// the same measure, the traffic of their last full run.
+func (m *Monitor) powerAction(ep models.Endpoint) string {
+	if b := m.battery(); b == nil || !b.LightTests {
+		return ""
+	}
+	cost, ok := m.traffic.cost(network.EndpointID(ep.Address, ep.Type))
+	if !ok || cost <= lightTestBytes {
+		return ""
+	}
+	if _, ok := downgrade(ep); ok {
+		return BudgetDowngraded
+	}
+	return BudgetSkipped
+}
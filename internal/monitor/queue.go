@@ -0,0 +1,266 @@
+package monitor
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+// DefaultWorkers is the size of the worker pool that runs scheduled tests
+const DefaultWorkers = 32
+
+// job is a test waiting in the queue
+type job struct {
+	due time.Time
+	// priority of the endpoint, higher ones run first among jobs due together
+	priority int
+	seq      uint64 // Keeps jobs due at the same time in insertion order
+	ctx      context.Context
+	ep       models.Endpoint
+	region   string
+	limit    int // Concurrency limit of the region, zero for none
+	done     func(models.TestResult)
+}
+
+type jobHeap []*job
+
+func (h jobHeap) Len() int { return len(h) }
+func (h jobHeap) Less(i, j int) bool {
+	if !h[i].due.Equal(h[j].due) {
+		return h[i].due.Before(h[j].due)
+	}
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *jobHeap) Push(x any)   { *h = append(*h, x.(*job)) }
+func (h *jobHeap) Pop() any {
+	old := *h
+	j := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return j
+}
+
+// testQueue orders tests by due time. Workers take the earliest due test
+// whose region is below its concurrency limit, so a saturated region never
+// keeps workers from testing the others.
+type testQueue struct {
+	mu      sync.Mutex
+	jobs    jobHeap
+	seq     uint64
+	running map[string]int // Region -> tests in flight
+	closed  bool           // Set by close, after which no job is accepted
+
+	// wake is signalled when a job is added or a region slot frees up
+	wake chan struct{}
+}
+
+func newTestQueue() *testQueue {
+	return &testQueue{
+		running: make(map[string]int),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// push adds a job, unless the queue is closed
+func (q *testQueue) push(j *job) bool {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return false
+	}
+	q.seq++
+	j.seq = q.seq
+	heap.Push(&q.jobs, j)
+	q.mu.Unlock()
+	q.signal()
+	return true
+}
+
+// close stops accepting jobs and returns those still waiting
+func (q *testQueue) close() []*job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	jobs := q.jobs
+	q.jobs = nil
+	return jobs
+}
+
+// remove drops a job still waiting in the queue. It returns false if a
+// worker already took it.
+func (q *testQueue) remove(j *job) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, queued := range q.jobs {
+		if queued == j {
+			heap.Remove(&q.jobs, i)
+			return true
+		}
+	}
+	return false
+}
+
+func (q *testQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next blocks until a job can run, or ctx is done
+func (q *testQueue) next(ctx context.Context) *job {
+	for {
+		q.mu.Lock()
+		j, wait := q.take(time.Now())
+		more := len(q.jobs) > 0
+		q.mu.Unlock()
+
+		if j != nil {
+			// Only one waiting worker is woken per signal, pass it on
+			if more {
+				q.signal()
+			}
+			return j
+		}
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-q.wake:
+		case <-timeout:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// take removes the earliest due job that can run now. When none can, it
+// returns how long until the earliest job not held back by its region is
+// due, or zero if every job waits for a region slot.
+func (q *testQueue) take(now time.Time) (*job, time.Duration) {
+	var skipped []*job
+	defer func() {
+		for _, j := range skipped {
+			heap.Push(&q.jobs, j)
+		}
+	}()
+
+	for len(q.jobs) > 0 {
+		j := q.jobs[0]
+		if j.due.After(now) {
+			return nil, j.due.Sub(now)
+		}
+		heap.Pop(&q.jobs)
+		if j.limit > 0 && q.running[j.region] >= j.limit {
+			skipped = append(skipped, j)
+			continue
+		}
+		q.running[j.region]++
+		return j, 0
+	}
+	return nil, 0
+}
+
+// finish releases the region slot held by a job
+func (q *testQueue) finish(j *job) {
+	q.mu.Lock()
+	q.running[j.region]--
+	if q.running[j.region] == 0 {
+		delete(q.running, j.region)
+	}
+	q.mu.Unlock()
+	q.signal()
+}
+
+// depth returns the jobs waiting and the jobs running
+func (q *testQueue) depth() (queued, running int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, n := range q.running {
+		running += n
+	}
+	return len(q.jobs), running
+}
+
+// QueueDepth returns the scheduled tests waiting for a worker, including
+// those held back by their region's concurrency limit, and those running
+func (m *Monitor) QueueDepth() (queued, running int) {
+	return m.queue.depth()
+}
+
+// startWorkers starts the worker pool the first time it is needed. Workers
+// outlive the monitor context so queued tests always complete, they are
+// reported as cancelled once their context is done. They exit once Shutdown
+// stops them and no due test is left.
+func (m *Monitor) startWorkers() {
+	m.workersOnce.Do(func() {
+		workers := m.Workers
+		if workers <= 0 {
+			workers = DefaultWorkers
+		}
+		m.workersWg.Add(workers)
+		for i := 0; i < workers; i++ {
+			go m.work()
+		}
+	})
+}
+
+func (m *Monitor) work() {
+	defer m.workersWg.Done()
+	for {
+		j := m.queue.next(m.workersCtx)
+		if j == nil {
+			return
+		}
+		result := m.runScheduled(j.ctx, j.ep)
+		m.queue.finish(j)
+		j.done(result)
+	}
+}
+
+// enqueue schedules a test of ep to run once due. After Shutdown the test is
+// reported as cancelled right away.
+func (m *Monitor) enqueue(ctx context.Context, due time.Time, region models.Region, regionName string, ep models.Endpoint, done func(models.TestResult)) *job {
+	m.startWorkers()
+	j := &job{
+		due:      due,
+		priority: ep.Priority,
+		ctx:      ctx,
+		ep:       ep,
+		region:   regionName,
+		limit:    region.Concurrency,
+		done:     done,
+	}
+	if !m.queue.push(j) {
+		go m.cancelJob(j)
+	}
+	return j
+}
+
+// cancelJob reports a job the workers will never run as cancelled
+func (m *Monitor) cancelJob(j *job) {
+	ts, seq, _ := m.Runner.Clock.Stamp()
+	j.done(models.TestResult{
+		Ts:    ts.UnixMilli(),
+		Seq:   seq,
+		Id:    network.EndpointID(j.ep.Address, j.ep.Type),
+		St:    ResultCancelled,
+		Trace: network.TraceFrom(j.ctx),
+	})
+}
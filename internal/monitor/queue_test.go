@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestQueueOrder(t *testing.T) {
+	q := newTestQueue()
+	now := time.Now()
+	q.push(&job{due: now.Add(time.Hour), ep: models.Endpoint{Name: "later"}})
+	q.push(&job{due: now, ep: models.Endpoint{Name: "first"}})
+	q.push(&job{due: now, ep: models.Endpoint{Name: "second"}})
+
+	for _, want := range []string{"first", "second"} {
+		j, _ := q.take(now)
+		if j == nil || j.ep.Name != want {
+			t.Fatalf("Expected %s, got %+v", want, j)
+		}
+	}
+	if j, wait := q.take(now); j != nil || wait != time.Hour {
+		t.Errorf("Expected to wait an hour for the next job, got %+v after %v", j, wait)
+	}
+}
+
+func TestQueueRegionLimit(t *testing.T) {
+	q := newTestQueue()
+	now := time.Now()
+	q.push(&job{due: now, region: "slow", limit: 1, ep: models.Endpoint{Name: "slow1"}})
+	q.push(&job{due: now, region: "slow", limit: 1, ep: models.Endpoint{Name: "slow2"}})
+	q.push(&job{due: now, region: "fast", ep: models.Endpoint{Name: "fast"}})
+
+	first, _ := q.take(now)
+	// The second slow job is skipped while the first runs
+	if j, _ := q.take(now); j == nil || j.ep.Name != "fast" {
+		t.Fatalf("Expected the fast region job, got %+v", j)
+	}
+	if j, wait := q.take(now); j != nil || wait != 0 {
+		t.Fatalf("Expected the slow region to be saturated, got %+v", j)
+	}
+	if queued, running := q.depth(); queued != 1 || running != 2 {
+		t.Errorf("Expected 1 queued and 2 running jobs, got %d and %d", queued, running)
+	}
+
+	q.finish(first)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if j := q.next(ctx); j == nil || j.ep.Name != "slow2" {
+		t.Errorf("Expected slow2 once a slot freed up, got %+v", j)
+	}
+}
+
+func TestQueueWaitsPastSaturatedRegion(t *testing.T) {
+	q := newTestQueue()
+	now := time.Now()
+	q.push(&job{due: now, region: "slow", limit: 1, ep: models.Endpoint{Name: "slow1"}})
+	q.push(&job{due: now, region: "slow", limit: 1, ep: models.Endpoint{Name: "slow2"}})
+	q.push(&job{due: now.Add(50 * time.Millisecond), region: "fast", ep: models.Endpoint{Name: "fast"}})
+
+	q.take(now)
+	// slow2 waits for a slot, which doesn't hold back the wait for fast
+	if j, wait := q.take(now); j != nil || wait != 50*time.Millisecond {
+		t.Fatalf("Expected to wait 50ms for the fast job, got %+v after %v", j, wait)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if j := q.next(ctx); j == nil || j.ep.Name != "fast" {
+		t.Errorf("Expected the fast job once due, got %+v", j)
+	}
+}
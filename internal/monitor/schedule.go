@@ -0,0 +1,241 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+	"github.com/rs/zerolog/log"
+)
+
+// endpointInterval returns the time between scheduled tests of ep, its own
+// interval if it has one. On battery no endpoint is tested more often than
+// the battery interval.
+func (m *Monitor) endpointInterval(ep models.Endpoint) time.Duration {
+	seconds := m.Config.Settings.TestIntervalSeconds
+	if ep.IntervalSeconds > 0 {
+		seconds = ep.IntervalSeconds
+	}
+	if b := m.battery(); b != nil && b.IntervalSeconds > seconds {
+		seconds = b.IntervalSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// scheduler tests every endpoint at its own interval. Each endpoint is
+// queued with its next due time and queued again once its result arrives, so
+// a slow endpoint never holds up the others. Tests due at the same time make
+// up a cycle, reported to OnCycle once its last result arrives.
+type scheduler struct {
+	ctx context.Context
+	m   *Monitor
+
+	// interval returns the time between tests of an endpoint,
+	// m.endpointInterval unless replaced by a test
+	interval func(models.Endpoint) time.Duration
+
+	mu      sync.Mutex
+	stopped bool
+	queued  map[*job]*scheduled
+	cycles  map[int64]*cycle // Keyed by due time in nanoseconds
+
+	// reportMu keeps OnCycle from being called by two cycles at once
+	reportMu sync.Mutex
+}
+
+// scheduled is a test of an endpoint waiting in the queue
+type scheduled struct {
+	regionName string
+	region     models.Region
+	ep         models.Endpoint
+	due        time.Time
+	// prev is the due time of the endpoint's previous test, zero for the first
+	prev time.Time
+	job  *job
+}
+
+// cycle gathers the tests due at the same time
+type cycle struct {
+	report  models.CycleReport
+	start   time.Time
+	queued  int
+	pending int
+}
+
+func newScheduler(ctx context.Context, m *Monitor) *scheduler {
+	return &scheduler{
+		ctx:      ctx,
+		m:        m,
+		interval: m.endpointInterval,
+		queued:   make(map[*job]*scheduled),
+		cycles:   make(map[int64]*cycle),
+	}
+}
+
+// start queues a test of every configured endpoint, due now
+func (s *scheduler) start() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, regionName := range slices.Sorted(maps.Keys(s.m.Config.Regions)) {
+		region := s.m.Config.Regions[regionName]
+		for _, ep := range region.Endpoints {
+			s.pushLocked(&scheduled{regionName: regionName, region: region, ep: ep, due: now}, 0)
+		}
+	}
+}
+
+// pushLocked queues t in the cycle of its due time. missed is how many tests
+// of the endpoint were skipped since its previous one.
+func (s *scheduler) pushLocked(t *scheduled, missed int) {
+	key := t.due.UnixNano()
+	c := s.cycles[key]
+	if c == nil {
+		c = &cycle{start: t.due, report: models.CycleReport{ID: network.NewRunID(), Start: t.due.UnixMilli()}}
+		s.cycles[key] = c
+	}
+	c.queued++
+	c.pending++
+	c.report.Skipped = max(c.report.Skipped, missed)
+	if c.queued == 1 {
+		log.Ctx(s.m.Ctx).Debug().Str("run", c.report.ID).Time("due", t.due).Msg("Test cycle scheduled")
+	}
+
+	s.m.inflight.Add(1)
+	traceCtx := network.WithTrace(s.ctx, fmt.Sprintf("%s.%d", c.report.ID, c.queued))
+	t.job = s.m.enqueue(traceCtx, t.due, t.region, t.regionName, t.ep, func(result models.TestResult) {
+		defer s.m.inflight.Done()
+		s.done(t, result)
+	})
+	s.queued[t.job] = t
+}
+
+// every returns the interval of ep, never zero so the schedule moves on
+func (s *scheduler) every(ep models.Endpoint) time.Duration {
+	if interval := s.interval(ep); interval > 0 {
+		return interval
+	}
+	return time.Second
+}
+
+// done records the result of a test and queues the next test of its endpoint
+func (s *scheduler) done(t *scheduled, result models.TestResult) {
+	s.m.deliver(t.ep, result)
+
+	interval := s.every(t.ep)
+	now := time.Now()
+	s.mu.Lock()
+	delete(s.queued, t.job)
+	c := s.cycles[t.due.UnixNano()]
+	countResult(&c.report, result)
+	if now.Sub(t.due) > interval {
+		c.report.Overrun = true
+	}
+	report, settled := s.releaseLocked(t.due)
+
+	if !s.stopped && s.ctx.Err() == nil {
+		// Tests due while this one ran are skipped rather than run late
+		next, missed := t.due.Add(interval), 0
+		for !next.After(now) {
+			next = next.Add(interval)
+			missed++
+		}
+		s.pushLocked(&scheduled{regionName: t.regionName, region: t.region, ep: t.ep, due: next, prev: t.due}, missed)
+	}
+	s.mu.Unlock()
+
+	if settled {
+		s.report(report)
+	}
+}
+
+// releaseLocked counts a test of the cycle due at due as done, and returns
+// the cycle's report once none of its tests is left
+func (s *scheduler) releaseLocked(due time.Time) (models.CycleReport, bool) {
+	key := due.UnixNano()
+	c := s.cycles[key]
+	c.pending--
+	if c.pending > 0 {
+		return models.CycleReport{}, false
+	}
+	delete(s.cycles, key)
+	if c.report.Tests == 0 {
+		return models.CycleReport{}, false
+	}
+	c.report.DurationMs = time.Since(c.start).Milliseconds()
+	return c.report, true
+}
+
+func (s *scheduler) report(report models.CycleReport) {
+	if report.Overrun || report.Skipped > 0 {
+		log.Ctx(s.m.Ctx).Warn().
+			Str("run", report.ID).
+			Int64("duration_ms", report.DurationMs).
+			Int("skipped", report.Skipped).
+			Msg("Test cycle overran the test interval")
+	}
+	if s.m.OnCycle != nil {
+		s.reportMu.Lock()
+		defer s.reportMu.Unlock()
+		s.m.OnCycle(report)
+	}
+}
+
+// reschedule moves queued tests to the current intervals of their endpoints,
+// e.g. sooner when back on AC. Tests moved to now share a cycle.
+func (s *scheduler) reschedule() {
+	now := time.Now()
+	var reports []models.CycleReport
+	s.mu.Lock()
+	for _, t := range slices.Collect(maps.Values(s.queued)) {
+		if t.prev.IsZero() {
+			continue
+		}
+		due := t.prev.Add(s.every(t.ep))
+		if due.Before(now) {
+			due = now
+		}
+		if due.Equal(t.due) || !s.m.queue.remove(t.job) {
+			continue
+		}
+		delete(s.queued, t.job)
+		s.m.inflight.Done()
+		if report, settled := s.releaseLocked(t.due); settled {
+			reports = append(reports, report)
+		}
+		s.pushLocked(&scheduled{regionName: t.regionName, region: t.region, ep: t.ep, due: due, prev: t.prev}, 0)
+	}
+	s.mu.Unlock()
+
+	for _, report := range reports {
+		s.report(report)
+	}
+}
+
+// stop drops the queued tests. Tests already running are aborted by the
+// cancellation of the scheduler's context and report their cycles.
+func (s *scheduler) stop() {
+	var reports []models.CycleReport
+	s.mu.Lock()
+	s.stopped = true
+	for j, t := range s.queued {
+		if !s.m.queue.remove(j) {
+			continue
+		}
+		delete(s.queued, j)
+		s.m.inflight.Done()
+		if report, settled := s.releaseLocked(t.due); settled {
+			reports = append(reports, report)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, report := range reports {
+		s.report(report)
+	}
+}
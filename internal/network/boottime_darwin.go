@@ -0,0 +1,13 @@
+package network
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// bootClock reads CLOCK_MONOTONIC, which on macOS keeps counting while the
+// system sleeps, unlike the clock Go's monotonic time is based on
+func bootClock() func() time.Duration {
+	return clockReader(unix.CLOCK_MONOTONIC)
+}
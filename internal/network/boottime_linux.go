@@ -0,0 +1,13 @@
+package network
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// bootClock reads CLOCK_BOOTTIME, which unlike the monotonic clock keeps
+// counting while the system is suspended
+func bootClock() func() time.Duration {
+	return clockReader(unix.CLOCK_BOOTTIME)
+}
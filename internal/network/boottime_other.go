@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package network
+
+import "time"
+
+// bootClock returns nil. Windows' monotonic clock already counts the time
+// asleep; elsewhere a resume is still reported as a clock jump.
+func bootClock() func() time.Duration {
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build linux || darwin
+
+package network
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// clockReader returns a reader of clock id, or nil if it can't be read
+func clockReader(id int32) func() time.Duration {
+	var ts unix.Timespec
+	if unix.ClockGettime(id, &ts) != nil {
+		return nil
+	}
+	return func() time.Duration {
+		var ts unix.Timespec
+		unix.ClockGettime(id, &ts)
+		return time.Duration(ts.Nano())
+	}
+}
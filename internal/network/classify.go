@@ -0,0 +1,56 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"syscall"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// ClassifyError maps a test error to the failure taxonomy used in aggregations
+func ClassifyError(err error, status int) models.ErrorKind {
+	if err == nil || status == models.TestStatusCancelled {
+		return ""
+	}
+
+	var dnsErr *net.DNSError
+	var dnsRespErr *DNSResponseError
+	var statusErr *HTTPStatusError
+	var icmpErr *ICMPError
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+
+	switch {
+	case errors.As(err, &dnsErr), errors.As(err, &dnsRespErr):
+		return models.ErrorKindDNS
+	case errors.As(err, &statusErr):
+		return models.ErrorKindHTTPStatus
+	case errors.As(err, &icmpErr):
+		return icmpErr.Kind()
+	case errors.Is(err, ErrPacketLoss):
+		return models.ErrorKindPacketLoss
+	case errors.Is(err, ErrUnexpectedResponse):
+		return models.ErrorKindBadResponse
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return models.ErrorKindRefused
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return models.ErrorKindUnreachable
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &unknownAuthority),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidCert):
+		return models.ErrorKindTLS
+	case status == models.TestStatusTimeout:
+		return models.ErrorKindTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return models.ErrorKindTimeout
+	}
+	return models.ErrorKindOther
+}
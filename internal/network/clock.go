@@ -0,0 +1,94 @@
+package network
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TagClockJump is added to results stamped right after the system clock
+// jumped, with the size of the jump in milliseconds
+const TagClockJump = "clock_jump_ms"
+
+// maxClockDrift is how far the wall clock may drift from the monotonic clock
+// before it counts as a jump. Gradual NTP slewing stays well below it.
+const maxClockDrift = 2 * time.Second
+
+// Clock stamps results so that clock changes during a session, such as an
+// NTP correction or the user changing the time, don't reorder or corrupt
+// them. Timestamps advance with the monotonic clock from an anchor wall time;
+// when the wall clock is found to have jumped away from that, the jump is
+// logged and the clock re-anchors so later results use the corrected time.
+// Results also get a sequence number that always increases within a session.
+//
+// The monotonic clock stops while the computer sleeps on Linux and macOS, so
+// the wall clock seems to jump forward on resume. Where the OS has a clock
+// that keeps counting during sleep, the time slept moves the anchor instead.
+type Clock struct {
+	Ctx context.Context
+
+	// Wall, Monotonic and Boot read the clocks and can be replaced in tests.
+	// Monotonic returns the time elapsed since an arbitrary fixed point, and
+	// Boot the same including the time asleep. Boot is nil where the OS has
+	// no such clock or the monotonic clock already counts sleep.
+	Wall      func() time.Time
+	Monotonic func() time.Duration
+	Boot      func() time.Duration
+
+	mu         sync.Mutex
+	anchored   bool
+	anchorWall time.Time
+	anchorMono time.Duration
+	lastMono   time.Duration
+	lastBoot   time.Duration
+	seq        uint64
+}
+
+func NewClock(ctx context.Context) *Clock {
+	start := time.Now()
+	return &Clock{
+		Ctx:       ctx,
+		Wall:      func() time.Time { return time.Now().Round(0) },
+		Monotonic: func() time.Duration { return time.Since(start) },
+		Boot:      bootClock(),
+	}
+}
+
+// Stamp returns the timestamp and sequence number of a result, and the size
+// of the clock jump detected since the previous stamp, if any
+func (c *Clock) Stamp() (ts time.Time, seq uint64, jump time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	wall, mono := c.Wall(), c.Monotonic()
+	var boot time.Duration
+	if c.Boot != nil {
+		boot = c.Boot()
+	}
+	c.seq++
+	if !c.anchored {
+		c.anchored, c.anchorWall, c.anchorMono = true, wall, mono
+		c.lastMono, c.lastBoot = mono, boot
+		return wall, c.seq, 0
+	}
+	// Time the monotonic clock missed since the last stamp was spent asleep
+	if slept := (boot - c.lastBoot) - (mono - c.lastMono); c.Boot != nil && slept > 0 {
+		c.anchorWall = c.anchorWall.Add(slept)
+	}
+	c.lastMono, c.lastBoot = mono, boot
+
+	expected := c.anchorWall.Add(mono - c.anchorMono)
+	jump = wall.Sub(expected)
+	if jump > -maxClockDrift && jump < maxClockDrift {
+		return expected, c.seq, 0
+	}
+
+	log.Ctx(c.Ctx).Warn().
+		Dur("jump", jump).
+		Time("expected", expected).
+		Time("wall", wall).
+		Msg("System clock jumped, timestamps follow the new time")
+	c.anchorWall, c.anchorMono = wall, mono
+	return wall, c.seq, jump
+}
@@ -0,0 +1,60 @@
+package network
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClockStamp(t *testing.T) {
+	c := NewClock(context.Background())
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var elapsed, skew time.Duration // skew offsets the wall clock, like a clock change
+	c.Wall = func() time.Time { return start.Add(elapsed + skew) }
+	c.Monotonic = func() time.Duration { return elapsed }
+	c.Boot = nil
+
+	first, seq1, _ := c.Stamp()
+	elapsed, skew = time.Second, time.Second // Slewed less than maxClockDrift, ignored
+	second, seq2, jump := c.Stamp()
+	if jump != 0 || second.Sub(first) != time.Second || seq2 != seq1+1 {
+		t.Errorf("drift: got %v after %v, jump %v, seq %d", second, first, jump, seq2)
+	}
+
+	// The clock is set back an hour
+	elapsed, skew = 2*time.Second, -time.Hour
+	third, _, jump := c.Stamp()
+	if jump != -time.Hour || !third.Equal(start.Add(2*time.Second-time.Hour)) {
+		t.Errorf("jump: got %v, jump %v", third, jump)
+	}
+
+	// Later stamps follow the corrected clock without reporting it again
+	elapsed = 3 * time.Second
+	fourth, seq4, jump := c.Stamp()
+	if jump != 0 || fourth.Sub(third) != time.Second || seq4 != 4 {
+		t.Errorf("after jump: got %v, jump %v, seq %d", fourth, jump, seq4)
+	}
+}
+
+func TestClockStampSuspend(t *testing.T) {
+	c := NewClock(context.Background())
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var elapsed, asleep, skew time.Duration // The monotonic clock misses asleep
+	c.Wall = func() time.Time { return start.Add(elapsed + asleep + skew) }
+	c.Monotonic = func() time.Duration { return elapsed }
+	c.Boot = func() time.Duration { return elapsed + asleep }
+
+	c.Stamp()
+	// The laptop sleeps for an hour, then tests again a second after resuming
+	elapsed, asleep = time.Second, time.Hour
+	ts, _, jump := c.Stamp()
+	if jump != 0 || !ts.Equal(start.Add(time.Hour+time.Second)) {
+		t.Errorf("resume: got %v, jump %v", ts, jump)
+	}
+
+	// A clock change is still reported after a sleep
+	elapsed, asleep, skew = 2*time.Second, 2*time.Hour, 30*time.Minute
+	if _, _, jump := c.Stamp(); jump != 30*time.Minute {
+		t.Errorf("Expected a 30m jump after sleeping, got %v", jump)
+	}
+}
@@ -0,0 +1,58 @@
+package network
+
+import (
+	"context"
+	"maps"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// TagConfirm is added to failed results of endpoints with a confirmation
+// probe: "up" when the probe succeeded, so the service is degraded but the
+// host is reachable, and "down" when the probe failed too
+const TagConfirm = "confirm"
+
+// Confirmation probe outcomes recorded in TagConfirm
+const (
+	ConfirmUp   = "up"
+	ConfirmDown = "down"
+)
+
+// confirm runs an endpoint's confirmation probe when its test fails, so a
+// failing HTTP check on a host that still accepts TCP connections isn't
+// reported as the host being down. The probe's own result isn't stored.
+func confirm() Middleware {
+	return func(next RunFunc) RunFunc {
+		return func(ctx context.Context, ep models.Endpoint) (models.TestResult, error) {
+			result, err := next(ctx, ep)
+			if ep.Confirm == nil || result.St == models.TestStatusSuccess || result.St == models.TestStatusCancelled {
+				return result, err
+			}
+
+			timeout := ep.Confirm.Timeout
+			if timeout <= 0 {
+				timeout = ep.Timeout
+			}
+			probe, _ := next(ctx, models.Endpoint{
+				Name:    ep.Name,
+				Type:    ep.Confirm.Type,
+				Address: ep.Confirm.Address,
+				Timeout: timeout,
+			})
+			if probe.St == models.TestStatusCancelled {
+				return result, err
+			}
+
+			outcome := ConfirmDown
+			if probe.St == models.TestStatusSuccess {
+				outcome = ConfirmUp
+			}
+			result.Tags = maps.Clone(result.Tags)
+			if result.Tags == nil {
+				result.Tags = make(map[string]string, 1)
+			}
+			result.Tags[TagConfirm] = outcome
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,543 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// DNS endpoints query a resolver for a name. The address scheme selects the
+// transport and the fragment the name to query:
+//
+//	udp://8.8.8.8#example.com                      plain DNS (also the default without a scheme)
+//	tcp://8.8.8.8:53#example.com                   DNS over TCP
+//	tls://1.1.1.1#example.com                      DNS over TLS (RFC 7858)
+//	https://cloudflare-dns.com/dns-query#example.com  DNS over HTTPS (RFC 8484)
+//
+// The record type, expected answers and DNSSEC validation are set with the
+// endpoint's DNS options.
+func init() {
+	register(Protocol{
+		Type:             models.TypeDNS,
+		DefaultTimeoutMs: 2000,
+		Validate: func(address string) error {
+			_, err := parseDNSAddress(address)
+			return err
+		},
+		Run: func(ctx context.Context, ep models.Endpoint, timeout time.Duration) (Measurement, error) {
+			return checkDNS(ctx, ep.Address, ep.DNS, timeout)
+		},
+	})
+}
+
+// Tags of DNS results. The latency covers both times, which tell a slow
+// connection to a DoT or DoH resolver apart from a slow resolution.
+const (
+	TagConnectMs = "connect_ms" // Time to connect to the resolver or TLS server, absent for DNS over UDP
+	TagQueryMs   = "query_ms"   // Time from sending the query to the answer
+	TagAnswers   = "answers"    // Comma separated answers of the queried type
+)
+
+const (
+	dnsTypeA     = 1
+	dnsTypeNS    = 2
+	dnsTypeCNAME = 5
+	dnsTypeSOA   = 6
+	dnsTypePTR   = 12
+	dnsTypeMX    = 15
+	dnsTypeTXT   = 16
+	dnsTypeAAAA  = 28
+	dnsTypeOPT   = 41
+	dnsClassIN   = 1
+
+	dnsFlagAD = 0x0020 // Authentic data, set by validating resolvers
+
+	dnsMessageMediaType = "application/dns-message"
+)
+
+// dnsRecordTypes are the record types DNS endpoints can query
+var dnsRecordTypes = map[string]uint16{
+	"A":     dnsTypeA,
+	"AAAA":  dnsTypeAAAA,
+	"CNAME": dnsTypeCNAME,
+	"MX":    dnsTypeMX,
+	"NS":    dnsTypeNS,
+	"PTR":   dnsTypePTR,
+	"SOA":   dnsTypeSOA,
+	"TXT":   dnsTypeTXT,
+}
+
+var dnsRcodes = map[int]string{
+	1: "FORMERR",
+	2: "SERVFAIL",
+	3: "NXDOMAIN",
+	4: "NOTIMP",
+	5: "REFUSED",
+}
+
+// DNSResponseError is returned when the resolver answers but the answer is
+// not usable
+type DNSResponseError struct {
+	Reason string
+}
+
+func (e *DNSResponseError) Error() string {
+	return "dns: " + e.Reason
+}
+
+// ValidateDNSOptions checks the options of a DNS endpoint
+func ValidateDNSOptions(o *models.DNSOptions) error {
+	if o == nil {
+		return nil
+	}
+	qtype, err := dnsRecordType(o.RecordType)
+	if err != nil {
+		return err
+	}
+	for _, v := range o.Expect {
+		if qtype != dnsTypeA && qtype != dnsTypeAAAA {
+			continue
+		}
+		if addr, err := netip.ParseAddr(v); err != nil || addr.Is4() != (qtype == dnsTypeA) {
+			return fmt.Errorf("expected answer %q is not an address of an %s record", v, strings.ToUpper(o.RecordType))
+		}
+	}
+	return nil
+}
+
+func dnsRecordType(name string) (uint16, error) {
+	if name == "" {
+		return dnsTypeA, nil
+	}
+	qtype, ok := dnsRecordTypes[strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("unsupported record type %q", name)
+	}
+	return qtype, nil
+}
+
+type dnsTarget struct {
+	transport string // udp, tcp, tls or https
+	server    string // host:port, or the URL for https
+	name      string
+}
+
+func parseDNSAddress(address string) (dnsTarget, error) {
+	if !strings.Contains(address, "://") {
+		address = "udp://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return dnsTarget{}, err
+	}
+	if u.Host == "" {
+		return dnsTarget{}, errors.New("resolver address is required")
+	}
+	name := strings.TrimSuffix(u.Fragment, ".")
+	if name == "" {
+		return dnsTarget{}, errors.New("name to query is required after '#', e.g. udp://8.8.8.8#example.com")
+	}
+
+	t := dnsTarget{transport: u.Scheme, name: name}
+	defaultPort := "53"
+	switch u.Scheme {
+	case "udp", "tcp":
+	case "tls":
+		defaultPort = "853"
+	case "https":
+		u.Fragment = ""
+		t.server = u.String()
+		return t, nil
+	default:
+		return dnsTarget{}, fmt.Errorf("unsupported DNS transport %q", u.Scheme)
+	}
+	t.server = u.Host
+	if u.Port() == "" {
+		t.server = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	return t, nil
+}
+
+func checkDNS(ctx context.Context, address string, opts *models.DNSOptions, timeout time.Duration) (Measurement, error) {
+	target, err := parseDNSAddress(address)
+	if err != nil {
+		return Measurement{}, err
+	}
+	var o models.DNSOptions
+	if opts != nil {
+		o = *opts
+	}
+	qtype, err := dnsRecordType(o.RecordType)
+	if err != nil {
+		return Measurement{}, err
+	}
+	query, id, err := buildDNSQuery(target.name, qtype)
+	if err != nil {
+		return Measurement{}, err
+	}
+	if o.DNSSEC {
+		query = withDNSSEC(query)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	var resp []byte
+	var connect time.Duration
+	switch target.transport {
+	case "udp":
+		resp, err = exchangeDNSUDP(ctx, target.server, query)
+	case "tcp", "tls":
+		resp, connect, err = exchangeDNSStream(ctx, target, query)
+	case "https":
+		resp, connect, err = exchangeDoH(ctx, target.server, query)
+	}
+	m := Measurement{Latency: time.Since(start), Tags: make(map[string]string)}
+	if connect > 0 {
+		m.Tags[TagConnectMs] = formatMs(connect)
+	}
+	m.Tags[TagQueryMs] = formatMs(m.Latency - connect)
+	if err != nil {
+		return m, err
+	}
+
+	answers, err := parseDNSResponse(resp, id)
+	if err != nil {
+		return m, err
+	}
+	if o.DNSSEC && binary.BigEndian.Uint16(resp[2:])&dnsFlagAD == 0 {
+		return m, &DNSResponseError{Reason: "answer not authenticated by DNSSEC"}
+	}
+	var values []string
+	for _, a := range answers {
+		if a.Type == qtype && a.Data != "" {
+			values = append(values, normalizeDNSValue(a.Data, qtype))
+		}
+	}
+	if len(values) > 0 {
+		m.Tags[TagAnswers] = strings.Join(values, ",")
+	}
+	var missing []string
+	for _, e := range o.Expect {
+		if !slices.Contains(values, normalizeDNSValue(e, qtype)) {
+			missing = append(missing, e)
+		}
+	}
+	if len(missing) > 0 {
+		return m, fmt.Errorf("%w: %s not among the answers", ErrUnexpectedResponse, strings.Join(missing, ", "))
+	}
+	return m, nil
+}
+
+// formatMs formats a duration in milliseconds with microsecond precision
+func formatMs(d time.Duration) string {
+	return strconv.FormatFloat(float64(d.Microseconds())/1000, 'f', 3, 64)
+}
+
+// normalizeDNSValue puts an answer or an expected answer in a form that
+// compares equal however it was written: addresses in canonical form and
+// host names in lower case without the final dot. TXT records are compared
+// as they are.
+func normalizeDNSValue(v string, qtype uint16) string {
+	switch qtype {
+	case dnsTypeTXT:
+		return v
+	case dnsTypeA, dnsTypeAAAA:
+		if addr, err := netip.ParseAddr(v); err == nil {
+			return addr.String()
+		}
+		return v
+	}
+	return strings.TrimSuffix(strings.ToLower(v), ".")
+}
+
+func exchangeDNSUDP(ctx context.Context, server string, query []byte) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialConn(ctx, &dialer, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// exchangeDNSStream sends the query over TCP or TLS, where messages carry a
+// two byte length prefix. It also returns the time taken to connect.
+func exchangeDNSStream(ctx context.Context, target dnsTarget, query []byte) ([]byte, time.Duration, error) {
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialConn(ctx, &dialer, "tcp", target.server)
+	if err != nil {
+		return nil, time.Since(start), err
+	}
+	defer conn.Close()
+	if target.transport == "tls" {
+		host, _, _ := net.SplitHostPort(target.server)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, time.Since(start), err
+		}
+		conn = tlsConn
+	}
+	connect := time.Since(start)
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(msg, query...)); err != nil {
+		return nil, connect, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, connect, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, connect, err
+	}
+	return resp, connect, nil
+}
+
+// exchangeDoH posts the query to a DoH server. It also returns the time
+// until a connection was ready, which is short when one was reused.
+func exchangeDoH(ctx context.Context, server string, query []byte) ([]byte, time.Duration, error) {
+	start := time.Now()
+	var connect time.Duration
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) { connect = time.Since(start) },
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server, bytes.NewReader(query))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", dnsMessageMediaType)
+	req.Header.Set("Accept", dnsMessageMediaType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, connect, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, connect, &HTTPStatusError{Code: resp.StatusCode}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	// Only the DNS messages are counted, as the default client's
+	// connections can't be
+	if u := usageFrom(ctx); u != nil {
+		u.sent.Add(int64(len(query)))
+		u.received.Add(int64(len(body)))
+	}
+	return body, connect, err
+}
+
+// buildDNSQuery returns a recursive query for name and its message ID
+func buildDNSQuery(name string, qtype uint16) ([]byte, uint16, error) {
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return nil, 0, err
+	}
+	id := binary.BigEndian.Uint16(idBytes[:])
+
+	msg := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], 0x0100) // RD
+	binary.BigEndian.PutUint16(msg[4:], 1)      // QDCOUNT
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, 0, fmt.Errorf("invalid DNS name %q", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, dnsClassIN)
+	return msg, id, nil
+}
+
+// withDNSSEC asks the resolver to validate the answer: the AD bit asks for
+// its verdict (RFC 6840) and the DO bit of an EDNS0 OPT record for the
+// DNSSEC records it is based on
+func withDNSSEC(query []byte) []byte {
+	binary.BigEndian.PutUint16(query[2:], binary.BigEndian.Uint16(query[2:])|dnsFlagAD)
+	binary.BigEndian.PutUint16(query[10:], 1) // ARCOUNT
+	query = append(query, 0)                  // Root name
+	query = binary.BigEndian.AppendUint16(query, dnsTypeOPT)
+	query = binary.BigEndian.AppendUint16(query, 4096)   // UDP payload size
+	query = binary.BigEndian.AppendUint32(query, 0x8000) // DO bit
+	return binary.BigEndian.AppendUint16(query, 0)       // No options
+}
+
+type dnsAnswer struct {
+	Type uint16
+	// Data is the address of A and AAAA records, the host name of CNAME,
+	// MX, NS and PTR records, the primary name server of SOA records and
+	// the text of TXT records. Empty for other types.
+	Data string
+}
+
+// parseDNSResponse validates the response to the query with the given ID and
+// returns its answers
+func parseDNSResponse(msg []byte, id uint16) ([]dnsAnswer, error) {
+	if len(msg) < 12 {
+		return nil, &DNSResponseError{Reason: "response too short"}
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != id {
+		return nil, &DNSResponseError{Reason: "response ID does not match the query"}
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if flags&0x8000 == 0 {
+		return nil, &DNSResponseError{Reason: "message is not a response"}
+	}
+	if rcode := int(flags & 0xF); rcode != 0 {
+		name, ok := dnsRcodes[rcode]
+		if !ok {
+			name = fmt.Sprintf("RCODE %d", rcode)
+		}
+		return nil, &DNSResponseError{Reason: name}
+	}
+
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+	offset := 12
+	var err error
+	for range qdcount {
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return nil, err
+		}
+		offset += 4
+	}
+
+	answers := make([]dnsAnswer, 0, ancount)
+	for range ancount {
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return nil, err
+		}
+		if offset+10 > len(msg) {
+			return nil, &DNSResponseError{Reason: "truncated answer"}
+		}
+		a := dnsAnswer{Type: binary.BigEndian.Uint16(msg[offset:])}
+		rdlen := int(binary.BigEndian.Uint16(msg[offset+8:]))
+		offset += 10
+		if offset+rdlen > len(msg) {
+			return nil, &DNSResponseError{Reason: "truncated answer data"}
+		}
+		rdata := msg[offset : offset+rdlen]
+		switch {
+		case (a.Type == dnsTypeA && rdlen == 4) || (a.Type == dnsTypeAAAA && rdlen == 16):
+			a.Data = net.IP(rdata).String()
+		case a.Type == dnsTypeCNAME, a.Type == dnsTypeNS, a.Type == dnsTypePTR, a.Type == dnsTypeSOA:
+			// Names may point anywhere in the message
+			a.Data, err = readDNSName(msg, offset)
+		case a.Type == dnsTypeMX && rdlen > 2:
+			a.Data, err = readDNSName(msg, offset+2) // After the preference
+		case a.Type == dnsTypeTXT:
+			a.Data, err = readDNSText(rdata)
+		}
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, a)
+		offset += rdlen
+	}
+	if len(answers) == 0 {
+		return nil, &DNSResponseError{Reason: "no answers"}
+	}
+	return answers, nil
+}
+
+func skipDNSName(msg []byte, offset int) (int, error) {
+	for {
+		if offset >= len(msg) {
+			return 0, &DNSResponseError{Reason: "truncated name"}
+		}
+		l := int(msg[offset])
+		switch {
+		case l == 0:
+			return offset + 1, nil
+		case l&0xC0 == 0xC0: // Compression pointer ends the name
+			return offset + 2, nil
+		default:
+			offset += l + 1
+		}
+	}
+}
+
+// readDNSName reads the possibly compressed name at offset
+func readDNSName(msg []byte, offset int) (string, error) {
+	var labels []string
+	for jumps := 0; ; {
+		if offset >= len(msg) {
+			return "", &DNSResponseError{Reason: "truncated name"}
+		}
+		l := int(msg[offset])
+		switch {
+		case l == 0:
+			return strings.Join(labels, "."), nil
+		case l&0xC0 == 0xC0:
+			if offset+1 >= len(msg) {
+				return "", &DNSResponseError{Reason: "truncated name"}
+			}
+			if jumps++; jumps > 32 {
+				return "", &DNSResponseError{Reason: "name compression loop"}
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3FFF)
+		default:
+			if offset+1+l > len(msg) {
+				return "", &DNSResponseError{Reason: "truncated name"}
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+l]))
+			offset += l + 1
+		}
+	}
+}
+
+// readDNSText joins the character strings of a TXT record
+func readDNSText(rdata []byte) (string, error) {
+	var text strings.Builder
+	for len(rdata) > 0 {
+		l := int(rdata[0])
+		if 1+l > len(rdata) {
+			return "", &DNSResponseError{Reason: "truncated text"}
+		}
+		text.Write(rdata[1 : 1+l])
+		rdata = rdata[1+l:]
+	}
+	return text.String(), nil
+}
@@ -0,0 +1,252 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// dnsReply answers query with a single A record, or with rcode when non-zero
+func dnsReply(query []byte, rcode uint16) []byte {
+	resp := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(resp[2:], 0x8180|rcode) // QR, RD, RA
+	if rcode != 0 {
+		return resp
+	}
+	binary.BigEndian.PutUint16(resp[6:], 1) // ANCOUNT
+	resp = append(resp, 0xC0, 12)           // Name: pointer to the question
+	resp = binary.BigEndian.AppendUint16(resp, dnsTypeA)
+	resp = binary.BigEndian.AppendUint16(resp, dnsClassIN)
+	resp = binary.BigEndian.AppendUint32(resp, 300)
+	resp = binary.BigEndian.AppendUint16(resp, 4)
+	return append(resp, 192, 0, 2, 1)
+}
+
+func TestParseDNSAddress(t *testing.T) {
+	tests := []struct {
+		address   string
+		transport string
+		server    string
+		valid     bool
+	}{
+		{"8.8.8.8#example.com", "udp", "8.8.8.8:53", true},
+		{"tcp://8.8.8.8:5353#example.com", "tcp", "8.8.8.8:5353", true},
+		{"tls://1.1.1.1#example.com", "tls", "1.1.1.1:853", true},
+		{"https://dns.example/dns-query#example.com", "https", "https://dns.example/dns-query", true},
+		{"udp://8.8.8.8", "", "", false},
+		{"quic://8.8.8.8#example.com", "", "", false},
+	}
+	for _, tt := range tests {
+		target, err := parseDNSAddress(tt.address)
+		if (err == nil) != tt.valid {
+			t.Errorf("parseDNSAddress(%q) error = %v, want valid=%v", tt.address, err, tt.valid)
+			continue
+		}
+		if tt.valid && (target.transport != tt.transport || target.server != tt.server || target.name != "example.com") {
+			t.Errorf("parseDNSAddress(%q) = %+v", tt.address, target)
+		}
+	}
+}
+
+func TestCheckDNSUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			rcode := uint16(0)
+			if n > 13 && string(buf[13:20]) == "missing" {
+				rcode = 3
+			}
+			pc.WriteTo(dnsReply(buf[:n], rcode), addr)
+		}
+	}()
+
+	if _, err := checkDNS(context.Background(), "udp://"+pc.LocalAddr().String()+"#example.com", nil, time.Second); err != nil {
+		t.Errorf("DNS query failed: %v", err)
+	}
+
+	_, err = checkDNS(context.Background(), "udp://"+pc.LocalAddr().String()+"#missing.example.com", nil, time.Second)
+	var respErr *DNSResponseError
+	if !errors.As(err, &respErr) || respErr.Reason != "NXDOMAIN" {
+		t.Errorf("Expected NXDOMAIN, got %v", err)
+	}
+}
+
+func TestCheckDoH(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dnsMessageMediaType {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		query, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", dnsMessageMediaType)
+		w.Write(dnsReply(query, 0))
+	}))
+	defer srv.Close()
+
+	// Trust the test server's certificate
+	orig := http.DefaultClient
+	http.DefaultClient = srv.Client()
+	defer func() { http.DefaultClient = orig }()
+
+	if _, err := checkDNS(context.Background(), srv.URL+"/dns-query#example.com", nil, time.Second); err != nil {
+		t.Errorf("DoH query failed: %v", err)
+	}
+}
+
+func TestParseDNSResponseRejectsMismatchedID(t *testing.T) {
+	query, id, err := buildDNSQuery("example.com", dnsTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answers, err := parseDNSResponse(dnsReply(query, 0), id)
+	if err != nil || len(answers) != 1 || answers[0].Data != "192.0.2.1" {
+		t.Errorf("Unexpected answers: %+v, %v", answers, err)
+	}
+	if _, err := parseDNSResponse(dnsReply(query, 0), id+1); err == nil {
+		t.Errorf("Expected error for mismatched ID")
+	}
+}
+
+// dnsRecordsReply answers query with the given flags and answer records,
+// dropping the query's additional records
+func dnsRecordsReply(query []byte, flags uint16, records ...[]byte) []byte {
+	end, _ := skipDNSName(query, 12)
+	resp := append([]byte(nil), query[:end+4]...)
+	binary.BigEndian.PutUint16(resp[2:], 0x8180|flags)
+	binary.BigEndian.PutUint16(resp[6:], uint16(len(records)))
+	binary.BigEndian.PutUint16(resp[10:], 0) // ARCOUNT
+	for _, r := range records {
+		resp = append(resp, r...)
+	}
+	return resp
+}
+
+// dnsRecord returns an answer record for the question's name
+func dnsRecord(qtype uint16, rdata []byte) []byte {
+	r := []byte{0xC0, 12}
+	r = binary.BigEndian.AppendUint16(r, qtype)
+	r = binary.BigEndian.AppendUint16(r, dnsClassIN)
+	r = binary.BigEndian.AppendUint32(r, 300)
+	r = binary.BigEndian.AppendUint16(r, uint16(len(rdata)))
+	return append(r, rdata...)
+}
+
+func TestCheckDNSOptions(t *testing.T) {
+	mx := append([]byte{0, 10}, 4, 'm', 'a', 'i', 'l', 0xC0, 12) // 10 mail.<question>
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			var length [2]byte
+			io.ReadFull(conn, length[:])
+			query := make([]byte, binary.BigEndian.Uint16(length[:]))
+			io.ReadFull(conn, query)
+
+			var resp []byte
+			qtype := binary.BigEndian.Uint16(query[len(query)-4:])
+			if binary.BigEndian.Uint16(query[10:]) > 0 {
+				// DNSSEC queries end with the OPT record; only the signed
+				// zone validates
+				end, _ := skipDNSName(query, 12)
+				qtype = binary.BigEndian.Uint16(query[end:])
+				flags := uint16(0)
+				if strings.Contains(string(query[12:end]), "signed") {
+					flags = dnsFlagAD
+				}
+				resp = dnsRecordsReply(query, flags, dnsRecord(dnsTypeA, []byte{192, 0, 2, 1}))
+			} else if qtype == dnsTypeMX {
+				resp = dnsRecordsReply(query, 0, dnsRecord(dnsTypeMX, mx))
+			} else {
+				resp = dnsRecordsReply(query, 0,
+					dnsRecord(dnsTypeAAAA, net.ParseIP("2001:db8::1")),
+					dnsRecord(dnsTypeTXT, []byte("\x05hello\x05world")))
+			}
+			conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+			conn.Close()
+		}
+	}()
+	server := "tcp://" + ln.Addr().String()
+
+	tests := []struct {
+		name    string
+		domain  string
+		opts    models.DNSOptions
+		answers string
+		err     func(error) bool
+	}{
+		{"MX", "Example.com", models.DNSOptions{RecordType: "mx", Expect: []string{"MAIL.example.com."}}, "mail.example.com", nil},
+		{"AAAA", "example.com", models.DNSOptions{RecordType: "AAAA", Expect: []string{"2001:db8:0::1"}}, "2001:db8::1", nil},
+		{"unexpected answer", "example.com", models.DNSOptions{RecordType: "AAAA", Expect: []string{"2001:db8::2"}}, "2001:db8::1",
+			func(err error) bool { return errors.Is(err, ErrUnexpectedResponse) }},
+		{"TXT", "example.com", models.DNSOptions{RecordType: "TXT", Expect: []string{"helloworld"}}, "helloworld", nil},
+		{"DNSSEC", "signed.example.com", models.DNSOptions{DNSSEC: true}, "192.0.2.1", nil},
+		{"DNSSEC not validated", "example.com", models.DNSOptions{DNSSEC: true}, "",
+			func(err error) bool { var e *DNSResponseError; return errors.As(err, &e) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := checkDNS(context.Background(), server+"#"+tt.domain, &tt.opts, time.Second)
+			if tt.err == nil && err != nil {
+				t.Fatalf("DNS query failed: %v", err)
+			}
+			if tt.err != nil && !tt.err(err) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if m.Tags[TagAnswers] != tt.answers {
+				t.Errorf("Expected answers %q, got %q", tt.answers, m.Tags[TagAnswers])
+			}
+			if m.Tags[TagConnectMs] == "" || m.Tags[TagQueryMs] == "" {
+				t.Errorf("Expected connection and query times, got %v", m.Tags)
+			}
+		})
+	}
+}
+
+func TestValidateDNSOptions(t *testing.T) {
+	valid := []*models.DNSOptions{
+		nil,
+		{},
+		{RecordType: "aaaa", Expect: []string{"2001:db8::1"}},
+		{RecordType: "MX", Expect: []string{"mail.example.com"}},
+	}
+	for _, o := range valid {
+		if err := ValidateDNSOptions(o); err != nil {
+			t.Errorf("ValidateDNSOptions(%+v) failed: %v", o, err)
+		}
+	}
+	invalid := []*models.DNSOptions{
+		{RecordType: "SRV"},
+		{Expect: []string{"example.com"}},
+		{RecordType: "AAAA", Expect: []string{"192.0.2.1"}},
+	}
+	for _, o := range invalid {
+		if err := ValidateDNSOptions(o); err == nil {
+			t.Errorf("ValidateDNSOptions(%+v) should fail", o)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// HTTPStatusError is returned by HTTP tests when the server answers with an
+// error status
+type HTTPStatusError struct {
+	Code int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("http status %d", e.Code)
+}
+
+// ICMPError is returned by ICMP tests when a router or the host answered the
+// echo request with an ICMP error instead of a reply
+type ICMPError struct {
+	Type int
+	Code int
+	From string // Address of the router or host that sent the error
+}
+
+// Destination unreachable codes meaning the probe was filtered (RFC 1812)
+const (
+	icmpCodeNetProhibited   = 9
+	icmpCodeHostProhibited  = 10
+	icmpCodeAdminProhibited = 13
+)
+
+func (e *ICMPError) Error() string {
+	switch {
+	case e.Type == icmpTimeExceeded:
+		return fmt.Sprintf("TTL exceeded in transit at %s", e.From)
+	case e.prohibited():
+		return fmt.Sprintf("administratively prohibited by %s (code %d)", e.From, e.Code)
+	default:
+		return fmt.Sprintf("destination unreachable from %s (code %d)", e.From, e.Code)
+	}
+}
+
+func (e *ICMPError) prohibited() bool {
+	return e.Type == icmpDestUnreachable &&
+		(e.Code == icmpCodeNetProhibited || e.Code == icmpCodeHostProhibited || e.Code == icmpCodeAdminProhibited)
+}
+
+// Kind returns the failure class of the ICMP error
+func (e *ICMPError) Kind() models.ErrorKind {
+	switch {
+	case e.Type == icmpTimeExceeded:
+		return models.ErrorKindTTLExceeded
+	case e.prohibited():
+		return models.ErrorKindProhibited
+	default:
+		return models.ErrorKindUnreachable
+	}
+}
+
+// ErrPacketLoss is returned when none of the probe packets were answered
+var ErrPacketLoss = errors.New("packet loss")
+
+// ErrUnexpectedResponse is returned when a service answers with content other
+// than what the endpoint expects
+var ErrUnexpectedResponse = errors.New("unexpected response")
@@ -0,0 +1,177 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// Result tags of endpoints tested on all their addresses
+const (
+	// TagAddrPrefix is followed by each address tested, e.g. "addr:192.0.2.1".
+	// The value is the latency in milliseconds, or the error kind when the
+	// address failed.
+	TagAddrPrefix = "addr:"
+	// TagAddrsUp records how many addresses passed, e.g. "3/4"
+	TagAddrsUp = "addrs_up"
+)
+
+// lookupIPAddr resolves the host of endpoints tested on all their addresses.
+// Replaced in tests.
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+type pinnedIPKey struct{}
+
+// WithPinnedIP returns a context that makes protocols supporting
+// AllAddresses connect to ip instead of resolving the endpoint's host
+func WithPinnedIP(ctx context.Context, ip net.IP) context.Context {
+	return context.WithValue(ctx, pinnedIPKey{}, ip)
+}
+
+// PinnedIP returns the IP pinned with WithPinnedIP, or nil
+func PinnedIP(ctx context.Context) net.IP {
+	ip, _ := ctx.Value(pinnedIPKey{}).(net.IP)
+	return ip
+}
+
+// pinHost returns the IP pinned in ctx, or host when none is
+func pinHost(ctx context.Context, host string) string {
+	if ip := PinnedIP(ctx); ip != nil {
+		return ip.String()
+	}
+	return host
+}
+
+// pinAddress replaces the host of a host:port address with the IP pinned in
+// ctx, if any
+func pinAddress(ctx context.Context, address string) string {
+	ip := PinnedIP(ctx)
+	if ip == nil {
+		return address
+	}
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return net.JoinHostPort(ip.String(), port)
+}
+
+// ValidateAllAddresses checks that the endpoint's protocol can test each
+// address its host resolves to, when the endpoint asks for it
+func (r *Registry) ValidateAllAddresses(ep models.Endpoint) error {
+	if !ep.AllAddresses {
+		return nil
+	}
+	if p, ok := r.Lookup(ep.Type); !ok || !p.AllAddresses {
+		return fmt.Errorf("%s endpoints can't be tested on all addresses", ep.Type)
+	}
+	return nil
+}
+
+// expandAddresses tests endpoints with AllAddresses set on every address
+// their host resolves to, in parallel, so a single broken anycast or CDN
+// node isn't hidden by whichever address the resolver happened to pick. The
+// combined result fails when any address fails and carries the failing
+// address's status; every address's outcome is tagged.
+func expandAddresses(protocols *Registry) Middleware {
+	return func(next RunFunc) RunFunc {
+		return func(ctx context.Context, ep models.Endpoint) (models.TestResult, error) {
+			if !ep.AllAddresses {
+				return next(ctx, ep)
+			}
+			if p, ok := protocols.Lookup(ep.Type); !ok || !p.AllAddresses {
+				return next(ctx, ep)
+			}
+			host := Host(ep.Address)
+			if net.ParseIP(host) != nil {
+				return next(ctx, ep)
+			}
+			// A failed lookup fails the regular test the same way
+			addrs, err := lookupIPAddr(ctx, host)
+			if err != nil || len(addrs) < 2 {
+				return next(ctx, ep)
+			}
+
+			results := make([]models.TestResult, len(addrs))
+			errs := make([]error, len(addrs))
+			var wg sync.WaitGroup
+			for i, addr := range addrs {
+				wg.Go(func() {
+					results[i], errs[i] = next(WithPinnedIP(ctx, addr.IP), ep)
+				})
+			}
+			wg.Wait()
+			return combineAddresses(addrs, results, errs)
+		}
+	}
+}
+
+// combineAddresses merges the results of testing each address. The result
+// reported is the first failed one, or the slowest when all passed, with the
+// traffic of all of them.
+func combineAddresses(addrs []net.IPAddr, results []models.TestResult, errs []error) (models.TestResult, error) {
+	tags := make(map[string]string, len(addrs)+1)
+	base := -1
+	up := 0
+	var failed []string
+	var firstErr error
+	for i, r := range results {
+		ip := addrs[i].IP.String()
+		if r.St == models.TestStatusSuccess {
+			up++
+			tags[TagAddrPrefix+ip] = strconv.FormatInt(r.Ms, 10)
+			if base < 0 || (results[base].St == models.TestStatusSuccess && r.Us > results[base].Us) {
+				base = i
+			}
+			continue
+		}
+		tags[TagAddrPrefix+ip] = string(r.Ek)
+		if errs[i] != nil {
+			failed = append(failed, ip+": "+errs[i].Error())
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+		}
+		if base < 0 || results[base].St == models.TestStatusSuccess {
+			base = i
+		}
+	}
+	tags[TagAddrsUp] = strconv.Itoa(up) + "/" + strconv.Itoa(len(addrs))
+
+	result := results[base]
+	result.Tx, result.Rx = 0, 0
+	for _, r := range results {
+		result.Tx += r.Tx
+		result.Rx += r.Rx
+	}
+	result.Tags = maps.Clone(result.Tags)
+	if result.Tags == nil {
+		result.Tags = tags
+	} else {
+		maps.Copy(result.Tags, tags)
+	}
+	if len(failed) == 0 {
+		return result, errs[base]
+	}
+	return result, &addressesError{failed: len(addrs) - up, total: len(addrs), msgs: failed, first: firstErr}
+}
+
+// addressesError reports the addresses that failed, unwrapping to the first
+// failure so it is classified like a single-address test
+type addressesError struct {
+	failed, total int
+	msgs          []string
+	first         error
+}
+
+func (e *addressesError) Error() string {
+	return fmt.Sprintf("%d of %d addresses failed: %s", e.failed, e.total, strings.Join(e.msgs, "; "))
+}
+
+func (e *addressesError) Unwrap() error { return e.first }
@@ -0,0 +1,109 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// Result tags of Happy Eyeballs tests
+const (
+	TagWinner   = "winner"    // "ipv4" or "ipv6"
+	TagMarginMs = "margin_ms" // How much faster the winner connected
+	TagIPv4Ms   = "ipv4_ms"
+	TagIPv6Ms   = "ipv6_ms"
+	TagIPv4Err  = "ipv4_error"
+	TagIPv6Err  = "ipv6_error"
+)
+
+func init() {
+	register(Protocol{
+		Type:             models.TypeHappyEyeballs,
+		DefaultTimeoutMs: 3000,
+		Validate:         validateHostPort,
+		Run: func(ctx context.Context, ep models.Endpoint, timeout time.Duration) (Measurement, error) {
+			return checkHappyEyeballs(ctx, ep.Address, timeout)
+		},
+	})
+}
+
+type familyResult struct {
+	latency time.Duration
+	err     error
+}
+
+// checkHappyEyeballs connects to host:port over IPv4 and IPv6 at the same
+// time, without the head start RFC 8305 gives IPv6, so the connect times can
+// be compared directly. The latency reported is the winner's.
+func checkHappyEyeballs(ctx context.Context, address string, timeout time.Duration) (Measurement, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	v4 := make(chan familyResult, 1)
+	v6 := make(chan familyResult, 1)
+	go func() { v4 <- dialFamily(ctx, "tcp4", "ip4", host, port) }()
+	go func() { v6 <- dialFamily(ctx, "tcp6", "ip6", host, port) }()
+	r4, r6 := <-v4, <-v6
+
+	m := Measurement{Tags: make(map[string]string)}
+	if r4.err == nil {
+		m.Tags[TagIPv4Ms] = strconv.FormatInt(r4.latency.Milliseconds(), 10)
+	} else {
+		m.Tags[TagIPv4Err] = r4.err.Error()
+	}
+	if r6.err == nil {
+		m.Tags[TagIPv6Ms] = strconv.FormatInt(r6.latency.Milliseconds(), 10)
+	} else {
+		m.Tags[TagIPv6Err] = r6.err.Error()
+	}
+
+	switch {
+	case r4.err != nil && r6.err != nil:
+		m.Latency = max(r4.latency, r6.latency)
+		return m, fmt.Errorf("both address families failed: ipv4: %w; ipv6: %v", r4.err, r6.err)
+	case r6.err != nil || (r4.err == nil && r4.latency <= r6.latency):
+		m.Latency = r4.latency
+		m.Tags[TagWinner] = "ipv4"
+		if r6.err == nil {
+			m.Tags[TagMarginMs] = strconv.FormatInt((r6.latency - r4.latency).Milliseconds(), 10)
+		}
+	default:
+		m.Latency = r6.latency
+		m.Tags[TagWinner] = "ipv6"
+		if r4.err == nil {
+			m.Tags[TagMarginMs] = strconv.FormatInt((r4.latency - r6.latency).Milliseconds(), 10)
+		}
+	}
+	return m, nil
+}
+
+// dialFamily resolves host within one address family and connects to the
+// first address. The time includes resolution, as a browser would see it.
+func dialFamily(ctx context.Context, network, ipNetwork, host, port string) familyResult {
+	start := time.Now()
+	ips, err := net.DefaultResolver.LookupIP(ctx, ipNetwork, host)
+	if err != nil {
+		return familyResult{latency: time.Since(start), err: err}
+	}
+	if len(ips) == 0 {
+		return familyResult{latency: time.Since(start), err: errors.New("no addresses")}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialConn(ctx, &dialer, network, net.JoinHostPort(ips[0].String(), port))
+	if err != nil {
+		return familyResult{latency: time.Since(start), err: err}
+	}
+	conn.Close()
+	return familyResult{latency: time.Since(start)}
+}
@@ -0,0 +1,21 @@
+package network
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Host returns the host name or IP address an endpoint address points at,
+// whatever the protocol's address format
+func Host(address string) string {
+	if u, err := url.Parse(address); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	// Drop the query name of scheme-less DNS addresses
+	address, _, _ = strings.Cut(address, "#")
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
@@ -0,0 +1,302 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func init() {
+	register(Protocol{
+		Type:             models.TypeHTTP,
+		DefaultTimeoutMs: 5000,
+		AllAddresses:     true,
+		Validate:         validateHTTP,
+		Run:              checkHTTP,
+	})
+}
+
+// TagProto records the HTTP version of the response, e.g. "HTTP/2.0"
+const TagProto = "proto"
+
+// TagConn records whether a keep-alive HTTP test reused a pooled connection,
+// so warm and cold latencies aren't mixed up
+const TagConn = "conn"
+
+// Connection states recorded in TagConn
+const (
+	ConnWarm = "warm"
+	ConnCold = "cold"
+)
+
+// httpIdleTimeout closes pooled connections unused for this long. Servers
+// often close idle connections sooner, so endpoints tested less often than
+// their server's idle timeout always find a cold connection.
+const httpIdleTimeout = 15 * time.Minute
+
+// httpDrainLimit is how much of a response body keep-alive tests read so the
+// connection can go back to the pool. Larger bodies close the connection.
+const httpDrainLimit = 1 << 20
+
+// httpTransport is shared by tests that don't need a transport of their own,
+// like http.DefaultTransport but counting traffic towards each test's usage
+var httpTransport = newTransport("")
+
+// httpPool holds a transport per keep-alive endpoint address, HTTP version
+// and pinned IP, so endpoints and their addresses never share connections
+var httpPool sync.Map
+
+func pooledTransport(ctx context.Context, address, version string) *http.Transport {
+	key := version + " " + address
+	if ip := PinnedIP(ctx); ip != nil {
+		key += " " + ip.String()
+	}
+	if t, ok := httpPool.Load(key); ok {
+		return t.(*http.Transport)
+	}
+	t := newTransport(version)
+	t.MaxIdleConnsPerHost = 1
+	t.IdleConnTimeout = httpIdleTimeout
+	actual, _ := httpPool.LoadOrStore(key, t)
+	return actual.(*http.Transport)
+}
+
+// newTransport returns a transport like the default one, restricted to the
+// HTTP version if one is given, that connects to the IP pinned in the
+// request's context if there is one and counts traffic towards its usage
+func newTransport(version string) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dialConn(ctx, dialer, network, address)
+	}
+	switch version {
+	case models.HTTPVersion1:
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP1(true)
+	case models.HTTPVersion2:
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP2(true)
+		t.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return t
+}
+
+// ValidateHTTPOptions checks the options of an HTTP endpoint, if it has any
+func ValidateHTTPOptions(o *models.HTTPOptions) error {
+	if o == nil {
+		return nil
+	}
+	switch o.Version {
+	case "", models.HTTPVersion1, models.HTTPVersion2:
+	default:
+		return fmt.Errorf("unsupported HTTP version %q, use %s or %s", o.Version, models.HTTPVersion1, models.HTTPVersion2)
+	}
+	if err := validateHTTPStep(requestStep("", *o)); err != nil {
+		return err
+	}
+	for i, step := range o.Steps {
+		if err := validateHTTPStep(step); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+		u, err := url.Parse(step.Address)
+		if err != nil || step.Address == "" {
+			return fmt.Errorf("step %d: invalid address %q", i+1, step.Address)
+		}
+		if u.IsAbs() {
+			if err := validateHTTP(step.Address); err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// requestStep returns the endpoint's own request as a step
+func requestStep(address string, o models.HTTPOptions) models.HTTPStep {
+	return models.HTTPStep{Address: address, Method: o.Method, Body: o.Body, ContentType: o.ContentType, Form: o.Form}
+}
+
+func validateHTTPStep(s models.HTTPStep) error {
+	switch s.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		return fmt.Errorf("unsupported HTTP method %q", s.Method)
+	}
+	if s.Body != "" && len(s.Form) > 0 {
+		return fmt.Errorf("set either a body or a form, not both")
+	}
+	if (s.Body != "" || len(s.Form) > 0) && (s.Method == http.MethodGet || s.Method == http.MethodHead) {
+		return fmt.Errorf("%s requests can't have a body", s.Method)
+	}
+	return nil
+}
+
+// newHTTPRequest builds the request of a test step
+func newHTTPRequest(ctx context.Context, s models.HTTPStep) (*http.Request, error) {
+	method, contentType := s.Method, s.ContentType
+	var body io.Reader
+	switch {
+	case len(s.Form) > 0:
+		form := make(url.Values, len(s.Form))
+		for k, v := range s.Form {
+			form.Set(k, v)
+		}
+		body = strings.NewReader(form.Encode())
+		if contentType == "" {
+			contentType = "application/x-www-form-urlencoded"
+		}
+	case s.Body != "":
+		body = strings.NewReader(s.Body)
+	}
+	if method == "" {
+		method = http.MethodGet
+		if body != nil {
+			method = http.MethodPost
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.Address, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, nil
+}
+
+// httpSteps returns the requests of a test in order, the endpoint's own one
+// last, with step addresses resolved against the endpoint address
+func httpSteps(address string, opts models.HTTPOptions) ([]models.HTTPStep, error) {
+	steps := make([]models.HTTPStep, 0, len(opts.Steps)+1)
+	if len(opts.Steps) > 0 {
+		base, err := url.Parse(address)
+		if err != nil {
+			return nil, err
+		}
+		for _, step := range opts.Steps {
+			ref, err := url.Parse(step.Address)
+			if err != nil {
+				return nil, err
+			}
+			step.Address = base.ResolveReference(ref).String()
+			steps = append(steps, step)
+		}
+	}
+	return append(steps, requestStep(address, opts)), nil
+}
+
+// doHTTPStep makes one request of a test. The response is returned with its
+// body closed, even when its status is an error.
+func doHTTPStep(ctx context.Context, client *http.Client, step models.HTTPStep, drain bool) (*http.Response, error) {
+	req, err := newHTTPRequest(ctx, step)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if drain {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, httpDrainLimit))
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return resp, &HTTPStatusError{Code: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+func validateHTTP(address string) error {
+	u, err := url.Parse(address)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("address must start with http:// or https://")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("address has no host")
+	}
+	return nil
+}
+
+// checkHTTP measures the time to the response headers
+func checkHTTP(ctx context.Context, ep models.Endpoint, timeout time.Duration) (Measurement, error) {
+	start := time.Now()
+	client := http.Client{
+		Timeout:   timeout,
+		Transport: httpTransport,
+	}
+	ctx = traceUsage(ctx)
+	var opts models.HTTPOptions
+	if ep.HTTP != nil {
+		opts = *ep.HTTP
+	}
+	keepAlive := opts.KeepAlive
+	reused := false
+	switch {
+	case keepAlive:
+		client.Transport = pooledTransport(ctx, ep.Address, opts.Version)
+		ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+		})
+	case opts.Version != "" || PinnedIP(ctx) != nil:
+		// A transport of its own so the test gets a new connection like
+		// tests on the shared transport do
+		t := newTransport(opts.Version)
+		defer t.CloseIdleConnections()
+		client.Transport = t
+	}
+	steps, err := httpSteps(ep.Address, opts)
+	if err != nil {
+		return Measurement{}, err
+	}
+	if len(steps) > 1 {
+		jar, _ := cookiejar.New(nil)
+		client.Jar = jar
+		// The timeout covers the whole sequence
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var resp *http.Response
+	for i, step := range steps {
+		resp, err = doHTTPStep(ctx, &client, step, keepAlive)
+		if err != nil {
+			if i < len(steps)-1 {
+				err = fmt.Errorf("step %d: %w", i+1, err)
+			}
+			break
+		}
+	}
+	m := Measurement{Latency: time.Since(start)}
+	if keepAlive {
+		m.Tags = map[string]string{TagConn: ConnCold}
+		if reused {
+			m.Tags[TagConn] = ConnWarm
+		}
+	}
+	if resp != nil {
+		if m.Tags == nil {
+			m.Tags = make(map[string]string, 1)
+		}
+		m.Tags[TagProto] = resp.Proto
+		if resp.TLS != nil {
+			addTLSTags(m.Tags, *resp.TLS, time.Now())
+		}
+	}
+	return m, err
+}
@@ -0,0 +1,155 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestCheckHTTPKeepAlive(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	ep := models.Endpoint{Type: models.TypeHTTP, Address: srv.URL, HTTP: &models.HTTPOptions{KeepAlive: true}}
+	for i, want := range []string{ConnCold, ConnWarm, ConnWarm} {
+		m, err := checkHTTP(context.Background(), ep, time.Second)
+		if err != nil {
+			t.Fatalf("Test %d failed: %v", i, err)
+		}
+		if got := m.Tags[TagConn]; got != want {
+			t.Errorf("Test %d: expected %s connection, got %q", i, want, got)
+		}
+	}
+
+	ep.HTTP = nil
+	m, err := checkHTTP(context.Background(), ep, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Tags[TagConn]; ok {
+		t.Errorf("Expected no connection tag without keep-alive, got %v", m.Tags)
+	}
+}
+
+func TestCheckHTTPVersion(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetHTTP1(true)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		opts  *models.HTTPOptions
+		proto string
+	}{
+		{nil, "HTTP/1.1"},
+		{&models.HTTPOptions{Version: models.HTTPVersion1}, "HTTP/1.1"},
+		{&models.HTTPOptions{Version: models.HTTPVersion2}, "HTTP/2.0"},
+		{&models.HTTPOptions{Version: models.HTTPVersion2, KeepAlive: true}, "HTTP/2.0"},
+	} {
+		m, err := checkHTTP(context.Background(), models.Endpoint{Address: srv.URL, HTTP: tc.opts}, time.Second)
+		if err != nil {
+			t.Fatalf("%+v: %v", tc.opts, err)
+		}
+		if m.Tags[TagProto] != tc.proto {
+			t.Errorf("%+v: expected %s, got %q", tc.opts, tc.proto, m.Tags[TagProto])
+		}
+	}
+
+	if err := ValidateHTTPOptions(&models.HTTPOptions{Version: "3"}); err == nil {
+		t.Errorf("Expected error for an unsupported version")
+	}
+}
+
+func TestCheckHTTPBody(t *testing.T) {
+	var method, contentType, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, contentType, body = r.Method, r.Header.Get("Content-Type"), string(b)
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		opts                      models.HTTPOptions
+		method, contentType, body string
+	}{
+		{models.HTTPOptions{}, "GET", "", ""},
+		{models.HTTPOptions{Body: `{"probe":true}`, ContentType: "application/json"}, "POST", "application/json", `{"probe":true}`},
+		{models.HTTPOptions{Method: "PUT", Form: map[string]string{"b": "2", "a": "x y"}}, "PUT", "application/x-www-form-urlencoded", "a=x+y&b=2"},
+	} {
+		if err := ValidateHTTPOptions(&tc.opts); err != nil {
+			t.Fatalf("%+v: %v", tc.opts, err)
+		}
+		if _, err := checkHTTP(context.Background(), models.Endpoint{Address: srv.URL, HTTP: &tc.opts}, time.Second); err != nil {
+			t.Fatalf("%+v: %v", tc.opts, err)
+		}
+		if method != tc.method || contentType != tc.contentType || body != tc.body {
+			t.Errorf("%+v: server got %s %q %q", tc.opts, method, contentType, body)
+		}
+	}
+
+	for _, opts := range []models.HTTPOptions{
+		{Method: "TRACE"},
+		{Method: "GET", Body: "x"},
+		{Body: "x", Form: map[string]string{"a": "1"}},
+	} {
+		if err := ValidateHTTPOptions(&opts); err == nil {
+			t.Errorf("%+v: expected error", opts)
+		}
+	}
+}
+
+func TestCheckHTTPSteps(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("user") != "probe" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+	})
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err != nil || c.Value != "s1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	opts := &models.HTTPOptions{Steps: []models.HTTPStep{{Address: "/login", Form: map[string]string{"user": "probe"}}}}
+	if err := ValidateHTTPOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	ep := models.Endpoint{Address: srv.URL + "/health", HTTP: opts}
+	if _, err := checkHTTP(context.Background(), ep, time.Second); err != nil {
+		t.Fatalf("Expected the session cookie to be sent: %v", err)
+	}
+
+	// The jar lasts one test only
+	ep.HTTP = nil
+	if _, err := checkHTTP(context.Background(), ep, time.Second); err == nil {
+		t.Errorf("Expected the health page to require a session")
+	}
+
+	opts.Steps[0].Form["user"] = "other"
+	ep.HTTP = opts
+	_, err := checkHTTP(context.Background(), ep, time.Second)
+	var status *HTTPStatusError
+	if !errors.As(err, &status) || status.Code != http.StatusForbidden || !strings.HasPrefix(err.Error(), "step 1:") {
+		t.Errorf("Expected the login step to fail, got %v", err)
+	}
+
+	if err := ValidateHTTPOptions(&models.HTTPOptions{Steps: []models.HTTPStep{{Address: "ftp://x/login"}}}); err == nil {
+		t.Errorf("Expected error for a non-HTTP step address")
+	}
+}
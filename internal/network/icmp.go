@@ -0,0 +1,217 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	probing "github.com/prometheus-community/pro-bing"
+)
+
+func init() {
+	register(Protocol{
+		Type:             models.TypeICMP,
+		DefaultTimeoutMs: 1000,
+		AllAddresses:     true,
+		Run: func(ctx context.Context, ep models.Endpoint, timeout time.Duration) (Measurement, error) {
+			return checkICMP(ctx, ep.Address, ep.ICMP, timeout)
+		},
+	})
+}
+
+// ICMP echo request trains
+const (
+	ICMPDefaultInterval = 200 * time.Millisecond
+	ICMPMaxCount        = 100
+	icmpMinInterval     = 10 * time.Millisecond
+)
+
+// ValidateICMPOptions checks the options of an ICMP endpoint, if it has any
+func ValidateICMPOptions(o *models.ICMPOptions) error {
+	if o == nil {
+		return nil
+	}
+	if o.Count < 0 || o.Count > ICMPMaxCount {
+		return fmt.Errorf("count must be between 1 and %d", ICMPMaxCount)
+	}
+	if o.IntervalMs < 0 || (o.IntervalMs > 0 && time.Duration(o.IntervalMs)*time.Millisecond < icmpMinInterval) {
+		return fmt.Errorf("interval must be at least %d ms", icmpMinInterval.Milliseconds())
+	}
+	return nil
+}
+
+// icmpTrain returns the number of echo requests and the interval between
+// them set by the options
+func icmpTrain(o *models.ICMPOptions) (int, time.Duration) {
+	count, interval := 1, ICMPDefaultInterval
+	if o != nil && o.Count > 0 {
+		count = o.Count
+	}
+	if o != nil && o.IntervalMs > 0 {
+		interval = time.Duration(o.IntervalMs) * time.Millisecond
+	}
+	return count, interval
+}
+
+// TagRedirect records the gateway a router redirected echo requests to
+const TagRedirect = "redirect"
+
+const icmpRedirect = 5
+
+// checkICMP pings the address once, or sends the train of echo requests set
+// by the options and reports its loss, jitter and RTT statistics. The
+// timeout applies to the reply to the last request. Where a raw ICMP socket
+// is available it also watches for ICMP errors about the echo requests, so a
+// lost ping is reported as the unreachable, prohibited or TTL exceeded error
+// a router sent back rather than as plain packet loss.
+func checkICMP(ctx context.Context, address string, opts *models.ICMPOptions, timeout time.Duration) (Measurement, error) {
+	pinger, err := probing.NewPinger(pinHost(ctx, address))
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	count, interval := icmpTrain(opts)
+	pinger.Count = count
+	pinger.Interval = interval
+	pinger.Timeout = timeout + time.Duration(count-1)*interval
+	pinger.RecordRtts = true
+
+	// On Windows, this triggers the use of the IcmpSendEcho API which works for unprivileged users.
+	// On Linux, it attempts raw sockets (requires root) unless configured otherwise.
+	if runtime.GOOS == "windows" {
+		pinger.SetPrivileged(true)
+	}
+
+	var watch *icmpWatch
+	if ip := pinger.IPAddr().IP.To4(); ip != nil {
+		watch = watchICMP(ip)
+	}
+	var icmpErr *ICMPError
+	m := Measurement{}
+
+	err = pinger.RunWithContext(ctx)
+	if watch != nil {
+		var gateway string
+		icmpErr, gateway = watch.stop()
+		if gateway != "" {
+			m.Tags = map[string]string{TagRedirect: gateway}
+		}
+	}
+	if err != nil {
+		return m, err
+	}
+	if ctx.Err() != nil {
+		return m, ctx.Err()
+	}
+
+	stats := pinger.Statistics()
+	// Echo requests and replies carry an 8 byte ICMP header
+	m.BytesSent = int64(stats.PacketsSent * (pinger.Size + 8))
+	m.BytesReceived = int64(stats.PacketsRecv * (pinger.Size + 8))
+	if stats.PacketsRecv == 0 {
+		if icmpErr != nil {
+			return m, icmpErr
+		}
+		return m, ErrPacketLoss
+	}
+
+	m.Latency = stats.AvgRtt
+	if count > 1 {
+		m.LossPct, m.JitterMs, m.RTT = trainStats(stats)
+	}
+	return m, nil
+}
+
+// trainStats derives the packet loss, jitter and RTT statistics of an echo
+// request train with at least one reply
+func trainStats(stats *probing.Statistics) (float64, float64, *models.RTTStats) {
+	recv := min(stats.PacketsRecv, stats.PacketsSent)
+	return float64(stats.PacketsSent-recv) / float64(stats.PacketsSent) * 100,
+		meanVariationMs(stats.Rtts),
+		&models.RTTStats{
+			Sent:     stats.PacketsSent,
+			Recv:     recv,
+			MinUs:    stats.MinRtt.Microseconds(),
+			AvgUs:    stats.AvgRtt.Microseconds(),
+			MaxUs:    stats.MaxRtt.Microseconds(),
+			StdDevUs: stats.StdDevRtt.Microseconds(),
+		}
+}
+
+// icmpWatch collects ICMP errors quoting echo requests sent to an address
+type icmpWatch struct {
+	conn    net.PacketConn
+	done    chan struct{}
+	mu      sync.Mutex
+	err     *ICMPError
+	gateway string
+}
+
+// watchICMP starts watching for ICMP errors about echo requests to dst. It
+// returns nil when no raw ICMP socket can be opened, which needs
+// administrator rights on Windows and root or CAP_NET_RAW elsewhere.
+func watchICMP(dst net.IP) *icmpWatch {
+	conn, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return nil
+	}
+	w := &icmpWatch{conn: conn, done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			typ, code, quotedDst, ok := parseICMPEchoError(buf[:n])
+			if !ok || !quotedDst.Equal(dst) {
+				continue
+			}
+			w.mu.Lock()
+			if typ == icmpRedirect {
+				w.gateway = net.IP(buf[4:8]).String()
+			} else if w.err == nil {
+				w.err = &ICMPError{Type: typ, Code: code, From: from.String()}
+			}
+			w.mu.Unlock()
+		}
+	}()
+	return w
+}
+
+// stop closes the socket and returns the first error and the redirect
+// gateway seen, if any
+func (w *icmpWatch) stop() (*ICMPError, string) {
+	w.conn.Close()
+	<-w.done
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err, w.gateway
+}
+
+// parseICMPEchoError extracts the type and code of an ICMP destination
+// unreachable, redirect or time exceeded message and the destination of the
+// echo request it quotes
+func parseICMPEchoError(msg []byte) (typ, code int, dst net.IP, ok bool) {
+	if len(msg) < 8 {
+		return 0, 0, nil, false
+	}
+	typ, code = int(msg[0]), int(msg[1])
+	if typ != icmpDestUnreachable && typ != icmpTimeExceeded && typ != icmpRedirect {
+		return 0, 0, nil, false
+	}
+	quoted := msg[8:]
+	if len(quoted) < 20 {
+		return 0, 0, nil, false
+	}
+	ihl := int(quoted[0]&0x0F) * 4
+	if quoted[9] != 1 || len(quoted) < ihl+1 || quoted[ihl] != 8 { // Only echo requests
+		return 0, 0, nil, false
+	}
+	return typ, code, net.IP(quoted[16:20]), true
+}
@@ -0,0 +1,190 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func init() {
+	register(Protocol{
+		Type:             models.TypeUDPJitter,
+		DefaultTimeoutMs: 2000,
+		AllAddresses:     true,
+		Validate:         validateHostPort,
+		Run: func(ctx context.Context, ep models.Endpoint, timeout time.Duration) (Measurement, error) {
+			stats, err := checkUDPJitter(ctx, ep.Address, timeout)
+			return Measurement{
+				Latency:  stats.avgRTT,
+				JitterMs: stats.jitterMs,
+				LossPct:  stats.lossPct,
+				MOS:      stats.mos,
+			}, err
+		},
+	})
+}
+
+// UDP jitter test parameters. The train mimics a VoIP stream: small packets
+// sent every 20ms, which the target is expected to echo back unchanged.
+const (
+	jitterPackets  = 10
+	jitterInterval = 20 * time.Millisecond
+	jitterPayload  = 32
+)
+
+var jitterMagic = []byte("NMJT")
+
+type jitterStats struct {
+	avgRTT   time.Duration
+	jitterMs float64 // Mean absolute difference between consecutive RTTs
+	lossPct  float64
+	mos      float64
+}
+
+// checkUDPJitter sends a train of sequenced packets to a UDP echo service and
+// derives round-trip time, jitter, loss and an estimated MOS from the replies.
+// The timeout applies to waiting for replies after the last packet is sent.
+func checkUDPJitter(ctx context.Context, address string, timeout time.Duration) (jitterStats, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialConn(ctx, &dialer, "udp", address)
+	if err != nil {
+		return jitterStats{}, err
+	}
+	defer conn.Close()
+
+	// Unblock the reader when the test is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	// mu guards the send times, written by the sender, against the reader,
+	// which may get a stray packet of a sequence not sent yet
+	var mu sync.Mutex
+	sent := make([]time.Time, jitterPackets)
+	rtts := make([]time.Duration, jitterPackets)
+	received := make([]bool, jitterPackets)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 1500)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			seq, ok := parseJitterPacket(buf[:n])
+			if !ok || seq >= jitterPackets {
+				continue
+			}
+			mu.Lock()
+			if !received[seq] && !sent[seq].IsZero() {
+				received[seq] = true
+				rtts[seq] = time.Since(sent[seq])
+			}
+			mu.Unlock()
+		}
+	}()
+
+	for seq := range jitterPackets {
+		mu.Lock()
+		sent[seq] = time.Now()
+		mu.Unlock()
+		if _, err := conn.Write(jitterPacket(seq)); err != nil {
+			conn.Close()
+			<-done
+			return jitterStats{}, err
+		}
+		if seq < jitterPackets-1 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(jitterInterval):
+			}
+		}
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	<-done // The reader is done with the slices
+
+	if ctx.Err() != nil {
+		return jitterStats{}, ctx.Err()
+	}
+
+	var samples []time.Duration
+	for seq, ok := range received {
+		if ok {
+			samples = append(samples, rtts[seq])
+		}
+	}
+	if len(samples) == 0 {
+		return jitterStats{}, ErrPacketLoss
+	}
+	return computeJitterStats(samples, jitterPackets), nil
+}
+
+func jitterPacket(seq int) []byte {
+	p := make([]byte, jitterPayload)
+	copy(p, jitterMagic)
+	binary.BigEndian.PutUint32(p[len(jitterMagic):], uint32(seq))
+	return p
+}
+
+func parseJitterPacket(p []byte) (int, bool) {
+	if len(p) < len(jitterMagic)+4 || !bytes.Equal(p[:len(jitterMagic)], jitterMagic) {
+		return 0, false
+	}
+	return int(binary.BigEndian.Uint32(p[len(jitterMagic):])), true
+}
+
+// computeJitterStats derives the metrics from the RTTs of the received
+// packets, in sequence order, out of the number of packets sent
+func computeJitterStats(samples []time.Duration, sent int) jitterStats {
+	var total time.Duration
+	for _, rtt := range samples {
+		total += rtt
+	}
+
+	stats := jitterStats{
+		avgRTT:   total / time.Duration(len(samples)),
+		jitterMs: meanVariationMs(samples),
+		lossPct:  float64(sent-len(samples)) / float64(sent) * 100,
+	}
+	stats.mos = estimateMOS(float64(stats.avgRTT)/float64(time.Millisecond), stats.jitterMs, stats.lossPct)
+	return stats
+}
+
+// meanVariationMs returns the jitter of RTTs in the order they were
+// measured: the mean absolute difference between consecutive ones, in
+// milliseconds. Zero with fewer than two.
+func meanVariationMs(samples []time.Duration) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	var variation float64
+	for i := 1; i < len(samples); i++ {
+		variation += math.Abs(float64(samples[i]-samples[i-1]) / float64(time.Millisecond))
+	}
+	return variation / float64(len(samples)-1)
+}
+
+// estimateMOS approximates the ITU-T G.107 E-model: the R-factor is reduced
+// by one-way delay (half the RTT, with jitter weighted double to account for
+// the jitter buffer) and by packet loss, then mapped onto the 1-5 MOS scale
+func estimateMOS(rttMs, jitterMs, lossPct float64) float64 {
+	effective := rttMs/2 + 2*jitterMs + 10
+
+	r := 93.2
+	if effective < 160 {
+		r -= effective / 40
+	} else {
+		r -= (effective - 120) / 10
+	}
+	r -= 2.5 * lossPct
+	r = max(0, min(100, r))
+
+	return 1 + 0.035*r + 0.000007*r*(r-60)*(100-r)
+}
@@ -0,0 +1,98 @@
+package network
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// MockType is the endpoint type MockTest registers by default
+const MockType models.EndpointType = "MOCK"
+
+// MockStep is one scripted test outcome
+type MockStep struct {
+	Measurement
+	// Err fails the test. A step whose latency reaches the endpoint timeout
+	// times out even without an error.
+	Err error
+	// Sleep makes the test take its latency in real time instead of only
+	// reporting it, e.g. to exercise concurrency limits or cancellation
+	Sleep bool
+}
+
+// MockTest is a scriptable protocol for tests of code that runs endpoints,
+// such as the scheduler or middlewares. Each address plays its script in
+// order and then repeats Default.
+//
+//	mock := network.NewMockTest()
+//	mock.Script("a", network.MockStep{Err: errors.New("down")})
+//	runner.Protocols.Register(mock.Protocol())
+//	ep := models.Endpoint{Type: network.MockType, Address: "a", Timeout: 1000}
+type MockTest struct {
+	Type    models.EndpointType
+	Default MockStep
+
+	mu      sync.Mutex
+	scripts map[string][]MockStep
+	calls   map[string]int
+}
+
+// NewMockTest returns a mock whose tests succeed in 1ms unless scripted
+func NewMockTest() *MockTest {
+	return &MockTest{
+		Type:    MockType,
+		Default: MockStep{Measurement: Measurement{Latency: time.Millisecond}},
+		scripts: make(map[string][]MockStep),
+		calls:   make(map[string]int),
+	}
+}
+
+// Script queues outcomes for the address's next tests
+func (m *MockTest) Script(address string, steps ...MockStep) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scripts[address] = append(m.scripts[address], steps...)
+}
+
+// Calls returns how many times the address was tested
+func (m *MockTest) Calls(address string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls[address]
+}
+
+// Protocol returns the protocol to register with a runner's registry
+func (m *MockTest) Protocol() Protocol {
+	return Protocol{Type: m.Type, DefaultTimeoutMs: 1000, Run: m.run}
+}
+
+func (m *MockTest) run(ctx context.Context, ep models.Endpoint, timeout time.Duration) (Measurement, error) {
+	address := ep.Address
+	m.mu.Lock()
+	m.calls[address]++
+	step := m.Default
+	if script := m.scripts[address]; len(script) > 0 {
+		step, m.scripts[address] = script[0], script[1:]
+	}
+	m.mu.Unlock()
+
+	err := step.Err
+	if step.Latency >= timeout {
+		step.Latency = timeout
+		if err == nil {
+			err = os.ErrDeadlineExceeded
+		}
+	}
+	if step.Sleep {
+		start := time.Now()
+		select {
+		case <-time.After(step.Latency):
+		case <-ctx.Done():
+			return Measurement{Latency: time.Since(start)}, ctx.Err()
+		}
+	}
+	return step.Measurement, err
+}
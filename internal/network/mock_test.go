@@ -0,0 +1,63 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestMockTest(t *testing.T) {
+	mock := NewMockTest()
+	mock.Script("a",
+		MockStep{Measurement: Measurement{Latency: 20 * time.Millisecond}},
+		MockStep{Err: syscall.ECONNREFUSED},
+		MockStep{Measurement: Measurement{Latency: time.Hour}},
+	)
+	r := NewRunner(context.Background())
+	if err := r.Protocols.Register(mock.Protocol()); err != nil {
+		t.Fatal(err)
+	}
+	ep := models.Endpoint{Type: MockType, Address: "a", Timeout: 100}
+
+	want := []struct {
+		ms int64
+		st int
+		ek models.ErrorKind
+	}{
+		{20, models.TestStatusSuccess, ""},
+		{0, models.TestStatusError, models.ErrorKindRefused},
+		{100, models.TestStatusTimeout, models.ErrorKindTimeout},
+		{1, models.TestStatusSuccess, ""}, // Script exhausted, Default
+	}
+	for i, w := range want {
+		result, _ := r.Run(context.Background(), ep)
+		if result.Ms != w.ms || result.St != w.st || result.Ek != w.ek {
+			t.Errorf("Run %d: got ms=%d st=%d ek=%q", i, result.Ms, result.St, result.Ek)
+		}
+	}
+	if n := mock.Calls("a"); n != 4 {
+		t.Errorf("Calls = %d, want 4", n)
+	}
+}
+
+func TestMockTestSleep(t *testing.T) {
+	mock := NewMockTest()
+	mock.Default = MockStep{Measurement: Measurement{Latency: 10 * time.Second}, Sleep: true}
+	r := NewRunner(context.Background())
+	_ = r.Protocols.Register(mock.Protocol())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result, err := r.Run(ctx, models.Endpoint{Type: MockType, Address: "slow", Timeout: 30000})
+	if !errors.Is(err, context.DeadlineExceeded) || result.St != models.TestStatusCancelled {
+		t.Errorf("got %+v, %v", result, err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("sleeping step ignored cancellation")
+	}
+}
@@ -0,0 +1,451 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	probing "github.com/prometheus-community/pro-bing"
+)
+
+func TestCheckICMP_Integration(t *testing.T) {
+	// Pinging localhost should generally work, but might require privileges or specific setup on Windows.
+	// Since we are switching to pro-bing with unprivileged support via API, this test is crucial.
+
+	// Skip if short test? No, we want to run this.
+
+	target := "127.0.0.1"
+	timeout := 2 * time.Second
+
+	fmt.Printf("Attempting to ping %s...\n", target)
+
+	_, err := checkICMP(context.Background(), target, nil, timeout)
+	if err != nil {
+		t.Logf("ICMP Ping to %s failed: %v", target, err)
+		t.Logf("Note: This might be expected if running without sufficient privileges or OS support.")
+		// We don't fail the test immediately because CI/Environments vary,
+		// but for local dev this should pass.
+		// Un-commenting Fatal to enforce it for now as per user request.
+		t.Fatal(err)
+	} else {
+		t.Logf("ICMP Ping to %s succeeded", target)
+	}
+}
+
+func TestComputeJitterStats(t *testing.T) {
+	ms := time.Millisecond
+	stats := computeJitterStats([]time.Duration{10 * ms, 20 * ms, 10 * ms, 20 * ms}, 5)
+	if stats.avgRTT != 15*ms || stats.jitterMs != 10 || stats.lossPct != 20 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if good, bad := estimateMOS(20, 1, 0), estimateMOS(300, 40, 5); good <= bad || good > 4.5 || bad < 1 {
+		t.Errorf("Unexpected MOS estimates: %f, %f", good, bad)
+	}
+}
+
+func TestCheckUDPJitterStrayPackets(t *testing.T) {
+	// The echo server also answers each packet with packets of sequences
+	// not sent yet, which the reader must ignore
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			seq, _ := parseJitterPacket(buf[:n])
+			for stray := seq + 1; stray < jitterPackets; stray++ {
+				pc.WriteTo(jitterPacket(stray), addr)
+			}
+			pc.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	stats, err := checkUDPJitter(context.Background(), pc.LocalAddr().String(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.lossPct != 0 || stats.avgRTT <= 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestTrainStats(t *testing.T) {
+	ms := time.Millisecond
+	loss, jitter, rtt := trainStats(&probing.Statistics{
+		PacketsSent: 4, PacketsRecv: 3, Rtts: []time.Duration{10 * ms, 14 * ms, 12 * ms},
+		MinRtt: 10 * ms, AvgRtt: 12 * ms, MaxRtt: 14 * ms, StdDevRtt: 1633 * time.Microsecond,
+	})
+	if loss != 25 || jitter != 3 {
+		t.Errorf("Expected 25%% loss and 3 ms jitter, got %v and %v", loss, jitter)
+	}
+	if *rtt != (models.RTTStats{Sent: 4, Recv: 3, MinUs: 10_000, AvgUs: 12_000, MaxUs: 14_000, StdDevUs: 1633}) {
+		t.Errorf("Unexpected RTT stats: %+v", rtt)
+	}
+
+	// Duplicate replies don't make loss negative
+	if loss, _, rtt := trainStats(&probing.Statistics{PacketsSent: 2, PacketsRecv: 3}); loss != 0 || rtt.Recv != 2 {
+		t.Errorf("Expected no loss with duplicates, got %v, %+v", loss, rtt)
+	}
+}
+
+func TestValidateICMPOptions(t *testing.T) {
+	for _, o := range []*models.ICMPOptions{nil, {}, {Count: 10}, {Count: ICMPMaxCount, IntervalMs: 10}} {
+		if err := ValidateICMPOptions(o); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", o, err)
+		}
+	}
+	for _, o := range []*models.ICMPOptions{{Count: -1}, {Count: ICMPMaxCount + 1}, {IntervalMs: 5}, {IntervalMs: -1}} {
+		if err := ValidateICMPOptions(o); err == nil {
+			t.Errorf("Expected %+v to be rejected", o)
+		}
+	}
+	if count, interval := icmpTrain(nil); count != 1 || interval != ICMPDefaultInterval {
+		t.Errorf("Expected a single ping by default, got %d every %v", count, interval)
+	}
+	if count, interval := icmpTrain(&models.ICMPOptions{Count: 5, IntervalMs: 50}); count != 5 || interval != 50*time.Millisecond {
+		t.Errorf("Expected 5 pings every 50ms, got %d every %v", count, interval)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	for _, typ := range []models.EndpointType{models.TypeHTTP, models.TypeTCP, models.TypeUDP, models.TypeICMP, models.TypeUDPJitter, models.TypeDNS, models.TypeHappyEyeballs} {
+		if _, ok := r.Lookup(typ); !ok {
+			t.Errorf("Built-in protocol %s not registered", typ)
+		}
+	}
+
+	run := func(context.Context, models.Endpoint, time.Duration) (Measurement, error) { return Measurement{}, nil }
+	if err := r.Register(Protocol{Type: models.TypeHTTP, Run: run}); err == nil {
+		t.Errorf("Expected error registering a built-in type again")
+	}
+	if err := r.Register(Protocol{Type: "REDIS", Run: run}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if _, ok := NewRegistry().Lookup("REDIS"); ok {
+		t.Errorf("Registrations must not leak between registries")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	r := NewRegistry()
+	tests := []struct {
+		typ     models.EndpointType
+		address string
+		valid   bool
+	}{
+		{models.TypeHTTP, "https://example.com", true},
+		{models.TypeHTTP, "example.com", false},
+		{models.TypeTCP, "example.com:443", true},
+		{models.TypeTCP, "example.com", false},
+		{models.TypeICMP, "8.8.8.8", true},
+		{models.TypeICMP, "", false},
+		{"BOGUS", "x", false},
+	}
+	for _, tt := range tests {
+		if err := r.Validate(tt.typ, tt.address); (err == nil) != tt.valid {
+			t.Errorf("Validate(%s, %q) = %v, want valid=%v", tt.typ, tt.address, err, tt.valid)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		want   models.ErrorKind
+	}{
+		{nil, models.TestStatusSuccess, ""},
+		{&net.DNSError{Err: "no such host", Name: "x"}, models.TestStatusError, models.ErrorKindDNS},
+		{fmt.Errorf("get: %w", &HTTPStatusError{Code: 503}), models.TestStatusError, models.ErrorKindHTTPStatus},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, models.TestStatusError, models.ErrorKindRefused},
+		{ErrPacketLoss, models.TestStatusError, models.ErrorKindPacketLoss},
+		{&ICMPError{Type: icmpDestUnreachable, Code: 1}, models.TestStatusError, models.ErrorKindUnreachable},
+		{&ICMPError{Type: icmpDestUnreachable, Code: icmpCodeAdminProhibited}, models.TestStatusError, models.ErrorKindProhibited},
+		{&ICMPError{Type: icmpTimeExceeded}, models.TestStatusError, models.ErrorKindTTLExceeded},
+		{fmt.Errorf("%w: prefix doesn't match", ErrUnexpectedResponse), models.TestStatusError, models.ErrorKindBadResponse},
+		{fmt.Errorf("i/o timeout"), models.TestStatusTimeout, models.ErrorKindTimeout},
+		{context.Canceled, models.TestStatusCancelled, ""},
+		{fmt.Errorf("boom"), models.TestStatusError, models.ErrorKindOther},
+	}
+	for _, tt := range tests {
+		if got := ClassifyError(tt.err, tt.status); got != tt.want {
+			t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRunnerMiddleware(t *testing.T) {
+	r := NewRunner(context.Background())
+	if err := r.Protocols.Register(Protocol{
+		Type: "FAKE",
+		Run: func(context.Context, models.Endpoint, time.Duration) (Measurement, error) {
+			return Measurement{Latency: 5 * time.Millisecond}, nil
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next RunFunc) RunFunc {
+			return func(ctx context.Context, ep models.Endpoint) (models.TestResult, error) {
+				order = append(order, "pre-"+name)
+				result, err := next(ctx, ep)
+				order = append(order, "post-"+name)
+				if result.Tags == nil {
+					result.Tags = make(map[string]string)
+				}
+				result.Tags[name] = ep.Name
+				return result, err
+			}
+		}
+	}
+	r.Use(tag("a"), tag("b"))
+
+	result, err := r.Run(context.Background(), models.Endpoint{Name: "ep", Type: "FAKE", Address: "x", Timeout: 1000})
+	if err != nil || result.St != models.TestStatusSuccess || result.Ms != 5 {
+		t.Fatalf("Unexpected result: %+v, %v", result, err)
+	}
+	if fmt.Sprint(order) != "[pre-a pre-b post-b post-a]" {
+		t.Errorf("Unexpected middleware order: %v", order)
+	}
+	if result.Tags["a"] != "ep" || result.Tags["b"] != "ep" {
+		t.Errorf("Middlewares did not enrich the result: %+v", result.Tags)
+	}
+}
+
+func TestHost(t *testing.T) {
+	for address, want := range map[string]string{
+		"https://example.com:8443/health": "example.com",
+		"example.com:443":                 "example.com",
+		"[2001:db8::1]:53":                "2001:db8::1",
+		"8.8.8.8":                         "8.8.8.8",
+		"8.8.8.8#example.com":             "8.8.8.8",
+		"tls://1.1.1.1#example.com":       "1.1.1.1",
+	} {
+		if got := Host(address); got != want {
+			t.Errorf("Host(%q) = %q, want %q", address, got, want)
+		}
+	}
+}
+
+func TestHappyEyeballs(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	// 127.0.0.1 has no IPv6 address, so IPv4 wins by default
+	m, err := checkHappyEyeballs(context.Background(), net.JoinHostPort("127.0.0.1", port), time.Second)
+	if err != nil {
+		t.Fatalf("Happy Eyeballs test failed: %v", err)
+	}
+	if m.Tags[TagWinner] != "ipv4" || m.Tags[TagIPv4Ms] == "" || m.Tags[TagIPv6Err] == "" {
+		t.Errorf("Unexpected tags: %+v", m.Tags)
+	}
+}
+
+func TestCheckTCPReuse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 16)
+			n, _ := conn.Read(buf)
+			conn.Write(buf[:n])
+			conn.Close()
+		}
+	}()
+
+	ep := models.Endpoint{Type: models.TypeTCP, Address: ln.Addr().String(), TCP: &models.TCPOptions{Reuse: true, Payload: "ping"}}
+	if err := ValidateTCPOptions(ep.TCP); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := NewRegistry().Lookup(models.TypeTCP)
+	m, err := p.Run(context.Background(), ep, time.Second)
+	if err != nil {
+		t.Fatalf("TCP reuse test failed: %v", err)
+	}
+	if m.Latency <= 0 || m.Tags[TagReuseMs] == "" {
+		t.Errorf("Expected connect and reuse times, got %v %+v", m.Latency, m.Tags)
+	}
+
+	// The runner counts the payload sent and the byte read of the reply
+	ep.Timeout = 1000
+	result, err := NewRunner(context.Background()).Run(context.Background(), ep)
+	if err != nil || result.Tx != 4 || result.Rx != 1 {
+		t.Errorf("Expected 4 bytes sent and 1 received, got %d and %d (%v)", result.Tx, result.Rx, err)
+	}
+
+	if err := ValidateTCPOptions(&models.TCPOptions{Reuse: true}); err == nil {
+		t.Errorf("Expected error for reuse without a payload")
+	}
+}
+
+func TestCheckUDPExpect(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(append([]byte("PONG "), buf[:n]...), addr)
+		}
+	}()
+
+	for _, tc := range []struct {
+		opts models.UDPOptions
+		ok   bool
+	}{
+		{models.UDPOptions{Payload: "hi", ExpectPrefix: "PONG"}, true},
+		{models.UDPOptions{Payload: "hi", ExpectRegex: `^PONG h.$`}, true},
+		{models.UDPOptions{PayloadHex: "01 02", ExpectHex: "504f4e47 20 ?? 02"}, true},
+		{models.UDPOptions{Payload: "hi", ExpectPrefix: "HELLO"}, false},
+		{models.UDPOptions{PayloadHex: "01", ExpectHex: "504f4e472002"}, false},
+	} {
+		if err := ValidateUDPOptions(&tc.opts); err != nil {
+			t.Fatalf("%+v: %v", tc.opts, err)
+		}
+		_, err := checkUDPOptions(context.Background(), conn.LocalAddr().String(), tc.opts, time.Second)
+		if tc.ok && err != nil {
+			t.Errorf("%+v: %v", tc.opts, err)
+		}
+		if !tc.ok && !errors.Is(err, ErrUnexpectedResponse) {
+			t.Errorf("%+v: expected an unexpected response error, got %v", tc.opts, err)
+		}
+	}
+
+	for _, opts := range []models.UDPOptions{
+		{Payload: "a", PayloadHex: "61"},
+		{ExpectRegex: "("},
+		{ExpectHex: "0g"},
+		{ExpectHex: "012"},
+	} {
+		if err := ValidateUDPOptions(&opts); err == nil {
+			t.Errorf("%+v: expected error", opts)
+		}
+	}
+}
+
+func TestParseICMPReply(t *testing.T) {
+	// Time exceeded quoting a UDP probe to port 33435
+	msg := make([]byte, 8+20+8)
+	msg[0] = icmpTimeExceeded
+	msg[8] = 0x45 // IPv4, 20 byte header
+	msg[8+9] = 17 // UDP
+	msg[8+20+2], msg[8+20+3] = 0x82, 0x9b
+
+	typ, port, ok := parseICMPReply(msg)
+	if !ok || typ != icmpTimeExceeded || port != 33435 {
+		t.Errorf("Unexpected parse: type=%d port=%d ok=%v", typ, port, ok)
+	}
+
+	msg[0] = 0 // Echo reply
+	if _, _, ok := parseICMPReply(msg); ok {
+		t.Errorf("Expected echo reply to be ignored")
+	}
+	if _, _, ok := parseICMPReply(msg[:10]); ok {
+		t.Errorf("Expected truncated message to be ignored")
+	}
+}
+
+func TestParseICMPEchoError(t *testing.T) {
+	// Host prohibited quoting an echo request to 192.0.2.7
+	msg := make([]byte, 8+20+8)
+	msg[0], msg[1] = icmpDestUnreachable, icmpCodeHostProhibited
+	msg[8] = 0x45
+	msg[8+9] = 1 // ICMP
+	copy(msg[8+16:], net.IPv4(192, 0, 2, 7).To4())
+	msg[8+20] = 8 // Echo request
+
+	typ, code, dst, ok := parseICMPEchoError(msg)
+	if !ok || typ != icmpDestUnreachable || code != icmpCodeHostProhibited || !dst.Equal(net.IPv4(192, 0, 2, 7)) {
+		t.Errorf("Unexpected parse: type=%d code=%d dst=%v ok=%v", typ, code, dst, ok)
+	}
+
+	msg[8+9] = 17 // Quoting a UDP probe
+	if _, _, _, ok := parseICMPEchoError(msg); ok {
+		t.Errorf("Expected errors about other protocols to be ignored")
+	}
+}
+
+func TestPathsDiffer(t *testing.T) {
+	hops := []Hop{{Addr: "10.0.0.1"}, {}, {Addr: "8.8.8.8", Reached: true}}
+	sig := HopSignature(hops)
+	if sig != "10.0.0.1,*,8.8.8.8" {
+		t.Fatalf("Unexpected signature %q", sig)
+	}
+
+	for other, want := range map[string]bool{
+		"10.0.0.1,*,8.8.8.8":           false,
+		"10.0.0.1,192.0.2.1,8.8.8.8":   false,
+		"*,*,*":                        false,
+		"10.0.0.2,192.0.2.1,8.8.8.8":   true,
+		"10.0.0.1,8.8.8.8":             true,
+		"10.0.0.1,*,192.0.2.9,8.8.8.8": true,
+	} {
+		if got := PathsDiffer(sig, other); got != want {
+			t.Errorf("PathsDiffer(%q, %q) = %v, want %v", sig, other, got, want)
+		}
+	}
+}
+
+func TestAllAddresses(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	// Only 127.0.0.1 has a listener, so the node on 127.0.0.2 is down
+	defer func(orig func(context.Context, string) ([]net.IPAddr, error)) { lookupIPAddr = orig }(lookupIPAddr)
+	lookupIPAddr = func(context.Context, string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}, {IP: net.IPv4(127, 0, 0, 2)}}, nil
+	}
+
+	r := NewRunner(context.Background())
+	ep := models.Endpoint{Type: models.TypeTCP, Address: net.JoinHostPort("cdn.test", port), Timeout: 1000, AllAddresses: true}
+	result, err := r.Run(context.Background(), ep)
+	if err == nil || result.St != models.TestStatusError || result.Ek != models.ErrorKindRefused {
+		t.Fatalf("Expected the down address to fail the test: %+v, %v", result, err)
+	}
+	if result.Tags[TagAddrsUp] != "1/2" || result.Tags[TagAddrPrefix+"127.0.0.2"] != string(models.ErrorKindRefused) {
+		t.Errorf("Unexpected tags: %+v", result.Tags)
+	}
+	if _, err := strconv.Atoi(result.Tags[TagAddrPrefix+"127.0.0.1"]); err != nil {
+		t.Errorf("Expected latency of the working address: %+v", result.Tags)
+	}
+
+	if err := r.Protocols.ValidateAllAddresses(models.Endpoint{Type: models.TypeDNS, AllAddresses: true}); err == nil {
+		t.Errorf("Expected error for a protocol that can't pin addresses")
+	}
+}
@@ -0,0 +1,124 @@
+// Package network implements the protocol tests run against endpoints. Each
+// protocol registers itself from its own file, so adding a protocol only
+// touches that file.
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// Measurement is the outcome of a successful or partially successful test
+type Measurement struct {
+	Latency time.Duration
+
+	// Set by tests that send a packet train, such as UDP jitter
+	JitterMs float64
+	LossPct  float64
+	MOS      float64
+	// RTT is set by ICMP tests sending more than one echo request
+	RTT *models.RTTStats
+
+	// Tags are copied to the result's tags
+	Tags map[string]string
+
+	// Traffic of tests that don't dial connections with dialConn, such as
+	// pings. Added to the traffic counted on connections.
+	BytesSent     int64
+	BytesReceived int64
+}
+
+// Protocol describes how to test one endpoint type
+type Protocol struct {
+	Type models.EndpointType
+	// DefaultTimeoutMs is used when an endpoint doesn't set a timeout
+	DefaultTimeoutMs int
+	// Validate checks the endpoint address before it is saved or tested.
+	// Optional.
+	Validate func(address string) error
+	// Run tests the endpoint's address, applying the endpoint's options for
+	// the protocol. It must return promptly once ctx is done.
+	Run func(ctx context.Context, ep models.Endpoint, timeout time.Duration) (Measurement, error)
+	// AllAddresses is set by protocols that connect to the IP pinned in
+	// ctx, see WithPinnedIP, so endpoints can be tested on every address
+	// their host resolves to
+	AllAddresses bool
+}
+
+// builtins are the protocols registered by this package's init functions
+var builtins []Protocol
+
+func register(p Protocol) {
+	builtins = append(builtins, p)
+}
+
+// Registry maps endpoint types to the protocols that test them
+type Registry struct {
+	mu        sync.RWMutex
+	protocols map[models.EndpointType]Protocol
+}
+
+// NewRegistry returns a registry holding the built-in protocols
+func NewRegistry() *Registry {
+	r := &Registry{protocols: make(map[models.EndpointType]Protocol)}
+	for _, p := range builtins {
+		r.protocols[p.Type] = p
+	}
+	return r
+}
+
+// Register adds a protocol. Types can't be registered twice.
+func (r *Registry) Register(p Protocol) error {
+	if p.Type == "" || p.Run == nil {
+		return errors.New("protocol type and Run are required")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.protocols[p.Type]; ok {
+		return fmt.Errorf("endpoint type %s is already registered", p.Type)
+	}
+	r.protocols[p.Type] = p
+	return nil
+}
+
+// Lookup returns the protocol for the endpoint type
+func (r *Registry) Lookup(t models.EndpointType) (Protocol, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.protocols[t]
+	return p, ok
+}
+
+// Types returns the registered endpoint types, sorted
+func (r *Registry) Types() []models.EndpointType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]models.EndpointType, 0, len(r.protocols))
+	for t := range r.protocols {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// Validate checks that the endpoint type is registered and its address is
+// acceptable to the protocol
+func (r *Registry) Validate(t models.EndpointType, address string) error {
+	p, ok := r.Lookup(t)
+	if !ok {
+		return fmt.Errorf("unsupported endpoint type: %s", t)
+	}
+	if address == "" {
+		return errors.New("address is required")
+	}
+	if p.Validate != nil {
+		return p.Validate(address)
+	}
+	return nil
+}
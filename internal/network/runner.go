@@ -0,0 +1,158 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// Runner turns an endpoint into a stored test result: it runs the endpoint's
+// protocol, maps the outcome to a status and classifies failures. Scheduled,
+// batch, manual and ad-hoc tests all go through it so they behave the same.
+type Runner struct {
+	Ctx       context.Context // Carries the logger
+	Protocols *Registry
+	Clock     *Clock
+
+	mu          sync.RWMutex
+	middlewares []Middleware
+}
+
+// RunFunc executes a test against an endpoint
+type RunFunc func(ctx context.Context, ep models.Endpoint) (models.TestResult, error)
+
+// Middleware wraps test execution so components can act before a test runs
+// and inspect or enrich its result afterwards
+type Middleware func(next RunFunc) RunFunc
+
+// NewRunner returns a runner with the built-in protocols that logs every test,
+// confirms failures of endpoints that have a confirmation probe and tests
+// endpoints on all their addresses when they ask for it
+func NewRunner(ctx context.Context) *Runner {
+	r := &Runner{Ctx: ctx, Protocols: NewRegistry(), Clock: NewClock(ctx)}
+	r.Use(logResult(ctx), confirm(), expandAddresses(r.Protocols))
+	return r
+}
+
+// Use appends middlewares to the chain. The first middleware added is the
+// outermost, seeing the endpoint first and the result last.
+func (r *Runner) Use(mw ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares = append(r.middlewares, mw...)
+}
+
+// EndpointID identifies an endpoint in stored results: the first 7 characters
+// of the SHA1 UUID of its address and type, so the same address tested with
+// different protocols gets different IDs
+func EndpointID(address string, t models.EndpointType) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(address+string(t))).String()[:7]
+}
+
+// Run tests the endpoint and also returns the error that made it fail, if any.
+// When ctx is done before the test finishes the result is reported as
+// cancelled rather than as a timeout. Tests without a trace ID in ctx get one
+// of their own.
+func (r *Runner) Run(ctx context.Context, ep models.Endpoint) (models.TestResult, error) {
+	if TraceFrom(ctx) == "" {
+		ctx = WithTrace(ctx, NewRunID())
+	}
+	r.mu.RLock()
+	run := r.run
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		run = r.middlewares[i](run)
+	}
+	r.mu.RUnlock()
+	return run(ctx, ep)
+}
+
+func (r *Runner) run(ctx context.Context, ep models.Endpoint) (models.TestResult, error) {
+	var err error
+	var status int
+
+	timeout := time.Duration(ep.Timeout) * time.Millisecond
+	var measurement Measurement
+	usage := new(Usage)
+
+	if proto, ok := r.Protocols.Lookup(ep.Type); ok {
+		measurement, err = proto.Run(withUsage(ctx, usage), ep, timeout)
+	} else {
+		err = fmt.Errorf("unknown endpoint type: %s", ep.Type)
+	}
+
+	d := measurement.Latency
+	if err != nil {
+		if ctx.Err() != nil {
+			status = models.TestStatusCancelled
+		} else if d >= timeout {
+			status = models.TestStatusTimeout
+		} else {
+			status = models.TestStatusError
+		}
+	} else {
+		status = models.TestStatusSuccess
+	}
+
+	ts, seq, jump := r.Clock.Stamp()
+	tags := measurement.Tags
+	if jump != 0 {
+		tags = maps.Clone(tags)
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[TagClockJump] = strconv.FormatInt(jump.Milliseconds(), 10)
+	}
+
+	return models.TestResult{
+		Ts:    ts.UnixMilli(),
+		Seq:   seq,
+		Id:    EndpointID(ep.Address, ep.Type),
+		Ms:    d.Milliseconds(),
+		Us:    d.Microseconds(),
+		St:    status,
+		Ek:    ClassifyError(err, status),
+		Jit:   measurement.JitterMs,
+		Loss:  measurement.LossPct,
+		Mos:   measurement.MOS,
+		Rtt:   measurement.RTT,
+		Tags:  tags,
+		Tx:    usage.Sent() + measurement.BytesSent,
+		Rx:    usage.Received() + measurement.BytesReceived,
+		Trace: TraceFrom(ctx),
+	}, err
+}
+
+// logResult logs every test at debug level
+func logResult(ctx context.Context) Middleware {
+	return func(next RunFunc) RunFunc {
+		return func(runCtx context.Context, ep models.Endpoint) (models.TestResult, error) {
+			result, err := next(runCtx, ep)
+			log.Ctx(ctx).Debug().
+				Str(TraceField, result.Trace).
+				Str("id", result.Id).
+				Str("address", ep.Address).
+				Str("type", string(ep.Type)).
+				Int64("latency_ms", result.Ms).
+				Int("status", result.St).
+				Str("error", errStr(err)).
+				Str("error_kind", string(result.Ek)).
+				Interface("tags", result.Tags).
+				Msg("Endpoint tested")
+			return result, err
+		}
+	}
+}
+
+func errStr(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,88 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func init() {
+	register(Protocol{
+		Type:             models.TypeTCP,
+		DefaultTimeoutMs: 2000,
+		AllAddresses:     true,
+		Validate:         validateHostPort,
+		Run: func(ctx context.Context, ep models.Endpoint, timeout time.Duration) (Measurement, error) {
+			if ep.TCP != nil && ep.TCP.Reuse {
+				return checkTCPReuse(ctx, ep.Address, []byte(ep.TCP.Payload), timeout)
+			}
+			d, err := checkTCP(ctx, ep.Address, timeout)
+			return Measurement{Latency: d}, err
+		},
+	})
+}
+
+// TagReuseMs records the round trip of a payload sent over the connection of
+// a TCP reuse test, while the result's latency is the connect time
+const TagReuseMs = "reuse_ms"
+
+// validateHostPort checks addresses of the form host:port
+func validateHostPort(address string) error {
+	_, _, err := net.SplitHostPort(address)
+	return err
+}
+
+// ValidateTCPOptions checks the options of a TCP endpoint, if it has any
+func ValidateTCPOptions(o *models.TCPOptions) error {
+	if o != nil && o.Reuse && o.Payload == "" {
+		return errors.New("connection reuse needs a payload the server answers")
+	}
+	return nil
+}
+
+func checkTCP(ctx context.Context, address string, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialConn(ctx, &dialer, "tcp", address)
+	if err != nil {
+		return time.Since(start), err
+	}
+	conn.Close()
+	return time.Since(start), nil
+}
+
+// checkTCPReuse connects like checkTCP, then times a payload round trip on
+// the established connection. A slow connect with a fast round trip points
+// at the handshake, e.g. a loaded listener or SYN loss, rather than the path.
+func checkTCPReuse(ctx context.Context, address string, payload []byte, timeout time.Duration) (Measurement, error) {
+	start := time.Now()
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialConn(ctx, &dialer, "tcp", address)
+	m := Measurement{Latency: time.Since(start)}
+	if err != nil {
+		return m, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	conn.SetDeadline(start.Add(timeout))
+
+	sent := time.Now()
+	if _, err := conn.Write(payload); err != nil {
+		return m, fmt.Errorf("reused connection: %w", err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		if ctx.Err() != nil {
+			return m, ctx.Err()
+		}
+		return m, fmt.Errorf("reused connection: %w", err)
+	}
+	rtt := time.Since(sent)
+	m.Tags = map[string]string{TagReuseMs: strconv.FormatFloat(float64(rtt.Microseconds())/1000, 'f', 3, 64)}
+	return m, nil
+}
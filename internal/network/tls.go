@@ -0,0 +1,142 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func init() {
+	register(Protocol{
+		Type:             models.TypeTLS,
+		DefaultTimeoutMs: 5000,
+		AllAddresses:     true,
+		Validate:         validateHostPort,
+		Run: func(ctx context.Context, ep models.Endpoint, timeout time.Duration) (Measurement, error) {
+			return checkTLS(ctx, ep.Address, timeout)
+		},
+	})
+}
+
+// Tags of TLS tests and HTTPS results describing the negotiated connection
+// and the certificate the server presented
+const (
+	TagTLSVersion     = "tls_version"      // e.g. "TLS 1.3"
+	TagTLSCipher      = "tls_cipher"       // e.g. "TLS_AES_128_GCM_SHA256"
+	TagCertSubject    = "cert_subject"     // Common name, or first DNS name, of the server certificate
+	TagCertIssuer     = "cert_issuer"      // Common name of its issuer
+	TagCertChain      = "cert_chain"       // Common names from the server certificate to the root, separated by " > "
+	TagCertNotAfter   = "cert_not_after"   // RFC 3339 expiry of the first certificate of the chain to expire
+	TagCertExpiryDays = "cert_expiry_days" // Whole days until then, negative once expired
+)
+
+// checkTLS times a TCP connect and TLS handshake. Certificates are verified
+// like a browser would, but the connection and certificate are recorded even
+// when verification fails, so an expired certificate still shows its expiry.
+func checkTLS(ctx context.Context, address string, timeout time.Duration) (Measurement, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return Measurement{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialConn(ctx, &dialer, "tcp", address)
+	m := Measurement{Latency: time.Since(start)}
+	if err != nil {
+		return m, err
+	}
+	defer conn.Close()
+	connect := m.Latency
+
+	var state *tls.ConnectionState
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName: host,
+		// Verified in VerifyConnection instead, after recording the state
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			state = &cs
+			return verifyTLS(cs, host)
+		},
+	})
+	err = tlsConn.HandshakeContext(ctx)
+	m.Latency = time.Since(start)
+	if err == nil {
+		cs := tlsConn.ConnectionState()
+		state = &cs
+	}
+	m.Tags = map[string]string{TagConnectMs: formatMs(connect)}
+	if state != nil {
+		addTLSTags(m.Tags, *state, time.Now())
+	}
+	return m, err
+}
+
+// verifyTLS verifies the server certificate like crypto/tls does without
+// InsecureSkipVerify
+func verifyTLS(cs tls.ConnectionState, host string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server sent no certificate")
+	}
+	opts := x509.VerifyOptions{DNSName: host, Intermediates: x509.NewCertPool()}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// addTLSTags records the negotiated version and cipher and the server
+// certificate chain. The expiry is that of the first certificate of the
+// chain to expire, since an expired intermediate breaks clients as well.
+func addTLSTags(tags map[string]string, cs tls.ConnectionState, now time.Time) {
+	tags[TagTLSVersion] = tls.VersionName(cs.Version)
+	tags[TagTLSCipher] = tls.CipherSuiteName(cs.CipherSuite)
+	chain := cs.PeerCertificates
+	if len(cs.VerifiedChains) > 0 {
+		chain = cs.VerifiedChains[0]
+	}
+	if len(chain) == 0 {
+		return
+	}
+	leaf := chain[0]
+	subject := leaf.Subject.CommonName
+	if subject == "" && len(leaf.DNSNames) > 0 {
+		subject = leaf.DNSNames[0]
+	}
+	tags[TagCertSubject] = subject
+	tags[TagCertIssuer] = leaf.Issuer.CommonName
+
+	names := make([]string, len(chain))
+	notAfter := leaf.NotAfter
+	for i, cert := range chain {
+		names[i] = cert.Subject.CommonName
+		if cert.NotAfter.Before(notAfter) {
+			notAfter = cert.NotAfter
+		}
+	}
+	tags[TagCertChain] = strings.Join(names, " > ")
+	tags[TagCertNotAfter] = notAfter.UTC().Format(time.RFC3339)
+	tags[TagCertExpiryDays] = strconv.Itoa(int(math.Floor(notAfter.Sub(now).Hours() / 24)))
+}
+
+// CertExpiryDays returns the days until the certificate recorded in a
+// result expires, if the result has one
+func CertExpiryDays(r models.TestResult) (int, bool) {
+	v, ok := r.Tags[TagCertExpiryDays]
+	if !ok {
+		return 0, false
+	}
+	days, err := strconv.Atoi(v)
+	return days, err == nil
+}
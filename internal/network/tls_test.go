@@ -0,0 +1,37 @@
+package network
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func TestCheckTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	address := strings.TrimPrefix(srv.URL, "https://")
+
+	// The test server's certificate isn't trusted, but is still recorded
+	m, err := checkTLS(context.Background(), address, time.Second)
+	if ClassifyError(err, models.TestStatusError) != models.ErrorKindTLS {
+		t.Errorf("Expected a TLS error for an untrusted certificate, got %v", err)
+	}
+	cert := srv.Certificate()
+	days, ok := CertExpiryDays(models.TestResult{Tags: m.Tags})
+	if !ok || days < 365 {
+		t.Errorf("Expected the certificate expiry in days, got %v", m.Tags)
+	}
+	if m.Tags[TagCertNotAfter] != cert.NotAfter.UTC().Format(time.RFC3339) || m.Tags[TagTLSVersion] != "TLS 1.3" ||
+		m.Tags[TagTLSCipher] == "" || m.Tags[TagConnectMs] == "" {
+		t.Errorf("Unexpected TLS tags %v", m.Tags)
+	}
+
+	if _, err := checkTLS(context.Background(), "127.0.0.1:1", time.Second); err == nil {
+		t.Errorf("Expected an error for a closed port")
+	}
+}
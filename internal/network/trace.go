@@ -0,0 +1,41 @@
+package network
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Every test carries a trace ID, stored with its result and added to the log
+// lines written while it runs, so a failed result shown in the UI can be
+// matched with its log lines. Tests of a scheduling cycle or batch share the
+// cycle's run ID as a prefix: run.n for the nth test queued.
+
+// TraceField is the log field holding the trace ID of a test
+const TraceField = "trace"
+
+type traceKey struct{}
+
+// NewRunID returns a random ID for a scheduling cycle or another group of
+// tests, also used as the trace of tests run on their own
+func NewRunID() string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// WithTrace returns a context that gives the test run with it the trace ID,
+// and adds it to the context's logger
+func WithTrace(ctx context.Context, trace string) context.Context {
+	ctx = context.WithValue(ctx, traceKey{}, trace)
+	l := log.Ctx(ctx).With().Str(TraceField, trace).Logger()
+	return l.WithContext(ctx)
+}
+
+// TraceFrom returns the trace ID set with WithTrace, if any
+func TraceFrom(ctx context.Context) string {
+	trace, _ := ctx.Value(traceKey{}).(string)
+	return trace
+}
@@ -0,0 +1,177 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// Result tags of traceroute tests
+const (
+	TagHops = "hops" // Comma separated hop addresses, "*" for hops that didn't answer
+)
+
+const (
+	traceMaxHops  = 30
+	traceBasePort = 33434 // Classic traceroute destination port range
+
+	icmpTimeExceeded    = 11
+	icmpDestUnreachable = 3
+)
+
+func init() {
+	register(Protocol{
+		Type:             models.TypeTraceroute,
+		DefaultTimeoutMs: 1000,
+		Run: func(ctx context.Context, ep models.Endpoint, timeout time.Duration) (Measurement, error) {
+			hops, err := Traceroute(ctx, ep.Address, traceMaxHops, timeout)
+			if err != nil {
+				return Measurement{}, err
+			}
+			last := hops[len(hops)-1]
+			m := Measurement{
+				Latency: last.RTT,
+				Tags:    map[string]string{TagHops: HopSignature(hops)},
+			}
+			if !last.Reached {
+				return m, fmt.Errorf("destination not reached within %d hops", len(hops))
+			}
+			return m, nil
+		},
+	})
+}
+
+// Hop is one step of a traced path
+type Hop struct {
+	TTL     int
+	Addr    string // Empty when the hop didn't answer
+	RTT     time.Duration
+	Reached bool // The destination itself answered
+}
+
+// Traceroute sends UDP probes with increasing TTL to the IPv4 address of host
+// and collects the ICMP replies. It needs a raw ICMP socket, so it requires
+// administrator rights on Windows and root or CAP_NET_RAW elsewhere. The
+// timeout applies to each hop.
+func Traceroute(ctx context.Context, host string, maxHops int, timeout time.Duration) ([]Hop, error) {
+	addrs, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	if err != nil {
+		return nil, err
+	}
+	dst := addrs[0]
+
+	icmp, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return nil, fmt.Errorf("traceroute needs a raw ICMP socket: %w", err)
+	}
+	defer icmp.Close()
+	stop := context.AfterFunc(ctx, func() { icmp.SetDeadline(time.Now()) })
+	defer stop()
+
+	var hops []Hop
+	for ttl := 1; ttl <= maxHops; ttl++ {
+		hop, err := probeHop(ctx, icmp, dst, ttl, timeout)
+		if err != nil {
+			return hops, err
+		}
+		hops = append(hops, hop)
+		if hop.Reached {
+			break
+		}
+	}
+	return hops, nil
+}
+
+func probeHop(ctx context.Context, icmp net.PacketConn, dst net.IP, ttl int, timeout time.Duration) (Hop, error) {
+	port := traceBasePort + ttl
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: dst, Port: port})
+	if err != nil {
+		return Hop{}, err
+	}
+	defer conn.Close()
+	if err := setTTL(conn, ttl); err != nil {
+		return Hop{}, fmt.Errorf("setting TTL: %w", err)
+	}
+
+	start := time.Now()
+	if _, err := conn.Write([]byte("netmonitor")); err != nil {
+		return Hop{}, err
+	}
+
+	hop := Hop{TTL: ttl}
+	deadline := start.Add(timeout)
+	icmp.SetReadDeadline(deadline)
+	buf := make([]byte, 1500)
+	for time.Now().Before(deadline) {
+		n, from, err := icmp.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return hop, ctx.Err()
+			}
+			return hop, nil // No answer from this hop
+		}
+		typ, probePort, ok := parseICMPReply(buf[:n])
+		if !ok || probePort != port {
+			continue // Reply to another probe or unrelated traffic
+		}
+		hop.Addr = from.String()
+		hop.RTT = time.Since(start)
+		hop.Reached = typ == icmpDestUnreachable
+		return hop, nil
+	}
+	return hop, nil
+}
+
+// parseICMPReply extracts the type of an ICMP time exceeded or destination
+// unreachable message and the destination port of the UDP probe it quotes
+func parseICMPReply(msg []byte) (typ int, port int, ok bool) {
+	if len(msg) < 8 {
+		return 0, 0, false
+	}
+	typ = int(msg[0])
+	if typ != icmpTimeExceeded && typ != icmpDestUnreachable {
+		return 0, 0, false
+	}
+	quoted := msg[8:]
+	if len(quoted) < 20 {
+		return 0, 0, false
+	}
+	ihl := int(quoted[0]&0x0F) * 4
+	if quoted[9] != 17 || len(quoted) < ihl+4 { // Only UDP probes
+		return 0, 0, false
+	}
+	return typ, int(binary.BigEndian.Uint16(quoted[ihl+2:])), true
+}
+
+// HopSignature encodes the hop addresses of a path, "*" for silent hops
+func HopSignature(hops []Hop) string {
+	addrs := make([]string, len(hops))
+	for i, h := range hops {
+		addrs[i] = h.Addr
+		if addrs[i] == "" {
+			addrs[i] = "*"
+		}
+	}
+	return strings.Join(addrs, ",")
+}
+
+// PathsDiffer reports whether two hop signatures describe different routes.
+// Silent hops match any address, since routers often rate limit ICMP and
+// would otherwise make every run look like a route change.
+func PathsDiffer(a, b string) bool {
+	ha, hb := strings.Split(a, ","), strings.Split(b, ",")
+	if len(ha) != len(hb) {
+		return true
+	}
+	for i := range ha {
+		if ha[i] != "*" && hb[i] != "*" && ha[i] != hb[i] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package network
+
+import (
+	"net"
+	"syscall"
+)
+
+// setTTL sets the IP time to live of packets sent on conn
+func setTTL(conn *net.UDPConn, ttl int) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL, ttl)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
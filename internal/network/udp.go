@@ -0,0 +1,185 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func init() {
+	register(Protocol{
+		Type:             models.TypeUDP,
+		DefaultTimeoutMs: 2000,
+		AllAddresses:     true,
+		Validate:         validateHostPort,
+		Run: func(ctx context.Context, ep models.Endpoint, timeout time.Duration) (Measurement, error) {
+			if ep.UDP != nil {
+				d, err := checkUDPOptions(ctx, ep.Address, *ep.UDP, timeout)
+				return Measurement{Latency: d}, err
+			}
+			d, err := checkUDP(ctx, ep.Address, timeout)
+			return Measurement{Latency: d}, err
+		},
+	})
+}
+
+func checkUDP(ctx context.Context, address string, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialConn(ctx, &dialer, "udp", address)
+	if err != nil {
+		return time.Since(start), err
+	}
+	defer conn.Close()
+
+	// Attempt to write a byte to verify socket
+	_, err = conn.Write([]byte{0})
+	return time.Since(start), err
+}
+
+// udpProbe is a compiled UDPOptions
+type udpProbe struct {
+	payload []byte
+	prefix  []byte
+	re      *regexp.Regexp
+	pattern []int // Bytes to match at the start of the reply, -1 for any
+}
+
+func (p udpProbe) expectsReply() bool {
+	return p.prefix != nil || p.re != nil || p.pattern != nil
+}
+
+// ValidateUDPOptions checks the options of a UDP endpoint, if it has any
+func ValidateUDPOptions(o *models.UDPOptions) error {
+	if o == nil {
+		return nil
+	}
+	_, err := compileUDPOptions(*o)
+	return err
+}
+
+func compileUDPOptions(o models.UDPOptions) (udpProbe, error) {
+	p := udpProbe{payload: []byte{0}}
+	switch {
+	case o.Payload != "" && o.PayloadHex != "":
+		return p, errors.New("set either a payload or a hex payload, not both")
+	case o.Payload != "":
+		p.payload = []byte(o.Payload)
+	case o.PayloadHex != "":
+		b, err := hex.DecodeString(strings.ReplaceAll(o.PayloadHex, " ", ""))
+		if err != nil {
+			return p, fmt.Errorf("invalid hex payload: %w", err)
+		}
+		p.payload = b
+	}
+	if o.ExpectPrefix != "" {
+		p.prefix = []byte(o.ExpectPrefix)
+	}
+	if o.ExpectRegex != "" {
+		re, err := regexp.Compile(o.ExpectRegex)
+		if err != nil {
+			return p, fmt.Errorf("invalid expected response pattern: %w", err)
+		}
+		p.re = re
+	}
+	if o.ExpectHex != "" {
+		pattern, err := parseBytePattern(o.ExpectHex)
+		if err != nil {
+			return p, fmt.Errorf("invalid expected hex response: %w", err)
+		}
+		p.pattern = pattern
+	}
+	return p, nil
+}
+
+// parseBytePattern parses hex bytes where ?? matches any byte. Spaces are
+// ignored.
+func parseBytePattern(s string) ([]int, error) {
+	s = strings.ReplaceAll(s, " ", "")
+	if len(s)%2 != 0 {
+		return nil, errors.New("odd number of hex digits")
+	}
+	pattern := make([]int, 0, len(s)/2)
+	for i := 0; i < len(s); i += 2 {
+		if s[i:i+2] == "??" {
+			pattern = append(pattern, -1)
+			continue
+		}
+		b, err := hex.DecodeString(s[i : i+2])
+		if err != nil {
+			return nil, err
+		}
+		pattern = append(pattern, int(b[0]))
+	}
+	return pattern, nil
+}
+
+// match returns why the reply doesn't meet the expectations, or "" if it does
+func (p udpProbe) match(reply []byte) string {
+	if p.prefix != nil && !bytes.HasPrefix(reply, p.prefix) {
+		return "prefix doesn't match"
+	}
+	if p.re != nil && !p.re.Match(reply) {
+		return "pattern doesn't match"
+	}
+	if p.pattern != nil {
+		if len(reply) < len(p.pattern) {
+			return "reply shorter than the expected bytes"
+		}
+		for i, b := range p.pattern {
+			if b >= 0 && int(reply[i]) != b {
+				return fmt.Sprintf("byte %d is %02x, expected %02x", i, reply[i], b)
+			}
+		}
+	}
+	return ""
+}
+
+// checkUDPOptions sends the configured payload and, when the endpoint
+// expects a reply, times it and checks its content so a test only passes
+// when the right service answered
+func checkUDPOptions(ctx context.Context, address string, o models.UDPOptions, timeout time.Duration) (time.Duration, error) {
+	p, err := compileUDPOptions(o)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialConn(ctx, &dialer, "udp", address)
+	if err != nil {
+		return time.Since(start), err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	conn.SetDeadline(start.Add(timeout))
+
+	if _, err := conn.Write(p.payload); err != nil {
+		return time.Since(start), err
+	}
+	if !p.expectsReply() {
+		return time.Since(start), nil
+	}
+
+	reply := make([]byte, 64*1024)
+	n, err := conn.Read(reply)
+	d := time.Since(start)
+	if err != nil {
+		if ctx.Err() != nil {
+			return d, ctx.Err()
+		}
+		return d, err
+	}
+	if reason := p.match(reply[:n]); reason != "" {
+		return d, fmt.Errorf("%w: %s", ErrUnexpectedResponse, reason)
+	}
+	return d, nil
+}
@@ -0,0 +1,98 @@
+package network
+
+import (
+	"context"
+	"net"
+	"net/http/httptrace"
+	"sync/atomic"
+)
+
+// Usage counts the bytes a test sent and received, so the traffic monitoring
+// itself causes can be accounted for on metered connections. Protocols count
+// connections dialed with dialConn automatically; those that don't use
+// connections report their traffic in their Measurement instead. Bytes are
+// counted above the transport, without IP, TCP or UDP headers.
+type Usage struct {
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+// Sent returns the bytes sent so far
+func (u *Usage) Sent() int64 { return u.sent.Load() }
+
+// Received returns the bytes received so far
+func (u *Usage) Received() int64 { return u.received.Load() }
+
+type usageKey struct{}
+
+// withUsage returns a context whose connections count towards u
+func withUsage(ctx context.Context, u *Usage) context.Context {
+	return context.WithValue(ctx, usageKey{}, u)
+}
+
+// usageFrom returns the usage counted in ctx, or nil
+func usageFrom(ctx context.Context) *Usage {
+	u, _ := ctx.Value(usageKey{}).(*Usage)
+	return u
+}
+
+// countingConn counts traffic towards the usage of the test currently using
+// the connection, which changes when a pooled connection is reused
+type countingConn struct {
+	net.Conn
+	usage atomic.Pointer[Usage]
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if u := c.usage.Load(); u != nil {
+		u.received.Add(int64(n))
+	}
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if u := c.usage.Load(); u != nil {
+		u.sent.Add(int64(n))
+	}
+	return n, err
+}
+
+func countConn(ctx context.Context, conn net.Conn) *countingConn {
+	c := &countingConn{Conn: conn}
+	c.usage.Store(usageFrom(ctx))
+	return c
+}
+
+// dialConn dials address like dialer.DialContext, connecting to the IP
+// pinned in ctx if there is one, and counts the connection's traffic towards
+// the test's usage
+func dialConn(ctx context.Context, dialer *net.Dialer, network, address string) (net.Conn, error) {
+	conn, err := dialer.DialContext(ctx, network, pinAddress(ctx, address))
+	if err != nil {
+		return nil, err
+	}
+	return countConn(ctx, conn), nil
+}
+
+// traceUsage returns a context that makes HTTP requests count the traffic of
+// the connection they get towards the test's usage, including connections
+// pooled by an earlier test. The transport must dial with dialConn.
+func traceUsage(ctx context.Context) context.Context {
+	u := usageFrom(ctx)
+	if u == nil {
+		return ctx
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			conn := info.Conn
+			if tc, ok := conn.(interface{ NetConn() net.Conn }); ok {
+				conn = tc.NetConn()
+			}
+			if c, ok := conn.(*countingConn); ok {
+				c.usage.Store(u)
+			}
+		},
+	})
+}
@@ -0,0 +1,25 @@
+//go:build darwin
+
+package onboarding
+
+import (
+	"errors"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+func defaultGateway() (net.IP, error) {
+	out, err := exec.Command("route", "-n", "get", "default").Output()
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "gateway:"); ok {
+			if ip := net.ParseIP(strings.TrimSpace(v)); ip != nil {
+				return ip, nil
+			}
+		}
+	}
+	return nil, errors.New("no default route")
+}
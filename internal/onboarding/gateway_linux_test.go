@@ -0,0 +1,24 @@
+package onboarding
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProcRoute(t *testing.T) {
+	routes := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	0001A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+`
+	gw, err := parseProcRoute(strings.NewReader(routes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gw.String() != "192.168.1.1" {
+		t.Errorf("Expected 192.168.1.1, got %s", gw)
+	}
+
+	if _, err := parseProcRoute(strings.NewReader("Iface\tDestination\tGateway\n")); err == nil {
+		t.Errorf("Expected error without a default route")
+	}
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package onboarding
+
+import (
+	"errors"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+func defaultGateway() (net.IP, error) {
+	out, err := exec.Command("route", "print", "-4", "0.0.0.0").Output()
+	if err != nil {
+		return nil, err
+	}
+	// Active routes are listed as: destination netmask gateway interface metric
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 5 && fields[0] == "0.0.0.0" && fields[1] == "0.0.0.0" {
+			if ip := net.ParseIP(fields[2]); ip != nil {
+				return ip, nil
+			}
+		}
+	}
+	return nil, errors.New("no default route")
+}
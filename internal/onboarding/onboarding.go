@@ -0,0 +1,153 @@
+// Package onboarding proposes and builds the first configuration of a new
+// install from what can be detected about the local network.
+package onboarding
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultRegion is the name proposed for the starter region
+const DefaultRegion = "Home"
+
+// dnsCheckName is the name queried by the proposed resolver checks
+const dnsCheckName = "example.com"
+
+// Detect looks up the default gateway and the system resolvers and proposes
+// a starter region testing them along with a few well known internet hosts.
+// Anything that can't be detected is left out of the proposal.
+func Detect(ctx context.Context) models.OnboardingProposal {
+	p := models.OnboardingProposal{Region: DefaultRegion}
+
+	gw, err := defaultGateway()
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Failed to detect default gateway")
+	} else {
+		p.Gateway = gw.String()
+	}
+	p.DNSServers, err = systemResolvers()
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Failed to detect DNS servers")
+	}
+
+	if p.Gateway != "" {
+		p.Endpoints = append(p.Endpoints, models.Endpoint{Name: "Gateway", Type: models.TypeICMP, Address: p.Gateway, Timeout: 1000})
+	}
+	for i, server := range p.DNSServers {
+		name := "DNS"
+		if len(p.DNSServers) > 1 {
+			name = fmt.Sprintf("DNS %d", i+1)
+		}
+		p.Endpoints = append(p.Endpoints, models.Endpoint{
+			Name:    name,
+			Type:    models.TypeDNS,
+			Address: "udp://" + net.JoinHostPort(server, "53") + "#" + dnsCheckName,
+			Timeout: 2000,
+		})
+	}
+	p.Endpoints = append(p.Endpoints,
+		models.Endpoint{Name: "Google DNS", Type: models.TypeICMP, Address: "8.8.8.8", Timeout: 1000},
+		models.Endpoint{Name: "Cloudflare", Type: models.TypeHTTP, Address: "https://1.1.1.1", Timeout: 5000},
+	)
+	return p
+}
+
+// Validate checks a selection before it becomes the configuration
+func Validate(sel models.OnboardingSelection, protocols *network.Registry) error {
+	if strings.TrimSpace(sel.Region) == "" {
+		return errors.New("region name is required")
+	}
+	if len(sel.Endpoints) == 0 {
+		return errors.New("select at least one endpoint")
+	}
+	if sel.TestIntervalSeconds < 0 {
+		return errors.New("test interval must not be negative")
+	}
+	seen := make(map[string]bool)
+	for _, ep := range sel.Endpoints {
+		if ep.Name == "" || ep.Address == "" {
+			return fmt.Errorf("endpoint %q: name and address are required", ep.Name)
+		}
+		if ep.Timeout < 0 {
+			return fmt.Errorf("endpoint %s: timeout must not be negative", ep.Name)
+		}
+		if err := protocols.Validate(ep.Type, ep.Address); err != nil {
+			return fmt.Errorf("endpoint %s: %w", ep.Name, err)
+		}
+		id := network.EndpointID(ep.Address, ep.Type)
+		if seen[id] {
+			return fmt.Errorf("endpoint %s is selected twice", ep.Name)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// Apply returns a copy of base whose regions are replaced by the selected
+// region. Endpoints without a timeout get their protocol's default.
+func Apply(base models.Configuration, sel models.OnboardingSelection, protocols *network.Registry) models.Configuration {
+	cfg := base
+	endpoints := make([]models.Endpoint, len(sel.Endpoints))
+	for i, ep := range sel.Endpoints {
+		if ep.Timeout == 0 {
+			ep.Timeout = 1000
+			if p, ok := protocols.Lookup(ep.Type); ok && p.DefaultTimeoutMs > 0 {
+				ep.Timeout = p.DefaultTimeoutMs
+			}
+		}
+		endpoints[i] = ep
+	}
+	thresholds := models.Thresholds{LatencyMs: 100, AvailabilityPercent: 99.0}
+	if r, ok := base.Regions["Default"]; ok {
+		thresholds = r.Thresholds
+	}
+	cfg.Regions = map[string]models.Region{
+		strings.TrimSpace(sel.Region): {Endpoints: endpoints, Thresholds: thresholds},
+	}
+	if sel.TestIntervalSeconds > 0 {
+		cfg.Settings.TestIntervalSeconds = sel.TestIntervalSeconds
+	}
+	cfg.Settings.OnboardingPending = false
+	return cfg
+}
+
+// systemResolvers returns the name servers in /etc/resolv.conf. Systems
+// without it report none.
+func systemResolvers() ([]string, error) {
+	f, err := os.Open("/etc/resolv.conf")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseResolvConf(f)
+}
+
+func parseResolvConf(r io.Reader) ([]string, error) {
+	var servers []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		// Drop IPv6 zones, endpoint addresses can't carry them
+		addr, _, _ := strings.Cut(fields[1], "%")
+		if net.ParseIP(addr) != nil {
+			servers = append(servers, addr)
+		}
+	}
+	return servers, scanner.Err()
+}
@@ -0,0 +1,71 @@
+package onboarding
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+func TestParseResolvConf(t *testing.T) {
+	conf := `# Generated by NetworkManager
+search example.lan
+nameserver 192.168.1.1
+nameserver fe80::1%eth0
+nameserver not-an-ip
+options edns0
+`
+	servers, err := parseResolvConf(strings.NewReader(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 2 || servers[0] != "192.168.1.1" || servers[1] != "fe80::1" {
+		t.Errorf("Unexpected servers: %v", servers)
+	}
+}
+
+func TestValidateAndApply(t *testing.T) {
+	protocols := network.NewRegistry()
+	sel := models.OnboardingSelection{
+		Region: "Home",
+		Endpoints: []models.Endpoint{
+			{Name: "Gateway", Type: models.TypeICMP, Address: "192.168.1.1"},
+			{Name: "DNS", Type: models.TypeDNS, Address: "udp://192.168.1.1:53#example.com", Timeout: 500},
+		},
+	}
+	if err := Validate(sel, protocols); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+
+	base := models.Configuration{
+		Regions:  map[string]models.Region{"Default": {Endpoints: []models.Endpoint{{Name: "old"}}}},
+		Settings: models.AppSettings{TestIntervalSeconds: 300, OnboardingPending: true},
+	}
+	cfg := Apply(base, sel, protocols)
+	region, ok := cfg.Regions["Home"]
+	if len(cfg.Regions) != 1 || !ok || len(region.Endpoints) != 2 {
+		t.Fatalf("Unexpected regions: %+v", cfg.Regions)
+	}
+	if region.Endpoints[0].Timeout <= 0 || region.Endpoints[1].Timeout != 500 {
+		t.Errorf("Unexpected timeouts: %+v", region.Endpoints)
+	}
+	if cfg.Settings.OnboardingPending || cfg.Settings.TestIntervalSeconds != 300 {
+		t.Errorf("Unexpected settings: %+v", cfg.Settings)
+	}
+	if _, ok := base.Regions["Default"]; !ok || len(base.Regions) != 1 {
+		t.Errorf("Apply modified the base config")
+	}
+
+	invalid := []models.OnboardingSelection{
+		{Region: " ", Endpoints: sel.Endpoints},
+		{Region: "Home"},
+		{Region: "Home", Endpoints: []models.Endpoint{{Name: "x", Type: "BOGUS", Address: "y"}}},
+		{Region: "Home", Endpoints: []models.Endpoint{sel.Endpoints[0], sel.Endpoints[0]}},
+	}
+	for i, s := range invalid {
+		if err := Validate(s, protocols); err == nil {
+			t.Errorf("Selection %d: expected validation error", i)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Package plugins runs custom test types implemented as external executables.
+//
+// A plugin is any executable file in the plugins directory that implements two
+// subcommands:
+//
+//	<plugin> describe
+//	    Prints a JSON object {"type": "REDIS", "name": "Redis PING", "version": "1.0"}
+//	    describing the endpoint type the plugin handles.
+//
+//	<plugin> test
+//	    Reads a JSON object {"address": "...", "timeout_ms": 2000} from stdin and
+//	    prints {"ok": true, "latency_ms": 12.5} or {"ok": false, "error": "..."}.
+//
+// Latency is measured by the plugin itself when reported, otherwise the wall
+// time of the test subcommand is used.
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+	"github.com/rs/zerolog/log"
+)
+
+// describeTimeout bounds how long a plugin may take to describe itself
+const describeTimeout = 5 * time.Second
+
+// DefaultTimeoutMs is the timeout of plugin tests whose endpoint doesn't set
+// one. Plugins start a process per test, so it is generous.
+const DefaultTimeoutMs = 5000
+
+var typePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// Plugin is a discovered plugin executable
+type Plugin struct {
+	Info models.PluginInfo
+}
+
+type testRequest struct {
+	Address   string `json:"address"`
+	TimeoutMs int    `json:"timeout_ms"`
+}
+
+type testResponse struct {
+	OK        bool    `json:"ok"`
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Test runs the plugin's test subcommand against the address
+func (p *Plugin) Test(ctx context.Context, address string, timeout time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	in, _ := json.Marshal(testRequest{Address: address, TimeoutMs: int(timeout.Milliseconds())})
+	cmd := exec.CommandContext(ctx, p.Info.Path, "test")
+	cmd.Stdin = bytes.NewReader(in)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(start)
+	if ctx.Err() != nil {
+		return elapsed, ctx.Err()
+	}
+	if err != nil {
+		return elapsed, fmt.Errorf("plugin %s: %w: %s", p.Info.Type, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var resp testResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return elapsed, fmt.Errorf("plugin %s: invalid test output: %w", p.Info.Type, err)
+	}
+	if resp.LatencyMs > 0 {
+		elapsed = time.Duration(resp.LatencyMs * float64(time.Millisecond))
+	}
+	if !resp.OK {
+		if resp.Error == "" {
+			resp.Error = "test failed"
+		}
+		return elapsed, errors.New(resp.Error)
+	}
+	return elapsed, nil
+}
+
+// Protocol adapts the plugin to the network test registry
+func (p *Plugin) Protocol() network.Protocol {
+	return network.Protocol{
+		Type:             p.Info.Type,
+		DefaultTimeoutMs: DefaultTimeoutMs,
+		Run: func(ctx context.Context, ep models.Endpoint, timeout time.Duration) (network.Measurement, error) {
+			d, err := p.Test(ctx, ep.Address, timeout)
+			return network.Measurement{Latency: d}, err
+		},
+	}
+}
+
+// Registry holds the discovered plugins, keyed by endpoint type
+type Registry struct {
+	mu        sync.RWMutex
+	plugins   map[models.EndpointType]*Plugin
+	protocols *network.Registry
+}
+
+// Discover describes every executable in dir and registers the valid ones,
+// also in protocols so the monitor can test their endpoint types. Invalid
+// plugins are logged and skipped. A missing directory yields an empty registry.
+func Discover(ctx context.Context, dir string, protocols *network.Registry) *Registry {
+	r := &Registry{plugins: make(map[models.EndpointType]*Plugin), protocols: protocols}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Ctx(ctx).Error().Err(err).Str("dir", dir).Msg("Failed to read plugins directory")
+		}
+		return r
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if !isExecutable(e) {
+			continue
+		}
+		info, err := describe(ctx, path)
+		if err == nil {
+			err = r.Register(&Plugin{Info: info})
+		}
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("path", path).Msg("Skipping invalid plugin")
+			continue
+		}
+		log.Ctx(ctx).Info().Str("type", string(info.Type)).Str("name", info.Name).Str("version", info.Version).Msg("Plugin registered")
+	}
+	return r
+}
+
+// Register validates and adds a plugin. Types already provided by a built-in
+// protocol or another plugin are rejected.
+func (r *Registry) Register(p *Plugin) error {
+	if !typePattern.MatchString(string(p.Info.Type)) {
+		return fmt.Errorf("invalid plugin type %q: must be upper case letters, digits and underscores", p.Info.Type)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.plugins[p.Info.Type]; ok {
+		return fmt.Errorf("plugin type %s already provided by %s", p.Info.Type, existing.Info.Path)
+	}
+	if r.protocols != nil {
+		if err := r.protocols.Register(p.Protocol()); err != nil {
+			return err
+		}
+	}
+	r.plugins[p.Info.Type] = p
+	return nil
+}
+
+// Lookup returns the plugin handling the endpoint type, if any
+func (r *Registry) Lookup(t models.EndpointType) (*Plugin, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.plugins[t]
+	return p, ok
+}
+
+// List returns the registered plugins sorted by type
+func (r *Registry) List() []models.PluginInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	infos := make([]models.PluginInfo, 0, len(r.plugins))
+	for _, p := range r.plugins {
+		infos = append(infos, p.Info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Type < infos[j].Type })
+	return infos
+}
+
+func describe(ctx context.Context, path string) (models.PluginInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, describeTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, path, "describe").Output()
+	if err != nil {
+		return models.PluginInfo{}, fmt.Errorf("describe failed: %w", err)
+	}
+	var info models.PluginInfo
+	if err := json.Unmarshal(out, &info); err != nil {
+		return models.PluginInfo{}, fmt.Errorf("invalid describe output: %w", err)
+	}
+	if info.Name == "" {
+		info.Name = string(info.Type)
+	}
+	info.Path = path
+	return info, nil
+}
+
+func isExecutable(e os.DirEntry) bool {
+	if e.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(e.Name()), ".exe")
+	}
+	info, err := e.Info()
+	return err == nil && info.Mode().Perm()&0111 != 0
+}
@@ -0,0 +1,75 @@
+package plugins
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+func writePlugin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscoverAndTest(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin test uses shell scripts")
+	}
+	dir := t.TempDir()
+
+	writePlugin(t, dir, "redis", `
+case "$1" in
+describe) echo '{"type":"REDIS","name":"Redis PING","version":"1.0"}' ;;
+test) read req; case "$req" in *up*) echo '{"ok":true,"latency_ms":12.5}' ;; *) echo '{"ok":false,"error":"no PONG"}' ;; esac ;;
+esac`)
+	writePlugin(t, dir, "shadow", `echo '{"type":"HTTP"}'`)
+	writePlugin(t, dir, "broken", `echo not json`)
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("not a plugin"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	protocols := network.NewRegistry()
+	r := Discover(context.Background(), dir, protocols)
+	list := r.List()
+	if len(list) != 1 || list[0].Type != "REDIS" || list[0].Name != "Redis PING" {
+		t.Fatalf("Unexpected plugins: %+v", list)
+	}
+
+	p, ok := r.Lookup("REDIS")
+	if !ok {
+		t.Fatal("REDIS plugin not found")
+	}
+	if proto, ok := protocols.Lookup("REDIS"); !ok || proto.DefaultTimeoutMs != DefaultTimeoutMs {
+		t.Errorf("REDIS plugin not registered as a protocol with a default timeout: %+v", proto)
+	}
+	d, err := p.Test(context.Background(), "up:6379", time.Second)
+	if err != nil || d != 12500*time.Microsecond {
+		t.Errorf("Unexpected test result: %v, %v", d, err)
+	}
+	if _, err := p.Test(context.Background(), "down:6379", time.Second); err == nil || err.Error() != "no PONG" {
+		t.Errorf("Expected plugin error, got %v", err)
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	r := Discover(context.Background(), filepath.Join(t.TempDir(), "missing"), network.NewRegistry())
+	for _, typ := range []models.EndpointType{"", "lower", models.TypeICMP} {
+		if err := r.Register(&Plugin{Info: models.PluginInfo{Type: typ}}); err == nil {
+			t.Errorf("Expected error registering type %q", typ)
+		}
+	}
+	if err := r.Register(&Plugin{Info: models.PluginInfo{Type: "MODBUS"}}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := r.Register(&Plugin{Info: models.PluginInfo{Type: "MODBUS"}}); err == nil {
+		t.Errorf("Expected error registering a duplicate type")
+	}
+}
@@ -0,0 +1,9 @@
+// Package power tells whether the computer runs on battery, so monitoring
+// can save energy until it is plugged in again.
+package power
+
+// OnBattery reports whether the computer runs on battery power. Computers
+// without a battery are always on AC power.
+func OnBattery() (bool, error) {
+	return onBattery()
+}
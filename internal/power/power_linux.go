@@ -0,0 +1,45 @@
+//go:build linux
+
+package power
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sysfsRoot is where the kernel lists power supplies, replaceable in tests
+var sysfsRoot = "/sys/class/power_supply"
+
+// onBattery reads the power supplies in sysfs: the computer is on battery
+// when it has a battery and no external supply (mains, USB) is online
+func onBattery() (bool, error) {
+	entries, err := os.ReadDir(sysfsRoot)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	hasBattery := false
+	for _, e := range entries {
+		dir := filepath.Join(sysfsRoot, e.Name())
+		switch read(dir, "type") {
+		case "Battery":
+			// Peripherals such as mice report their batteries too
+			if read(dir, "scope") != "Device" {
+				hasBattery = true
+			}
+		case "Mains", "USB", "USB_C", "USB_PD":
+			if read(dir, "online") == "1" {
+				return false, nil
+			}
+		}
+	}
+	return hasBattery, nil
+}
+
+func read(dir, name string) string {
+	data, _ := os.ReadFile(filepath.Join(dir, name))
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,63 @@
+//go:build linux
+
+package power
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOnBatteryLinux(t *testing.T) {
+	supply := func(root, name string, files map[string]string) {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for f, v := range files {
+			if err := os.WriteFile(filepath.Join(dir, f), []byte(v+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	defer func(root string) { sysfsRoot = root }(sysfsRoot)
+
+	tests := []struct {
+		name     string
+		supplies map[string]map[string]string
+		want     bool
+	}{
+		{"desktop", map[string]map[string]string{"AC": {"type": "Mains", "online": "1"}}, false},
+		{"no supplies", nil, false},
+		{"plugged in", map[string]map[string]string{
+			"AC":   {"type": "Mains", "online": "1"},
+			"BAT0": {"type": "Battery", "scope": "System"},
+		}, false},
+		{"unplugged", map[string]map[string]string{
+			"AC":   {"type": "Mains", "online": "0"},
+			"BAT0": {"type": "Battery"},
+		}, true},
+		{"usb-c charger", map[string]map[string]string{
+			"ucsi-source-psy-1": {"type": "USB", "online": "1"},
+			"BAT0":              {"type": "Battery"},
+		}, false},
+		{"mouse battery only", map[string]map[string]string{
+			"hidpp_battery_0": {"type": "Battery", "scope": "Device"},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sysfsRoot = t.TempDir()
+			for name, files := range tt.supplies {
+				supply(sysfsRoot, name, files)
+			}
+			got, err := OnBattery()
+			if err != nil {
+				t.Fatalf("OnBattery failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("OnBattery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
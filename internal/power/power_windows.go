@@ -0,0 +1,34 @@
+//go:build windows
+
+package power
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procGetSystemPowerStatus = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetSystemPowerStatus")
+
+// systemPowerStatus is the Win32 SYSTEM_POWER_STATUS structure
+type systemPowerStatus struct {
+	acLineStatus        byte
+	batteryFlag         byte
+	batteryLifePercent  byte
+	systemStatusFlag    byte
+	batteryLifeTime     uint32
+	batteryFullLifeTime uint32
+}
+
+const (
+	acOffline        = 0
+	batteryNoBattery = 128
+)
+
+func onBattery() (bool, error) {
+	var s systemPowerStatus
+	if r, _, err := procGetSystemPowerStatus.Call(uintptr(unsafe.Pointer(&s))); r == 0 {
+		return false, err
+	}
+	return s.acLineStatus == acOffline && s.batteryFlag&batteryNoBattery == 0, nil
+}
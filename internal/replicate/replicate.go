@@ -0,0 +1,259 @@
+// Package replicate copies daily result files and the config to a second
+// location so monitoring history survives the loss of the machine.
+//
+// Each machine writes under its own host name, so several machines can share
+// a target. A remote file that changed since this machine last wrote it is
+// never overwritten; the local copy is written next to it under a conflict
+// name instead.
+package replicate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/config"
+	"github.com/marcoshack/netmonitor/internal/data"
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultIntervalMinutes is used when the sync settings don't set an interval
+const DefaultIntervalMinutes = 60
+
+// Replicator periodically syncs the data directory and config to a Target
+type Replicator struct {
+	Ctx        context.Context
+	Storage    *data.Storage
+	ConfigPath string
+	// Host namespaces the files of this machine on the target
+	Host string
+
+	mu       sync.Mutex
+	settings *models.SyncSettings
+	target   Target
+	last     models.SyncReport
+	stop     chan struct{}
+	wg       sync.WaitGroup
+
+	// runMu serializes sync runs
+	runMu sync.Mutex
+}
+
+func New(ctx context.Context, store *data.Storage, configPath string) *Replicator {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "netmonitor"
+	}
+	return &Replicator{Ctx: ctx, Storage: store, ConfigPath: configPath, Host: host}
+}
+
+// Configure applies new settings and restarts the periodic sync. Nil
+// settings disable syncing.
+func (r *Replicator) Configure(settings *models.SyncSettings) error {
+	var target Target
+	if settings != nil {
+		var err error
+		if target, err = NewTarget(*settings); err != nil {
+			return err
+		}
+	}
+
+	r.Stop()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.settings = settings
+	r.target = target
+	if settings == nil {
+		return nil
+	}
+
+	interval := time.Duration(settings.IntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = DefaultIntervalMinutes * time.Minute
+	}
+	r.stop = make(chan struct{})
+	r.wg.Add(1)
+	go r.loop(r.stop, interval)
+	return nil
+}
+
+// Stop ends the periodic sync, waiting for a run in progress
+func (r *Replicator) Stop() {
+	r.mu.Lock()
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+func (r *Replicator) loop(stop chan struct{}, interval time.Duration) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-r.Ctx.Done():
+			return
+		case <-ticker.C:
+			r.Run()
+		}
+	}
+}
+
+// Last returns the report of the most recent sync run
+func (r *Replicator) Last() models.SyncReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// Run syncs every daily file and the config once
+func (r *Replicator) Run() models.SyncReport {
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+
+	start := time.Now()
+	report := models.SyncReport{Start: start.UnixMilli()}
+	r.mu.Lock()
+	target := r.target
+	r.mu.Unlock()
+
+	if target == nil {
+		report.Error = "sync is not configured"
+	} else if err := r.run(target, &report); err != nil {
+		report.Error = err.Error()
+		log.Ctx(r.Ctx).Error().Err(err).Msg("Sync failed")
+	}
+	report.DurationMs = time.Since(start).Milliseconds()
+
+	log.Ctx(r.Ctx).Info().
+		Int("uploaded", report.Uploaded).
+		Int("unchanged", report.Unchanged).
+		Int("conflicts", report.Conflicts).
+		Msg("Sync finished")
+	r.mu.Lock()
+	r.last = report
+	r.mu.Unlock()
+	return report
+}
+
+func (r *Replicator) run(target Target, report *models.SyncReport) error {
+	state := r.loadState()
+	defer func() {
+		if err := r.saveState(state); err != nil {
+			log.Ctx(r.Ctx).Error().Err(err).Msg("Failed to save sync state")
+		}
+	}()
+
+	files, err := r.Storage.DailyFiles()
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		content, err := r.Storage.ReadDailyFile(name)
+		if err != nil {
+			return err
+		}
+		if err := r.sync(target, state, "data/"+name, content, report); err != nil {
+			return err
+		}
+	}
+
+	content, err := r.redactedConfig()
+	if err != nil {
+		return err
+	}
+	return r.sync(target, state, "config.json", content, report)
+}
+
+// redactedConfig returns the config file without the secrets config.Redact
+// removes, so passwords and API tokens never reach the target
+func (r *Replicator) redactedConfig() ([]byte, error) {
+	content, err := os.ReadFile(r.ConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	var cfg models.Configuration
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	return json.MarshalIndent(config.Redact(cfg), "", "  ")
+}
+
+// sync writes one file unless it is unchanged since the last run
+func (r *Replicator) sync(target Target, state map[string]string, name string, content []byte, report *models.SyncReport) error {
+	hash := digest(content)
+	if state[name] == hash {
+		report.Unchanged++
+		return nil
+	}
+
+	remoteName := r.Host + "/" + name
+	remote, err := target.Get(r.Ctx, remoteName)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	case digest(remote) == hash:
+		// Already there, e.g. state was lost
+		state[name] = hash
+		report.Unchanged++
+		return nil
+	case digest(remote) != state[name]:
+		// Changed by someone else since we last wrote it, keep both
+		conflict := fmt.Sprintf("%s.conflict-%s-%s", remoteName, r.Host, time.Now().UTC().Format("20060102T150405Z"))
+		if err := target.Put(r.Ctx, conflict, content); err != nil {
+			return err
+		}
+		log.Ctx(r.Ctx).Warn().Str("file", remoteName).Str("conflict", conflict).Msg("Sync conflict, remote file changed")
+		state[name] = hash // Don't write the same content again on every run
+		report.Conflicts++
+		return nil
+	}
+
+	if err := target.Put(r.Ctx, remoteName, content); err != nil {
+		return err
+	}
+	state[name] = hash
+	report.Uploaded++
+	return nil
+}
+
+func digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// The sync state maps file names to the hash last written to the target
+func (r *Replicator) statePath() string {
+	return filepath.Join(r.Storage.DataDir, "sync-state.json")
+}
+
+func (r *Replicator) loadState() map[string]string {
+	state := make(map[string]string)
+	if b, err := os.ReadFile(r.statePath()); err == nil {
+		_ = json.Unmarshal(b, &state)
+	}
+	return state
+}
+
+func (r *Replicator) saveState(state map[string]string) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.statePath(), b, 0644)
+}
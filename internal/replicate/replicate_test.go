@@ -0,0 +1,144 @@
+package replicate
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/data"
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+func newReplicator(t *testing.T) *Replicator {
+	t.Helper()
+	dir := t.TempDir()
+	store := data.NewStorage(filepath.Join(dir, "data"))
+	if err := store.SaveResult(models.TestResult{Ts: time.Date(2025, 1, 2, 10, 0, 0, 0, time.Local).UnixMilli(), Id: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte(`{"regions":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := New(context.Background(), store, configPath)
+	r.Host = "laptop"
+	return r
+}
+
+func TestSyncToDirectory(t *testing.T) {
+	r := newReplicator(t)
+	remote := t.TempDir()
+	if err := r.Configure(&models.SyncSettings{Target: remote}); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	report := r.Run()
+	if report.Error != "" || report.Uploaded != 2 {
+		t.Fatalf("Unexpected first run: %+v", report)
+	}
+	if _, err := os.Stat(filepath.Join(remote, "laptop", "data", "2025-01-02.json")); err != nil {
+		t.Errorf("Daily file not replicated: %v", err)
+	}
+
+	if report := r.Run(); report.Uploaded != 0 || report.Unchanged != 2 {
+		t.Errorf("Expected nothing to upload, got %+v", report)
+	}
+
+	// Someone else edits the remote config, the local change goes to a conflict file
+	os.WriteFile(filepath.Join(remote, "laptop", "config.json"), []byte(`{"edited":true}`), 0644)
+	os.WriteFile(r.ConfigPath, []byte(`{"regions":{"Default":{}}}`), 0644)
+	if report := r.Run(); report.Conflicts != 1 || report.Uploaded != 0 {
+		t.Errorf("Expected a conflict, got %+v", report)
+	}
+	remoteConfig, _ := os.ReadFile(filepath.Join(remote, "laptop", "config.json"))
+	if string(remoteConfig) != `{"edited":true}` {
+		t.Errorf("Remote config was overwritten: %s", remoteConfig)
+	}
+	matches, _ := filepath.Glob(filepath.Join(remote, "laptop", "config.json.conflict-laptop-*"))
+	if len(matches) != 1 {
+		t.Errorf("Expected one conflict file, got %v", matches)
+	}
+}
+
+func TestSyncToWebDAV(t *testing.T) {
+	var mu sync.Mutex
+	files := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if user, pass, _ := req.BasicAuth(); user != "u" || pass != "p" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.Method {
+		case "MKCOL":
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodPut:
+			files[req.URL.Path], _ = io.ReadAll(req.Body)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			b, ok := files[req.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(b)
+		}
+	}))
+	defer srv.Close()
+
+	r := newReplicator(t)
+	if err := r.Configure(&models.SyncSettings{Target: srv.URL + "/dav", Username: "u", Password: "p"}); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	if report := r.Run(); report.Error != "" || report.Uploaded != 2 {
+		t.Fatalf("Unexpected run: %+v", report)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !strings.Contains(string(files["/dav/laptop/config.json"]), "regions") {
+		t.Errorf("Config not uploaded, files: %v", files)
+	}
+}
+
+func TestSyncRedactsConfig(t *testing.T) {
+	r := newReplicator(t)
+	os.WriteFile(r.ConfigPath, []byte(`{"regions":{},"settings":{
+		"sync":{"target":"https://dav.example.com","password":"dav-secret"},
+		"api":{"enabled":true,"tokens":[{"name":"ops","hash":"token-hash"}]},
+		"alerting":{"notifiers":[{"name":"mail","type":"email","smtp_password":"smtp-secret"}]}}}`), 0644)
+	remote := t.TempDir()
+	if err := r.Configure(&models.SyncSettings{Target: remote}); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	if report := r.Run(); report.Error != "" {
+		t.Fatalf("Unexpected run: %+v", report)
+	}
+	content, _ := os.ReadFile(filepath.Join(remote, "laptop", "config.json"))
+	for _, secret := range []string{"dav-secret", "token-hash", "smtp-secret"} {
+		if strings.Contains(string(content), secret) {
+			t.Errorf("Replicated config contains %s:\n%s", secret, content)
+		}
+	}
+	if !strings.Contains(string(content), "dav.example.com") {
+		t.Errorf("Expected the rest of the config to be replicated:\n%s", content)
+	}
+}
+
+func TestNewTargetRejectsRelativePaths(t *testing.T) {
+	if _, err := NewTarget(models.SyncSettings{Target: "backup"}); err == nil {
+		t.Errorf("Expected error for relative path")
+	}
+}
@@ -0,0 +1,132 @@
+package replicate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// Target is a location files are replicated to. Names use forward slashes.
+type Target interface {
+	// Get returns the content of a file, or an error wrapping fs.ErrNotExist
+	Get(ctx context.Context, name string) ([]byte, error)
+	Put(ctx context.Context, name string, data []byte) error
+}
+
+// NewTarget returns a WebDAV target for http(s) URLs and a directory target
+// otherwise
+func NewTarget(s models.SyncSettings) (Target, error) {
+	if strings.HasPrefix(s.Target, "http://") || strings.HasPrefix(s.Target, "https://") {
+		base, err := url.Parse(strings.TrimSuffix(s.Target, "/") + "/")
+		if err != nil {
+			return nil, fmt.Errorf("invalid WebDAV URL: %w", err)
+		}
+		return &WebDAVTarget{Base: base, Username: s.Username, Password: s.Password, Client: http.DefaultClient}, nil
+	}
+	if !filepath.IsAbs(s.Target) {
+		return nil, fmt.Errorf("sync target must be an absolute path or a WebDAV URL")
+	}
+	return DirTarget(s.Target), nil
+}
+
+// DirTarget replicates into a local directory, typically one kept in sync by
+// a cloud storage client
+type DirTarget string
+
+func (d DirTarget) Get(ctx context.Context, name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(string(d), filepath.FromSlash(name)))
+}
+
+func (d DirTarget) Put(ctx context.Context, name string, data []byte) error {
+	dst := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	// Write and rename so sync clients never upload a partial file
+	tmp := dst + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// WebDAVTarget replicates to a WebDAV folder with plain GET, PUT and MKCOL
+type WebDAVTarget struct {
+	Base     *url.URL
+	Username string
+	Password string
+	Client   *http.Client
+}
+
+func (w *WebDAVTarget) Get(ctx context.Context, name string) ([]byte, error) {
+	resp, err := w.do(ctx, http.MethodGet, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+	case resp.StatusCode >= 300:
+		return nil, fmt.Errorf("GET %s: %s", name, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (w *WebDAVTarget) Put(ctx context.Context, name string, data []byte) error {
+	// Create parent collections, servers answer 405 for existing ones
+	dir := ""
+	for _, part := range strings.Split(path.Dir(name), "/") {
+		if part == "." || part == "" {
+			continue
+		}
+		dir += part + "/"
+		resp, err := w.do(ctx, "MKCOL", dir, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 && resp.StatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("MKCOL %s: %s", dir, resp.Status)
+		}
+	}
+
+	resp, err := w.do(ctx, http.MethodPut, name, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("PUT %s: %s", name, resp.Status)
+	}
+	return nil
+}
+
+func (w *WebDAVTarget) do(ctx context.Context, method, name string, body []byte) (*http.Response, error) {
+	u := w.Base.JoinPath(name)
+	if strings.HasSuffix(name, "/") {
+		u.Path += "/"
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if w.Username != "" {
+		req.SetBasicAuth(w.Username, w.Password)
+	}
+	return w.Client.Do(req)
+}
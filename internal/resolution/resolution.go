@@ -0,0 +1,160 @@
+// Package resolution records which addresses endpoint hosts resolve to and
+// flags when that changes, e.g. a new CDN POP or a hijacked DNS response.
+package resolution
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+	"github.com/rs/zerolog/log"
+)
+
+// Result tags added by the tracker
+const (
+	TagIP        = "ip"         // First resolved address, in sorted order
+	TagPTR       = "ptr"        // Reverse DNS name of TagIP
+	TagIPChanged = "ip_changed" // Set to "true" when the resolved addresses changed since the previous test
+)
+
+const (
+	resolveTimeout = 2 * time.Second
+
+	// ptrTTL is how long reverse lookups are cached, since PTR records
+	// rarely change and are looked up on every test otherwise
+	ptrTTL = time.Hour
+)
+
+type ptrEntry struct {
+	name    string
+	expires time.Time
+}
+
+// Tracker resolves endpoint hosts around each test and reports changes in
+// the resolved address set
+type Tracker struct {
+	Ctx context.Context
+
+	// OnChange, if set, receives every detected change
+	OnChange func(models.ResolutionChange)
+
+	// Resolver and LookupAddr can be replaced in tests
+	Resolver   func(ctx context.Context, host string) ([]string, error)
+	LookupAddr func(ctx context.Context, ip string) ([]string, error)
+
+	mu   sync.Mutex
+	last map[string][]string // Endpoint ID -> sorted addresses
+	ptrs map[string]ptrEntry
+}
+
+func NewTracker(ctx context.Context) *Tracker {
+	return &Tracker{
+		Ctx:        ctx,
+		Resolver:   net.DefaultResolver.LookupHost,
+		LookupAddr: net.DefaultResolver.LookupAddr,
+		last:       make(map[string][]string),
+		ptrs:       make(map[string]ptrEntry),
+	}
+}
+
+// Middleware tags every non-cancelled result with the resolved address and
+// its PTR name
+func (t *Tracker) Middleware() network.Middleware {
+	return func(next network.RunFunc) network.RunFunc {
+		return func(ctx context.Context, ep models.Endpoint) (models.TestResult, error) {
+			result, err := next(ctx, ep)
+			if result.St != models.TestStatusCancelled {
+				t.track(ctx, ep, &result)
+			}
+			return result, err
+		}
+	}
+}
+
+func (t *Tracker) track(ctx context.Context, ep models.Endpoint, result *models.TestResult) {
+	host := network.Host(ep.Address)
+	addrs, err := t.resolve(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		log.Ctx(t.Ctx).Debug().Err(err).Str("host", host).Msg("Resolution tracking skipped")
+		return
+	}
+
+	if result.Tags == nil {
+		result.Tags = make(map[string]string)
+	}
+	result.Tags[TagIP] = addrs[0]
+	if ptr := t.ptr(ctx, addrs[0]); ptr != "" {
+		result.Tags[TagPTR] = ptr
+	}
+
+	t.mu.Lock()
+	previous, seen := t.last[result.Id]
+	t.last[result.Id] = addrs
+	t.mu.Unlock()
+
+	if !seen || slices.Equal(previous, addrs) {
+		return
+	}
+	result.Tags[TagIPChanged] = "true"
+	change := models.ResolutionChange{
+		EndpointID: result.Id,
+		Host:       host,
+		Ts:         result.Ts,
+		OldIPs:     previous,
+		NewIPs:     addrs,
+		PTR:        result.Tags[TagPTR],
+	}
+	log.Ctx(t.Ctx).Info().
+		Str("id", result.Id).
+		Str("host", host).
+		Str("from", strings.Join(previous, ",")).
+		Str("to", strings.Join(addrs, ",")).
+		Msg("Endpoint resolution changed")
+	if t.OnChange != nil {
+		t.OnChange(change)
+	}
+}
+
+// resolve returns the sorted addresses of host. IP literals resolve to themselves.
+func (t *Tracker) resolve(ctx context.Context, host string) ([]string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{ip.String()}, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	addrs, err := t.Resolver(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs = slices.Clone(addrs)
+	slices.Sort(addrs)
+	return slices.Compact(addrs), nil
+}
+
+func (t *Tracker) ptr(ctx context.Context, ip string) string {
+	t.mu.Lock()
+	entry, ok := t.ptrs[ip]
+	t.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.name
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	var name string
+	if names, err := t.LookupAddr(ctx, ip); err == nil && len(names) > 0 {
+		name = strings.TrimSuffix(names[0], ".")
+	}
+
+	// Failed lookups are cached too so hosts without PTR records aren't
+	// queried on every test
+	t.mu.Lock()
+	t.ptrs[ip] = ptrEntry{name: name, expires: time.Now().Add(ptrTTL)}
+	t.mu.Unlock()
+	return name
+}
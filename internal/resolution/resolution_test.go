@@ -0,0 +1,54 @@
+package resolution
+
+import (
+	"context"
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+func TestTrackerDetectsChanges(t *testing.T) {
+	tr := NewTracker(context.Background())
+	answers := [][]string{
+		{"203.0.113.2", "203.0.113.1"},
+		{"203.0.113.1", "203.0.113.2"}, // Same set, different order
+		{"198.51.100.7"},
+	}
+	call := 0
+	tr.Resolver = func(context.Context, string) ([]string, error) {
+		a := answers[call]
+		call++
+		return a, nil
+	}
+	ptrLookups := 0
+	tr.LookupAddr = func(_ context.Context, ip string) ([]string, error) {
+		ptrLookups++
+		return []string{"edge-" + ip + ".example.net."}, nil
+	}
+	var changes []models.ResolutionChange
+	tr.OnChange = func(c models.ResolutionChange) { changes = append(changes, c) }
+
+	runner := network.NewRunner(context.Background())
+	_ = runner.Protocols.Register(network.NewMockTest().Protocol())
+	runner.Use(tr.Middleware())
+	ep := models.Endpoint{Type: network.MockType, Address: "https://cdn.example.com/health", Timeout: 1000}
+
+	for i := range answers {
+		result, _ := runner.Run(context.Background(), ep)
+		changed := result.Tags[TagIPChanged] == "true"
+		if changed != (i == 2) {
+			t.Errorf("Run %d: unexpected change flag in %+v", i, result.Tags)
+		}
+		if i == 0 && (result.Tags[TagIP] != "203.0.113.1" || result.Tags[TagPTR] != "edge-203.0.113.1.example.net") {
+			t.Errorf("Unexpected tags: %+v", result.Tags)
+		}
+	}
+
+	if len(changes) != 1 || changes[0].Host != "cdn.example.com" || changes[0].NewIPs[0] != "198.51.100.7" || len(changes[0].OldIPs) != 2 {
+		t.Errorf("Unexpected changes: %+v", changes)
+	}
+	if ptrLookups != 2 {
+		t.Errorf("Expected PTR lookups to be cached per IP, got %d lookups", ptrLookups)
+	}
+}
@@ -0,0 +1,104 @@
+// Package routes compares the traced paths of traceroute endpoints between
+// runs and flags route changes, so latency shifts can be correlated with a
+// change in the path (e.g. a BGP reroute).
+package routes
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+	"github.com/rs/zerolog/log"
+)
+
+// TagRouteChanged is set to "true" on results whose path differs from the
+// previous test of the endpoint
+const TagRouteChanged = "route_changed"
+
+type lastRoute struct {
+	hops string
+	ms   int64
+}
+
+// Tracker remembers the last path of each traceroute endpoint
+type Tracker struct {
+	Ctx context.Context
+
+	// OnChange, if set, receives every detected change
+	OnChange func(models.RouteChange)
+
+	mu   sync.Mutex
+	last map[string]lastRoute // Endpoint ID -> last path
+}
+
+func NewTracker(ctx context.Context) *Tracker {
+	return &Tracker{
+		Ctx:  ctx,
+		last: make(map[string]lastRoute),
+	}
+}
+
+// Middleware compares the hop signature of every traceroute result with the
+// previous one of the same endpoint
+func (t *Tracker) Middleware() network.Middleware {
+	return func(next network.RunFunc) network.RunFunc {
+		return func(ctx context.Context, ep models.Endpoint) (models.TestResult, error) {
+			result, err := next(ctx, ep)
+			if hops, ok := result.Tags[network.TagHops]; ok {
+				t.track(&result, hops)
+			}
+			return result, err
+		}
+	}
+}
+
+func (t *Tracker) track(result *models.TestResult, hops string) {
+	t.mu.Lock()
+	previous, seen := t.last[result.Id]
+	changed := seen && network.PathsDiffer(previous.hops, hops)
+	if seen && !changed {
+		// Keep the addresses of hops that were silent this time, otherwise
+		// a later change at that hop would go unnoticed
+		t.last[result.Id] = lastRoute{hops: fillSilent(hops, previous.hops), ms: result.Ms}
+	} else {
+		t.last[result.Id] = lastRoute{hops: hops, ms: result.Ms}
+	}
+	t.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	result.Tags[TagRouteChanged] = "true"
+	change := models.RouteChange{
+		EndpointID: result.Id,
+		Ts:         result.Ts,
+		OldHops:    strings.Split(previous.hops, ","),
+		NewHops:    strings.Split(hops, ","),
+		OldMs:      previous.ms,
+		NewMs:      result.Ms,
+	}
+	log.Ctx(t.Ctx).Info().
+		Str("id", result.Id).
+		Str("from", previous.hops).
+		Str("to", hops).
+		Int64("old_ms", previous.ms).
+		Int64("new_ms", result.Ms).
+		Msg("Endpoint route changed")
+	if t.OnChange != nil {
+		t.OnChange(change)
+	}
+}
+
+// fillSilent replaces the silent hops of a path with the known addresses of
+// an equivalent path
+func fillSilent(hops, known string) string {
+	h, k := strings.Split(hops, ","), strings.Split(known, ",")
+	for i := range h {
+		if h[i] == "*" {
+			h[i] = k[i]
+		}
+	}
+	return strings.Join(h, ",")
+}
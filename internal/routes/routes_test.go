@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+func TestTrackerDetectsRouteChange(t *testing.T) {
+	tracker := NewTracker(context.Background())
+	var changes []models.RouteChange
+	tracker.OnChange = func(c models.RouteChange) { changes = append(changes, c) }
+
+	paths := []string{
+		"10.0.0.1,192.0.2.1,8.8.8.8",
+		"10.0.0.1,*,8.8.8.8", // Silent hop is not a change
+		"10.0.0.1,198.51.100.1,8.8.8.8",
+	}
+	run := tracker.Middleware()(func(ctx context.Context, ep models.Endpoint) (models.TestResult, error) {
+		return models.TestResult{Id: "a", Ms: int64(len(ep.Address)), Tags: map[string]string{network.TagHops: ep.Address}}, nil
+	})
+
+	var results []models.TestResult
+	for _, p := range paths {
+		result, _ := run(context.Background(), models.Endpoint{Address: p})
+		results = append(results, result)
+	}
+
+	if results[0].Tags[TagRouteChanged] != "" || results[1].Tags[TagRouteChanged] != "" {
+		t.Errorf("Unexpected route change on stable path: %+v", results[:2])
+	}
+	if results[2].Tags[TagRouteChanged] != "true" {
+		t.Errorf("Expected route change tag, got %v", results[2].Tags)
+	}
+	if len(changes) != 1 || changes[0].OldHops[1] != "192.0.2.1" || changes[0].NewHops[1] != "198.51.100.1" {
+		t.Errorf("Unexpected changes: %+v", changes)
+	}
+
+	// Results of other test types are left alone
+	plain := tracker.Middleware()(func(ctx context.Context, ep models.Endpoint) (models.TestResult, error) {
+		return models.TestResult{Id: "b"}, nil
+	})
+	if result, _ := plain(context.Background(), models.Endpoint{}); result.Tags != nil {
+		t.Errorf("Expected no tags, got %v", result.Tags)
+	}
+}
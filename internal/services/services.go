@@ -0,0 +1,178 @@
+// Package services rolls up the state of endpoint groups into one status per
+// logical service, so alerting and the dashboard can follow what users rely
+// on rather than individual probes.
+package services
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+// Validate checks that services have unique names, a known rule and members
+// that exist in cfg
+func Validate(cfg models.Configuration) error {
+	names := make(map[string]bool)
+	for _, svc := range cfg.Services {
+		if svc.Name == "" {
+			return fmt.Errorf("service name is required")
+		}
+		if names[svc.Name] {
+			return fmt.Errorf("duplicate service: %s", svc.Name)
+		}
+		names[svc.Name] = true
+
+		if len(svc.Members) == 0 {
+			return fmt.Errorf("service %s has no members", svc.Name)
+		}
+		for _, m := range svc.Members {
+			if _, ok := lookup(cfg, m); !ok {
+				return fmt.Errorf("service %s: no endpoint %s in region %s", svc.Name, m.Endpoint, m.Region)
+			}
+		}
+		switch svc.Rule {
+		case models.ServiceRuleAll, models.ServiceRuleAny:
+		case models.ServiceRuleQuorum:
+			if svc.Quorum < 0 || svc.Quorum > len(svc.Members) {
+				return fmt.Errorf("service %s: quorum must be between 1 and %d", svc.Name, len(svc.Members))
+			}
+		default:
+			return fmt.Errorf("service %s: unknown rule %q", svc.Name, svc.Rule)
+		}
+	}
+	return nil
+}
+
+// lookup finds the ID of a member's endpoint
+func lookup(cfg models.Configuration, m models.ServiceMember) (string, bool) {
+	for _, ep := range cfg.Regions[m.Region].Endpoints {
+		if ep.Name == m.Endpoint {
+			return network.EndpointID(ep.Address, ep.Type), true
+		}
+	}
+	return "", false
+}
+
+// Rollup computes the status of every service in cfg from endpoint states
+// keyed by endpoint ID. Members without a state yet or missing from the
+// config count towards the total only.
+func Rollup(cfg models.Configuration, states map[string]models.EndpointState) []models.ServiceStatus {
+	statuses := make([]models.ServiceStatus, 0, len(cfg.Services))
+	for _, svc := range cfg.Services {
+		status := models.ServiceStatus{Name: svc.Name, Total: len(svc.Members)}
+		for _, m := range svc.Members {
+			id, ok := lookup(cfg, m)
+			if !ok {
+				continue
+			}
+			if state, ok := states[id]; ok {
+				if state.Up {
+					status.Up++
+				} else {
+					status.Down++
+				}
+			}
+		}
+		status.Status = rollupStatus(svc, status)
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func rollupStatus(svc models.Service, s models.ServiceStatus) string {
+	if s.Up+s.Down == 0 {
+		return models.RegionUnknown
+	}
+	var holds bool
+	switch svc.Rule {
+	case models.ServiceRuleAny:
+		holds = s.Up > 0
+	case models.ServiceRuleQuorum:
+		quorum := svc.Quorum
+		if quorum == 0 {
+			quorum = s.Total/2 + 1
+		}
+		holds = s.Up >= quorum
+	default:
+		holds = s.Down == 0
+	}
+	switch {
+	case !holds:
+		return models.RegionDown
+	case s.Down > 0:
+		return models.RegionDegraded
+	default:
+		return models.RegionOperational
+	}
+}
+
+// Tracker remembers the last status of each service to report changes
+type Tracker struct {
+	mu   sync.Mutex
+	last map[string]string
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{last: make(map[string]string)}
+}
+
+// Update records statuses and returns those that changed. A service first
+// seen operational or unknown isn't a change, one first seen degraded or
+// down is, like an endpoint found down by its first test.
+func (t *Tracker) Update(statuses []models.ServiceStatus) []models.ServiceStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var changed []models.ServiceStatus
+	for _, s := range statuses {
+		if s.Status == models.RegionUnknown {
+			continue
+		}
+		prev, seen := t.last[s.Name]
+		t.last[s.Name] = s.Status
+		if s.Status == prev {
+			continue
+		}
+		if !seen && s.Status == models.RegionOperational {
+			continue
+		}
+		changed = append(changed, s)
+	}
+	return changed
+}
+
+// RenameMember updates the services of cfg after an endpoint of region was
+// renamed
+func RenameMember(cfg *models.Configuration, region, oldName, newName string) {
+	for i := range cfg.Services {
+		for j, m := range cfg.Services[i].Members {
+			if m.Region == region && m.Endpoint == oldName {
+				cfg.Services[i].Members[j].Endpoint = newName
+			}
+		}
+	}
+}
+
+// RemoveMember updates the services of cfg after an endpoint of region was
+// deleted. Services left without members are deleted too, and quorums are
+// lowered to the members left.
+func RemoveMember(cfg *models.Configuration, region, name string) {
+	kept := cfg.Services[:0]
+	for _, svc := range cfg.Services {
+		members := svc.Members[:0]
+		for _, m := range svc.Members {
+			if m.Region != region || m.Endpoint != name {
+				members = append(members, m)
+			}
+		}
+		if len(members) == 0 {
+			continue
+		}
+		svc.Members = members
+		svc.Quorum = min(svc.Quorum, len(members))
+		kept = append(kept, svc)
+	}
+	cfg.Services = kept
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/network"
+)
+
+func testConfig(rule string, quorum int) models.Configuration {
+	return models.Configuration{
+		Regions: map[string]models.Region{
+			"Prod": {Endpoints: []models.Endpoint{
+				{Name: "LB", Type: models.TypeICMP, Address: "192.0.2.1"},
+				{Name: "DNS", Type: models.TypeDNS, Address: "udp://192.0.2.53:53#api.example.com"},
+				{Name: "Health", Type: models.TypeHTTP, Address: "https://api.example.com/health"},
+			}},
+		},
+		Services: []models.Service{{
+			Name: "API",
+			Members: []models.ServiceMember{
+				{Region: "Prod", Endpoint: "LB"},
+				{Region: "Prod", Endpoint: "DNS"},
+				{Region: "Prod", Endpoint: "Health"},
+			},
+			Rule:   rule,
+			Quorum: quorum,
+		}},
+	}
+}
+
+// states marks the endpoints of the Prod region up or down, in order
+func states(cfg models.Configuration, up ...bool) map[string]models.EndpointState {
+	s := make(map[string]models.EndpointState)
+	for i, u := range up {
+		ep := cfg.Regions["Prod"].Endpoints[i]
+		s[network.EndpointID(ep.Address, ep.Type)] = models.EndpointState{Up: u}
+	}
+	return s
+}
+
+func TestRollup(t *testing.T) {
+	tests := []struct {
+		rule   string
+		quorum int
+		up     []bool
+		want   string
+	}{
+		{models.ServiceRuleAll, 0, []bool{true, true, true}, models.RegionOperational},
+		{models.ServiceRuleAll, 0, []bool{true, false, true}, models.RegionDown},
+		{models.ServiceRuleAny, 0, []bool{false, false, true}, models.RegionDegraded},
+		{models.ServiceRuleAny, 0, []bool{false, false, false}, models.RegionDown},
+		{models.ServiceRuleQuorum, 0, []bool{true, false, true}, models.RegionDegraded},
+		{models.ServiceRuleQuorum, 0, []bool{true, false, false}, models.RegionDown},
+		{models.ServiceRuleQuorum, 1, []bool{true, false, false}, models.RegionDegraded},
+		{models.ServiceRuleAll, 0, nil, models.RegionUnknown},
+	}
+	for _, tt := range tests {
+		cfg := testConfig(tt.rule, tt.quorum)
+		got := Rollup(cfg, states(cfg, tt.up...))[0]
+		if got.Status != tt.want || got.Total != 3 {
+			t.Errorf("%s/%d %v: got %+v, want %s", tt.rule, tt.quorum, tt.up, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(testConfig(models.ServiceRuleQuorum, 2)); err != nil {
+		t.Fatal(err)
+	}
+	bad := []func(*models.Configuration){
+		func(c *models.Configuration) { c.Services[0].Name = "" },
+		func(c *models.Configuration) { c.Services = append(c.Services, c.Services[0]) },
+		func(c *models.Configuration) { c.Services[0].Members = nil },
+		func(c *models.Configuration) { c.Services[0].Members[0].Endpoint = "Missing" },
+		func(c *models.Configuration) { c.Services[0].Rule = "most" },
+		func(c *models.Configuration) { c.Services[0].Quorum = 4 },
+	}
+	for i, mutate := range bad {
+		cfg := testConfig(models.ServiceRuleQuorum, 2)
+		mutate(&cfg)
+		if Validate(cfg) == nil {
+			t.Errorf("case %d accepted", i)
+		}
+	}
+}
+
+func TestTrackerUpdate(t *testing.T) {
+	tr := NewTracker()
+	status := func(s string) []models.ServiceStatus { return []models.ServiceStatus{{Name: "API", Status: s}} }
+
+	steps := []struct {
+		status  string
+		changed bool
+	}{
+		{models.RegionUnknown, false},
+		{models.RegionOperational, false}, // First known status, all good
+		{models.RegionOperational, false},
+		{models.RegionDegraded, true},
+		{models.RegionDown, true},
+		{models.RegionOperational, true},
+	}
+	for i, s := range steps {
+		if changed := len(tr.Update(status(s.status))) == 1; changed != s.changed {
+			t.Errorf("step %d: changed=%v, want %v", i, changed, s.changed)
+		}
+	}
+}
+
+func TestMemberChanges(t *testing.T) {
+	cfg := testConfig(models.ServiceRuleQuorum, 3)
+	RenameMember(&cfg, "Prod", "LB", "Load balancer")
+	if cfg.Services[0].Members[0].Endpoint != "Load balancer" {
+		t.Errorf("member not renamed: %+v", cfg.Services[0].Members)
+	}
+
+	RemoveMember(&cfg, "Prod", "DNS")
+	if svc := cfg.Services[0]; len(svc.Members) != 2 || svc.Quorum != 2 {
+		t.Errorf("unexpected service after removal: %+v", svc)
+	}
+	RemoveMember(&cfg, "Prod", "Load balancer")
+	RemoveMember(&cfg, "Prod", "Health")
+	if len(cfg.Services) != 0 {
+		t.Errorf("empty service kept: %+v", cfg.Services)
+	}
+}
@@ -0,0 +1,163 @@
+// Package state versions the layout of the app data directory. A manifest
+// records the schema the directory was written with, and migrations bring
+// older layouts up to date at startup before anything reads them.
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SchemaVersion is the layout version written by this build. Bump it and
+// append a migration whenever the format of results, history or export
+// files changes.
+const SchemaVersion = 2
+
+const manifestFile = "manifest.json"
+
+// Manifest describes the data directory
+type Manifest struct {
+	SchemaVersion int    `json:"schema_version"`
+	CreatedBy     string `json:"created_by"` // App version that created the directory
+	UpdatedBy     string `json:"updated_by"` // Last app version that opened it
+	CreatedAt     int64  `json:"created_at"`
+	MigratedAt    int64  `json:"migrated_at,omitempty"`
+}
+
+// Migration upgrades the directory from schema From to From+1
+type Migration struct {
+	From        int
+	Description string
+	Migrate     func(dir string) error
+}
+
+// migrations must stay sorted by From, one per schema version
+var migrations = []Migration{
+	{
+		From:        0,
+		Description: "adopt directories created before the manifest existed",
+		Migrate:     func(dir string) error { return nil },
+	},
+	{
+		// Results gained latency in microseconds. Older results are read as
+		// they are, but older builds would drop the field when rewriting a
+		// day file, so they must not open a directory written by this one.
+		From:        1,
+		Description: "store latency in microseconds",
+		Migrate:     func(dir string) error { return nil },
+	},
+}
+
+// DefaultDir returns the directory holding config, data and logs, shared by
+// the desktop app and the daemon
+func DefaultDir() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		println("Error getting user config directory:", err.Error())
+		configDir = "." // Fallback to current directory
+	}
+	return filepath.Join(configDir, "NetMonitor")
+}
+
+// ErrNewerSchema is returned when the directory was written by a newer build
+type ErrNewerSchema struct {
+	Found int
+}
+
+func (e *ErrNewerSchema) Error() string {
+	return fmt.Sprintf("data directory schema %d is newer than supported schema %d, update the app", e.Found, SchemaVersion)
+}
+
+// Prepare checks the manifest in dir, running any pending migrations, and
+// records appVersion as the last version to open it. Directories without a
+// manifest are treated as schema 0 if they already hold data.
+func Prepare(ctx context.Context, dir, appVersion string) (Manifest, error) {
+	return prepare(ctx, dir, appVersion, migrations)
+}
+
+func prepare(ctx context.Context, dir, appVersion string, migrations []Migration) (Manifest, error) {
+	manifest, err := Load(dir)
+	if os.IsNotExist(err) {
+		manifest = Manifest{CreatedBy: appVersion, CreatedAt: time.Now().UnixMilli()}
+		if empty(dir) {
+			manifest.SchemaVersion = SchemaVersion
+		}
+	} else if err != nil {
+		return Manifest{}, fmt.Errorf("reading manifest: %w", err)
+	}
+
+	if manifest.SchemaVersion > SchemaVersion {
+		return manifest, &ErrNewerSchema{Found: manifest.SchemaVersion}
+	}
+
+	for _, m := range migrations {
+		if m.From != manifest.SchemaVersion {
+			continue
+		}
+		log.Ctx(ctx).Info().
+			Int("from", m.From).
+			Int("to", m.From+1).
+			Str("migration", m.Description).
+			Msg("Migrating data directory")
+		if err := m.Migrate(dir); err != nil {
+			return manifest, fmt.Errorf("migrating schema %d: %w", m.From, err)
+		}
+		// Saved after every step so a failed migration resumes where it stopped
+		manifest.SchemaVersion = m.From + 1
+		manifest.MigratedAt = time.Now().UnixMilli()
+		if err := save(dir, manifest); err != nil {
+			return manifest, err
+		}
+	}
+	if manifest.SchemaVersion != SchemaVersion {
+		return manifest, fmt.Errorf("no migration from schema %d", manifest.SchemaVersion)
+	}
+
+	manifest.UpdatedBy = appVersion
+	return manifest, save(dir, manifest)
+}
+
+// Load reads the manifest of dir
+func Load(dir string) (Manifest, error) {
+	var manifest Manifest
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return manifest, err
+	}
+	err = json.Unmarshal(data, &manifest)
+	return manifest, err
+}
+
+func save(dir string, manifest Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// Write and rename so an interrupted save never leaves a truncated manifest
+	tmp := filepath.Join(dir, manifestFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, manifestFile))
+}
+
+// empty reports whether dir holds no data yet. Logs are written before the
+// manifest is checked and don't count.
+func empty(dir string) bool {
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if e.Name() != "logs" {
+			return false
+		}
+	}
+	return true
+}
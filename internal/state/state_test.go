@@ -0,0 +1,71 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrepareFreshDirectory(t *testing.T) {
+	dir := t.TempDir()
+	manifest, err := Prepare(context.Background(), dir, "1.0.0")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if manifest.SchemaVersion != SchemaVersion || manifest.CreatedBy != "1.0.0" || manifest.MigratedAt != 0 {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+
+	manifest, err = Prepare(context.Background(), dir, "1.1.0")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if manifest.CreatedBy != "1.0.0" || manifest.UpdatedBy != "1.1.0" {
+		t.Errorf("Unexpected manifest after reopening: %+v", manifest)
+	}
+}
+
+func TestPrepareMigratesLegacyDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var ran []int
+	steps := []Migration{
+		{From: 0, Migrate: func(string) error { ran = append(ran, 0); return nil }},
+		{From: 1, Migrate: func(string) error { ran = append(ran, 1); return nil }},
+	}
+	manifest, err := prepare(context.Background(), dir, "1.0.0", steps)
+	if err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	if len(ran) != 2 || manifest.SchemaVersion != SchemaVersion || manifest.MigratedAt == 0 {
+		t.Errorf("Unexpected migration: ran=%v manifest=%+v", ran, manifest)
+	}
+
+	// A failed migration leaves the schema where it was
+	legacy := t.TempDir()
+	os.WriteFile(filepath.Join(legacy, "config.json"), []byte("{}"), 0644)
+	failing := []Migration{{From: 0, Migrate: func(string) error { return errors.New("boom") }}}
+	if _, err := prepare(context.Background(), legacy, "1.0.0", failing); err == nil {
+		t.Errorf("Expected migration error")
+	}
+	if _, err := Load(legacy); !os.IsNotExist(err) {
+		t.Errorf("Expected no manifest after a failed first migration, got %v", err)
+	}
+}
+
+func TestPrepareRejectsNewerSchema(t *testing.T) {
+	dir := t.TempDir()
+	if err := save(dir, Manifest{SchemaVersion: SchemaVersion + 1}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Prepare(context.Background(), dir, "1.0.0")
+	var newer *ErrNewerSchema
+	if !errors.As(err, &newer) || newer.Found != SchemaVersion+1 {
+		t.Errorf("Expected ErrNewerSchema, got %v", err)
+	}
+}
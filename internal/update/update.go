@@ -0,0 +1,127 @@
+// Package update checks GitHub releases for a newer version of the app. It
+// is opt-in: nothing is requested until the user enables update checks, and
+// never in offline mode, for air-gapped installs.
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// DefaultURL is the GitHub API endpoint of the latest release
+const DefaultURL = "https://api.github.com/repos/marcoshack/netmonitor/releases/latest"
+
+// cacheFor is how long a successful check is reused, so repeated calls
+// from the UI don't run into GitHub's rate limit
+const cacheFor = time.Hour
+
+// Checker compares the running version with the latest release
+type Checker struct {
+	Current string // Version of the running app
+	URL     string
+	Client  *http.Client
+
+	mu   sync.Mutex
+	last models.UpdateInfo
+}
+
+// New returns a checker of the GitHub releases of the app
+func New(current string) *Checker {
+	return &Checker{Current: current, URL: DefaultURL, Client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+// release is the part of a GitHub release the checker uses
+type release struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Body        string    `json:"body"`
+	HTMLURL     string    `json:"html_url"`
+	PublishedAt time.Time `json:"published_at"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+}
+
+// Check fetches the latest release, or returns the previous answer if it is
+// less than an hour old
+func (c *Checker) Check(ctx context.Context) (models.UpdateInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last.Latest != "" && time.Since(time.UnixMilli(c.last.CheckedAt)) < cacheFor {
+		return c.last, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return models.UpdateInfo{Current: c.Current}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "netmonitor/"+c.Current)
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return models.UpdateInfo{Current: c.Current}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return models.UpdateInfo{Current: c.Current}, fmt.Errorf("release check failed: %s", resp.Status)
+	}
+	var r release
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return models.UpdateInfo{Current: c.Current}, fmt.Errorf("invalid release: %w", err)
+	}
+	if r.TagName == "" || r.Draft || r.Prerelease {
+		return models.UpdateInfo{Current: c.Current}, fmt.Errorf("no published release")
+	}
+
+	c.last = models.UpdateInfo{
+		Current:     c.Current,
+		Latest:      strings.TrimPrefix(r.TagName, "v"),
+		Available:   Newer(r.TagName, c.Current),
+		Name:        r.Name,
+		Notes:       r.Body,
+		URL:         r.HTMLURL,
+		PublishedAt: r.PublishedAt.UnixMilli(),
+		CheckedAt:   time.Now().UnixMilli(),
+	}
+	return c.last, nil
+}
+
+// Newer reports whether version a is newer than b. Versions are dotted
+// numbers with an optional "v" prefix; a pre-release suffix such as "-rc1"
+// sorts before the release itself. Unparseable parts count as zero.
+func Newer(a, b string) bool {
+	an, apre := parseVersion(a)
+	bn, bpre := parseVersion(b)
+	for i := range max(len(an), len(bn)) {
+		var x, y int
+		if i < len(an) {
+			x = an[i]
+		}
+		if i < len(bn) {
+			y = bn[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return apre == "" && bpre != ""
+}
+
+func parseVersion(v string) ([]int, string) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	v, pre, _ := strings.Cut(v, "-")
+	v, _, _ = strings.Cut(v, "+") // Build metadata doesn't order versions
+	var nums []int
+	for _, part := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(part)
+		nums = append(nums, n)
+	}
+	return nums, pre
+}
@@ -0,0 +1,48 @@
+package update
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewer(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want bool
+	}{
+		{"v1.2.0", "1.1.9", true},
+		{"1.10.0", "1.9.0", true},
+		{"1.2", "1.2.0", false},
+		{"1.2.0", "1.2.0-rc1", true},
+		{"1.2.0-rc1", "1.2.0", false},
+		{"1.0.0", "1.0.1", false},
+	} {
+		if got := Newer(c.a, c.b); got != c.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"tag_name": "v1.3.0", "name": "NetMonitor 1.3", "body": "- Faster", "html_url": "https://example.com/r", "published_at": "2026-01-02T03:04:05Z"}`))
+	}))
+	defer srv.Close()
+
+	c := New("1.2.0")
+	c.URL = srv.URL
+	info, err := c.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Available || info.Latest != "1.3.0" || info.Notes != "- Faster" || info.Current != "1.2.0" {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+	if _, err := c.Check(context.Background()); err != nil || calls != 1 {
+		t.Errorf("Expected the answer to be cached, %d calls (%v)", calls, err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Kiosk mode turns the main window into a full-screen, read-only dashboard
+// for wall displays. The frontend hides every control and refreshes on its
+// own while the "kiosk-mode" event reports it enabled.
+
+// EnterKioskMode switches the window to the full-screen dashboard
+func (a *App) EnterKioskMode() {
+	a.setKiosk(true)
+}
+
+// ExitKioskMode restores the regular window
+func (a *App) ExitKioskMode() {
+	a.setKiosk(false)
+}
+
+// IsKioskMode reports whether the dashboard is in kiosk mode
+func (a *App) IsKioskMode() bool {
+	return a.kiosk.Load()
+}
+
+func (a *App) setKiosk(enabled bool) {
+	if a.kiosk.Swap(enabled) == enabled || a.ctx == nil {
+		return
+	}
+	if enabled {
+		a.ShowWindow()
+		runtime.WindowFullscreen(a.ctx)
+	} else {
+		runtime.WindowUnfullscreen(a.ctx)
+	}
+	runtime.EventsEmit(a.ctx, "kiosk-mode", enabled)
+}
@@ -6,30 +6,41 @@ import (
 	"flag"
 	"os"
 	"path/filepath"
+	_ "time/tzdata" // Region time zones on systems without a zone database
 
 	"github.com/getlantern/systray"
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
 
+	"github.com/marcoshack/netmonitor/internal/chaos"
 	"github.com/marcoshack/netmonitor/internal/logger"
+	"github.com/marcoshack/netmonitor/internal/state"
 )
 
 //go:embed all:frontend/dist
 var assets embed.FS
 
+// Version is the app version, set at build time with
+// -ldflags "-X main.Version=..."
+var Version = "1.0.0"
+
 func main() {
+	// Subcommands run without the UI
+	if len(os.Args) > 1 && os.Args[1] == "generate" {
+		os.Exit(runGenerate(os.Args[2:]))
+	}
+
 	// Parse CLI flags
 	debug := flag.Bool("debug", false, "Enable debug logging")
+	exportPreset := flag.String("export-preset", "", "Run the named export preset and exit")
+	kiosk := flag.Bool("kiosk", false, "Start in full-screen read-only dashboard mode")
+	readOnly := flag.Bool("read-only", false, "Disable config changes, for shared machines")
+	offline := flag.Bool("offline", false, "Never reach the internet on its own, e.g. to check for updates, for air-gapped installs")
+	chaosSpec := flag.String("chaos", "", "Inject faults into tests for development, e.g. failure=0.1,timeout=0.05,spike=0.2")
 	flag.Parse()
 
-	// Get User Config Directory
-	configDir, err := os.UserConfigDir()
-	if err != nil {
-		println("Error getting user config directory:", err.Error())
-		configDir = "." // Fallback to current directory
-	}
-	appDir := filepath.Join(configDir, "NetMonitor")
+	appDir := state.DefaultDir()
 	_ = os.MkdirAll(appDir, 0755)
 
 	// Initialize Logger
@@ -45,8 +56,42 @@ func main() {
 	// Create context with logger
 	ctx := l.WithContext(context.Background())
 
+	// Check the data directory layout before anything reads it
+	if _, err := state.Prepare(ctx, appDir, Version); err != nil {
+		l.Error().Err(err).Str("dir", appDir).Msg("Data directory is not usable")
+		println("Error preparing data directory:", err.Error())
+		closeLogger()
+		os.Exit(1)
+	}
+
 	// Create an instance of the app structure
 	app := NewApp(ctx, appDir)
+	app.readOnly = *readOnly
+	app.offline = *offline
+
+	if *chaosSpec != "" {
+		cfg, err := chaos.Parse(*chaosSpec)
+		if err != nil {
+			println("Invalid --chaos:", err.Error())
+			closeLogger()
+			os.Exit(2)
+		}
+		l.Warn().Interface("chaos", cfg).Msg("Chaos mode enabled, results include injected faults")
+		// Innermost, so the other middlewares see injected results like real ones
+		app.Monitor.Runner.Use(chaos.New(ctx, cfg).Middleware())
+	}
+
+	if *exportPreset != "" {
+		code := runExportPreset(app, *exportPreset)
+		closeLogger()
+		os.Exit(code)
+	}
+
+	startState := options.Normal
+	if *kiosk {
+		app.kiosk.Store(true)
+		startState = options.Fullscreen
+	}
 
 	// Create application with options
 	err = wails.Run(&options.App{
@@ -57,6 +102,7 @@ func main() {
 			Assets: assets,
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
+		WindowStartState: startState,
 		OnStartup:        app.Startup,
 		OnDomReady:       app.DomReady,
 		OnShutdown:       app.Shutdown,
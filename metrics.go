@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/marcoshack/netmonitor/internal/metrics"
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// metricsSource serves the app's state through the Prometheus exporter
+type metricsSource struct {
+	app *App
+}
+
+func (s metricsSource) Endpoints() []metrics.Endpoint {
+	var endpoints []metrics.Endpoint
+	for regionName, region := range s.app.Config.Regions {
+		for _, ep := range region.Endpoints {
+			endpoints = append(endpoints, metrics.Endpoint{
+				ID:     s.app.GenerateEndpointID(ep.Address, ep.Type),
+				Name:   ep.Name,
+				Region: regionName,
+				Type:   string(ep.Type),
+			})
+		}
+	}
+	return endpoints
+}
+
+func (s metricsSource) States() map[string]models.EndpointState {
+	return s.app.Monitor.CurrentStates()
+}
+
+func (s metricsSource) Availability() map[string]float64 {
+	availability := make(map[string]float64)
+	for _, agg := range s.app.Live.Day() {
+		if agg.Count > 0 {
+			availability[agg.EndpointID] = float64(agg.Count-agg.Failures) / float64(agg.Count)
+		}
+	}
+	return availability
+}
+
+func (s metricsSource) Queue() (int, int) {
+	return s.app.Monitor.QueueDepth()
+}
+
+func (s metricsSource) StorageBytes() int64 {
+	stats, _ := s.app.Storage.Stats()
+	return stats.Bytes
+}
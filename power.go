@@ -0,0 +1,72 @@
+package main
+
+import (
+	"slices"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/i18n"
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/marcoshack/netmonitor/internal/monitor"
+	"github.com/marcoshack/netmonitor/internal/power"
+	"github.com/rs/zerolog/log"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// With the battery setting, monitoring slows down, runs lighter tests or
+// pauses while the computer runs on battery and resumes in full on AC power.
+// Every switch is recorded as an annotation, so gaps and lighter results in
+// the history can be told apart from network problems.
+
+// powerPollInterval is how often the power source is checked
+const powerPollInterval = 30 * time.Second
+
+// watchPower follows the power source while the battery setting is set
+func (a *App) watchPower() {
+	ticker := time.NewTicker(powerPollInterval)
+	defer ticker.Stop()
+	var last *bool // Power source of the previous check, nil if unknown
+	for {
+		if a.Config.Settings.Battery == nil {
+			last = nil
+			a.applyPower(false)
+		} else if onBattery, err := power.OnBattery(); err != nil {
+			log.Ctx(a.ctx).Warn().Err(err).Msg("Failed to read the power source")
+		} else {
+			if last != nil && *last != onBattery {
+				a.annotatePower(onBattery)
+			}
+			last = &onBattery
+			a.applyPower(onBattery)
+		}
+		select {
+		case <-ticker.C:
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+// applyPower switches the monitor to the battery settings or back
+func (a *App) applyPower(onBattery bool) {
+	a.Monitor.SetOnBattery(onBattery)
+	if b := a.Config.Settings.Battery; onBattery && b != nil && b.Pause {
+		if !slices.Contains(a.Monitor.PauseReasons(), monitor.PauseReasonBattery) {
+			a.Monitor.Pause(monitor.PauseReasonBattery)
+		}
+	} else {
+		a.Monitor.Resume(monitor.PauseReasonBattery)
+	}
+}
+
+// annotatePower records a switch of the power source
+func (a *App) annotatePower(onBattery bool) {
+	annotation := models.Annotation{Ts: time.Now().UnixMilli(), Kind: models.AnnotationAC, Text: i18n.T("annotation.ac")}
+	if onBattery {
+		annotation.Kind, annotation.Text = models.AnnotationBattery, i18n.T("annotation.battery")
+	}
+	log.Ctx(a.ctx).Info().Str("kind", annotation.Kind).Msg("Power source changed")
+	if err := a.Storage.SaveAnnotation(annotation); err != nil {
+		log.Ctx(a.ctx).Error().Err(err).Msg("Failed to save annotation")
+	}
+	runtime.EventsEmit(a.ctx, "annotation", annotation)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/marcoshack/netmonitor/internal/i18n"
+)
+
+// Read-only mode, enabled with --read-only or the read_only setting, keeps
+// the app usable as a status display on shared machines. Bindings that change
+// the config refuse to run and the frontend hides their controls.
+
+// IsReadOnly reports whether config changes are disabled
+func (a *App) IsReadOnly() bool {
+	return a.readOnly || a.Config.Settings.ReadOnly
+}
+
+// writeDenied returns the error bindings that change the config report in
+// read-only mode, or an empty string when changes are allowed
+func (a *App) writeDenied() string {
+	if a.IsReadOnly() {
+		return i18n.T("error.read_only")
+	}
+	return ""
+}
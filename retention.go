@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/marcoshack/netmonitor/internal/config"
+	"github.com/marcoshack/netmonitor/internal/data"
+	"github.com/marcoshack/netmonitor/internal/i18n"
+	"github.com/marcoshack/netmonitor/internal/models"
+)
+
+// cleanupIfDue runs the retention cleanup on the first cycle of each day
+func (a *App) cleanupIfDue(now time.Time) {
+	a.Retention.RunIfDue(now, a.Config)
+}
+
+// UndoLastCleanup restores the files moved to the trash by the most recent
+// cleanup. Cleanups only trash files when cleanup_grace_days is set. The
+// restored days are removed again by the next cleanup unless the retention
+// period is extended or they are held.
+func (a *App) UndoLastCleanup() string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	_, err := a.Retention.Undo()
+	if errors.Is(err, data.ErrNothingToUndo) {
+		return i18n.T("error.nothing_to_undo")
+	}
+	if err != nil {
+		return i18n.T("error.undo_cleanup", err)
+	}
+	return ""
+}
+
+// GetRetentionHolds returns the days kept regardless of the retention setting
+func (a *App) GetRetentionHolds() []models.RetentionHold {
+	return slices.Clone(a.Config.RetentionHolds)
+}
+
+// AddRetentionHold keeps a day or range of days forever
+func (a *App) AddRetentionHold(hold models.RetentionHold) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	if err := data.ValidateRetentionHold(hold); err != nil {
+		return i18n.T("error.invalid_retention_hold", err)
+	}
+	a.Config.RetentionHolds = append(a.Config.RetentionHolds, hold)
+	if err := config.SaveConfig(a.ConfigPath, a.Config); err != nil {
+		return i18n.T("error.save_config", err)
+	}
+	return ""
+}
+
+// RemoveRetentionHold releases the hold at index, as listed by
+// GetRetentionHolds. Its days are removed by the next daily cleanup if they
+// are past the retention period.
+func (a *App) RemoveRetentionHold(index int) string {
+	if msg := a.writeDenied(); msg != "" {
+		return msg
+	}
+	if index < 0 || index >= len(a.Config.RetentionHolds) {
+		return i18n.T("error.retention_hold_not_found")
+	}
+	a.Config.RetentionHolds = slices.Delete(slices.Clone(a.Config.RetentionHolds), index, index+1)
+	if err := config.SaveConfig(a.ConfigPath, a.Config); err != nil {
+		return i18n.T("error.save_config", err)
+	}
+	return ""
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"cmp"
 	_ "embed"
 	"log"
+	"math"
 	"os"
+	"slices"
+	"time"
 
 	"github.com/getlantern/systray"
+	"github.com/marcoshack/netmonitor/internal/i18n"
+	"github.com/marcoshack/netmonitor/internal/models"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
@@ -23,12 +29,16 @@ func (a *App) onReady() {
 	}
 
 	systray.SetTitle("NetMonitor")
-	systray.SetTooltip("NetMonitor - Network Monitoring Tool")
+	systray.SetTooltip(i18n.T("tray.tooltip"))
 
 	// Add menu items
-	mShow := systray.AddMenuItem("Show App", "Show the application window")
+	mShow := systray.AddMenuItem(i18n.T("tray.show"), i18n.T("tray.show.tooltip"))
+	mKiosk := systray.AddMenuItem(i18n.T("tray.dashboard"), i18n.T("tray.dashboard.tooltip"))
+	mUpdate := a.addTrayUpdate()
 	systray.AddSeparator()
-	mQuit := systray.AddMenuItem("Exit", "Quit the application")
+	mQuit := systray.AddMenuItem(i18n.T("tray.exit"), i18n.T("tray.exit.tooltip"))
+
+	go a.updateTrayHealth()
 
 	// Handle menu actions in a goroutine
 	go func() {
@@ -36,6 +46,10 @@ func (a *App) onReady() {
 			select {
 			case <-mShow.ClickedCh:
 				a.ShowWindow()
+			case <-mKiosk.ClickedCh:
+				a.setKiosk(!a.IsKioskMode())
+			case <-mUpdate.ClickedCh:
+				a.openTrayUpdate()
 			case <-mQuit.ClickedCh:
 				log.Println("Exit menu clicked, quitting...")
 				// Quit systray first - this will trigger onExit
@@ -46,6 +60,24 @@ func (a *App) onReady() {
 	}()
 }
 
+// updateTrayHealth keeps the tray tooltip showing the lowest health score of
+// the current hour, so the worst endpoint is one hover away
+func (a *App) updateTrayHealth() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		aggs := a.GetCurrentAggregates("hour")
+		if len(aggs) > 0 {
+			worst := slices.MinFunc(aggs, func(x, y models.AggregatedResult) int {
+				return cmp.Compare(x.Health, y.Health)
+			})
+			name := a.endpointNames()[worst.EndpointID]
+			systray.SetTooltip(i18n.T("tray.tooltip.health", math.Round(worst.Health), name))
+		}
+		<-ticker.C
+	}
+}
+
 // onExit is called when the system tray is exiting
 func (a *App) onExit() {
 	log.Println("System tray exiting, quitting Wails app...")
@@ -65,6 +97,7 @@ func (a *App) InitSystemTray() {
 // ShowWindow shows the application window
 func (a *App) ShowWindow() {
 	if a.ctx != nil {
+		a.windowHidden.Store(false)
 		// Show and unminimize the window
 		runtime.WindowShow(a.ctx)
 		runtime.WindowUnminimise(a.ctx)
@@ -77,6 +110,7 @@ func (a *App) ShowWindow() {
 // HideWindow hides the application window
 func (a *App) HideWindow() {
 	if a.ctx != nil {
+		a.windowHidden.Store(true)
 		runtime.WindowHide(a.ctx)
 	}
 }
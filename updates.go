@@ -0,0 +1,112 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/getlantern/systray"
+	"github.com/marcoshack/netmonitor/internal/i18n"
+	"github.com/marcoshack/netmonitor/internal/models"
+	"github.com/rs/zerolog/log"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Update checks are opt-in with the update_check setting. Offline mode,
+// enabled with --offline or the offline setting, overrides it for air-gapped
+// installs so the app never reaches the internet on its own.
+
+// updateCheckInterval is how often the background check runs
+const updateCheckInterval = 24 * time.Hour
+
+// IsOffline reports whether the app must not reach the internet on its own
+func (a *App) IsOffline() bool {
+	return a.offline || a.Config.Settings.Offline
+}
+
+// CheckForUpdates compares the running version with the latest release on
+// GitHub, including its release notes. It only contacts GitHub when update
+// checks are enabled and the app isn't offline; otherwise Error says why.
+func (a *App) CheckForUpdates() models.UpdateInfo {
+	if a.IsOffline() {
+		return models.UpdateInfo{Current: Version, Error: i18n.T("update.offline")}
+	}
+	if !a.Config.Settings.UpdateCheck {
+		return models.UpdateInfo{Current: Version, Error: i18n.T("update.disabled")}
+	}
+	info, err := a.Updates.Check(a.ctx)
+	if err != nil {
+		log.Ctx(a.ctx).Warn().Err(err).Msg("Failed to check for updates")
+		info.Error = err.Error()
+	}
+	return info
+}
+
+// watchUpdates checks for updates at startup and then daily while checks
+// are enabled, prompting through the UI and the tray once per new version
+func (a *App) watchUpdates() {
+	ticker := time.NewTicker(updateCheckInterval)
+	defer ticker.Stop()
+	notified := ""
+	for {
+		if !a.IsOffline() && a.Config.Settings.UpdateCheck {
+			info := a.CheckForUpdates()
+			if info.Available && info.Latest != notified {
+				notified = info.Latest
+				log.Ctx(a.ctx).Info().Str("current", info.Current).Str("latest", info.Latest).Msg("Update available")
+				runtime.EventsEmit(a.ctx, "update-available", info)
+				a.showTrayUpdate(info)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+// trayUpdate is the tray item announcing an update. The tray may come up
+// after the first check, so the update found is kept until the item exists.
+type trayUpdate struct {
+	mu   sync.Mutex
+	item *systray.MenuItem
+	info *models.UpdateInfo
+}
+
+// showTrayUpdate reveals the tray item that opens the release page
+func (a *App) showTrayUpdate(info models.UpdateInfo) {
+	t := &a.trayUpdate
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.info = &info
+	if t.item != nil {
+		t.item.SetTitle(i18n.T("tray.update", info.Latest))
+		t.item.Show()
+	}
+}
+
+// addTrayUpdate adds the tray item announcing updates, hidden until one is
+// found
+func (a *App) addTrayUpdate() *systray.MenuItem {
+	t := &a.trayUpdate
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.item = systray.AddMenuItem(i18n.T("tray.update", ""), i18n.T("tray.update.tooltip"))
+	if t.info != nil {
+		t.item.SetTitle(i18n.T("tray.update", t.info.Latest))
+	} else {
+		t.item.Hide()
+	}
+	return t.item
+}
+
+// openTrayUpdate opens the release page of the update shown in the tray
+func (a *App) openTrayUpdate() {
+	t := &a.trayUpdate
+	t.mu.Lock()
+	info := t.info
+	t.mu.Unlock()
+	if info != nil && info.URL != "" {
+		runtime.BrowserOpenURL(a.ctx, info.URL)
+	}
+}